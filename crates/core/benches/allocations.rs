@@ -0,0 +1,102 @@
+//! Heap allocations per frame of `FrameCache::render` for a 24 fixture rig, idle and with
+//! every fixture changing, plus the cost of timing a tick. A busy frame should allocate only
+//! the universes handed to the DMX module and the list holding them.
+//!
+//! Only the frame cache is covered, not the rest of the console's `update()`: the tick still
+//! allocates the `FixtureValuesUpdated` and `PixelDataUpdated` lists, the static values and
+//! effect lists cloned from the current cue, and the writes of each `PlaybackMerge`.
+//!
+//! ```sh
+//! cargo bench -p halo-core --bench allocations
+//! ```
+
+use std::alloc::{GlobalAlloc, Layout, System};
+use std::collections::{HashMap, HashSet};
+use std::hint::black_box;
+use std::sync::atomic::{AtomicU64, Ordering};
+use std::time::Duration;
+
+use halo_core::{auto_patch, FrameCache, PatchSpec, TickHistogram};
+use halo_fixtures::{ChannelType, Fixture, FixtureLibrary};
+
+const FRAMES: u64 = 10_000;
+
+/// The system allocator, counting every allocation
+struct Counting;
+
+static ALLOCATIONS: AtomicU64 = AtomicU64::new(0);
+
+unsafe impl GlobalAlloc for Counting {
+    unsafe fn alloc(&self, layout: Layout) -> *mut u8 {
+        ALLOCATIONS.fetch_add(1, Ordering::Relaxed);
+        System.alloc(layout)
+    }
+
+    unsafe fn dealloc(&self, ptr: *mut u8, layout: Layout) {
+        System.dealloc(ptr, layout)
+    }
+
+    unsafe fn realloc(&self, ptr: *mut u8, layout: Layout, new_size: usize) -> *mut u8 {
+        ALLOCATIONS.fetch_add(1, Ordering::Relaxed);
+        System.realloc(ptr, layout, new_size)
+    }
+}
+
+#[global_allocator]
+static GLOBAL: Counting = Counting;
+
+fn rig() -> Vec<Fixture> {
+    let specs: Vec<PatchSpec> = (0..24)
+        .map(|i| PatchSpec {
+            name: format!("Par {i}"),
+            profile_id: "shehds-rgbw-par".to_string(),
+            address: None,
+            mode: None,
+            pan_tilt_limits: None,
+        })
+        .collect();
+    auto_patch(&specs, &[1, 2], &FixtureLibrary::new())
+        .expect("24 PARs fit in two universes")
+        .fixtures
+}
+
+/// Allocations per frame over `FRAMES` calls of `frame`, after one to warm up
+fn run(name: &str, mut frame: impl FnMut(u64)) -> f64 {
+    frame(0);
+    let before = ALLOCATIONS.load(Ordering::Relaxed);
+    for i in 1..=FRAMES {
+        frame(i);
+    }
+    let per_frame = (ALLOCATIONS.load(Ordering::Relaxed) - before) as f64 / FRAMES as f64;
+    println!("{name:<8} {per_frame:.2} allocations per frame");
+    per_frame
+}
+
+fn main() {
+    let mut fixtures = rig();
+    let mut cache = FrameCache::new();
+
+    run("idle", |_| {
+        black_box(cache.render(black_box(&fixtures), HashMap::new()));
+    });
+
+    let busy = run("busy", |i| {
+        for fixture in &mut fixtures {
+            fixture.set_channel_value(&ChannelType::Dimmer, (i % 256) as u8);
+        }
+        black_box(cache.render(black_box(&fixtures), HashMap::new()));
+    });
+
+    let ticks = TickHistogram::new();
+    run("timing", |i| {
+        ticks.record(black_box(Duration::from_micros(i % 60_000)));
+    });
+
+    // One for each universe handed over and one for the list of them
+    let universes: HashSet<u8> = fixtures.iter().map(|f| f.universe).collect();
+    println!(
+        "a busy frame cache render hands over {} universes, and allocates {:.2} times beyond them",
+        universes.len(),
+        busy - (universes.len() + 1) as f64
+    );
+}
@@ -0,0 +1,97 @@
+//! Render cost of a badly authored frame: a cue running an effect on each of 200 multi-cell
+//! bars, every one changing every frame. Effects are rendered one after another on the
+//! render thread, with no task per effect to schedule or lock to contend on, so the cost
+//! grows with the channels written and nothing else. The frame should stay well inside the
+//! render tick.
+//!
+//! ```sh
+//! cargo bench -p halo-core --bench busy_frame
+//! ```
+
+use std::collections::HashMap;
+use std::hint::black_box;
+use std::time::{Duration, Instant};
+
+use halo_core::{
+    auto_patch, Effect, EffectDistribution, EffectMapping, EffectPlayer, EffectRegistry,
+    EffectRelease, FrameCache, PatchSpec, RhythmState, RENDER_TICK,
+};
+use halo_fixtures::{ChannelType, Fixture, FixtureLibrary};
+
+const FIXTURES: usize = 200;
+const FRAMES: u32 = 2_000;
+
+fn rig() -> Vec<Fixture> {
+    let specs: Vec<PatchSpec> = (0..FIXTURES)
+        .map(|i| PatchSpec {
+            name: format!("Bar {i}"),
+            profile_id: "shehds-led-bar-beam-8x12w".to_string(),
+            address: None,
+            mode: None,
+            pan_tilt_limits: None,
+        })
+        .collect();
+    let universes: Vec<u8> = (1..=64).collect();
+    auto_patch(&specs, &universes, &FixtureLibrary::new())
+        .expect("200 bars fit in 64 universes")
+        .fixtures
+}
+
+/// One effect per fixture, on its dimmer and colors, each a little behind the one before
+fn effects(fixtures: &[Fixture]) -> Vec<EffectMapping> {
+    fixtures
+        .iter()
+        .map(|fixture| EffectMapping {
+            name: format!("{} Chase", fixture.name),
+            effect: Effect::default(),
+            fixture_ids: vec![fixture.id],
+            channel_types: vec![
+                ChannelType::Dimmer,
+                ChannelType::Red,
+                ChannelType::Green,
+                ChannelType::Blue,
+            ],
+            distribution: EffectDistribution::All,
+            release: EffectRelease::Hold,
+        })
+        .collect()
+}
+
+fn rhythm(beat_phase: f64) -> RhythmState {
+    RhythmState {
+        beat_phase,
+        bar_phase: 0.0,
+        phrase_phase: 0.0,
+        beats_per_bar: 4,
+        bars_per_phrase: 4,
+        last_tap_time: None,
+        tap_count: 0,
+    }
+}
+
+fn main() {
+    let mut fixtures = rig();
+    let mappings = effects(&fixtures);
+    let mut player = EffectPlayer::new(EffectRegistry::new());
+    let mut cache = FrameCache::new();
+
+    let mut slowest = Duration::ZERO;
+    let started = Instant::now();
+    for frame in 0..FRAMES {
+        let frame_started = Instant::now();
+        let phase = (frame as f64 / 44.0).fract();
+        player.render(&mappings, |_| rhythm(phase), &mut fixtures);
+        black_box(cache.render(black_box(&fixtures), HashMap::new()));
+        slowest = slowest.max(frame_started.elapsed());
+    }
+    let per_frame = started.elapsed() / FRAMES;
+
+    let channels: usize = fixtures.iter().map(|f| f.channels.len()).sum();
+    println!("{FIXTURES} effects over {channels} channels");
+    println!("mean  {per_frame:?} per frame");
+    println!("worst {slowest:?}");
+    println!(
+        "a busy frame takes {:.1}% of the {RENDER_TICK:?} render tick",
+        per_frame.as_secs_f64() / RENDER_TICK.as_secs_f64() * 100.0
+    );
+}
@@ -0,0 +1,79 @@
+//! Frame rendering cost for an idle 24 fixture rig, rebuilding every universe each frame as
+//! the console used to against the frame cache, which skips fixtures that haven't changed.
+//!
+//! ```sh
+//! cargo bench -p halo-core --bench render
+//! ```
+
+use std::collections::HashMap;
+use std::hint::black_box;
+use std::time::{Duration, Instant};
+
+use halo_core::{auto_patch, FrameCache, PatchSpec};
+use halo_fixtures::{ChannelType, Fixture, FixtureLibrary};
+
+const FRAMES: u32 = 20_000;
+
+fn rig() -> Vec<Fixture> {
+    let specs: Vec<PatchSpec> = (0..24)
+        .map(|i| PatchSpec {
+            name: format!("Par {i}"),
+            profile_id: "shehds-rgbw-par".to_string(),
+            address: None,
+            mode: None,
+            pan_tilt_limits: None,
+        })
+        .collect();
+    let mut fixtures = auto_patch(&specs, &[1, 2], &FixtureLibrary::new())
+        .expect("24 PARs fit in two universes")
+        .fixtures;
+    for fixture in &mut fixtures {
+        fixture.set_channel_value(&ChannelType::Dimmer, 200);
+        fixture.set_channel_value(&ChannelType::Red, 255);
+    }
+    fixtures
+}
+
+/// Every fixture written into fresh universes, every frame
+fn rebuild(fixtures: &[Fixture]) -> HashMap<u8, Vec<u8>> {
+    let mut universes: HashMap<u8, Vec<u8>> = HashMap::new();
+    for fixture in fixtures {
+        let buffer = universes
+            .entry(fixture.universe)
+            .or_insert_with(|| vec![0; 512]);
+        let start = (fixture.start_address - 1) as usize;
+        let values = fixture.get_dmx_values();
+        let end = (start + values.len()).min(512);
+        buffer[start..end].copy_from_slice(&values[..end - start]);
+    }
+    universes
+}
+
+fn run(name: &str, mut frame: impl FnMut()) -> Duration {
+    let started = Instant::now();
+    for _ in 0..FRAMES {
+        frame();
+    }
+    let per_frame = started.elapsed() / FRAMES;
+    println!("{name:<8} {per_frame:?} per frame");
+    per_frame
+}
+
+fn main() {
+    let fixtures = rig();
+
+    let before = run("before", || {
+        black_box(rebuild(black_box(&fixtures)));
+    });
+
+    let mut cache = FrameCache::new();
+    cache.render(&fixtures, HashMap::new());
+    let after = run("after", || {
+        black_box(cache.render(black_box(&fixtures), HashMap::new()));
+    });
+
+    println!(
+        "idle frames are {:.1}x cheaper, and send nothing",
+        before.as_secs_f64() / after.as_secs_f64()
+    );
+}
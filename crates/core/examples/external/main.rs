@@ -0,0 +1,60 @@
+//! Drive a rig with halo as a library: patch two PARs, give them a cue list built in code and
+//! step through it.
+//!
+//! ```sh
+//! cargo run -p halo-core --example external -- 192.168.1.100
+//! ```
+
+use std::net::IpAddr;
+use std::time::Duration;
+
+use halo_core::{ConsoleCommand, ConsoleEvent, Cue, CueList, Engine, EngineOptions, NetworkConfig};
+
+#[tokio::main]
+async fn main() -> anyhow::Result<()> {
+    let source_ip: IpAddr = std::env::args()
+        .nth(1)
+        .unwrap_or_else(|| "127.0.0.1".to_string())
+        .parse()?;
+
+    let mut engine = Engine::start(EngineOptions::new(NetworkConfig::new(
+        source_ip, None, 6454, true,
+    )))
+    .await?;
+    let mut events = engine.take_events().expect("events are only taken once");
+
+    for (name, address) in [("Left PAR", 1), ("Right PAR", 10)] {
+        engine.send(ConsoleCommand::PatchFixture {
+            name: name.to_string(),
+            profile_name: "shehds-rgbw-par".to_string(),
+            universe: 1,
+            address,
+        })?;
+    }
+    engine.send(ConsoleCommand::SetCueLists {
+        cue_lists: vec![CueList {
+            name: "Song".to_string(),
+            cues: vec![
+                Cue::intensity_only("Dark", &[0, 1], 0, Duration::ZERO),
+                Cue::intensity_only("Left", &[0], 255, Duration::from_secs(1)),
+                Cue::intensity_only("Both", &[0, 1], 255, Duration::from_secs(1)),
+            ],
+            audio_file: None,
+            default_fade: None,
+            default_values: vec![],
+            move_in_black: None,
+        }],
+    })?;
+
+    for _ in 0..3 {
+        engine.send(ConsoleCommand::NextCue { list_index: 0 })?;
+        tokio::time::sleep(Duration::from_secs(2)).await;
+    }
+
+    while let Ok(event) = events.try_recv() {
+        if let ConsoleEvent::Error { message } = event {
+            eprintln!("{message}");
+        }
+    }
+    engine.shutdown().await
+}
@@ -0,0 +1,48 @@
+//! A candle flicker, written against halo's effect source API the way an external plugin
+//! would be.
+
+use halo_core::{Effect, EffectContext, EffectSource};
+
+/// Each fixture wanders between 60% and full, independently of the others
+pub struct Flicker {
+    seed: u64,
+    state: u64,
+    levels: Vec<f64>,
+}
+
+impl Flicker {
+    /// Registered with `registry.register("Flicker", Flicker::factory)`
+    pub fn factory(effect: &Effect) -> Box<dyn EffectSource> {
+        // Different phase offsets give differently seeded flickers
+        let seed = 0x2545_f491_4f6c_dd1d ^ effect.params.phase.to_bits();
+        Box::new(Self {
+            seed,
+            state: seed,
+            levels: Vec::new(),
+        })
+    }
+
+    /// xorshift, so the example needs nothing beyond halo
+    fn next_random(&mut self) -> f64 {
+        self.state ^= self.state << 13;
+        self.state ^= self.state >> 7;
+        self.state ^= self.state << 17;
+        (self.state >> 11) as f64 / (1u64 << 53) as f64
+    }
+}
+
+impl EffectSource for Flicker {
+    fn start(&mut self) {
+        self.state = self.seed;
+        self.levels.clear();
+    }
+
+    fn values(&mut self, _context: &EffectContext, fixture_ids: &[usize]) -> Vec<f64> {
+        self.levels.resize(fixture_ids.len(), 1.0);
+        for index in 0..self.levels.len() {
+            let target = 0.6 + 0.4 * self.next_random();
+            self.levels[index] += (target - self.levels[index]) * 0.3;
+        }
+        self.levels.clone()
+    }
+}
@@ -0,0 +1,80 @@
+//! Register an effect from outside halo and run it in a cue: two PARs flickering like candles.
+//!
+//! ```sh
+//! cargo run -p halo-core --example plugin -- 192.168.1.100
+//! ```
+
+mod flicker;
+
+use std::net::IpAddr;
+use std::time::Duration;
+
+use flicker::Flicker;
+use halo_core::{
+    ConsoleCommand, ConsoleEvent, Cue, CueList, Effect, EffectDistribution, EffectMapping,
+    EffectRegistry, EffectRelease, Engine, EngineOptions, NetworkConfig,
+};
+use halo_fixtures::ChannelType;
+
+#[tokio::main]
+async fn main() -> anyhow::Result<()> {
+    let source_ip: IpAddr = std::env::args()
+        .nth(1)
+        .unwrap_or_else(|| "127.0.0.1".to_string())
+        .parse()?;
+
+    let mut effects = EffectRegistry::new();
+    effects.register("Flicker", Flicker::factory);
+    let mut options = EngineOptions::new(NetworkConfig::new(source_ip, None, 6454, true));
+    options.effects = effects;
+
+    let mut engine = Engine::start(options).await?;
+    let mut events = engine.take_events().expect("events are only taken once");
+
+    for (name, address) in [("Left PAR", 1), ("Right PAR", 10)] {
+        engine.send(ConsoleCommand::PatchFixture {
+            name: name.to_string(),
+            profile_name: "shehds-rgbw-par".to_string(),
+            universe: 1,
+            address,
+        })?;
+    }
+
+    let mut candles = Cue::intensity_only("Candles", &[0, 1], 255, Duration::from_secs(1));
+    candles.effects.push(EffectMapping {
+        name: "Candles".to_string(),
+        effect: Effect {
+            source: Some("Flicker".to_string()),
+            ..Effect::default()
+        },
+        fixture_ids: vec![0, 1],
+        channel_types: vec![ChannelType::Dimmer],
+        distribution: EffectDistribution::All,
+        release: EffectRelease::Remove,
+    });
+    engine.send(ConsoleCommand::SetCueLists {
+        cue_lists: vec![CueList {
+            name: "Song".to_string(),
+            cues: vec![
+                candles,
+                Cue::intensity_only("Dark", &[0, 1], 0, Duration::ZERO),
+            ],
+            audio_file: None,
+            default_fade: None,
+            default_values: vec![],
+            move_in_black: None,
+        }],
+    })?;
+
+    for _ in 0..2 {
+        engine.send(ConsoleCommand::NextCue { list_index: 0 })?;
+        tokio::time::sleep(Duration::from_secs(5)).await;
+    }
+
+    while let Ok(event) = events.try_recv() {
+        if let ConsoleEvent::Error { message } = event {
+            eprintln!("{message}");
+        }
+    }
+    engine.shutdown().await
+}
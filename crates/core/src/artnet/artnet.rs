@@ -78,7 +78,7 @@ impl ArtNet {
         }
     }
 
-    pub fn send_data(&self, universe: u8, dmx: Vec<u8>) {
+    pub fn send_data(&self, universe: u8, dmx: Vec<u8>) -> Result<(), anyhow::Error> {
         let command = ArtCommand::Output(Output {
             // length: dmx.len() as u16,
             port_address: universe.into(),
@@ -86,7 +86,8 @@ impl ArtNet {
             ..Output::default()
         });
 
-        let bytes = command.write_to_buffer().unwrap();
-        self.socket.send_to(&bytes, self.destination).unwrap();
+        let bytes = command.write_to_buffer()?;
+        self.socket.send_to(&bytes, self.destination)?;
+        Ok(())
     }
 }
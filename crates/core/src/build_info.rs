@@ -0,0 +1,44 @@
+use std::fmt;
+
+use serde::{Deserialize, Serialize};
+
+/// Which build of halo made something, so bug reports, recordings and saved state can be
+/// traced back to it.
+///
+/// The commit and build date are baked in from `HALO_COMMIT` and `HALO_BUILD_DATE` in the
+/// environment at compile time, e.g. by a release script, and are left out of local builds.
+#[derive(Clone, Debug, PartialEq, Eq, Serialize, Deserialize)]
+pub struct BuildInfo {
+    pub version: String,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub commit: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub built: Option<String>,
+}
+
+impl BuildInfo {
+    /// The build that's running
+    pub fn current() -> Self {
+        Self {
+            version: env!("CARGO_PKG_VERSION").to_string(),
+            commit: option_env!("HALO_COMMIT")
+                .filter(|commit| !commit.is_empty())
+                .map(str::to_string),
+            built: option_env!("HALO_BUILD_DATE")
+                .filter(|built| !built.is_empty())
+                .map(str::to_string),
+        }
+    }
+}
+
+impl fmt::Display for BuildInfo {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        write!(f, "halo {}", self.version)?;
+        match (&self.commit, &self.built) {
+            (Some(commit), Some(built)) => write!(f, " ({commit}, built {built})"),
+            (Some(commit), None) => write!(f, " ({commit})"),
+            (None, Some(built)) => write!(f, " (built {built})"),
+            (None, None) => Ok(()),
+        }
+    }
+}
@@ -0,0 +1,251 @@
+//! Rough sizing of a rig against the machine running it, so a show that's too big for the
+//! hardware says so at startup instead of dropping frames mid-set.
+
+use std::collections::{HashMap, HashSet};
+use std::fmt;
+use std::time::{Duration, Instant};
+
+use halo_fixtures::{Fixture, FixtureLibrary};
+use serde::Serialize;
+
+use crate::{
+    CueList, Effect, EffectDistribution, EffectMapping, EffectPlayer, FrameCache, RhythmState,
+    Settings,
+};
+
+/// How much of the frame budget the estimate may use before it's flagged as tight
+const HEADROOM: f64 = 0.75;
+
+/// What one frame has to get through for a show
+#[derive(Clone, Debug, Default, PartialEq, Serialize)]
+pub struct Workload {
+    pub fixtures: usize,
+    pub universes: usize,
+    /// Channels across every patched fixture
+    pub channels: usize,
+    /// Most effects that can run at once
+    pub effects: usize,
+    /// Most fixture channels those effects can drive at once
+    pub effect_channels: usize,
+}
+
+impl Workload {
+    /// The workload of a patch and its cue lists. Each cue list is taken to be running its
+    /// busiest cue, which overstates shows that never run their lists together.
+    pub fn of(fixtures: &[Fixture], cue_lists: &[CueList]) -> Self {
+        let universes: HashSet<u8> = fixtures.iter().map(|f| f.universe).collect();
+        let mut workload = Self {
+            fixtures: fixtures.len(),
+            universes: universes.len(),
+            channels: fixtures.iter().map(|f| f.channels.len()).sum(),
+            ..Self::default()
+        };
+        for cue_list in cue_lists {
+            let busiest = cue_list
+                .cues
+                .iter()
+                .map(|cue| {
+                    let channels = cue
+                        .effects
+                        .iter()
+                        .map(|e| e.fixture_ids.len() * e.channel_types.len())
+                        .sum::<usize>();
+                    (channels, cue.effects.len())
+                })
+                .max()
+                .unwrap_or_default();
+            workload.effect_channels += busiest.0;
+            workload.effects += busiest.1;
+        }
+        workload
+    }
+}
+
+/// What each unit of work costs on this machine
+#[derive(Clone, Copy, Debug, Default, PartialEq, Serialize)]
+pub struct UnitCosts {
+    /// Rendering one fixture channel into its universe
+    pub channel: Duration,
+    /// Running one effect on one fixture channel
+    pub effect_channel: Duration,
+}
+
+impl UnitCosts {
+    /// Time a few hundred frames of a synthetic rig. Takes well under a second.
+    pub fn measure() -> Self {
+        const FIXTURES: usize = 64;
+        const FRAMES: u32 = 200;
+
+        let library = FixtureLibrary::new();
+        let Some(profile) = library
+            .profiles
+            .get("shehds-rgbw-par")
+            .or_else(|| library.profiles.values().next())
+        else {
+            return Self::default();
+        };
+        let mut fixtures: Vec<Fixture> = (0..FIXTURES)
+            .map(|id| {
+                let channels = profile.channel_layout.clone();
+                let start = (id * channels.len() % 500) as u16 + 1;
+                Fixture::new(
+                    id,
+                    &format!("Bench {id}"),
+                    profile.clone(),
+                    channels,
+                    (id / 32) as u8 + 1,
+                    start,
+                )
+            })
+            .collect();
+        let channels: usize = fixtures.iter().map(|f| f.channels.len()).sum();
+        let mapping = EffectMapping {
+            name: "Bench".to_string(),
+            effect: Effect::default(),
+            fixture_ids: (0..FIXTURES).collect(),
+            channel_types: profile
+                .channel_layout
+                .iter()
+                .map(|c| c.channel_type.clone())
+                .collect(),
+            distribution: EffectDistribution::Wave(0.1),
+            release: Default::default(),
+        };
+        let effect_channels = mapping.fixture_ids.len() * mapping.channel_types.len();
+        let mut rhythm = RhythmState {
+            beat_phase: 0.0,
+            bar_phase: 0.0,
+            phrase_phase: 0.0,
+            beats_per_bar: 4,
+            bars_per_phrase: 4,
+            last_tap_time: None,
+            tap_count: 0,
+        };
+
+        // Effects change every channel every frame, so rendering them times both costs
+        // together. Static frames then time the render alone.
+        let mut player = EffectPlayer::default();
+        let mut cache = FrameCache::new();
+        let started = Instant::now();
+        for frame in 0..FRAMES {
+            rhythm.beat_phase = frame as f64 / FRAMES as f64;
+            player.render(
+                std::slice::from_ref(&mapping),
+                |_| rhythm.clone(),
+                &mut fixtures,
+            );
+            cache.render(&fixtures, HashMap::new());
+        }
+        let with_effects = started.elapsed() / FRAMES;
+
+        let mut cache = FrameCache::new();
+        let started = Instant::now();
+        for frame in 0..FRAMES {
+            for fixture in &mut fixtures {
+                for channel in &mut fixture.channels {
+                    channel.value = frame as u8;
+                }
+            }
+            cache.render(&fixtures, HashMap::new());
+        }
+        let render = started.elapsed() / FRAMES;
+
+        Self {
+            channel: render / channels as u32,
+            effect_channel: with_effects.saturating_sub(render) / effect_channels as u32,
+        }
+    }
+}
+
+/// A workload weighed against the frame budget and the configured limits
+#[derive(Clone, Debug, Serialize)]
+pub struct CapacityEstimate {
+    pub workload: Workload,
+    pub costs: UnitCosts,
+    /// Estimated time to build one frame
+    pub frame_time: Duration,
+    /// Time available for each frame at the target frame rate
+    pub budget: Duration,
+    pub warnings: Vec<String>,
+}
+
+impl CapacityEstimate {
+    pub fn new(workload: Workload, costs: UnitCosts, settings: &Settings) -> Self {
+        let frame_time = costs.channel * workload.channels as u32
+            + costs.effect_channel * workload.effect_channels as u32;
+        let budget = Duration::from_secs_f64(1.0 / settings.target_fps.max(1) as f64);
+
+        let mut warnings = Vec::new();
+        if let Some(max) = settings.max_universes {
+            if workload.universes > max as usize {
+                warnings.push(format!(
+                    "Show uses {} universes, more than the limit of {max}",
+                    workload.universes
+                ));
+            }
+        }
+        if let Some(max) = settings.max_fixtures {
+            if workload.fixtures > max {
+                warnings.push(format!(
+                    "Show patches {} fixtures, more than the limit of {max}",
+                    workload.fixtures
+                ));
+            }
+        }
+        if frame_time > budget {
+            warnings.push(format!(
+                "Frames are estimated to take {:.1}ms, over the {:.1}ms budget for {} fps",
+                frame_time.as_secs_f64() * 1000.0,
+                budget.as_secs_f64() * 1000.0,
+                settings.target_fps
+            ));
+        } else if frame_time > budget.mul_f64(HEADROOM) {
+            warnings.push(format!(
+                "Frames are estimated to take {:.1}ms, close to the {:.1}ms budget for {} fps",
+                frame_time.as_secs_f64() * 1000.0,
+                budget.as_secs_f64() * 1000.0,
+                settings.target_fps
+            ));
+        }
+
+        Self {
+            workload,
+            costs,
+            frame_time,
+            budget,
+            warnings,
+        }
+    }
+
+    /// Share of the frame budget the estimate uses
+    pub fn load(&self) -> f64 {
+        self.frame_time.as_secs_f64() / self.budget.as_secs_f64()
+    }
+}
+
+impl fmt::Display for CapacityEstimate {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        let workload = &self.workload;
+        writeln!(
+            f,
+            "{} fixtures in {} universes, {} channels",
+            workload.fixtures, workload.universes, workload.channels
+        )?;
+        writeln!(
+            f,
+            "Up to {} effects on {} channels",
+            workload.effects, workload.effect_channels
+        )?;
+        writeln!(
+            f,
+            "Estimated frame time: {:.2}ms of {:.2}ms ({:.0}%)",
+            self.frame_time.as_secs_f64() * 1000.0,
+            self.budget.as_secs_f64() * 1000.0,
+            self.load() * 100.0
+        )?;
+        for warning in &self.warnings {
+            writeln!(f, "Warning: {warning}")?;
+        }
+        Ok(())
+    }
+}
@@ -0,0 +1,299 @@
+use std::collections::{BTreeMap, HashMap};
+use std::fmt;
+use std::path::{Path, PathBuf};
+use std::sync::Arc;
+use std::time::{Duration, Instant};
+
+use parking_lot::Mutex;
+use serde::{Deserialize, Serialize};
+
+use crate::build_info::BuildInfo;
+use crate::output::OutputDriver;
+use crate::recording::{read_json_lines, JsonLines};
+
+/// Written at the top of every capture. Readers take any version up to this one.
+pub const CAPTURE_VERSION: u32 = 1;
+
+const CAPTURE_FORMAT: &str = "halo-dmxcap";
+
+/// The first line of a capture
+#[derive(Clone, Debug, PartialEq, Serialize, Deserialize)]
+pub struct CaptureHeader {
+    pub format: String,
+    pub version: u32,
+    /// Wall clock time the first frame went out, RFC 3339
+    pub started_at: String,
+    /// Frames a second universes were sent at
+    pub rate_hz: f64,
+    /// Universes sent at a rate of their own
+    #[serde(default, skip_serializing_if = "BTreeMap::is_empty")]
+    pub universe_rates: BTreeMap<u8, f64>,
+    /// Universes the output was driving when the capture started
+    pub universes: Vec<u8>,
+    /// Hash of the show file playing, if the show came from one
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub show_hash: Option<String>,
+    /// The halo that made the capture, missing from captures made before it was noted
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub build: Option<BuildInfo>,
+}
+
+/// One universe exactly as it went to the driver
+#[derive(Clone, Debug, PartialEq, Serialize, Deserialize)]
+pub struct CapturedFrame {
+    /// Microseconds since the first frame of the capture
+    pub micros: u64,
+    pub universe: u8,
+    #[serde(with = "hex")]
+    pub data: Vec<u8>,
+}
+
+/// Writes a `.dmxcap` file: a header line, then one JSON line per universe frame sent, with
+/// its data as hex.
+///
+/// It's written like a recording, but holds something else: a recording keeps the console's
+/// output as it changed frame to frame, by musical position, for inspecting, where a capture
+/// keeps every universe as the driver was given it, keep-alives and per-universe rates
+/// included, to the microsecond, for sending out again.
+struct CaptureWriter {
+    path: PathBuf,
+    lines: JsonLines,
+    started: Option<Instant>,
+}
+
+/// Captures what the DMX module sends, byte for byte, for replaying a rehearsal or working
+/// out what a node was given.
+///
+/// The handle is shared between the module, which writes each universe as it goes out, and
+/// whatever starts and stops the capture: the `--capture` flag, or a command from the UI or
+/// an OSC trigger.
+#[derive(Clone, Default)]
+pub struct OutputCapture {
+    writer: Arc<Mutex<Option<CaptureWriter>>>,
+    /// Hash of the show file the console is playing, for the header
+    show_hash: Arc<Mutex<Option<String>>>,
+}
+
+impl OutputCapture {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Start capturing to `path`, ending any capture already running
+    pub fn start(&self, path: &Path) -> Result<(), String> {
+        *self.writer.lock() = Some(CaptureWriter {
+            path: path.to_path_buf(),
+            lines: JsonLines::create(path, "capture")?,
+            started: None,
+        });
+        log::info!("Capturing DMX output to {}", path.display());
+        Ok(())
+    }
+
+    /// Stop capturing, returning where the capture went if one was running
+    pub fn stop(&self) -> Option<PathBuf> {
+        let writer = self.writer.lock().take()?;
+        log::info!("Stopped capturing DMX output to {}", writer.path.display());
+        Some(writer.path.clone())
+    }
+
+    /// Note the hash of the show file playing, for the header of the next capture to start
+    pub fn set_show_hash(&self, hash: Option<&str>) {
+        *self.show_hash.lock() = hash.map(str::to_string);
+    }
+
+    /// Where the running capture is going, if there is one
+    pub fn path(&self) -> Option<PathBuf> {
+        self.writer.lock().as_ref().map(|w| w.path.clone())
+    }
+
+    /// Note a universe frame that went out at `now`. The header, from `header` with the show
+    /// hash filled in, is written with the first frame. A capture that can't be written is
+    /// stopped.
+    pub fn record(
+        &self,
+        now: Instant,
+        universe: u8,
+        data: &[u8],
+        header: impl FnOnce() -> CaptureHeader,
+    ) {
+        let mut guard = self.writer.lock();
+        let Some(writer) = guard.as_mut() else {
+            return;
+        };
+        let result = (|| {
+            let started = match writer.started {
+                Some(started) => started,
+                None => {
+                    let mut header = header();
+                    header.show_hash = self.show_hash.lock().clone();
+                    writer.lines.write_line(&header)?;
+                    writer.started = Some(now);
+                    now
+                }
+            };
+            writer.lines.write_line(&CapturedFrame {
+                micros: now.duration_since(started).as_micros() as u64,
+                universe,
+                data: data.to_vec(),
+            })?;
+            writer.lines.flush_after(now)
+        })();
+        if let Err(e) = result {
+            log::error!("{e}, capture stopped");
+            *guard = None;
+        }
+    }
+
+    /// A header for a capture of `universes` starting now
+    pub fn header(
+        rate_hz: f64,
+        universe_rates: BTreeMap<u8, f64>,
+        universes: Vec<u8>,
+    ) -> CaptureHeader {
+        CaptureHeader {
+            format: CAPTURE_FORMAT.to_string(),
+            version: CAPTURE_VERSION,
+            started_at: chrono::Utc::now().to_rfc3339(),
+            rate_hz,
+            universe_rates,
+            universes,
+            show_hash: None,
+            build: Some(BuildInfo::current()),
+        }
+    }
+}
+
+impl fmt::Debug for OutputCapture {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        f.debug_struct("OutputCapture")
+            .field("path", &self.path())
+            .finish()
+    }
+}
+
+/// A capture read back
+#[derive(Clone, Debug)]
+pub struct Capture {
+    pub header: CaptureHeader,
+    pub frames: Vec<CapturedFrame>,
+}
+
+impl Capture {
+    pub fn read(path: &Path) -> Result<Self, String> {
+        let (header, frames) =
+            read_json_lines(path, CAPTURE_FORMAT, CAPTURE_VERSION, "output capture")?;
+        Ok(Self { header, frames })
+    }
+
+    /// How long the capture runs, from its first frame to its last
+    pub fn length(&self) -> Duration {
+        self.frames
+            .last()
+            .map_or(Duration::ZERO, |f| Duration::from_micros(f.micros))
+    }
+
+    /// The frames from `from` on, led by the last frame of each universe before it so the
+    /// rig starts out as it stood at that point
+    pub fn frames_from(&self, from: Duration) -> Vec<CapturedFrame> {
+        let from = from.as_micros() as u64;
+        let start = self.frames.partition_point(|f| f.micros < from);
+        let mut standing: HashMap<u8, &CapturedFrame> = HashMap::new();
+        for frame in &self.frames[..start] {
+            standing.insert(frame.universe, frame);
+        }
+        let mut frames: Vec<CapturedFrame> = standing
+            .into_values()
+            .map(|frame| CapturedFrame {
+                micros: from,
+                ..frame.clone()
+            })
+            .collect();
+        frames.sort_by_key(|f| f.universe);
+        frames.extend_from_slice(&self.frames[start..]);
+        frames
+    }
+
+    /// Send the capture through `driver` with its original timing, `speed` times as fast,
+    /// starting `from` into it. Returns the number of frames sent.
+    ///
+    /// A universe that fails to send is logged and the rest carry on, as in the DMX module.
+    pub async fn play(
+        &self,
+        driver: &mut dyn OutputDriver,
+        speed: f64,
+        from: Duration,
+    ) -> Result<usize, anyhow::Error> {
+        let frames = self.frames_from(from);
+        let from = from.as_micros() as u64;
+        let start = tokio::time::Instant::now();
+
+        driver.open()?;
+        let mut sent = 0;
+        for frame in &frames {
+            let offset = Duration::from_micros(frame.micros - from).div_f64(speed);
+            tokio::time::sleep_until(start + offset).await;
+            match driver.send_universe(frame.universe, &frame.data) {
+                Ok(()) => sent += 1,
+                Err(e) => log::warn!("{}: {}", driver.name(), e),
+            }
+        }
+        driver.close()?;
+        Ok(sent)
+    }
+}
+
+impl fmt::Display for Capture {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        writeln!(
+            f,
+            "Output capture from {} (version {})",
+            self.header.started_at, self.header.version
+        )?;
+        let universes: Vec<String> = self.header.universes.iter().map(u8::to_string).collect();
+        writeln!(f, "  Universes: {}", universes.join(", "))?;
+        writeln!(f, "  Rate:      {}Hz", self.header.rate_hz)?;
+        for (universe, rate) in &self.header.universe_rates {
+            writeln!(f, "             {rate}Hz on universe {universe}")?;
+        }
+        if let Some(build) = &self.header.build {
+            writeln!(f, "  Made by:   {build}")?;
+        }
+        if let Some(hash) = &self.header.show_hash {
+            writeln!(f, "  Show:      {hash}")?;
+        }
+        writeln!(f, "  Frames:    {}", self.frames.len())?;
+        writeln!(f, "  Length:    {:.1}s", self.length().as_secs_f64())
+    }
+}
+
+/// Universe data as a hex string, about half the size of a JSON array of numbers
+mod hex {
+    use serde::{Deserialize, Deserializer, Serializer};
+
+    pub fn serialize<S: Serializer>(data: &[u8], serializer: S) -> Result<S::Ok, S::Error> {
+        let text: String = data.iter().map(|byte| format!("{byte:02x}")).collect();
+        serializer.serialize_str(&text)
+    }
+
+    pub fn deserialize<'de, D: Deserializer<'de>>(deserializer: D) -> Result<Vec<u8>, D::Error> {
+        let text = String::deserialize(deserializer)?;
+        if text.len() % 2 != 0 {
+            return Err(serde::de::Error::custom("odd number of hex digits"));
+        }
+        // By byte, as slicing the string could split a character that isn't a digit at all
+        text.as_bytes()
+            .chunks(2)
+            .map(|pair| {
+                let digit = |byte: u8| (byte as char).to_digit(16);
+                match (digit(pair[0]), digit(pair[1])) {
+                    (Some(high), Some(low)) => Ok((high * 16 + low) as u8),
+                    _ => Err(serde::de::Error::custom(format!(
+                        "'{}' isn't hex",
+                        String::from_utf8_lossy(pair)
+                    ))),
+                }
+            })
+            .collect()
+    }
+}
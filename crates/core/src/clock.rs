@@ -0,0 +1,80 @@
+use std::sync::Arc;
+use std::time::{Duration, Instant};
+
+use chrono::NaiveDateTime;
+use parking_lot::Mutex;
+
+/// Source of the current time for the console and cue manager.
+///
+/// Production code uses [`SystemClock`]; tests and scripted reproductions use [`ManualClock`] so
+/// that time only moves when they say so.
+pub trait Clock: Send + Sync {
+    fn now(&self) -> Instant;
+
+    /// Local wall clock time, for rules that run at a time of day
+    fn local_now(&self) -> NaiveDateTime;
+}
+
+/// Clock backed by `Instant::now()`
+#[derive(Debug, Default, Clone, Copy)]
+pub struct SystemClock;
+
+impl Clock for SystemClock {
+    fn now(&self) -> Instant {
+        Instant::now()
+    }
+
+    fn local_now(&self) -> NaiveDateTime {
+        chrono::Local::now().naive_local()
+    }
+}
+
+/// Clock that only advances when `advance` is called
+#[derive(Debug, Clone)]
+pub struct ManualClock {
+    base: Instant,
+    offset: Arc<Mutex<Duration>>,
+    /// Wall clock time when the offset was zero
+    local_base: Arc<Mutex<NaiveDateTime>>,
+}
+
+impl ManualClock {
+    pub fn new() -> Self {
+        Self {
+            base: Instant::now(),
+            offset: Arc::new(Mutex::new(Duration::ZERO)),
+            local_base: Arc::new(Mutex::new(chrono::Local::now().naive_local())),
+        }
+    }
+
+    /// Set the wall clock to `time` without moving the monotonic clock
+    pub fn set_local_time(&self, time: NaiveDateTime) {
+        *self.local_base.lock() = time - self.elapsed();
+    }
+
+    /// Move the clock forward by `duration`
+    pub fn advance(&self, duration: Duration) {
+        *self.offset.lock() += duration;
+    }
+
+    /// Total time advanced since the clock was created
+    pub fn elapsed(&self) -> Duration {
+        *self.offset.lock()
+    }
+}
+
+impl Default for ManualClock {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+impl Clock for ManualClock {
+    fn now(&self) -> Instant {
+        self.base + *self.offset.lock()
+    }
+
+    fn local_now(&self) -> NaiveDateTime {
+        *self.local_base.lock() + self.elapsed()
+    }
+}
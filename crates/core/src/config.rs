@@ -3,6 +3,7 @@ use std::path::{Path, PathBuf};
 
 use serde::{Deserialize, Serialize};
 
+use crate::realtime::NICENESS_RANGE;
 use crate::Settings;
 
 /// Configuration manager for Halo settings
@@ -51,6 +52,7 @@ pub struct OutputConfigSchema {
     pub dmx_source_ip: ConfigOption<String>,
     pub dmx_dest_ip: ConfigOption<String>,
     pub dmx_port: ConfigOption<u16>,
+    pub dmx_rate_hz: ConfigOption<f32>,
     pub wled_enabled: ConfigOption<bool>,
     pub wled_ip: ConfigOption<String>,
 }
@@ -271,6 +273,13 @@ impl ConfigManager {
                     description: "UDP port for Art-Net output".to_string(),
                     requires_restart: true,
                 },
+                dmx_rate_hz: ConfigOption {
+                    default: 44.0,
+                    valid_range: Some((1.0, 44.0)),
+                    valid_choices: None,
+                    description: "DMX frames per second for each universe".to_string(),
+                    requires_restart: true,
+                },
                 wled_enabled: ConfigOption {
                     default: false,
                     valid_range: None,
@@ -346,6 +355,32 @@ impl ConfigManager {
             }
         }
 
+        if let Some((min, max)) = schema.output.dmx_rate_hz.valid_range {
+            if !(min..=max).contains(&settings.dmx_rate_hz) {
+                errors.push(format!("dmx_rate_hz must be between {} and {}", min, max));
+            }
+            let mut universes: Vec<_> = settings.universe_rate_hz.iter().collect();
+            universes.sort_by_key(|(universe, _)| **universe);
+            for (universe, hz) in universes {
+                if !(min..=max).contains(hz) {
+                    errors.push(format!(
+                        "universe_rate_hz for universe {} must be between {} and {}",
+                        universe, min, max
+                    ));
+                }
+            }
+        }
+
+        let (min, max) = NICENESS_RANGE;
+        if let Some(niceness) = settings.thread_niceness {
+            if !(min..=max).contains(&niceness) {
+                errors.push(format!(
+                    "thread_niceness must be between {} and {}",
+                    min, max
+                ));
+            }
+        }
+
         if errors.is_empty() {
             Ok(())
         } else {
@@ -441,6 +476,26 @@ mod tests {
         assert!(ConfigManager::validate_settings(&settings).is_err());
     }
 
+    #[test]
+    fn test_dmx_rate_validation() {
+        let mut settings = Settings::default();
+        settings.dmx_rate_hz = 30.0;
+        settings.universe_rate_hz.insert(2, 20.0);
+        assert!(ConfigManager::validate_settings(&settings).is_ok());
+
+        // Faster than DMX can refresh
+        settings.universe_rate_hz.insert(3, 60.0);
+        let errors = ConfigManager::validate_settings(&settings).unwrap_err();
+        assert_eq!(
+            errors,
+            vec!["universe_rate_hz for universe 3 must be between 1 and 44"]
+        );
+
+        settings.universe_rate_hz.clear();
+        settings.dmx_rate_hz = 0.0;
+        assert!(ConfigManager::validate_settings(&settings).is_err());
+    }
+
     #[test]
     fn test_schema_completeness() {
         let schema = ConfigManager::schema();
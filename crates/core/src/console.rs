@@ -1,28 +1,72 @@
-use std::collections::HashMap;
+use std::collections::{BTreeMap, HashMap, HashSet};
 use std::sync::Arc;
-use std::time::{Duration, Instant};
+use std::time::Duration;
 
-use halo_fixtures::{Fixture, FixtureLibrary};
+use halo_fixtures::{
+    split_16, ChannelType, Fixture, FixtureLibrary, FixtureProfile, FixtureType, Orientation,
+    ProfileLoad,
+};
 use tokio::sync::{mpsc, Mutex, RwLock};
 use tokio::task::JoinHandle;
 
 use crate::artnet::network_config::NetworkConfig;
 use crate::audio::device_enumerator;
+use crate::build_info::BuildInfo;
+use crate::capture::OutputCapture;
+use crate::clock::{Clock, SystemClock};
+use crate::contributions::{ContributionSource, ContributionTrace, SourceValue};
+use crate::cue::chase::{Chase, ChasePlayer};
+use crate::cue::crossfade::{CrossfadeAction, Crossfader};
 use crate::cue::cue::Cue;
 use crate::cue::cue_manager::{CueManager, PlaybackState};
+use crate::cue::fade::{CueFade, FadeKey};
+use crate::cue::learn::apply_learned;
+use crate::cue::look::{expand_looks, Looks};
+use crate::cue::position::resolve_positions;
+use crate::cue::variation::VariationPicker;
+use crate::disable::DisabledOutputs;
+use crate::dmx_import::{import_universe, DmxImport};
+use crate::effect::player::EffectPlayer;
+use crate::effect::source::EffectRegistry;
+use crate::exclusion::{ExclusionConflict, ExclusionGroup, ExclusionGroups};
+use crate::fixture_command::FixtureCommandRunner;
+use crate::fixture_stats::{FixtureStats, StatsCounter};
+use crate::flash::FlashLayer;
+use crate::full_on::FullOnLayer;
+use crate::grand_master::{scale_intensity, GrandMaster};
+use crate::manual::ManualLayer;
+use crate::merge::PlaybackMerge;
 use crate::messages::{ConsoleCommand, ConsoleEvent, Settings};
-use crate::midi::midi::{MidiMessage, MidiOverride};
+use crate::midi::midi::{MidiAction, MidiMessage, MidiOverride};
 use crate::modules::{
-    AudioModule, DmxModule, MidiModule, ModuleEvent, ModuleId, ModuleManager, ModuleMessage,
-    SmpteModule,
+    AsyncModule, AudioModule, DmxModule, MidiModule, ModuleEvent, ModuleId, ModuleManager,
+    ModuleMessage, SmpteModule,
 };
+use crate::move_in_black::MoveInBlack;
+use crate::patch::patch_conflicts;
 use crate::pixel::PixelEngine;
 use crate::programmer::Programmer;
-use crate::rhythm::rhythm::RhythmState;
+use crate::realtime::{FrameDrift, FrameTimer, TickHistogram};
+use crate::recording::{DmxRecorder, MusicalPosition};
+use crate::render::{FrameCache, RENDER_TICK};
+use crate::resume::{unix_now, ResumeState, ResumeWriter, RESUME_FADE};
+use crate::rhythm::rhythm::{BeatGridEdit, Meter, RhythmState};
+use crate::safe_mode::recover_panic;
+use crate::schedule::{ScheduledAction, ScheduledEvent, ShowSchedule};
+use crate::show::alias::{alias_collisions, find_fixture};
+use crate::show::show::Show;
 use crate::show::show_manager::ShowManager;
+use crate::show::variables::{ShowVariables, Variable, VARIABLE_OSC_PREFIX};
+use crate::show::workspace::ShowWorkspace;
+use crate::smoothing::ChannelSmoother;
+use crate::solo::SoloLayer;
+use crate::standby::{show_file_hash, MirrorState, StandbyLink};
+use crate::strobe::StrobeLimiter;
 use crate::timecode::timecode::TimeCode;
+use crate::timetable::{Timetable, TimetableAction};
 use crate::tracking_state::TrackingState;
-use crate::{AbletonLinkManager, CueList};
+use crate::trigger::{TriggerDispatcher, TriggerEvent};
+use crate::{analyze_usage, AbletonLinkManager, CueList, FixtureDescription, StaticValue};
 
 pub struct LightingConsole {
     // Core components
@@ -58,9 +102,129 @@ pub struct LightingConsole {
     // Tracking state for tracking console behavior
     tracking_state: Arc<RwLock<TrackingState>>,
 
+    // Crossfade into the running cue's tracked values
+    cue_fade: Arc<RwLock<CueFade>>,
+
+    // Intensity for the cue start a scaled trigger fired, keyed like the crossfade
+    cue_intensity: Arc<RwLock<Option<(FadeKey, f32)>>>,
+
+    // Dark fixtures pre-positioned for the next cue
+    move_in_black: Arc<RwLock<MoveInBlack>>,
+
+    // The running cue's chase
+    chase_player: Arc<RwLock<ChasePlayer>>,
+
+    // Manual fader into the next cue
+    crossfader: Arc<RwLock<Crossfader>>,
+
+    // Momentary flashes rendered over everything else
+    flash_layer: Arc<RwLock<FlashLayer>>,
+
+    // Values from OSC faders and other manual sources, released when they go quiet
+    manual_layer: Arc<RwLock<ManualLayer>>,
+
+    // Fixtures kept lit while everything else is dark
+    solo_layer: Arc<RwLock<SoloLayer>>,
+
+    // Timed fades to black and back, scaling intensity under the emergency full on
+    grand_master: Arc<RwLock<GrandMaster>>,
+
+    // Emergency full on, rendered over everything
+    full_on: Arc<RwLock<FullOnLayer>>,
+
+    // Runs each effect through its registered source
+    effect_player: Arc<RwLock<EffectPlayer>>,
+
+    // Rolls the running cue's variations
+    variations: Arc<RwLock<VariationPicker>>,
+
+    // Strobe rate limits, applied to the final output
+    strobe_limiter: Arc<RwLock<StrobeLimiter>>,
+    // Ramps step changes on smoothed channels, like pan and tilt
+    channel_smoother: Arc<RwLock<ChannelSmoother>>,
+
+    // Fixtures and universes taken out of the output, held over everything
+    disabled_outputs: Arc<RwLock<DisabledOutputs>>,
+
+    // Lamp and reset sequences, parked over everything else while they run
+    fixture_commands: Arc<RwLock<FixtureCommandRunner>>,
+
+    // Position preset and look warnings already logged, so a running cue doesn't repeat them
+    // every frame
+    position_warnings: Arc<RwLock<HashSet<String>>>,
+
+    // The show's named looks, laid under the cues that use them as they run
+    looks: Arc<RwLock<Looks>>,
+
+    // The show's variables, and the running cue as it was when it started
+    variables: Arc<RwLock<ShowVariables>>,
+
+    // Fixtures only one cue list may drive at a time, and which list has each
+    exclusion: Arc<RwLock<ExclusionGroups>>,
+
+    // MIDI and OSC events mapped to cue list actions
+    triggers: Arc<RwLock<TriggerDispatcher>>,
+
+    // Housekeeping run against the show clock
+    schedule: Arc<RwLock<ShowSchedule>>,
+
+    // Last frame sent for each universe, so only changes are rendered
+    frame_cache: Arc<RwLock<FrameCache>>,
+    // Output written to disk frame by frame, for inspecting after the show
+    dmx_recorder: Arc<RwLock<Option<DmxRecorder>>>,
+    // Universes exactly as the DMX module sent them, shared with the module
+    output_capture: OutputCapture,
+    // How long each update tick took
+    render_ticks: Arc<TickHistogram>,
+    // How long each frame took by the show clock, and the worst per cue
+    frame_timer: FrameTimer,
+
+    // Time of day rules for unattended operation
+    timetable: Arc<RwLock<Timetable>>,
+
+    // List the timetable steps through: list index, interval and when the next step is due
+    timetable_loop: Arc<RwLock<Option<(usize, Duration, std::time::Instant)>>>,
+
+    // Where playback state is saved for a restart to pick up from, and when it's next due
+    resume_writer: Arc<RwLock<Option<ResumeWriter>>>,
+
+    // Saved state to restore once the show's cue lists are loaded
+    pending_resume: Arc<RwLock<Option<ResumeState>>>,
+
+    // On time and pan/tilt travel per fixture, for maintenance
+    stats_counter: Arc<RwLock<StatsCounter>>,
+
+    // Cue whose warning the operator has confirmed, so the next Go can run it
+    confirmed_go: Option<(usize, usize)>,
+
+    // Why the console is running without a show, if it is
+    safe_mode: Option<String>,
+
+    // Shows loaded side by side for switching between
+    workspace: ShowWorkspace,
+    // Show to switch to once the outgoing one has faded out, and when
+    show_switch: Option<(String, std::time::Instant)>,
+    // Show switched to in the last update, for the UI to pick up
+    show_activated: Option<String>,
+
+    // Hash of the running show's file, for checking a standby runs the same show
+    show_hash: Option<String>,
+    // Following a primary with output muted, until taking over
+    standby: Option<StandbyLink>,
+    // Where a primary streams its playback for a standby
+    mirror_tx: Option<mpsc::UnboundedSender<MirrorState>>,
+    // Standby taken over or refused in the last update, for the UI to pick up
+    standby_changed: bool,
+
+    // What each layer of the last frame put on each channel
+    contributions: Arc<RwLock<ContributionTrace>>,
+
     // System state
     is_running: bool,
 
+    // Time source for update timing, tap tempo and cue playback
+    clock: Arc<dyn Clock>,
+
     // Internal timing for rhythm state when Link is not active
     last_update_time: std::time::Instant,
     accumulated_beats: f64,
@@ -76,19 +240,60 @@ impl LightingConsole {
         network_config: NetworkConfig,
         settings: Settings,
     ) -> Result<Self, anyhow::Error> {
-        let mut module_manager = ModuleManager::new();
+        let mut dmx = DmxModule::new(network_config);
+        dmx.set_keep_alive(settings.dmx_keep_alive());
+        dmx.set_target_fps(settings.dmx_rate_hz.into());
+        for (universe, fps) in &settings.universe_rate_hz {
+            dmx.set_universe_fps(*universe, (*fps).into());
+        }
+        let capture = OutputCapture::new();
+        dmx.set_capture(capture.clone());
+        let mut console = Self::new_with_output(bpm, Box::new(dmx), settings)?;
+        console.set_output_capture(capture);
+        Ok(console)
+    }
 
+    /// Create a console that sends DMX through `output`, e.g. a [`DmxModule`] with an
+    /// [`crate::SacnDriver`] instead of Art-Net, alongside the usual audio, SMPTE and MIDI modules
+    pub fn new_with_output(
+        bpm: f64,
+        output: Box<dyn AsyncModule>,
+        settings: Settings,
+    ) -> Result<Self, anyhow::Error> {
         // Register async modules
-        module_manager.register_module(Box::new(DmxModule::new(network_config)));
-        module_manager.register_module(Box::new(AudioModule::new()));
-        module_manager.register_module(Box::new(SmpteModule::new(30))); // 30fps default
+        let mut modules: Vec<Box<dyn AsyncModule>> = vec![
+            output,
+            Box::new(AudioModule::new()),
+            Box::new(SmpteModule::new(30)), // 30fps default
+        ];
 
         // Only register MIDI module if enabled and device is not "None"
         if settings.midi_enabled && settings.midi_device != "None" {
-            module_manager.register_module(Box::new(MidiModule::new(settings.midi_device.clone())));
+            modules.push(Box::new(MidiModule::new(settings.midi_device.clone())));
+        }
+
+        Self::new_with_modules(bpm, settings, modules)
+    }
+
+    /// Create a console that drives the given modules instead of the default hardware set.
+    ///
+    /// This is how the integration harness swaps in a recording DMX module.
+    pub fn new_with_modules(
+        bpm: f64,
+        settings: Settings,
+        modules: Vec<Box<dyn AsyncModule>>,
+    ) -> Result<Self, anyhow::Error> {
+        let mut module_manager = ModuleManager::new();
+        for module in modules {
+            module_manager.register_module(module);
         }
 
         let show_manager = ShowManager::new()?;
+        let triggers = TriggerDispatcher::new(settings.triggers.clone());
+        let mut timetable = Timetable::new();
+        for error in timetable.set_rules(&settings.timetable) {
+            log::warn!("{error}");
+        }
 
         Ok(Self {
             show_name: "Untitled Show".to_string(),
@@ -116,7 +321,50 @@ impl LightingConsole {
             settings: Arc::new(RwLock::new(settings)),
             pixel_engine: Arc::new(RwLock::new(PixelEngine::new())),
             tracking_state: Arc::new(RwLock::new(TrackingState::new())),
+            cue_fade: Arc::new(RwLock::new(CueFade::new())),
+            cue_intensity: Arc::new(RwLock::new(None)),
+            move_in_black: Arc::new(RwLock::new(MoveInBlack::new())),
+            chase_player: Arc::new(RwLock::new(ChasePlayer::new())),
+            crossfader: Arc::new(RwLock::new(Crossfader::new())),
+            flash_layer: Arc::new(RwLock::new(FlashLayer::new())),
+            manual_layer: Arc::new(RwLock::new(ManualLayer::new())),
+            solo_layer: Arc::new(RwLock::new(SoloLayer::new())),
+            grand_master: Arc::new(RwLock::new(GrandMaster::new())),
+            full_on: Arc::new(RwLock::new(FullOnLayer::new())),
+            effect_player: Arc::new(RwLock::new(EffectPlayer::default())),
+            variations: Arc::new(RwLock::new(VariationPicker::default())),
+            strobe_limiter: Arc::new(RwLock::new(StrobeLimiter::new())),
+            channel_smoother: Arc::new(RwLock::new(ChannelSmoother::new())),
+            disabled_outputs: Arc::new(RwLock::new(DisabledOutputs::new())),
+            fixture_commands: Arc::new(RwLock::new(FixtureCommandRunner::new())),
+            position_warnings: Arc::new(RwLock::new(HashSet::new())),
+            looks: Arc::new(RwLock::new(Looks::new())),
+            variables: Arc::new(RwLock::new(ShowVariables::default())),
+            exclusion: Arc::new(RwLock::new(ExclusionGroups::new())),
+            triggers: Arc::new(RwLock::new(triggers)),
+            schedule: Arc::new(RwLock::new(ShowSchedule::new())),
+            frame_cache: Arc::new(RwLock::new(FrameCache::new())),
+            dmx_recorder: Arc::new(RwLock::new(None)),
+            output_capture: OutputCapture::new(),
+            render_ticks: Arc::new(TickHistogram::new()),
+            frame_timer: FrameTimer::new(),
+            timetable: Arc::new(RwLock::new(timetable)),
+            timetable_loop: Arc::new(RwLock::new(None)),
+            resume_writer: Arc::new(RwLock::new(None)),
+            pending_resume: Arc::new(RwLock::new(None)),
+            stats_counter: Arc::new(RwLock::new(StatsCounter::new(None))),
+            confirmed_go: None,
+            safe_mode: None,
+            workspace: ShowWorkspace::new(),
+            show_switch: None,
+            show_activated: None,
+            show_hash: None,
+            standby: None,
+            mirror_tx: None,
+            standby_changed: false,
+            contributions: Arc::new(RwLock::new(ContributionTrace::new())),
             is_running: false,
+            clock: Arc::new(SystemClock),
             last_update_time: std::time::Instant::now(),
             accumulated_beats: 0.0,
         })
@@ -124,7 +372,10 @@ impl LightingConsole {
 
     /// Initialize the async console and all modules
     pub async fn initialize(&mut self) -> Result<(), anyhow::Error> {
-        log::info!("Initializing async lighting console...");
+        log::info!(
+            "Initializing async lighting console, {}...",
+            BuildInfo::current()
+        );
 
         // Initialize all modules
         self.module_manager
@@ -148,6 +399,13 @@ impl LightingConsole {
         Ok(())
     }
 
+    /// Replace the time source used by the console and its cue manager
+    pub async fn set_clock(&mut self, clock: Arc<dyn Clock>) {
+        self.last_update_time = clock.now();
+        self.cue_manager.write().await.set_clock(Arc::clone(&clock));
+        self.clock = clock;
+    }
+
     async fn handle_midi_input(
         midi_msg: MidiMessage,
         _rhythm_state: &Arc<RwLock<RhythmState>>,
@@ -192,8 +450,14 @@ impl LightingConsole {
 
     /// Main update loop - call this regularly to process lighting data
     pub async fn update(&mut self) -> Result<Vec<(usize, Vec<(u8, u8, u8)>)>, anyhow::Error> {
+        // Fades are worked out afresh each tick, so there's nothing to cancel on shutdown.
+        // A tick already queued behind it just writes nothing.
+        if !self.is_running {
+            return Ok(Vec::new());
+        }
+
         // Update timing for rhythm state
-        let now = std::time::Instant::now();
+        let now = self.clock.now();
         let delta_time = now.duration_since(self.last_update_time).as_secs_f64();
         self.last_update_time = now;
 
@@ -217,46 +481,339 @@ impl LightingConsole {
             self.update_rhythm_state(self.accumulated_beats).await;
         }
 
+        // Scheduled events can move playback, so run them before rendering it. Safe mode
+        // leaves the lights to the operator.
+        // A standby takes all of that from the primary instead.
+        if self.safe_mode.is_none() {
+            if self.standby.is_some() {
+                self.run_standby(now).await;
+            } else {
+                self.run_resume(now).await;
+                self.run_schedule(now).await;
+                self.run_timetable(now).await;
+            }
+        }
+
+        // A show switch takes over once the outgoing show has been released
+        if let Some((name, at)) = self.show_switch.clone() {
+            if now >= at {
+                self.show_switch = None;
+                self.finish_show_switch(&name).await;
+            }
+        }
+
         // Process current cue if playing - update tracking state
+        let mut chase = None;
+        let mut frame_cue = None;
         {
             let cue_manager = self.cue_manager.read().await;
-            if cue_manager.get_playback_state() == PlaybackState::Playing {
-                if let Some(current_cue) = cue_manager.get_current_cue() {
-                    // Update tracking state with current cue
-                    self.update_tracking_state(current_cue.clone()).await;
+            let playing = cue_manager.get_playback_state() == PlaybackState::Playing;
+            if playing {
+                frame_cue = Some((
+                    cue_manager.get_current_cue_list_idx(),
+                    cue_manager.get_current_cue_index(),
+                ));
+            }
+            self.exclusion
+                .write()
+                .await
+                .begin(playing.then(|| cue_manager.get_current_cue_list_idx()), now);
+            if playing {
+                // A new cue start begins a new crossfade
+                let key = cue_manager.get_current_cue_start_time().map(|started| {
+                    (
+                        cue_manager.get_current_cue_list_idx(),
+                        cue_manager.get_current_cue_index(),
+                        started,
+                    )
+                });
+                let current_cue = {
+                    let mut variables = self.variables.write().await;
+                    cue_manager.get_current_cue_list().and_then(|list| {
+                        let cue = list.cues.get(cue_manager.get_current_cue_index())?;
+                        // Variables changed since the cue started wait for its next run
+                        let cue = match key {
+                            Some(key) => variables.as_started(key, cue),
+                            None => cue.clone(),
+                        };
+                        Some(list.resolve(&cue).at_tempo(&cue_manager.meter()))
+                    })
+                };
+                if let Some(mut cue) = current_cue {
+                    if let Some(key) = key {
+                        self.cue_fade.write().await.track(key, &cue);
+                        self.effect_player
+                            .write()
+                            .await
+                            .cue_started(key, &cue.color_overrides);
+                        let fixtures = self.fixtures.read().await;
+                        let varied = self
+                            .variations
+                            .write()
+                            .await
+                            .values(key, &cue, &fixtures)
+                            .to_vec();
+                        cue.static_values.extend(varied);
+                    }
+
+                    // Update tracking state with current cue, scaled if a trigger asked for it
+                    if let Some((scaled, intensity)) = *self.cue_intensity.read().await {
+                        if key == Some(scaled) {
+                            scale_intensity(
+                                &mut cue.static_values,
+                                &self.fixtures.read().await,
+                                intensity,
+                            );
+                        }
+                    }
+                    let name = cue.name.clone();
+                    let cue_chase = self
+                        .update_tracking_state(
+                            cue,
+                            cue_manager.get_current_cue_list_idx(),
+                            cue_manager.get_current_cue_index(),
+                        )
+                        .await;
+                    if let Some(key) = key {
+                        chase = cue_chase.map(|c| (key, name, c));
+                    }
                 }
             }
         }
 
+        // A blackout or full on hides the cue fade, which carries on underneath per the policy
+        {
+            let hidden = self.full_on.read().await.is_active()
+                || self.grand_master.read().await.output_level(now) <= 0.0;
+            let policy = self.settings.read().await.override_fade_policy;
+            self.cue_fade.write().await.set_hidden(hidden, policy, now);
+        }
+
+        // Take back last frame's overrides, newest first, so playback renders underneath
+        self.disabled_outputs
+            .write()
+            .await
+            .restore(&mut self.fixtures.write().await);
+        self.channel_smoother
+            .write()
+            .await
+            .restore(&mut self.fixtures.write().await);
+        self.strobe_limiter
+            .write()
+            .await
+            .restore(&mut self.fixtures.write().await);
+        self.full_on
+            .write()
+            .await
+            .restore(&mut self.fixtures.write().await);
+        self.grand_master
+            .write()
+            .await
+            .restore(&mut self.fixtures.write().await);
+        self.fixture_commands
+            .write()
+            .await
+            .restore(&mut self.fixtures.write().await);
+        self.solo_layer
+            .write()
+            .await
+            .restore(&mut self.fixtures.write().await);
+        self.flash_layer
+            .write()
+            .await
+            .restore(&mut self.fixtures.write().await);
+        self.manual_layer
+            .write()
+            .await
+            .restore(&mut self.fixtures.write().await);
+        self.crossfader
+            .write()
+            .await
+            .restore(&mut self.fixtures.write().await);
+        self.chase_player
+            .write()
+            .await
+            .restore(&mut self.fixtures.write().await);
+
+        // Note what each layer puts on each channel from here on, for inspecting the output
+        let mut trace = ContributionTrace::new();
+        trace.start(&self.fixtures.read().await);
+        for (fixture_id, channel_type, value, group) in self.exclusion.read().await.dropped() {
+            trace.propose(
+                *fixture_id,
+                channel_type,
+                *value,
+                ContributionSource::Excluded(group.clone()),
+            );
+        }
+
         // Apply accumulated tracking state to fixtures
-        self.apply_tracking_state().await;
+        self.apply_tracking_state(&mut trace).await;
+
+        // Step the running cue's chase over what it tracked
+        if let Some(name) = self.apply_chase(chase, now).await {
+            trace.layer(ContributionSource::Chase(name), &self.fixtures.read().await);
+        }
+
+        // Set up dark fixtures for the next cue
+        self.apply_move_in_black(now).await;
+        trace.layer(ContributionSource::MoveInBlack, &self.fixtures.read().await);
+
+        // Blend towards the next cue while the crossfader is up
+        if self.crossfader.read().await.is_engaged() {
+            let next = self.next_cue_values().await;
+            self.crossfader
+                .write()
+                .await
+                .apply(&mut self.fixtures.write().await, &next);
+            trace.layer(ContributionSource::Crossfade, &self.fixtures.read().await);
+        }
+
+        // What playback put out, for the next fade to start from
+        self.cue_fade
+            .write()
+            .await
+            .note_output(&self.fixtures.read().await);
 
-        // Apply programmer values (highest priority)
+        // Apply programmer values
         self.apply_programmer_values().await;
+        trace.layer(ContributionSource::Programmer, &self.fixtures.read().await);
+
+        // Manual sources, fading back to playback once released
+        {
+            let settings = self.settings.read().await;
+            self.manual_layer.write().await.apply(
+                &mut self.fixtures.write().await,
+                now,
+                settings.manual_release_secs.map(Duration::from_secs_f32),
+                Duration::from_secs_f32(settings.manual_release_fade_secs),
+            );
+        }
+        trace.layer(ContributionSource::Manual, &self.fixtures.read().await);
+
+        // Apply held flashes
+        self.flash_layer
+            .write()
+            .await
+            .apply(&mut self.fixtures.write().await);
+        trace.layer(ContributionSource::Flash, &self.fixtures.read().await);
+
+        // Darken everything outside the solo
+        self.solo_layer
+            .write()
+            .await
+            .apply(&mut self.fixtures.write().await);
+        trace.layer(ContributionSource::Solo, &self.fixtures.read().await);
+
+        // Park channels for running fixture commands
+        let finished = self
+            .fixture_commands
+            .write()
+            .await
+            .apply(&mut self.fixtures.write().await, now);
+        for (fixture_id, command) in finished {
+            log::info!("Fixture {fixture_id} finished {command}");
+        }
+        trace.layer(ContributionSource::Parked, &self.fixtures.read().await);
+
+        // Grand master fades scale everything rendered so far
+        self.grand_master
+            .write()
+            .await
+            .apply(&mut self.fixtures.write().await, now);
+        trace.layer(ContributionSource::GrandMaster, &self.fixtures.read().await);
+
+        // Emergency full on (highest priority)
+        self.full_on
+            .write()
+            .await
+            .apply(&mut self.fixtures.write().await);
+        trace.layer(ContributionSource::FullOn, &self.fixtures.read().await);
+
+        // Strobe limits hold even over full on
+        {
+            let settings = self.settings.read().await;
+            self.strobe_limiter.write().await.apply(
+                &mut self.fixtures.write().await,
+                settings.max_strobe_hz,
+                settings.no_strobe,
+            );
+        }
+        trace.layer(ContributionSource::StrobeLimit, &self.fixtures.read().await);
+
+        // Smooth what's about to go out, so nothing moves faster than its channel allows
+        {
+            let settings = self.settings.read().await;
+            if settings.smooth_channels {
+                self.channel_smoother.write().await.apply(
+                    &mut self.fixtures.write().await,
+                    now,
+                    &settings.channel_smoothing,
+                );
+            } else {
+                self.channel_smoother.write().await.reset();
+            }
+        }
+        trace.layer(ContributionSource::Smoothing, &self.fixtures.read().await);
+
+        // Disabled fixtures stay frozen whatever else is happening
+        self.disabled_outputs
+            .write()
+            .await
+            .apply(&mut self.fixtures.write().await);
+        trace.layer(ContributionSource::Disabled, &self.fixtures.read().await);
+        *self.contributions.write().await = trace;
 
         // Generate and send DMX data
         let pixel_data = self.send_dmx_data().await?;
 
+        // Count fixture wear from what went out
+        {
+            let mut stats_counter = self.stats_counter.write().await;
+            stats_counter.sample(&self.fixtures.read().await, now);
+            stats_counter.save(now, false);
+        }
+
         // Update cue manager
+        let meter = self.meter().await;
         {
             let mut cue_manager = self.cue_manager.write().await;
+            cue_manager.set_meter(meter);
             cue_manager.update();
         }
+        self.save_resume_state(now).await;
+
+        // Time the frame against the tick, naming one that ran past the warning threshold
+        let values = self.render_count().await;
+        let took = self.clock.now().saturating_duration_since(now);
+        let frame = self.frame_timer.record(frame_cue, took, values);
+        if let Some(limit) = self.settings.read().await.slow_frame_ms {
+            if took.as_secs_f64() * 1000.0 > f64::from(limit) {
+                log::warn!("Slow {frame}");
+            }
+        }
 
         Ok(pixel_data)
     }
 
     async fn update_rhythm_state(&self, beat_time: f64) {
-        let mut rhythm = self.rhythm_state.write().await;
-        rhythm.beat_phase = beat_time.fract();
-        rhythm.bar_phase = (beat_time / rhythm.beats_per_bar as f64).fract();
-        rhythm.phrase_phase =
-            (beat_time / (rhythm.beats_per_bar * rhythm.bars_per_phrase) as f64).fract();
+        self.rhythm_state.write().await.set_beat_time(beat_time);
+    }
+
+    /// The rhythm as it will be once a frame sent now has taken `latency` to reach the
+    /// fixtures, so beat-synced output lands on the beat rather than behind it
+    fn rhythm_ahead(&self, rhythm: &RhythmState, latency: Duration) -> RhythmState {
+        let mut ahead = rhythm.clone();
+        if !latency.is_zero() {
+            let beats = latency.as_secs_f64() * self.tempo / 60.0;
+            ahead.set_beat_time(self.accumulated_beats + beats);
+        }
+        ahead
     }
 
     /// Update rhythm state based on internal time when Link isn't available
     async fn update_internal_rhythm(&mut self) {
-        let now = Instant::now();
+        let now = self.clock.now();
         let elapsed = now.duration_since(self.last_update_time).as_secs_f64();
         self.last_update_time = now;
 
@@ -275,10 +832,32 @@ impl LightingConsole {
         self.update_rhythm_state(self.accumulated_beats).await;
     }
 
-    /// Update tracking state with current cue
-    async fn update_tracking_state(&self, cue: crate::cue::cue::Cue) {
+    /// Update tracking state with current cue, returning its chase with anything kept off an
+    /// exclusion group taken out
+    async fn update_tracking_state(
+        &self,
+        mut cue: crate::cue::cue::Cue,
+        list_index: usize,
+        cue_index: usize,
+    ) -> Option<Chase> {
+        self.resolve_cue_presets(&mut cue).await;
+
+        // Keep the cue off fixtures another list has claimed
+        self.exclusion.write().await.filter(
+            list_index,
+            cue_index,
+            &mut cue,
+            &self.fixtures.read().await,
+            self.clock.now(),
+        );
+
         let mut tracking_state = self.tracking_state.write().await;
 
+        // Released fixtures come out of running effects, or the effects would hold them
+        if let Some(release) = &cue.release {
+            tracking_state.release_effects(release, &self.fixtures.read().await);
+        }
+
         if cue.is_blocking {
             // Blocking cue: clear state and apply this cue
             tracking_state.apply_blocking_cue(&cue);
@@ -286,25 +865,255 @@ impl LightingConsole {
             // Non-blocking cue: merge into tracking state
             tracking_state.apply_cue(&cue);
         }
+        cue.chase
+    }
+
+    /// Tracked values once the next cue in the current list has run, for the crossfader
+    async fn next_cue_values(&self) -> Vec<StaticValue> {
+        let (list_index, next) = {
+            let cue_manager = self.cue_manager.read().await;
+            let next = cue_manager.get_current_cue_list().and_then(|list| {
+                list.cue_at_tempo(
+                    cue_manager.get_current_cue_index() + 1,
+                    &cue_manager.meter(),
+                )
+            });
+            (cue_manager.get_current_cue_list_idx(), next)
+        };
+        let Some(mut next) = next else {
+            return Vec::new();
+        };
+        self.resolve_cue_presets(&mut next).await;
+        self.exclusion
+            .read()
+            .await
+            .keep_off(list_index, &mut next.static_values);
+
+        let mut state = self.tracking_state.read().await.clone();
+        if next.is_blocking {
+            state.apply_blocking_cue(&next);
+        } else {
+            state.apply_cue(&next);
+        }
+        state.get_static_values()
+    }
+
+    /// Render this frame of the running cue's chase, if it has one, returning the cue's name
+    async fn apply_chase(
+        &self,
+        chase: Option<(FadeKey, String, Chase)>,
+        now: std::time::Instant,
+    ) -> Option<String> {
+        let mut player = self.chase_player.write().await;
+        let Some((key, name, chase)) = chase else {
+            player.stop();
+            return None;
+        };
+        player.apply(
+            key,
+            &chase,
+            &mut self.fixtures.write().await,
+            self.tempo,
+            now,
+        );
+        Some(name)
+    }
+
+    /// The step the running chase is on and how many steps it has, if a chase is running
+    pub async fn chase_step(&self) -> Option<(usize, usize)> {
+        self.chase_player.read().await.step()
+    }
+
+    /// Pre-position the fixtures the next Go lights while they're dark, if its list moves in
+    /// black
+    async fn apply_move_in_black(&self, now: std::time::Instant) {
+        let next = {
+            let cue_manager = self.cue_manager.read().await;
+            cue_manager.next_go().and_then(|(list_index, cue_index)| {
+                let list = cue_manager.get_cue_list(list_index)?;
+                Some((
+                    list_index,
+                    list.move_in_black?,
+                    list.cue_at_tempo(cue_index, &cue_manager.meter())?,
+                ))
+            })
+        };
+        let Some((list_index, lead, mut next)) = next else {
+            self.move_in_black.write().await.clear();
+            return;
+        };
+        self.resolve_cue_presets(&mut next).await;
+        self.exclusion
+            .read()
+            .await
+            .keep_off(list_index, &mut next.static_values);
+        self.move_in_black.write().await.apply(
+            &mut self.fixtures.write().await,
+            &next.static_values,
+            lead,
+            now,
+        );
+    }
+
+    /// Turn a cue's looks, release and position presets into static values
+    async fn resolve_cue_presets(&self, cue: &mut Cue) {
+        // Looks resolve as the cue runs too, so editing one changes every cue that uses it
+        if !cue.looks.is_empty() {
+            let (values, warnings) = expand_looks(cue, &*self.looks.read().await);
+            cue.static_values = values;
+            self.log_once(warnings).await;
+        }
+
+        // Home values go under everything the cue sets itself
+        if let Some(release) = &cue.release {
+            let mut values = release.values(&self.fixtures.read().await);
+            values.retain(|home| {
+                !cue.static_values
+                    .iter()
+                    .any(|v| v.fixture_id == home.fixture_id && v.channel_type == home.channel_type)
+            });
+            cue.static_values.splice(0..0, values);
+        }
+
+        // Position presets resolve against this venue's settings, leaving the stored cue untouched
+        if !cue.positions.is_empty() {
+            let (values, warnings) = resolve_positions(
+                &cue.positions,
+                &self.fixtures.read().await,
+                &self.settings.read().await.position_presets,
+            );
+            cue.static_values.extend(values);
+            self.log_once(warnings).await;
+        }
+    }
+
+    /// Log each warning the first time it comes up
+    async fn log_once(&self, warnings: Vec<String>) {
+        let mut logged = self.position_warnings.write().await;
+        for warning in warnings {
+            if logged.insert(warning.clone()) {
+                log::warn!("{warning}");
+            }
+        }
     }
 
     /// Apply accumulated tracking state to fixtures
-    async fn apply_tracking_state(&self) {
+    async fn apply_tracking_state(&self, trace: &mut ContributionTrace) {
         let tracking_state = self.tracking_state.read().await;
         let mut fixtures = self.fixtures.write().await;
+        let mut cue_fade = self.cue_fade.write().await;
+        let now = self.clock.now();
 
-        // Apply static values from tracking state
-        for value in tracking_state.get_static_values() {
-            if let Some(fixture) = fixtures.iter_mut().find(|f| f.id == value.fixture_id) {
-                fixture.set_channel_value(&value.channel_type, value.value);
+        // Cues and effects writing the same channel are merged once both are in, so the
+        // sources this frame are all that count
+        let mut merge = PlaybackMerge::new(self.settings.read().await.intensity_merge);
+
+        // Static values from tracking state, part way through the cue's fade
+        let static_values = tracking_state.get_static_values();
+        let tracked = |fixture_id: usize, channel_type: &ChannelType| {
+            static_values
+                .iter()
+                .find(|v| v.fixture_id == fixture_id && v.channel_type == *channel_type)
+        };
+        // Red, green and blue go out together, once all three are in, so the fixture can
+        // calibrate them
+        let mut cue_colors: BTreeMap<usize, [Option<(ContributionSource, u8)>; 3]> =
+            BTreeMap::new();
+        for value in &static_values {
+            let Some(fixture) = fixtures.iter().find(|f| f.id == value.fixture_id) else {
+                continue;
+            };
+            let source = ContributionSource::Cue(
+                tracking_state
+                    .cue_for(value.fixture_id, &value.channel_type)
+                    .unwrap_or_default()
+                    .to_string(),
+            );
+            let fine_channel = value
+                .channel_type
+                .fine_channel()
+                .filter(|_| fixture.has_fine_channel(&value.channel_type));
+            match fine_channel {
+                // Pan and tilt fade as 16-bit values on fixtures with fine channels, the fine
+                // byte going out with the coarse one
+                Some(fine_channel) => {
+                    let fine = tracked(value.fixture_id, &fine_channel).map_or(0, |v| v.value);
+                    let (coarse, fine) = split_16(cue_fade.value_16(fixture, value, fine, now));
+                    merge.write(
+                        source.clone(),
+                        value.fixture_id,
+                        &value.channel_type,
+                        coarse,
+                    );
+                    merge.write(source, value.fixture_id, &fine_channel, fine);
+                }
+                // A fine value under a tracked coarse one has gone out with it
+                None if value
+                    .channel_type
+                    .coarse_channel()
+                    .is_some_and(|coarse| tracked(value.fixture_id, &coarse).is_some()) => {}
+                None => {
+                    let faded = cue_fade.value(fixture, value, now);
+                    let rgb_index = match value.channel_type {
+                        ChannelType::Red => Some(0),
+                        ChannelType::Green => Some(1),
+                        ChannelType::Blue => Some(2),
+                        _ => None,
+                    };
+                    match rgb_index {
+                        Some(index) => {
+                            cue_colors.entry(value.fixture_id).or_default()[index] =
+                                Some((source, faded));
+                        }
+                        None => merge.write(source, value.fixture_id, &value.channel_type, faded),
+                    }
+                }
+            }
+        }
+        drop(cue_fade);
+        for (fixture_id, rgb) in cue_colors {
+            let Some(fixture) = fixtures.iter().find(|f| f.id == fixture_id) else {
+                continue;
+            };
+            let level = |index: usize| rgb[index].as_ref().map_or(0, |(_, value)| *value);
+            match fixture.cue_color_values((level(0), level(1), level(2))) {
+                // Each calibrated channel mixes all three, so it's put down to the first cue
+                Some(values) => {
+                    let (source, _) = rgb
+                        .iter()
+                        .flatten()
+                        .next()
+                        .expect("a tracked color has at least one channel");
+                    for (channel_type, value) in values {
+                        merge.write(source.clone(), fixture_id, &channel_type, value);
+                    }
+                }
+                None => {
+                    let channel_types = [ChannelType::Red, ChannelType::Green, ChannelType::Blue];
+                    for (channel_type, tracked) in channel_types.iter().zip(rgb) {
+                        if let Some((source, value)) = tracked {
+                            merge.write(source, fixture_id, channel_type, value);
+                        }
+                    }
+                }
             }
         }
 
         // Release fixtures lock before processing effects
         drop(fixtures);
 
-        // Apply effects from tracking state
+        // Effects from tracking state
         self.apply_effects().await;
+        for (effect, fixture_id, channel_type, value) in self.effect_player.read().await.rendered()
+        {
+            merge.write(
+                ContributionSource::Effect(effect.clone()),
+                *fixture_id,
+                channel_type,
+                *value,
+            );
+        }
+        merge.render(&mut self.fixtures.write().await, trace);
 
         // Apply pixel effects from tracking state
         let pixel_effects = tracking_state.get_pixel_effects();
@@ -331,66 +1140,81 @@ impl LightingConsole {
         let effects = tracking_state.get_effects();
         let rhythm_state = self.rhythm_state.read().await;
         let mut fixtures = self.fixtures.write().await;
+        let settings = self.settings.read().await;
+        let (max_strobe_hz, no_strobe) = (settings.max_strobe_hz, settings.no_strobe);
+        let mut strobe_limiter = self.strobe_limiter.write().await;
 
-        for effect_mapping in effects {
-            // Calculate effect phase based on rhythm state
-            let phase = crate::effect::effect::get_effect_phase(
-                &rhythm_state,
-                &effect_mapping.effect.params,
-            );
-
-            // Apply the effect to get normalized value (0.0 to 1.0)
-            let normalized_value = effect_mapping.effect.apply(phase);
-
-            // Scale to min/max range
-            let min = effect_mapping.effect.min as f64;
-            let max = effect_mapping.effect.max as f64;
-            let scaled_value = (min + (max - min) * normalized_value) as u8;
-
-            // Apply effect to fixtures based on distribution
-            match &effect_mapping.distribution {
-                crate::EffectDistribution::All => {
-                    // Apply same value to all fixtures
-                    for fixture_id in &effect_mapping.fixture_ids {
-                        if let Some(fixture) = fixtures.iter_mut().find(|f| f.id == *fixture_id) {
-                            for channel_type in &effect_mapping.channel_types {
-                                fixture.set_channel_value(channel_type, scaled_value);
-                            }
-                        }
-                    }
-                }
-                crate::EffectDistribution::Step(step_size) => {
-                    // Apply effect with step distribution
-                    for (idx, fixture_id) in effect_mapping.fixture_ids.iter().enumerate() {
-                        let step_phase = (phase + (idx / step_size) as f64) % 1.0;
-                        let step_normalized = effect_mapping.effect.apply(step_phase);
-                        let step_value = (min + (max - min) * step_normalized) as u8;
-
-                        if let Some(fixture) = fixtures.iter_mut().find(|f| f.id == *fixture_id) {
-                            for channel_type in &effect_mapping.channel_types {
-                                fixture.set_channel_value(channel_type, step_value);
-                            }
-                        }
-                    }
-                }
-                crate::EffectDistribution::Wave(phase_offset) => {
-                    // Apply effect with wave distribution (phase offset per fixture)
-                    for (idx, fixture_id) in effect_mapping.fixture_ids.iter().enumerate() {
-                        let wave_phase = (phase + idx as f64 * phase_offset) % 1.0;
-                        let wave_normalized = effect_mapping.effect.apply(wave_phase);
-                        let wave_value = (min + (max - min) * wave_normalized) as u8;
+        // Effects on intensity flash at their rate, so they follow the strobe limits too
+        let effects: Vec<crate::EffectMapping> = effects
+            .iter()
+            .filter_map(|effect_mapping| {
+                strobe_limiter.limit_effect(
+                    effect_mapping,
+                    self.tempo,
+                    &rhythm_state,
+                    max_strobe_hz,
+                    no_strobe,
+                )
+            })
+            .collect();
+
+        // Each effect runs ahead by the slowest link among the universes its fixtures are on
+        let latencies: HashMap<usize, Duration> = fixtures
+            .iter()
+            .map(|fixture| (fixture.id, settings.output_latency(fixture.universe)))
+            .collect();
+        let rhythm_for = |mapping: &crate::EffectMapping| {
+            let latency = mapping
+                .fixture_ids
+                .iter()
+                .filter_map(|id| latencies.get(id))
+                .max()
+                .copied()
+                .unwrap_or_default();
+            self.rhythm_ahead(&rhythm_state, latency)
+        };
+        self.effect_player
+            .write()
+            .await
+            .render(&effects, rhythm_for, &mut fixtures);
+    }
 
-                        if let Some(fixture) = fixtures.iter_mut().find(|f| f.id == *fixture_id) {
-                            for channel_type in &effect_mapping.channel_types {
-                                fixture.set_channel_value(channel_type, wave_value);
-                            }
-                        }
-                    }
-                }
-            }
+    /// Write the frame to the DMX recording, if there is one, stamped with where it falls in
+    /// the music and the timecode being chased
+    async fn record_frame(
+        &self,
+        fixtures: &[Fixture],
+        rhythm_state: &RhythmState,
+        frame_cache: &FrameCache,
+    ) {
+        let mut dmx_recorder = self.dmx_recorder.write().await;
+        let Some(recorder) = dmx_recorder.as_mut() else {
+            return;
+        };
+        let timecode = self.cue_manager.read().await.current_timecode;
+        recorder.set_show_hash(self.show_hash.as_deref());
+        if let Err(e) = recorder.record(
+            self.clock.now(),
+            &self.show_name,
+            fixtures,
+            self.accumulated_beats,
+            rhythm_state,
+            timecode.as_ref(),
+            frame_cache.universes(),
+        ) {
+            log::error!("{e}. Recording stopped.");
+            *dmx_recorder = None;
         }
     }
 
+    fn position_in(&self, rhythm_state: &RhythmState) -> MusicalPosition {
+        MusicalPosition::from_beats(
+            self.accumulated_beats,
+            rhythm_state.beats_per_bar,
+            rhythm_state.bars_per_phrase,
+        )
+    }
+
     async fn apply_programmer_values(&self) {
         let programmer = self.programmer.read().await;
         if programmer.get_preview_mode() {
@@ -408,34 +1232,35 @@ impl LightingConsole {
     async fn send_dmx_data(&self) -> Result<Vec<(usize, Vec<(u8, u8, u8)>)>, anyhow::Error> {
         let fixtures = self.fixtures.read().await;
 
-        // Render pixel fixtures first
+        // Render pixel fixtures first, then regular fixtures that changed over them
         let pixel_engine = self.pixel_engine.read().await;
         let rhythm_state = self.rhythm_state.read().await;
-        let mut universe_data = pixel_engine.render(&fixtures, &rhythm_state);
-
-        // Merge regular fixtures into universe buffers
-        for fixture in fixtures.iter() {
-            if fixture.profile.fixture_type != halo_fixtures::FixtureType::PixelBar {
-                // Get or create universe buffer
-                let universe_buffer = universe_data
-                    .entry(fixture.universe)
-                    .or_insert_with(|| vec![0; 512]);
-
-                let start_channel = (fixture.start_address - 1) as usize;
-                let fixture_data = fixture.get_dmx_values();
-                let end_channel = (start_channel + fixture_data.len()).min(512);
-
-                universe_buffer[start_channel..end_channel].copy_from_slice(&fixture_data);
-            }
-        }
+        let settings = self.settings.read().await;
+        let solo_layer = self.solo_layer.read().await;
+        let mut pixel_universes = pixel_engine.render(
+            &fixtures,
+            |universe| self.rhythm_ahead(&rhythm_state, settings.output_latency(universe)),
+            |fixture_id| solo_layer.lit(fixture_id),
+        );
+        drop(solo_layer);
+        self.grand_master
+            .read()
+            .await
+            .apply_to_pixels(&mut pixel_universes, self.clock.now());
+        let mut frame_cache = self.frame_cache.write().await;
+        frame_cache.set_full_frames(settings.full_universe_frames);
+        drop(settings);
+        let changed = frame_cache.render(&fixtures, pixel_universes);
+        self.record_frame(&fixtures, &rhythm_state, &frame_cache)
+            .await;
 
         // Extract pixel data for visualization before sending
         let mut pixel_data = Vec::new();
         for fixture in fixtures.iter() {
             if fixture.profile.fixture_type == halo_fixtures::FixtureType::PixelBar {
                 let universe = pixel_engine.get_fixture_universe(fixture.id, fixture.universe);
-                if let Some(universe_buffer) = universe_data.get(&universe) {
-                    let start_idx = (fixture.start_address - 1) as usize;
+                if let Some(universe_buffer) = frame_cache.universe(universe) {
+                    let start_idx = fixture.start_address.saturating_sub(1) as usize;
                     let pixel_count = fixture.channels.len() / 3;
                     let mut pixels = Vec::new();
 
@@ -456,8 +1281,13 @@ impl LightingConsole {
             }
         }
 
-        // Send all universes to DMX module
-        for (universe, data) in universe_data {
+        // A standby renders everything but holds its output until it takes over
+        if self.standby.is_some() {
+            return Ok(pixel_data);
+        }
+
+        // Send universes that changed to the DMX module, which keeps refreshing the rest
+        for (universe, data) in changed {
             self.module_manager
                 .send_to_module(ModuleId::Dmx, ModuleEvent::DmxOutput(universe, data))
                 .await
@@ -472,8 +1302,28 @@ impl LightingConsole {
         self.fixture_library = FixtureLibrary::new();
     }
 
-    /// Convert a channel name string to a ChannelType
-    fn channel_string_to_type(channel: &str) -> halo_fixtures::ChannelType {
+    /// Add the profiles in a directory to the library, overriding built-ins with the same ID.
+    /// Files that can't be read are logged and skipped, and warnings about the rest logged.
+    pub fn load_profiles(&mut self, dir: &std::path::Path) -> ProfileLoad {
+        let load = self.fixture_library.load_profiles(dir);
+        for error in &load.errors {
+            log::warn!("Skipped fixture profile {error}");
+        }
+        for warning in &load.warnings {
+            log::warn!("Fixture profile {warning}");
+        }
+        if !load.loaded.is_empty() {
+            log::info!(
+                "Loaded {} fixture profiles from {}",
+                load.loaded.len(),
+                dir.display()
+            );
+        }
+        load
+    }
+
+    /// Convert a channel name string to a ChannelType
+    fn channel_string_to_type(channel: &str) -> halo_fixtures::ChannelType {
         use halo_fixtures::ChannelType;
 
         match channel.to_lowercase().as_str() {
@@ -489,6 +1339,8 @@ impl LightingConsole {
             "strobe" => ChannelType::Strobe,
             "pan" => ChannelType::Pan,
             "tilt" => ChannelType::Tilt,
+            "panfine" | "pan_fine" => ChannelType::PanFine,
+            "tiltfine" | "tilt_fine" => ChannelType::TiltFine,
             "tiltspeed" | "tilt_speed" => ChannelType::TiltSpeed,
             "beam" => ChannelType::Beam,
             "focus" => ChannelType::Focus,
@@ -501,6 +1353,28 @@ impl LightingConsole {
         }
     }
 
+    /// Order fixtures left to right across the stage for a fan. Selection order is kept
+    /// unless every fixture has been placed.
+    async fn fan_order(&self, mut fixture_ids: Vec<usize>) -> Vec<usize> {
+        let fixtures = self.fixtures.read().await;
+        let positions: Option<Vec<(usize, f32)>> = fixture_ids
+            .iter()
+            .map(|id| {
+                fixtures
+                    .iter()
+                    .find(|f| f.id == *id)
+                    .and_then(|f| f.position)
+                    .map(|p| (*id, p.x))
+            })
+            .collect();
+
+        if let Some(mut positions) = positions {
+            positions.sort_by(|a, b| a.1.total_cmp(&b.1));
+            fixture_ids = positions.into_iter().map(|(id, _)| id).collect();
+        }
+        fixture_ids
+    }
+
     /// Patch a fixture
     pub async fn patch_fixture(
         &mut self,
@@ -509,11 +1383,15 @@ impl LightingConsole {
         universe: u8,
         address: u16,
     ) -> Result<usize, String> {
+        Self::validate_address(address)?;
+
         let profile = self
             .fixture_library
             .profiles
             .get(profile_name)
             .ok_or_else(|| format!("Profile {} not found", profile_name))?;
+        let channels = profile.layout(None)?;
+        Self::validate_footprint(profile, universe, address, channels.len())?;
 
         let mut fixtures = self.fixtures.write().await;
         // Find the next available ID by getting max ID + 1, or 0 if no fixtures exist
@@ -529,10 +1407,14 @@ impl LightingConsole {
             name: name.to_string(),
             profile_id: profile.id.clone(),
             profile: profile.clone(),
-            channels: profile.channel_layout.clone(),
+            channels,
             universe,
             start_address: address,
             pan_tilt_limits: None,
+            orientation: Orientation::default(),
+            position: None,
+            mode: None,
+            aliases: Vec::new(),
         };
 
         fixtures.push(fixture);
@@ -547,11 +1429,14 @@ impl LightingConsole {
         universe: u8,
         address: u16,
     ) -> Result<Fixture, String> {
+        Self::validate_address(address)?;
+
         let mut fixtures = self.fixtures.write().await;
         let fixture = fixtures
             .iter_mut()
             .find(|f| f.id == fixture_id)
             .ok_or_else(|| format!("Fixture {fixture_id} not found"))?;
+        Self::validate_footprint(&fixture.profile, universe, address, fixture.channels.len())?;
 
         fixture.name = name;
         fixture.universe = universe;
@@ -560,6 +1445,38 @@ impl LightingConsole {
         Ok(fixture.clone())
     }
 
+    /// Check that a start address lies within a DMX universe
+    fn validate_address(address: u16) -> Result<(), String> {
+        if (1..=512).contains(&address) {
+            Ok(())
+        } else {
+            Err(format!("DMX address {address} is out of range (1-512)"))
+        }
+    }
+
+    /// Check that all of a fixture's channels fit in its universe, as those past the end
+    /// would never be sent. Pixel bars are left to the pixel engine, which carries them on
+    /// into the next universe.
+    fn validate_footprint(
+        profile: &FixtureProfile,
+        universe: u8,
+        address: u16,
+        channels: usize,
+    ) -> Result<(), String> {
+        if profile.fixture_type == FixtureType::PixelBar {
+            return Ok(());
+        }
+        let last = address as usize + channels.max(1) - 1;
+        if last <= 512 {
+            Ok(())
+        } else {
+            Err(format!(
+                "A {channels} channel fixture at {universe}.{address} would run to \
+                 {universe}.{last}, past the end of the universe at {universe}.512"
+            ))
+        }
+    }
+
     /// Remove a fixture
     pub async fn unpatch_fixture(&mut self, fixture_id: usize) -> Result<(), String> {
         let mut fixtures = self.fixtures.write().await;
@@ -577,6 +1494,56 @@ impl LightingConsole {
     }
 
     /// Set cue lists
+    /// Check every fixture the cue lists reference is patched. Cue lists with missing
+    /// references are rejected with all of them listed, or in lenient mode have those
+    /// references dropped, so playback never looks for a fixture that isn't there.
+    async fn check_fixture_references(
+        &self,
+        cue_lists: Vec<CueList>,
+    ) -> Result<Vec<CueList>, String> {
+        let patched: HashSet<usize> = self.fixtures.read().await.iter().map(|f| f.id).collect();
+        self.check_references_to(cue_lists, &patched).await
+    }
+
+    /// Check cue lists against the fixtures in `patched`, as for the patch
+    async fn check_references_to(
+        &self,
+        mut cue_lists: Vec<CueList>,
+        patched: &HashSet<usize>,
+    ) -> Result<Vec<CueList>, String> {
+        let mut missing = Vec::new();
+        for cue_list in &cue_lists {
+            for cue in &cue_list.cues {
+                for id in cue.fixture_ids() {
+                    if !patched.contains(&id) {
+                        missing.push(format!(
+                            "  - Cue '{}' in '{}' references missing fixture {}",
+                            cue.name, cue_list.name, id
+                        ));
+                    }
+                }
+            }
+        }
+        if missing.is_empty() {
+            return Ok(cue_lists);
+        }
+
+        if !self.settings.read().await.lenient_fixture_references {
+            return Err(format!(
+                "{} cue reference(s) to fixtures that aren't patched:\n{}",
+                missing.len(),
+                missing.join("\n")
+            ));
+        }
+        for reference in &missing {
+            log::warn!("Dropping reference:{}", reference.trim_start_matches("  -"));
+        }
+        for cue in cue_lists.iter_mut().flat_map(|list| list.cues.iter_mut()) {
+            cue.retain_fixtures(|id| patched.contains(&id));
+        }
+        Ok(cue_lists)
+    }
+
     pub async fn set_cue_lists(&self, cue_lists: Vec<CueList>) {
         let mut cue_manager = self.cue_manager.write().await;
         cue_manager.set_cue_lists(cue_lists);
@@ -590,6 +1557,9 @@ impl LightingConsole {
 
         log::info!("Shutting down async lighting console...");
 
+        let now = self.clock.now();
+        self.stats_counter.write().await.save(now, true);
+
         // Shutdown module manager
         self.module_manager
             .shutdown()
@@ -670,6 +1640,366 @@ impl LightingConsole {
         Ok(())
     }
 
+    /// Tempo and time signature now, for estimating how long cues run
+    pub async fn meter(&self) -> Meter {
+        let rhythm = self.rhythm_state.read().await;
+        Meter {
+            bpm: self.tempo,
+            beats_per_bar: rhythm.beats_per_bar,
+            bars_per_phrase: rhythm.bars_per_phrase,
+        }
+    }
+
+    /// Move the beat grid, keeping the tempo change within the same bounds as `set_bpm`
+    pub async fn edit_beat_grid(&mut self, edit: BeatGridEdit) -> Result<(), anyhow::Error> {
+        let (beats_per_bar, bars_per_phrase) = {
+            let rhythm = self.rhythm_state.read().await;
+            (rhythm.beats_per_bar, rhythm.bars_per_phrase)
+        };
+        let (tempo, beat_time) = edit.apply(
+            self.tempo,
+            self.accumulated_beats,
+            beats_per_bar,
+            bars_per_phrase,
+        );
+        if tempo != self.tempo {
+            self.set_bpm(tempo).await?;
+        }
+        self.accumulated_beats = beat_time;
+        self.update_rhythm_state(beat_time).await;
+        log::info!(
+            "Beat grid {edit:?}, now at {}",
+            self.musical_position().await
+        );
+        Ok(())
+    }
+
+    /// Record a tap and return the tempo implied by the gap since the previous one.
+    ///
+    /// Taps more than two seconds apart start a new sequence.
+    async fn register_tap(&self) -> Option<f64> {
+        let now = self.clock.now();
+        let mut rhythm = self.rhythm_state.write().await;
+
+        let bpm = match rhythm.last_tap_time {
+            Some(last_tap) if now.duration_since(last_tap) < Duration::from_secs(2) => {
+                rhythm.tap_count += 1;
+                let interval = now.duration_since(last_tap).as_secs_f64();
+                (interval > 0.0).then(|| 60.0 / interval)
+            }
+            _ => {
+                rhythm.tap_count = 1;
+                None
+            }
+        };
+
+        rhythm.last_tap_time = Some(now);
+        bpm
+    }
+
+    /// Start one of a fixture's control commands. It runs over the following updates.
+    pub async fn send_fixture_command(
+        &self,
+        fixture_id: usize,
+        command: &str,
+    ) -> Result<(), String> {
+        let fixtures = self.fixtures.read().await;
+        let fixture = fixtures
+            .iter()
+            .find(|f| f.id == fixture_id)
+            .ok_or_else(|| format!("Fixture {fixture_id} not found"))?;
+        self.fixture_commands
+            .write()
+            .await
+            .start(fixture, command, self.clock.now())
+    }
+
+    /// Hold a cue's static values as a flash
+    pub async fn flash_on(&self, cue_name: &str) -> Result<(), String> {
+        self.flash_on_scaled(cue_name, 1.0).await
+    }
+
+    /// Hold a cue's look as a flash with its intensity scaled from 0.0 to 1.0
+    pub async fn flash_on_scaled(&self, cue_name: &str, intensity: f32) -> Result<(), String> {
+        let (mut values, freeze_others) = self
+            .cue_manager
+            .read()
+            .await
+            .get_cue_lists()
+            .iter()
+            .flat_map(|list| &list.cues)
+            .find(|cue| cue.name == cue_name)
+            .map(|cue| (cue.static_values.clone(), cue.freeze_others))
+            .ok_or_else(|| format!("No cue named '{cue_name}' to flash"))?;
+        if intensity < 1.0 {
+            scale_intensity(&mut values, &self.fixtures.read().await, intensity);
+        }
+
+        let mut flash_layer = self.flash_layer.write().await;
+        if !freeze_others {
+            flash_layer.flash_on(cue_name, values);
+        } else if flash_layer.flash_on_freezing(cue_name, values) {
+            self.effect_player.write().await.freeze_all();
+        }
+        Ok(())
+    }
+
+    /// Release a held flash, letting effects run on if it froze them
+    pub async fn flash_off(&self, cue_name: &str) {
+        let mut flash_layer = self.flash_layer.write().await;
+        let freezing = flash_layer.is_freezing(cue_name);
+        if flash_layer.flash_off(cue_name) && freezing {
+            self.effect_player.write().await.resume_all();
+        }
+    }
+
+    /// Resolve the configured triggers against the current cue lists, returning a description
+    /// of each trigger that can't be used
+    async fn resolve_triggers(&self) -> Vec<String> {
+        let cue_lists = self.cue_manager.read().await.get_cue_lists();
+        let mut triggers = self.triggers.write().await;
+        triggers.set_triggers(self.settings.read().await.triggers.clone());
+        triggers.resolve(&cue_lists)
+    }
+
+    /// Report triggers that don't match the show, so a bad config shows up when the show loads
+    /// rather than when the button is pressed. Nothing is reported before a show is loaded.
+    async fn report_trigger_errors(&self, event_tx: &mpsc::UnboundedSender<ConsoleEvent>) {
+        let errors = self.resolve_triggers().await;
+        let show_loaded = !self.cue_manager.read().await.get_cue_lists().is_empty();
+        if show_loaded && !errors.is_empty() {
+            let message = format!(
+                "{} trigger(s) don't match the show:\n{}",
+                errors.len(),
+                errors.join("\n")
+            );
+            log::warn!("{message}");
+            let _ = event_tx.send(ConsoleEvent::Error { message });
+        }
+    }
+
+    /// Console commands for the triggers an event matches
+    pub async fn dispatch_trigger(&self, event: &TriggerEvent) -> Vec<ConsoleCommand> {
+        self.triggers.write().await.dispatch(event)
+    }
+
+    /// Run scheduled events that have come due on the show clock
+    async fn run_schedule(&self, now: std::time::Instant) {
+        let due = self.schedule.write().await.poll(now);
+        for event in due {
+            log::info!("Running scheduled event '{}'", event.name);
+            if let Err(e) = self.run_scheduled_event(&event).await {
+                log::warn!("Scheduled event '{}' failed: {e}", event.name);
+            }
+        }
+    }
+
+    /// Run timetable rules that have come due on the wall clock and step a looping list.
+    /// Nothing runs until a show is loaded, so the first rules to run catch up with it.
+    async fn run_timetable(&self, now: std::time::Instant) {
+        if self.cue_manager.read().await.get_cue_lists().is_empty() {
+            return;
+        }
+
+        let due = self.timetable.write().await.poll(self.clock.local_now());
+        for action in due {
+            log::info!("Running timetable action {action:?}");
+            if let Err(e) = self.run_timetable_action(&action, now).await {
+                log::warn!("Timetable action {action:?} failed: {e}");
+            }
+        }
+
+        let mut timetable_loop = self.timetable_loop.write().await;
+        let Some((list_index, step, next)) = timetable_loop.as_mut() else {
+            return;
+        };
+        if now < *next {
+            return;
+        }
+        *next = now + *step;
+        let mut cue_manager = self.cue_manager.write().await;
+        if cue_manager.get_current_cue_list_idx() == *list_index
+            && cue_manager.get_playback_state() == PlaybackState::Playing
+            && cue_manager.go_to_next_cue().is_err()
+        {
+            let _ = cue_manager.go_to_cue(*list_index, 0);
+        }
+    }
+
+    async fn run_timetable_action(
+        &self,
+        action: &TimetableAction,
+        now: std::time::Instant,
+    ) -> Result<(), String> {
+        match action {
+            TimetableAction::Start {
+                cue_list,
+                step_secs,
+            } => {
+                let mut cue_manager = self.cue_manager.write().await;
+                let list_index = Self::find_cue_list(&cue_manager.get_cue_lists(), cue_list)?;
+                cue_manager.go_to_cue(list_index, 0)?;
+                self.grand_master
+                    .write()
+                    .await
+                    .fade_to(1.0, Duration::ZERO, now);
+                *self.timetable_loop.write().await =
+                    step_secs.filter(|secs| *secs > 0.0).map(|secs| {
+                        let step = Duration::from_secs_f64(secs);
+                        (list_index, step, now + step)
+                    });
+            }
+            TimetableAction::Stop => {
+                *self.timetable_loop.write().await = None;
+                let _ = self.cue_manager.write().await.stop();
+                self.tracking_state.write().await.clear();
+            }
+            TimetableAction::GrandMaster { level, time } => {
+                let duration = Duration::from_secs_f64(time.max(0.0));
+                self.grand_master
+                    .write()
+                    .await
+                    .fade_to(*level, duration, now);
+            }
+            TimetableAction::Blackout { time } => {
+                let duration = Duration::from_secs_f64(time.max(0.0));
+                self.grand_master.write().await.fade_to(0.0, duration, now);
+            }
+        }
+        Ok(())
+    }
+
+    /// Time of day rules for unattended operation, with a description of each that can't
+    /// be used
+    async fn update_timetable(&self) -> Vec<String> {
+        let rules = self.settings.read().await.timetable.clone();
+        self.timetable.write().await.set_rules(&rules)
+    }
+
+    async fn run_scheduled_event(&self, event: &ScheduledEvent) -> Result<(), String> {
+        match &event.action {
+            ScheduledAction::Go { cue_list } => {
+                let mut cue_manager = self.cue_manager.write().await;
+                let list_index = Self::find_cue_list(&cue_manager.get_cue_lists(), cue_list)?;
+                if cue_manager.get_current_cue_list_idx() == list_index
+                    && cue_manager.get_playback_state() == PlaybackState::Playing
+                {
+                    cue_manager.select_cue_list(list_index)?;
+                    cue_manager.go_to_next_cue()?;
+                } else {
+                    cue_manager.go_to_cue(list_index, 0)?;
+                }
+            }
+            ScheduledAction::Goto { cue_list, cue } => {
+                let mut cue_manager = self.cue_manager.write().await;
+                let cue_lists = cue_manager.get_cue_lists();
+                let list_index = Self::find_cue_list(&cue_lists, cue_list)?;
+                let cue_index = cue_lists[list_index]
+                    .cues
+                    .iter()
+                    .position(|c| c.name.eq_ignore_ascii_case(cue))
+                    .ok_or_else(|| format!("No cue named '{cue}' in '{cue_list}'"))?;
+                cue_manager.go_to_cue(list_index, cue_index)?;
+            }
+            ScheduledAction::Hold { values } => {
+                self.flash_layer
+                    .write()
+                    .await
+                    .flash_on(&Self::scheduled_hold_name(event), values.clone());
+            }
+        }
+        Ok(())
+    }
+
+    fn find_cue_list(cue_lists: &[CueList], name: &str) -> Result<usize, String> {
+        cue_lists
+            .iter()
+            .position(|list| list.name.eq_ignore_ascii_case(name))
+            .ok_or_else(|| format!("No cue list named '{name}'"))
+    }
+
+    /// Point Go and Back at the named list, optionally arming it to start from its first cue.
+    /// Whatever is playing carries on until the next Go.
+    async fn select_cue_list(&self, name: &str, preload: bool) -> Result<usize, String> {
+        let cue_lists = self.cue_manager.read().await.get_cue_lists();
+        let list_index = Self::find_cue_list(&cue_lists, name)?;
+        if preload {
+            self.check_fixture_references(vec![cue_lists[list_index].clone()])
+                .await?;
+        }
+
+        let mut cue_manager = self.cue_manager.write().await;
+        if preload {
+            cue_manager.preload_cue_list(list_index)?;
+        } else {
+            cue_manager.select_cue_list(list_index)?;
+        }
+        Ok(list_index)
+    }
+
+    fn scheduled_hold_name(event: &ScheduledEvent) -> String {
+        format!("Scheduled {}", event.name)
+    }
+
+    /// Number of MIDI and OSC events that matched no trigger
+    pub async fn unmatched_trigger_events(&self) -> u64 {
+        self.triggers.read().await.unmatched_events()
+    }
+
+    /// Run the override bound to a MIDI note. Static values and flashes are held while the
+    /// note is down.
+    async fn handle_midi_override(&self, midi_msg: &MidiMessage) -> Result<(), String> {
+        let (note, down) = match midi_msg {
+            MidiMessage::NoteOn(note, _) => (*note, true),
+            MidiMessage::NoteOff(note) => (*note, false),
+            _ => return Ok(()),
+        };
+        let Some(override_config) = self.midi_overrides.get(&note) else {
+            return Ok(());
+        };
+
+        match &override_config.action {
+            MidiAction::StaticValues(values) => {
+                let name = format!("MIDI note {note}");
+                let mut flash_layer = self.flash_layer.write().await;
+                if down {
+                    flash_layer.flash_on(&name, values.clone());
+                } else {
+                    flash_layer.flash_off(&name);
+                }
+            }
+            MidiAction::Flash(cue_name) => {
+                if down {
+                    self.flash_on(cue_name).await?;
+                } else {
+                    self.flash_off(cue_name).await;
+                }
+            }
+            MidiAction::TriggerCue(cue_name) if down => {
+                let mut cue_manager = self.cue_manager.write().await;
+                let position = cue_manager.get_cue_lists().iter().enumerate().find_map(
+                    |(list_index, list)| {
+                        list.cues
+                            .iter()
+                            .position(|cue| &cue.name == cue_name)
+                            .map(|cue_index| (list_index, cue_index))
+                    },
+                );
+                let (list_index, cue_index) =
+                    position.ok_or_else(|| format!("No cue named '{cue_name}' to trigger"))?;
+                cue_manager.go_to_cue(list_index, cue_index)?;
+            }
+            MidiAction::TriggerCue(_) => {}
+            MidiAction::FullOn if down => {
+                let mut full_on = self.full_on.write().await;
+                let active = !full_on.is_active();
+                full_on.set_active(active);
+            }
+            MidiAction::FullOn => {}
+        }
+        Ok(())
+    }
+
     /// Add a new MIDI override configuration
     pub fn add_midi_override(&mut self, note: u8, override_config: MidiOverride) {
         self.midi_overrides.insert(note, override_config);
@@ -721,17 +2051,29 @@ impl LightingConsole {
 
     /// Load a show from a path
     pub async fn load_show(&mut self, path: &std::path::Path) -> Result<(), anyhow::Error> {
+        let show = self.prepare_show(path).await?;
+        self.show_manager
+            .write()
+            .await
+            .set_current(show.clone(), path);
+        self.show_hash = show_file_hash(path).ok();
+        self.output_capture.set_show_hash(self.show_hash.as_deref());
+        self.workspace.set_active(None);
+        self.apply_show(show).await;
+        Ok(())
+    }
+
+    /// Read a show file and check it can run: every fixture's profile, mode and address,
+    /// its aliases, and every fixture its cues reference. Nothing about the running show
+    /// changes.
+    async fn prepare_show(&self, path: &std::path::Path) -> Result<Show, anyhow::Error> {
         // Validate that the file exists
         if !path.exists() {
             return Err(anyhow::anyhow!("Show file not found: {}", path.display()));
         }
 
         // Load the show from the file
-        let show = self
-            .show_manager
-            .write()
-            .await
-            .load_show(path)
+        let mut show = ShowManager::read_show(path)
             .map_err(|e| anyhow::anyhow!("Failed to load show file '{}': {}", path.display(), e))?;
 
         log::info!(
@@ -741,31 +2083,57 @@ impl LightingConsole {
             show.cue_lists.len()
         );
 
-        // Clear current fixtures and cue lists before loading
-        {
-            let mut fixtures = self.fixtures.write().await;
-            fixtures.clear();
-        }
-
-        // Track missing profiles for better error reporting
+        // Track missing profiles and bad addresses for better error reporting
         let mut missing_profiles = Vec::new();
+        let mut invalid_addresses = Vec::new();
+        let mut invalid_modes = Vec::new();
+        let mut fixtures = Vec::new();
 
         // For each fixture in the loaded show
-        for mut fixture in show.fixtures {
+        for mut fixture in std::mem::take(&mut show.fixtures) {
             // Preserve the original fixture ID
             let fixture_id = fixture.id;
             let fixture_name = fixture.name.clone();
             let profile_id = fixture.profile_id.clone();
 
+            if let Err(e) = Self::validate_address(fixture.start_address) {
+                invalid_addresses.push(format!(
+                    "  - Fixture '{}' (ID: {}): {}",
+                    fixture_name, fixture_id, e
+                ));
+                continue;
+            }
+
             // Look up the profile by ID in the fixture library
             if let Some(profile) = self.fixture_library.profiles.get(&profile_id) {
+                let channels = match profile.layout(fixture.mode) {
+                    Ok(channels) => channels,
+                    Err(e) => {
+                        invalid_modes.push(format!(
+                            "  - Fixture '{}' (ID: {}): {}",
+                            fixture_name, fixture_id, e
+                        ));
+                        continue;
+                    }
+                };
+                if let Err(e) = Self::validate_footprint(
+                    profile,
+                    fixture.universe,
+                    fixture.start_address,
+                    channels.len(),
+                ) {
+                    invalid_addresses.push(format!(
+                        "  - Fixture '{}' (ID: {}): {}",
+                        fixture_name, fixture_id, e
+                    ));
+                    continue;
+                }
                 // Set the profile field with the one from the library
                 fixture.profile = profile.clone();
-                fixture.channels = profile.channel_layout.clone();
+                fixture.channels = channels;
 
                 // Ensure the fixture keeps its original ID to maintain cue references
                 fixture.id = fixture_id;
-                let mut fixtures = self.fixtures.write().await;
                 fixtures.push(fixture);
                 log::debug!(
                     "Loaded fixture '{}' with profile '{}'",
@@ -790,12 +2158,93 @@ impl LightingConsole {
             ));
         }
 
-        // After all fixtures are loaded with their original IDs, set the cue lists
+        if !invalid_modes.is_empty() {
+            return Err(anyhow::anyhow!(
+                "Failed to load show '{}': {} fixture(s) are patched in modes their profiles don't have:\n{}",
+                path.display(),
+                invalid_modes.len(),
+                invalid_modes.join("\n")
+            ));
+        }
+
+        if !invalid_addresses.is_empty() {
+            return Err(anyhow::anyhow!(
+                "Failed to load show '{}': {} fixture(s) have invalid addresses:\n{}",
+                path.display(),
+                invalid_addresses.len(),
+                invalid_addresses.join("\n")
+            ));
+        }
+
+        // Pixel bars are addressed by the pixel engine, so only DMX fixtures can overlap
+        let dmx_fixtures: Vec<Fixture> = fixtures
+            .iter()
+            .filter(|f| f.profile.fixture_type != FixtureType::PixelBar)
+            .cloned()
+            .collect();
+        let conflicts = patch_conflicts(&dmx_fixtures);
+        if !conflicts.is_empty() {
+            return Err(anyhow::anyhow!(
+                "Failed to load show '{}': {} patch conflict(s):\n  - {}",
+                path.display(),
+                conflicts.len(),
+                conflicts.join("\n  - ")
+            ));
+        }
+
+        let collisions = alias_collisions(&fixtures);
+        if !collisions.is_empty() {
+            return Err(anyhow::anyhow!(
+                "Failed to load show '{}': {} fixture alias(es) are ambiguous:\n  - {}",
+                path.display(),
+                collisions.len(),
+                collisions.join("\n  - ")
+            ));
+        }
+
+        // Cues may only reference the show's own fixtures
+        let patched: HashSet<usize> = fixtures.iter().map(|f| f.id).collect();
+        show.cue_lists = self
+            .check_references_to(std::mem::take(&mut show.cue_lists), &patched)
+            .await
+            .map_err(|e| anyhow::anyhow!("Failed to load show '{}': {}", path.display(), e))?;
+        show.fixtures = fixtures;
+        Ok(show)
+    }
+
+    /// Replace the patch, cue lists, schedule, palettes and looks with a prepared show's
+    async fn apply_show(&mut self, show: Show) {
+        *self.fixtures.write().await = show.fixtures;
         self.set_cue_lists(show.cue_lists).await;
+        self.schedule
+            .write()
+            .await
+            .set_events(show.schedule, self.clock.now());
+        self.effect_player.write().await.set_palettes(show.palettes);
+        *self.looks.write().await = show.looks;
+        *self.variables.write().await = ShowVariables::new(show.vars);
+        self.exclusion
+            .write()
+            .await
+            .set_groups(show.exclusion_groups);
         self.show_name = show.name.clone();
 
         log::info!("Successfully loaded show '{}'", show.name);
 
+        {
+            let fixtures = self.fixtures.read().await;
+            let cue_lists = self.cue_manager.read().await.get_cue_lists();
+            for warning in analyze_usage(&fixtures, &cue_lists).warnings() {
+                log::warn!("{warning}");
+            }
+            for warning in cue_lists.iter().flat_map(CueList::short_fade_warnings) {
+                log::warn!("{warning}");
+            }
+            for warning in cue_lists.iter().flat_map(CueList::duplicate_warnings) {
+                log::warn!("{warning}");
+            }
+        }
+
         // Enable sequential packing for pixel bars
         {
             let fixtures = self.fixtures.read().await;
@@ -804,10 +2253,699 @@ impl LightingConsole {
         }
 
         // Settings are now loaded separately from config file, not from show
+    }
+
+    /// Load the show halo was started with. If it fails to load, or loading it panics, the
+    /// console drops into safe mode instead, so the rig can still be run by hand.
+    pub async fn start_show(&mut self, path: &std::path::Path) -> Result<(), String> {
+        let error = match recover_panic(self.load_show(path)).await {
+            Ok(Ok(())) => return Ok(()),
+            Ok(Err(e)) => e.to_string(),
+            Err(panic) => format!("Loading show '{}' crashed: {panic}", path.display()),
+        };
+        log::error!("{error}");
+        self.enter_safe_mode(error.clone()).await;
+        Err(error)
+    }
 
+    /// Run without a show: cue lists, scheduled events, the timetable and resuming are all
+    /// dropped, leaving the patch, the programmer and manual sources. Shows can't be loaded
+    /// until halo is restarted.
+    pub async fn enter_safe_mode(&mut self, reason: String) {
+        log::warn!("Entering safe mode: {reason}");
+        self.set_cue_lists(Vec::new()).await;
+        self.tracking_state.write().await.clear();
+        self.schedule
+            .write()
+            .await
+            .set_events(Vec::new(), self.clock.now());
+        *self.timetable_loop.write().await = None;
+        *self.looks.write().await = Looks::new();
+        *self.variables.write().await = ShowVariables::default();
+        self.exclusion.write().await.set_groups(Vec::new());
+        *self.pending_resume.write().await = None;
+        self.show_switch = None;
+        self.show_hash = None;
+        self.output_capture.set_show_hash(self.show_hash.as_deref());
+        // Keep the saved playback state for when the show runs again
+        *self.resume_writer.write().await = None;
+        self.safe_mode = Some(reason);
+    }
+
+    /// Why the console is running without a show, if it is
+    pub fn safe_mode(&self) -> Option<&str> {
+        self.safe_mode.as_deref()
+    }
+
+    /// Load a show into the workspace as `name`, ready to switch to, leaving the running show
+    /// alone. Loading over a name replaces that show, unless it's the one running.
+    pub async fn load_show_as(
+        &mut self,
+        name: &str,
+        path: &std::path::Path,
+    ) -> Result<(), anyhow::Error> {
+        if self.workspace.active() == Some(name) {
+            return Err(anyhow::anyhow!(
+                "'{name}' is the active show, switch to another before reloading it"
+            ));
+        }
+        let show = self.prepare_show(path).await?;
+        self.workspace.insert(name, path, show);
+        log::info!("Loaded {} into the workspace as '{name}'", path.display());
+        Ok(())
+    }
+
+    /// Drop a show from the workspace. The active show, and one being switched to, stay.
+    pub fn unload_show(&mut self, name: &str) -> Result<(), String> {
+        if self
+            .show_switch
+            .as_ref()
+            .is_some_and(|(incoming, _)| incoming == name)
+        {
+            return Err(format!("'{name}' is being switched to"));
+        }
+        self.workspace.remove(name)
+    }
+
+    /// Switch playback to a show in the workspace. The running show's cues and effects are
+    /// released over `transition`, then the incoming show's patch and cue lists take over
+    /// with its first cue list armed. Tempo, manual values, the programmer and the grand
+    /// master carry on across the switch.
+    pub async fn activate_show(&mut self, name: &str, transition: Duration) -> Result<(), String> {
+        if self.workspace.get(name).is_none() {
+            return Err(format!("No show loaded as '{name}'"));
+        }
+        // Keep edits made to the outgoing show for when it's switched back to. Mid-switch,
+        // the cue lists are only the release.
+        if self.show_switch.is_none() {
+            if let Some(active) = self.workspace.active().map(str::to_string) {
+                let running = self.get_show().await;
+                self.workspace.update(&active, running);
+            }
+        }
+
+        if transition.is_zero() {
+            self.show_switch = None;
+            self.finish_show_switch(name).await;
+            return Ok(());
+        }
+        self.set_cue_lists(vec![CueList {
+            name: "Show change".to_string(),
+            cues: vec![Cue::release_all("Release", &[], transition)],
+            audio_file: None,
+            default_fade: None,
+            default_values: Vec::new(),
+            move_in_black: None,
+        }])
+        .await;
+        self.cue_manager.write().await.go_to_cue(0, 0)?;
+        self.show_switch = Some((name.to_string(), self.clock.now() + transition));
+        log::info!("Switching to show '{name}' over {transition:?}");
+        Ok(())
+    }
+
+    /// Hand playback to a workspace show once the outgoing one has been released. Anything
+    /// the outgoing show left running is stopped here, so none of it can carry into the next.
+    async fn finish_show_switch(&mut self, name: &str) {
+        let Some(loaded) = self.workspace.get(name).cloned() else {
+            log::warn!("Show '{name}' was unloaded before it could take over");
+            return;
+        };
+        self.tracking_state.write().await.clear();
+        self.effect_player.write().await.stop_effects();
+        self.pixel_engine.write().await.set_effects(Vec::new());
+        *self.cue_intensity.write().await = None;
+        *self.timetable_loop.write().await = None;
+        self.position_warnings.write().await.clear();
+        self.confirmed_go = None;
+
+        self.show_manager
+            .write()
+            .await
+            .set_current(loaded.show.clone(), &loaded.path);
+        self.show_hash = show_file_hash(&loaded.path).ok();
+        self.output_capture.set_show_hash(self.show_hash.as_deref());
+        self.apply_show(loaded.show).await;
+        if let Err(e) = self.cue_manager.write().await.arm(0, 0) {
+            log::warn!("Nothing to arm in show '{name}': {e}");
+        }
+        for error in self.resolve_triggers().await {
+            log::warn!("{error}");
+        }
+        self.workspace.set_active(Some(name));
+        self.show_activated = Some(name.to_string());
+        log::info!("Switched to show '{name}'");
+    }
+
+    /// The shows loaded in the workspace
+    pub fn workspace(&self) -> &ShowWorkspace {
+        &self.workspace
+    }
+
+    /// Hash of the running show's file, the same on any machine running the same file
+    pub fn show_hash(&self) -> Option<&str> {
+        self.show_hash.as_deref()
+    }
+
+    /// Stream playback to a standby through `tx` after every update
+    pub fn set_mirror(&mut self, tx: Option<mpsc::UnboundedSender<MirrorState>>) {
+        self.mirror_tx = tx;
+    }
+
+    /// What a standby needs to mirror playback now, once a show is loaded
+    pub async fn mirror_state(&self) -> Option<MirrorState> {
+        let show_hash = self.show_hash.clone()?;
+        let (cue_list, cue, cue_elapsed) = {
+            let cue_manager = self.cue_manager.read().await;
+            let running = cue_manager.get_playback_state() != PlaybackState::Stopped;
+            let started = cue_manager.get_current_cue_start_time();
+            (
+                cue_manager.get_current_cue_list_idx(),
+                running.then(|| cue_manager.get_current_cue_index()),
+                started
+                    .map(|started| self.clock.now().duration_since(started))
+                    .unwrap_or_default(),
+            )
+        };
+        Some(MirrorState {
+            show_hash,
+            cue_list,
+            cue,
+            cue_elapsed,
+            values: self.tracking_state.read().await.get_static_values(),
+            grand_master: self
+                .grand_master
+                .read()
+                .await
+                .output_level(self.clock.now()),
+            bpm: self.tempo,
+            beats: self.accumulated_beats,
+        })
+    }
+
+    /// Mirror a primary with output muted, until `take_over` or the primary goes quiet
+    pub fn enter_standby(&mut self) {
+        log::info!("Standing by, output muted until takeover");
+        self.standby = Some(StandbyLink::default());
+    }
+
+    /// Whether the console is a muted standby
+    pub fn is_standby(&self) -> bool {
+        self.standby.is_some()
+    }
+
+    /// Why the standby won't mirror its primary, if it refused to
+    pub fn standby_refused(&self) -> Option<&str> {
+        self.standby.as_ref()?.refused.as_deref()
+    }
+
+    /// Take the primary's latest state, to apply next update. A primary running a different
+    /// show file is refused, and the standby stops following it until restarted.
+    pub fn follow_primary(&mut self, state: MirrorState) -> Result<(), String> {
+        let now = self.clock.now();
+        let show_hash = self.show_hash.clone();
+        let Some(link) = self.standby.as_mut() else {
+            return Err("Not in standby".to_string());
+        };
+        if let Some(reason) = &link.refused {
+            return Err(reason.clone());
+        }
+        if show_hash.as_deref() != Some(state.show_hash.as_str()) {
+            let reason = format!(
+                "Refusing standby: the primary is running show {} but this console has {}",
+                state.show_hash,
+                show_hash.as_deref().unwrap_or("no show")
+            );
+            log::error!("{reason}");
+            link.refused = Some(reason.clone());
+            link.pending = None;
+            self.standby_changed = true;
+            return Err(reason);
+        }
+        link.pending = Some(state);
+        link.last_heard = Some(now);
+        Ok(())
+    }
+
+    /// Unmute a standby and run the show from here, sending every universe straight away
+    pub async fn take_over(&mut self) -> Result<(), String> {
+        if self.standby.take().is_none() {
+            return Err("Not in standby".to_string());
+        }
+        log::warn!("Taking over from the primary");
+        self.standby_changed = true;
+        let universes: Vec<(u8, Vec<u8>)> = self
+            .frame_cache
+            .read()
+            .await
+            .universes()
+            .map(|(universe, data)| (universe, data.to_vec()))
+            .collect();
+        for (universe, data) in universes {
+            self.module_manager
+                .send_to_module(ModuleId::Dmx, ModuleEvent::DmxOutput(universe, data))
+                .await?;
+        }
         Ok(())
     }
 
+    /// What each layer of the last frame put on a fixture's channels, in render order, by
+    /// the fixture's name or one of its aliases. The last value on each channel went out.
+    pub async fn contributions(&self, fixture: &str) -> Result<Vec<SourceValue>, String> {
+        let fixture_id = find_fixture(&self.fixtures.read().await, fixture)
+            .map(|(fixture, _)| fixture.id)
+            .ok_or_else(|| format!("No fixture named '{fixture}'"))?;
+        Ok(self.contributions.read().await.contributions(fixture_id))
+    }
+
+    /// The last rendered frame for `universe`, whether or not it was sent
+    pub async fn rendered_universe(&self, universe: u8) -> Option<Vec<u8>> {
+        self.frame_cache
+            .read()
+            .await
+            .universe(universe)
+            .map(<[u8]>::to_vec)
+    }
+
+    /// Take a universe's DMX as the tracked look, mapped back through the patch, so the next
+    /// cue fades from what was on the wire rather than from black. Values from earlier
+    /// snapshots and cues on other channels are kept. Levels at addresses with nothing
+    /// patched are left out and reported.
+    pub async fn import_dmx_snapshot(
+        &mut self,
+        universe: u8,
+        data: &[u8],
+    ) -> Result<DmxImport, String> {
+        if data.len() > 512 {
+            return Err(format!(
+                "A universe is at most 512 channels, not {}",
+                data.len()
+            ));
+        }
+        let import = import_universe(&self.fixtures.read().await, universe, data);
+        if import.values.is_empty() {
+            return Err(format!("Nothing is patched in universe {universe}"));
+        }
+        if !import.skipped.is_empty() {
+            log::warn!(
+                "Skipped {} unpatched addresses in universe {universe}: {:?}",
+                import.skipped.len(),
+                import.skipped
+            );
+        }
+        log::info!(
+            "Imported {} channel values from universe {universe}",
+            import.values.len()
+        );
+        let imported = Cue {
+            name: format!("Imported universe {universe}"),
+            static_values: import.values.clone(),
+            ..Cue::default()
+        };
+        self.tracking_state.write().await.apply_cue(&imported);
+        Ok(import)
+    }
+
+    /// Apply the primary's latest state: tempo, beat, grand master and the running cue, with
+    /// the tracked values taken once on first contact. Takes over if the primary goes quiet.
+    async fn run_standby(&mut self, now: std::time::Instant) {
+        let Some(link) = self.standby.as_mut() else {
+            return;
+        };
+        if link.timed_out(now) {
+            log::warn!("Lost the primary's heartbeat");
+            if let Err(e) = self.take_over().await {
+                log::error!("Couldn't take over: {e}");
+            }
+            return;
+        }
+        let Some(state) = link.pending.take() else {
+            return;
+        };
+        let first = !link.synced;
+        link.synced = true;
+
+        if first {
+            let tracked = Cue {
+                static_values: state.values.clone(),
+                ..Cue::default()
+            };
+            self.tracking_state
+                .write()
+                .await
+                .apply_blocking_cue(&tracked);
+        }
+        if state.bpm != self.tempo {
+            if let Err(e) = self.set_bpm(state.bpm).await {
+                log::warn!("Couldn't follow the primary's tempo: {e}");
+            }
+        }
+        self.accumulated_beats = state.beats;
+        self.update_rhythm_state(state.beats).await;
+        self.grand_master
+            .write()
+            .await
+            .fade_to(state.grand_master, Duration::ZERO, now);
+
+        let mut cue_manager = self.cue_manager.write().await;
+        let playing = cue_manager.get_playback_state() != PlaybackState::Stopped;
+        let running = playing.then(|| {
+            (
+                cue_manager.get_current_cue_list_idx(),
+                cue_manager.get_current_cue_index(),
+            )
+        });
+        match state.cue {
+            Some(cue) if running != Some((state.cue_list, cue)) => {
+                match cue_manager.go_to_cue(state.cue_list, cue) {
+                    Ok(_) => cue_manager
+                        .set_current_cue_started(now.checked_sub(state.cue_elapsed).unwrap_or(now)),
+                    Err(e) => log::warn!("Couldn't follow the primary to cue {cue}: {e}"),
+                }
+            }
+            None if playing => {
+                let _ = cue_manager.stop();
+            }
+            _ => {}
+        }
+    }
+
+    async fn send_standby_changed(&mut self, event_tx: &mpsc::UnboundedSender<ConsoleEvent>) {
+        if std::mem::take(&mut self.standby_changed) {
+            let _ = event_tx.send(ConsoleEvent::StandbyChanged {
+                standby: self.is_standby(),
+                refused: self.standby_refused().map(str::to_string),
+            });
+        }
+    }
+
+    /// Names of the effects cues have left running
+    pub async fn active_effects(&self) -> Vec<String> {
+        let mut names: Vec<String> = self
+            .tracking_state
+            .read()
+            .await
+            .get_effects()
+            .into_iter()
+            .map(|effect| effect.name)
+            .collect();
+        names.sort();
+        names
+    }
+
+    /// Record every frame of output to `path` for `halo inspect`, replacing any recording
+    /// already running. `None` stops recording.
+    pub async fn record_dmx(&self, path: Option<&std::path::Path>) -> Result<(), String> {
+        let recorder = path.map(DmxRecorder::create).transpose()?;
+        *self.dmx_recorder.write().await = recorder;
+        Ok(())
+    }
+
+    /// Capture through `capture`, the handle given to the DMX module. Consoles built around
+    /// an output module of their own have nothing to capture until this is called.
+    pub fn set_output_capture(&mut self, capture: OutputCapture) {
+        capture.set_show_hash(self.show_hash.as_deref());
+        self.output_capture = capture;
+    }
+
+    /// Capture everything the DMX output sends to `path`, replacing any capture running.
+    /// `None` stops capturing.
+    pub fn capture_output(&self, path: Option<&std::path::Path>) -> Result<(), String> {
+        match path {
+            Some(path) => self.output_capture.start(path),
+            None => {
+                self.output_capture.stop();
+                Ok(())
+            }
+        }
+    }
+
+    /// Run the DMX module on an OS thread of its own once the modules start, at `niceness` if
+    /// given, for a console whose own loop is also pinned to a thread. The other modules run
+    /// on the current runtime.
+    pub fn pin_output(&mut self, niceness: Option<i32>) {
+        self.module_manager
+            .pin_output(niceness, tokio::runtime::Handle::current());
+    }
+
+    /// How long the update ticks of [`Self::run_with_channels`] take, shared with the
+    /// running loop
+    pub fn render_ticks(&self) -> Arc<TickHistogram> {
+        Arc::clone(&self.render_ticks)
+    }
+
+    /// Tracked values and active effects, what each frame renders
+    pub async fn render_count(&self) -> usize {
+        self.tracking_state.read().await.render_count()
+    }
+
+    /// The frame that ran furthest past its tick while the current cue played
+    pub fn worst_frame(&self) -> Option<FrameDrift> {
+        self.frame_timer.worst()
+    }
+
+    /// Cues that have given way to another since last asked, each with its worst frame
+    pub fn take_completed_cues(&mut self) -> Vec<(usize, usize, Option<FrameDrift>)> {
+        self.frame_timer.take_completed()
+    }
+
+    /// Change a show variable and rebind the cues that use it, see [`ShowVariables::set`]
+    pub async fn set_variable(&self, name: &str, value: Variable) -> Result<(), String> {
+        let mut cue_manager = self.cue_manager.write().await;
+        let mut cue_lists = cue_manager.get_cue_lists();
+        self.variables
+            .write()
+            .await
+            .set(name, value, &mut cue_lists)?;
+        cue_manager.set_cue_lists(cue_lists);
+        Ok(())
+    }
+
+    /// Named looks for cues to build on, replacing the show's
+    pub async fn set_looks(&self, looks: Looks) {
+        *self.looks.write().await = looks;
+    }
+
+    /// Groups of fixtures only one cue list may drive at a time, replacing the show's
+    pub async fn set_exclusion_groups(&self, groups: Vec<ExclusionGroup>) {
+        self.exclusion.write().await.set_groups(groups);
+    }
+
+    /// Which cue list holds each exclusion group, `None` for groups nobody has claimed
+    pub async fn exclusion_owners(&self) -> Vec<(String, Option<usize>)> {
+        let exclusion = self.exclusion.read().await;
+        exclusion
+            .groups()
+            .iter()
+            .map(|group| (group.name.clone(), exclusion.owner(&group.name)))
+            .collect()
+    }
+
+    /// Cues kept off exclusion groups since the last call
+    pub async fn take_exclusion_conflicts(&self) -> Vec<ExclusionConflict> {
+        self.exclusion.write().await.take_conflicts()
+    }
+
+    /// Named colors for effect color overrides, replacing the show's
+    pub async fn set_palettes(&self, palettes: HashMap<String, (u8, u8, u8)>) {
+        self.effect_player.write().await.set_palettes(palettes);
+    }
+
+    /// Roll cue variations from `seed` from now on, so runs from the same seed match
+    pub async fn set_seed(&self, seed: u64) {
+        self.variations.write().await.reseed(seed);
+    }
+
+    /// Indexes of the running cue's variations that applied this time round
+    pub async fn applied_variations(&self) -> Vec<usize> {
+        self.variations.read().await.applied().to_vec()
+    }
+
+    /// Replace the effects show files can name, e.g. with a registry that adds plugin effects
+    pub async fn set_effect_registry(&self, registry: EffectRegistry) {
+        self.effect_player.write().await.set_registry(registry);
+    }
+
+    /// Save playback state to `path` as the show runs, so `resume_from` can pick up from it after a
+    /// crash. `None` stops saving.
+    pub async fn set_resume_file(&self, path: Option<std::path::PathBuf>) {
+        *self.resume_writer.write().await = path.map(ResumeWriter::new);
+    }
+
+    /// Count fixture wear into the stats file at `path`, adding to what it already holds.
+    /// `None` keeps counting without saving.
+    pub async fn set_stats_file(&self, path: Option<std::path::PathBuf>) {
+        *self.stats_counter.write().await = StatsCounter::new(path);
+    }
+
+    /// On time and pan/tilt travel for every fixture, saved and since the last save
+    pub async fn fixture_stats(&self) -> FixtureStats {
+        self.stats_counter.read().await.stats()
+    }
+
+    /// Where playback is now, for saving
+    pub async fn resume_state(&self) -> ResumeState {
+        let cue_manager = self.cue_manager.read().await;
+        let cue_list = cue_manager
+            .get_current_cue_list()
+            .map(|list| list.name.clone())
+            .unwrap_or_default();
+        ResumeState {
+            saved_at: unix_now(),
+            cue_list,
+            next_cue: cue_manager.next_go().map(|(_, cue)| cue),
+            values: self.tracking_state.read().await.get_static_values(),
+            grand_master: self.grand_master.read().await.target(),
+            bpm: self.tempo,
+            show_hash: self.show_hash.clone(),
+            build: Some(BuildInfo::current()),
+        }
+    }
+
+    /// Restore saved playback state once the show's cue lists are loaded: fixtures fade up to
+    /// their tracked values and the next Go runs the cue that was next. Saving waits until
+    /// then, so the state being restored isn't overwritten.
+    pub async fn resume_from(&self, state: ResumeState) {
+        *self.pending_resume.write().await = Some(state);
+    }
+
+    async fn run_resume(&mut self, now: std::time::Instant) {
+        if self.cue_manager.read().await.get_cue_lists().is_empty() {
+            return;
+        }
+        let Some(state) = self.pending_resume.write().await.take() else {
+            return;
+        };
+        log::info!(
+            "Resuming '{}' with {} tracked values",
+            state.cue_list,
+            state.values.len()
+        );
+
+        let resumed = Cue {
+            static_values: state.values,
+            ..Cue::default()
+        };
+        self.tracking_state
+            .write()
+            .await
+            .apply_blocking_cue(&resumed);
+
+        {
+            let mut grand_master = self.grand_master.write().await;
+            grand_master.fade_to(0.0, Duration::ZERO, now);
+            grand_master.fade_to(state.grand_master, RESUME_FADE, now);
+        }
+
+        if let Err(e) = self.set_bpm(state.bpm).await {
+            log::warn!("Couldn't restore the tempo: {e}");
+        }
+
+        let mut cue_manager = self.cue_manager.write().await;
+        let list_index = Self::find_cue_list(&cue_manager.get_cue_lists(), &state.cue_list);
+        let armed = match (list_index, state.next_cue) {
+            (Ok(list_index), Some(cue)) => cue_manager.arm(list_index, cue),
+            (Ok(_), None) => Ok(()),
+            (Err(e), _) => Err(e),
+        };
+        if let Err(e) = armed {
+            log::warn!("Couldn't restore the cue to run next: {e}");
+        }
+    }
+
+    async fn save_resume_state(&self, now: std::time::Instant) {
+        if self.pending_resume.read().await.is_some() {
+            return;
+        }
+        let cue = {
+            let cue_manager = self.cue_manager.read().await;
+            cue_manager.get_current_cue_start_time().map(|started| {
+                (
+                    cue_manager.get_current_cue_list_idx(),
+                    cue_manager.get_current_cue_index(),
+                    started,
+                )
+            })
+        };
+        let path = {
+            let mut writer = self.resume_writer.write().await;
+            let Some(writer) = writer.as_mut() else {
+                return;
+            };
+            if !writer.due(now, cue) {
+                return;
+            }
+            writer.path.clone()
+        };
+        if let Err(e) = self.resume_state().await.save(&path) {
+            log::warn!("{e}");
+        }
+    }
+
+    /// Where the console is in the music, as phrase.bar.beat
+    pub async fn musical_position(&self) -> MusicalPosition {
+        self.position_in(&*self.rhythm_state.read().await)
+    }
+
+    /// Current grand master level, from 0.0 to 1.0
+    pub async fn grand_master_level(&self) -> f32 {
+        self.grand_master.read().await.level(self.clock.now())
+    }
+
+    /// Whether a blackout is holding every fixture's intensity at zero
+    pub async fn is_blacked_out(&self) -> bool {
+        self.grand_master.read().await.is_blacked_out()
+    }
+
+    async fn fade_grand_master(
+        &self,
+        level: f32,
+        duration_secs: f64,
+        event_tx: &mpsc::UnboundedSender<ConsoleEvent>,
+    ) {
+        let now = self.clock.now();
+        let duration = Duration::from_secs_f64(duration_secs.max(0.0));
+        self.grand_master
+            .write()
+            .await
+            .fade_to(level, duration, now);
+        let _ = event_tx.send(ConsoleEvent::GrandMasterChanged { level });
+    }
+
+    /// Describe a patched fixture, found by name or alias (ignoring case) or ID
+    pub async fn describe_fixture(&self, name: &str) -> Result<FixtureDescription, String> {
+        let fixtures = self.fixtures.read().await;
+        let (fixture, warning) =
+            find_fixture(&fixtures, name).ok_or_else(|| format!("No fixture named '{name}'"))?;
+        self.log_once(warning.into_iter().collect()).await;
+        let disabled = self.disabled_outputs.read().await.is_disabled(fixture);
+        Ok(FixtureDescription::new(fixture, disabled))
+    }
+
+    fn send_workspace(&self, event_tx: &mpsc::UnboundedSender<ConsoleEvent>) {
+        let _ = event_tx.send(ConsoleEvent::WorkspaceUpdated {
+            shows: self.workspace.names(),
+            active: self.workspace.active().map(str::to_string),
+        });
+    }
+
+    /// Tell the UI about a show that's finished switching in, if one has
+    async fn send_show_activated(&mut self, event_tx: &mpsc::UnboundedSender<ConsoleEvent>) {
+        if self.show_activated.take().is_some() {
+            let show = self.get_show().await;
+            let _ = event_tx.send(ConsoleEvent::ShowLoaded { show });
+            self.send_workspace(event_tx);
+        }
+    }
+
+    async fn send_disabled_outputs(&self, event_tx: &mpsc::UnboundedSender<ConsoleEvent>) {
+        let disabled = self.disabled_outputs.read().await;
+        let _ = event_tx.send(ConsoleEvent::DisabledOutputsChanged {
+            fixture_ids: disabled.disabled_fixtures(),
+            universes: disabled.disabled_universes(),
+        });
+    }
+
     /// Get the current show
     pub async fn get_show(&self) -> crate::show::show::Show {
         let fixtures = self.fixtures.read().await;
@@ -815,6 +2953,11 @@ impl LightingConsole {
         let mut show = crate::show::show::Show::new(self.show_name.clone());
         show.fixtures = fixtures.clone();
         show.cue_lists = cue_lists;
+        show.schedule = self.schedule.read().await.events();
+        show.palettes = self.effect_player.read().await.palettes().clone();
+        show.looks = self.looks.read().await.clone();
+        show.vars = self.variables.read().await.values().clone();
+        show.exclusion_groups = self.exclusion.read().await.groups().to_vec();
         show.modified_at = std::time::SystemTime::now();
         show
     }
@@ -838,6 +2981,50 @@ impl LightingConsole {
     }
 
     /// Process a command from the UI
+    /// A `CueWarning` if the next Go would run a cue with a warning the operator hasn't
+    /// confirmed. Any confirmation is used up either way.
+    async fn unconfirmed_warning(&mut self) -> Option<ConsoleEvent> {
+        let confirmed = self.confirmed_go.take();
+        let cue_manager = self.cue_manager.read().await;
+        let (list_index, cue_index) = cue_manager.next_go()?;
+        let cue = cue_manager.get_cue_list(list_index)?.cues.get(cue_index)?;
+        if cue.warning.is_empty() || confirmed == Some((list_index, cue_index)) {
+            return None;
+        }
+        log::info!("Cue '{}' needs confirming: {}", cue.name, cue.warning);
+        Some(ConsoleEvent::CueWarning {
+            list_index,
+            cue_index,
+            warning: cue.warning.clone(),
+        })
+    }
+
+    /// A `CueStarted` event carrying the cue's notes and warning for the operator
+    async fn cue_started(&self, list_index: usize, cue_index: usize) -> ConsoleEvent {
+        let cue_manager = self.cue_manager.read().await;
+        let cue = cue_manager
+            .get_cue_list(list_index)
+            .and_then(|list| list.cues.get(cue_index));
+        ConsoleEvent::CueStarted {
+            list_index,
+            cue_index,
+            notes: cue.map(|cue| cue.notes.clone()).unwrap_or_default(),
+            warning: cue.map(|cue| cue.warning.clone()).unwrap_or_default(),
+        }
+    }
+
+    /// A `CueStarted` event for the cue playback has just gone into
+    async fn current_cue_started(&self) -> ConsoleEvent {
+        let (list_index, cue_index) = {
+            let cue_manager = self.cue_manager.read().await;
+            (
+                cue_manager.get_current_cue_list_idx(),
+                cue_manager.get_current_cue_index(),
+            )
+        };
+        self.cue_started(list_index, cue_index).await
+    }
+
     pub async fn process_command(
         &mut self,
         command: ConsoleCommand,
@@ -852,6 +3039,17 @@ impl LightingConsole {
                 log::info!("Processing Initialize command");
                 self.initialize().await?;
                 let _ = event_tx.send(ConsoleEvent::Initialized);
+                if let Some(reason) = &self.safe_mode {
+                    let _ = event_tx.send(ConsoleEvent::SafeModeEntered {
+                        reason: reason.clone(),
+                    });
+                }
+                if self.is_standby() {
+                    let _ = event_tx.send(ConsoleEvent::StandbyChanged {
+                        standby: true,
+                        refused: None,
+                    });
+                }
             }
             Shutdown => {
                 log::info!("Processing Shutdown command");
@@ -867,6 +3065,33 @@ impl LightingConsole {
                 self.new_show(name.clone()).await?;
                 let _ = event_tx.send(ConsoleEvent::ShowCreated { name });
             }
+            LoadShow { .. }
+            | StartShow { .. }
+            | ReloadShow
+            | LoadShowAs { .. }
+            | ActivateShow { .. }
+                if self.safe_mode.is_some() =>
+            {
+                let _ = event_tx.send(ConsoleEvent::Error {
+                    message: "Shows can't be loaded in safe mode, restart halo to load one"
+                        .to_string(),
+                });
+            }
+            StartShow { path } => {
+                log::info!("Starting show {:?}", path);
+                match self.start_show(&path).await {
+                    Ok(()) => {
+                        let show = self.get_show().await;
+                        let settings = self.settings.read().await.clone();
+                        let _ = event_tx.send(ConsoleEvent::ShowLoaded { show });
+                        let _ = event_tx.send(ConsoleEvent::CurrentSettings { settings });
+                        self.report_trigger_errors(event_tx).await;
+                    }
+                    Err(reason) => {
+                        let _ = event_tx.send(ConsoleEvent::SafeModeEntered { reason });
+                    }
+                }
+            }
             LoadShow { path } => {
                 log::info!("Processing LoadShow command for path: {:?}", path);
                 match self.load_show(&path).await {
@@ -875,6 +3100,7 @@ impl LightingConsole {
                         let settings = self.settings.read().await.clone();
                         let _ = event_tx.send(ConsoleEvent::ShowLoaded { show });
                         let _ = event_tx.send(ConsoleEvent::CurrentSettings { settings });
+                        self.report_trigger_errors(event_tx).await;
                         log::info!("LoadShow command completed successfully");
                     }
                     Err(e) => {
@@ -886,6 +3112,44 @@ impl LightingConsole {
                     }
                 }
             }
+            LoadShowAs { name, path } => match self.load_show_as(&name, &path).await {
+                Ok(()) => self.send_workspace(event_tx),
+                Err(e) => {
+                    let _ = event_tx.send(ConsoleEvent::Error {
+                        message: format!("Failed to load show: {e}"),
+                    });
+                }
+            },
+            ActivateShow {
+                name,
+                transition_secs,
+            } => {
+                let transition = Duration::from_secs_f64(transition_secs.max(0.0));
+                match self.activate_show(&name, transition).await {
+                    Ok(()) => self.send_show_activated(event_tx).await,
+                    Err(message) => {
+                        let _ = event_tx.send(ConsoleEvent::Error { message });
+                    }
+                }
+            }
+            MirrorPrimary { state } => {
+                // Refusal is reported once, through the standby change
+                let _ = self.follow_primary(state);
+                self.send_standby_changed(event_tx).await;
+            }
+            TakeOver => match self.take_over().await {
+                Ok(()) => self.send_standby_changed(event_tx).await,
+                Err(message) => {
+                    let _ = event_tx.send(ConsoleEvent::Error { message });
+                }
+            },
+            UnloadShow { name } => match self.unload_show(&name) {
+                Ok(()) => self.send_workspace(event_tx),
+                Err(message) => {
+                    let _ = event_tx.send(ConsoleEvent::Error { message });
+                }
+            },
+            QueryWorkspace => self.send_workspace(event_tx),
             SaveShow => {
                 let path = self.save_show().await?;
                 let _ = event_tx.send(ConsoleEvent::ShowSaved { path });
@@ -956,6 +3220,20 @@ impl LightingConsole {
                     fixture,
                 });
             }
+            SendFixtureCommand {
+                fixture_id,
+                command,
+            } => match self.send_fixture_command(fixture_id, &command).await {
+                Ok(()) => {
+                    let _ = event_tx.send(ConsoleEvent::FixtureCommandStarted {
+                        fixture_id,
+                        command,
+                    });
+                }
+                Err(message) => {
+                    let _ = event_tx.send(ConsoleEvent::Error { message });
+                }
+            },
             UpdateFixtureChannels {
                 fixture_id,
                 channel_values,
@@ -994,7 +3272,17 @@ impl LightingConsole {
 
             // Cue management
             SetCueLists { cue_lists } => {
+                let cue_lists = match self.check_fixture_references(cue_lists).await {
+                    Ok(cue_lists) => cue_lists,
+                    Err(e) => {
+                        let _ = event_tx.send(ConsoleEvent::Error { message: e });
+                        return Ok(());
+                    }
+                };
                 self.set_cue_lists(cue_lists.clone()).await;
+                for error in self.resolve_triggers().await {
+                    log::warn!("{error}");
+                }
                 let _ = event_tx.send(ConsoleEvent::CueListsUpdated { cue_lists });
             }
             UpdateCue {
@@ -1099,11 +3387,28 @@ impl LightingConsole {
                     id: 0, // Will be set by the cue manager
                     name,
                     fade_time: Duration::from_secs_f64(fade_time),
+                    intensity_fade: None,
+                    color_fade: None,
+                    position_fade: None,
                     timecode,
                     static_values: Vec::new(),
                     effects: Vec::new(),
                     pixel_effects: Vec::new(),
                     is_blocking,
+                    positions: Vec::new(),
+                    delays: vec![],
+                    default_values: vec![],
+                    color_overrides: vec![],
+                    looks: vec![],
+                    variations: vec![],
+                    release: None,
+                    notes: String::new(),
+                    warning: String::new(),
+                    follow: None,
+                    chase: None,
+                    freeze_others: false,
+                    tempo_fade: None,
+                    variables: vec![],
                 };
                 let result = self.cue_manager.write().await.add_cue(list_index, cue);
                 match result {
@@ -1127,10 +3432,7 @@ impl LightingConsole {
                     .write()
                     .await
                     .go_to_cue(list_index, cue_index);
-                let _ = event_tx.send(ConsoleEvent::CueStarted {
-                    list_index,
-                    cue_index,
-                });
+                let _ = event_tx.send(self.cue_started(list_index, cue_index).await);
             }
             StopCue { list_index } => {
                 let _ = self.cue_manager.write().await.stop();
@@ -1155,10 +3457,7 @@ impl LightingConsole {
                     .write()
                     .await
                     .go_to_cue(list_index, cue_index);
-                let _ = event_tx.send(ConsoleEvent::CueStarted {
-                    list_index,
-                    cue_index,
-                });
+                let _ = event_tx.send(self.cue_started(list_index, cue_index).await);
                 // Send current cue update
                 let cue_manager = self.cue_manager.read().await;
                 let current_cue_index = cue_manager.get_current_cue_idx().unwrap_or(0);
@@ -1168,8 +3467,44 @@ impl LightingConsole {
                     progress,
                 });
             }
+            GoToCueScaled {
+                list_index,
+                cue_index,
+                intensity,
+            } => {
+                Box::pin(self.process_command(
+                    GoToCue {
+                        list_index,
+                        cue_index,
+                    },
+                    event_tx,
+                ))
+                .await?;
+                let cue_manager = self.cue_manager.read().await;
+                if let Some(started) = cue_manager.get_current_cue_start_time() {
+                    let key = (
+                        cue_manager.get_current_cue_list_idx(),
+                        cue_manager.get_current_cue_index(),
+                        started,
+                    );
+                    if key.0 == list_index && key.1 == cue_index {
+                        *self.cue_intensity.write().await = Some((key, intensity));
+                    }
+                }
+            }
+            ConfirmGo => {
+                self.confirmed_go = self.cue_manager.read().await.next_go();
+                Box::pin(self.process_command(Play, event_tx)).await?;
+            }
             NextCue { list_index: _ } => {
-                let _ = self.cue_manager.write().await.go_to_next_cue();
+                if let Some(event) = self.unconfirmed_warning().await {
+                    let _ = event_tx.send(event);
+                    return Ok(());
+                }
+                if self.cue_manager.write().await.go_to_next_cue().is_ok() {
+                    let event = self.current_cue_started().await;
+                    let _ = event_tx.send(event);
+                }
                 // Send current cue update
                 let cue_manager = self.cue_manager.read().await;
                 let cue_index = cue_manager.get_current_cue_idx().unwrap_or(0);
@@ -1195,20 +3530,75 @@ impl LightingConsole {
                 if let Err(err) = cue_manager.select_next_cue_list() {
                     log::warn!("Error selecting next cue list: {}", err);
                 } else {
-                    let current_index = cue_manager.get_current_cue_list_idx();
                     let _ = event_tx.send(ConsoleEvent::CueListSelected {
-                        list_index: current_index,
+                        list_index: cue_manager.get_selected_cue_list_idx(),
                     });
                 }
             }
+            SelectCueList { name } => match self.select_cue_list(&name, false).await {
+                Ok(list_index) => {
+                    let _ = event_tx.send(ConsoleEvent::CueListSelected { list_index });
+                }
+                Err(message) => {
+                    let _ = event_tx.send(ConsoleEvent::Error { message });
+                }
+            },
+            PreloadCueList { name } => match self.select_cue_list(&name, true).await {
+                Ok(list_index) => {
+                    let _ = event_tx.send(ConsoleEvent::CueListSelected { list_index });
+                }
+                Err(message) => {
+                    let _ = event_tx.send(ConsoleEvent::Error { message });
+                }
+            },
+            StartLearning { list_index } => {
+                match self.cue_manager.write().await.start_learning(list_index) {
+                    Ok(()) => {
+                        log::info!("Learning the timing of cue list {}", list_index);
+                        let _ = event_tx.send(ConsoleEvent::LearningStarted { list_index });
+                    }
+                    Err(message) => {
+                        let _ = event_tx.send(ConsoleEvent::Error { message });
+                    }
+                }
+            }
+            StopLearning { write } => {
+                let stopped = self.cue_manager.write().await.stop_learning();
+                let Some((list_index, timings)) = stopped else {
+                    let _ = event_tx.send(ConsoleEvent::Error {
+                        message: "Not learning any cue list".to_string(),
+                    });
+                    return Ok(());
+                };
+                for timing in &timings {
+                    log::info!("Learned {}", timing);
+                }
+                if write {
+                    {
+                        let mut cue_manager = self.cue_manager.write().await;
+                        if let Some(list) = cue_manager.get_cue_list_mut(list_index) {
+                            for change in apply_learned(list, &timings) {
+                                log::info!("{}", change);
+                            }
+                        }
+                    }
+                    let path = self.save_show().await?;
+                    let _ = event_tx.send(ConsoleEvent::ShowSaved { path });
+                    let cue_lists = self.cue_manager.read().await.get_cue_lists();
+                    let _ = event_tx.send(ConsoleEvent::CueListsUpdated { cue_lists });
+                }
+                let _ = event_tx.send(ConsoleEvent::TimingLearned {
+                    list_index,
+                    timings,
+                });
+            }
             SelectPreviousCueList => {
                 let mut cue_manager = self.cue_manager.write().await;
                 if let Err(err) = cue_manager.select_previous_cue_list() {
                     log::warn!("Error selecting previous cue list: {}", err);
                 } else {
-                    let current_index = cue_manager.get_current_cue_list_idx();
                     let _ = event_tx.send(ConsoleEvent::CueListSelected {
-                        list_index: current_index,
+                        list_index: cue_manager.get_selected_cue_list_idx(),
                     });
                 }
             }
@@ -1217,9 +3607,17 @@ impl LightingConsole {
             Play => {
                 println!("Console received Play command");
                 log::info!("Console received Play command");
-                let _ = self.cue_manager.write().await.go();
+                if let Some(event) = self.unconfirmed_warning().await {
+                    let _ = event_tx.send(event);
+                    return Ok(());
+                }
+                let started = self.cue_manager.write().await.go().is_ok();
                 let state = self.cue_manager.read().await.get_playback_state();
                 let _ = event_tx.send(ConsoleEvent::PlaybackStateChanged { state });
+                if started {
+                    let event = self.current_cue_started().await;
+                    let _ = event_tx.send(event);
+                }
 
                 // Check if current cuelist has an audio file and play it
                 let cue_manager = self.cue_manager.read().await;
@@ -1323,6 +3721,33 @@ impl LightingConsole {
             SetPlaybackRate { rate: _ } => {
                 // TODO: Implement playback rate control
             }
+            SetCrossfade {
+                position,
+                list_index,
+            } => {
+                // A fader bound to one list does nothing while another is playing
+                let current = self.cue_manager.read().await.get_current_cue_list_idx();
+                if list_index.is_some_and(|list_index| list_index != current) {
+                    log::debug!("Crossfade ignored, cue list {current} is playing");
+                } else {
+                    let action = self.crossfader.write().await.set_position(position);
+                    if action == CrossfadeAction::Commit {
+                        // The fader has already faded the cue in, so it starts at its target
+                        self.cue_fade.write().await.snap_next();
+                        let mut cue_manager = self.cue_manager.write().await;
+                        if let Err(e) = cue_manager.go_to_next_cue() {
+                            log::warn!("Crossfade has no cue to commit: {e}");
+                        }
+                        let _ = event_tx.send(ConsoleEvent::CurrentCueChanged {
+                            cue_index: cue_manager.get_current_cue_idx().unwrap_or(0),
+                            progress: cue_manager.get_current_cue_progress(),
+                        });
+                    }
+                    let _ = event_tx.send(ConsoleEvent::CrossfadeChanged {
+                        position: self.crossfader.read().await.position(),
+                    });
+                }
+            }
 
             // Tempo and timing
             SetBpm { bpm } => {
@@ -1331,10 +3756,19 @@ impl LightingConsole {
                 }
                 let _ = event_tx.send(ConsoleEvent::BpmChanged { bpm: self.tempo });
             }
+            EditBeatGrid { edit } => {
+                self.edit_beat_grid(edit).await?;
+                let state = self.rhythm_state.read().await.clone();
+                let _ = event_tx.send(ConsoleEvent::BpmChanged { bpm: self.tempo });
+                let _ = event_tx.send(ConsoleEvent::RhythmStateUpdated { state });
+            }
             TapTempo => {
-                // TODO: Implement tap tempo
-                let bpm = self.tempo;
-                let _ = event_tx.send(ConsoleEvent::BpmChanged { bpm });
+                if let Some(bpm) = self.register_tap().await {
+                    if let Err(e) = self.set_bpm(bpm).await {
+                        log::error!("Failed to set BPM from tap tempo: {}", e);
+                    }
+                }
+                let _ = event_tx.send(ConsoleEvent::BpmChanged { bpm: self.tempo });
             }
             SetTimecode { timecode } => {
                 self.cue_manager.write().await.current_timecode = Some(timecode);
@@ -1361,7 +3795,7 @@ impl LightingConsole {
                     // Adjust show start time so that elapsed time calculation reflects the new
                     // position
                     if cue_manager.show_start_time.is_some() {
-                        let now = std::time::Instant::now();
+                        let now = self.clock.now();
                         let adjusted_start_time =
                             now - std::time::Duration::from_secs_f64(position_seconds);
                         cue_manager.show_start_time = Some(adjusted_start_time);
@@ -1401,9 +3835,238 @@ impl LightingConsole {
                 let _ = event_tx.send(ConsoleEvent::MidiOverrideRemoved { note });
             }
             ProcessMidiMessage { message } => {
-                // TODO: Process MIDI message
+                if let Some(midi_msg) = MidiMessage::from_bytes(&message) {
+                    if let Err(e) = self.handle_midi_override(&midi_msg).await {
+                        let _ = event_tx.send(ConsoleEvent::Error { message: e });
+                    }
+                    let commands = self
+                        .dispatch_trigger(&TriggerEvent::Midi(midi_msg.clone()))
+                        .await;
+                    Self::handle_midi_input(midi_msg, &self.rhythm_state, &self.cue_manager).await;
+                    for command in commands {
+                        Box::pin(self.process_command(command, event_tx)).await?;
+                    }
+                }
                 let _ = event_tx.send(ConsoleEvent::MidiMessageReceived { message });
             }
+            ProcessOscMessage { address, args } => {
+                // Each show variable has an address of its own, e.g. /halo/var/accent
+                if let Some(name) = address.strip_prefix(VARIABLE_OSC_PREFIX) {
+                    let value = match self.variables.read().await.get(name) {
+                        Some(current) => current.parse_osc(&args),
+                        None => Err(format!("the show has no variable ${name}")),
+                    };
+                    match value {
+                        Ok(value) => {
+                            let command = SetVariable {
+                                name: name.to_string(),
+                                value,
+                            };
+                            Box::pin(self.process_command(command, event_tx)).await?;
+                        }
+                        Err(e) => {
+                            let _ = event_tx.send(ConsoleEvent::Error {
+                                message: format!("{address}: {e}"),
+                            });
+                        }
+                    }
+                    return Ok(());
+                }
+                let event = TriggerEvent::Osc { address, args };
+                for command in self.dispatch_trigger(&event).await {
+                    Box::pin(self.process_command(command, event_tx)).await?;
+                }
+            }
+
+            // Manual sources
+            SetManualValue {
+                source,
+                fixture_id,
+                channel,
+                value,
+            } => {
+                if !self
+                    .fixtures
+                    .read()
+                    .await
+                    .iter()
+                    .any(|f| f.id == fixture_id)
+                {
+                    let _ = event_tx.send(ConsoleEvent::Error {
+                        message: format!("Fixture {fixture_id} not found"),
+                    });
+                    return Ok(());
+                }
+                let channel_type = Self::channel_string_to_type(&channel);
+                let now = self.clock.now();
+                self.manual_layer
+                    .write()
+                    .await
+                    .set(&source, fixture_id, channel_type, value, now);
+            }
+            ReleaseManualSource { source } => {
+                let now = self.clock.now();
+                self.manual_layer.write().await.release(&source, now);
+            }
+
+            // Show clock
+            StartShowClock => {
+                let now = self.clock.now();
+                self.schedule.write().await.start(now);
+                log::info!("Show clock started");
+                let _ = event_tx.send(ConsoleEvent::ShowClockChanged { running: true });
+            }
+            StopShowClock => {
+                let events = {
+                    let mut schedule = self.schedule.write().await;
+                    schedule.stop();
+                    schedule.events()
+                };
+                // Holds only last as long as the show
+                let mut flash_layer = self.flash_layer.write().await;
+                for event in &events {
+                    if let ScheduledAction::Hold { .. } = event.action {
+                        flash_layer.flash_off(&Self::scheduled_hold_name(event));
+                    }
+                }
+                drop(flash_layer);
+                let _ = event_tx.send(ConsoleEvent::ShowClockChanged { running: false });
+            }
+            ScheduleEvent { event } => {
+                let now = self.clock.now();
+                self.schedule.write().await.add(event, now);
+            }
+
+            // Grand master
+            FadeToBlack { duration_secs } => {
+                self.fade_grand_master(0.0, duration_secs, event_tx).await;
+            }
+            FadeUp { duration_secs } => {
+                self.fade_grand_master(1.0, duration_secs, event_tx).await;
+            }
+            SetGrandMaster { level } => {
+                let level = level.clamp(0.0, 1.0);
+                let now = self.clock.now();
+                self.grand_master.write().await.set_level(level, now);
+                let _ = event_tx.send(ConsoleEvent::GrandMasterChanged { level });
+            }
+            SetBlackout { active } => {
+                self.grand_master.write().await.set_blackout(active);
+                let _ = event_tx.send(ConsoleEvent::BlackoutChanged { active });
+            }
+
+            // Show variables
+            SetVariable { name, value } => match self.set_variable(&name, value).await {
+                Ok(()) => {
+                    log::info!("${name} set to {value}");
+                    let _ = event_tx.send(ConsoleEvent::VariableChanged { name, value });
+                    let cue_lists = self.cue_manager.read().await.get_cue_lists();
+                    let _ = event_tx.send(ConsoleEvent::CueListsUpdated { cue_lists });
+                }
+                Err(message) => {
+                    let _ = event_tx.send(ConsoleEvent::Error { message });
+                }
+            },
+
+            // Emergency
+            FullOn => {
+                self.full_on.write().await.set_active(true);
+                let _ = event_tx.send(ConsoleEvent::FullOnChanged { active: true });
+            }
+            ReleaseFullOn => {
+                self.full_on.write().await.set_active(false);
+                let _ = event_tx.send(ConsoleEvent::FullOnChanged { active: false });
+            }
+
+            // Solo
+            Solo { fixture_ids } => {
+                self.solo_layer.write().await.solo(fixture_ids.clone());
+                let _ = event_tx.send(ConsoleEvent::SoloChanged {
+                    fixture_ids: Some(fixture_ids),
+                });
+            }
+            Unsolo => {
+                self.solo_layer.write().await.clear();
+                let _ = event_tx.send(ConsoleEvent::SoloChanged { fixture_ids: None });
+            }
+
+            // Disabled outputs
+            DisableFixture { fixture_id } => {
+                let hold = self.settings.read().await.hold_disabled_fixtures;
+                let fixtures = self.fixtures.read().await;
+                match fixtures.iter().find(|f| f.id == fixture_id) {
+                    Some(fixture) => {
+                        log::info!("Disabling output for {}", fixture.name);
+                        self.disabled_outputs
+                            .write()
+                            .await
+                            .disable_fixture(fixture, hold);
+                    }
+                    None => {
+                        let _ = event_tx.send(ConsoleEvent::Error {
+                            message: format!("Fixture {fixture_id} not found"),
+                        });
+                        return Ok(());
+                    }
+                }
+                drop(fixtures);
+                self.send_disabled_outputs(event_tx).await;
+            }
+            EnableFixture { fixture_id } => {
+                self.disabled_outputs
+                    .write()
+                    .await
+                    .enable_fixture(&self.fixtures.read().await, fixture_id);
+                self.send_disabled_outputs(event_tx).await;
+            }
+            DisableUniverse { universe } => {
+                let hold = self.settings.read().await.hold_disabled_fixtures;
+                log::info!("Disabling output for universe {universe}");
+                self.disabled_outputs.write().await.disable_universe(
+                    &self.fixtures.read().await,
+                    universe,
+                    hold,
+                );
+                self.send_disabled_outputs(event_tx).await;
+            }
+            EnableUniverse { universe } => {
+                self.disabled_outputs
+                    .write()
+                    .await
+                    .enable_universe(&self.fixtures.read().await, universe);
+                self.send_disabled_outputs(event_tx).await;
+            }
+            QueryDisabledOutputs => {
+                self.send_disabled_outputs(event_tx).await;
+            }
+
+            // Flash
+            FlashOn { cue_name } => match self.flash_on(&cue_name).await {
+                Ok(()) => {
+                    let active = self.flash_layer.read().await.active_looks();
+                    let _ = event_tx.send(ConsoleEvent::FlashesChanged { active });
+                }
+                Err(message) => {
+                    let _ = event_tx.send(ConsoleEvent::Error { message });
+                }
+            },
+            FlashOnScaled {
+                cue_name,
+                intensity,
+            } => match self.flash_on_scaled(&cue_name, intensity).await {
+                Ok(()) => {
+                    let active = self.flash_layer.read().await.active_looks();
+                    let _ = event_tx.send(ConsoleEvent::FlashesChanged { active });
+                }
+                Err(message) => {
+                    let _ = event_tx.send(ConsoleEvent::Error { message });
+                }
+            },
+            FlashOff { cue_name } => {
+                self.flash_off(&cue_name).await;
+                let active = self.flash_layer.read().await.active_looks();
+                let _ = event_tx.send(ConsoleEvent::FlashesChanged { active });
+            }
 
             // Audio
             PlayAudio { file_path } => {
@@ -1436,6 +4099,24 @@ impl LightingConsole {
             } => {
                 // TODO: Implement clear_effect method
             }
+            SetEffectColorOverride { effect, color } => {
+                let result = self
+                    .effect_player
+                    .write()
+                    .await
+                    .set_color_override(&effect, color);
+                if let Err(e) = result {
+                    let _ = event_tx.send(ConsoleEvent::Error {
+                        message: format!("Couldn't override the color of {effect}: {e}"),
+                    });
+                }
+            }
+            ClearEffectColorOverride { effect } => {
+                self.effect_player
+                    .write()
+                    .await
+                    .clear_color_override(&effect);
+            }
 
             // Programmer
             SetProgrammerValue {
@@ -1461,6 +4142,81 @@ impl LightingConsole {
 
                 let _ = event_tx.send(ConsoleEvent::ProgrammerValuesUpdated { values });
             }
+            SetProgrammerColor {
+                fixture_ids,
+                red,
+                green,
+                blue,
+            } => {
+                {
+                    let fixtures = self.fixtures.read().await;
+                    let mut programmer = self.programmer.write().await;
+                    for fixture in fixtures.iter().filter(|f| fixture_ids.contains(&f.id)) {
+                        for (channel_type, value) in fixture.color_values((red, green, blue)) {
+                            programmer.add_value(fixture.id, channel_type, value);
+                        }
+                    }
+                }
+
+                let programmer = self.programmer.read().await;
+                let values: Vec<(usize, String, u8)> = programmer
+                    .get_values()
+                    .iter()
+                    .map(|v| (v.fixture_id, v.channel_type.to_string(), v.value))
+                    .collect();
+                drop(programmer);
+
+                let _ = event_tx.send(ConsoleEvent::ProgrammerValuesUpdated { values });
+            }
+            SetProgrammerGobo {
+                fixture_ids,
+                gobo,
+                shake,
+            } => {
+                {
+                    let fixtures = self.fixtures.read().await;
+                    let mut programmer = self.programmer.write().await;
+                    for fixture in fixtures.iter().filter(|f| fixture_ids.contains(&f.id)) {
+                        for (channel_type, value) in fixture.gobo_values(gobo, shake) {
+                            programmer.add_value(fixture.id, channel_type, value);
+                        }
+                    }
+                }
+
+                let programmer = self.programmer.read().await;
+                let values: Vec<(usize, String, u8)> = programmer
+                    .get_values()
+                    .iter()
+                    .map(|v| (v.fixture_id, v.channel_type.to_string(), v.value))
+                    .collect();
+                drop(programmer);
+
+                let _ = event_tx.send(ConsoleEvent::ProgrammerValuesUpdated { values });
+            }
+            FanProgrammerValues {
+                fixture_ids,
+                channel,
+                from,
+                to,
+                mode,
+            } => {
+                let channel_type = Self::channel_string_to_type(&channel);
+                let fixture_ids = self.fan_order(fixture_ids).await;
+                self.programmer
+                    .write()
+                    .await
+                    .fan(&fixture_ids, channel_type, from, to, mode);
+
+                let programmer = self.programmer.read().await;
+                let values: Vec<(usize, String, u8)> = programmer
+                    .get_values()
+                    .iter()
+                    .map(|v| (v.fixture_id, v.channel_type.to_string(), v.value))
+                    .collect();
+                drop(programmer);
+
+                let _ = event_tx.send(ConsoleEvent::ProgrammerValuesUpdated { values });
+            }
             SetProgrammerPreviewMode { preview_mode } => {
                 self.programmer.write().await.set_preview_mode(preview_mode);
                 let programmer = self.programmer.read().await;
@@ -1572,6 +4328,7 @@ impl LightingConsole {
                         interval_ratio: ratio as f64,
                         phase: phase as f64,
                     },
+                    source: None,
                 };
 
                 // Create effect mapping
@@ -1599,7 +4356,7 @@ impl LightingConsole {
                 let _ = event_tx.send(ConsoleEvent::CueListsList { cue_lists });
             }
             QueryCurrentCueListIndex => {
-                let index = self.cue_manager.read().await.get_current_cue_list_idx();
+                let index = self.cue_manager.read().await.get_selected_cue_list_idx();
                 let _ = event_tx.send(ConsoleEvent::CurrentCueListIndex { index });
             }
             QueryCurrentCue => {
@@ -1611,6 +4368,10 @@ impl LightingConsole {
                     progress,
                 });
             }
+            QueryCueStatus { pending } => {
+                let lists = self.cue_manager.read().await.status(pending);
+                let _ = event_tx.send(ConsoleEvent::CueStatus { lists });
+            }
             QueryPlaybackState => {
                 let state = self.cue_manager.read().await.get_playback_state();
                 let _ = event_tx.send(ConsoleEvent::CurrentPlaybackState { state });
@@ -1646,6 +4407,57 @@ impl LightingConsole {
                     .collect();
                 let _ = event_tx.send(ConsoleEvent::FixtureLibraryList { profiles });
             }
+            DescribeFixture { name } => match self.describe_fixture(&name).await {
+                Ok(description) => {
+                    let _ = event_tx.send(ConsoleEvent::FixtureDescribed { description });
+                }
+                Err(message) => {
+                    let _ = event_tx.send(ConsoleEvent::Error { message });
+                }
+            },
+            QueryContributions { name } => match self.contributions(&name).await {
+                Ok(values) => {
+                    let _ = event_tx.send(ConsoleEvent::FixtureContributions { name, values });
+                }
+                Err(message) => {
+                    let _ = event_tx.send(ConsoleEvent::Error { message });
+                }
+            },
+            ImportDmxSnapshot { universe, data } => {
+                match self.import_dmx_snapshot(universe, &data).await {
+                    Ok(import) => {
+                        let _ = event_tx.send(ConsoleEvent::DmxSnapshotImported {
+                            universe,
+                            values: import.values.len(),
+                            skipped: import.skipped,
+                        });
+                    }
+                    Err(message) => {
+                        let _ = event_tx.send(ConsoleEvent::Error { message });
+                    }
+                }
+            }
+            StartOutputCapture { path } => {
+                let path = path.unwrap_or_else(|| {
+                    std::path::PathBuf::from(format!(
+                        "halo-{}.dmxcap",
+                        chrono::Local::now().format("%Y%m%d-%H%M%S")
+                    ))
+                });
+                match self.capture_output(Some(&path)) {
+                    Ok(()) => {
+                        let _ = event_tx.send(ConsoleEvent::OutputCapture { path: Some(path) });
+                    }
+                    Err(message) => {
+                        let _ = event_tx.send(ConsoleEvent::Error { message });
+                    }
+                }
+            }
+            StopOutputCapture => {
+                if self.output_capture.stop().is_some() {
+                    let _ = event_tx.send(ConsoleEvent::OutputCapture { path: None });
+                }
+            }
             EnableAbletonLink => {
                 if let Err(e) = self.enable_ableton_link().await {
                     let _ = event_tx.send(ConsoleEvent::Error {
@@ -1669,6 +4481,17 @@ impl LightingConsole {
                 log::info!("Updating settings");
                 *self.settings.write().await = settings.clone();
                 let _ = event_tx.send(ConsoleEvent::SettingsUpdated { settings });
+                self.report_trigger_errors(event_tx).await;
+                let errors = self.update_timetable().await;
+                if !errors.is_empty() {
+                    let message = format!(
+                        "{} timetable rule(s) can't be used:\n{}",
+                        errors.len(),
+                        errors.join("\n")
+                    );
+                    log::warn!("{message}");
+                    let _ = event_tx.send(ConsoleEvent::Error { message });
+                }
             }
             QuerySettings => {
                 let settings = self.settings.read().await.clone();
@@ -1734,7 +4557,7 @@ impl LightingConsole {
         log::info!("Console run_with_channels starting...");
 
         // Start the update loop
-        let mut update_interval = tokio::time::interval(std::time::Duration::from_millis(23)); // ~44Hz
+        let mut update_interval = tokio::time::interval(RENDER_TICK); // ~44Hz
         log::info!("Starting console main loop...");
 
         loop {
@@ -1760,6 +4583,7 @@ impl LightingConsole {
 
                 // Regular update tick
                 _ = update_interval.tick() => {
+                    let tick_started = std::time::Instant::now();
                     let pixel_data = match self.update().await {
                         Ok(data) => data,
                         Err(e) => {
@@ -1768,9 +4592,42 @@ impl LightingConsole {
                         }
                     };
 
+                    self.send_show_activated(&event_tx).await;
+                    self.send_standby_changed(&event_tx).await;
+                    for (list_index, cue_index, worst_frame) in self.take_completed_cues() {
+                        let _ = event_tx.send(ConsoleEvent::CueCompleted {
+                            list_index,
+                            cue_index,
+                            worst_frame,
+                        });
+                    }
+                    for conflict in self.take_exclusion_conflicts().await {
+                        let _ = event_tx.send(ConsoleEvent::ExclusionConflict {
+                            warning: conflict.to_string(),
+                            conflict,
+                        });
+                    }
+
+                    // Stream playback to the standby, if there is one
+                    if let Some(mirror_tx) = &self.mirror_tx {
+                        if let Some(state) = self.mirror_state().await {
+                            let _ = mirror_tx.send(state);
+                        }
+                    }
+
                     // Always send pixel data update for smooth animation and proper clearing
                     let _ = event_tx.send(ConsoleEvent::PixelDataUpdated { pixel_data });
 
+                    // Send live channel values for the stage visualizer
+                    let values = self
+                        .fixtures
+                        .read()
+                        .await
+                        .iter()
+                        .map(|f| (f.id, f.get_dmx_values()))
+                        .collect();
+                    let _ = event_tx.send(ConsoleEvent::FixtureValuesUpdated { values });
+
                     // Send periodic state updates
                     if let Some(timecode) = self.cue_manager.read().await.current_timecode {
                         let _ = event_tx.send(ConsoleEvent::TimecodeUpdated { timecode });
@@ -1781,6 +4638,8 @@ impl LightingConsole {
                     let cue_index = cue_manager.get_current_cue_idx().unwrap_or(0);
                     let progress = cue_manager.get_current_cue_progress();
                     let _ = event_tx.send(ConsoleEvent::CurrentCueChanged { cue_index, progress });
+                    let step = self.chase_step().await;
+                    let _ = event_tx.send(ConsoleEvent::ChaseStepChanged { step });
 
                     let rhythm_guard = self.rhythm_state.read().await;
                     let rhythm_state = RhythmState {
@@ -1798,6 +4657,7 @@ impl LightingConsole {
                     let tracking_state = self.tracking_state.read().await;
                     let active_effect_count = tracking_state.active_effect_count();
                     let _ = event_tx.send(ConsoleEvent::TrackingStateUpdated { active_effect_count });
+                    self.render_ticks.record(tick_started.elapsed());
                 }
 
                 // Process module messages (if available)
@@ -1813,7 +4673,16 @@ impl LightingConsole {
                         ModuleMessage::Event(event) => {
                             match event {
                                 ModuleEvent::MidiInput(midi_msg) => {
+                                    if let Err(e) = self.handle_midi_override(&midi_msg).await {
+                                        let _ = event_tx.send(ConsoleEvent::Error { message: e });
+                                    }
+                                    let commands = self.dispatch_trigger(&TriggerEvent::Midi(midi_msg.clone())).await;
                                     Self::handle_midi_input(midi_msg, &self.rhythm_state, &self.cue_manager).await;
+                                    for command in commands {
+                                        if let Err(e) = self.process_command(command, &event_tx).await {
+                                            log::error!("Trigger command failed: {e}");
+                                        }
+                                    }
                                 }
                                 _ => {
                                     // Handle other inter-module events as needed
@@ -1833,6 +4702,7 @@ impl LightingConsole {
             }
         }
 
+        log::info!("Render ticks: {}", self.render_ticks);
         log::info!("Console run_with_channels completed");
         Ok(())
     }
@@ -2005,6 +4875,9 @@ impl SyncLightingConsole {
                     name: "Main".to_string(),
                     cues: vec![],
                     audio_file: None,
+                    default_fade: None,
+                    default_values: vec![],
+                    move_in_black: None,
                 });
             }
 
@@ -2012,11 +4885,28 @@ impl SyncLightingConsole {
                 id: 0, // Will be assigned by the cue manager
                 name,
                 fade_time: std::time::Duration::from_secs_f64(fade_time),
+                intensity_fade: None,
+                color_fade: None,
+                position_fade: None,
                 static_values: values,
                 effects: vec![],
                 pixel_effects: vec![],
                 timecode: None,
                 is_blocking: false,
+                positions: vec![],
+                delays: vec![],
+                default_values: vec![],
+                color_overrides: vec![],
+                looks: vec![],
+                variations: vec![],
+                release: None,
+                notes: String::new(),
+                warning: String::new(),
+                follow: None,
+                chase: None,
+                freeze_others: false,
+                tempo_fade: None,
+                variables: vec![],
             };
 
             cue_manager
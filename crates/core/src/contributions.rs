@@ -0,0 +1,226 @@
+use std::collections::HashMap;
+use std::fmt;
+
+use halo_fixtures::{ChannelType, Fixture};
+use serde::{Deserialize, Serialize};
+
+/// A layer of the render that can put a value on a channel
+#[derive(Clone, Debug, PartialEq, Serialize, Deserialize)]
+#[serde(tag = "type", content = "name", rename_all = "snake_case")]
+pub enum ContributionSource {
+    /// A tracked value, by the name of the cue that last set it
+    Cue(String),
+    /// The running cue's chase, by the cue's name
+    Chase(String),
+    /// A dark fixture pre-positioned for the next cue
+    MoveInBlack,
+    /// The crossfader blending towards the next cue
+    Crossfade,
+    /// A running effect, by name
+    Effect(String),
+    Programmer,
+    Manual,
+    Flash,
+    Solo,
+    /// A fixture command holding the channel
+    Parked,
+    GrandMaster,
+    FullOn,
+    StrobeLimit,
+    Smoothing,
+    /// A disabled fixture or universe, frozen at its last value
+    Disabled,
+    /// A cue's value kept off a fixture, by the exclusion group another list holds
+    Excluded(String),
+}
+
+/// Why a layer's value replaced the one underneath
+#[derive(Clone, Copy, Debug, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum Rule {
+    /// Latest takes precedence: the layer renders after those under it
+    Ltp,
+    /// Highest takes precedence: the layer only ever raises a channel
+    Htp,
+    /// Scaled or limited what was rendered underneath
+    Scaled,
+    /// An override that beats all playback, whatever it was doing
+    Priority,
+    /// Never went out: another cue list holds the fixture's exclusion group
+    Excluded,
+}
+
+impl ContributionSource {
+    pub fn rule(&self) -> Rule {
+        match self {
+            ContributionSource::Cue(_)
+            | ContributionSource::Chase(_)
+            | ContributionSource::MoveInBlack
+            | ContributionSource::Crossfade
+            | ContributionSource::Effect(_)
+            | ContributionSource::Programmer
+            | ContributionSource::Manual => Rule::Ltp,
+            ContributionSource::Flash => Rule::Htp,
+            ContributionSource::GrandMaster
+            | ContributionSource::StrobeLimit
+            | ContributionSource::Smoothing => Rule::Scaled,
+            ContributionSource::Solo
+            | ContributionSource::Parked
+            | ContributionSource::FullOn
+            | ContributionSource::Disabled => Rule::Priority,
+            ContributionSource::Excluded(_) => Rule::Excluded,
+        }
+    }
+}
+
+impl fmt::Display for ContributionSource {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        match self {
+            ContributionSource::Cue(name) => write!(f, "cue '{name}'"),
+            ContributionSource::Chase(name) => write!(f, "chase in '{name}'"),
+            ContributionSource::Effect(name) => write!(f, "effect '{name}'"),
+            ContributionSource::MoveInBlack => write!(f, "move in black"),
+            ContributionSource::Crossfade => write!(f, "crossfade"),
+            ContributionSource::Programmer => write!(f, "programmer"),
+            ContributionSource::Manual => write!(f, "manual"),
+            ContributionSource::Flash => write!(f, "flash"),
+            ContributionSource::Solo => write!(f, "solo"),
+            ContributionSource::Parked => write!(f, "parked"),
+            ContributionSource::GrandMaster => write!(f, "grand master"),
+            ContributionSource::FullOn => write!(f, "full on"),
+            ContributionSource::StrobeLimit => write!(f, "strobe limit"),
+            ContributionSource::Smoothing => write!(f, "smoothing"),
+            ContributionSource::Disabled => write!(f, "disabled"),
+            ContributionSource::Excluded(group) => write!(f, "kept out of '{group}'"),
+        }
+    }
+}
+
+/// A value one source put on one channel in the last rendered frame
+#[derive(Clone, Debug, PartialEq, Serialize, Deserialize)]
+pub struct SourceValue {
+    pub source: ContributionSource,
+    pub channel_type: ChannelType,
+    pub value: u8,
+    /// Whether this is the value that went out
+    pub won: bool,
+    /// Why the value took over from the ones before it
+    pub rule: Rule,
+}
+
+/// What every layer of the last frame put on each fixture's channels, in render order, for
+/// working out why the output looks the way it does.
+///
+/// Layers with several sources inside them, the cues' tracked values and the effects, are
+/// noted value by value. The rest are found by comparing the fixtures before and after the
+/// layer renders, so a layer that writes the value already there doesn't show.
+#[derive(Clone, Debug, Default)]
+pub struct ContributionTrace {
+    /// Channel values by fixture ID as of the last layer
+    before: HashMap<usize, Vec<u8>>,
+    values: HashMap<usize, Vec<(ContributionSource, ChannelType, u8, Rule)>>,
+}
+
+impl ContributionTrace {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Start a new frame from the fixtures as they are before any layer renders
+    pub fn start(&mut self, fixtures: &[Fixture]) {
+        self.values.clear();
+        self.snapshot(fixtures);
+    }
+
+    /// Note a value a source put on a channel
+    pub fn propose(
+        &mut self,
+        fixture_id: usize,
+        channel_type: &ChannelType,
+        value: u8,
+        source: ContributionSource,
+    ) {
+        let rule = source.rule();
+        self.propose_as(fixture_id, channel_type, value, source, rule);
+    }
+
+    /// Note a value a source put on a channel, merged with the values before it by `rule`
+    /// rather than the source's usual one
+    pub fn propose_as(
+        &mut self,
+        fixture_id: usize,
+        channel_type: &ChannelType,
+        value: u8,
+        source: ContributionSource,
+        rule: Rule,
+    ) {
+        self.values.entry(fixture_id).or_default().push((
+            source,
+            channel_type.clone(),
+            value,
+            rule,
+        ));
+    }
+
+    /// Note every channel `source` changed since the last layer
+    pub fn layer(&mut self, source: ContributionSource, fixtures: &[Fixture]) {
+        for fixture in fixtures {
+            let Some(before) = self.before.get(&fixture.id) else {
+                continue;
+            };
+            for (channel, previous) in fixture.channels.iter().zip(before) {
+                if channel.value != *previous {
+                    self.values.entry(fixture.id).or_default().push((
+                        source.clone(),
+                        channel.channel_type.clone(),
+                        channel.value,
+                        source.rule(),
+                    ));
+                }
+            }
+        }
+        self.snapshot(fixtures);
+    }
+
+    /// Take the fixtures as they are now as the starting point for the next layer, after
+    /// proposing values for a layer by hand
+    pub fn snapshot(&mut self, fixtures: &[Fixture]) {
+        self.before = fixtures
+            .iter()
+            .map(|f| (f.id, f.channels.iter().map(|c| c.value).collect()))
+            .collect();
+    }
+
+    /// Everything the last frame put on a fixture, in render order. The value that went out
+    /// on each channel is the last one, unless it was merged HTP and an earlier value was at
+    /// least as high.
+    pub fn contributions(&self, fixture_id: usize) -> Vec<SourceValue> {
+        let Some(values) = self.values.get(&fixture_id) else {
+            return Vec::new();
+        };
+
+        // Index of the value holding each channel so far
+        let mut winners: Vec<(&ChannelType, usize)> = Vec::new();
+        for (i, (_, channel_type, value, rule)) in values.iter().enumerate() {
+            let winner = winners.iter_mut().find(|(c, _)| *c == channel_type);
+            match (rule, winner) {
+                (Rule::Excluded, _) => {}
+                (Rule::Htp, Some((_, held))) if values[*held].2 >= *value => {}
+                (_, Some((_, held))) => *held = i,
+                (_, None) => winners.push((channel_type, i)),
+            }
+        }
+
+        values
+            .iter()
+            .enumerate()
+            .map(|(i, (source, channel_type, value, rule))| SourceValue {
+                source: source.clone(),
+                channel_type: channel_type.clone(),
+                value: *value,
+                won: winners.iter().any(|(_, held)| *held == i),
+                rule: *rule,
+            })
+            .collect()
+    }
+}
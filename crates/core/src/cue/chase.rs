@@ -0,0 +1,229 @@
+use std::time::{Duration, Instant};
+
+use halo_fixtures::Fixture;
+use serde::{Deserialize, Serialize};
+
+use crate::cue::fade::FadeKey;
+use crate::parked::ParkedChannels;
+use crate::StaticValue;
+
+/// How long each step of a chase lasts
+#[derive(Clone, Copy, Debug, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum ChaseRate {
+    /// Beats of the tempo per step, so the chase follows tempo changes
+    Beats(f64),
+    /// Seconds per step, whatever the tempo
+    Seconds(f64),
+}
+
+impl Default for ChaseRate {
+    fn default() -> Self {
+        ChaseRate::Beats(1.0)
+    }
+}
+
+/// Which way a chase runs through its steps
+#[derive(Clone, Copy, Debug, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub enum ChaseDirection {
+    #[default]
+    Forward,
+    Backward,
+    /// Forward to the last step, then back to the first without repeating either end
+    Bounce,
+}
+
+/// One step of a chase: values for some channels of some fixtures, over what the cues have
+/// tracked
+#[derive(Clone, Debug, Default, PartialEq, Serialize, Deserialize)]
+pub struct ChaseStep {
+    pub static_values: Vec<StaticValue>,
+    /// Fixture IDs and channel indices the step's values go to on fixtures with several
+    /// channels of their type. Values without one go to the first channel of their type.
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub channels: Vec<(usize, usize)>,
+}
+
+impl ChaseStep {
+    /// Index in `fixture`'s channel list of the channel `value` goes to, if it has one
+    pub fn channel_index(&self, fixture: &Fixture, value: &StaticValue) -> Option<usize> {
+        self.channels
+            .iter()
+            .filter(|(fixture_id, _)| *fixture_id == fixture.id)
+            .map(|(_, index)| *index)
+            .find(|index| {
+                fixture
+                    .channels
+                    .get(*index)
+                    .is_some_and(|c| c.channel_type == value.channel_type)
+            })
+            .or_else(|| {
+                fixture
+                    .channels
+                    .iter()
+                    .position(|c| c.channel_type == value.channel_type)
+            })
+    }
+
+    /// Each value's fixture ID, channel index and value, so channels sharing a type stay
+    /// apart. Values for fixtures that aren't patched or lack the channel are left out.
+    fn targets(&self, fixtures: &[Fixture]) -> Vec<(usize, usize, u8)> {
+        self.static_values
+            .iter()
+            .filter_map(|v| {
+                let fixture = fixtures.iter().find(|f| f.id == v.fixture_id)?;
+                Some((v.fixture_id, self.channel_index(fixture, v)?, v.value))
+            })
+            .collect()
+    }
+}
+
+/// A cue that steps through partial looks while it runs, e.g. a dimmer chase across a row
+/// of PARs.
+///
+/// Steps advance at run time from the tempo or the clock rather than being baked into cues,
+/// so changing the rate or the tempo takes effect straight away.
+#[derive(Clone, Debug, Default, PartialEq, Serialize, Deserialize)]
+pub struct Chase {
+    pub steps: Vec<ChaseStep>,
+    pub rate: ChaseRate,
+    #[serde(default)]
+    pub direction: ChaseDirection,
+    /// Share of each step spent crossfading into the next, from 0 for a snap to 1 for a
+    /// chase that's always fading
+    #[serde(default)]
+    pub crossfade: f64,
+}
+
+impl Chase {
+    /// Step indices in the order one cycle of the chase plays them
+    pub fn order(&self) -> Vec<usize> {
+        let count = self.steps.len();
+        match self.direction {
+            ChaseDirection::Forward => (0..count).collect(),
+            ChaseDirection::Backward => (0..count).rev().collect(),
+            ChaseDirection::Bounce => (0..count)
+                .chain((1..count.saturating_sub(1)).rev())
+                .collect(),
+        }
+    }
+
+    /// The step playing `progress` steps after the chase started, the step after it and how
+    /// far the crossfade between the two has got, from 0 to 1
+    pub fn position(&self, progress: f64) -> Option<(usize, usize, f64)> {
+        let order = self.order();
+        if order.is_empty() {
+            return None;
+        }
+        let progress = progress.max(0.0);
+        let index = progress.floor() as usize % order.len();
+        let into_step = progress.fract();
+        let crossfade = self.crossfade.clamp(0.0, 1.0);
+        let blend = if crossfade > 0.0 && into_step > 1.0 - crossfade {
+            (into_step - (1.0 - crossfade)) / crossfade
+        } else {
+            0.0
+        };
+        Some((order[index], order[(index + 1) % order.len()], blend))
+    }
+
+    /// Steps that have gone by in `elapsed` at `bpm`
+    fn steps_in(&self, elapsed: Duration, bpm: f64) -> f64 {
+        let seconds = elapsed.as_secs_f64();
+        match self.rate {
+            ChaseRate::Beats(beats) if beats > 0.0 => seconds * bpm / 60.0 / beats,
+            ChaseRate::Seconds(step) if step > 0.0 => seconds / step,
+            _ => 0.0,
+        }
+    }
+}
+
+/// Plays the running cue's chase over the tracked values.
+///
+/// Progress is added up frame by frame at the rate and tempo of the moment, so a change to
+/// either speeds the chase up or slows it down from where it is instead of jumping to another
+/// step. Chased channels are parked, so when the cue stops they go back to what tracked.
+#[derive(Clone, Default)]
+pub struct ChasePlayer {
+    /// The cue run the chase belongs to, and when it was last rendered
+    running: Option<(FadeKey, Instant)>,
+    /// Steps gone by since the cue started
+    progress: f64,
+    step: Option<(usize, usize)>,
+    parked: ParkedChannels,
+}
+
+impl ChasePlayer {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Put back the values the last frame's chase replaced. Call before rendering playback.
+    pub fn restore(&mut self, fixtures: &mut [Fixture]) {
+        self.parked.restore(fixtures);
+    }
+
+    /// Write this frame of `chase` over the fixtures. `key` identifies the cue run, the way
+    /// the cue fade does, so running the cue again starts the chase from its first step.
+    pub fn apply(
+        &mut self,
+        key: FadeKey,
+        chase: &Chase,
+        fixtures: &mut [Fixture],
+        bpm: f64,
+        now: Instant,
+    ) {
+        let since = match self.running {
+            Some((running, last)) if running == key => last,
+            _ => {
+                self.progress = 0.0;
+                key.2
+            }
+        };
+        self.progress += chase.steps_in(now.saturating_duration_since(since), bpm);
+        self.running = Some((key, now));
+
+        let Some((step, next, blend)) = chase.position(self.progress) else {
+            self.step = None;
+            return;
+        };
+        self.step = Some((step, chase.steps.len()));
+
+        let from = chase.steps[step].targets(fixtures);
+        let to = chase.steps[next].targets(fixtures);
+        let mut channels: Vec<(usize, usize)> = Vec::new();
+        for (fixture_id, index, _) in from.iter().chain(to.iter()) {
+            if !channels.contains(&(*fixture_id, *index)) {
+                channels.push((*fixture_id, *index));
+            }
+        }
+        for (fixture_id, index) in channels {
+            let Some(fixture) = fixtures.iter_mut().find(|f| f.id == fixture_id) else {
+                continue;
+            };
+            let underneath = fixture.channels[index].value;
+            // A channel one of the two steps leaves alone fades from or to what's underneath
+            let value_in = |values: &[(usize, usize, u8)]| {
+                values
+                    .iter()
+                    .find(|(f, i, _)| *f == fixture_id && *i == index)
+                    .map_or(underneath, |(_, _, v)| *v)
+            };
+            let (a, b) = (value_in(&from) as f64, value_in(&to) as f64);
+            let value = (a + (b - a) * blend).round() as u8;
+            self.parked.park_at(fixture, index, value);
+        }
+    }
+
+    /// Stop the chase, e.g. when its cue is no longer running
+    pub fn stop(&mut self) {
+        self.running = None;
+        self.progress = 0.0;
+        self.step = None;
+    }
+
+    /// The step playing and how many steps the chase has, while one is running
+    pub fn step(&self) -> Option<(usize, usize)> {
+        self.step
+    }
+}
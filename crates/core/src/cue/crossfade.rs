@@ -0,0 +1,110 @@
+use halo_fixtures::{ChannelType, Fixture};
+
+use crate::parked::ParkedChannels;
+use crate::StaticValue;
+
+/// What a crossfader move asks playback to do
+#[derive(Clone, Copy, Debug, PartialEq, Eq)]
+pub enum CrossfadeAction {
+    /// Nothing to do: the fader is at rest or waiting to re-arm
+    Idle,
+    /// Output is part way between the current look and the next cue
+    Fading,
+    /// The fader reached the end, so the next cue should become the current one
+    Commit,
+}
+
+/// Manual crossfade from the current look into the next cue, driven by a fader.
+///
+/// Moving the fader off zero engages it. Reaching the top commits the next cue, and the fader
+/// has to come back to zero before it will fade into the cue after that. Blended channels are
+/// parked, so pulling the fader back without committing leaves the current look as it was.
+#[derive(Clone, Debug)]
+pub struct Crossfader {
+    position: f32,
+    armed: bool,
+    /// Channel values captured when the fade engaged
+    from: Vec<(usize, ChannelType, u8)>,
+    parked: ParkedChannels,
+}
+
+impl Default for Crossfader {
+    fn default() -> Self {
+        Self {
+            position: 0.0,
+            armed: true,
+            from: Vec::new(),
+            parked: ParkedChannels::default(),
+        }
+    }
+}
+
+impl Crossfader {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    pub fn position(&self) -> f32 {
+        self.position
+    }
+
+    /// Whether the fader is driving output into the next cue
+    pub fn is_engaged(&self) -> bool {
+        self.armed && self.position > 0.0
+    }
+
+    /// Move the fader, from 0.0 (the current look) to 1.0 (the next cue)
+    pub fn set_position(&mut self, position: f32) -> CrossfadeAction {
+        self.position = position.clamp(0.0, 1.0);
+
+        if self.position <= 0.0 {
+            self.armed = true;
+            self.from.clear();
+            return CrossfadeAction::Idle;
+        }
+        if !self.armed {
+            return CrossfadeAction::Idle;
+        }
+        if self.position >= 1.0 {
+            self.armed = false;
+            self.from.clear();
+            return CrossfadeAction::Commit;
+        }
+        CrossfadeAction::Fading
+    }
+
+    /// Put back the values the last frame's blend replaced. Call before rendering playback.
+    pub fn restore(&mut self, fixtures: &mut [Fixture]) {
+        self.parked.restore(fixtures);
+    }
+
+    /// Render the next cue's values at the fader position over the current output
+    pub fn apply(&mut self, fixtures: &mut [Fixture], next: &[StaticValue]) {
+        if !self.is_engaged() {
+            return;
+        }
+
+        for target in next {
+            let Some(fixture) = fixtures.iter_mut().find(|f| f.id == target.fixture_id) else {
+                continue;
+            };
+            let from = match self.from.iter().find(|(id, channel_type, _)| {
+                *id == target.fixture_id && *channel_type == target.channel_type
+            }) {
+                Some((_, _, value)) => *value,
+                None => {
+                    let Some(value) = fixture.channel_value(&target.channel_type) else {
+                        continue;
+                    };
+                    self.from
+                        .push((target.fixture_id, target.channel_type.clone(), value));
+                    value
+                }
+            };
+
+            let value = from as f32 + (target.value as f32 - from as f32) * self.position;
+            self.parked
+                .park(fixture, &target.channel_type, value.round() as u8);
+        }
+    }
+}
@@ -3,13 +3,126 @@ use std::time::Duration;
 use halo_fixtures::ChannelType;
 use serde::{Deserialize, Serialize};
 
-use crate::{Effect, EffectRelease, PixelEffect};
+use crate::cue::chase::Chase;
+use crate::cue::estimate::Follow;
+use crate::cue::fade::Attribute;
+use crate::cue::release::Release;
+use crate::duration::absolute;
+use crate::render::RENDER_TICK;
+use crate::{
+    ColorOverride, Effect, EffectRelease, Meter, MusicalDuration, PixelEffect, VariableRef,
+};
 
 #[derive(Clone, Debug, Serialize, Deserialize)]
 pub struct CueList {
     pub name: String,
     pub cues: Vec<Cue>,
     pub audio_file: Option<String>,
+    // Fade for cues that don't set one
+    #[serde(
+        default,
+        skip_serializing_if = "Option::is_none",
+        deserialize_with = "absolute::deserialize_option"
+    )]
+    pub default_fade: Option<Duration>,
+    // Values for channels a cue leaves unset on the fixtures it sets values for
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub default_values: Vec<DefaultValue>,
+    // Pre-position dark fixtures for the next cue once they've been dark this long
+    #[serde(
+        default,
+        skip_serializing_if = "Option::is_none",
+        deserialize_with = "absolute::deserialize_option"
+    )]
+    pub move_in_black: Option<Duration>,
+}
+
+impl CueList {
+    /// A warning for each cue that writes a channel more than once, see
+    /// [`Cue::duplicate_writes`]
+    pub fn duplicate_warnings(&self) -> Vec<String> {
+        self.cues
+            .iter()
+            .filter_map(|cue| {
+                let duplicates = cue.duplicate_writes();
+                (!duplicates.is_empty()).then(|| {
+                    format!(
+                        "Cue '{}' in '{}' writes channels twice: {}",
+                        cue.name,
+                        self.name,
+                        duplicates.join("; ")
+                    )
+                })
+            })
+            .collect()
+    }
+
+    /// The cue at `index` as it runs, see [`CueList::resolve`]
+    pub fn resolved_cue(&self, index: usize) -> Option<Cue> {
+        self.cues.get(index).map(|cue| self.resolve(cue))
+    }
+
+    /// The cue at `index` as it runs at `meter`'s tempo, see [`Cue::at_tempo`]
+    pub fn cue_at_tempo(&self, index: usize, meter: &Meter) -> Option<Cue> {
+        self.resolved_cue(index).map(|cue| cue.at_tempo(meter))
+    }
+
+    /// A warning for each cue whose fades, with the list's default, are shorter than a frame
+    pub fn short_fade_warnings(&self) -> Vec<String> {
+        self.cues
+            .iter()
+            .filter_map(|cue| {
+                let location = format!("Cue '{}' in '{}'", cue.name, self.name);
+                short_fade_warning(&location, &self.resolve(cue))
+            })
+            .collect()
+    }
+
+    /// A cue with the defaults filled in, for both playback and previews.
+    ///
+    /// Each setting comes from the most specific level that has one: the cue's own values and
+    /// attribute fades, then the cue's defaults and fade time, then the list's defaults and
+    /// fade. A zero fade time counts as unset, so use an attribute fade of zero to snap in a
+    /// list with a default fade. Values the cue sets are never replaced, zero included.
+    pub fn resolve(&self, cue: &Cue) -> Cue {
+        let mut resolved = cue.clone();
+        if resolved.fade_time.is_zero() {
+            resolved.fade_time = self.default_fade.unwrap_or_default();
+        }
+
+        let defaults: Vec<&DefaultValue> = cue
+            .default_values
+            .iter()
+            .chain(self.default_values.iter().filter(|list_default| {
+                !cue.default_values
+                    .iter()
+                    .any(|d| d.channel_type == list_default.channel_type)
+            }))
+            .collect();
+        if defaults.is_empty() {
+            return resolved;
+        }
+
+        let mut fixture_ids: Vec<usize> = cue.static_values.iter().map(|v| v.fixture_id).collect();
+        fixture_ids.sort_unstable();
+        fixture_ids.dedup();
+        for fixture_id in fixture_ids {
+            for default in &defaults {
+                let set = resolved
+                    .static_values
+                    .iter()
+                    .any(|v| v.fixture_id == fixture_id && v.channel_type == default.channel_type);
+                if !set {
+                    resolved.static_values.push(StaticValue {
+                        fixture_id,
+                        channel_type: default.channel_type.clone(),
+                        value: default.value,
+                    });
+                }
+            }
+        }
+        resolved
+    }
 }
 
 #[derive(Clone, Debug, Serialize, Deserialize)]
@@ -17,7 +130,27 @@ pub struct Cue {
     pub id: usize,
     pub name: String,
     // Time to fade to the new values
+    #[serde(deserialize_with = "absolute::deserialize")]
     pub fade_time: Duration,
+    // Per-attribute fade times, falling back to fade_time when unset
+    #[serde(
+        default,
+        skip_serializing_if = "Option::is_none",
+        deserialize_with = "absolute::deserialize_option"
+    )]
+    pub intensity_fade: Option<Duration>,
+    #[serde(
+        default,
+        skip_serializing_if = "Option::is_none",
+        deserialize_with = "absolute::deserialize_option"
+    )]
+    pub color_fade: Option<Duration>,
+    #[serde(
+        default,
+        skip_serializing_if = "Option::is_none",
+        deserialize_with = "absolute::deserialize_option"
+    )]
+    pub position_fade: Option<Duration>,
     // TODO - Wait before starting the fade
     //pub delay_time: Duration,
     pub static_values: Vec<StaticValue>,
@@ -26,6 +159,48 @@ pub struct Cue {
     pub timecode: Option<String>,
     // A blocking cue prevents level changes from tracking through it and successive cues.
     pub is_blocking: bool,
+    // Position presets, resolved against the venue's config when the cue runs
+    #[serde(default)]
+    pub positions: Vec<PositionValue>,
+    // Fixtures that start their fade late, e.g. for a ripple across a row of PARs
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub delays: Vec<FixtureDelay>,
+    // Values for channels this cue leaves unset, in place of the list's defaults
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub default_values: Vec<DefaultValue>,
+    // Colors to hold running effects at, or release, when the cue starts
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub color_overrides: Vec<ColorOverride>,
+    // Named looks from the show, under the cue's own values, each over the ones before
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub looks: Vec<String>,
+    // Values left to chance, rolled each time the cue runs
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub variations: Vec<Variation>,
+    // Fixtures to take back to their home state, under the cue's own values
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub release: Option<Release>,
+    // Reminders for the operator, shown while the cue is next or running
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub notes: String,
+    // Something the operator has to confirm before Go runs the cue, e.g. a pyro safety check
+    #[serde(default, skip_serializing_if = "String::is_empty")]
+    pub warning: String,
+    // Run the next cue on its own, after a time or a number of effect cycles
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub follow: Option<Follow>,
+    // Steps to run through over the cue's values for as long as the cue runs
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub chase: Option<Chase>,
+    // Flashed, hold running effects where they are until the flash is released
+    #[serde(default)]
+    pub freeze_others: bool,
+    // Fade time that follows the tempo, e.g. "4b" or "2bar", in place of fade_time
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub tempo_fade: Option<MusicalDuration>,
+    // Fields the show fills in from its vars, noted when the show loads
+    #[serde(skip)]
+    pub variables: Vec<VariableRef>,
 }
 
 impl Default for Cue {
@@ -34,23 +209,406 @@ impl Default for Cue {
             id: 0,
             name: "".to_string(),
             fade_time: Duration::ZERO,
+            intensity_fade: None,
+            color_fade: None,
+            position_fade: None,
             //delay_time: Duration::ZERO,
             timecode: None,
             static_values: vec![],
             effects: vec![],
             pixel_effects: vec![],
             is_blocking: false,
+            positions: vec![],
+            delays: vec![],
+            default_values: vec![],
+            color_overrides: vec![],
+            looks: vec![],
+            variations: vec![],
+            release: None,
+            notes: String::new(),
+            warning: String::new(),
+            follow: None,
+            chase: None,
+            freeze_others: false,
+            tempo_fade: None,
+            variables: vec![],
         }
     }
 }
 
-#[derive(Clone, Debug, Serialize, Deserialize)]
+impl Cue {
+    /// A cue taking every attribute of the given fixtures, or of every fixture when there are
+    /// none, back to their home state, e.g. to end a song
+    pub fn release_all(name: &str, fixture_ids: &[usize], fade: Duration) -> Self {
+        Self {
+            name: name.to_string(),
+            fade_time: fade,
+            release: Some(Release {
+                fixture_ids: fixture_ids.to_vec(),
+                attributes: vec![],
+            }),
+            ..Self::default()
+        }
+    }
+
+    /// Fade over a length that follows the tempo, e.g. `MusicalDuration::Bars(2.0)`
+    pub fn with_tempo_fade(mut self, fade: MusicalDuration) -> Self {
+        self.tempo_fade = Some(fade);
+        self
+    }
+
+    /// The cue with its tempo fade worked out at `meter`'s tempo. The cue engine calls this as
+    /// the cue runs, so the fade takes its length from the tempo at the Go rather than when the
+    /// show was loaded.
+    pub fn at_tempo(mut self, meter: &Meter) -> Self {
+        if let Some(fade) = self.tempo_fade {
+            self.fade_time = fade.resolve(meter);
+        }
+        self
+    }
+
+    /// Fade time for an attribute group
+    pub fn fade_for(&self, attribute: Attribute) -> Duration {
+        let fade = match attribute {
+            Attribute::Intensity => self.intensity_fade,
+            Attribute::Color => self.color_fade,
+            Attribute::Position => self.position_fade,
+            Attribute::Other => None,
+        };
+        fade.unwrap_or(self.fade_time)
+    }
+
+    /// A cue that only sets the color of the given fixtures, leaving intensity, position and
+    /// everything else tracking from earlier cues
+    pub fn color_only(
+        name: &str,
+        fixture_ids: &[usize],
+        rgb: (u8, u8, u8),
+        fade: Duration,
+    ) -> Self {
+        let (red, green, blue) = rgb;
+        Self {
+            name: name.to_string(),
+            color_fade: Some(fade),
+            static_values: fixture_ids
+                .iter()
+                .flat_map(|&fixture_id| {
+                    [
+                        (ChannelType::Red, red),
+                        (ChannelType::Green, green),
+                        (ChannelType::Blue, blue),
+                    ]
+                    .map(|(channel_type, value)| StaticValue {
+                        fixture_id,
+                        channel_type,
+                        value,
+                    })
+                })
+                .collect(),
+            ..Self::default()
+        }
+    }
+
+    /// A cue that only sets the intensity of the given fixtures
+    pub fn intensity_only(name: &str, fixture_ids: &[usize], level: u8, fade: Duration) -> Self {
+        Self {
+            name: name.to_string(),
+            intensity_fade: Some(fade),
+            static_values: fixture_ids
+                .iter()
+                .map(|&fixture_id| StaticValue {
+                    fixture_id,
+                    channel_type: ChannelType::Dimmer,
+                    value: level,
+                })
+                .collect(),
+            ..Self::default()
+        }
+    }
+
+    /// A cue that colors the given fixtures and leaves their intensity to `intensity`, an
+    /// effect on the dimmers alone. The effect pulses the color without ever changing it.
+    pub fn color_base(
+        name: &str,
+        fixture_ids: &[usize],
+        rgb: (u8, u8, u8),
+        intensity: Effect,
+    ) -> Self {
+        Self {
+            effects: vec![EffectMapping::intensity_only(
+                &format!("{name} Intensity"),
+                intensity,
+                fixture_ids,
+                EffectDistribution::All,
+            )],
+            ..Self::color_only(name, fixture_ids, rgb, Duration::ZERO)
+        }
+    }
+
+    /// One cue that brings each fixture to the same values, each starting `stagger` after
+    /// the one before
+    pub fn ripple(
+        name: &str,
+        fixture_ids: &[usize],
+        values: &[(ChannelType, u8)],
+        fade: Duration,
+        stagger: Duration,
+    ) -> Self {
+        Self {
+            name: name.to_string(),
+            fade_time: fade,
+            static_values: fixture_ids
+                .iter()
+                .flat_map(|&fixture_id| {
+                    values.iter().map(move |(channel_type, value)| StaticValue {
+                        fixture_id,
+                        channel_type: channel_type.clone(),
+                        value: *value,
+                    })
+                })
+                .collect(),
+            delays: fixture_ids
+                .iter()
+                .enumerate()
+                .map(|(i, &fixture_id)| FixtureDelay {
+                    fixture_id,
+                    delay: stagger * i as u32,
+                })
+                .collect(),
+            ..Self::default()
+        }
+    }
+
+    /// Drop static values outside the given attribute groups, so the cue only changes what it
+    /// means to. The cue stops blocking, since that would clear the attributes it leaves alone.
+    pub fn mask(&mut self, attributes: &[Attribute]) {
+        self.static_values
+            .retain(|v| attributes.contains(&Attribute::of(&v.channel_type)));
+        self.is_blocking = false;
+    }
+
+    /// Every fixture the cue references, sorted and without repeats
+    pub fn fixture_ids(&self) -> Vec<usize> {
+        let mut ids: Vec<usize> = self
+            .static_values
+            .iter()
+            .map(|v| v.fixture_id)
+            .chain(self.effects.iter().flat_map(|e| e.fixture_ids.clone()))
+            .chain(
+                self.pixel_effects
+                    .iter()
+                    .flat_map(|e| e.fixture_ids.clone()),
+            )
+            .chain(self.positions.iter().map(|p| p.fixture_id))
+            .chain(self.delays.iter().map(|d| d.fixture_id))
+            .chain(self.release.iter().flat_map(|r| r.fixture_ids.clone()))
+            .chain(self.chase.iter().flat_map(|c| {
+                c.steps
+                    .iter()
+                    .flat_map(|s| s.static_values.iter().map(|v| v.fixture_id))
+            }))
+            .collect();
+        ids.sort_unstable();
+        ids.dedup();
+        ids
+    }
+
+    /// Drop every reference to fixtures that `keep` rejects. Effects left with no fixtures
+    /// are dropped too.
+    pub fn retain_fixtures(&mut self, keep: impl Fn(usize) -> bool) {
+        self.static_values.retain(|v| keep(v.fixture_id));
+        for effect in &mut self.effects {
+            effect.fixture_ids.retain(|id| keep(*id));
+        }
+        self.effects.retain(|e| !e.fixture_ids.is_empty());
+        for effect in &mut self.pixel_effects {
+            effect.fixture_ids.retain(|id| keep(*id));
+        }
+        self.pixel_effects.retain(|e| !e.fixture_ids.is_empty());
+        self.positions.retain(|p| keep(p.fixture_id));
+        self.delays.retain(|d| keep(d.fixture_id));
+        if let Some(chase) = &mut self.chase {
+            for step in &mut chase.steps {
+                step.static_values.retain(|v| keep(v.fixture_id));
+            }
+        }
+        // A release left with no fixtures would release every fixture, so it goes too
+        if let Some(release) = &mut self.release {
+            if !release.fixture_ids.is_empty() {
+                release.fixture_ids.retain(|id| keep(*id));
+                if release.fixture_ids.is_empty() {
+                    self.release = None;
+                }
+            }
+        }
+    }
+
+    /// How long the cue takes to complete: its longest fade after its longest delay
+    pub fn completion_time(&self) -> Duration {
+        let delay = self
+            .delays
+            .iter()
+            .map(|d| d.delay)
+            .max()
+            .unwrap_or_default();
+        delay + self.longest_fade()
+    }
+
+    /// Channels the cue writes more than once, e.g. "fixture 0 Red set twice, the last value
+    /// wins". Within a cue the later value or effect always wins a channel, but the earlier
+    /// one is dead weight at best. Intensity effects merge by the show's policy instead, so
+    /// only overlaps on other channels count.
+    pub fn duplicate_writes(&self) -> Vec<String> {
+        let mut duplicates = Vec::new();
+        let mut set: Vec<(usize, &ChannelType)> = Vec::new();
+        for value in &self.static_values {
+            let key = (value.fixture_id, &value.channel_type);
+            if !set.contains(&key) {
+                set.push(key);
+                continue;
+            }
+            let duplicate = format!(
+                "fixture {} {} set twice, the last value wins",
+                value.fixture_id, value.channel_type
+            );
+            if !duplicates.contains(&duplicate) {
+                duplicates.push(duplicate);
+            }
+        }
+
+        // The effect that last wrote each channel, and how many channels each later effect
+        // takes from an earlier one
+        let mut written: Vec<(usize, &ChannelType, &str)> = Vec::new();
+        let mut overlaps: Vec<(&str, &str, usize)> = Vec::new();
+        for effect in &self.effects {
+            let channels = effect
+                .channel_types
+                .iter()
+                .filter(|channel_type| Attribute::of(channel_type) != Attribute::Intensity);
+            for channel_type in channels {
+                for fixture_id in &effect.fixture_ids {
+                    let earlier = written
+                        .iter_mut()
+                        .find(|(id, c, _)| id == fixture_id && *c == channel_type);
+                    let Some((_, _, earlier)) = earlier else {
+                        written.push((*fixture_id, channel_type, effect.name.as_str()));
+                        continue;
+                    };
+                    let pair = (*earlier, effect.name.as_str());
+                    match overlaps.iter_mut().find(|(a, b, _)| (*a, *b) == pair) {
+                        Some((_, _, count)) => *count += 1,
+                        None => overlaps.push((pair.0, pair.1, 1)),
+                    }
+                    *earlier = effect.name.as_str();
+                }
+            }
+        }
+        duplicates.extend(overlaps.into_iter().map(|(earlier, later, count)| {
+            format!("effects '{earlier}' and '{later}' share {count} channel(s), '{later}' wins")
+        }));
+        duplicates
+    }
+
+    /// Fades set shorter than one frame, e.g. "10ms color fade". They snap, as a zero fade
+    /// does, but are more often a typo, such as 10ms for 10s.
+    pub fn short_fades(&self) -> Vec<String> {
+        [
+            ("fade", Some(self.fade_time)),
+            ("intensity fade", self.intensity_fade),
+            ("color fade", self.color_fade),
+            ("position fade", self.position_fade),
+        ]
+        .into_iter()
+        .filter_map(|(what, fade)| {
+            let fade = fade.filter(|fade| !fade.is_zero() && *fade < RENDER_TICK)?;
+            Some(format!("{}ms {what}", fade.as_millis()))
+        })
+        .collect()
+    }
+
+    /// The longest fade of any attribute group
+    pub fn longest_fade(&self) -> Duration {
+        [
+            self.fade_time,
+            self.fade_for(Attribute::Intensity),
+            self.fade_for(Attribute::Color),
+            self.fade_for(Attribute::Position),
+        ]
+        .into_iter()
+        .max()
+        .unwrap_or_default()
+    }
+}
+
+/// A warning that the cue at `location` snaps, if any of its fades are shorter than a frame
+pub(crate) fn short_fade_warning(location: &str, cue: &Cue) -> Option<String> {
+    let fades = cue.short_fades();
+    (!fades.is_empty()).then(|| {
+        format!(
+            "{location} snaps: {} shorter than one {}ms frame",
+            fades.join(", "),
+            RENDER_TICK.as_millis()
+        )
+    })
+}
+
+#[derive(Clone, Debug, PartialEq, Serialize, Deserialize)]
 pub struct StaticValue {
     pub fixture_id: usize,
     pub channel_type: ChannelType,
     pub value: u8,
 }
 
+/// A channel value filled in on fixtures a cue sets other channels of
+#[derive(Clone, Debug, PartialEq, Serialize, Deserialize)]
+pub struct DefaultValue {
+    pub channel_type: ChannelType,
+    pub value: u8,
+}
+
+/// Values a cue applies only some of the times it runs, or with a color picked at random,
+/// so a cue that comes round again doesn't look the same each time
+#[derive(Clone, Debug, PartialEq, Serialize, Deserialize)]
+pub struct Variation {
+    /// Chance from 0.0 to 1.0 that the variation applies each time its cue runs
+    #[serde(default = "always")]
+    pub probability: f64,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub static_values: Vec<StaticValue>,
+    /// Fixtures to color from `color_choices`
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub fixture_ids: Vec<usize>,
+    /// Colors to pick one of, all fixtures alike, more often the heavier it is
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub color_choices: Vec<WeightedColor>,
+}
+
+fn always() -> f64 {
+    1.0
+}
+
+#[derive(Clone, Debug, PartialEq, Serialize, Deserialize)]
+pub struct WeightedColor {
+    pub color: (u8, u8, u8),
+    #[serde(default = "always")]
+    pub weight: f64,
+}
+
+/// How long a fixture waits after its cue starts before fading
+#[derive(Clone, Debug, PartialEq, Serialize, Deserialize)]
+pub struct FixtureDelay {
+    pub fixture_id: usize,
+    #[serde(deserialize_with = "absolute::deserialize")]
+    pub delay: Duration,
+}
+
+/// Points a fixture at a named position preset rather than fixed pan/tilt values
+#[derive(Clone, Debug, PartialEq, Serialize, Deserialize)]
+pub struct PositionValue {
+    pub fixture_id: usize,
+    pub preset: String,
+}
+
 #[derive(Clone, Debug, Serialize)]
 pub struct EffectMapping {
     pub name: String,
@@ -62,6 +620,26 @@ pub struct EffectMapping {
     pub release: EffectRelease,
 }
 
+impl EffectMapping {
+    /// An effect on the given fixtures' intensity only, leaving their color to the cues. On
+    /// fixtures without a dimmer it scales whatever color they've been given.
+    pub fn intensity_only(
+        name: &str,
+        effect: Effect,
+        fixture_ids: &[usize],
+        distribution: EffectDistribution,
+    ) -> Self {
+        Self {
+            name: name.to_string(),
+            effect,
+            fixture_ids: fixture_ids.to_vec(),
+            channel_types: vec![ChannelType::Dimmer],
+            distribution,
+            release: EffectRelease::default(),
+        }
+    }
+}
+
 impl<'de> Deserialize<'de> for EffectMapping {
     fn deserialize<D>(deserializer: D) -> Result<Self, D::Error>
     where
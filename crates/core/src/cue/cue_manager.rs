@@ -1,6 +1,12 @@
+use std::collections::HashMap;
+use std::sync::Arc;
 use std::time::{Duration, Instant};
 
-use crate::{Cue, CueList, EffectMapping, PixelEffectMapping, StaticValue, TimeCode};
+use crate::clock::{Clock, SystemClock};
+use crate::cue::learn::{LearnedTiming, TimingLearner};
+use crate::{
+    Cue, CueDuration, CueList, EffectMapping, Meter, PixelEffectMapping, StaticValue, TimeCode,
+};
 
 #[derive(Clone, Copy, PartialEq, Debug, Default)]
 pub enum PlaybackState {
@@ -10,10 +16,51 @@ pub enum PlaybackState {
     Holding,
 }
 
+/// Snapshot of a cue list for display, taken in one go so it can't tear mid-update
+#[derive(Clone, Debug, PartialEq)]
+pub struct CueListStatus {
+    pub name: String,
+    /// Whether this is the list playback is following
+    pub is_current: bool,
+    /// Whether this is the list Go and Back drive
+    pub is_selected: bool,
+    pub playback_state: PlaybackState,
+    pub active_cue: Option<CueStatus>,
+    /// Names of the next cues to run
+    pub pending: Vec<String>,
+    /// Number of cues already run
+    pub processed: usize,
+}
+
+/// The cue a list is running and how far through it is
+#[derive(Clone, Debug, PartialEq)]
+pub struct CueStatus {
+    pub index: usize,
+    pub name: String,
+    pub elapsed: Duration,
+    /// Time the cue takes to complete, including delays
+    pub duration: Duration,
+    /// How long the cue runs before the next one, see [`Cue::estimated_duration`]
+    pub estimate: CueDuration,
+    /// How long the chain of follow cues this cue starts runs for
+    pub chain: CueDuration,
+    /// Fraction of the estimate that has elapsed, or of `duration` for open-ended cues, from
+    /// 0.0 to 1.0
+    pub progress: f32,
+}
+
+/// Runs cue lists against the console clock. Displays should read `status` rather than the
+/// timing fields, which change every update.
 pub struct CueManager {
     cue_lists: Vec<CueList>,
     current_cue_list: usize,
     current_cue: usize,
+    /// List Go and Back drive, which takes over playback on the next Go
+    selected_cue_list: usize,
+    /// Cue each list was on when playback last left it, so reselecting a list resumes there
+    resume_points: HashMap<usize, usize>,
+    /// Cue the next Go runs in the current list, set when playback is restored after a restart
+    armed_cue: Option<usize>,
     playback_state: PlaybackState,
     /// Show start time
     pub show_start_time: Option<Instant>,
@@ -31,6 +78,12 @@ pub struct CueManager {
     original_start_time: Option<Instant>,
     /// Current cue progress
     progress: f32,
+    /// Time source for cue and show timing
+    clock: Arc<dyn Clock>,
+    /// Tempo for cues that follow on after their effects' cycles
+    meter: Meter,
+    /// Go timings being learned from a manual run of a list
+    learning: Option<TimingLearner>,
     // audio_player: Option<AudioPlayer>, // Removed - using audio module instead
 }
 
@@ -40,6 +93,9 @@ impl CueManager {
             cue_lists,
             current_cue_list: 0,
             current_cue: 0,
+            selected_cue_list: 0,
+            resume_points: HashMap::new(),
+            armed_cue: None,
             playback_state: PlaybackState::Stopped,
             show_start_time: None,
             show_elapsed_time: 0.0,
@@ -49,24 +105,42 @@ impl CueManager {
             last_update: Instant::now(),
             original_start_time: None,
             progress: 0.0,
+            clock: Arc::new(SystemClock),
+            meter: Meter::default(),
+            learning: None,
         }
     }
 
+    /// Replace the time source used for cue and show timing
+    pub fn set_clock(&mut self, clock: Arc<dyn Clock>) {
+        self.last_update = clock.now();
+        self.clock = clock;
+    }
+
+    /// Keep follow times in effect cycles in step with the tempo
+    pub fn set_meter(&mut self, meter: Meter) {
+        self.meter = meter;
+    }
+
+    pub fn meter(&self) -> Meter {
+        self.meter
+    }
+
     pub fn update(&mut self) {
         if self.playback_state != PlaybackState::Playing {
             return;
         }
 
-        let now = Instant::now();
+        let now = self.clock.now();
 
         // Show Elapsed Time
         if let Some(show_start_time) = self.show_start_time {
-            self.show_elapsed_time = show_start_time.elapsed().as_secs_f64();
+            self.show_elapsed_time = now.duration_since(show_start_time).as_secs_f64();
         }
 
         // Cue Elapsed Time
         if let Some(cue_start_time) = self.current_cue_start_time {
-            self.current_cue_elapsed_time = cue_start_time.elapsed().as_secs_f64();
+            self.current_cue_elapsed_time = now.duration_since(cue_start_time).as_secs_f64();
         }
 
         self.update_timecode();
@@ -82,12 +156,41 @@ impl CueManager {
             }
         }
 
+        // Follow cues run the next cue on their own
+        let current_cue = self
+            .get_current_cue_list()
+            .and_then(|list| list.cue_at_tempo(self.current_cue, &self.meter));
+        let list_len = self
+            .get_current_cue_list()
+            .map_or(0, |list| list.cues.len());
+        if let Some(cue) = &current_cue {
+            let follow = cue
+                .follow
+                .and_then(|_| cue.estimated_duration(&self.meter).fixed());
+            let elapsed = self
+                .current_cue_start_time
+                .map(|started| now.duration_since(started));
+            if follow
+                .zip(elapsed)
+                .is_some_and(|(after, elapsed)| elapsed >= after)
+                && self.current_cue + 1 < list_len
+            {
+                let _ = self.go_to_cue(self.current_cue_list, self.current_cue + 1);
+            }
+        }
+
         // Calculate cue progress for visual feedback
-        if let Some(current_cue) = self.get_current_cue() {
-            if current_cue.fade_time.as_secs_f64() > 0.0 {
-                self.progress = (self.current_cue_elapsed_time
-                    / current_cue.fade_time.as_secs_f64())
-                .min(1.0) as f32;
+        let current_cue = self
+            .get_current_cue_list()
+            .and_then(|list| list.cue_at_tempo(self.current_cue, &self.meter));
+        if let Some(current_cue) = current_cue {
+            let fade = current_cue
+                .estimated_duration(&self.meter)
+                .fixed()
+                .unwrap_or_else(|| current_cue.completion_time());
+            if fade.as_secs_f64() > 0.0 {
+                self.progress =
+                    (self.current_cue_elapsed_time / fade.as_secs_f64()).min(1.0) as f32;
             } else {
                 self.progress = 1.0;
             }
@@ -103,6 +206,21 @@ impl CueManager {
 
     pub fn set_cue_lists(&mut self, cue_lists: Vec<CueList>) {
         self.cue_lists = cue_lists;
+        let count = self.cue_lists.len();
+        self.resume_points.retain(|list, _| *list < count);
+        if self.selected_cue_list >= count {
+            self.selected_cue_list = self.current_cue_list;
+        }
+        let current_len = self
+            .cue_lists
+            .get(self.current_cue_list)
+            .map_or(0, |list| list.cues.len());
+        if self.armed_cue.is_some_and(|cue| cue >= current_len) {
+            self.armed_cue = None;
+        }
+        if self.learning_list().is_some_and(|list| list >= count) {
+            self.learning = None;
+        }
     }
 
     pub fn add_cue_list(&mut self, cue_list: CueList) -> usize {
@@ -130,9 +248,105 @@ impl CueManager {
         self.current_cue_list
     }
 
+    pub fn get_selected_cue_list_idx(&self) -> usize {
+        self.selected_cue_list
+    }
+
+    /// Point Go and Back at a list. The list that's playing carries on until the next Go.
+    pub fn select_cue_list(&mut self, index: usize) -> Result<(), String> {
+        if index >= self.cue_lists.len() {
+            return Err("Invalid cue list index".to_string());
+        }
+        self.selected_cue_list = index;
+        Ok(())
+    }
+
+    /// Select a list and arm it to start from its first cue on the next Go
+    pub fn preload_cue_list(&mut self, index: usize) -> Result<(), String> {
+        let list = self
+            .cue_lists
+            .get(index)
+            .ok_or_else(|| "Invalid cue list index".to_string())?;
+        if list.cues.is_empty() {
+            return Err(format!("Cue list '{}' has no cues", list.name));
+        }
+        if index == self.current_cue_list && self.playback_state != PlaybackState::Stopped {
+            return Err(format!("Cue list '{}' is already playing", list.name));
+        }
+        self.selected_cue_list = index;
+        self.resume_points.remove(&index);
+        Ok(())
+    }
+
+    /// The cue to start a selected list from when it takes over playback: the one after where
+    /// it was left, or its first
+    fn selected_entry_cue(&self, step_back: bool) -> Result<usize, String> {
+        let list = self
+            .cue_lists
+            .get(self.selected_cue_list)
+            .ok_or_else(|| "Invalid cue list index".to_string())?;
+        let cue = match (self.resume_points.get(&self.selected_cue_list), step_back) {
+            (Some(cue), false) => cue + 1,
+            (None, false) => 0,
+            (Some(cue), true) if *cue > 0 => cue - 1,
+            (_, true) => return Err("Already at first cue".to_string()),
+        };
+        if cue >= list.cues.len() {
+            return Err("No next cue".to_string());
+        }
+        Ok(cue)
+    }
+
+    /// Hand playback to the selected list, remembering where the outgoing list was
+    fn enter_selected_list(&mut self, step_back: bool) -> Result<&Cue, String> {
+        let cue = self.selected_entry_cue(step_back)?;
+        if self.playback_state != PlaybackState::Stopped {
+            self.resume_points
+                .insert(self.current_cue_list, self.current_cue);
+        }
+        self.resume_points.remove(&self.selected_cue_list);
+        self.go_to_cue(self.selected_cue_list, cue)
+    }
+
+    /// The list and cue the next Go runs, if there is one
+    pub fn next_go(&self) -> Option<(usize, usize)> {
+        if self.selected_cue_list != self.current_cue_list {
+            let cue = self.selected_entry_cue(false).ok()?;
+            return Some((self.selected_cue_list, cue));
+        }
+        let list = self.cue_lists.get(self.current_cue_list)?;
+        let cue = self.armed_cue.unwrap_or(self.current_cue + 1);
+        (cue < list.cues.len()).then_some((self.current_cue_list, cue))
+    }
+
+    /// Stop and point the next Go at a cue, e.g. to carry on from where a show was before a
+    /// restart
+    pub fn arm(&mut self, list_index: usize, cue_index: usize) -> Result<(), String> {
+        let list = self
+            .cue_lists
+            .get(list_index)
+            .ok_or_else(|| "Invalid cue list index".to_string())?;
+        if cue_index >= list.cues.len() {
+            return Err(format!("Cue list '{}' has no cue {cue_index}", list.name));
+        }
+        let _ = self.stop();
+        self.current_cue_list = list_index;
+        self.selected_cue_list = list_index;
+        self.current_cue = cue_index;
+        self.armed_cue = Some(cue_index);
+        Ok(())
+    }
+
     pub fn remove_cue_list(&mut self, index: usize) -> Result<CueList, String> {
         if index < self.cue_lists.len() {
-            Ok(self.cue_lists.remove(index))
+            let removed = self.cue_lists.remove(index);
+            // Indexes past the removed list have shifted, so the remembered positions no
+            // longer line up
+            self.resume_points.clear();
+            if self.selected_cue_list >= self.cue_lists.len() {
+                self.selected_cue_list = self.cue_lists.len().saturating_sub(1);
+            }
+            Ok(removed)
         } else {
             Err("Cue list index out of bounds".to_string())
         }
@@ -227,12 +441,12 @@ impl CueManager {
 
     /// Selects the previous cue list if available
     pub fn select_previous_cue_list(&mut self) -> Result<(), String> {
-        if self.current_cue_list > 0 {
-            self.current_cue_list = self.current_cue_list - 1;
+        if self.selected_cue_list > 0 {
+            self.selected_cue_list -= 1;
             Ok(())
         } else if !self.cue_lists.is_empty() {
             // Wrap around to the last cue list
-            self.current_cue_list = self.cue_lists.len() - 1;
+            self.selected_cue_list = self.cue_lists.len() - 1;
             Ok(())
         } else {
             Err("No cue lists available".to_string())
@@ -241,12 +455,12 @@ impl CueManager {
 
     /// Selects the next cue list if available
     pub fn select_next_cue_list(&mut self) -> Result<(), String> {
-        if self.current_cue_list + 1 < self.cue_lists.len() {
-            self.current_cue_list = self.current_cue_list + 1;
+        if self.selected_cue_list + 1 < self.cue_lists.len() {
+            self.selected_cue_list += 1;
             Ok(())
         } else if !self.cue_lists.is_empty() {
             // Wrap around to the first cue list
-            self.current_cue_list = 0;
+            self.selected_cue_list = 0;
             Ok(())
         } else {
             Err("No cue lists available".to_string())
@@ -294,12 +508,19 @@ impl CueManager {
         self.current_cue_start_time = None;
         self.original_start_time = None;
         self.current_cue = 0;
+        self.armed_cue = None;
         self.update_timecode();
         self.get_current_cue()
             .ok_or_else(|| "No current cue".to_string())
     }
 
     pub fn go_to_next_cue(&mut self) -> Result<&Cue, String> {
+        if self.selected_cue_list != self.current_cue_list {
+            return self.enter_selected_list(false);
+        }
+        if let Some(cue) = self.armed_cue {
+            return self.go_to_cue(self.current_cue_list, cue);
+        }
         if self.current_cue_list >= self.cue_lists.len() {
             return Err("Invalid cue list index".to_string());
         }
@@ -311,17 +532,22 @@ impl CueManager {
 
         self.progress = 0.0;
         self.current_cue += 1;
-        self.show_start_time = Some(Instant::now());
-        self.current_cue_start_time = Some(Instant::now());
+        let now = self.clock.now();
+        self.show_start_time = Some(now);
+        self.current_cue_start_time = Some(now);
         self.original_start_time = self.current_cue_start_time;
-        self.last_update = Instant::now();
+        self.last_update = now;
         self.playback_state = PlaybackState::Playing;
+        self.note_cue_started(now);
 
         self.get_current_cue()
             .ok_or_else(|| "No current cue".to_string())
     }
 
     pub fn go_to_previous_cue(&mut self) -> Result<&Cue, String> {
+        if self.selected_cue_list != self.current_cue_list {
+            return self.enter_selected_list(true);
+        }
         if self.current_cue_list >= self.cue_lists.len() {
             return Err("Invalid cue list index".to_string());
         }
@@ -352,11 +578,15 @@ impl CueManager {
         }
 
         self.current_cue_list = cue_list_idx;
+        self.selected_cue_list = cue_list_idx;
         self.current_cue = cue_idx;
-        self.current_cue_start_time = Some(Instant::now());
+        self.armed_cue = None;
+        let now = self.clock.now();
+        self.current_cue_start_time = Some(now);
         self.original_start_time = self.current_cue_start_time;
-        self.last_update = Instant::now();
+        self.last_update = now;
         self.playback_state = PlaybackState::Playing;
+        self.note_cue_started(now);
 
         self.get_current_cue()
             .ok_or_else(|| "No current cue".to_string())
@@ -405,6 +635,81 @@ impl CueManager {
         self.playback_state
     }
 
+    /// Snapshot every cue list, listing up to `pending` upcoming cue names for each
+    pub fn status(&self, pending: usize) -> Vec<CueListStatus> {
+        let now = self.clock.now();
+
+        self.cue_lists
+            .iter()
+            .enumerate()
+            .map(|(index, list)| {
+                let is_current = index == self.current_cue_list;
+                let is_selected = index == self.selected_cue_list;
+                if !is_current {
+                    let processed = self.resume_points.get(&index).map_or(0, |cue| cue + 1);
+                    return CueListStatus {
+                        name: list.name.clone(),
+                        is_current,
+                        is_selected,
+                        playback_state: PlaybackState::Stopped,
+                        active_cue: None,
+                        pending: list
+                            .cues
+                            .iter()
+                            .skip(processed)
+                            .take(pending)
+                            .map(|c| c.name.clone())
+                            .collect(),
+                        processed,
+                    };
+                }
+
+                let active_cue = self.current_cue_start_time.and_then(|started| {
+                    let cue = list.cue_at_tempo(self.current_cue, &self.meter)?;
+                    let elapsed = now.duration_since(started);
+                    let duration = cue.completion_time();
+                    let estimate = cue.estimated_duration(&self.meter);
+                    let until = estimate.fixed().unwrap_or(duration);
+                    let progress = if until.is_zero() {
+                        1.0
+                    } else {
+                        (elapsed.as_secs_f64() / until.as_secs_f64()).min(1.0) as f32
+                    };
+                    Some(CueStatus {
+                        index: self.current_cue,
+                        name: cue.name.clone(),
+                        elapsed,
+                        duration,
+                        estimate,
+                        chain: list.chain_duration(self.current_cue, &self.meter),
+                        progress,
+                    })
+                });
+                let next = if active_cue.is_some() {
+                    self.current_cue + 1
+                } else {
+                    self.current_cue
+                };
+
+                CueListStatus {
+                    name: list.name.clone(),
+                    is_current,
+                    is_selected,
+                    playback_state: self.playback_state,
+                    active_cue,
+                    pending: list
+                        .cues
+                        .iter()
+                        .skip(next)
+                        .take(pending)
+                        .map(|c| c.name.clone())
+                        .collect(),
+                    processed: self.current_cue,
+                }
+            })
+            .collect()
+    }
+
     // Audio Playback Control - now handled by audio module
 
     // Cue Management
@@ -423,11 +728,28 @@ impl CueManager {
                 id,
                 name: cue_name,
                 fade_time: Duration::from_secs_f32(fade_time),
+                intensity_fade: None,
+                color_fade: None,
+                position_fade: None,
                 static_values: values,
                 effects,
                 pixel_effects,
                 timecode: None,
                 is_blocking: false,
+                positions: vec![],
+                delays: vec![],
+                default_values: vec![],
+                color_overrides: vec![],
+                looks: vec![],
+                variations: vec![],
+                release: None,
+                notes: String::new(),
+                warning: String::new(),
+                follow: None,
+                chase: None,
+                freeze_others: false,
+                tempo_fade: None,
+                variables: vec![],
             });
         }
     }
@@ -478,9 +800,11 @@ impl CueManager {
         self.current_cue = cue_index;
 
         // Reset cue timing
-        self.current_cue_start_time = Some(Instant::now());
+        let now = self.clock.now();
+        self.current_cue_start_time = Some(now);
         self.current_cue_elapsed_time = 0.0;
         self.progress = 0.0;
+        self.note_cue_started(now);
 
         log::info!(
             "Jumped to cue {}: {}",
@@ -490,10 +814,50 @@ impl CueManager {
         Ok(())
     }
 
+    /// When the current cue started, if one is running
+    pub fn get_current_cue_start_time(&self) -> Option<Instant> {
+        self.current_cue_start_time
+    }
+
+    /// Move the running cue's start, e.g. to line it up with another console running it
+    pub fn set_current_cue_started(&mut self, started: Instant) {
+        self.current_cue_start_time = Some(started);
+        self.original_start_time = Some(started);
+    }
+
     /// Get the current cue index (public accessor)
     pub fn get_current_cue_index(&self) -> usize {
         self.current_cue
     }
+
+    /// Start learning a list's timing from the Go presses of a manual run, dropping anything
+    /// learned before
+    pub fn start_learning(&mut self, list_index: usize) -> Result<(), String> {
+        if list_index >= self.cue_lists.len() {
+            return Err("Invalid cue list index".to_string());
+        }
+        self.learning = Some(TimingLearner::new(list_index));
+        Ok(())
+    }
+
+    /// Stop learning, returning the list learned and its timings
+    pub fn stop_learning(&mut self) -> Option<(usize, Vec<LearnedTiming>)> {
+        let learner = self.learning.take()?;
+        Some((learner.list_index(), learner.learned()))
+    }
+
+    /// The list whose timing is being learned, if any
+    pub fn learning_list(&self) -> Option<usize> {
+        self.learning.as_ref().map(|learner| learner.list_index())
+    }
+
+    fn note_cue_started(&mut self, now: Instant) {
+        if let Some(learner) = &mut self.learning {
+            if learner.list_index() == self.current_cue_list {
+                learner.cue_started(self.current_cue, now, self.meter);
+            }
+        }
+    }
 }
 
 impl Clone for CueManager {
@@ -502,6 +866,9 @@ impl Clone for CueManager {
             cue_lists: self.cue_lists.clone(),
             current_cue_list: self.current_cue_list,
             current_cue: self.current_cue,
+            selected_cue_list: self.selected_cue_list,
+            resume_points: self.resume_points.clone(),
+            armed_cue: self.armed_cue,
             playback_state: self.playback_state,
             show_start_time: self.show_start_time,
             show_elapsed_time: self.show_elapsed_time,
@@ -511,6 +878,9 @@ impl Clone for CueManager {
             last_update: self.last_update,
             original_start_time: self.original_start_time,
             progress: self.progress,
+            clock: Arc::clone(&self.clock),
+            meter: self.meter,
+            learning: self.learning.clone(),
         }
     }
 }
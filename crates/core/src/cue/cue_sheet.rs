@@ -0,0 +1,342 @@
+use std::fmt;
+use std::time::Duration;
+
+use super::cue::{Cue, CueList};
+use super::estimate::Follow;
+use crate::{Meter, TimeCode};
+
+/// Frame rate of the timecode anchors written for cue sheet times
+const SHEET_FRAME_RATE: u8 = 30;
+
+/// When a cue sheet row goes, as a stage manager writes it down
+#[derive(Clone, Copy, Debug, PartialEq)]
+pub enum SheetTime {
+    /// Time into the show, e.g. "1:20.5" or "0:01:20"
+    Absolute(Duration),
+    /// Bar and beat, counting from 1, e.g. "17.3"
+    Musical { bar: u32, beat: u32 },
+}
+
+impl SheetTime {
+    /// Time into the show, with bars and beats at the tempo of `meter`
+    pub fn resolve(&self, meter: &Meter) -> Duration {
+        match self {
+            SheetTime::Absolute(at) => *at,
+            SheetTime::Musical { bar, beat } => {
+                let beats = (*bar as f64 - 1.0) * meter.beats_per_bar as f64 + (*beat as f64 - 1.0);
+                meter.duration_of(beats)
+            }
+        }
+    }
+
+    /// Read a `time` cell: seconds, e.g. "75.5", or minutes and seconds with hours in front
+    /// if need be, e.g. "1:20.5" or "0:01:20"
+    pub fn absolute(s: &str) -> Result<Self, String> {
+        let text = s.trim();
+        let mismatch = || format!("'{s}' isn't a time, write it as seconds, m:ss or h:mm:ss");
+        let parts: Vec<&str> = text.split(':').collect();
+        if parts.len() > 3 {
+            return Err(mismatch());
+        }
+        // Only the seconds can be fractional
+        let (seconds, whole) = parts.split_last().expect("split gives at least one part");
+        let seconds: f64 = seconds.parse().map_err(|_| mismatch())?;
+        let mut total = 0.0;
+        for part in whole {
+            let count: u32 = part
+                .parse()
+                .map_err(|_| format!("'{part}' in '{s}' isn't a whole number"))?;
+            total = total * 60.0 + count as f64;
+        }
+        Duration::try_from_secs_f64(total * 60.0 + seconds)
+            .map(SheetTime::Absolute)
+            .map_err(|_| format!("'{s}' isn't a time into the show"))
+    }
+
+    /// Read a `position` cell: a bar, or a bar and beat, e.g. "17" or "17.3"
+    pub fn musical(s: &str) -> Result<Self, String> {
+        let text = s.trim();
+        if text.contains(':') {
+            return Err(format!("'{s}' is a time, not a bar.beat position"));
+        }
+        let mismatch = || format!("'{s}' isn't a bar.beat position");
+        let (bar, beat) = text.split_once('.').unwrap_or((text, "1"));
+        let bar: u32 = bar.parse().map_err(|_| mismatch())?;
+        let beat: u32 = beat.parse().map_err(|_| mismatch())?;
+        if bar == 0 || beat == 0 {
+            return Err(format!("'{s}' counts bars and beats from 1"));
+        }
+        Ok(SheetTime::Musical { bar, beat })
+    }
+}
+
+impl fmt::Display for SheetTime {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        match self {
+            SheetTime::Absolute(at) => write!(f, "{:.2}s", at.as_secs_f64()),
+            SheetTime::Musical { bar, beat } => write!(f, "bar {bar} beat {beat}"),
+        }
+    }
+}
+
+/// One cue on a cue sheet
+#[derive(Clone, Debug, PartialEq)]
+pub struct CueSheetRow {
+    /// Line of the sheet the row is on, counting from 1
+    pub line: usize,
+    /// Cue number as written, matched against cue IDs
+    pub number: String,
+    pub label: String,
+    pub time: Option<SheetTime>,
+    /// What the stage manager wants to see, noted on cues that don't exist yet
+    pub look: String,
+}
+
+/// Read a CSV cue sheet. The first row names the columns, in any order and any case: `cue`
+/// (or `number`, `no`, `#`), `label` (or `name`), `time` (or `at`) for time into the show,
+/// `position` (or `bar`) for bar.beat, and `look` (or `description`, `notes`). Every column
+/// but the cue number or label is optional, and blank rows are skipped. A row with both a
+/// time and a position goes at its time.
+pub fn parse_cue_sheet(text: &str) -> Result<Vec<CueSheetRow>, String> {
+    let mut lines = text
+        .lines()
+        .enumerate()
+        .map(|(index, line)| (index + 1, line))
+        .filter(|(_, line)| !line.trim().is_empty());
+    let Some((header_line, header)) = lines.next() else {
+        return Err("The cue sheet is empty".to_string());
+    };
+    let header = split_row(header).map_err(|e| format!("Line {header_line}: {e}"))?;
+    let column = |names: &[&str]| {
+        header
+            .iter()
+            .position(|h| names.contains(&h.trim().to_ascii_lowercase().as_str()))
+    };
+    let number = column(&["cue", "number", "no", "#"]);
+    let label = column(&["label", "name"]);
+    let time = column(&["time", "at"]);
+    let position = column(&["position", "bar"]);
+    let look = column(&["look", "description", "notes"]);
+    if number.is_none() && label.is_none() {
+        return Err(format!(
+            "Line {header_line}: the header needs a cue or label column"
+        ));
+    }
+
+    let mut rows = Vec::new();
+    for (line, text) in lines {
+        let fields = split_row(text).map_err(|e| format!("Line {line}: {e}"))?;
+        let field = |column: Option<usize>| {
+            column
+                .and_then(|c| fields.get(c))
+                .map(|f| f.trim().to_string())
+                .unwrap_or_default()
+        };
+        // Each column is read as its own kind of time, so a cell in the wrong one is an error
+        // rather than a guess
+        let read = |column: Option<usize>, parse: fn(&str) -> Result<SheetTime, String>| {
+            let cell = field(column);
+            if cell.is_empty() {
+                return Ok(None);
+            }
+            parse(&cell)
+                .map(Some)
+                .map_err(|e| format!("Line {line}: {e}"))
+        };
+        let at = read(time, SheetTime::absolute)?;
+        let bar = read(position, SheetTime::musical)?;
+        let row = CueSheetRow {
+            line,
+            number: field(number),
+            label: field(label),
+            time: at.or(bar),
+            look: field(look),
+        };
+        if row.number.is_empty() && row.label.is_empty() {
+            return Err(format!("Line {line}: the row has no cue number or label"));
+        }
+        rows.push(row);
+    }
+    Ok(rows)
+}
+
+/// Split a CSV row on commas, with double quotes around fields that hold commas and `""` for
+/// a quote inside them
+fn split_row(text: &str) -> Result<Vec<String>, String> {
+    let mut fields = Vec::new();
+    let mut field = String::new();
+    let mut quoted = false;
+    let mut chars = text.chars().peekable();
+    while let Some(c) = chars.next() {
+        match c {
+            '"' if quoted && chars.peek() == Some(&'"') => {
+                field.push('"');
+                chars.next();
+            }
+            '"' => quoted = !quoted,
+            ',' if !quoted => fields.push(std::mem::take(&mut field)),
+            c => field.push(c),
+        }
+    }
+    if quoted {
+        return Err("a quoted field isn't closed".to_string());
+    }
+    fields.push(field);
+    Ok(fields)
+}
+
+/// What merging a cue sheet into a cue list changed
+#[derive(Clone, Debug, Default, PartialEq)]
+pub struct CueSheetMerge {
+    /// One line per cue that changed, with what changed
+    pub updated: Vec<String>,
+    /// One line per stub cue added for a row the list didn't have
+    pub added: Vec<String>,
+    /// Cues matched whose timing and label were already as on the sheet
+    pub unchanged: usize,
+}
+
+impl fmt::Display for CueSheetMerge {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        for line in &self.updated {
+            writeln!(f, "Updated {line}")?;
+        }
+        for line in &self.added {
+            writeln!(f, "Added {line}")?;
+        }
+        writeln!(
+            f,
+            "{} updated, {} added, {} unchanged",
+            self.updated.len(),
+            self.added.len(),
+            self.unchanged
+        )
+    }
+}
+
+/// Merge a cue sheet's labels and timing into `list`, with bar.beat positions at the tempo
+/// of `meter`.
+///
+/// A row matches the cue whose ID is its number, or failing that the cue with its label. The
+/// row's label renames the cue. A cue that follows on after a time keeps doing so, with the
+/// time until the next timed row on the sheet; any other cue is anchored to the timeline at
+/// the row's time. A row matching no cue adds a stub after the cue of the row before it,
+/// named from the row and noted as left to do.
+pub fn merge_cue_sheet(list: &mut CueList, rows: &[CueSheetRow], meter: &Meter) -> CueSheetMerge {
+    let times: Vec<Option<Duration>> = rows
+        .iter()
+        .map(|row| row.time.map(|t| t.resolve(meter)))
+        .collect();
+    let mut merge = CueSheetMerge::default();
+    // Where the cue of the row before is in the list, so stubs go in sheet order
+    let mut previous: Option<usize> = None;
+
+    for (i, row) in rows.iter().enumerate() {
+        let at = times[i];
+        let until_next = at.and_then(|at| {
+            let next = times[i + 1..].iter().flatten().next()?;
+            next.checked_sub(at).filter(|gap| !gap.is_zero())
+        });
+
+        match find_cue(list, row) {
+            Some(index) => {
+                let cue = &mut list.cues[index];
+                let changes = apply_row(cue, row, at, until_next);
+                if changes.is_empty() {
+                    merge.unchanged += 1;
+                } else {
+                    merge
+                        .updated
+                        .push(format!("'{}': {}", cue.name, changes.join(", ")));
+                }
+                previous = Some(index);
+            }
+            None => {
+                let id = row.number.parse().unwrap_or_else(|_| {
+                    list.cues
+                        .iter()
+                        .map(|c| c.id.saturating_add(1))
+                        .max()
+                        .unwrap_or(0)
+                });
+                let cue = stub(row, id, at);
+                merge.added.push(match &cue.timecode {
+                    Some(timecode) => format!("stub '{}' (cue {id}) at {timecode}", cue.name),
+                    None => format!("stub '{}' (cue {id})", cue.name),
+                });
+                let index = previous.map_or(0, |p| p + 1);
+                list.cues.insert(index, cue);
+                previous = Some(index);
+            }
+        }
+    }
+    merge
+}
+
+fn find_cue(list: &CueList, row: &CueSheetRow) -> Option<usize> {
+    let by_number = row
+        .number
+        .parse::<usize>()
+        .ok()
+        .and_then(|id| list.cues.iter().position(|c| c.id == id));
+    by_number.or_else(|| {
+        if row.label.is_empty() {
+            return None;
+        }
+        list.cues
+            .iter()
+            .position(|c| c.name.eq_ignore_ascii_case(&row.label))
+    })
+}
+
+/// Put a row's label and timing on the cue it matched, returning what changed
+fn apply_row(
+    cue: &mut Cue,
+    row: &CueSheetRow,
+    at: Option<Duration>,
+    until_next: Option<Duration>,
+) -> Vec<String> {
+    let mut changes = Vec::new();
+    if !row.label.is_empty() && cue.name != row.label {
+        changes.push(format!("renamed from '{}'", cue.name));
+        cue.name = row.label.clone();
+    }
+    if let Some(Follow::After(current)) = cue.follow {
+        if let Some(after) = until_next.filter(|after| *after != current) {
+            cue.follow = Some(Follow::After(after));
+            changes.push(format!("follows on after {:.2}s", after.as_secs_f64()));
+        }
+    } else if let Some(at) = at {
+        let timecode = timecode_at(at);
+        if cue.timecode.as_deref() != Some(timecode.as_str()) {
+            changes.push(format!("at {timecode}"));
+            cue.timecode = Some(timecode);
+        }
+    }
+    changes
+}
+
+/// An empty cue standing in for a row the list doesn't have yet
+fn stub(row: &CueSheetRow, id: usize, at: Option<Duration>) -> Cue {
+    let name = if row.label.is_empty() {
+        format!("Cue {}", row.number)
+    } else {
+        row.label.clone()
+    };
+    let look = if row.look.is_empty() {
+        "build this cue"
+    } else {
+        row.look.as_str()
+    };
+    Cue {
+        id,
+        name,
+        timecode: at.map(timecode_at),
+        notes: format!("TODO: {look}"),
+        ..Cue::default()
+    }
+}
+
+fn timecode_at(at: Duration) -> String {
+    TimeCode::from_seconds(at.as_secs_f64(), SHEET_FRAME_RATE).to_string()
+}
@@ -0,0 +1,92 @@
+use std::fmt;
+use std::time::Duration;
+
+use serde::{Deserialize, Serialize};
+
+use crate::{Cue, CueList, Meter};
+
+/// When a cue runs the next cue on its own, chaining cues without a Go
+#[derive(Clone, Copy, Debug, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum Follow {
+    /// This long after the cue starts
+    After(Duration),
+    /// Once the cue's slowest effect has run this many cycles, at the tempo then
+    Cycles(u32),
+}
+
+/// How long a cue runs before the next one, as far as can be told ahead of time
+#[derive(Clone, Copy, Debug, PartialEq)]
+pub enum CueDuration {
+    Fixed(Duration),
+    /// Effects that run until the next Go, with no end to estimate
+    OpenEnded,
+}
+
+impl CueDuration {
+    pub fn fixed(&self) -> Option<Duration> {
+        match self {
+            CueDuration::Fixed(duration) => Some(*duration),
+            CueDuration::OpenEnded => None,
+        }
+    }
+}
+
+impl fmt::Display for CueDuration {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        match self {
+            CueDuration::Fixed(duration) => write!(f, "{:.1}s", duration.as_secs_f64()),
+            CueDuration::OpenEnded => write!(f, "open-ended"),
+        }
+    }
+}
+
+impl Cue {
+    /// One cycle of the cue's slowest effect at `meter`, if it has effects
+    pub fn effect_cycle(&self, meter: &Meter) -> Option<Duration> {
+        self.effects
+            .iter()
+            .filter(|mapping| mapping.effect.params.interval_ratio > 0.0)
+            .map(|mapping| {
+                let params = &mapping.effect.params;
+                meter.duration_of(meter.beats_in(&params.interval) / params.interval_ratio)
+            })
+            .max()
+    }
+
+    /// How long the cue runs before the next one. Cues that follow on run until they do.
+    /// Others run their fades and delays, unless they start effects, which run until the
+    /// next Go.
+    pub fn estimated_duration(&self, meter: &Meter) -> CueDuration {
+        match self.follow {
+            Some(Follow::After(after)) => CueDuration::Fixed(after),
+            Some(Follow::Cycles(cycles)) => CueDuration::Fixed(
+                self.effect_cycle(meter)
+                    .map_or_else(|| self.completion_time(), |cycle| cycle * cycles),
+            ),
+            None if self.effects.is_empty() && self.pixel_effects.is_empty() => {
+                CueDuration::Fixed(self.completion_time())
+            }
+            None => CueDuration::OpenEnded,
+        }
+    }
+}
+
+impl CueList {
+    /// How long the chain of follow cues starting at `index` runs, to the end of the first
+    /// cue that waits for a Go. Open-ended if any cue in it is.
+    pub fn chain_duration(&self, index: usize, meter: &Meter) -> CueDuration {
+        let mut total = Duration::ZERO;
+        for cue in self.cues.iter().skip(index) {
+            let cue = self.resolve(cue).at_tempo(meter);
+            match cue.estimated_duration(meter) {
+                CueDuration::Fixed(duration) => total += duration,
+                CueDuration::OpenEnded => return CueDuration::OpenEnded,
+            }
+            if cue.follow.is_none() {
+                break;
+            }
+        }
+        CueDuration::Fixed(total)
+    }
+}
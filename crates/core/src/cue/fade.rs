@@ -0,0 +1,308 @@
+use std::collections::HashMap;
+use std::time::{Duration, Instant};
+
+use halo_fixtures::{join_16, ChannelType, Fixture, FixtureType};
+use serde::{Deserialize, Serialize};
+
+use crate::cue::cue::short_fade_warning;
+use crate::render::RENDER_TICK;
+use crate::{Cue, StaticValue};
+
+/// Groups of channels that can fade on their own time within a cue
+#[derive(Clone, Copy, Debug, PartialEq, Eq, Serialize, Deserialize)]
+pub enum Attribute {
+    Intensity,
+    Color,
+    Position,
+    /// Everything else, which follows the cue's fade time
+    Other,
+}
+
+impl Attribute {
+    pub fn of(channel_type: &ChannelType) -> Self {
+        match channel_type {
+            ChannelType::Dimmer => Attribute::Intensity,
+            ChannelType::Color
+            | ChannelType::Red
+            | ChannelType::Green
+            | ChannelType::Blue
+            | ChannelType::White
+            | ChannelType::Amber
+            | ChannelType::UV => Attribute::Color,
+            ChannelType::Pan | ChannelType::Tilt | ChannelType::PanFine | ChannelType::TiltFine => {
+                Attribute::Position
+            }
+            _ => Attribute::Other,
+        }
+    }
+}
+
+/// What a running cue fade does while a blackout or full on hides it
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum OverrideFadePolicy {
+    /// Keep fading unseen, so releasing reveals wherever the fade has got to
+    #[default]
+    Continue,
+    /// Pause the fade until the override is released, then pick up where it left off
+    Freeze,
+    /// Snap the fade to its target when the override is released
+    Complete,
+}
+
+/// The cue a fade belongs to: list index, cue index and when it started
+pub(crate) type FadeKey = (usize, usize, Instant);
+
+/// Crossfades tracked values from whatever was on stage when a cue started.
+///
+/// A fade starts from what playback last put out, read back through each fixture's channel
+/// map, rather than from the values last tracked. A fade interrupted part way, or a chase
+/// holding a channel somewhere else, carries on from where the channel actually was.
+///
+/// Each attribute group runs on the cue's fade time for that group, so intensity can snap in
+/// while color is still on its way. Fixtures with a delay hold their old values until it
+/// has passed.
+#[derive(Clone, Default)]
+pub struct CueFade {
+    key: Option<FadeKey>,
+    intensity: Duration,
+    color: Duration,
+    position: Duration,
+    other: Duration,
+    /// How long each delayed fixture waits before it starts fading
+    delays: Vec<(usize, Duration)>,
+    /// Channel values captured the first time the fade touched them
+    from: Vec<(usize, ChannelType, u8)>,
+    /// Playback's output at the end of the last frame, by universe
+    on_stage: HashMap<u8, Vec<u8>>,
+    /// Start the next cue at its target, e.g. when a crossfader already faded into it
+    snap_next: bool,
+    /// When a blackout or full on started hiding the fade, and the policy it was hidden under
+    hidden: Option<(Instant, OverrideFadePolicy)>,
+    /// Time the fade spent frozen under earlier overrides
+    frozen: Duration,
+    /// Finished early by an override releasing
+    finished: bool,
+}
+
+impl CueFade {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Follow the running cue, starting a new fade when it changes
+    pub fn track(&mut self, key: FadeKey, cue: &Cue) {
+        if self.key == Some(key) {
+            return;
+        }
+        self.key = Some(key);
+        self.from.clear();
+        self.frozen = Duration::ZERO;
+        self.finished = false;
+        // A cue started under an override is only hidden from its own start
+        if let Some((since, _)) = &mut self.hidden {
+            *since = (*since).max(key.2);
+        }
+        if std::mem::take(&mut self.snap_next) {
+            self.intensity = Duration::ZERO;
+            self.color = Duration::ZERO;
+            self.position = Duration::ZERO;
+            self.other = Duration::ZERO;
+            self.delays.clear();
+            return;
+        }
+        if let Some(warning) = short_fade_warning(&format!("Cue '{}'", cue.name), cue) {
+            log::warn!("{warning}");
+        }
+        self.delays = cue.delays.iter().map(|d| (d.fixture_id, d.delay)).collect();
+        self.intensity = frame_fade(cue, Attribute::Intensity);
+        self.color = frame_fade(cue, Attribute::Color);
+        self.position = frame_fade(cue, Attribute::Position);
+        self.other = frame_fade(cue, Attribute::Other);
+    }
+
+    /// Note what playback put out this frame, under the programmer and overrides, for the
+    /// next fade to start from
+    pub fn note_output(&mut self, fixtures: &[Fixture]) {
+        for fixture in fixtures
+            .iter()
+            .filter(|f| f.profile.fixture_type != FixtureType::PixelBar)
+        {
+            let buffer = self
+                .on_stage
+                .entry(fixture.universe)
+                .or_insert_with(|| vec![0; 512]);
+            let start = fixture.start_address.saturating_sub(1) as usize;
+            // As sent, so reading back through the fixture's orientation gives the cue's values
+            for (slot, value) in buffer.iter_mut().skip(start).zip(fixture.get_dmx_values()) {
+                *slot = value;
+            }
+        }
+    }
+
+    /// Skip the fade of the next cue that starts
+    pub fn snap_next(&mut self) {
+        self.snap_next = true;
+    }
+
+    /// Note whether a blackout or full on is hiding the output. Fades keep their progress
+    /// apart from what is emitted, so `policy` decides where they are once it is released.
+    pub fn set_hidden(&mut self, hidden: bool, policy: OverrideFadePolicy, now: Instant) {
+        match (self.hidden, hidden) {
+            (None, true) => self.hidden = Some((now, policy)),
+            (Some((since, policy)), false) => {
+                self.hidden = None;
+                match policy {
+                    OverrideFadePolicy::Continue => {}
+                    OverrideFadePolicy::Freeze => {
+                        self.frozen += now.saturating_duration_since(since)
+                    }
+                    OverrideFadePolicy::Complete => self.finished = self.key.is_some(),
+                }
+            }
+            _ => {}
+        }
+    }
+
+    /// How long the fade has run by `now`, leaving out time spent frozen
+    fn elapsed(&self, started: Instant, now: Instant) -> Duration {
+        let now = match self.hidden {
+            Some((since, OverrideFadePolicy::Freeze)) => now.min(since),
+            _ => now,
+        };
+        now.saturating_duration_since(started)
+            .saturating_sub(self.frozen)
+    }
+
+    fn duration(&self, attribute: Attribute) -> Duration {
+        match attribute {
+            Attribute::Intensity => self.intensity,
+            Attribute::Color => self.color,
+            Attribute::Position => self.position,
+            Attribute::Other => self.other,
+        }
+    }
+
+    /// How far an attribute group is through its fade, from 0.0 to 1.0
+    pub fn progress(&self, attribute: Attribute, now: Instant) -> f64 {
+        self.progress_after(attribute, Duration::ZERO, now)
+    }
+
+    /// Progress of an attribute group that waits `delay` before it starts
+    fn progress_after(&self, attribute: Attribute, delay: Duration, now: Instant) -> f64 {
+        let Some((_, _, started)) = self.key else {
+            return 1.0;
+        };
+        if self.finished {
+            return 1.0;
+        }
+        let running = self.elapsed(started, now);
+        let elapsed = running.saturating_sub(delay);
+        let duration = self.duration(attribute);
+        if duration.is_zero() {
+            return if running >= delay { 1.0 } else { 0.0 };
+        }
+        (elapsed.as_secs_f64() / duration.as_secs_f64()).min(1.0)
+    }
+
+    fn delay(&self, fixture_id: usize) -> Duration {
+        self.delays
+            .iter()
+            .find(|(id, _)| *id == fixture_id)
+            .map(|(_, delay)| *delay)
+            .unwrap_or_default()
+    }
+
+    /// Whether every attribute group has reached its target on every fixture
+    pub fn is_complete(&self, now: Instant) -> bool {
+        let delay = self
+            .delays
+            .iter()
+            .map(|(_, delay)| *delay)
+            .max()
+            .unwrap_or_default();
+        [
+            Attribute::Intensity,
+            Attribute::Color,
+            Attribute::Position,
+            Attribute::Other,
+        ]
+        .into_iter()
+        .all(|attribute| self.progress_after(attribute, delay, now) >= 1.0)
+    }
+
+    /// The value to output for a tracked value at `now`
+    pub fn value(&mut self, fixture: &Fixture, target: &StaticValue, now: Instant) -> u8 {
+        let progress = self.progress_after(
+            Attribute::of(&target.channel_type),
+            self.delay(target.fixture_id),
+            now,
+        );
+        if progress >= 1.0 {
+            return target.value;
+        }
+
+        let from = self.from_value(fixture, &target.channel_type, target.value);
+        (from as f64 + (target.value as f64 - from as f64) * progress).round() as u8
+    }
+
+    /// The 16-bit value to output for a tracked pan or tilt at `now`, on a fixture with a
+    /// fine channel, so a slow move glides through the steps between coarse values. `fine`
+    /// is the target's low byte, zero unless the cue sets the fine channel too.
+    pub fn value_16(
+        &mut self,
+        fixture: &Fixture,
+        target: &StaticValue,
+        fine: u8,
+        now: Instant,
+    ) -> u16 {
+        let to = join_16(target.value, fine);
+        let progress = self.progress_after(
+            Attribute::of(&target.channel_type),
+            self.delay(target.fixture_id),
+            now,
+        );
+        let Some(fine_channel) = target.channel_type.fine_channel() else {
+            return to;
+        };
+        if progress >= 1.0 {
+            return to;
+        }
+
+        let from = join_16(
+            self.from_value(fixture, &target.channel_type, target.value),
+            self.from_value(fixture, &fine_channel, fine),
+        );
+        (from as f64 + (to as f64 - from as f64) * progress).round() as u16
+    }
+
+    /// Where a channel's fade starts, captured the first time the fade touches it
+    fn from_value(&mut self, fixture: &Fixture, channel_type: &ChannelType, target: u8) -> u8 {
+        if let Some((_, _, value)) = self
+            .from
+            .iter()
+            .find(|(id, c, _)| *id == fixture.id && c == channel_type)
+        {
+            return *value;
+        }
+        let value = self
+            .on_stage
+            .get(&fixture.universe)
+            .and_then(|data| fixture.channel_value_in(data, channel_type))
+            .or_else(|| fixture.channel_value(channel_type))
+            .unwrap_or(target);
+        self.from.push((fixture.id, channel_type.clone(), value));
+        value
+    }
+}
+
+/// The cue's fade for `attribute`, or none if it's too short for a frame to show a step of.
+/// The values then go out in one write on the first frame, wherever the frames fall.
+fn frame_fade(cue: &Cue, attribute: Attribute) -> Duration {
+    let fade = cue.fade_for(attribute);
+    if fade < RENDER_TICK {
+        Duration::ZERO
+    } else {
+        fade
+    }
+}
@@ -0,0 +1,99 @@
+use std::fmt;
+use std::time::{Duration, Instant};
+
+use serde::{Deserialize, Serialize};
+
+use super::cue::CueList;
+use super::estimate::Follow;
+use crate::Meter;
+
+/// How long a cue ran before the operator's Go for the next one, learned from a manual run
+#[derive(Clone, Copy, Debug, PartialEq, Serialize, Deserialize)]
+pub struct LearnedTiming {
+    pub cue_index: usize,
+    pub after: Duration,
+    /// The same length in beats, at the tempo when the cue started
+    pub beats: f64,
+}
+
+impl fmt::Display for LearnedTiming {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        write!(
+            f,
+            "Cue {}: next after {:.2}s ({:.2} beats)",
+            self.cue_index,
+            self.after.as_secs_f64(),
+            self.beats
+        )
+    }
+}
+
+/// Learns a cue list's timing from a manual run: each time a cue starts, the one before it
+/// is noted as running from its own start to this one.
+///
+/// Only a cue followed by the next cue in the list counts, as a follow time can't express a
+/// jump. A cue run more than once keeps its latest timing.
+#[derive(Clone, Debug)]
+pub struct TimingLearner {
+    list_index: usize,
+    /// The cue running, when it started and the tempo then
+    running: Option<(usize, Instant, Meter)>,
+    learned: Vec<LearnedTiming>,
+}
+
+impl TimingLearner {
+    pub fn new(list_index: usize) -> Self {
+        Self {
+            list_index,
+            running: None,
+            learned: Vec::new(),
+        }
+    }
+
+    /// The list being learned
+    pub fn list_index(&self) -> usize {
+        self.list_index
+    }
+
+    /// Note a cue of the list starting at `now`, with `meter` the tempo then
+    pub fn cue_started(&mut self, cue_index: usize, now: Instant, meter: Meter) {
+        if let Some((running, started, started_meter)) = self.running.take() {
+            if cue_index == running + 1 {
+                let after = now.saturating_duration_since(started);
+                let beats = after.as_secs_f64() / started_meter.duration_of(1.0).as_secs_f64();
+                self.learned.retain(|t| t.cue_index != running);
+                self.learned.push(LearnedTiming {
+                    cue_index: running,
+                    after,
+                    beats,
+                });
+            }
+        }
+        self.running = Some((cue_index, now, meter));
+    }
+
+    /// What's been learned so far, in cue order
+    pub fn learned(&self) -> Vec<LearnedTiming> {
+        let mut learned = self.learned.clone();
+        learned.sort_by_key(|t| t.cue_index);
+        learned
+    }
+}
+
+/// Set follow times on `list` from learned timings, so the next run goes on its own. Returns
+/// what changed, one line per cue.
+pub fn apply_learned(list: &mut CueList, timings: &[LearnedTiming]) -> Vec<String> {
+    let mut changes = Vec::new();
+    for timing in timings {
+        let Some(cue) = list.cues.get_mut(timing.cue_index) else {
+            continue;
+        };
+        cue.follow = Some(Follow::After(timing.after));
+        changes.push(format!(
+            "{}: follows on after {:.2}s",
+            cue.name,
+            timing.after.as_secs_f64()
+        ));
+    }
+    changes
+}
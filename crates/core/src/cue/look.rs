@@ -0,0 +1,33 @@
+use std::collections::HashMap;
+
+use super::cue::{Cue, StaticValue};
+
+/// Named sets of values a show's cues can build on, keyed by look name
+pub type Looks = HashMap<String, Vec<StaticValue>>;
+
+/// A cue's static values with its looks laid underneath.
+///
+/// Looks apply in the order the cue names them, each over the ones before, and the cue's own
+/// values go over all of them. A warning is returned for each look the show doesn't have.
+pub fn expand_looks(cue: &Cue, looks: &Looks) -> (Vec<StaticValue>, Vec<String>) {
+    let mut values: Vec<StaticValue> = Vec::new();
+    let mut warnings = Vec::new();
+
+    let layers = cue.looks.iter().filter_map(|name| match looks.get(name) {
+        Some(look) => Some(look),
+        None => {
+            warnings.push(format!("Cue '{}' uses missing look '{name}'", cue.name));
+            None
+        }
+    });
+    for value in layers.flatten().chain(&cue.static_values) {
+        match values
+            .iter_mut()
+            .find(|v| v.fixture_id == value.fixture_id && v.channel_type == value.channel_type)
+        {
+            Some(existing) => existing.value = value.value,
+            None => values.push(value.clone()),
+        }
+    }
+    (values, warnings)
+}
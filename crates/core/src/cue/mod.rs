@@ -1,2 +1,13 @@
+pub mod chase;
+pub mod crossfade;
 pub mod cue;
 pub mod cue_manager;
+pub mod cue_sheet;
+pub mod estimate;
+pub mod fade;
+pub mod learn;
+pub mod look;
+pub mod position;
+pub mod release;
+pub mod test_pattern;
+pub mod variation;
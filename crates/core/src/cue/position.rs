@@ -0,0 +1,61 @@
+use std::collections::HashMap;
+
+use halo_fixtures::{ChannelType, Fixture, PanTilt};
+
+use crate::cue::cue::PositionValue;
+use crate::show::alias::preset_entry;
+use crate::StaticValue;
+
+/// Pan and tilt for each named position, keyed by preset name then fixture name
+pub type PositionPresets = HashMap<String, HashMap<String, PanTilt>>;
+
+/// Resolve a cue's position presets to pan and tilt values for the current venue.
+///
+/// Fixtures without an entry in the preset fall back to their home position, and a warning is
+/// returned for each. An entry under one of a fixture's aliases is used with a warning too.
+/// References to fixtures that aren't patched are skipped.
+pub fn resolve_positions(
+    positions: &[PositionValue],
+    fixtures: &[Fixture],
+    presets: &PositionPresets,
+) -> (Vec<StaticValue>, Vec<String>) {
+    let mut values = Vec::new();
+    let mut warnings = Vec::new();
+
+    for position in positions {
+        let Some(fixture) = fixtures.iter().find(|f| f.id == position.fixture_id) else {
+            continue;
+        };
+
+        let pan_tilt = match presets
+            .get(&position.preset)
+            .and_then(|preset| preset_entry(preset, fixture))
+        {
+            Some((pan_tilt, warning)) => {
+                warnings
+                    .extend(warning.map(|w| format!("Position preset '{}': {w}", position.preset)));
+                pan_tilt
+            }
+            None => {
+                warnings.push(format!(
+                    "Position preset '{}' has no entry for {}, using its home position",
+                    position.preset, fixture.name
+                ));
+                fixture.home_position()
+            }
+        };
+
+        values.push(StaticValue {
+            fixture_id: fixture.id,
+            channel_type: ChannelType::Pan,
+            value: pan_tilt.pan,
+        });
+        values.push(StaticValue {
+            fixture_id: fixture.id,
+            channel_type: ChannelType::Tilt,
+            value: pan_tilt.tilt,
+        });
+    }
+
+    (values, warnings)
+}
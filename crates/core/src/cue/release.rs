@@ -0,0 +1,59 @@
+use halo_fixtures::{ChannelType, Fixture};
+use serde::{Deserialize, Serialize};
+
+use crate::cue::fade::Attribute;
+use crate::StaticValue;
+
+/// Takes fixtures back to their home state, e.g. at the end of a song so nothing tilted or
+/// strobing carries into the next one
+#[derive(Clone, Debug, Default, PartialEq, Serialize, Deserialize)]
+pub struct Release {
+    /// Fixtures to release, or every patched fixture when empty
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub fixture_ids: Vec<usize>,
+    /// Attributes to release, or all of them when empty
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub attributes: Vec<Attribute>,
+}
+
+impl Release {
+    /// The patched fixtures this releases
+    pub fn fixtures<'a>(&'a self, fixtures: &'a [Fixture]) -> impl Iterator<Item = &'a Fixture> {
+        fixtures
+            .iter()
+            .filter(|f| self.fixture_ids.is_empty() || self.fixture_ids.contains(&f.id))
+    }
+
+    /// Whether this releases channels of `attribute`
+    pub fn releases(&self, attribute: Attribute) -> bool {
+        self.attributes.is_empty() || self.attributes.contains(&attribute)
+    }
+
+    /// Home values for every released channel of the released fixtures
+    pub fn values(&self, fixtures: &[Fixture]) -> Vec<StaticValue> {
+        let mut values = Vec::new();
+        for fixture in self.fixtures(fixtures) {
+            for channel in &fixture.channels {
+                if self.releases(Attribute::of(&channel.channel_type)) {
+                    values.push(StaticValue {
+                        fixture_id: fixture.id,
+                        channel_type: channel.channel_type.clone(),
+                        value: home_value(fixture, &channel.channel_type),
+                    });
+                }
+            }
+        }
+        values
+    }
+}
+
+/// Where a channel rests when nothing is using the fixture: dark, shutter open and pointing
+/// at its home position
+pub fn home_value(fixture: &Fixture, channel_type: &ChannelType) -> u8 {
+    match channel_type {
+        ChannelType::Pan => fixture.home_position().pan,
+        ChannelType::Tilt => fixture.home_position().tilt,
+        ChannelType::Strobe => fixture.profile.strobe_range().open,
+        _ => 0,
+    }
+}
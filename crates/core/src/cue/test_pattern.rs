@@ -0,0 +1,219 @@
+use std::fmt;
+use std::str::FromStr;
+use std::time::Duration;
+
+use halo_fixtures::{ChannelType, Fixture};
+
+use crate::cue::chase::{Chase, ChaseDirection, ChaseRate, ChaseStep};
+use crate::cue::cue::{Cue, StaticValue};
+use crate::grand_master::is_intensity;
+
+/// Canned looks for checking a single fixture against its profile
+#[derive(Clone, Copy, Debug, PartialEq, Eq)]
+pub enum TestPattern {
+    /// Full red, green, blue and white in turn, at full intensity
+    RgbSweep,
+    /// Intensity fading from 0 to full and back
+    IntensityRamp,
+    /// Each channel to full on its own for a second, in profile order, channels sharing a
+    /// type included. The dimmer stays down but for its own step and the steps of channels
+    /// it has to light, such as the colors.
+    ChannelWalk,
+    /// Pan and tilt round the corners of a box
+    PanTiltBox,
+}
+
+impl TestPattern {
+    pub const ALL: [TestPattern; 4] = [
+        TestPattern::RgbSweep,
+        TestPattern::IntensityRamp,
+        TestPattern::ChannelWalk,
+        TestPattern::PanTiltBox,
+    ];
+
+    /// How long each step of the pattern lasts
+    pub fn step_time(&self) -> Duration {
+        match self {
+            TestPattern::RgbSweep | TestPattern::ChannelWalk => Duration::from_secs(1),
+            TestPattern::IntensityRamp | TestPattern::PanTiltBox => Duration::from_secs(2),
+        }
+    }
+
+    /// A cue that runs the pattern on `fixture` as a chase over its channels, all of which
+    /// it sets to zero underneath but for the dimmer where the pattern needs it lit. Refused
+    /// for fixtures without the channels the pattern needs, e.g. a pan/tilt box on a PAR.
+    pub fn cue(&self, fixture: &Fixture) -> Result<Cue, String> {
+        let has = |channel_type: &ChannelType| fixture.channel_value(channel_type).is_some();
+        let value = |channel_type: &ChannelType, value: u8| StaticValue {
+            fixture_id: fixture.id,
+            channel_type: channel_type.clone(),
+            value,
+        };
+        let step = |values: Vec<StaticValue>| ChaseStep {
+            static_values: values,
+            channels: Vec::new(),
+        };
+
+        let mut static_values: Vec<StaticValue> = Vec::new();
+        for channel in &fixture.channels {
+            if !static_values
+                .iter()
+                .any(|v| v.channel_type == channel.channel_type)
+            {
+                static_values.push(value(&channel.channel_type, 0));
+            }
+        }
+        let full_dimmer = |static_values: &mut Vec<StaticValue>| {
+            if let Some(dimmer) = static_values
+                .iter_mut()
+                .find(|v| v.channel_type == ChannelType::Dimmer)
+            {
+                dimmer.value = 255;
+            }
+        };
+
+        let (steps, crossfade) = match self {
+            TestPattern::RgbSweep => {
+                let colors: Vec<ChannelType> = [
+                    ChannelType::Red,
+                    ChannelType::Green,
+                    ChannelType::Blue,
+                    ChannelType::White,
+                ]
+                .into_iter()
+                .filter(|c| has(c))
+                .collect();
+                if colors.len() < 3 {
+                    return Err(format!("{} has no RGB channels to sweep", fixture.name));
+                }
+                full_dimmer(&mut static_values);
+                let steps: Vec<ChaseStep> =
+                    colors.iter().map(|c| step(vec![value(c, 255)])).collect();
+                (steps, 0.0)
+            }
+            TestPattern::IntensityRamp => {
+                if !has(&ChannelType::Dimmer) {
+                    return Err(format!("{} has no dimmer to ramp", fixture.name));
+                }
+                // White, where there's color mixing, so the ramp shows
+                for channel_type in [
+                    ChannelType::Red,
+                    ChannelType::Green,
+                    ChannelType::Blue,
+                    ChannelType::White,
+                ] {
+                    if let Some(v) = static_values
+                        .iter_mut()
+                        .find(|v| v.channel_type == channel_type)
+                    {
+                        v.value = 255;
+                    }
+                }
+                // Two steps always crossfading, so the dimmer rises over one and falls over
+                // the other
+                let steps = vec![
+                    step(vec![value(&ChannelType::Dimmer, 0)]),
+                    step(vec![value(&ChannelType::Dimmer, 255)]),
+                ];
+                (steps, 1.0)
+            }
+            TestPattern::ChannelWalk => {
+                // By index, so each of several channels of one type gets its own step
+                let dimmer = fixture
+                    .channels
+                    .iter()
+                    .position(|c| c.channel_type == ChannelType::Dimmer);
+                let steps: Vec<ChaseStep> = fixture
+                    .channels
+                    .iter()
+                    .enumerate()
+                    .map(|(index, channel)| {
+                        let mut walked = step(vec![value(&channel.channel_type, 255)]);
+                        walked.channels.push((fixture.id, index));
+                        if let Some(dimmer) =
+                            dimmer.filter(|_| !is_intensity(fixture, &channel.channel_type))
+                        {
+                            walked.static_values.push(value(&ChannelType::Dimmer, 255));
+                            walked.channels.push((fixture.id, dimmer));
+                        }
+                        walked
+                    })
+                    .collect();
+                (steps, 0.0)
+            }
+            TestPattern::PanTiltBox => {
+                if !has(&ChannelType::Pan) || !has(&ChannelType::Tilt) {
+                    return Err(format!("{} doesn't pan and tilt", fixture.name));
+                }
+                full_dimmer(&mut static_values);
+                let steps: Vec<ChaseStep> = [(64, 64), (192, 64), (192, 192), (64, 192)]
+                    .into_iter()
+                    .map(|(pan, tilt)| {
+                        step(vec![
+                            value(&ChannelType::Pan, pan),
+                            value(&ChannelType::Tilt, tilt),
+                        ])
+                    })
+                    .collect();
+                (steps, 0.0)
+            }
+        };
+
+        Ok(Cue {
+            name: format!("{} {}", fixture.name, self),
+            static_values,
+            chase: Some(Chase {
+                steps,
+                rate: ChaseRate::Seconds(self.step_time().as_secs_f64()),
+                direction: ChaseDirection::Forward,
+                crossfade,
+            }),
+            ..Cue::default()
+        })
+    }
+}
+
+/// What a test pattern step shows on `fixture`, e.g. "Green (channel 3)", for announcing it
+/// as it plays
+pub fn describe_step(fixture: &Fixture, step: &ChaseStep) -> String {
+    step.static_values
+        .iter()
+        .filter(|v| v.fixture_id == fixture.id)
+        .map(|v| {
+            let name = step
+                .channel_index(fixture, v)
+                .map(|i| format!("{} (channel {})", fixture.channels[i].name, i + 1))
+                .unwrap_or_else(|| v.channel_type.to_string());
+            match v.value {
+                255 => name,
+                value => format!("{name} at {value}"),
+            }
+        })
+        .collect::<Vec<_>>()
+        .join(", ")
+}
+
+impl fmt::Display for TestPattern {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        match self {
+            TestPattern::RgbSweep => write!(f, "rgb-sweep"),
+            TestPattern::IntensityRamp => write!(f, "intensity-ramp"),
+            TestPattern::ChannelWalk => write!(f, "channel-walk"),
+            TestPattern::PanTiltBox => write!(f, "pan-tilt-box"),
+        }
+    }
+}
+
+impl FromStr for TestPattern {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        TestPattern::ALL
+            .into_iter()
+            .find(|pattern| pattern.to_string() == s.to_ascii_lowercase())
+            .ok_or_else(|| {
+                let names: Vec<String> = TestPattern::ALL.iter().map(|p| p.to_string()).collect();
+                format!("Unknown pattern '{s}', expected {}", names.join(", "))
+            })
+    }
+}
@@ -0,0 +1,140 @@
+use std::time::{SystemTime, UNIX_EPOCH};
+
+use halo_fixtures::Fixture;
+
+use super::cue::{Cue, StaticValue, Variation, WeightedColor};
+use super::fade::FadeKey;
+
+/// A small seeded generator (SplitMix64), so a show run from the same seed rolls the same
+/// variations every time
+#[derive(Clone, Debug)]
+pub struct ChanceSource {
+    state: u64,
+}
+
+impl ChanceSource {
+    pub fn new(seed: u64) -> Self {
+        Self { state: seed }
+    }
+
+    /// Seeded from the system clock, for a different show every night
+    pub fn from_time() -> Self {
+        let nanos = SystemTime::now()
+            .duration_since(UNIX_EPOCH)
+            .map_or(0, |d| d.as_nanos() as u64);
+        Self::new(nanos)
+    }
+
+    fn next_u64(&mut self) -> u64 {
+        self.state = self.state.wrapping_add(0x9E37_79B9_7F4A_7C15);
+        let mut z = self.state;
+        z = (z ^ (z >> 30)).wrapping_mul(0xBF58_476D_1CE4_E5B9);
+        z = (z ^ (z >> 27)).wrapping_mul(0x94D0_49BB_1331_11EB);
+        z ^ (z >> 31)
+    }
+
+    /// A number from 0.0 up to but not including 1.0
+    pub fn next_f64(&mut self) -> f64 {
+        (self.next_u64() >> 11) as f64 / (1u64 << 53) as f64
+    }
+}
+
+/// Rolls each cue's variations once when it starts and keeps the outcome for as long as it
+/// runs
+pub struct VariationPicker {
+    chance: ChanceSource,
+    key: Option<FadeKey>,
+    /// Which of the cue's variations applied, by index
+    applied: Vec<usize>,
+    values: Vec<StaticValue>,
+}
+
+impl Default for VariationPicker {
+    fn default() -> Self {
+        Self::new(ChanceSource::from_time())
+    }
+}
+
+impl VariationPicker {
+    pub fn new(chance: ChanceSource) -> Self {
+        Self {
+            chance,
+            key: None,
+            applied: Vec::new(),
+            values: Vec::new(),
+        }
+    }
+
+    /// Start again from `seed`, rolling the running cue afresh
+    pub fn reseed(&mut self, seed: u64) {
+        *self = Self::new(ChanceSource::new(seed));
+    }
+
+    /// Values the cue's variations add, rolled the first time a cue start is seen
+    pub(crate) fn values(
+        &mut self,
+        key: FadeKey,
+        cue: &Cue,
+        fixtures: &[Fixture],
+    ) -> &[StaticValue] {
+        if self.key != Some(key) {
+            self.key = Some(key);
+            self.applied.clear();
+            self.values.clear();
+            for (index, variation) in cue.variations.iter().enumerate() {
+                if self.roll(variation, fixtures) {
+                    self.applied.push(index);
+                }
+            }
+        }
+        &self.values
+    }
+
+    /// Indexes of the running cue's variations that applied
+    pub fn applied(&self) -> &[usize] {
+        &self.applied
+    }
+
+    fn roll(&mut self, variation: &Variation, fixtures: &[Fixture]) -> bool {
+        let draw = self.chance.next_f64();
+        if draw >= variation.probability {
+            return false;
+        }
+        self.values.extend(variation.static_values.iter().cloned());
+
+        if let Some(rgb) = self.pick(&variation.color_choices) {
+            for fixture in fixtures
+                .iter()
+                .filter(|f| variation.fixture_ids.contains(&f.id))
+            {
+                self.values
+                    .extend(
+                        fixture
+                            .color_values(rgb)
+                            .into_iter()
+                            .map(|(channel_type, value)| StaticValue {
+                                fixture_id: fixture.id,
+                                channel_type,
+                                value,
+                            }),
+                    );
+            }
+        }
+        true
+    }
+
+    fn pick(&mut self, choices: &[WeightedColor]) -> Option<(u8, u8, u8)> {
+        let total: f64 = choices.iter().map(|c| c.weight.max(0.0)).sum();
+        if total <= 0.0 {
+            return None;
+        }
+        let mut draw = self.chance.next_f64() * total;
+        for choice in choices {
+            draw -= choice.weight.max(0.0);
+            if draw < 0.0 {
+                return Some(choice.color);
+            }
+        }
+        choices.last().map(|c| c.color)
+    }
+}
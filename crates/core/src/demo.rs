@@ -0,0 +1,256 @@
+use std::time::Duration;
+
+use halo_fixtures::{ChannelType, Fixture, FixtureLibrary};
+
+use crate::{
+    auto_patch, Chase, ChaseRate, ChaseStep, Cue, CueList, Effect, EffectDistribution,
+    EffectMapping, EffectParams, EffectRelease, EffectType, Interval, LatePolicy, PatchSpec,
+    ScheduledAction, ScheduledEvent, Show, StaticValue,
+};
+
+/// Name of the show `halo demo` plays
+pub const DEMO_SHOW_NAME: &str = "Halo Demo";
+
+/// How long the demo holds each cue before the next
+pub const DEMO_CUE_TIME: Duration = Duration::from_secs(8);
+
+const DEMO_LIST: &str = "Demo";
+const PARS: [usize; 8] = [0, 1, 2, 3, 4, 5, 6, 7];
+const MOVERS: [usize; 2] = [8, 9];
+
+/// The demo rig: eight RGBW PARs and two moving spots, all on universe 1
+pub fn demo_patch() -> Vec<PatchSpec> {
+    let pars = (1..=PARS.len()).map(|n| (format!("PAR {n}"), "shehds-rgbw-par"));
+    let movers = ["Spot SL", "Spot SR"].map(|name| (name.to_string(), "shehds-led-spot-60w"));
+    pars.chain(movers)
+        .map(|(name, profile_id)| PatchSpec {
+            name,
+            profile_id: profile_id.to_string(),
+            address: None,
+            mode: None,
+            pan_tilt_limits: None,
+        })
+        .collect()
+}
+
+/// A show for the demo rig that plays itself from the moment it loads: a warm ripple, a chase
+/// with the spots changing gobo on the beat, a rainbow and a strobe, each for
+/// [`DEMO_CUE_TIME`], then a release. After that the cues can be run by hand.
+pub fn demo_show() -> Result<Show, String> {
+    let mut show = Show::new(DEMO_SHOW_NAME.to_string());
+    show.fixtures = auto_patch(&demo_patch(), &[1], &FixtureLibrary::new())?.fixtures;
+
+    // The spots only show light with their shutter open
+    let open = show.fixtures[MOVERS[0]].profile.strobe_range().open;
+    let movers_on = values(
+        &MOVERS,
+        &[
+            (ChannelType::Dimmer, 255),
+            (ChannelType::Strobe, open),
+            (ChannelType::Pan, 128),
+            (ChannelType::Tilt, 128),
+        ],
+    );
+    let fade = Duration::from_millis(500);
+
+    let mut warm_up = Cue::ripple(
+        "Warm up",
+        &PARS,
+        &[
+            (ChannelType::Dimmer, 255),
+            (ChannelType::Red, 255),
+            (ChannelType::Green, 120),
+            (ChannelType::Blue, 0),
+            (ChannelType::White, 0),
+        ],
+        Duration::from_secs(1),
+        Duration::from_millis(100),
+    );
+    warm_up.static_values.extend(movers_on.clone());
+
+    let chase = Cue {
+        name: "Chase".to_string(),
+        fade_time: fade,
+        static_values: values(
+            &PARS,
+            &[
+                (ChannelType::Red, 0),
+                (ChannelType::Green, 0),
+                (ChannelType::Blue, 255),
+            ],
+        ),
+        effects: vec![
+            mapping(
+                "Chase",
+                &PARS,
+                ChannelType::Dimmer,
+                Effect {
+                    effect_type: EffectType::Square,
+                    params: params(Interval::Bar, 0.0),
+                    ..Effect::default()
+                },
+                EffectDistribution::Wave(1.0 / PARS.len() as f64),
+            ),
+            mapping(
+                "Pan sweep",
+                &MOVERS,
+                ChannelType::Pan,
+                Effect {
+                    min: 64,
+                    max: 192,
+                    params: params(Interval::Bar, 0.0),
+                    ..Effect::default()
+                },
+                EffectDistribution::Wave(0.5),
+            ),
+        ],
+        chase: Some(Chase {
+            steps: (1..=4)
+                .map(|gobo| ChaseStep {
+                    static_values: gobos(&show.fixtures, &MOVERS, gobo),
+                    channels: Vec::new(),
+                })
+                .collect(),
+            rate: ChaseRate::Beats(1.0),
+            ..Chase::default()
+        }),
+        ..Cue::default()
+    };
+
+    // Red, green and blue a third of a cycle apart, rolling across the row
+    let rainbow = Cue {
+        name: "Rainbow".to_string(),
+        fade_time: fade,
+        static_values: values(&PARS, &[(ChannelType::Dimmer, 255)]),
+        effects: [ChannelType::Red, ChannelType::Green, ChannelType::Blue]
+            .into_iter()
+            .enumerate()
+            .map(|(i, channel_type)| {
+                mapping(
+                    "Rainbow",
+                    &PARS,
+                    channel_type,
+                    Effect {
+                        params: params(Interval::Bar, i as f64 / 3.0),
+                        ..Effect::default()
+                    },
+                    EffectDistribution::Wave(1.0 / PARS.len() as f64),
+                )
+            })
+            .collect(),
+        ..Cue::default()
+    };
+
+    let strobe = Cue {
+        name: "Strobe".to_string(),
+        static_values: values(
+            &PARS,
+            &[
+                (ChannelType::Red, 255),
+                (ChannelType::Green, 255),
+                (ChannelType::Blue, 255),
+                (ChannelType::White, 255),
+            ],
+        ),
+        effects: vec![mapping(
+            "Strobe",
+            &PARS,
+            ChannelType::Dimmer,
+            Effect {
+                effect_type: EffectType::Square,
+                params: EffectParams {
+                    interval_ratio: 4.0,
+                    ..EffectParams::default()
+                },
+                ..Effect::default()
+            },
+            EffectDistribution::All,
+        )],
+        ..Cue::default()
+    };
+
+    let cues = vec![
+        warm_up,
+        chase,
+        rainbow,
+        strobe,
+        Cue::release_all("Release", &[], Duration::from_secs(2)),
+    ];
+    show.schedule = cues
+        .iter()
+        .enumerate()
+        .map(|(i, cue)| ScheduledEvent {
+            name: format!("Demo {}", cue.name),
+            at: DEMO_CUE_TIME * i as u32,
+            action: ScheduledAction::Goto {
+                cue_list: DEMO_LIST.to_string(),
+                cue: cue.name.clone(),
+            },
+            late: LatePolicy::default(),
+        })
+        .collect();
+    show.cue_lists = vec![CueList {
+        name: DEMO_LIST.to_string(),
+        cues,
+        audio_file: None,
+        default_fade: None,
+        default_values: vec![],
+        move_in_black: None,
+    }];
+    Ok(show)
+}
+
+fn values(fixture_ids: &[usize], values: &[(ChannelType, u8)]) -> Vec<StaticValue> {
+    fixture_ids
+        .iter()
+        .flat_map(|&fixture_id| {
+            values.iter().map(move |(channel_type, value)| StaticValue {
+                fixture_id,
+                channel_type: channel_type.clone(),
+                value: *value,
+            })
+        })
+        .collect()
+}
+
+/// Values putting gobo `gobo` in the beam of each of `fixture_ids`
+fn gobos(fixtures: &[Fixture], fixture_ids: &[usize], gobo: usize) -> Vec<StaticValue> {
+    fixture_ids
+        .iter()
+        .flat_map(|&fixture_id| {
+            fixtures[fixture_id]
+                .gobo_values(gobo, None)
+                .into_iter()
+                .map(move |(channel_type, value)| StaticValue {
+                    fixture_id,
+                    channel_type,
+                    value,
+                })
+        })
+        .collect()
+}
+
+fn params(interval: Interval, phase: f64) -> EffectParams {
+    EffectParams {
+        interval,
+        phase,
+        ..EffectParams::default()
+    }
+}
+
+fn mapping(
+    name: &str,
+    fixture_ids: &[usize],
+    channel_type: ChannelType,
+    effect: Effect,
+    distribution: EffectDistribution,
+) -> EffectMapping {
+    EffectMapping {
+        name: name.to_string(),
+        effect,
+        fixture_ids: fixture_ids.to_vec(),
+        channel_types: vec![channel_type],
+        distribution,
+        release: EffectRelease::Remove,
+    }
+}
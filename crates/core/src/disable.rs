@@ -0,0 +1,111 @@
+use std::collections::{BTreeMap, BTreeSet};
+
+use halo_fixtures::{ChannelType, Fixture};
+
+use crate::parked::ParkedChannels;
+
+/// Fixtures and universes taken out of the output at runtime, e.g. a fixture with a failing
+/// driver.
+///
+/// Every channel of a disabled fixture is parked at zero, or at the values it was putting out
+/// when it was disabled. Playback keeps running underneath, so enabling the fixture again
+/// brings it straight back to wherever the current cue has got to.
+#[derive(Clone, Default)]
+pub struct DisabledOutputs {
+    fixture_ids: BTreeSet<usize>,
+    universes: BTreeSet<u8>,
+    /// Channel values each disabled fixture is held at, in layout order
+    frozen: BTreeMap<usize, Vec<u8>>,
+    parked: ParkedChannels,
+}
+
+impl DisabledOutputs {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Take a fixture out of the output. `hold` keeps its current values instead of zeroing it.
+    pub fn disable_fixture(&mut self, fixture: &Fixture, hold: bool) {
+        self.fixture_ids.insert(fixture.id);
+        self.freeze(fixture, hold);
+    }
+
+    pub fn enable_fixture(&mut self, fixtures: &[Fixture], fixture_id: usize) {
+        self.fixture_ids.remove(&fixture_id);
+        self.thaw(fixtures);
+    }
+
+    /// Take every fixture patched in a universe out of the output
+    pub fn disable_universe(&mut self, fixtures: &[Fixture], universe: u8, hold: bool) {
+        self.universes.insert(universe);
+        for fixture in fixtures.iter().filter(|f| f.universe == universe) {
+            self.freeze(fixture, hold);
+        }
+    }
+
+    pub fn enable_universe(&mut self, fixtures: &[Fixture], universe: u8) {
+        self.universes.remove(&universe);
+        self.thaw(fixtures);
+    }
+
+    pub fn disabled_fixtures(&self) -> Vec<usize> {
+        self.fixture_ids.iter().copied().collect()
+    }
+
+    pub fn disabled_universes(&self) -> Vec<u8> {
+        self.universes.iter().copied().collect()
+    }
+
+    /// Whether a fixture is out of the output, on its own or through its universe
+    pub fn is_disabled(&self, fixture: &Fixture) -> bool {
+        self.fixture_ids.contains(&fixture.id) || self.universes.contains(&fixture.universe)
+    }
+
+    /// Remember what a fixture is held at, unless it's already held
+    fn freeze(&mut self, fixture: &Fixture, hold: bool) {
+        self.frozen.entry(fixture.id).or_insert_with(|| {
+            if hold {
+                fixture.channels.iter().map(|c| c.value).collect()
+            } else {
+                vec![0; fixture.channels.len()]
+            }
+        });
+    }
+
+    /// Forget held values for fixtures that are back in the output
+    fn thaw(&mut self, fixtures: &[Fixture]) {
+        self.frozen.retain(|id, _| {
+            fixtures.iter().find(|f| f.id == *id).is_some_and(|f| {
+                self.fixture_ids.contains(&f.id) || self.universes.contains(&f.universe)
+            })
+        });
+    }
+
+    /// Put back the values the last frame held. Call before rendering playback.
+    pub fn restore(&mut self, fixtures: &mut [Fixture]) {
+        self.parked.restore(fixtures);
+    }
+
+    /// Hold every disabled fixture at its frozen values
+    pub fn apply(&mut self, fixtures: &mut [Fixture]) {
+        for fixture in fixtures.iter_mut() {
+            if !self.is_disabled(fixture) {
+                continue;
+            }
+            // Fixtures patched into a disabled universe after it was disabled go dark
+            let values = self
+                .frozen
+                .entry(fixture.id)
+                .or_insert_with(|| vec![0; fixture.channels.len()])
+                .clone();
+            let channel_types: Vec<ChannelType> = fixture
+                .channels
+                .iter()
+                .map(|c| c.channel_type.clone())
+                .collect();
+            for (channel_type, value) in channel_types.iter().zip(values) {
+                self.parked.park(fixture, channel_type, value);
+            }
+        }
+    }
+}
@@ -0,0 +1,48 @@
+use halo_fixtures::{Fixture, FixtureType};
+use serde::{Deserialize, Serialize};
+
+use crate::StaticValue;
+
+/// A universe's worth of DMX mapped back through the patch
+#[derive(Clone, Debug, Default, PartialEq, Serialize, Deserialize)]
+pub struct DmxImport {
+    /// Every patched channel's level, by fixture and channel type
+    pub values: Vec<StaticValue>,
+    /// Addresses, counting from 1, that had a level but no patched channel to put it on
+    pub skipped: Vec<u16>,
+}
+
+/// Map a universe's DMX back onto the fixtures patched in it, the reverse of rendering.
+///
+/// Each fixture takes its channels from its start address in profile order. Pixel fixtures
+/// are left out, as their channels come from the pixel engine rather than channel values, and
+/// so are channels past the end of `data`.
+pub fn import_universe(fixtures: &[Fixture], universe: u8, data: &[u8]) -> DmxImport {
+    let data = &data[..data.len().min(512)];
+    let mut covered = vec![false; data.len()];
+    let mut values = Vec::new();
+    for fixture in fixtures
+        .iter()
+        .filter(|f| f.universe == universe && f.profile.fixture_type != FixtureType::PixelBar)
+    {
+        let start = fixture.start_address.saturating_sub(1) as usize;
+        let read = fixture.values_from_dmx(data);
+        for flag in covered.iter_mut().skip(start).take(read.len()) {
+            *flag = true;
+        }
+        values.extend(read.into_iter().map(|(channel_type, value)| StaticValue {
+            fixture_id: fixture.id,
+            channel_type,
+            value,
+        }));
+    }
+
+    let skipped = data
+        .iter()
+        .zip(&covered)
+        .enumerate()
+        .filter(|(_, (value, covered))| **value > 0 && !**covered)
+        .map(|(address, _)| address as u16 + 1)
+        .collect();
+    DmxImport { values, skipped }
+}
@@ -0,0 +1,154 @@
+use std::fmt;
+use std::str::FromStr;
+use std::time::Duration;
+
+use serde::{Deserialize, Deserializer, Serialize, Serializer};
+
+use crate::{Interval, Meter};
+
+/// A length of time written in a show file, either absolute ("500ms", "2s") or musical
+/// ("4b", "2bar", "1phrase"). Musical lengths are worked out against the tempo each time
+/// they're used, so they rescale when the tempo changes.
+#[derive(Clone, Copy, Debug, PartialEq)]
+pub enum MusicalDuration {
+    Absolute(Duration),
+    Beats(f64),
+    Bars(f64),
+    Phrases(f64),
+}
+
+impl MusicalDuration {
+    /// How long this is at `meter`'s tempo
+    pub fn resolve(&self, meter: &Meter) -> Duration {
+        match self {
+            MusicalDuration::Absolute(duration) => *duration,
+            MusicalDuration::Beats(beats) => meter.duration_of(*beats),
+            MusicalDuration::Bars(bars) => meter.duration_of(bars * meter.beats_in(&Interval::Bar)),
+            MusicalDuration::Phrases(phrases) => {
+                meter.duration_of(phrases * meter.beats_in(&Interval::Phrase))
+            }
+        }
+    }
+
+    /// Whether the length follows the tempo
+    pub fn is_musical(&self) -> bool {
+        !matches!(self, MusicalDuration::Absolute(_))
+    }
+}
+
+impl From<Duration> for MusicalDuration {
+    fn from(duration: Duration) -> Self {
+        MusicalDuration::Absolute(duration)
+    }
+}
+
+impl FromStr for MusicalDuration {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        let text = s.trim();
+        let split = text
+            .find(|c: char| !(c.is_ascii_digit() || c == '.'))
+            .unwrap_or(text.len());
+        let (number, unit) = text.split_at(split);
+        if number.is_empty() {
+            return Err(format!("'{s}' doesn't start with a number"));
+        }
+        let count: f64 = number
+            .parse()
+            .map_err(|_| format!("'{number}' in '{s}' isn't a number"))?;
+
+        match unit.trim().to_ascii_lowercase().as_str() {
+            "" => Err(format!("'{s}' has no unit, add ms, s, b, bar or phrase")),
+            "ms" => Ok(MusicalDuration::Absolute(Duration::from_secs_f64(
+                count / 1000.0,
+            ))),
+            "s" | "sec" | "secs" => Ok(MusicalDuration::Absolute(Duration::from_secs_f64(count))),
+            "min" | "mins" => Ok(MusicalDuration::Absolute(Duration::from_secs_f64(
+                count * 60.0,
+            ))),
+            "b" | "beat" | "beats" => Ok(MusicalDuration::Beats(count)),
+            "bar" | "bars" => Ok(MusicalDuration::Bars(count)),
+            "phrase" | "phrases" => Ok(MusicalDuration::Phrases(count)),
+            // Minutes or measures
+            "m" => Err(format!(
+                "'{s}' is ambiguous, use min for minutes or bar for bars"
+            )),
+            unit => Err(format!(
+                "Unknown unit '{unit}' in '{s}', expected ms, s, min, b, bar or phrase"
+            )),
+        }
+    }
+}
+
+impl fmt::Display for MusicalDuration {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        match self {
+            MusicalDuration::Absolute(duration) if duration.subsec_millis() == 0 => {
+                write!(f, "{}s", duration.as_secs_f64())
+            }
+            MusicalDuration::Absolute(duration) => {
+                write!(f, "{}ms", duration.as_secs_f64() * 1000.0)
+            }
+            MusicalDuration::Beats(beats) => write!(f, "{beats}b"),
+            MusicalDuration::Bars(bars) => write!(f, "{bars}bar"),
+            MusicalDuration::Phrases(phrases) => write!(f, "{phrases}phrase"),
+        }
+    }
+}
+
+impl Serialize for MusicalDuration {
+    fn serialize<S: Serializer>(&self, serializer: S) -> Result<S::Ok, S::Error> {
+        serializer.serialize_str(&self.to_string())
+    }
+}
+
+/// A duration as show files write it: a string with units, or the `{"secs", "nanos"}` object
+/// older files have
+#[derive(Deserialize)]
+#[serde(untagged)]
+enum Written {
+    Text(String),
+    Object(Duration),
+}
+
+impl<'de> Deserialize<'de> for MusicalDuration {
+    fn deserialize<D: Deserializer<'de>>(deserializer: D) -> Result<Self, D::Error> {
+        match Written::deserialize(deserializer)? {
+            Written::Text(text) => text.parse().map_err(serde::de::Error::custom),
+            Written::Object(duration) => Ok(MusicalDuration::Absolute(duration)),
+        }
+    }
+}
+
+/// For `Duration` fields in show files, so they can be written as "500ms" or "2s". Musical
+/// lengths are refused here, as these fields are fixed when the show loads.
+pub(crate) mod absolute {
+    use std::time::Duration;
+
+    use serde::{Deserialize, Deserializer};
+
+    use super::MusicalDuration;
+
+    fn fixed<E: serde::de::Error>(duration: MusicalDuration) -> Result<Duration, E> {
+        match duration {
+            MusicalDuration::Absolute(duration) => Ok(duration),
+            musical => Err(E::custom(format!(
+                "'{musical}' follows the tempo, which only a cue's tempo_fade can. \
+                 Use a time such as 500ms or 2s here."
+            ))),
+        }
+    }
+
+    pub fn deserialize<'de, D: Deserializer<'de>>(deserializer: D) -> Result<Duration, D::Error> {
+        fixed(MusicalDuration::deserialize(deserializer)?)
+    }
+
+    pub fn deserialize_option<'de, D: Deserializer<'de>>(
+        deserializer: D,
+    ) -> Result<Option<Duration>, D::Error> {
+        Option::<MusicalDuration>::deserialize(deserializer)?
+            .map(fixed)
+            .transpose()
+    }
+}
@@ -31,6 +31,9 @@ pub struct Effect {
     pub frequency: f32,
     pub offset: f32,
     pub params: EffectParams,
+    /// Registered effect to run in place of the built-in `effect_type`, by name
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub source: Option<String>,
     // pub value: f64,
     // pub loop: bool,
     // pub paused: bool,
@@ -44,17 +47,18 @@ impl Effect {
             EffectType::Sine => sine_effect,
             EffectType::Square => square_effect,
             EffectType::Sawtooth => sawtooth_effect,
-            EffectType::Triangle => |phase| {
-                if phase < 0.5 {
-                    phase * 2.0
-                } else {
-                    2.0 - phase * 2.0
-                }
-            },
+            EffectType::Triangle => triangle_effect,
             _ => sine_effect, // Default
         };
         (apply_fn)(phase)
     }
+
+    /// The name the effect's source is registered under
+    pub fn source_name(&self) -> &str {
+        self.source
+            .as_deref()
+            .unwrap_or_else(|| self.effect_type.as_str())
+    }
 }
 
 impl Default for Effect {
@@ -67,6 +71,7 @@ impl Default for Effect {
             frequency: 1.0,
             offset: 0.0,
             params: EffectParams::default(),
+            source: None,
         }
     }
 }
@@ -137,3 +142,11 @@ pub fn square_effect(phase: f64) -> f64 {
 pub fn sawtooth_effect(phase: f64) -> f64 {
     phase
 }
+
+pub fn triangle_effect(phase: f64) -> f64 {
+    if phase < 0.5 {
+        phase * 2.0
+    } else {
+        2.0 - phase * 2.0
+    }
+}
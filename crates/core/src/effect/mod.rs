@@ -1,3 +1,5 @@
 pub(crate) mod effect;
+pub(crate) mod player;
+pub(crate) mod source;
 
 pub use effect::EffectRelease;
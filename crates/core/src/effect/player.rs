@@ -0,0 +1,301 @@
+use std::collections::{HashMap, HashSet};
+
+use halo_fixtures::{ChannelType, Fixture};
+use serde::{Deserialize, Serialize};
+
+use super::source::{EffectContext, EffectRegistry, EffectSource};
+use crate::cue::fade::{Attribute, FadeKey};
+use crate::{EffectMapping, RhythmState};
+
+/// A color to hold an effect at, as `[r, g, b]` or the name of one of the show's palettes
+#[derive(Clone, Debug, PartialEq, Serialize, Deserialize)]
+#[serde(untagged)]
+pub enum OverrideColor {
+    Rgb(u8, u8, u8),
+    Palette(String),
+}
+
+/// A cue action that holds a running effect at one color, or releases it with no color
+#[derive(Clone, Debug, PartialEq, Serialize, Deserialize)]
+pub struct ColorOverride {
+    /// Name of the effect mapping
+    pub effect: String,
+    #[serde(default)]
+    pub color: Option<OverrideColor>,
+}
+
+/// Runs effect mappings through their sources, starting a source when its mapping first
+/// appears and stopping it when the mapping goes
+pub struct EffectPlayer {
+    registry: EffectRegistry,
+    running: HashMap<String, (String, Box<dyn EffectSource>)>,
+    unknown: HashSet<String>,
+    color_overrides: HashMap<String, OverrideColor>,
+    palettes: HashMap<String, (u8, u8, u8)>,
+    // The cue whose overrides were applied last, so each cue applies them once
+    override_cue: Option<FadeKey>,
+    // What each mapping wrote last frame: mapping, fixture, channel and value
+    rendered: Vec<(String, usize, ChannelType, u8)>,
+    // Freezes in force, each from a call to freeze_all that hasn't been resumed
+    freezes: usize,
+    // Where in the phrase, in beats, each mapping is held while frozen
+    held: HashMap<String, f64>,
+    // Beats each mapping runs behind the rhythm, from the time it spent frozen
+    lag: HashMap<String, f64>,
+}
+
+impl Default for EffectPlayer {
+    fn default() -> Self {
+        Self::new(EffectRegistry::new())
+    }
+}
+
+impl EffectPlayer {
+    pub fn new(registry: EffectRegistry) -> Self {
+        Self {
+            registry,
+            running: HashMap::new(),
+            unknown: HashSet::new(),
+            color_overrides: HashMap::new(),
+            palettes: HashMap::new(),
+            override_cue: None,
+            rendered: Vec::new(),
+            freezes: 0,
+            held: HashMap::new(),
+            lag: HashMap::new(),
+        }
+    }
+
+    pub fn registry(&self) -> &EffectRegistry {
+        &self.registry
+    }
+
+    /// Swap the registry, restarting every running effect from it
+    pub fn set_registry(&mut self, registry: EffectRegistry) {
+        self.stop_all();
+        self.registry = registry;
+        self.unknown.clear();
+    }
+
+    /// Named colors that overrides can use in place of RGB
+    pub fn set_palettes(&mut self, palettes: HashMap<String, (u8, u8, u8)>) {
+        self.palettes = palettes;
+    }
+
+    pub fn palettes(&self) -> &HashMap<String, (u8, u8, u8)> {
+        &self.palettes
+    }
+
+    /// Hold an effect's color channels at `color` while its other channels keep running. The
+    /// override is dropped when the effect stops.
+    pub fn set_color_override(&mut self, effect: &str, color: OverrideColor) -> Result<(), String> {
+        if let OverrideColor::Palette(name) = &color {
+            if !self.palettes.contains_key(name) {
+                return Err(format!("No palette named '{name}'"));
+            }
+        }
+        self.color_overrides.insert(effect.to_string(), color);
+        Ok(())
+    }
+
+    pub fn clear_color_override(&mut self, effect: &str) {
+        self.color_overrides.remove(effect);
+    }
+
+    pub fn color_override(&self, effect: &str) -> Option<&OverrideColor> {
+        self.color_overrides.get(effect)
+    }
+
+    /// Apply a cue's color overrides, once when it starts
+    pub(crate) fn cue_started(&mut self, key: FadeKey, overrides: &[ColorOverride]) {
+        if self.override_cue == Some(key) {
+            return;
+        }
+        self.override_cue = Some(key);
+        for color_override in overrides {
+            match &color_override.color {
+                Some(color) => {
+                    if let Err(e) = self.set_color_override(&color_override.effect, color.clone()) {
+                        log::warn!("Color override for {}: {e}", color_override.effect);
+                    }
+                }
+                None => self.clear_color_override(&color_override.effect),
+            }
+        }
+    }
+
+    /// Stop every running effect's phase from advancing while it keeps rendering where it is,
+    /// e.g. under a blinder hit, so the effects carry on from the same place afterwards.
+    /// Freezes nest: effects run again once each freeze has been resumed.
+    pub fn freeze_all(&mut self) {
+        self.freezes += 1;
+    }
+
+    /// Release one [`freeze_all`](Self::freeze_all)
+    pub fn resume_all(&mut self) {
+        self.freezes = self.freezes.saturating_sub(1);
+    }
+
+    pub fn is_frozen(&self) -> bool {
+        self.freezes > 0
+    }
+
+    /// The rhythm a mapping plays to: held while frozen, then behind the live rhythm by
+    /// however long it was held
+    fn rhythm_after_freezes(&mut self, name: &str, live: RhythmState) -> RhythmState {
+        if self.freezes == 0 && !self.held.contains_key(name) && !self.lag.contains_key(name) {
+            return live;
+        }
+
+        // Every phase repeats each phrase, so the time in the phrase is all that matters
+        let phrase = (live.beats_per_bar * live.bars_per_phrase).max(1) as f64;
+        let now = live.phrase_phase * phrase;
+        let lag = self.lag.get(name).copied().unwrap_or(0.0);
+        let beat = if self.freezes > 0 {
+            *self
+                .held
+                .entry(name.to_string())
+                .or_insert_with(|| (now - lag).rem_euclid(phrase))
+        } else {
+            match self.held.remove(name) {
+                Some(held) => {
+                    self.lag
+                        .insert(name.to_string(), (now - held).rem_euclid(phrase));
+                    held
+                }
+                None => (now - lag).rem_euclid(phrase),
+            }
+        };
+
+        let mut rhythm = live;
+        rhythm.set_beat_time(beat);
+        rhythm
+    }
+
+    /// What each mapping wrote last frame, in the order it was written: mapping name, fixture
+    /// ID, channel and value
+    pub fn rendered(&self) -> &[(String, usize, ChannelType, u8)] {
+        &self.rendered
+    }
+
+    /// Write this frame's effect values over the fixtures, each mapping following the rhythm
+    /// `rhythm_for` gives it
+    pub fn render(
+        &mut self,
+        mappings: &[EffectMapping],
+        rhythm_for: impl Fn(&EffectMapping) -> RhythmState,
+        fixtures: &mut [Fixture],
+    ) {
+        self.rendered.clear();
+        let live: HashSet<&str> = mappings.iter().map(|m| m.name.as_str()).collect();
+        let stopped: Vec<String> = self
+            .running
+            .keys()
+            .filter(|name| !live.contains(name.as_str()))
+            .cloned()
+            .collect();
+        for name in stopped {
+            if let Some((_, mut source)) = self.running.remove(&name) {
+                source.stop();
+            }
+            self.color_overrides.remove(&name);
+            self.held.remove(&name);
+            self.lag.remove(&name);
+        }
+
+        for mapping in mappings {
+            let rhythm = self.rhythm_after_freezes(&mapping.name, rhythm_for(mapping));
+            let Some(source) = self.source_for(mapping) else {
+                continue;
+            };
+            let context = EffectContext {
+                effect: &mapping.effect,
+                distribution: &mapping.distribution,
+                rhythm: &rhythm,
+            };
+            let values = source.values(&context, &mapping.fixture_ids);
+            let color = match self.color_overrides.get(&mapping.name) {
+                Some(OverrideColor::Rgb(red, green, blue)) => Some((*red, *green, *blue)),
+                Some(OverrideColor::Palette(name)) => self.palettes.get(name).copied(),
+                None => None,
+            };
+
+            let (min, max) = (mapping.effect.min, mapping.effect.max);
+            for (fixture_id, value) in mapping.fixture_ids.iter().zip(values) {
+                let value = value.clamp(0.0, 1.0);
+                if let Some(fixture) = fixtures.iter_mut().find(|f| f.id == *fixture_id) {
+                    // Overridden color channels take the fixture's mix of the color instead
+                    let colors = color.map(|rgb| fixture.color_values(rgb));
+                    for channel_type in &mapping.channel_types {
+                        let value = match &colors {
+                            Some(colors) if Attribute::of(channel_type) == Attribute::Color => {
+                                colors
+                                    .iter()
+                                    .find(|(c, _)| c == channel_type)
+                                    .map_or(0, |(_, v)| *v)
+                            }
+                            // Movement sweeps the part of its range the fixture's limits
+                            // allow, so it never sits against them
+                            _ => {
+                                let (min, max) = fixture.limited_range(channel_type, min, max);
+                                (min as f64 + (max as f64 - min as f64) * value) as u8
+                            }
+                        };
+                        fixture.set_channel_value(channel_type, value);
+                        self.rendered.push((
+                            mapping.name.clone(),
+                            *fixture_id,
+                            channel_type.clone(),
+                            value,
+                        ));
+                    }
+                }
+            }
+        }
+    }
+
+    /// The running source for a mapping, started fresh if the mapping is new or now names
+    /// a different effect
+    fn source_for(&mut self, mapping: &EffectMapping) -> Option<&mut Box<dyn EffectSource>> {
+        let name = mapping.effect.source_name();
+        let current = self
+            .running
+            .get(&mapping.name)
+            .is_some_and(|(running, _)| running == name);
+        if !current {
+            if let Some((_, mut source)) = self.running.remove(&mapping.name) {
+                source.stop();
+            }
+            let Some(mut source) = self.registry.create(&mapping.effect) else {
+                if self.unknown.insert(name.to_string()) {
+                    log::warn!(
+                        "Effect {} uses '{name}', which isn't a registered effect",
+                        mapping.name
+                    );
+                }
+                return None;
+            };
+            source.start();
+            self.running
+                .insert(mapping.name.clone(), (name.to_string(), source));
+        }
+        self.running
+            .get_mut(&mapping.name)
+            .map(|(_, source)| source)
+    }
+
+    /// Stop every running effect and drop its color override, e.g. when the show changes
+    pub(crate) fn stop_effects(&mut self) {
+        self.stop_all();
+        self.color_overrides.clear();
+        self.override_cue = None;
+    }
+
+    fn stop_all(&mut self) {
+        for (_, (_, mut source)) in self.running.drain() {
+            source.stop();
+        }
+        self.held.clear();
+        self.lag.clear();
+    }
+}
@@ -0,0 +1,136 @@
+//! Effect sources: what an effect computes each frame, for the built-in waveforms and for
+//! effects registered from outside halo alike.
+
+use std::collections::HashMap;
+use std::sync::Arc;
+
+use super::effect::{
+    get_effect_phase, sawtooth_effect, sine_effect, square_effect, triangle_effect,
+};
+use crate::{Effect, EffectDistribution, EffectType, RhythmState};
+
+/// What a source gets to work with each frame
+pub struct EffectContext<'a> {
+    pub effect: &'a Effect,
+    pub distribution: &'a EffectDistribution,
+    pub rhythm: &'a RhythmState,
+}
+
+impl EffectContext<'_> {
+    /// The effect's phase from 0.0 to 1.0, following its interval, ratio and phase offset
+    pub fn phase(&self) -> f64 {
+        get_effect_phase(self.rhythm, &self.effect.params)
+    }
+
+    /// The phase for the fixture at `index` once the distribution has spread it
+    pub fn phase_for(&self, index: usize) -> f64 {
+        let phase = self.phase();
+        match self.distribution {
+            EffectDistribution::All => phase,
+            EffectDistribution::Step(step) => (phase + (index / (*step).max(1)) as f64) % 1.0,
+            EffectDistribution::Wave(offset) => (phase + index as f64 * offset) % 1.0,
+        }
+    }
+}
+
+/// An effect's output over time, built in or registered with [`EffectRegistry::register`].
+///
+/// A source is created from the effect's settings when a cue starts the effect and dropped
+/// when the effect stops, with `start` and `stop` called either side. While the effect runs,
+/// `values` is called once per console frame (around 40 times a second), always from the
+/// console's update task. Sources must be `Send + Sync` to live in the console, but are never
+/// called from two threads at once.
+///
+/// Values are from 0.0 to 1.0, one for each fixture the effect is mapped to, in order. The
+/// console scales them to the effect's `min` and `max` and writes them to the mapped
+/// channels. Out of range values are clamped and missing ones leave the fixture alone.
+pub trait EffectSource: Send + Sync {
+    /// Called once before the first `values`
+    fn start(&mut self) {}
+
+    /// Values from 0.0 to 1.0 for each of `fixture_ids`, in order
+    fn values(&mut self, context: &EffectContext, fixture_ids: &[usize]) -> Vec<f64>;
+
+    /// Called once when the effect stops running
+    fn stop(&mut self) {}
+}
+
+/// Makes a source for an effect each time a cue starts it
+pub type EffectFactory = Arc<dyn Fn(&Effect) -> Box<dyn EffectSource> + Send + Sync>;
+
+/// The effects show files can name, built in and registered
+#[derive(Clone)]
+pub struct EffectRegistry {
+    factories: HashMap<String, EffectFactory>,
+}
+
+impl Default for EffectRegistry {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+impl EffectRegistry {
+    /// A registry with the built-in waveforms, under their effect type names
+    pub fn new() -> Self {
+        let mut registry = Self {
+            factories: HashMap::new(),
+        };
+        for effect_type in [
+            EffectType::Sine,
+            EffectType::Sawtooth,
+            EffectType::Square,
+            EffectType::Triangle,
+            EffectType::Pulse,
+            EffectType::Random,
+        ] {
+            registry.register(effect_type.as_str(), move |_| {
+                Box::new(Waveform(effect_type))
+            });
+        }
+        registry
+    }
+
+    /// Make an effect available to show files as `name`, replacing any effect already
+    /// registered under it. Names match without regard to case.
+    pub fn register(
+        &mut self,
+        name: &str,
+        factory: impl Fn(&Effect) -> Box<dyn EffectSource> + Send + Sync + 'static,
+    ) {
+        self.factories
+            .insert(name.to_ascii_lowercase(), Arc::new(factory));
+    }
+
+    /// A new source for an effect, or `None` if nothing is registered under its name
+    pub fn create(&self, effect: &Effect) -> Option<Box<dyn EffectSource>> {
+        let factory = self
+            .factories
+            .get(&effect.source_name().to_ascii_lowercase())?;
+        Some(factory(effect))
+    }
+
+    /// Every registered name, sorted
+    pub fn names(&self) -> Vec<String> {
+        let mut names: Vec<String> = self.factories.keys().cloned().collect();
+        names.sort();
+        names
+    }
+}
+
+/// The built-in waveforms
+struct Waveform(EffectType);
+
+impl EffectSource for Waveform {
+    fn values(&mut self, context: &EffectContext, fixture_ids: &[usize]) -> Vec<f64> {
+        let wave = match self.0 {
+            EffectType::Square => square_effect,
+            EffectType::Sawtooth => sawtooth_effect,
+            EffectType::Triangle => triangle_effect,
+            _ => sine_effect,
+        };
+        (0..fixture_ids.len())
+            .map(|index| wave(context.phase_for(index)))
+            .collect()
+    }
+}
@@ -0,0 +1,335 @@
+use std::path::PathBuf;
+use std::sync::Arc;
+use std::time::Duration;
+
+use async_trait::async_trait;
+use tokio::sync::mpsc;
+use tokio::task::JoinHandle;
+
+use crate::lifecycle::{Lifecycle, Stage};
+use crate::realtime::spawn_pinned;
+use crate::{
+    follow_primary, serve_standby, AsyncModule, ConsoleCommand, ConsoleEvent, DmxModule,
+    EffectRegistry, LightingConsole, MirrorState, NetworkConfig, NullDmxModule, OutputCapture,
+    OutputStats, Redundancy, ResumeState, SacnConfig, Settings, TickHistogram, SAFE_MODE_REQUESTED,
+};
+
+/// How to bring up a console with [`Engine::start`]
+#[derive(Clone)]
+pub struct EngineOptions {
+    /// Starting tempo for effects and the rhythm engine
+    pub bpm: f64,
+    pub network_config: NetworkConfig,
+    pub settings: Settings,
+    /// Where to keep saving playback state as the show runs, for a restart to pick up from
+    pub resume_file: Option<PathBuf>,
+    /// Saved state to carry on from once a show is loaded
+    pub resume: Option<ResumeState>,
+    /// Where to keep fixture on time and pan/tilt travel across runs
+    pub stats_file: Option<PathBuf>,
+    /// A directory of fixture profile files to add to the built-in library
+    pub profiles: Option<PathBuf>,
+    /// Where to record the output frame by frame, for `halo inspect`
+    pub record: Option<PathBuf>,
+    /// Where to capture exactly what the DMX output sends, from the start
+    pub capture: Option<PathBuf>,
+    /// The effects show files can name, the built-ins plus any registered by the host program
+    pub effects: EffectRegistry,
+    /// Seed for cue variations, for a run that can be repeated exactly. Seeded from the clock
+    /// when `None`.
+    pub seed: Option<u64>,
+    /// Discard DMX output instead of sending it over Art-Net, e.g. to try halo without a rig
+    pub null_output: bool,
+    /// How to send sACN when `settings.output` picks it. Multicasts from any interface as
+    /// "halo" when `None`.
+    pub sacn: Option<SacnConfig>,
+    /// Start without a show, with only the patch, programmer and manual sources
+    pub safe_mode: bool,
+    /// Run as the primary or the standby of a redundant pair
+    pub redundancy: Option<Redundancy>,
+}
+
+impl EngineOptions {
+    pub fn new(network_config: NetworkConfig) -> Self {
+        Self {
+            bpm: 80.0,
+            network_config,
+            settings: Settings::default(),
+            resume_file: None,
+            resume: None,
+            stats_file: None,
+            profiles: None,
+            record: None,
+            capture: None,
+            effects: EffectRegistry::new(),
+            seed: None,
+            null_output: false,
+            sacn: None,
+            safe_mode: false,
+            redundancy: None,
+        }
+    }
+}
+
+/// How long the console gets to come up and to stop, saving its state on the way out
+const CONSOLE_TIMEOUT: Duration = Duration::from_secs(5);
+
+/// How long the link to a standby or primary gets to come up and to stop
+const REDUNDANCY_TIMEOUT: Duration = Duration::from_secs(1);
+
+/// A console running on its own task, driven by [`ConsoleCommand`]s and reporting back with
+/// [`ConsoleEvent`]s.
+///
+/// This is the bootstrap the `halo` binary runs before handing over to the UI, for programs
+/// that drive a rig with halo as a library. The console and the link to a redundant machine
+/// are [`Lifecycle`] stages, so they come up in order and shut down in reverse, with a
+/// timeout on each.
+///
+/// With `low_latency` set, the console's render loop and the DMX module each get an OS
+/// thread of their own, at `thread_niceness` if set.
+pub struct Engine {
+    commands: mpsc::UnboundedSender<ConsoleCommand>,
+    events: Option<mpsc::UnboundedReceiver<ConsoleEvent>>,
+    lifecycle: Lifecycle,
+    render_ticks: Arc<TickHistogram>,
+    output_stats: Option<Arc<OutputStats>>,
+}
+
+impl Engine {
+    /// Start the console and initialize its modules. Must be called within a Tokio runtime.
+    pub async fn start(options: EngineOptions) -> Result<Self, anyhow::Error> {
+        let (command_tx, command_rx) = mpsc::unbounded_channel::<ConsoleCommand>();
+        let (event_tx, event_rx) = mpsc::unbounded_channel::<ConsoleEvent>();
+
+        let capture = OutputCapture::new();
+        let pinned = options
+            .settings
+            .low_latency
+            .then_some(options.settings.thread_niceness);
+        let mut output_stats = None;
+        let mut console = if options.null_output {
+            let modules: Vec<Box<dyn AsyncModule>> = vec![Box::new(NullDmxModule::new())];
+            LightingConsole::new_with_modules(options.bpm, options.settings, modules)?
+        } else {
+            let driver = options
+                .settings
+                .output
+                .driver(options.network_config, options.sacn);
+            let mut dmx = DmxModule::with_driver(driver);
+            dmx.set_keep_alive(options.settings.dmx_keep_alive());
+            dmx.set_capture(capture.clone());
+            dmx.set_target_fps(options.settings.dmx_rate_hz.into());
+            for (universe, fps) in &options.settings.universe_rate_hz {
+                dmx.set_universe_fps(*universe, (*fps).into());
+            }
+            output_stats = Some(dmx.stats());
+            LightingConsole::new_with_output(options.bpm, Box::new(dmx), options.settings)?
+        };
+        if let Some(niceness) = pinned {
+            console.pin_output(niceness);
+        }
+        let render_ticks = console.render_ticks();
+        if let Some(dir) = &options.profiles {
+            console.load_profiles(dir);
+        }
+        console.set_effect_registry(options.effects).await;
+        if let Some(seed) = options.seed {
+            console.set_seed(seed).await;
+        }
+        console
+            .record_dmx(options.record.as_deref())
+            .await
+            .map_err(|e| anyhow::anyhow!(e))?;
+        console.set_output_capture(capture);
+        console
+            .capture_output(options.capture.as_deref())
+            .map_err(|e| anyhow::anyhow!(e))?;
+        console.set_resume_file(options.resume_file).await;
+        if let Some(state) = options.resume {
+            console.resume_from(state).await;
+        }
+        console.set_stats_file(options.stats_file).await;
+        if options.safe_mode {
+            console
+                .enter_safe_mode(SAFE_MODE_REQUESTED.to_string())
+                .await;
+        }
+        let link = options.redundancy.map(|redundancy| {
+            let mirror = match redundancy {
+                Redundancy::Primary(_) => {
+                    let (mirror_tx, mirror_rx) = mpsc::unbounded_channel();
+                    console.set_mirror(Some(mirror_tx));
+                    Some(mirror_rx)
+                }
+                Redundancy::Standby(_) => {
+                    console.enter_standby();
+                    None
+                }
+            };
+            RedundancyStage {
+                redundancy,
+                mirror,
+                commands: command_tx.clone(),
+                task: None,
+            }
+        });
+
+        let mut lifecycle = Lifecycle::new();
+        lifecycle.add(
+            ConsoleStage {
+                console: Some((console, command_rx, event_tx)),
+                commands: command_tx.clone(),
+                pinned,
+                task: None,
+            },
+            CONSOLE_TIMEOUT,
+        );
+        if let Some(link) = link {
+            lifecycle.add(link, REDUNDANCY_TIMEOUT);
+        }
+        lifecycle.start().await?;
+
+        Ok(Self {
+            commands: command_tx,
+            events: Some(event_rx),
+            lifecycle,
+            render_ticks,
+            output_stats,
+        })
+    }
+
+    pub fn send(&self, command: ConsoleCommand) -> Result<(), anyhow::Error> {
+        self.commands
+            .send(command)
+            .map_err(|e| anyhow::anyhow!("Failed to send command: {}", e))
+    }
+
+    /// A sender for handing to a UI or other task that issues commands
+    pub fn commands(&self) -> mpsc::UnboundedSender<ConsoleCommand> {
+        self.commands.clone()
+    }
+
+    /// Take the receiver for console events. Only the first call gets it.
+    pub fn take_events(&mut self) -> Option<mpsc::UnboundedReceiver<ConsoleEvent>> {
+        self.events.take()
+    }
+
+    /// How long the console's update ticks take, for checking a machine keeps up
+    pub fn render_ticks(&self) -> Arc<TickHistogram> {
+        Arc::clone(&self.render_ticks)
+    }
+
+    /// What the DMX module has sent and how long its ticks take. `None` when output is
+    /// discarded.
+    pub fn output_stats(&self) -> Option<Arc<OutputStats>> {
+        self.output_stats.clone()
+    }
+
+    /// Stop the link to a redundant machine, then the console, waiting for each to finish
+    pub async fn shutdown(mut self) -> Result<(), anyhow::Error> {
+        self.lifecycle.stop().await?;
+        Ok(())
+    }
+}
+
+/// The console's own task, initialized on start and told to shut down on stop
+struct ConsoleStage {
+    /// The console and its channels, until the task takes them
+    console: Option<(
+        LightingConsole,
+        mpsc::UnboundedReceiver<ConsoleCommand>,
+        mpsc::UnboundedSender<ConsoleEvent>,
+    )>,
+    commands: mpsc::UnboundedSender<ConsoleCommand>,
+    /// The niceness to run the console on a thread of its own at, if it gets one
+    pinned: Option<Option<i32>>,
+    task: Option<JoinHandle<()>>,
+}
+
+#[async_trait]
+impl Stage for ConsoleStage {
+    fn name(&self) -> &str {
+        "console"
+    }
+
+    async fn start(&mut self) -> Result<(), anyhow::Error> {
+        let (mut console, command_rx, event_tx) = self
+            .console
+            .take()
+            .ok_or_else(|| anyhow::anyhow!("The console has already run"))?;
+        let run = async move {
+            if let Err(e) = console.run_with_channels(command_rx, event_tx).await {
+                log::error!("Console error: {}", e);
+            }
+        };
+        self.task = Some(match self.pinned {
+            Some(niceness) => spawn_pinned("halo-render", niceness, run)?,
+            None => tokio::spawn(run),
+        });
+        self.commands
+            .send(ConsoleCommand::Initialize)
+            .map_err(|e| anyhow::anyhow!("Failed to send command: {}", e))?;
+
+        // Allow time for the modules to come up before the first commands arrive
+        tokio::time::sleep(Duration::from_millis(100)).await;
+        Ok(())
+    }
+
+    async fn stop(&mut self) -> Result<(), anyhow::Error> {
+        let Some(task) = self.task.as_mut() else {
+            return Ok(());
+        };
+        // The console may have stopped on its own, in which case there's only the task to wait on
+        let _ = self.commands.send(ConsoleCommand::Shutdown);
+        let result = task
+            .await
+            .map_err(|e| anyhow::anyhow!("Console task failed: {}", e));
+        self.task = None;
+        result
+    }
+}
+
+/// Streaming to a standby, or following a primary
+struct RedundancyStage {
+    redundancy: Redundancy,
+    /// The console's states for a standby, until the task takes them
+    mirror: Option<mpsc::UnboundedReceiver<MirrorState>>,
+    commands: mpsc::UnboundedSender<ConsoleCommand>,
+    task: Option<JoinHandle<()>>,
+}
+
+#[async_trait]
+impl Stage for RedundancyStage {
+    fn name(&self) -> &str {
+        match self.redundancy {
+            Redundancy::Primary(_) => "standby link",
+            Redundancy::Standby(_) => "primary link",
+        }
+    }
+
+    async fn start(&mut self) -> Result<(), anyhow::Error> {
+        self.task = Some(match self.redundancy {
+            Redundancy::Primary(addr) => {
+                let mirror_rx = self
+                    .mirror
+                    .take()
+                    .ok_or_else(|| anyhow::anyhow!("The standby link has already run"))?;
+                tokio::spawn(async move {
+                    if let Err(e) = serve_standby(addr, mirror_rx).await {
+                        log::error!("Couldn't serve a standby on {addr}: {e}");
+                    }
+                })
+            }
+            Redundancy::Standby(addr) => tokio::spawn(follow_primary(addr, self.commands.clone())),
+        });
+        Ok(())
+    }
+
+    async fn stop(&mut self) -> Result<(), anyhow::Error> {
+        if let Some(task) = self.task.take() {
+            task.abort();
+            let _ = task.await;
+        }
+        Ok(())
+    }
+}
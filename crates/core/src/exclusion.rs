@@ -0,0 +1,225 @@
+use std::collections::HashMap;
+use std::fmt;
+use std::time::{Duration, Instant};
+
+use halo_fixtures::{ChannelType, Fixture};
+use serde::{Deserialize, Serialize};
+
+use crate::{Cue, StaticValue};
+
+fn default_release_after() -> Duration {
+    Duration::from_secs(5)
+}
+
+/// Fixtures only one cue list may drive at a time, such as a haze machine or a pyro enable,
+/// whatever the lists' cues would otherwise merge to
+#[derive(Clone, Debug, PartialEq, Serialize, Deserialize)]
+pub struct ExclusionGroup {
+    pub name: String,
+    pub fixture_ids: Vec<usize>,
+    /// How long the list holding the group has to sit idle before another list can claim it
+    #[serde(
+        default = "default_release_after",
+        deserialize_with = "crate::duration::absolute::deserialize"
+    )]
+    pub release_after: Duration,
+}
+
+/// A cue kept off an exclusion group another list holds
+#[derive(Clone, Debug, PartialEq, Serialize, Deserialize)]
+pub struct ExclusionConflict {
+    pub group: String,
+    /// The list whose cue was kept off
+    pub list_index: usize,
+    pub cue_index: usize,
+    /// The list holding the group
+    pub owner: usize,
+}
+
+impl fmt::Display for ExclusionConflict {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        write!(
+            f,
+            "Cue {} in list {} left '{}' alone, list {} has it",
+            self.cue_index, self.list_index, self.group, self.owner
+        )
+    }
+}
+
+/// Which list holds each group, and since when it was last playing
+#[derive(Clone, Copy, Debug)]
+struct Claim {
+    list: usize,
+    active_at: Instant,
+}
+
+/// Keeps each exclusion group to the cue list that claimed it.
+///
+/// A list claims a group by running a cue that sets one of its fixtures while nobody holds
+/// it. The claim lasts until the list has gone `release_after` without playing, and until
+/// then other lists' values, effects, chase steps and releases on the group's fixtures are
+/// dropped before they reach the tracked state, with a conflict noted once per cue. Values
+/// other lists set up ahead of their next cue, for moving in black or the crossfader, are
+/// kept off the same way.
+#[derive(Clone, Debug, Default)]
+pub struct ExclusionGroups {
+    groups: Vec<ExclusionGroup>,
+    claims: HashMap<String, Claim>,
+    /// The cue each group last reported a conflict for, so a running cue doesn't repeat it
+    warned: HashMap<String, (usize, usize)>,
+    conflicts: Vec<ExclusionConflict>,
+    /// Values dropped this frame, with the group that kept them out
+    dropped: Vec<(usize, ChannelType, u8, String)>,
+}
+
+impl ExclusionGroups {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Replace the groups, letting go of every claim
+    pub fn set_groups(&mut self, groups: Vec<ExclusionGroup>) {
+        self.groups = groups;
+        self.claims.clear();
+        self.warned.clear();
+        self.dropped.clear();
+    }
+
+    pub fn groups(&self) -> &[ExclusionGroup] {
+        &self.groups
+    }
+
+    /// The list holding `group`, if any does
+    pub fn owner(&self, group: &str) -> Option<usize> {
+        self.claims.get(group).map(|claim| claim.list)
+    }
+
+    /// Start a frame with `playing` the list running, if any. Claims held by lists that have
+    /// been idle for longer than their group allows are let go.
+    pub fn begin(&mut self, playing: Option<usize>, now: Instant) {
+        self.dropped.clear();
+        let groups = &self.groups;
+        self.claims.retain(|name, claim| {
+            if Some(claim.list) == playing {
+                claim.active_at = now;
+                return true;
+            }
+            let release_after = groups
+                .iter()
+                .find(|g| &g.name == name)
+                .map_or(Duration::ZERO, |g| g.release_after);
+            now.duration_since(claim.active_at) < release_after
+        });
+        self.warned.retain(|name, _| self.claims.contains_key(name));
+    }
+
+    /// Take out of `cue`, running as `cue_index` in `list`, everything it would put on a
+    /// group another list holds, with `fixtures` the patch for releases of every fixture.
+    /// Groups nobody holds are claimed for `list`.
+    pub fn filter(
+        &mut self,
+        list: usize,
+        cue_index: usize,
+        cue: &mut Cue,
+        fixtures: &[Fixture],
+        now: Instant,
+    ) {
+        for group in &self.groups {
+            let in_group = |fixture_id: &usize| group.fixture_ids.contains(fixture_id);
+            let touches = cue.static_values.iter().any(|v| in_group(&v.fixture_id))
+                || cue
+                    .effects
+                    .iter()
+                    .any(|e| e.fixture_ids.iter().any(|id| in_group(id)))
+                || cue.chase.iter().any(|chase| {
+                    chase
+                        .steps
+                        .iter()
+                        .any(|step| step.static_values.iter().any(|v| in_group(&v.fixture_id)))
+                });
+            if !touches {
+                continue;
+            }
+
+            let claim = self.claims.entry(group.name.clone()).or_insert(Claim {
+                list,
+                active_at: now,
+            });
+            if claim.list == list {
+                claim.active_at = now;
+                continue;
+            }
+            let owner = claim.list;
+
+            let dropped = &mut self.dropped;
+            cue.static_values.retain(|v| {
+                if !in_group(&v.fixture_id) {
+                    return true;
+                }
+                dropped.push((
+                    v.fixture_id,
+                    v.channel_type.clone(),
+                    v.value,
+                    group.name.clone(),
+                ));
+                false
+            });
+            for effect in &mut cue.effects {
+                effect.fixture_ids.retain(|id| !in_group(id));
+            }
+            cue.effects.retain(|e| !e.fixture_ids.is_empty());
+            if let Some(chase) = &mut cue.chase {
+                for step in &mut chase.steps {
+                    step.static_values.retain(|v| !in_group(&v.fixture_id));
+                }
+            }
+            // A release would take the owner's effects off the group's fixtures
+            if let Some(release) = &mut cue.release {
+                let released: Vec<usize> = release
+                    .fixtures(fixtures)
+                    .map(|f| f.id)
+                    .filter(|id| !in_group(id))
+                    .collect();
+                if released.is_empty() {
+                    cue.release = None;
+                } else {
+                    release.fixture_ids = released;
+                }
+            }
+
+            if self.warned.insert(group.name.clone(), (list, cue_index)) != Some((list, cue_index))
+            {
+                let conflict = ExclusionConflict {
+                    group: group.name.clone(),
+                    list_index: list,
+                    cue_index,
+                    owner,
+                };
+                log::warn!("{conflict}");
+                self.conflicts.push(conflict);
+            }
+        }
+    }
+
+    /// Take out of `values`, which `list` is setting up ahead of its next cue, those on a
+    /// group another list holds. Nothing is claimed, as the list isn't playing them yet.
+    pub fn keep_off(&self, list: usize, values: &mut Vec<StaticValue>) {
+        let held: Vec<usize> = self
+            .groups
+            .iter()
+            .filter(|group| self.owner(&group.name).is_some_and(|owner| owner != list))
+            .flat_map(|group| group.fixture_ids.iter().copied())
+            .collect();
+        values.retain(|v| !held.contains(&v.fixture_id));
+    }
+
+    /// Values kept off a group this frame, as (fixture ID, channel, value, group)
+    pub fn dropped(&self) -> &[(usize, ChannelType, u8, String)] {
+        &self.dropped
+    }
+
+    /// Conflicts since the last call, oldest first
+    pub fn take_conflicts(&mut self) -> Vec<ExclusionConflict> {
+        std::mem::take(&mut self.conflicts)
+    }
+}
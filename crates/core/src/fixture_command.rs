@@ -0,0 +1,91 @@
+use std::time::Instant;
+
+use halo_fixtures::{ControlCommand, Fixture};
+
+use crate::parked::ParkedChannels;
+
+/// A control command part way through its steps
+#[derive(Clone)]
+struct RunningCommand {
+    fixture_id: usize,
+    command: ControlCommand,
+    step: usize,
+    step_started: Instant,
+}
+
+/// Runs fixture control commands (lamp on, lamp off, reset) against the console clock.
+///
+/// The channel a step holds is parked, so normal rendering can't overwrite it mid-sequence
+/// and it returns to whatever playback wants once the command finishes.
+#[derive(Clone, Default)]
+pub struct FixtureCommandRunner {
+    running: Vec<RunningCommand>,
+    parked: ParkedChannels,
+}
+
+impl FixtureCommandRunner {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Start a command from the fixture's profile
+    pub fn start(&mut self, fixture: &Fixture, name: &str, now: Instant) -> Result<(), String> {
+        if self.is_running(fixture.id) {
+            return Err(format!("{} is already running a command", fixture.name));
+        }
+        let command = fixture
+            .profile
+            .commands
+            .iter()
+            .find(|c| c.name.eq_ignore_ascii_case(name))
+            .ok_or_else(|| format!("{} has no '{name}' command", fixture.name))?;
+
+        self.running.push(RunningCommand {
+            fixture_id: fixture.id,
+            command: command.clone(),
+            step: 0,
+            step_started: now,
+        });
+        Ok(())
+    }
+
+    pub fn is_running(&self, fixture_id: usize) -> bool {
+        self.running.iter().any(|r| r.fixture_id == fixture_id)
+    }
+
+    /// Put back the values the last frame's parked channels replaced. Call before rendering.
+    pub fn restore(&mut self, fixtures: &mut [Fixture]) {
+        self.parked.restore(fixtures);
+    }
+
+    /// Advance running commands and park their current step's channel. Returns the
+    /// `(fixture id, command name)` of every command that finished.
+    pub fn apply(&mut self, fixtures: &mut [Fixture], now: Instant) -> Vec<(usize, String)> {
+        let mut finished = Vec::new();
+
+        self.running.retain_mut(|running| {
+            while let Some(step) = running.command.steps.get(running.step) {
+                if now.duration_since(running.step_started) < step.hold {
+                    break;
+                }
+                running.step_started += step.hold;
+                running.step += 1;
+            }
+
+            if running.step >= running.command.steps.len() {
+                finished.push((running.fixture_id, running.command.name.clone()));
+                return false;
+            }
+            true
+        });
+
+        for running in &self.running {
+            let step = &running.command.steps[running.step];
+            if let Some(fixture) = fixtures.iter_mut().find(|f| f.id == running.fixture_id) {
+                self.parked.park(fixture, &step.channel_type, step.value);
+            }
+        }
+
+        finished
+    }
+}
@@ -0,0 +1,249 @@
+use std::collections::{BTreeMap, HashMap};
+use std::fmt;
+use std::path::{Path, PathBuf};
+use std::time::{Duration, Instant};
+
+use halo_fixtures::{ChannelType, Fixture};
+use serde::{Deserialize, Serialize};
+
+/// How often the counters look at the output. Pan and tilt moves that come back within a
+/// sample aren't counted.
+const SAMPLE_INTERVAL: Duration = Duration::from_secs(1);
+
+/// How often the counters are added to the stats file
+const SAVE_INTERVAL: Duration = Duration::from_secs(60);
+
+/// Color channels that count as lit on fixtures with no dimmer
+const COLOR_CHANNELS: [ChannelType; 5] = [
+    ChannelType::Red,
+    ChannelType::Green,
+    ChannelType::Blue,
+    ChannelType::White,
+    ChannelType::Amber,
+];
+
+/// Wear on one fixture, for planning lamp and LED service
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct FixtureWear {
+    /// Seconds the fixture has been lit
+    pub on_secs: f64,
+    /// DMX steps the pan channel has moved through
+    pub pan_travel: u64,
+    /// DMX steps the tilt channel has moved through
+    pub tilt_travel: u64,
+}
+
+impl FixtureWear {
+    fn add(&mut self, other: &FixtureWear) {
+        self.on_secs += other.on_secs;
+        self.pan_travel += other.pan_travel;
+        self.tilt_travel += other.tilt_travel;
+    }
+
+    pub fn on_time(&self) -> Duration {
+        Duration::from_secs_f64(self.on_secs.max(0.0))
+    }
+}
+
+/// Wear counters for every fixture that has run, keyed by fixture name and kept across runs
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct FixtureStats {
+    pub fixtures: BTreeMap<String, FixtureWear>,
+}
+
+impl FixtureStats {
+    /// Read the stats file, starting from nothing if there isn't one yet
+    pub fn load(path: &Path) -> Result<Self, String> {
+        if !path.exists() {
+            return Ok(Self::default());
+        }
+        let data = std::fs::read_to_string(path)
+            .map_err(|e| format!("Couldn't read {}: {e}", path.display()))?;
+        serde_json::from_str(&data).map_err(|e| format!("Couldn't parse {}: {e}", path.display()))
+    }
+
+    /// Write the stats, replacing the file in one step so a crash mid-write can't corrupt it
+    pub fn save(&self, path: &Path) -> Result<(), String> {
+        let data = serde_json::to_string_pretty(self).map_err(|e| e.to_string())?;
+        let tmp = path.with_extension("tmp");
+        std::fs::write(&tmp, data)
+            .and_then(|_| std::fs::rename(&tmp, path))
+            .map_err(|e| format!("Couldn't write {}: {e}", path.display()))
+    }
+
+    pub fn get(&self, fixture: &str) -> Option<&FixtureWear> {
+        self.fixtures.get(fixture)
+    }
+
+    /// Zero a fixture's counters after servicing it, returning whether it had any
+    pub fn reset(&mut self, fixture: &str) -> bool {
+        self.fixtures.remove(fixture).is_some()
+    }
+
+    fn add(&mut self, other: &FixtureStats) {
+        for (name, wear) in &other.fixtures {
+            self.fixtures.entry(name.clone()).or_default().add(wear);
+        }
+    }
+}
+
+impl fmt::Display for FixtureStats {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        if self.fixtures.is_empty() {
+            return writeln!(f, "No fixture usage recorded yet");
+        }
+        writeln!(
+            f,
+            "{:<30} {:>10} {:>12} {:>12}",
+            "Fixture", "On time", "Pan sweeps", "Tilt sweeps"
+        )?;
+        for (name, wear) in &self.fixtures {
+            let minutes = wear.on_time().as_secs() / 60;
+            writeln!(
+                f,
+                "{:<30} {:>7}:{:02} {:>12.1} {:>12.1}",
+                name,
+                minutes / 60,
+                minutes % 60,
+                wear.pan_travel as f64 / 255.0,
+                wear.tilt_travel as f64 / 255.0
+            )?;
+        }
+        Ok(())
+    }
+}
+
+/// Whether a fixture is putting out light: its dimmer is up, or on fixtures without one,
+/// any color is
+fn is_lit(fixture: &Fixture) -> bool {
+    match fixture.channel_value(&ChannelType::Dimmer) {
+        Some(dimmer) => dimmer > 0,
+        None => COLOR_CHANNELS
+            .iter()
+            .any(|channel_type| fixture.channel_value(channel_type).unwrap_or(0) > 0),
+    }
+}
+
+/// Counts fixture wear from the output once a second, adding it to the stats file every
+/// minute. Saving adds to whatever the file holds then, so a reset made by `halo stats`
+/// while the console runs sticks.
+pub(crate) struct StatsCounter {
+    path: Option<PathBuf>,
+    /// The stats file as of the last save
+    saved: FixtureStats,
+    /// Wear counted since the last save
+    pending: FixtureStats,
+    last_sample: Option<Instant>,
+    last_save: Option<Instant>,
+    positions: HashMap<usize, (Option<u8>, Option<u8>)>,
+}
+
+impl StatsCounter {
+    pub fn new(path: Option<PathBuf>) -> Self {
+        let saved = match &path {
+            Some(path) => FixtureStats::load(path).unwrap_or_else(|e| {
+                log::warn!("{e}, counting fixture usage from zero");
+                FixtureStats::default()
+            }),
+            None => FixtureStats::default(),
+        };
+        Self {
+            path,
+            saved,
+            pending: FixtureStats::default(),
+            last_sample: None,
+            last_save: None,
+            positions: HashMap::new(),
+        }
+    }
+
+    /// Count what the fixtures have done since the last sample, if a second has passed
+    pub fn sample(&mut self, fixtures: &[Fixture], now: Instant) {
+        let Some(last) = self.last_sample else {
+            self.last_sample = Some(now);
+            self.last_save = Some(now);
+            self.note_positions(fixtures);
+            return;
+        };
+        let elapsed = now.saturating_duration_since(last);
+        if elapsed < SAMPLE_INTERVAL {
+            return;
+        }
+        self.last_sample = Some(now);
+
+        for fixture in fixtures {
+            let wear = self
+                .pending
+                .fixtures
+                .entry(fixture.name.clone())
+                .or_default();
+            if is_lit(fixture) {
+                wear.on_secs += elapsed.as_secs_f64();
+            }
+            let pan = fixture.channel_value(&ChannelType::Pan);
+            let tilt = fixture.channel_value(&ChannelType::Tilt);
+            if let Some((last_pan, last_tilt)) = self.positions.get(&fixture.id) {
+                wear.pan_travel += travel(*last_pan, pan);
+                wear.tilt_travel += travel(*last_tilt, tilt);
+            }
+        }
+        self.note_positions(fixtures);
+    }
+
+    fn note_positions(&mut self, fixtures: &[Fixture]) {
+        self.positions = fixtures
+            .iter()
+            .map(|f| {
+                (
+                    f.id,
+                    (
+                        f.channel_value(&ChannelType::Pan),
+                        f.channel_value(&ChannelType::Tilt),
+                    ),
+                )
+            })
+            .collect();
+    }
+
+    /// The counters so far, saved and not
+    pub fn stats(&self) -> FixtureStats {
+        let mut stats = self.saved.clone();
+        stats.add(&self.pending);
+        stats
+    }
+
+    /// Add the pending counts to the stats file if a minute has passed, or straight away if
+    /// `force`d, e.g. at shutdown
+    pub fn save(&mut self, now: Instant, force: bool) {
+        let Some(path) = &self.path else {
+            return;
+        };
+        let due = self
+            .last_save
+            .map_or(true, |last| now.duration_since(last) >= SAVE_INTERVAL);
+        if !due && !force {
+            return;
+        }
+        self.last_save = Some(now);
+
+        let mut stats = FixtureStats::load(path).unwrap_or_else(|e| {
+            log::warn!("{e}, rewriting it");
+            FixtureStats::default()
+        });
+        stats.add(&self.pending);
+        match stats.save(path) {
+            Ok(()) => {
+                self.saved = stats;
+                self.pending = FixtureStats::default();
+            }
+            Err(e) => log::warn!("{e}"),
+        }
+    }
+}
+
+fn travel(from: Option<u8>, to: Option<u8>) -> u64 {
+    match (from, to) {
+        (Some(from), Some(to)) => from.abs_diff(to) as u64,
+        _ => 0,
+    }
+}
@@ -0,0 +1,101 @@
+use halo_fixtures::{ChannelType, Fixture};
+
+use crate::parked::ParkedChannels;
+use crate::StaticValue;
+
+/// Momentary looks held on top of playback and the programmer.
+///
+/// Flashed channels are parked, so releasing a flash returns the channel to whatever playback
+/// has moved on to rather than a stale snapshot.
+#[derive(Clone, Default)]
+pub struct FlashLayer {
+    /// Held looks in the order they were pressed
+    active: Vec<(String, Vec<StaticValue>)>,
+    /// Held looks that freeze the effects running underneath them
+    freezing: Vec<String>,
+    parked: ParkedChannels,
+}
+
+impl FlashLayer {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Hold a look. Pressing a look that is already held does nothing.
+    pub fn flash_on(&mut self, name: &str, values: Vec<StaticValue>) {
+        if !self.is_active(name) {
+            self.active.push((name.to_string(), values));
+        }
+    }
+
+    /// Hold a look that freezes running effects, returning whether this press started a
+    /// freeze. Pressing a look that is already held does nothing.
+    pub fn flash_on_freezing(&mut self, name: &str, values: Vec<StaticValue>) -> bool {
+        if self.is_active(name) {
+            return false;
+        }
+        self.active.push((name.to_string(), values));
+        self.freezing.push(name.to_string());
+        true
+    }
+
+    /// Release a look, returning whether it was held
+    pub fn flash_off(&mut self, name: &str) -> bool {
+        let held = self.active.len();
+        self.active.retain(|(n, _)| n != name);
+        self.freezing.retain(|n| n != name);
+        self.active.len() != held
+    }
+
+    /// Whether a held look is freezing the effects underneath it
+    pub fn is_freezing(&self, name: &str) -> bool {
+        self.freezing.iter().any(|n| n == name)
+    }
+
+    pub fn is_active(&self, name: &str) -> bool {
+        self.active.iter().any(|(n, _)| n == name)
+    }
+
+    pub fn active_looks(&self) -> Vec<String> {
+        self.active.iter().map(|(n, _)| n.clone()).collect()
+    }
+
+    /// Put back the values the last frame's flashes replaced. Call before rendering playback.
+    pub fn restore(&mut self, fixtures: &mut [Fixture]) {
+        self.parked.restore(fixtures);
+    }
+
+    /// Render held looks over the current output. Intensity is highest takes precedence
+    /// across flashes and the output underneath; everything else is latest takes precedence.
+    pub fn apply(&mut self, fixtures: &mut [Fixture]) {
+        let mut merged: Vec<StaticValue> = Vec::new();
+        for value in self.active.iter().flat_map(|(_, values)| values) {
+            match merged
+                .iter_mut()
+                .find(|v| v.fixture_id == value.fixture_id && v.channel_type == value.channel_type)
+            {
+                Some(existing) if value.channel_type == ChannelType::Dimmer => {
+                    existing.value = existing.value.max(value.value);
+                }
+                Some(existing) => existing.value = value.value,
+                None => merged.push(value.clone()),
+            }
+        }
+
+        for value in merged {
+            let Some(fixture) = fixtures.iter_mut().find(|f| f.id == value.fixture_id) else {
+                continue;
+            };
+            let Some(current) = fixture.channel_value(&value.channel_type) else {
+                continue;
+            };
+
+            let flashed = if value.channel_type == ChannelType::Dimmer {
+                current.max(value.value)
+            } else {
+                value.value
+            };
+            self.parked.park(fixture, &value.channel_type, flashed);
+        }
+    }
+}
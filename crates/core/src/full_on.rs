@@ -0,0 +1,56 @@
+use halo_fixtures::{ChannelType, Fixture};
+
+use crate::parked::ParkedChannels;
+
+/// Channels driven by an emergency full on, and the value each is driven to
+const FULL_ON_VALUES: [(ChannelType, u8); 6] = [
+    (ChannelType::Dimmer, 255),
+    (ChannelType::Red, 255),
+    (ChannelType::Green, 255),
+    (ChannelType::Blue, 255),
+    (ChannelType::White, 255),
+    (ChannelType::Strobe, 0),
+];
+
+/// Emergency "all lights full open white", rendered over everything else.
+///
+/// Channels are parked, so releasing returns every fixture to whatever the cues dictate now.
+#[derive(Clone, Default)]
+pub struct FullOnLayer {
+    active: bool,
+    parked: ParkedChannels,
+}
+
+impl FullOnLayer {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    pub fn set_active(&mut self, active: bool) {
+        self.active = active;
+    }
+
+    pub fn is_active(&self) -> bool {
+        self.active
+    }
+
+    /// Put back the values the last frame's full on replaced. Call before rendering playback.
+    pub fn restore(&mut self, fixtures: &mut [Fixture]) {
+        self.parked.restore(fixtures);
+    }
+
+    /// Drive every fixture with an intensity channel to full white with the shutter open
+    pub fn apply(&mut self, fixtures: &mut [Fixture]) {
+        if !self.active {
+            return;
+        }
+        for fixture in fixtures
+            .iter_mut()
+            .filter(|f| f.channel_value(&ChannelType::Dimmer).is_some())
+        {
+            for (channel_type, value) in &FULL_ON_VALUES {
+                self.parked.park(fixture, channel_type, *value);
+            }
+        }
+    }
+}
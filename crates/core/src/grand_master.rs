@@ -0,0 +1,175 @@
+use std::collections::HashMap;
+use std::time::{Duration, Instant};
+
+use halo_fixtures::{ChannelType, Fixture};
+
+use crate::parked::ParkedChannels;
+use crate::StaticValue;
+
+/// Color channels scaled on fixtures that have no dimmer
+const COLOR_CHANNELS: [ChannelType; 5] = [
+    ChannelType::Red,
+    ChannelType::Green,
+    ChannelType::Blue,
+    ChannelType::White,
+    ChannelType::Amber,
+];
+
+#[derive(Clone, Copy)]
+struct Ramp {
+    from: f32,
+    to: f32,
+    started: Instant,
+    duration: Duration,
+}
+
+/// A grand master scaling every fixture's intensity, for timed fades to black and back.
+///
+/// Playback keeps running underneath, so fading up reveals whatever the cues and effects
+/// dictate by then. Starting a fade part way through another ramps from wherever the level
+/// has got to.
+///
+/// Blackout takes the output to zero at once, whatever the level, and releasing it goes
+/// straight back to the level and to what playback is doing by then.
+#[derive(Clone)]
+pub struct GrandMaster {
+    ramp: Ramp,
+    blackout: bool,
+    parked: ParkedChannels,
+}
+
+impl Default for GrandMaster {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+impl GrandMaster {
+    pub fn new() -> Self {
+        Self {
+            ramp: Ramp {
+                from: 1.0,
+                to: 1.0,
+                started: Instant::now(),
+                duration: Duration::ZERO,
+            },
+            blackout: false,
+            parked: ParkedChannels::default(),
+        }
+    }
+
+    /// Ramp from the current level to `level` (0.0 to 1.0) over `duration`
+    pub fn fade_to(&mut self, level: f32, duration: Duration, now: Instant) {
+        self.ramp = Ramp {
+            from: self.level(now),
+            to: level.clamp(0.0, 1.0),
+            started: now,
+            duration,
+        };
+    }
+
+    /// Go to `level` (0.0 to 1.0) at once
+    pub fn set_level(&mut self, level: f32, now: Instant) {
+        self.fade_to(level, Duration::ZERO, now);
+    }
+
+    pub fn set_blackout(&mut self, active: bool) {
+        self.blackout = active;
+    }
+
+    pub fn is_blacked_out(&self) -> bool {
+        self.blackout
+    }
+
+    /// The level the current ramp is heading for
+    pub fn target(&self) -> f32 {
+        self.ramp.to
+    }
+
+    pub fn level(&self, now: Instant) -> f32 {
+        let ramp = &self.ramp;
+        let elapsed = now.saturating_duration_since(ramp.started);
+        if elapsed >= ramp.duration {
+            return ramp.to;
+        }
+        let progress = elapsed.as_secs_f32() / ramp.duration.as_secs_f32();
+        ramp.from + (ramp.to - ramp.from) * progress
+    }
+
+    /// The level intensity is scaled by: the grand master's, or zero during a blackout
+    pub fn output_level(&self, now: Instant) -> f32 {
+        if self.blackout {
+            0.0
+        } else {
+            self.level(now)
+        }
+    }
+
+    /// Put back the values the last frame's scaling replaced. Call before rendering playback.
+    pub fn restore(&mut self, fixtures: &mut [Fixture]) {
+        self.parked.restore(fixtures);
+    }
+
+    /// Scale every dimmer by the current level, or zero it during a blackout. Fixtures
+    /// without a dimmer have their color channels scaled instead.
+    pub fn apply(&mut self, fixtures: &mut [Fixture], now: Instant) {
+        let level = self.output_level(now);
+        if level >= 1.0 {
+            return;
+        }
+        let scale = |value: u8| (value as f32 * level).round() as u8;
+
+        for fixture in fixtures.iter_mut() {
+            let intensity: Vec<(ChannelType, u8)> = fixture
+                .channels
+                .iter()
+                .filter(|channel| is_intensity(fixture, &channel.channel_type))
+                .map(|channel| (channel.channel_type.clone(), scale(channel.value)))
+                .collect();
+            for (channel_type, value) in intensity {
+                self.parked.park(fixture, &channel_type, value);
+            }
+        }
+    }
+
+    /// Scale what the pixel engine rendered, which goes out without passing through the
+    /// fixtures' channels
+    pub fn apply_to_pixels(&self, universes: &mut HashMap<u8, Vec<u8>>, now: Instant) {
+        let level = self.output_level(now);
+        if level >= 1.0 {
+            return;
+        }
+        for data in universes.values_mut() {
+            for value in data.iter_mut() {
+                *value = (*value as f32 * level).round() as u8;
+            }
+        }
+    }
+}
+
+/// Whether the grand master scales `channel_type` on `fixture`: its dimmer, or its color and
+/// pixel channels if it has no dimmer. Everything else, such as position or gobo, passes
+/// through.
+pub fn is_intensity(fixture: &Fixture, channel_type: &ChannelType) -> bool {
+    if fixture.channel_value(&ChannelType::Dimmer).is_some() {
+        return *channel_type == ChannelType::Dimmer;
+    }
+    matches!(
+        channel_type,
+        ChannelType::PixelRed(_) | ChannelType::PixelGreen(_) | ChannelType::PixelBlue(_)
+    ) || COLOR_CHANNELS.contains(channel_type)
+}
+
+/// Scale a look's intensity by `level` (0.0 to 1.0) the way the grand master does: dimmer
+/// values, or color values on fixtures without a dimmer
+pub(crate) fn scale_intensity(values: &mut [StaticValue], fixtures: &[Fixture], level: f32) {
+    let level = level.clamp(0.0, 1.0);
+    for value in values.iter_mut() {
+        let Some(fixture) = fixtures.iter().find(|f| f.id == value.fixture_id) else {
+            continue;
+        };
+        if is_intensity(fixture, &value.channel_type) {
+            value.value = (value.value as f32 * level).round() as u8;
+        }
+    }
+}
@@ -1,46 +1,177 @@
+//! The halo lighting console as a library.
+//!
+//! The supported surface for driving a rig from another program is:
+//!
+//! - [`Engine`] and [`EngineOptions`] to start a console on its own task, the same way the `halo`
+//!   binary does, then [`ConsoleCommand`] to control it and [`ConsoleEvent`] to follow it.
+//! - [`Show`], [`CueList`] and [`Cue`] to build shows in code. [`Cue::intensity_only`],
+//!   [`Cue::color_only`] and [`Cue::ripple`] cover the common cue shapes, and [`Show::read`] loads
+//!   a show file with its fixtures' channels resolved. [`demo_show`] is one that runs on a
+//!   virtual rig, for trying halo out.
+//! - [`EffectMapping::intensity_only`] and [`Cue::color_base`] for the common split of a cue
+//!   setting a color base while an effect moves only the intensity. Playback merges channel
+//!   by channel, so the effect never touches the color, and on fixtures without a dimmer it
+//!   scales the cue's color instead.
+//! - [`auto_patch`], [`patch_sheet`], [`PatchReport`] and [`FixtureDescription`] for patching,
+//!   with fixture profiles from the `halo-fixtures` crate.
+//! - [`simulate_show`] and [`analyze_usage`] to check a show before it runs.
+//!
+//! [`LightingConsole`] and the layers it is built from are public for tests and tools, but
+//! are driven through commands in normal use. See `examples/external/main.rs` for a program
+//! that builds and runs a show with nothing but this crate.
+
 pub use ableton_link::AbletonLinkManager;
 pub use artnet::artnet::ArtNetMode;
 pub use artnet::network_config::{ArtNetDestination, NetworkConfig};
 pub use audio::audio_player::AudioPlayer;
 pub use audio::device_enumerator::{enumerate_audio_devices, AudioDeviceInfo};
+pub use build_info::BuildInfo;
+pub use capacity::{CapacityEstimate, UnitCosts, Workload};
+pub use capture::{Capture, CaptureHeader, CapturedFrame, OutputCapture, CAPTURE_VERSION};
+pub use clock::{Clock, ManualClock, SystemClock};
 pub use config::{ConfigError, ConfigManager, ConfigSchema};
 pub use console::{LightingConsole, SyncLightingConsole};
+pub use contributions::{ContributionSource, ContributionTrace, Rule, SourceValue};
+pub use cue::chase::{Chase, ChaseDirection, ChasePlayer, ChaseRate, ChaseStep};
+pub use cue::crossfade::{CrossfadeAction, Crossfader};
 pub use cue::cue::{
-    Cue, CueList, EffectDistribution, EffectMapping, PixelEffectMapping, StaticValue,
+    Cue, CueList, DefaultValue, EffectDistribution, EffectMapping, FixtureDelay,
+    PixelEffectMapping, PositionValue, StaticValue, Variation, WeightedColor,
 };
-pub use cue::cue_manager::{CueManager, PlaybackState};
+pub use cue::cue_manager::{CueListStatus, CueManager, CueStatus, PlaybackState};
+pub use cue::cue_sheet::{merge_cue_sheet, parse_cue_sheet, CueSheetMerge, CueSheetRow, SheetTime};
+pub use cue::estimate::{CueDuration, Follow};
+pub use cue::fade::{Attribute, CueFade, OverrideFadePolicy};
+pub use cue::learn::{apply_learned, LearnedTiming, TimingLearner};
+pub use cue::look::{expand_looks, Looks};
+pub use cue::position::{resolve_positions, PositionPresets};
+pub use cue::release::{home_value, Release};
+pub use cue::test_pattern::{describe_step, TestPattern};
+pub use demo::{demo_patch, demo_show, DEMO_CUE_TIME, DEMO_SHOW_NAME};
+pub use disable::DisabledOutputs;
+pub use dmx_import::{import_universe, DmxImport};
+pub use duration::MusicalDuration;
 pub use effect::effect::{
-    sawtooth_effect, sine_effect, square_effect, Effect, EffectParams, EffectType,
+    sawtooth_effect, sine_effect, square_effect, triangle_effect, Effect, EffectParams, EffectType,
 };
+pub use effect::player::{ColorOverride, EffectPlayer, OverrideColor};
+pub use effect::source::{EffectContext, EffectFactory, EffectRegistry, EffectSource};
 pub use effect::EffectRelease;
+pub use engine::{Engine, EngineOptions};
+pub use exclusion::{ExclusionConflict, ExclusionGroup, ExclusionGroups};
+pub use fixture_command::FixtureCommandRunner;
+pub use fixture_stats::{FixtureStats, FixtureWear};
+pub use flash::FlashLayer;
+pub use full_on::FullOnLayer;
+pub use grand_master::{is_intensity, GrandMaster};
+pub use lifecycle::{Lifecycle, LifecycleError, Stage, StageError};
+pub use manual::ManualLayer;
+pub use merge::{MergePolicy, PlaybackMerge};
 pub use messages::{ConsoleCommand, ConsoleEvent, Settings};
 pub use midi::midi::{MidiAction, MidiMessage, MidiOverride};
 // Async module system exports
 pub use modules::{
     AsyncModule, AudioModule, DmxModule, MidiModule, ModuleEvent, ModuleId, ModuleManager,
-    ModuleMessage, SmpteModule,
+    ModuleMessage, NullDmxModule, OutputStats, SmpteModule,
+};
+pub use motion::{Axis, AxisPosition, HeadPosition, MotionModel, SpeedDemand};
+pub use move_in_black::{moves_in_black, MoveInBlack};
+pub use output::{ArtNetDriver, NullDriver, OutputDriver, OutputKind, SacnDriver};
+pub use patch::{
+    auto_patch, patch_conflicts, patch_sheet, ChannelDescription, FixtureDescription, PatchAddress,
+    PatchPlan, PatchSpec,
 };
+pub use patch_report::{PatchReport, PatchReportRow, ReportFormat, UniverseUsage};
 pub use pixel::{PixelEffect, PixelEffectParams, PixelEffectScope, PixelEffectType, PixelEngine};
-pub use rhythm::rhythm::{Interval, RhythmState};
+pub use programmer::{fan_values, FanMode};
+pub use realtime::{
+    set_thread_niceness, spawn_pinned, FrameDrift, FrameTimer, TickHistogram, NICENESS_RANGE,
+};
+pub use recording::{
+    ChannelChange, DmxRecorder, FixtureHistory, HistoryRow, MusicalPosition, PositionDiff,
+    RecordedFixture, RecordedFrame, Recording, RecordingHeader, RECORDING_VERSION,
+};
+pub use render::{FrameCache, RENDER_TICK};
+pub use resume::ResumeState;
+pub use rhythm::rhythm::{BeatGridEdit, Interval, Meter, RhythmState};
+pub use sacn::sacn::{multicast_address, SacnConfig, SACN_PORT};
+pub use safe_mode::{recover_panic, SAFE_MODE_REQUESTED};
+pub use schedule::{LatePolicy, ScheduledAction, ScheduledEvent, ShowSchedule};
+pub use show::alias::{alias_collisions, analyze_aliases, find_fixture, AliasReport, AliasUsage};
 pub use show::show::Show;
 pub use show::show_manager::ShowManager;
+pub use show::usage::{analyze_usage, FixtureUsage, UsageReport};
+pub use show::variables::{bind_cue, ShowVariables, Variable, VariableRef, Variables};
+pub use show::workspace::{ShowWorkspace, WorkspaceShow};
+pub use simulation::{
+    fix_gaps, simulate_show, simulate_show_with, CueTiming, Finding, Gap, GapCheck, MotionLag,
+    Severity, SimulationOptions, SimulationReport,
+};
+pub use smoothing::{default_channel_smoothing, ChannelSmoother, ChannelSmoothing};
+pub use solo::SoloLayer;
+pub use standby::{
+    follow_primary, read_mirror, serve_standby, show_file_hash, write_mirror, MirrorState,
+    Redundancy, HEARTBEAT_TIMEOUT,
+};
+pub use strobe::{effect_hz, StrobeLimiter};
 pub use timecode::timecode::TimeCode;
+pub use timetable::{Timetable, TimetableAction, TimetableRule};
 pub use tracking_state::TrackingState;
+pub use trigger::{Trigger, TriggerAction, TriggerDispatcher, TriggerEvent, TriggerSource};
 
 mod ableton_link;
 mod artnet;
 pub mod audio;
+mod build_info;
+mod capacity;
+mod capture;
+mod clock;
 mod config;
 mod console;
+mod contributions;
 
 mod cue;
+mod demo;
+mod disable;
+mod dmx_import;
+mod duration;
 mod effect;
+mod engine;
+mod exclusion;
+mod fixture_command;
+mod fixture_stats;
+mod flash;
+mod full_on;
+mod grand_master;
+mod lifecycle;
+mod manual;
+mod merge;
 pub mod messages;
 mod midi;
 mod modules;
+mod motion;
+mod move_in_black;
+mod output;
+mod parked;
+mod patch;
+mod patch_report;
 mod pixel;
 mod programmer;
+mod realtime;
+mod recording;
+mod render;
+mod resume;
 mod rhythm;
+mod sacn;
+mod safe_mode;
+mod schedule;
 mod show;
+mod simulation;
+mod smoothing;
+mod solo;
+mod standby;
+mod strobe;
 mod timecode;
+mod timetable;
 mod tracking_state;
+mod trigger;
@@ -0,0 +1,131 @@
+use std::fmt;
+use std::time::Duration;
+
+use async_trait::async_trait;
+
+/// One part of a running program that [`Lifecycle`] brings up and takes down in turn, e.g.
+/// the console or a link to a standby
+#[async_trait]
+pub trait Stage: Send {
+    /// What to call the stage in logs and errors
+    fn name(&self) -> &str;
+
+    async fn start(&mut self) -> Result<(), anyhow::Error>;
+
+    async fn stop(&mut self) -> Result<(), anyhow::Error>;
+}
+
+/// A stage that failed to start or stop, or didn't finish in time
+#[derive(Debug, Clone, PartialEq)]
+pub struct StageError {
+    pub stage: String,
+    pub error: String,
+}
+
+/// Everything that went wrong starting or stopping a [`Lifecycle`]
+#[derive(Debug, Clone, PartialEq)]
+pub struct LifecycleError {
+    pub failures: Vec<StageError>,
+}
+
+impl fmt::Display for LifecycleError {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        for (i, failure) in self.failures.iter().enumerate() {
+            if i > 0 {
+                write!(f, "; ")?;
+            }
+            write!(f, "{}: {}", failure.stage, failure.error)?;
+        }
+        Ok(())
+    }
+}
+
+impl std::error::Error for LifecycleError {}
+
+/// Starts stages in the order they were added and stops them in reverse, so each stage can
+/// rely on the ones before it for as long as it runs.
+///
+/// Every start and stop has its stage's timeout. A stage that hangs is abandoned with an
+/// error and the rest carry on, so one stuck output can't keep the program from exiting.
+/// Stopping goes through every started stage whatever fails along the way, and reports all
+/// the failures together.
+#[derive(Default)]
+pub struct Lifecycle {
+    stages: Vec<(Box<dyn Stage>, Duration)>,
+    /// How many stages, from the first, are running
+    started: usize,
+}
+
+impl Lifecycle {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Add a stage to start after those already added, with how long it gets to start or stop
+    pub fn add(&mut self, stage: impl Stage + 'static, timeout: Duration) -> &mut Self {
+        self.stages.push((Box::new(stage), timeout));
+        self
+    }
+
+    /// The stages' names, in starting order
+    pub fn stage_names(&self) -> Vec<&str> {
+        self.stages.iter().map(|(stage, _)| stage.name()).collect()
+    }
+
+    /// Start every stage not yet running, in order. If one fails, those already started are
+    /// stopped again and nothing is left half up.
+    pub async fn start(&mut self) -> Result<(), LifecycleError> {
+        while self.started < self.stages.len() {
+            let (stage, timeout) = &mut self.stages[self.started];
+            let name = stage.name().to_string();
+            log::debug!("Starting {name}");
+            if let Err(failure) = run(&name, stage.start(), *timeout).await {
+                log::error!("Couldn't start {}: {}", failure.stage, failure.error);
+                let mut failures = vec![failure];
+                if let Err(e) = self.stop().await {
+                    failures.extend(e.failures);
+                }
+                return Err(LifecycleError { failures });
+            }
+            self.started += 1;
+        }
+        Ok(())
+    }
+
+    /// Stop every running stage, last started first
+    pub async fn stop(&mut self) -> Result<(), LifecycleError> {
+        let mut failures = Vec::new();
+        while self.started > 0 {
+            self.started -= 1;
+            let (stage, timeout) = &mut self.stages[self.started];
+            let name = stage.name().to_string();
+            log::debug!("Stopping {name}");
+            if let Err(failure) = run(&name, stage.stop(), *timeout).await {
+                log::error!("Couldn't stop {}: {}", failure.stage, failure.error);
+                failures.push(failure);
+            }
+        }
+        if failures.is_empty() {
+            Ok(())
+        } else {
+            Err(LifecycleError { failures })
+        }
+    }
+}
+
+/// Run a stage's start or stop, giving up on it after `timeout`
+async fn run(
+    name: &str,
+    step: impl std::future::Future<Output = Result<(), anyhow::Error>>,
+    timeout: Duration,
+) -> Result<(), StageError> {
+    let error = match tokio::time::timeout(timeout, step).await {
+        Ok(Ok(())) => return Ok(()),
+        Ok(Err(e)) => e.to_string(),
+        Err(_) => format!("timed out after {timeout:?}"),
+    };
+    Err(StageError {
+        stage: name.to_string(),
+        error,
+    })
+}
@@ -0,0 +1,139 @@
+use std::time::{Duration, Instant};
+
+use halo_fixtures::{ChannelType, Fixture};
+
+use crate::parked::ParkedChannels;
+
+/// One attribute written by a manual source
+#[derive(Clone)]
+struct ManualWrite {
+    source: String,
+    fixture_id: usize,
+    channel_type: ChannelType,
+    value: u8,
+    written: Instant,
+    /// When an explicit release started fading the write out
+    released: Option<Instant>,
+}
+
+/// Values written by manual sources (OSC faders, MIDI controls) over playback.
+///
+/// Attributes are latest takes precedence across sources. A source that stops sending is
+/// released after the inactivity timeout, and a released attribute fades from its manual value
+/// back to whatever playback is putting out underneath, so a forgotten fader can't hold a
+/// channel forever.
+#[derive(Clone, Default)]
+pub struct ManualLayer {
+    writes: Vec<ManualWrite>,
+    parked: ParkedChannels,
+}
+
+impl ManualLayer {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Write an attribute from a source, replacing its last write and cancelling any release
+    pub fn set(
+        &mut self,
+        source: &str,
+        fixture_id: usize,
+        channel_type: ChannelType,
+        value: u8,
+        now: Instant,
+    ) {
+        self.writes.retain(|w| {
+            !(w.source == source && w.fixture_id == fixture_id && w.channel_type == channel_type)
+        });
+        self.writes.push(ManualWrite {
+            source: source.to_string(),
+            fixture_id,
+            channel_type,
+            value,
+            written: now,
+            released: None,
+        });
+    }
+
+    /// Start fading out everything a source has written, returning whether it had anything
+    pub fn release(&mut self, source: &str, now: Instant) -> bool {
+        let mut found = false;
+        for write in self.writes.iter_mut().filter(|w| w.source == source) {
+            write.released.get_or_insert(now);
+            found = true;
+        }
+        found
+    }
+
+    /// Sources with at least one attribute still in the output
+    pub fn sources(&self) -> Vec<String> {
+        let mut sources: Vec<String> = Vec::new();
+        for write in &self.writes {
+            if !sources.contains(&write.source) {
+                sources.push(write.source.clone());
+            }
+        }
+        sources
+    }
+
+    /// Put back the values the last frame's manual writes replaced. Call before rendering
+    /// playback.
+    pub fn restore(&mut self, fixtures: &mut [Fixture]) {
+        self.parked.restore(fixtures);
+    }
+
+    /// Render manual writes over the current output. Writes older than `timeout` are released,
+    /// and released writes take `fade` to hand back to the output underneath.
+    pub fn apply(
+        &mut self,
+        fixtures: &mut [Fixture],
+        now: Instant,
+        timeout: Option<Duration>,
+        fade: Duration,
+    ) {
+        let release_started = |write: &ManualWrite| {
+            let timed_out = timeout
+                .map(|timeout| write.written + timeout)
+                .filter(|at| *at <= now);
+            match (write.released, timed_out) {
+                (Some(a), Some(b)) => Some(a.min(b)),
+                (a, b) => a.or(b),
+            }
+        };
+        self.writes.retain(|write| {
+            release_started(write).is_none_or(|started| now.duration_since(started) < fade)
+        });
+
+        // Latest write wins each attribute
+        let mut latest: Vec<&ManualWrite> = Vec::new();
+        for write in &self.writes {
+            match latest
+                .iter_mut()
+                .find(|w| w.fixture_id == write.fixture_id && w.channel_type == write.channel_type)
+            {
+                Some(existing) if existing.written <= write.written => *existing = write,
+                Some(_) => {}
+                None => latest.push(write),
+            }
+        }
+
+        for write in latest {
+            let Some(fixture) = fixtures.iter_mut().find(|f| f.id == write.fixture_id) else {
+                continue;
+            };
+            let Some(underlying) = fixture.channel_value(&write.channel_type) else {
+                continue;
+            };
+            let value = match release_started(write) {
+                Some(started) => {
+                    let progress = now.duration_since(started).as_secs_f32() / fade.as_secs_f32();
+                    let value = write.value as f32
+                        + (underlying as f32 - write.value as f32) * progress.clamp(0.0, 1.0);
+                    value.round() as u8
+                }
+                None => write.value,
+            };
+            self.parked.park(fixture, &write.channel_type, value);
+        }
+    }
+}
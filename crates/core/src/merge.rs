@@ -0,0 +1,136 @@
+use halo_fixtures::{ChannelType, Fixture};
+use serde::{Deserialize, Serialize};
+
+use crate::contributions::{ContributionSource, ContributionTrace, Rule};
+use crate::cue::fade::Attribute;
+use crate::grand_master::is_intensity;
+
+/// How values from several playback sources on the same channel combine
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum MergePolicy {
+    /// Latest takes precedence: effects write over the cues they run in, as shows have
+    /// always played
+    #[default]
+    Ltp,
+    /// Highest takes precedence: the brightest source wins, whatever order they wrote in.
+    /// A dimmer effect under a cue holding the dimmer at or above the effect's max renders
+    /// solid, so shows opt in once their cues leave the dimmer to the effect.
+    Htp,
+}
+
+impl MergePolicy {
+    pub fn rule(&self) -> Rule {
+        match self {
+            MergePolicy::Htp => Rule::Htp,
+            MergePolicy::Ltp => Rule::Ltp,
+        }
+    }
+}
+
+/// The tracked cue values and running effects of one frame, each tagged with the source that
+/// wrote it and merged channel by channel once they're all in.
+///
+/// Sources write afresh every frame, so a cue released or an effect stopped simply stops
+/// contributing, leaving the others as if it had never been there. Intensity follows the
+/// show's policy, LTP unless set otherwise; color, position and everything else take the
+/// latest value written. Cues write before effects and effects in the order they were
+/// declared, so the latest is the same every frame.
+///
+/// Intensity and color are resolved apart, so one fixture can take its color from a cue and
+/// its intensity from an effect in the same frame. A fixture without a dimmer takes the
+/// dimmer level written for it as a scale on its colors.
+#[derive(Clone, Debug, Default)]
+pub struct PlaybackMerge {
+    intensity: MergePolicy,
+    writes: Vec<(ContributionSource, usize, ChannelType, u8)>,
+}
+
+impl PlaybackMerge {
+    pub fn new(intensity: MergePolicy) -> Self {
+        Self {
+            intensity,
+            writes: Vec::new(),
+        }
+    }
+
+    /// How values on `channel_type` combine
+    pub fn policy(&self, channel_type: &ChannelType) -> MergePolicy {
+        match Attribute::of(channel_type) {
+            Attribute::Intensity => self.intensity,
+            _ => MergePolicy::Ltp,
+        }
+    }
+
+    /// Note a value `source` puts on a channel this frame
+    pub fn write(
+        &mut self,
+        source: ContributionSource,
+        fixture_id: usize,
+        channel_type: &ChannelType,
+        value: u8,
+    ) {
+        self.writes
+            .push((source, fixture_id, channel_type.clone(), value));
+    }
+
+    /// What each channel written to merges to, as (fixture ID, channel, value) in the order
+    /// the channels were first written
+    pub fn merged(&self) -> Vec<(usize, ChannelType, u8)> {
+        let mut merged: Vec<(usize, ChannelType, u8)> = Vec::new();
+        for (_, fixture_id, channel_type, value) in &self.writes {
+            match merged
+                .iter_mut()
+                .find(|(id, c, _)| id == fixture_id && c == channel_type)
+            {
+                Some((_, _, current)) => {
+                    *current = match self.policy(channel_type) {
+                        MergePolicy::Htp => (*current).max(*value),
+                        MergePolicy::Ltp => *value,
+                    };
+                }
+                None => merged.push((*fixture_id, channel_type.clone(), *value)),
+            }
+        }
+        merged
+    }
+
+    /// Put the merged values on the fixtures, noting every source's value in `trace` with
+    /// the rule it was merged by
+    pub fn render(&self, fixtures: &mut [Fixture], trace: &mut ContributionTrace) {
+        for (source, fixture_id, channel_type, value) in &self.writes {
+            trace.propose_as(
+                *fixture_id,
+                channel_type,
+                *value,
+                source.clone(),
+                self.policy(channel_type).rule(),
+            );
+        }
+        let merged = self.merged();
+        for (fixture_id, channel_type, value) in &merged {
+            if let Some(fixture) = fixtures.iter_mut().find(|f| f.id == *fixture_id) {
+                fixture.set_channel_value(channel_type, *value);
+            }
+        }
+        for (fixture_id, channel_type, level) in &merged {
+            if *channel_type != ChannelType::Dimmer {
+                continue;
+            }
+            let Some(fixture) = fixtures.iter_mut().find(|f| f.id == *fixture_id) else {
+                continue;
+            };
+            if fixture.channel_value(&ChannelType::Dimmer).is_some() {
+                continue;
+            }
+            // Only colors written this frame are scaled, so the scaling never compounds
+            for (id, color, value) in &merged {
+                if id == fixture_id && is_intensity(fixture, color) {
+                    let scaled = (*value as u32 * *level as u32 + 127) / 255;
+                    fixture.set_channel_value(color, scaled as u8);
+                }
+            }
+        }
+        trace.snapshot(fixtures);
+    }
+}
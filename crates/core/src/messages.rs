@@ -1,10 +1,17 @@
+use std::collections::HashMap;
 use std::path::PathBuf;
+use std::time::Duration;
 
-use halo_fixtures::Fixture;
+use halo_fixtures::{Fixture, PanTilt};
 use serde::{Deserialize, Serialize};
 
 use crate::audio::device_enumerator::AudioDeviceInfo;
-use crate::{CueList, EffectType, MidiOverride, PlaybackState, RhythmState, Show, TimeCode};
+use crate::{
+    default_channel_smoothing, BeatGridEdit, ChannelSmoothing, CueList, CueListStatus, EffectType,
+    ExclusionConflict, FanMode, FixtureDescription, FrameDrift, LearnedTiming, MergePolicy,
+    MidiOverride, MirrorState, OutputKind, OverrideColor, OverrideFadePolicy, PlaybackState,
+    RhythmState, ScheduledEvent, Show, SourceValue, TimeCode, TimetableRule, Trigger, Variable,
+};
 
 /// Commands sent from UI to Console
 #[derive(Debug, Clone)]
@@ -21,12 +28,40 @@ pub enum ConsoleCommand {
     LoadShow {
         path: PathBuf,
     },
+    /// Load the show halo was started with, falling back to safe mode if it fails to load or
+    /// crashes loading
+    StartShow {
+        path: PathBuf,
+    },
     SaveShow,
     SaveShowAs {
         name: String,
         path: PathBuf,
     },
     ReloadShow,
+    /// Load a show into the workspace under `name`, ready to switch to
+    LoadShowAs {
+        name: String,
+        path: PathBuf,
+    },
+    /// Switch to a workspace show, releasing the running show over the transition
+    ActivateShow {
+        name: String,
+        transition_secs: f64,
+    },
+    /// Drop a show from the workspace
+    UnloadShow {
+        name: String,
+    },
+    QueryWorkspace,
+
+    // Redundancy
+    /// Playback state streamed from the primary, for a standby to mirror
+    MirrorPrimary {
+        state: MirrorState,
+    },
+    /// Unmute a standby's output and run the show from it
+    TakeOver,
 
     // Fixture management
     PatchFixture {
@@ -44,6 +79,11 @@ pub enum ConsoleCommand {
         universe: u8,
         address: u16,
     },
+    /// Run one of the fixture profile's control commands, e.g. lamp on or reset
+    SendFixtureCommand {
+        fixture_id: usize,
+        command: String,
+    },
     UpdateFixtureChannels {
         fixture_id: usize,
         channel_values: Vec<(String, u8)>,
@@ -106,6 +146,13 @@ pub enum ConsoleCommand {
         list_index: usize,
         cue_index: usize,
     },
+    /// Jump to a cue with its intensity scaled from 0.0 to 1.0, e.g. by the velocity of the
+    /// note that fired it. The stored cue is left as it is.
+    GoToCueScaled {
+        list_index: usize,
+        cue_index: usize,
+        intensity: f32,
+    },
     NextCue {
         list_index: usize,
     },
@@ -114,21 +161,51 @@ pub enum ConsoleCommand {
     },
     SelectNextCueList,
     SelectPreviousCueList,
+    /// Point Go and Back at a cue list by name. The playing list carries on until the next Go.
+    SelectCueList {
+        name: String,
+    },
+    /// Select a cue list and arm it to start from its first cue on the next Go
+    PreloadCueList {
+        name: String,
+    },
+    /// Learn a cue list's timing from the operator's Go presses, until `StopLearning`
+    StartLearning {
+        list_index: usize,
+    },
+    /// Stop learning. With `write` the learned times become the cues' follow times and the
+    /// show is saved.
+    StopLearning {
+        write: bool,
+    },
 
     // Playback control
     Play,
+    /// Go into the next cue once the operator has read its warning
+    ConfirmGo,
     Stop,
     Pause,
     Resume,
     SetPlaybackRate {
         rate: f64,
     },
+    /// Manual crossfade into the next cue, from 0.0 (current look) to 1.0 (next cue). With a
+    /// list, only while that list is the one playing.
+    SetCrossfade {
+        position: f32,
+        list_index: Option<usize>,
+    },
 
     // Tempo and timing
     SetBpm {
         bpm: f64,
     },
     TapTempo,
+    /// Line the metronome up with the music: halve or double the tempo, or renumber the
+    /// beats and bars
+    EditBeatGrid {
+        edit: BeatGridEdit,
+    },
     SetTimecode {
         timecode: TimeCode,
     },
@@ -147,6 +224,102 @@ pub enum ConsoleCommand {
     ProcessMidiMessage {
         message: Vec<u8>,
     },
+    /// An OSC message from an external controller, routed through the trigger map
+    ProcessOscMessage {
+        address: String,
+        args: Vec<f32>,
+    },
+
+    // Manual sources
+    /// Write an attribute from a manual source such as an OSC fader, over playback
+    SetManualValue {
+        source: String,
+        fixture_id: usize,
+        channel: String,
+        value: u8,
+    },
+    /// Fade everything a manual source has written back to playback
+    ReleaseManualSource {
+        source: String,
+    },
+
+    // Show clock
+    /// Start the clock scheduled events run against, restarting it if it's running
+    StartShowClock,
+    StopShowClock,
+    /// Add an event to the schedule. Late events fire or are skipped by their late policy.
+    ScheduleEvent {
+        event: ScheduledEvent,
+    },
+
+    // Grand master
+    /// Ramp the grand master to zero, leaving playback running underneath
+    FadeToBlack {
+        duration_secs: f64,
+    },
+    /// Ramp the grand master back to full, revealing whatever playback is doing now
+    FadeUp {
+        duration_secs: f64,
+    },
+    /// Set the grand master to `level`, from 0.0 to 1.0, at once
+    SetGrandMaster {
+        level: f32,
+    },
+    /// Black out every fixture at once, or release to the grand master level
+    SetBlackout {
+        active: bool,
+    },
+
+    // Show variables
+    /// Change one of the show's `$name` variables, keeping its sort. A cue picks the new
+    /// value up the next time it runs.
+    SetVariable {
+        name: String,
+        value: Variable,
+    },
+
+    // Emergency
+    /// Drive every fixture to full open white over everything else
+    FullOn,
+    ReleaseFullOn,
+
+    // Solo
+    /// Darken every fixture not listed, replacing any solo already active
+    Solo {
+        fixture_ids: Vec<usize>,
+    },
+    Unsolo,
+
+    // Disabled outputs
+    /// Freeze a fixture's output while playback carries on underneath
+    DisableFixture {
+        fixture_id: usize,
+    },
+    EnableFixture {
+        fixture_id: usize,
+    },
+    /// Freeze every fixture patched in a universe
+    DisableUniverse {
+        universe: u8,
+    },
+    EnableUniverse {
+        universe: u8,
+    },
+    QueryDisabledOutputs,
+
+    // Flash
+    /// Hold a cue's static values on top of everything else until released
+    FlashOn {
+        cue_name: String,
+    },
+    /// Hold a cue's look with its intensity scaled from 0.0 to 1.0
+    FlashOnScaled {
+        cue_name: String,
+        intensity: f32,
+    },
+    FlashOff {
+        cue_name: String,
+    },
 
     // Audio
     PlayAudio {
@@ -174,6 +347,14 @@ pub enum ConsoleCommand {
         fixture_ids: Vec<usize>,
         channel_type: String,
     },
+    /// Hold a running effect's color channels at one color, leaving the rest of it running
+    SetEffectColorOverride {
+        effect: String,
+        color: OverrideColor,
+    },
+    ClearEffectColorOverride {
+        effect: String,
+    },
 
     // Programmer
     SetProgrammerValue {
@@ -181,6 +362,29 @@ pub enum ConsoleCommand {
         channel: String,
         value: u8,
     },
+    /// Set one color on every listed fixture, calibrated for each fixture's profile
+    SetProgrammerColor {
+        fixture_ids: Vec<usize>,
+        red: u8,
+        green: u8,
+        blue: u8,
+    },
+    /// Put a gobo in the beam of every listed fixture with a gobo channel, counting from 0 for
+    /// open, shaking at `shake` from 0 for slow to 1 for fast if given
+    SetProgrammerGobo {
+        fixture_ids: Vec<usize>,
+        gobo: usize,
+        shake: Option<f64>,
+    },
+    /// Spread a channel across fixtures, ordered left to right by stage position when every
+    /// fixture has one and by selection order otherwise
+    FanProgrammerValues {
+        fixture_ids: Vec<usize>,
+        channel: String,
+        from: u8,
+        to: u8,
+        mode: FanMode,
+    },
     SetProgrammerPreviewMode {
         preview_mode: bool,
     },
@@ -240,11 +444,34 @@ pub enum ConsoleCommand {
     QueryCueLists,
     QueryCurrentCueListIndex,
     QueryCurrentCue,
+    /// Snapshot every cue list with the next few cue names
+    QueryCueStatus {
+        pending: usize,
+    },
     QueryPlaybackState,
     QueryRhythmState,
     QueryShow,
     QueryLinkState,
     QueryFixtureLibrary,
+    /// Describe a patched fixture by name or ID, with its absolute channel addresses
+    DescribeFixture {
+        name: String,
+    },
+    /// What each layer of the last frame put on a fixture, by name or alias
+    QueryContributions {
+        name: String,
+    },
+    /// Take a universe's DMX, e.g. captured from the house console, as the tracked look
+    ImportDmxSnapshot {
+        universe: u8,
+        data: Vec<u8>,
+    },
+    /// Capture everything the DMX output sends to `path`, or to a timestamped file in the
+    /// working directory
+    StartOutputCapture {
+        path: Option<PathBuf>,
+    },
+    StopOutputCapture,
 }
 
 /// Settings configuration
@@ -266,6 +493,9 @@ pub struct Settings {
     pub midi_channel: u8,
 
     // Output settings (DMX/Art-Net)
+    /// How DMX goes out: Art-Net, sACN, or nowhere for a dry run. Read at startup.
+    #[serde(default)]
+    pub output: OutputKind,
     pub dmx_enabled: bool,
     pub dmx_broadcast: bool,
     pub dmx_source_ip: String,
@@ -273,6 +503,37 @@ pub struct Settings {
     pub dmx_port: u16,
     pub wled_enabled: bool,
     pub wled_ip: String,
+    /// Milliseconds the DMX link takes to reach the fixtures, so beat-synced effects can go out
+    /// that much early and still land on the beat
+    #[serde(default)]
+    pub output_latency_ms: f32,
+    /// Latency for universes on a different link, over `output_latency_ms`, keyed by universe
+    #[serde(default)]
+    pub universe_latency_ms: HashMap<u8, f32>,
+    /// Send all 512 channels of every universe, for receivers that won't take shorter frames
+    #[serde(default)]
+    pub full_universe_frames: bool,
+    /// Seconds between resends of a universe that hasn't changed, so nodes don't time out.
+    /// Zero sends only changes.
+    #[serde(default = "default_dmx_keep_alive_secs")]
+    pub dmx_keep_alive_secs: f32,
+    /// Frames a second sent to each universe, from 1 to the 44 DMX allows
+    #[serde(default = "default_dmx_rate_hz")]
+    pub dmx_rate_hz: f32,
+    /// Rate for universes on a slower link, e.g. wireless DMX, over `dmx_rate_hz`, keyed by
+    /// universe
+    #[serde(default)]
+    pub universe_rate_hz: HashMap<u8, f32>,
+    /// Run the render loop and the DMX module on OS threads of their own, for machines such
+    /// as a Pi where scheduling jitter makes strobes stutter. Read at startup.
+    #[serde(default)]
+    pub low_latency: bool,
+    /// Niceness for those threads, -20 to 19, on Linux. Below 0 needs root or CAP_SYS_NICE.
+    #[serde(default)]
+    pub thread_niceness: Option<i32>,
+    /// Milliseconds a render frame may take before it's logged as slow, or never
+    #[serde(default)]
+    pub slow_frame_ms: Option<f32>,
 
     // Pixel engine settings
     pub pixel_engine_enabled: bool,
@@ -281,6 +542,64 @@ pub struct Settings {
 
     // Fixture settings
     pub enable_pan_tilt_limits: bool,
+    /// Fastest strobe allowed, in flashes per second, for venues with photosensitivity limits
+    #[serde(default)]
+    pub max_strobe_hz: Option<f32>,
+    /// Hold every strobe channel open and stop hard-edged effects on intensity
+    #[serde(default)]
+    pub no_strobe: bool,
+    /// Ramp sudden changes on the channel types in `channel_smoothing`
+    #[serde(default)]
+    pub smooth_channels: bool,
+    /// How fast each channel type may move when smoothing is on
+    #[serde(default = "default_channel_smoothing")]
+    pub channel_smoothing: Vec<ChannelSmoothing>,
+    /// Freeze disabled fixtures at their last values instead of zero
+    #[serde(default)]
+    pub hold_disabled_fixtures: bool,
+    /// Accept cues that reference unpatched fixtures, dropping those references, rather than
+    /// rejecting them
+    #[serde(default)]
+    pub lenient_fixture_references: bool,
+    /// Seconds a manual source can go quiet before its values are released, or never
+    #[serde(default)]
+    pub manual_release_secs: Option<f32>,
+    /// Seconds a released manual value takes to fade back to playback
+    #[serde(default = "default_manual_release_fade_secs")]
+    pub manual_release_fade_secs: f32,
+    /// Whether cue fades hidden by a blackout or full on keep going, pause or finish
+    #[serde(default)]
+    pub override_fade_policy: OverrideFadePolicy,
+    /// How cues and effects putting values on the same intensity channel combine, latest
+    /// unless set to highest. Other channels always take the latest value.
+    #[serde(default)]
+    pub intensity_merge: MergePolicy,
+
+    /// Oldest saved playback state `--resume` will pick up from, in seconds
+    #[serde(default = "default_resume_max_age_secs")]
+    pub resume_max_age_secs: u64,
+
+    /// Most universes this machine is expected to drive, warned about at startup
+    #[serde(default)]
+    pub max_universes: Option<u8>,
+    /// Most fixtures this machine is expected to drive, warned about at startup
+    #[serde(default)]
+    pub max_fixtures: Option<usize>,
+
+    // Venue settings
+    /// Pan and tilt for each named position, keyed by preset name then fixture name
+    #[serde(default)]
+    pub position_presets: HashMap<String, HashMap<String, PanTilt>>,
+
+    // Trigger settings
+    /// MIDI and OSC events mapped to cue list actions
+    #[serde(default)]
+    pub triggers: Vec<Trigger>,
+
+    // Timetable settings
+    /// Time of day rules for running the venue with no operator
+    #[serde(default)]
+    pub timetable: Vec<TimetableRule>,
 }
 
 impl Default for Settings {
@@ -302,6 +621,7 @@ impl Default for Settings {
             midi_channel: 1,
 
             // Output defaults
+            output: OutputKind::ArtNet,
             dmx_enabled: true,
             dmx_broadcast: false,
             dmx_source_ip: "192.168.1.100".to_string(),
@@ -309,6 +629,15 @@ impl Default for Settings {
             dmx_port: 6454,
             wled_enabled: false,
             wled_ip: "192.168.1.50".to_string(),
+            output_latency_ms: 0.0,
+            universe_latency_ms: HashMap::new(),
+            full_universe_frames: false,
+            dmx_keep_alive_secs: default_dmx_keep_alive_secs(),
+            dmx_rate_hz: default_dmx_rate_hz(),
+            universe_rate_hz: HashMap::new(),
+            low_latency: false,
+            thread_niceness: None,
+            slow_frame_ms: None,
 
             // Pixel engine defaults
             pixel_engine_enabled: false,
@@ -317,10 +646,63 @@ impl Default for Settings {
 
             // Fixture defaults
             enable_pan_tilt_limits: true,
+            max_strobe_hz: None,
+            no_strobe: false,
+            smooth_channels: false,
+            channel_smoothing: default_channel_smoothing(),
+            hold_disabled_fixtures: false,
+            lenient_fixture_references: false,
+            manual_release_secs: None,
+            manual_release_fade_secs: default_manual_release_fade_secs(),
+            override_fade_policy: OverrideFadePolicy::default(),
+            intensity_merge: MergePolicy::default(),
+            resume_max_age_secs: default_resume_max_age_secs(),
+            max_universes: None,
+            max_fixtures: None,
+
+            // Venue defaults
+            position_presets: HashMap::new(),
+
+            // Trigger defaults
+            triggers: Vec::new(),
+            timetable: Vec::new(),
         }
     }
 }
 
+impl Settings {
+    /// How often unchanged universes are resent, if at all
+    pub fn dmx_keep_alive(&self) -> Option<Duration> {
+        (self.dmx_keep_alive_secs > 0.0).then(|| Duration::from_secs_f32(self.dmx_keep_alive_secs))
+    }
+
+    /// How long output to `universe` takes to reach the fixtures
+    pub fn output_latency(&self, universe: u8) -> Duration {
+        let ms = self
+            .universe_latency_ms
+            .get(&universe)
+            .copied()
+            .unwrap_or(self.output_latency_ms);
+        Duration::from_micros((ms.max(0.0) * 1000.0).round() as u64)
+    }
+}
+
+fn default_manual_release_fade_secs() -> f32 {
+    1.0
+}
+
+fn default_dmx_keep_alive_secs() -> f32 {
+    1.0
+}
+
+fn default_dmx_rate_hz() -> f32 {
+    44.0
+}
+
+fn default_resume_max_age_secs() -> u64 {
+    30 * 60
+}
+
 /// Events sent from Console to UI
 #[derive(Debug, Clone)]
 pub enum ConsoleEvent {
@@ -364,6 +746,21 @@ pub enum ConsoleEvent {
     ShowCreated {
         name: String,
     },
+    /// Running without a show, with only manual control, and why
+    SafeModeEntered {
+        reason: String,
+    },
+    /// Whether the console is a muted standby, and why it won't mirror the primary if it
+    /// refused to
+    StandbyChanged {
+        standby: bool,
+        refused: Option<String>,
+    },
+    /// The shows loaded in the workspace, and which is running
+    WorkspaceUpdated {
+        shows: Vec<String>,
+        active: Option<String>,
+    },
 
     // Fixture events
     FixturePatched {
@@ -377,6 +774,10 @@ pub enum ConsoleEvent {
         fixture_id: usize,
         fixture: Fixture,
     },
+    FixtureCommandStarted {
+        fixture_id: usize,
+        command: String,
+    },
     FixtureValuesChanged {
         fixture_id: usize,
         values: Vec<(String, u8)>,
@@ -386,17 +787,41 @@ pub enum ConsoleEvent {
     CueStarted {
         list_index: usize,
         cue_index: usize,
+        notes: String,
+        warning: String,
+    },
+    /// Go is waiting for the operator to confirm the next cue's warning with `ConfirmGo`
+    CueWarning {
+        list_index: usize,
+        cue_index: usize,
+        warning: String,
+    },
+    /// A cue was kept off an exclusion group another cue list holds
+    ExclusionConflict {
+        conflict: ExclusionConflict,
+        warning: String,
     },
     CueStopped {
         list_index: usize,
     },
+    /// A cue gave way to another, with the frame that ran longest past a render tick while
+    /// it played, if any did
     CueCompleted {
         list_index: usize,
         cue_index: usize,
+        worst_frame: Option<FrameDrift>,
     },
     CueListCompleted {
         list_index: usize,
     },
+    LearningStarted {
+        list_index: usize,
+    },
+    /// What was learned from a run of a cue list, once learning stops
+    TimingLearned {
+        list_index: usize,
+        timings: Vec<LearnedTiming>,
+    },
     CueListSelected {
         list_index: usize,
     },
@@ -404,6 +829,16 @@ pub enum ConsoleEvent {
         cue_index: usize,
         progress: f32,
     },
+    /// The running cue's chase step and how many steps it has, `None` when no chase runs
+    ChaseStepChanged {
+        step: Option<(usize, usize)>,
+    },
+    CrossfadeChanged {
+        position: f32,
+    },
+    CueStatus {
+        lists: Vec<CueListStatus>,
+    },
 
     // MIDI events
     MidiOverrideAdded {
@@ -416,6 +851,45 @@ pub enum ConsoleEvent {
         message: Vec<u8>,
     },
 
+    // Flash events
+    FlashesChanged {
+        active: Vec<String>,
+    },
+
+    // Emergency events
+    FullOnChanged {
+        active: bool,
+    },
+
+    // Solo events
+    SoloChanged {
+        fixture_ids: Option<Vec<usize>>,
+    },
+
+    // Disabled output events
+    DisabledOutputsChanged {
+        fixture_ids: Vec<usize>,
+        universes: Vec<u8>,
+    },
+
+    // Grand master events
+    /// A grand master fade started, heading for `level` from 0.0 to 1.0
+    GrandMasterChanged {
+        level: f32,
+    },
+    BlackoutChanged {
+        active: bool,
+    },
+    VariableChanged {
+        name: String,
+        value: Variable,
+    },
+
+    // Show clock events
+    ShowClockChanged {
+        running: bool,
+    },
+
     // Audio events
     AudioStarted {
         file_path: String,
@@ -485,7 +959,28 @@ pub enum ConsoleEvent {
     FixtureLibraryList {
         profiles: Vec<(String, String)>, // (id, display_name)
     },
+    FixtureDescribed {
+        description: FixtureDescription,
+    },
+    /// What each layer of the last frame put on a fixture, in render order
+    FixtureContributions {
+        name: String,
+        values: Vec<SourceValue>,
+    },
+    /// A DMX snapshot was taken as the tracked look, with the addresses nothing is patched at
+    DmxSnapshotImported {
+        universe: u8,
+        values: usize,
+        skipped: Vec<u16>,
+    },
+    /// Where the output is being captured, or `None` once a capture stops
+    OutputCapture {
+        path: Option<PathBuf>,
+    },
     PixelDataUpdated {
         pixel_data: Vec<(usize, Vec<(u8, u8, u8)>)>, // (fixture_id, pixels_rgb)
     },
+    FixtureValuesUpdated {
+        values: Vec<(usize, Vec<u8>)>, // (fixture_id, channel values)
+    },
 }
@@ -4,6 +4,8 @@ use crate::StaticValue;
 pub enum MidiAction {
     StaticValues(Vec<StaticValue>),
     TriggerCue(String), // Cue name to trigger
+    Flash(String),      // Cue name to hold as a flash while the note is down
+    FullOn,             // Toggle the emergency full on
 }
 
 // Represent a MIDI override (could be from keys, pads, or controls)
@@ -20,3 +22,20 @@ pub enum MidiMessage {
     ControlChange(u8, u8), // (controller number, value)
     Clock,                 // MIDI clock messages
 }
+
+impl MidiMessage {
+    /// Parse a raw MIDI message, ignoring anything we don't handle
+    pub fn from_bytes(message: &[u8]) -> Option<Self> {
+        match message {
+            [0xF8, ..] => Some(MidiMessage::Clock),
+            [status, note, velocity, ..] => match status & 0xF0 {
+                // Note On with zero velocity is a Note Off
+                0x90 if *velocity > 0 => Some(MidiMessage::NoteOn(*note, *velocity)),
+                0x90 | 0x80 => Some(MidiMessage::NoteOff(*note)),
+                0xB0 => Some(MidiMessage::ControlChange(*note, *velocity)),
+                _ => None,
+            },
+            _ => None,
+        }
+    }
+}
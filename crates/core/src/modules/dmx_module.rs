@@ -1,42 +1,194 @@
-use std::collections::HashMap;
+use std::collections::{BTreeMap, HashMap, HashSet};
+use std::sync::atomic::{AtomicU64, Ordering};
+use std::sync::Arc;
 
 use async_trait::async_trait;
 use tokio::sync::mpsc;
-use tokio::time::{interval, Duration, Instant};
+use tokio::time::{sleep_until, Duration, Instant};
 
 use super::traits::{AsyncModule, ModuleEvent, ModuleId, ModuleMessage};
-use crate::artnet::artnet::ArtNet;
 use crate::artnet::network_config::NetworkConfig;
+use crate::capture::OutputCapture;
+use crate::output::{ArtNetDriver, OutputDriver};
+use crate::realtime::TickHistogram;
 
+/// The fastest DMX refreshes a universe, and the slowest rate the module will send at
+const MAX_FPS: f64 = 44.0;
+const MIN_FPS: f64 = 1.0;
+
+/// Universe frames the DMX module has sent and skipped, and how long its ticks took,
+/// readable while it runs
+#[derive(Debug, Default)]
+pub struct OutputStats {
+    sent: AtomicU64,
+    skipped: AtomicU64,
+    ticks: TickHistogram,
+}
+
+impl OutputStats {
+    /// Universe frames that went out, changed or resent to keep the node alive
+    pub fn sent(&self) -> u64 {
+        self.sent.load(Ordering::Relaxed)
+    }
+
+    /// Universe frames held back because nothing in them had changed
+    pub fn skipped(&self) -> u64 {
+        self.skipped.load(Ordering::Relaxed)
+    }
+
+    /// Time spent sending each tick, from waking to the last universe going out
+    pub fn ticks(&self) -> &TickHistogram {
+        &self.ticks
+    }
+}
+
+/// Whether an output error means the connection itself has gone, rather than one universe
+/// failing, e.g. one with no destination routed
+fn lost_connection(error: &anyhow::Error) -> bool {
+    error.downcast_ref::<std::io::Error>().is_some()
+}
+
+/// Sends universes through an [`OutputDriver`] at a steady rate.
+///
+/// Each universe has its own tick, at the module's rate or a slower one set for it, e.g. for a
+/// wireless link that can't keep up. Each tick, only universes whose data changed since they
+/// were last sent go out. Unchanged ones are resent once the keep-alive has passed, as nodes
+/// drop to their fail mode when a universe stops arriving.
+///
+/// An output that won't open, or whose connection fails mid-show, is closed and opened again
+/// with a backoff that doubles up to a limit. Frames keep arriving in the meantime, and the
+/// latest of every universe goes out as soon as it's back.
 pub struct DmxModule {
-    artnet_connections: Vec<Option<ArtNet>>, // Multiple ArtNet instances
-    network_config: NetworkConfig,
+    driver: Box<dyn OutputDriver>,
+    connected: bool,
+    /// While the driver is closed, when to next try to open it
+    reconnect_at: Option<Instant>,
+    /// How long to wait after the next failure
+    reconnect_wait: Duration,
+    /// The first wait and the most it doubles to
+    reconnect_backoff: (Duration, Duration),
     last_frame_time: Option<Instant>,
     frames_sent: u64,
     target_fps: f64,
+    /// Rates for universes that don't go out at `target_fps`
+    universe_fps: HashMap<u8, f64>,
+    keep_alive: Option<Duration>,
+    stats: Arc<OutputStats>,
+    capture: Option<OutputCapture>,
     status: HashMap<String, String>,
 }
 
 impl DmxModule {
+    /// Sends over Art-Net to the destinations in `network_config`
     pub fn new(network_config: NetworkConfig) -> Self {
-        let num_destinations = network_config.destinations.len();
-        let mut artnet_connections = Vec::new();
-        for _ in 0..num_destinations {
-            artnet_connections.push(None);
-        }
+        Self::with_driver(Box::new(ArtNetDriver::new(network_config)))
+    }
 
+    pub fn with_driver(driver: Box<dyn OutputDriver>) -> Self {
         Self {
-            artnet_connections,
-            network_config,
+            driver,
+            connected: false,
+            reconnect_at: None,
+            reconnect_wait: Duration::from_millis(250),
+            reconnect_backoff: (Duration::from_millis(250), Duration::from_secs(10)),
             last_frame_time: None,
             frames_sent: 0,
-            target_fps: 44.0, // DMX standard 44Hz
+            target_fps: MAX_FPS, // DMX standard 44Hz
+            universe_fps: HashMap::new(),
+            keep_alive: Some(Duration::from_secs(1)),
+            stats: Arc::new(OutputStats::default()),
+            capture: None,
             status: HashMap::new(),
         }
     }
 
+    /// Frames a second for every universe without a rate of its own, held to what DMX allows
     pub fn set_target_fps(&mut self, fps: f64) {
-        self.target_fps = fps;
+        self.target_fps = fps.clamp(MIN_FPS, MAX_FPS);
+    }
+
+    /// Frames a second for `universe` alone, held to what DMX allows
+    pub fn set_universe_fps(&mut self, universe: u8, fps: f64) {
+        self.universe_fps
+            .insert(universe, fps.clamp(MIN_FPS, MAX_FPS));
+    }
+
+    /// Time between frames of `universe`
+    fn frame_interval(&self, universe: u8) -> Duration {
+        let fps = self
+            .universe_fps
+            .get(&universe)
+            .copied()
+            .unwrap_or(self.target_fps);
+        Duration::from_secs_f64(1.0 / fps)
+    }
+
+    /// How often to resend a universe that hasn't changed. `None` sends only changes.
+    pub fn set_keep_alive(&mut self, keep_alive: Option<Duration>) {
+        self.keep_alive = keep_alive;
+    }
+
+    /// How long to wait before the first attempt to reopen a lost output, and the most the
+    /// wait doubles to
+    pub fn set_reconnect_backoff(&mut self, first: Duration, max: Duration) {
+        self.reconnect_backoff = (first, max);
+        self.reconnect_wait = first;
+    }
+
+    /// Write every universe that goes out to `capture` while it's capturing
+    pub fn set_capture(&mut self, capture: OutputCapture) {
+        self.capture = Some(capture);
+    }
+
+    /// Counts of sent and skipped universe frames, shared with the running module
+    pub fn stats(&self) -> Arc<OutputStats> {
+        Arc::clone(&self.stats)
+    }
+
+    /// Give up on the driver for now and try it again after the backoff, which doubles each
+    /// time until a frame gets through
+    fn disconnect(&mut self, now: Instant) {
+        if self.connected {
+            if let Err(e) = self.driver.close() {
+                log::warn!("Couldn't close {} output: {}", self.driver.name(), e);
+            }
+        }
+        log::warn!(
+            "{} output is down, trying again in {:?}",
+            self.driver.name(),
+            self.reconnect_wait
+        );
+        self.connected = false;
+        self.reconnect_at = Some(now + self.reconnect_wait);
+        let (_, max) = self.reconnect_backoff;
+        self.reconnect_wait = (self.reconnect_wait * 2).min(max);
+        self.status
+            .insert("status".to_string(), "reconnecting".to_string());
+    }
+
+    /// Open the driver if it's due another try. Returns whether it's open.
+    fn reconnect(&mut self, now: Instant) -> bool {
+        if self.connected {
+            return true;
+        }
+        if self.reconnect_at.is_some_and(|at| now < at) {
+            return false;
+        }
+        match self.driver.open() {
+            Ok(()) => {
+                log::info!("{} output reopened", self.driver.name());
+                self.connected = true;
+                self.reconnect_at = None;
+                self.status
+                    .insert("status".to_string(), "running".to_string());
+                true
+            }
+            Err(e) => {
+                log::warn!("Couldn't reopen {} output: {}", self.driver.name(), e);
+                self.disconnect(now);
+                false
+            }
+        }
     }
 }
 
@@ -47,38 +199,28 @@ impl AsyncModule for DmxModule {
     }
 
     async fn initialize(&mut self) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
-        log::info!(
-            "Initializing DMX module with {} destinations",
-            self.network_config.destinations.len()
-        );
-
-        // Initialize ArtNet connections for each destination
-        for (i, destination) in self.network_config.destinations.iter().enumerate() {
-            log::info!(
-                "Setting up ArtNet connection {} for destination: {}",
-                i,
-                destination.name
-            );
+        log::info!("Initializing DMX module with {} output", self.driver.name());
+        self.status
+            .insert("protocol".to_string(), self.driver.name().to_string());
+        self.status.extend(self.driver.status());
 
-            let artnet = ArtNet::new(destination.mode.clone())?;
-            self.artnet_connections[i] = Some(artnet);
+        // The console still starts without its output, which is retried once running
+        match self.driver.open() {
+            Ok(()) => {
+                self.connected = true;
+                self.status
+                    .insert("status".to_string(), "initialized".to_string());
+            }
+            Err(e) => {
+                log::error!(
+                    "Couldn't open {} output, retrying in the background: {}",
+                    self.driver.name(),
+                    e
+                );
+                self.disconnect(Instant::now());
+            }
         }
 
-        self.status.insert(
-            "mode".to_string(),
-            self.network_config.get_mode_string().to_string(),
-        );
-        self.status.insert(
-            "destinations".to_string(),
-            format!("{}", self.network_config.destinations.len()),
-        );
-        self.status.insert(
-            "destination_info".to_string(),
-            self.network_config.get_destination(),
-        );
-        self.status
-            .insert("status".to_string(), "initialized".to_string());
-
         Ok(())
     }
 
@@ -87,42 +229,52 @@ impl AsyncModule for DmxModule {
         mut rx: mpsc::Receiver<ModuleEvent>,
         tx: mpsc::Sender<ModuleMessage>,
     ) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
-        // Validate all ArtNet connections are initialized
-        for (i, conn) in self.artnet_connections.iter().enumerate() {
-            if conn.is_none() {
-                return Err(format!("ArtNet connection {} not initialized", i).into());
-            }
-        }
-
-        // Create interval for DMX output timing
         let frame_duration = Duration::from_secs_f64(1.0 / self.target_fps);
-        let mut frame_interval = interval(frame_duration);
 
         let mut last_dmx_data: HashMap<u8, Vec<u8>> = HashMap::new();
+        // Universes with data that hasn't gone out yet, and when each last went out
+        let mut changed: HashSet<u8> = HashSet::new();
+        let mut last_sent: HashMap<u8, Instant> = HashMap::new();
+        // When each universe's next tick is
+        let mut due: HashMap<u8, Instant> = HashMap::new();
+        let mut last_status = Instant::now();
         let mut shutdown = false;
 
         log::info!(
-            "DMX module started with {} destinations, running at {}Hz",
-            self.artnet_connections.len(),
+            "DMX module started with {} output, running at {}Hz",
+            self.driver.name(),
             self.target_fps
         );
 
         // Send initial status
         let _ = tx
             .send(ModuleMessage::Status(format!(
-                "DMX module running at {}Hz with {} destinations",
+                "DMX module running at {}Hz with {} output",
                 self.target_fps,
-                self.artnet_connections.len()
+                self.driver.name()
             )))
             .await;
 
         while !shutdown {
+            // Wake for the next universe due, or to retry a lost output
+            let wake = if self.connected {
+                due.values().min().copied()
+            } else {
+                self.reconnect_at
+            }
+            .unwrap_or_else(|| Instant::now() + frame_duration);
+
             tokio::select! {
                 // Handle incoming events
                 Some(event) = rx.recv() => {
                     match event {
                         ModuleEvent::DmxOutput(universe, data) => {
-                            last_dmx_data.insert(universe, data);
+                            if last_dmx_data.get(&universe) != Some(&data) {
+                                last_dmx_data.insert(universe, data);
+                                changed.insert(universe);
+                            }
+                            // A universe's first frame goes out straight away
+                            due.entry(universe).or_insert_with(Instant::now);
                         }
                         ModuleEvent::Shutdown => {
                             log::info!("DMX module received shutdown signal");
@@ -135,43 +287,107 @@ impl AsyncModule for DmxModule {
                     }
                 }
 
-                // Send DMX data at regular intervals
-                _ = frame_interval.tick() => {
+                // Send the universes whose tick it is
+                _ = sleep_until(wake) => {
                     let now = Instant::now();
+                    // Work done, in real time whatever the runtime's clock says
+                    let tick_started = std::time::Instant::now();
+
+                    // Hold everything while the output is down, and send it all once it's back
+                    let was_connected = self.connected;
+                    if !self.reconnect(now) {
+                        continue;
+                    }
+                    if !was_connected {
+                        changed.extend(last_dmx_data.keys());
+                        due.values_mut().for_each(|at| *at = now);
+                    }
 
-                    // Send each universe to its routed destination
                     for (universe, data) in &last_dmx_data {
-                        if let Some(dest_index) = self.network_config.get_destination_for_universe(*universe) {
-                            if let Some(Some(artnet)) = self.artnet_connections.get(dest_index) {
-                                artnet.send_data(*universe, data.clone());
-                            } else {
-                                log::warn!("No ArtNet connection found for destination index {}", dest_index);
+                        let at = due.entry(*universe).or_insert(now);
+                        if *at > now {
+                            continue;
+                        }
+                        // Ticks keep to the universe's rate, skipping any missed while busy
+                        let interval = self.frame_interval(*universe);
+                        *at += interval;
+                        if *at <= now {
+                            *at = now + interval;
+                        }
+
+                        let stale = self.keep_alive.is_some_and(|keep_alive| {
+                            last_sent
+                                .get(universe)
+                                .is_none_or(|sent| now.duration_since(*sent) >= keep_alive)
+                        });
+                        if !changed.remove(universe) && !stale {
+                            self.stats.skipped.fetch_add(1, Ordering::Relaxed);
+                            continue;
+                        }
+                        if let Err(e) = self.driver.send_universe(*universe, data) {
+                            log::warn!("{}: {}", self.driver.name(), e);
+                            if lost_connection(&e) {
+                                changed.extend(last_dmx_data.keys());
+                                self.disconnect(now);
+                                break;
                             }
                         } else {
-                            log::warn!("No destination routing configured for universe {}", universe);
+                            self.reconnect_wait = self.reconnect_backoff.0;
+                            if let Some(capture) = &self.capture {
+                                capture.record(now.into_std(), *universe, data, || {
+                                    let mut universes: Vec<u8> =
+                                        last_dmx_data.keys().copied().collect();
+                                    universes.sort_unstable();
+                                    OutputCapture::header(
+                                        self.target_fps,
+                                        self.universe_fps
+                                            .iter()
+                                            .map(|(u, fps)| (*u, *fps))
+                                            .collect::<BTreeMap<_, _>>(),
+                                        universes,
+                                    )
+                                });
+                            }
                         }
+                        last_sent.insert(*universe, now);
+                        self.stats.sent.fetch_add(1, Ordering::Relaxed);
                     }
 
                     self.frames_sent += 1;
                     self.last_frame_time = Some(now);
+                    self.stats.ticks.record(tick_started.elapsed());
 
-                    // Update status periodically
-                    if self.frames_sent % (self.target_fps as u64 * 5) == 0 { // Every 5 seconds
+                    // Update status every 5 seconds
+                    if now.duration_since(last_status) >= Duration::from_secs(5) {
+                        last_status = now;
                         self.status.insert("frames_sent".to_string(), self.frames_sent.to_string());
                         self.status.insert("fps".to_string(), format!("{:.1}", self.target_fps));
                         self.status.insert("universes".to_string(), last_dmx_data.len().to_string());
+                        self.status.insert("universes_sent".to_string(), self.stats.sent().to_string());
+                        self.status.insert("universes_skipped".to_string(), self.stats.skipped().to_string());
+                        self.status.insert("tick_max_ms".to_string(), format!("{:.2}", self.stats.ticks.max().as_secs_f64() * 1000.0));
 
                         let _ = tx.send(ModuleMessage::Status(format!(
-                            "DMX: {} frames sent, {} universes active across {} destinations",
+                            "DMX: {} frames, {} universes active over {}, {} universe frames sent and {} unchanged skipped, {}",
                             self.frames_sent,
                             last_dmx_data.len(),
-                            self.artnet_connections.len()
+                            self.driver.name(),
+                            self.stats.sent(),
+                            self.stats.skipped(),
+                            self.stats.ticks
                         ))).await;
                     }
                 }
             }
         }
 
+        // The module manager doesn't call shutdown once run has taken the module, so the
+        // driver is closed here, if it ever opened
+        if self.connected {
+            if let Err(e) = self.driver.close() {
+                log::warn!("Couldn't close {} output: {}", self.driver.name(), e);
+            }
+        }
         log::info!(
             "DMX module shutting down after sending {} frames",
             self.frames_sent
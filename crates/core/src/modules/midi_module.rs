@@ -50,30 +50,13 @@ impl MidiModule {
                 &in_port,
                 "async-midi-input",
                 move |_timestamp, message, _| {
-                    if message.len() >= 3 {
-                        let midi_msg = match message[0] & 0xF0 {
-                            0xF8 => Some(MidiMessage::Clock),
-                            0x90 => {
-                                // Note On
-                                if message[2] > 0 {
-                                    Some(MidiMessage::NoteOn(message[1], message[2]))
-                                } else {
-                                    Some(MidiMessage::NoteOff(message[1]))
-                                }
-                            }
-                            0x80 => Some(MidiMessage::NoteOff(message[1])),
-                            0xB0 => Some(MidiMessage::ControlChange(message[1], message[2])),
-                            _ => None,
-                        };
-
-                        if let Some(midi_msg) = midi_msg {
-                            let event = ModuleEvent::MidiInput(midi_msg);
-
-                            // Since we're in a callback, we need to use try_send
-                            // to avoid blocking if the channel is full
-                            if let Err(e) = tx_clone.try_send(ModuleMessage::Event(event)) {
-                                log::warn!("Failed to send MIDI message: {}", e);
-                            }
+                    if let Some(midi_msg) = MidiMessage::from_bytes(message) {
+                        let event = ModuleEvent::MidiInput(midi_msg);
+
+                        // Since we're in a callback, we need to use try_send
+                        // to avoid blocking if the channel is full
+                        if let Err(e) = tx_clone.try_send(ModuleMessage::Event(event)) {
+                            log::warn!("Failed to send MIDI message: {}", e);
                         }
                     }
                 },
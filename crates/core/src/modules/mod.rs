@@ -2,13 +2,15 @@ pub mod audio_module;
 pub mod dmx_module;
 pub mod midi_module;
 pub mod module_manager;
+pub mod null_dmx_module;
 pub mod smpte_module;
 pub mod traits;
 
 // Re-export for convenience
 pub use audio_module::AudioModule;
-pub use dmx_module::DmxModule;
+pub use dmx_module::{DmxModule, OutputStats};
 pub use midi_module::MidiModule;
 pub use module_manager::ModuleManager;
+pub use null_dmx_module::NullDmxModule;
 pub use smpte_module::SmpteModule;
 pub use traits::{AsyncModule, ModuleEvent, ModuleId, ModuleMessage};
@@ -1,9 +1,11 @@
 use std::collections::HashMap;
 
+use tokio::runtime::Handle;
 use tokio::sync::mpsc;
 use tokio::task::JoinHandle;
 
 use super::traits::{AsyncModule, ModuleEvent, ModuleId, ModuleMessage};
+use crate::realtime::spawn_pinned;
 
 pub struct ModuleManager {
     modules: HashMap<ModuleId, Box<dyn AsyncModule>>,
@@ -11,6 +13,9 @@ pub struct ModuleManager {
     module_senders: HashMap<ModuleId, mpsc::Sender<ModuleEvent>>,
     message_receiver: Option<mpsc::Receiver<ModuleMessage>>,
     message_sender: mpsc::Sender<ModuleMessage>,
+    /// With the DMX module on a thread of its own, its niceness and the runtime the other
+    /// modules run on
+    pinned_output: Option<(Option<i32>, Handle)>,
     running: bool,
 }
 
@@ -24,6 +29,7 @@ impl ModuleManager {
             module_senders: HashMap::new(),
             message_receiver: Some(message_receiver),
             message_sender,
+            pinned_output: None,
             running: false,
         }
     }
@@ -34,6 +40,12 @@ impl ModuleManager {
         self.modules.insert(id, module);
     }
 
+    /// Run the DMX module on an OS thread of its own, at `niceness` if given, and the others on
+    /// `runtime` rather than wherever the manager is started from, e.g. a render thread
+    pub fn pin_output(&mut self, niceness: Option<i32>, runtime: Handle) {
+        self.pinned_output = Some((niceness, runtime));
+    }
+
     /// Initialize all registered modules
     pub async fn initialize(&mut self) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
         for (id, module) in &mut self.modules {
@@ -63,7 +75,7 @@ impl ModuleManager {
             let message_tx = self.message_sender.clone();
             let module_id = id.clone();
 
-            let handle = tokio::spawn(async move {
+            let run = async move {
                 if let Err(e) = module.run(event_rx, message_tx.clone()).await {
                     let _ = message_tx
                         .send(ModuleMessage::Error(format!(
@@ -72,7 +84,15 @@ impl ModuleManager {
                         )))
                         .await;
                 }
-            });
+            };
+            let handle = match &self.pinned_output {
+                Some((niceness, _)) if id == ModuleId::Dmx => {
+                    spawn_pinned("halo-dmx", *niceness, run)
+                        .map_err(|e| format!("Couldn't start a thread for the DMX module: {e}"))?
+                }
+                Some((_, runtime)) => runtime.spawn(run),
+                None => tokio::spawn(run),
+            };
 
             self.module_handles.insert(id.clone(), handle);
             self.module_senders.insert(id, event_tx);
@@ -0,0 +1,57 @@
+use std::collections::HashMap;
+
+use async_trait::async_trait;
+use tokio::sync::mpsc;
+
+use super::traits::{AsyncModule, ModuleEvent, ModuleId, ModuleMessage};
+
+/// DMX module that accepts frames and discards them, for dry runs without Art-Net hardware
+#[derive(Default)]
+pub struct NullDmxModule {
+    frames_received: u64,
+}
+
+impl NullDmxModule {
+    pub fn new() -> Self {
+        Self::default()
+    }
+}
+
+#[async_trait]
+impl AsyncModule for NullDmxModule {
+    fn id(&self) -> ModuleId {
+        ModuleId::Dmx
+    }
+
+    async fn initialize(&mut self) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        Ok(())
+    }
+
+    async fn run(
+        &mut self,
+        mut rx: mpsc::Receiver<ModuleEvent>,
+        _tx: mpsc::Sender<ModuleMessage>,
+    ) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        while let Some(event) = rx.recv().await {
+            match event {
+                ModuleEvent::DmxOutput(..) => self.frames_received += 1,
+                ModuleEvent::Shutdown => break,
+                _ => {}
+            }
+        }
+        Ok(())
+    }
+
+    async fn shutdown(&mut self) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        Ok(())
+    }
+
+    fn status(&self) -> HashMap<String, String> {
+        let mut status = HashMap::new();
+        status.insert(
+            "frames_received".to_string(),
+            self.frames_received.to_string(),
+        );
+        status
+    }
+}
@@ -0,0 +1,171 @@
+use std::collections::HashMap;
+use std::fmt;
+use std::time::Duration;
+
+use halo_fixtures::{ChannelType, Fixture, Motion};
+use serde::Serialize;
+
+/// One of a moving head's two axes of travel
+#[derive(Clone, Copy, Debug, PartialEq, Eq, PartialOrd, Ord, Hash, Serialize)]
+#[serde(rename_all = "lowercase")]
+pub enum Axis {
+    Pan,
+    Tilt,
+}
+
+impl Axis {
+    pub fn channel_type(&self) -> ChannelType {
+        match self {
+            Axis::Pan => ChannelType::Pan,
+            Axis::Tilt => ChannelType::Tilt,
+        }
+    }
+
+    /// Degrees of travel and top speed on this axis
+    fn limits(&self, motion: &Motion) -> (f32, f32) {
+        match self {
+            Axis::Pan => (motion.pan_range, motion.pan_speed),
+            Axis::Tilt => (motion.tilt_range, motion.tilt_speed),
+        }
+    }
+}
+
+impl fmt::Display for Axis {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        match self {
+            Axis::Pan => write!(f, "pan"),
+            Axis::Tilt => write!(f, "tilt"),
+        }
+    }
+}
+
+/// Where one axis was told to be and where it has got to, in degrees from the end of its
+/// travel
+#[derive(Clone, Copy, Debug, Default, PartialEq)]
+pub struct AxisPosition {
+    pub commanded: f32,
+    pub actual: f32,
+}
+
+impl AxisPosition {
+    /// How many degrees the axis is behind where it was told to be
+    pub fn lag(&self) -> f32 {
+        (self.commanded - self.actual).abs()
+    }
+}
+
+/// A moving head's position on both axes
+#[derive(Clone, Copy, Debug, Default, PartialEq)]
+pub struct HeadPosition {
+    pub pan: AxisPosition,
+    pub tilt: AxisPosition,
+}
+
+impl HeadPosition {
+    pub fn axis(&self, axis: Axis) -> &AxisPosition {
+        match axis {
+            Axis::Pan => &self.pan,
+            Axis::Tilt => &self.tilt,
+        }
+    }
+
+    fn axis_mut(&mut self, axis: Axis) -> &mut AxisPosition {
+        match axis {
+            Axis::Pan => &mut self.pan,
+            Axis::Tilt => &mut self.tilt,
+        }
+    }
+}
+
+/// How fast an axis was told to move over one update, against how fast it can
+#[derive(Clone, Copy, Debug, PartialEq)]
+pub struct SpeedDemand {
+    pub fixture_id: usize,
+    pub axis: Axis,
+    /// Degrees per second the output asked for
+    pub speed: f32,
+    /// Degrees per second the head can manage
+    pub max_speed: f32,
+    /// Degrees behind after the update
+    pub lag: f32,
+}
+
+impl SpeedDemand {
+    pub fn exceeded(&self) -> bool {
+        self.speed > self.max_speed
+    }
+}
+
+/// Follows where moving heads physically are as the output drives them, for fixtures whose
+/// profile gives their [`Motion`].
+///
+/// Each update moves the head towards the position on its pan and tilt channels no faster
+/// than the profile allows, so an effect that asks for more than the hardware can do shows
+/// up as a head that falls behind. This only looks at the output and never changes it.
+#[derive(Clone, Debug, Default)]
+pub struct MotionModel {
+    heads: HashMap<usize, HeadPosition>,
+}
+
+impl MotionModel {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Move each head towards its fixture's output after `elapsed`, returning how fast each
+    /// axis was asked to go. A head seen for the first time starts where it was told to be.
+    pub fn update<'a>(
+        &mut self,
+        fixtures: impl IntoIterator<Item = &'a Fixture>,
+        elapsed: Duration,
+    ) -> Vec<SpeedDemand> {
+        let seconds = elapsed.as_secs_f32();
+        let mut demands = Vec::new();
+        for fixture in fixtures {
+            let Some(motion) = &fixture.profile.motion else {
+                continue;
+            };
+            let new = !self.heads.contains_key(&fixture.id);
+            let head = self.heads.entry(fixture.id).or_default();
+            for axis in [Axis::Pan, Axis::Tilt] {
+                let Some(value) = fixture.channel_value(&axis.channel_type()) else {
+                    continue;
+                };
+                let (range, max_speed) = axis.limits(motion);
+                let commanded = value as f32 / 255.0 * range;
+                let position = head.axis_mut(axis);
+                if new || seconds <= 0.0 {
+                    *position = AxisPosition {
+                        commanded,
+                        actual: commanded,
+                    };
+                    continue;
+                }
+
+                let speed = (commanded - position.commanded).abs() / seconds;
+                let step =
+                    (commanded - position.actual).clamp(-max_speed * seconds, max_speed * seconds);
+                position.commanded = commanded;
+                position.actual += step;
+                demands.push(SpeedDemand {
+                    fixture_id: fixture.id,
+                    axis,
+                    speed,
+                    max_speed,
+                    lag: position.lag(),
+                });
+            }
+        }
+        demands
+    }
+
+    /// Where a head is told to be and where it actually is
+    pub fn position(&self, fixture_id: usize) -> Option<&HeadPosition> {
+        self.heads.get(&fixture_id)
+    }
+
+    /// Forget every head, e.g. when a new show is loaded
+    pub fn clear(&mut self) {
+        self.heads.clear();
+    }
+}
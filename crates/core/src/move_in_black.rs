@@ -0,0 +1,80 @@
+use std::collections::{HashMap, HashSet};
+use std::time::{Duration, Instant};
+
+use halo_fixtures::{ChannelType, Fixture};
+
+use crate::StaticValue;
+
+/// Whether a channel is set ahead of time on a dark fixture: the ones the audience would
+/// see travel or spin if they changed with the light up
+pub fn moves_in_black(channel_type: &ChannelType) -> bool {
+    matches!(
+        channel_type,
+        ChannelType::Pan
+            | ChannelType::Tilt
+            | ChannelType::PanFine
+            | ChannelType::TiltFine
+            | ChannelType::Gobo
+            | ChannelType::Color
+    )
+}
+
+/// Pre-positions fixtures the next cue brings up while they're still dark, so the audience
+/// never sees them move.
+///
+/// A fixture moves once its dimmer has been at zero for the list's lead time, which gives a
+/// fade out and the lamp's afterglow time to finish. Values are written over the tracked
+/// ones every frame and never parked, so when the next cue runs its fade starts from the
+/// pre-positioned values and has nothing left to move. Fixtures without a dimmer channel
+/// can't be told to be dark and are left alone.
+#[derive(Clone, Debug, Default)]
+pub struct MoveInBlack {
+    /// When each fixture waiting to be pre-positioned was first seen dark
+    dark_since: HashMap<usize, Instant>,
+}
+
+impl MoveInBlack {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Forget every fixture, e.g. when the list playing doesn't move in black
+    pub fn clear(&mut self) {
+        self.dark_since.clear();
+    }
+
+    /// Write the position and wheel values from `next`, the values the next cue sets, on
+    /// each fixture it lights that has been dark for `lead`
+    pub fn apply(
+        &mut self,
+        fixtures: &mut [Fixture],
+        next: &[StaticValue],
+        lead: Duration,
+        now: Instant,
+    ) {
+        let lit: HashSet<usize> = next
+            .iter()
+            .filter(|v| v.channel_type == ChannelType::Dimmer && v.value > 0)
+            .map(|v| v.fixture_id)
+            .collect();
+        self.dark_since
+            .retain(|fixture_id, _| lit.contains(fixture_id));
+
+        for fixture in fixtures.iter_mut().filter(|f| lit.contains(&f.id)) {
+            if fixture.channel_value(&ChannelType::Dimmer) != Some(0) {
+                self.dark_since.remove(&fixture.id);
+                continue;
+            }
+            let since = *self.dark_since.entry(fixture.id).or_insert(now);
+            if now.duration_since(since) < lead {
+                continue;
+            }
+            for value in next
+                .iter()
+                .filter(|v| v.fixture_id == fixture.id && moves_in_black(&v.channel_type))
+            {
+                fixture.set_channel_value(&value.channel_type, value.value);
+            }
+        }
+    }
+}
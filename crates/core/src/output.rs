@@ -0,0 +1,239 @@
+use std::collections::HashMap;
+use std::fmt;
+use std::net::{IpAddr, Ipv4Addr};
+use std::str::FromStr;
+
+use serde::{Deserialize, Serialize};
+
+use crate::artnet::artnet::ArtNet;
+use crate::artnet::network_config::NetworkConfig;
+use crate::sacn::sacn::{Sacn, SacnConfig};
+
+/// Which transport DMX goes out on, chosen in the config file as `"output"`
+#[derive(Clone, Copy, Debug, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum OutputKind {
+    #[default]
+    ArtNet,
+    Sacn,
+    /// Discard every frame, for dry runs
+    Null,
+}
+
+impl fmt::Display for OutputKind {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        match self {
+            OutputKind::ArtNet => write!(f, "artnet"),
+            OutputKind::Sacn => write!(f, "sacn"),
+            OutputKind::Null => write!(f, "null"),
+        }
+    }
+}
+
+impl FromStr for OutputKind {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s.to_ascii_lowercase().as_str() {
+            "artnet" | "art-net" => Ok(OutputKind::ArtNet),
+            "sacn" | "e1.31" => Ok(OutputKind::Sacn),
+            "null" | "none" => Ok(OutputKind::Null),
+            _ => Err(format!(
+                "Unknown output '{s}', expected artnet, sacn or null"
+            )),
+        }
+    }
+}
+
+impl OutputKind {
+    /// A driver for this transport, sending Art-Net to `network_config`'s destinations or
+    /// sACN with `sacn`, or multicast as "halo" from any interface without it
+    pub fn driver(
+        self,
+        network_config: NetworkConfig,
+        sacn: Option<SacnConfig>,
+    ) -> Box<dyn OutputDriver> {
+        match self {
+            OutputKind::ArtNet => Box::new(ArtNetDriver::new(network_config)),
+            OutputKind::Sacn => {
+                Box::new(SacnDriver::new(sacn.unwrap_or_else(|| {
+                    SacnConfig::new(IpAddr::V4(Ipv4Addr::UNSPECIFIED), "halo")
+                })))
+            }
+            OutputKind::Null => Box::new(NullDriver),
+        }
+    }
+}
+
+/// Where DMX frames go: a transport such as Art-Net or sACN, or nowhere.
+///
+/// [`crate::DmxModule`] owns a driver and calls it from its frame loop, so a transport only
+/// has to open its sockets and send one universe at a time.
+pub trait OutputDriver: Send + Sync {
+    /// Name for logs, e.g. "Art-Net"
+    fn name(&self) -> &str;
+
+    /// Open sockets or devices, once before the first frame
+    fn open(&mut self) -> Result<(), anyhow::Error> {
+        Ok(())
+    }
+
+    fn send_universe(&mut self, universe: u8, data: &[u8]) -> Result<(), anyhow::Error>;
+
+    /// Finish up after the last frame
+    fn close(&mut self) -> Result<(), anyhow::Error> {
+        Ok(())
+    }
+
+    /// Details to show in the module's status
+    fn status(&self) -> HashMap<String, String> {
+        HashMap::new()
+    }
+}
+
+/// Sends each universe over Art-Net to the destination it's routed to
+pub struct ArtNetDriver {
+    network_config: NetworkConfig,
+    connections: Vec<ArtNet>,
+}
+
+impl ArtNetDriver {
+    pub fn new(network_config: NetworkConfig) -> Self {
+        Self {
+            network_config,
+            connections: Vec::new(),
+        }
+    }
+}
+
+impl OutputDriver for ArtNetDriver {
+    fn name(&self) -> &str {
+        "Art-Net"
+    }
+
+    fn open(&mut self) -> Result<(), anyhow::Error> {
+        self.connections.clear();
+        for (i, destination) in self.network_config.destinations.iter().enumerate() {
+            log::info!(
+                "Setting up ArtNet connection {} for destination: {}",
+                i,
+                destination.name
+            );
+            self.connections
+                .push(ArtNet::new(destination.mode.clone())?);
+        }
+        Ok(())
+    }
+
+    fn send_universe(&mut self, universe: u8, data: &[u8]) -> Result<(), anyhow::Error> {
+        let index = self
+            .network_config
+            .get_destination_for_universe(universe)
+            .ok_or_else(|| {
+                anyhow::anyhow!("No destination routing configured for universe {universe}")
+            })?;
+        let artnet = self.connections.get(index).ok_or_else(|| {
+            anyhow::anyhow!("No ArtNet connection found for destination index {index}")
+        })?;
+        artnet.send_data(universe, data.to_vec())
+    }
+
+    fn status(&self) -> HashMap<String, String> {
+        HashMap::from([
+            (
+                "mode".to_string(),
+                self.network_config.get_mode_string().to_string(),
+            ),
+            (
+                "destinations".to_string(),
+                self.network_config.destinations.len().to_string(),
+            ),
+            (
+                "destination_info".to_string(),
+                self.network_config.get_destination(),
+            ),
+        ])
+    }
+}
+
+/// Sends each universe as sACN (E1.31), and tells receivers the streams have ended on close
+pub struct SacnDriver {
+    config: SacnConfig,
+    sacn: Option<Sacn>,
+    universes: Vec<u8>,
+}
+
+impl SacnDriver {
+    pub fn new(config: SacnConfig) -> Self {
+        Self {
+            config,
+            sacn: None,
+            universes: Vec::new(),
+        }
+    }
+
+    fn mode(&self) -> String {
+        if self.config.unicast.is_empty() {
+            "multicast".to_string()
+        } else {
+            format!("unicast to {} receivers", self.config.unicast.len())
+        }
+    }
+}
+
+impl OutputDriver for SacnDriver {
+    fn name(&self) -> &str {
+        "sACN"
+    }
+
+    fn open(&mut self) -> Result<(), anyhow::Error> {
+        log::info!(
+            "Sending sACN as '{}' from {}, {}",
+            self.config.source_name,
+            self.config.interface,
+            self.mode()
+        );
+        self.sacn = Some(Sacn::new(self.config.clone())?);
+        Ok(())
+    }
+
+    fn send_universe(&mut self, universe: u8, data: &[u8]) -> Result<(), anyhow::Error> {
+        let sacn = self
+            .sacn
+            .as_mut()
+            .ok_or_else(|| anyhow::anyhow!("sACN sender not opened"))?;
+        if !self.universes.contains(&universe) {
+            self.universes.push(universe);
+        }
+        sacn.send_data(universe, data)
+    }
+
+    fn close(&mut self) -> Result<(), anyhow::Error> {
+        // Receivers would otherwise hold the last frame until their source timeout runs out
+        if let Some(sacn) = self.sacn.as_mut() {
+            sacn.terminate(&self.universes)?;
+        }
+        Ok(())
+    }
+
+    fn status(&self) -> HashMap<String, String> {
+        HashMap::from([
+            ("mode".to_string(), self.mode()),
+            ("source_name".to_string(), self.config.source_name.clone()),
+        ])
+    }
+}
+
+/// Accepts every frame and sends it nowhere, for dry runs without a rig
+#[derive(Default)]
+pub struct NullDriver;
+
+impl OutputDriver for NullDriver {
+    fn name(&self) -> &str {
+        "null"
+    }
+
+    fn send_universe(&mut self, _universe: u8, _data: &[u8]) -> Result<(), anyhow::Error> {
+        Ok(())
+    }
+}
@@ -0,0 +1,50 @@
+use halo_fixtures::{ChannelType, Fixture};
+
+/// Channel values an output layer has written over, so they can be put back before the next
+/// frame renders.
+///
+/// Layers that sit on top of playback (flashes, solo, fixture commands) write their values
+/// after everything else, then restore what was underneath at the start of the next frame.
+/// Channels that playback doesn't touch would otherwise keep the layer's value after it's gone.
+#[derive(Clone, Debug, Default)]
+pub struct ParkedChannels {
+    /// Fixture ID, the channel's index in its channel list and the value underneath
+    underlying: Vec<(usize, usize, u8)>,
+}
+
+impl ParkedChannels {
+    /// Write a value over a channel, remembering the value underneath. Fixtures without the
+    /// channel are left alone.
+    pub fn park(&mut self, fixture: &mut Fixture, channel_type: &ChannelType, value: u8) {
+        if let Some(index) = fixture
+            .channels
+            .iter()
+            .position(|c| c.channel_type == *channel_type)
+        {
+            self.park_at(fixture, index, value);
+        }
+    }
+
+    /// Write a value over the channel at `index` in the fixture's channel list, for fixtures
+    /// with several channels of one type
+    pub fn park_at(&mut self, fixture: &mut Fixture, index: usize, value: u8) {
+        if let Some(channel) = fixture.channels.get(index) {
+            self.underlying.push((fixture.id, index, channel.value));
+            fixture.set_channel_value_at(index, value);
+        }
+    }
+
+    /// Put back every value written over since the last restore
+    pub fn restore(&mut self, fixtures: &mut [Fixture]) {
+        // Newest first, so a channel parked twice ends up with its original value
+        for (fixture_id, index, value) in self.underlying.drain(..).rev() {
+            if let Some(channel) = fixtures
+                .iter_mut()
+                .find(|f| f.id == fixture_id)
+                .and_then(|f| f.channels.get_mut(index))
+            {
+                channel.value = value;
+            }
+        }
+    }
+}
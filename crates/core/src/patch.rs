@@ -0,0 +1,306 @@
+use std::collections::BTreeMap;
+use std::fmt::{self, Write};
+
+use halo_fixtures::{Fixture, FixtureLibrary, PanTiltLimits};
+use serde::{Deserialize, Serialize};
+
+/// Channels in a DMX universe
+pub(crate) const UNIVERSE_SIZE: u16 = 512;
+
+/// A universe and start address
+#[derive(Clone, Copy, Debug, PartialEq, Eq, Serialize, Deserialize)]
+pub struct PatchAddress {
+    pub universe: u8,
+    pub start_address: u16,
+}
+
+/// A fixture waiting to be patched. Fixtures with an `address` are pinned there; the rest are
+/// placed by `auto_patch`.
+#[derive(Clone, Debug, Serialize, Deserialize)]
+pub struct PatchSpec {
+    pub name: String,
+    pub profile_id: String,
+    #[serde(default)]
+    pub address: Option<PatchAddress>,
+    /// Profile mode, which decides the fixture's footprint
+    #[serde(default)]
+    pub mode: Option<u8>,
+    /// Where a moving fixture may point, e.g. to keep a wash off the audience
+    #[serde(default)]
+    pub pan_tilt_limits: Option<PanTiltLimits>,
+}
+
+/// Fixtures placed by `auto_patch`, with the channels left over in each universe
+#[derive(Clone, Debug)]
+pub struct PatchPlan {
+    /// Patched fixtures in the order they were given, with IDs counting up from 0
+    pub fixtures: Vec<Fixture>,
+    pub free_channels: BTreeMap<u8, u16>,
+    /// IDs of the fixtures given an address, rather than pinned to one
+    pub auto_patched: Vec<usize>,
+}
+
+/// Assign addresses to fixtures across the given universes.
+///
+/// Pinned fixtures keep their addresses. The rest are placed largest footprint first, each at
+/// the lowest free address in the first universe it fits, so a fixture never straddles two
+/// universes. The same specs always produce the same patch.
+pub fn auto_patch(
+    specs: &[PatchSpec],
+    universes: &[u8],
+    library: &FixtureLibrary,
+) -> Result<PatchPlan, String> {
+    let mut fixtures = Vec::with_capacity(specs.len());
+    for (id, spec) in specs.iter().enumerate() {
+        let profile = library
+            .profiles
+            .get(&spec.profile_id)
+            .ok_or_else(|| format!("Profile {} not found", spec.profile_id))?;
+        let channels = profile
+            .layout(spec.mode)
+            .map_err(|e| format!("{}: {e}", spec.name))?;
+        let mut fixture = Fixture::new(id, &spec.name, profile.clone(), channels, 0, 0);
+        fixture.mode = spec.mode;
+        fixture.pan_tilt_limits = spec.pan_tilt_limits.clone();
+        if let Some(address) = spec.address {
+            fixture.universe = address.universe;
+            fixture.start_address = address.start_address;
+        }
+        fixtures.push(fixture);
+    }
+
+    let pinned: Vec<Fixture> = specs
+        .iter()
+        .zip(&fixtures)
+        .filter(|(spec, _)| spec.address.is_some())
+        .map(|(_, fixture)| fixture.clone())
+        .collect();
+    let conflicts = patch_conflicts(&pinned);
+    if !conflicts.is_empty() {
+        return Err(conflicts.join("\n"));
+    }
+
+    // Used ranges per universe, as (first, last) addresses
+    let mut used: BTreeMap<u8, Vec<(u16, u16)>> =
+        universes.iter().map(|u| (*u, Vec::new())).collect();
+    for fixture in &pinned {
+        used.entry(fixture.universe)
+            .or_default()
+            .push(address_range(fixture));
+    }
+
+    let mut unplaced: Vec<usize> = (0..fixtures.len())
+        .filter(|i| specs[*i].address.is_none())
+        .collect();
+    let auto_patched = unplaced.clone();
+    // Stable, so fixtures of the same size keep the order they were given in
+    unplaced.sort_by_key(|i| std::cmp::Reverse(fixtures[*i].channels.len()));
+
+    for index in unplaced {
+        let fixture = &mut fixtures[index];
+        let footprint = fixture.channels.len().max(1) as u16;
+        let placed = universes.iter().find_map(|universe| {
+            let ranges = used.get_mut(universe)?;
+            let start = first_gap(ranges, footprint)?;
+            ranges.push((start, start + footprint - 1));
+            Some((*universe, start))
+        });
+        let Some((universe, start)) = placed else {
+            return Err(format!(
+                "No room for {} ({footprint} channels) in universes {universes:?}",
+                fixture.name
+            ));
+        };
+        fixture.universe = universe;
+        fixture.start_address = start;
+    }
+
+    let free_channels = used
+        .iter()
+        .map(|(universe, ranges)| {
+            let taken: u16 = ranges.iter().map(|(first, last)| last - first + 1).sum();
+            (*universe, UNIVERSE_SIZE.saturating_sub(taken))
+        })
+        .collect();
+
+    Ok(PatchPlan {
+        fixtures,
+        free_channels,
+        auto_patched,
+    })
+}
+
+/// Lowest address with `footprint` free channels after it
+fn first_gap(ranges: &mut [(u16, u16)], footprint: u16) -> Option<u16> {
+    ranges.sort_unstable();
+    let mut start = 1;
+    for (first, last) in ranges.iter() {
+        if start + footprint <= *first {
+            break;
+        }
+        start = start.max(last + 1);
+    }
+    (start + footprint - 1 <= UNIVERSE_SIZE).then_some(start)
+}
+
+/// First and last address a fixture occupies
+pub(crate) fn address_range(fixture: &Fixture) -> (u16, u16) {
+    let footprint = fixture.channels.len().max(1) as u16;
+    (
+        fixture.start_address,
+        fixture.start_address.saturating_add(footprint - 1),
+    )
+}
+
+/// Fixtures that overlap each other or run off the end of their universe. Applies to any
+/// patch, however the addresses were chosen.
+pub fn patch_conflicts(fixtures: &[Fixture]) -> Vec<String> {
+    let mut conflicts = Vec::new();
+
+    for fixture in fixtures {
+        let (first, last) = address_range(fixture);
+        if first == 0 || last > UNIVERSE_SIZE {
+            conflicts.push(format!(
+                "{} at {}.{first} runs past the end of the universe",
+                fixture.name, fixture.universe
+            ));
+        }
+    }
+
+    for (i, a) in fixtures.iter().enumerate() {
+        for b in &fixtures[i + 1..] {
+            if a.universe != b.universe {
+                continue;
+            }
+            let (a_first, a_last) = address_range(a);
+            let (b_first, b_last) = address_range(b);
+            if a_first <= b_last && b_first <= a_last {
+                conflicts.push(format!(
+                    "{} ({}.{a_first}-{a_last}) overlaps {} ({}.{b_first}-{b_last})",
+                    a.name, a.universe, b.name, b.universe
+                ));
+            }
+        }
+    }
+
+    conflicts
+}
+
+/// A printable patch sheet, one line per fixture sorted by universe and address
+pub fn patch_sheet(fixtures: &[Fixture]) -> String {
+    let mut sorted: Vec<&Fixture> = fixtures.iter().collect();
+    sorted.sort_by_key(|f| (f.universe, f.start_address, f.id));
+
+    let name_width = sorted
+        .iter()
+        .map(|f| f.name.len())
+        .max()
+        .unwrap_or(0)
+        .max("Fixture".len());
+
+    let mut sheet = format!("{:<name_width$}  Universe  Address\n", "Fixture");
+    for fixture in sorted {
+        let (first, last) = address_range(fixture);
+        let _ = writeln!(
+            sheet,
+            "{:<name_width$}  {:>8}  {first:>3}-{last:<3}",
+            fixture.name, fixture.universe
+        );
+    }
+    sheet
+}
+
+/// One channel of a described fixture
+#[derive(Clone, Debug, Serialize)]
+pub struct ChannelDescription {
+    pub name: String,
+    /// Absolute address in the fixture's universe
+    pub address: u16,
+    pub value: u8,
+}
+
+/// Everything about how a fixture is patched and what it's putting out, for debugging
+/// addressing without working offsets out by hand
+#[derive(Clone, Debug, Serialize)]
+pub struct FixtureDescription {
+    pub id: usize,
+    pub name: String,
+    pub profile_id: String,
+    pub manufacturer: String,
+    pub model: String,
+    pub mode: Option<u8>,
+    pub universe: u8,
+    pub start_address: u16,
+    pub end_address: u16,
+    pub channels: Vec<ChannelDescription>,
+    /// Whether the fixture has been taken out of the output
+    pub disabled: bool,
+}
+
+impl FixtureDescription {
+    pub fn new(fixture: &Fixture, disabled: bool) -> Self {
+        let (start_address, end_address) = address_range(fixture);
+        let channels = fixture
+            .channel_map()
+            .into_iter()
+            .zip(&fixture.channels)
+            .map(|((name, address), channel)| ChannelDescription {
+                name,
+                address,
+                value: channel.value,
+            })
+            .collect();
+        Self {
+            id: fixture.id,
+            name: fixture.name.clone(),
+            profile_id: fixture.profile_id.clone(),
+            manufacturer: fixture.profile.manufacturer.clone(),
+            model: fixture.profile.model.clone(),
+            mode: fixture.mode,
+            universe: fixture.universe,
+            start_address,
+            end_address,
+            channels,
+            disabled,
+        }
+    }
+}
+
+impl fmt::Display for FixtureDescription {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        writeln!(f, "{} (ID: {})", self.name, self.id)?;
+        writeln!(
+            f,
+            "  Profile:  {} ({} {})",
+            self.profile_id, self.manufacturer, self.model
+        )?;
+        if let Some(mode) = self.mode {
+            writeln!(f, "  Mode:     {mode}")?;
+        }
+        writeln!(
+            f,
+            "  Address:  {}.{}-{} ({} channels)",
+            self.universe,
+            self.start_address,
+            self.end_address,
+            self.channels.len()
+        )?;
+        if self.disabled {
+            writeln!(f, "  Output:   disabled")?;
+        }
+        let name_width = self
+            .channels
+            .iter()
+            .map(|c| c.name.len())
+            .max()
+            .unwrap_or(0);
+        for channel in &self.channels {
+            writeln!(
+                f,
+                "  {:>3}  {:<name_width$}  {:>3}",
+                channel.address, channel.name, channel.value
+            )?;
+        }
+        Ok(())
+    }
+}
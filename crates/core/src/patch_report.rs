@@ -0,0 +1,270 @@
+use std::collections::BTreeMap;
+use std::fmt::Write;
+use std::str::FromStr;
+
+use halo_fixtures::{ChannelType, Fixture, StagePosition};
+
+use crate::patch::{address_range, UNIVERSE_SIZE};
+
+/// What `halo patchsheet` writes a [`PatchReport`] as
+#[derive(Clone, Copy, Debug, Default, PartialEq, Eq)]
+pub enum ReportFormat {
+    #[default]
+    Markdown,
+    Csv,
+    Html,
+}
+
+impl FromStr for ReportFormat {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s.to_ascii_lowercase().as_str() {
+            "md" | "markdown" => Ok(ReportFormat::Markdown),
+            "csv" => Ok(ReportFormat::Csv),
+            "html" => Ok(ReportFormat::Html),
+            _ => Err(format!("Unknown format '{s}', expected md, csv or html")),
+        }
+    }
+}
+
+/// One fixture's line in a [`PatchReport`]
+#[derive(Clone, Debug, PartialEq)]
+pub struct PatchReportRow {
+    pub id: usize,
+    pub name: String,
+    pub universe: u8,
+    pub start_address: u16,
+    pub end_address: u16,
+    pub profile_id: String,
+    /// Manufacturer and model
+    pub profile: String,
+    pub mode: Option<u8>,
+    pub position: Option<StagePosition>,
+    /// Pan and tilt the fixture rests at, for fixtures that move
+    pub home: Option<(u8, u8)>,
+    /// Whether `auto_patch` chose the address
+    pub auto_patched: bool,
+}
+
+impl PatchReportRow {
+    fn cells(&self) -> [String; 9] {
+        [
+            self.name.clone(),
+            self.id.to_string(),
+            self.universe.to_string(),
+            format!("{}-{}", self.start_address, self.end_address),
+            format!("{} ({})", self.profile_id, self.profile),
+            self.mode.map_or("-".to_string(), |m| m.to_string()),
+            self.position
+                .as_ref()
+                .map_or("-".to_string(), |p| format!("{:.2}, {:.2}", p.x, p.y)),
+            self.home
+                .map_or("-".to_string(), |(pan, tilt)| format!("{pan}/{tilt}")),
+            if self.auto_patched { "auto" } else { "fixed" }.to_string(),
+        ]
+    }
+}
+
+/// How full one universe is
+#[derive(Clone, Copy, Debug, PartialEq, Eq)]
+pub struct UniverseUsage {
+    pub fixtures: usize,
+    pub channels_used: u16,
+    pub channels_free: u16,
+}
+
+const HEADINGS: [&str; 9] = [
+    "Fixture",
+    "ID",
+    "Universe",
+    "Address",
+    "Profile",
+    "Mode",
+    "Position",
+    "Home (pan/tilt)",
+    "Patch",
+];
+
+/// A one page patch report to print before a show: fixtures grouped by type with their
+/// address ranges, profiles, stage positions and home positions, then how full each
+/// universe is.
+///
+/// Types are in name order and fixtures within a type in name order, so the same rig gives
+/// the same report and a diff between two venues shows only what moved.
+#[derive(Clone, Debug, PartialEq)]
+pub struct PatchReport {
+    pub title: String,
+    /// Fixture type names, each with its fixtures
+    pub groups: Vec<(String, Vec<PatchReportRow>)>,
+    pub universes: BTreeMap<u8, UniverseUsage>,
+}
+
+impl PatchReport {
+    /// A report on `fixtures`, marking those in `auto_patched` as placed by `auto_patch`
+    pub fn new(title: &str, fixtures: &[Fixture], auto_patched: &[usize]) -> Self {
+        let mut groups: BTreeMap<String, Vec<PatchReportRow>> = BTreeMap::new();
+        let mut universes: BTreeMap<u8, UniverseUsage> = BTreeMap::new();
+        for fixture in fixtures {
+            let (start_address, end_address) = address_range(fixture);
+            let moves = fixture.channel_value(&ChannelType::Pan).is_some()
+                && fixture.channel_value(&ChannelType::Tilt).is_some();
+            let home = moves.then(|| {
+                let home = fixture.home_position();
+                (home.pan, home.tilt)
+            });
+            groups
+                .entry(format!("{:?}", fixture.profile.fixture_type))
+                .or_default()
+                .push(PatchReportRow {
+                    id: fixture.id,
+                    name: fixture.name.clone(),
+                    universe: fixture.universe,
+                    start_address,
+                    end_address,
+                    profile_id: fixture.profile_id.clone(),
+                    profile: fixture.profile.to_string(),
+                    mode: fixture.mode,
+                    position: fixture.position,
+                    home,
+                    auto_patched: auto_patched.contains(&fixture.id),
+                });
+
+            let usage = universes.entry(fixture.universe).or_insert(UniverseUsage {
+                fixtures: 0,
+                channels_used: 0,
+                channels_free: UNIVERSE_SIZE,
+            });
+            usage.fixtures += 1;
+            usage.channels_used += fixture.channels.len() as u16;
+            usage.channels_free = UNIVERSE_SIZE.saturating_sub(usage.channels_used);
+        }
+        for rows in groups.values_mut() {
+            rows.sort_by(|a, b| a.name.cmp(&b.name).then(a.id.cmp(&b.id)));
+        }
+
+        Self {
+            title: title.to_string(),
+            groups: groups.into_iter().collect(),
+            universes,
+        }
+    }
+
+    pub fn render(&self, format: ReportFormat) -> String {
+        match format {
+            ReportFormat::Markdown => self.to_markdown(),
+            ReportFormat::Csv => self.to_csv(),
+            ReportFormat::Html => self.to_html(),
+        }
+    }
+
+    pub fn to_markdown(&self) -> String {
+        let row = |cells: &[String]| {
+            let cells: Vec<String> = cells.iter().map(|c| c.replace('|', "\\|")).collect();
+            format!("| {} |\n", cells.join(" | "))
+        };
+        let headings = |headings: &[&str]| {
+            let headings: Vec<String> = headings.iter().map(|h| h.to_string()).collect();
+            let rule = vec!["---".to_string(); headings.len()];
+            row(&headings) + &row(&rule)
+        };
+
+        let mut out = format!("# Patch sheet: {}\n", self.title);
+        for (fixture_type, rows) in &self.groups {
+            let _ = write!(out, "\n## {fixture_type}\n\n{}", headings(&HEADINGS));
+            for fixture in rows {
+                out += &row(&fixture.cells());
+            }
+        }
+        let _ = write!(
+            out,
+            "\n## Universes\n\n{}",
+            headings(&["Universe", "Fixtures", "Channels used", "Free"])
+        );
+        for (universe, usage) in &self.universes {
+            out += &row(&universe_cells(*universe, usage));
+        }
+        out
+    }
+
+    /// One line per fixture with its type in the first column, for spreadsheets
+    pub fn to_csv(&self) -> String {
+        let line = |cells: &[String]| {
+            let cells: Vec<String> = cells.iter().map(|c| csv_field(c)).collect();
+            cells.join(",") + "\n"
+        };
+        let mut headings = vec!["Type".to_string()];
+        headings.extend(HEADINGS.iter().map(|h| h.to_string()));
+        let mut out = line(&headings);
+        for (fixture_type, rows) in &self.groups {
+            for fixture in rows {
+                let mut cells = vec![fixture_type.clone()];
+                cells.extend(fixture.cells());
+                out += &line(&cells);
+            }
+        }
+        out
+    }
+
+    /// A plain HTML page with a table per fixture type
+    pub fn to_html(&self) -> String {
+        let table = |headings: &[&str], rows: Vec<Vec<String>>| {
+            let mut table = String::from("<table>\n<tr>");
+            for heading in headings {
+                let _ = write!(table, "<th>{}</th>", html_escape(heading));
+            }
+            table += "</tr>\n";
+            for cells in rows {
+                table += "<tr>";
+                for cell in cells {
+                    let _ = write!(table, "<td>{}</td>", html_escape(&cell));
+                }
+                table += "</tr>\n";
+            }
+            table + "</table>\n"
+        };
+
+        let title = html_escape(&format!("Patch sheet: {}", self.title));
+        let mut out = format!(
+            "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>{title}</title>\n</head>\n<body>\n<h1>{title}</h1>\n"
+        );
+        for (fixture_type, rows) in &self.groups {
+            let _ = writeln!(out, "<h2>{}</h2>", html_escape(fixture_type));
+            out += &table(&HEADINGS, rows.iter().map(|r| r.cells().to_vec()).collect());
+        }
+        out += "<h2>Universes</h2>\n";
+        out += &table(
+            &["Universe", "Fixtures", "Channels used", "Free"],
+            self.universes
+                .iter()
+                .map(|(universe, usage)| universe_cells(*universe, usage).to_vec())
+                .collect(),
+        );
+        out + "</body>\n</html>\n"
+    }
+}
+
+fn universe_cells(universe: u8, usage: &UniverseUsage) -> [String; 4] {
+    [
+        universe.to_string(),
+        usage.fixtures.to_string(),
+        usage.channels_used.to_string(),
+        usage.channels_free.to_string(),
+    ]
+}
+
+/// Quote a field that would otherwise break the line up
+fn csv_field(field: &str) -> String {
+    if field.contains([',', '"', '\n']) {
+        format!("\"{}\"", field.replace('"', "\"\""))
+    } else {
+        field.to_string()
+    }
+}
+
+fn html_escape(text: &str) -> String {
+    text.replace('&', "&amp;")
+        .replace('<', "&lt;")
+        .replace('>', "&gt;")
+        .replace('"', "&quot;")
+}
@@ -176,8 +176,15 @@ impl PixelEngine {
         self.active_effects.clear();
     }
 
-    /// Render all pixel fixtures and return DMX data per universe
-    pub fn render(&self, fixtures: &[Fixture], rhythm_state: &RhythmState) -> HashMap<u8, Vec<u8>> {
+    /// Render all pixel fixtures and return DMX data per universe, each fixture following the
+    /// rhythm `rhythm_for` gives the universe it starts in. Fixtures `lit` turns down by ID go
+    /// out black.
+    pub fn render(
+        &self,
+        fixtures: &[Fixture],
+        rhythm_for: impl Fn(u8) -> RhythmState,
+        lit: impl Fn(usize) -> bool,
+    ) -> HashMap<u8, Vec<u8>> {
         if !self.enabled {
             return HashMap::new();
         }
@@ -201,8 +208,6 @@ impl PixelEngine {
                 continue;
             }
 
-            // Calculate RGB values for each pixel
-            let pixel_data = self.render_fixture(fixture, pixel_count, rhythm_state);
             let channels_needed = pixel_count * 3; // RGB per pixel
 
             // Determine universe and start address (use sequential mapping if enabled)
@@ -223,6 +228,13 @@ impl PixelEngine {
                 )
             };
 
+            // Calculate RGB values for each pixel
+            let pixel_data = if lit(fixture.id) {
+                self.render_fixture(fixture, pixel_count, &rhythm_for(start_universe))
+            } else {
+                vec![0u8; channels_needed]
+            };
+
             log::info!(
                 "Pixel Engine - Fixture {} ({}): pixel_count={}, channels.len()={}, start_address={}, universe={}, channels_needed={}",
                 fixture.id,
@@ -290,7 +302,8 @@ impl PixelEngine {
                 let phase = match distribution {
                     EffectDistribution::All => base_phase,
                     EffectDistribution::Step(step) => {
-                        let step_offset = (fixture_idx % step) as f64 / (*step).max(1) as f64;
+                        let step = (*step).max(1);
+                        let step_offset = (fixture_idx % step) as f64 / step as f64;
                         (base_phase + step_offset) % 1.0
                     }
                     EffectDistribution::Wave(offset) => {
@@ -1,7 +1,39 @@
 use halo_fixtures::ChannelType;
+use serde::{Deserialize, Serialize};
 
 use crate::{EffectMapping, StaticValue};
 
+/// How a fan spreads values across an ordered selection of fixtures
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub enum FanMode {
+    /// `from` on the first fixture through to `to` on the last
+    #[default]
+    Linear,
+    /// `from` in the center, spreading out to `to` on both edges
+    Symmetric,
+}
+
+/// Spread values between `from` and `to` across `count` fixtures.
+///
+/// A single fixture gets `from`.
+pub fn fan_values(count: usize, from: u8, to: u8, mode: FanMode) -> Vec<u8> {
+    if count < 2 {
+        return vec![from; count];
+    }
+
+    let last = (count - 1) as f32;
+    (0..count)
+        .map(|i| {
+            let position = match mode {
+                FanMode::Linear => i as f32 / last,
+                FanMode::Symmetric => (i as f32 - last / 2.0).abs() / (last / 2.0),
+            };
+            let value = from as f32 + (to as f32 - from as f32) * position;
+            value.round().clamp(0.0, 255.0) as u8
+        })
+        .collect()
+}
+
 #[derive(Clone)]
 pub struct Programmer {
     values: Vec<StaticValue>,
@@ -35,6 +67,21 @@ impl Programmer {
         });
     }
 
+    /// Fan a channel across fixtures, in the order given
+    pub fn fan(
+        &mut self,
+        fixture_ids: &[usize],
+        channel_type: ChannelType,
+        from: u8,
+        to: u8,
+        mode: FanMode,
+    ) {
+        let values = fan_values(fixture_ids.len(), from, to, mode);
+        for (&fixture_id, value) in fixture_ids.iter().zip(values) {
+            self.add_value(fixture_id, channel_type.clone(), value);
+        }
+    }
+
     pub fn get_values(&self) -> &Vec<StaticValue> {
         &self.values
     }
@@ -0,0 +1,262 @@
+use std::fmt;
+use std::future::Future;
+use std::sync::atomic::{AtomicU64, Ordering};
+use std::time::Duration;
+
+use serde::{Deserialize, Serialize};
+use tokio::task::JoinHandle;
+
+use crate::render::RENDER_TICK;
+
+/// Niceness a thread can be given, from most favoured to least
+pub const NICENESS_RANGE: (i32, i32) = (-20, 19);
+
+/// Upper bounds of the tick time buckets, in milliseconds. Ticks over the last go in a bucket
+/// of their own.
+const BUCKET_BOUNDS_MS: [u64; 10] = [1, 2, 5, 10, 15, 20, 25, 30, 40, 50];
+
+/// How long the ticks of a loop took, counted into fixed buckets so recording one never
+/// allocates or locks. Shared between the loop and whatever reports on it.
+#[derive(Debug, Default)]
+pub struct TickHistogram {
+    buckets: [AtomicU64; BUCKET_BOUNDS_MS.len() + 1],
+    total_micros: AtomicU64,
+    max_micros: AtomicU64,
+}
+
+impl TickHistogram {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Count a tick that took `took`
+    pub fn record(&self, took: Duration) {
+        let micros = took.as_micros() as u64;
+        let bucket = BUCKET_BOUNDS_MS
+            .iter()
+            .position(|bound| micros < bound * 1000)
+            .unwrap_or(BUCKET_BOUNDS_MS.len());
+        self.buckets[bucket].fetch_add(1, Ordering::Relaxed);
+        self.total_micros.fetch_add(micros, Ordering::Relaxed);
+        self.max_micros.fetch_max(micros, Ordering::Relaxed);
+    }
+
+    /// Ticks counted so far
+    pub fn count(&self) -> u64 {
+        self.buckets.iter().map(|b| b.load(Ordering::Relaxed)).sum()
+    }
+
+    /// The longest tick
+    pub fn max(&self) -> Duration {
+        Duration::from_micros(self.max_micros.load(Ordering::Relaxed))
+    }
+
+    pub fn mean(&self) -> Duration {
+        match self.count() {
+            0 => Duration::ZERO,
+            count => Duration::from_micros(self.total_micros.load(Ordering::Relaxed) / count),
+        }
+    }
+
+    /// The bound under which `percent` of ticks came in, to the nearest bucket. Ticks past the
+    /// last bucket give the longest tick.
+    pub fn percentile(&self, percent: f64) -> Duration {
+        let wanted = (self.count() as f64 * percent / 100.0).ceil() as u64;
+        let mut seen = 0;
+        for (bound, count) in self.buckets() {
+            seen += count;
+            if seen >= wanted.max(1) {
+                return bound.unwrap_or_else(|| self.max());
+            }
+        }
+        self.max()
+    }
+
+    /// Each bucket's upper bound, `None` for the last, open one, and its count
+    pub fn buckets(&self) -> Vec<(Option<Duration>, u64)> {
+        self.buckets
+            .iter()
+            .enumerate()
+            .map(|(i, count)| {
+                let bound = BUCKET_BOUNDS_MS.get(i).map(|ms| Duration::from_millis(*ms));
+                (bound, count.load(Ordering::Relaxed))
+            })
+            .collect()
+    }
+
+    /// Ticks that took at least `limit`, to the nearest bucket
+    pub fn over(&self, limit: Duration) -> u64 {
+        let mut lower = Duration::ZERO;
+        let mut over = 0;
+        for (bound, count) in self.buckets() {
+            if lower >= limit {
+                over += count;
+            }
+            lower = bound.unwrap_or(Duration::MAX);
+        }
+        over
+    }
+}
+
+impl fmt::Display for TickHistogram {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        let ms = |d: Duration| d.as_secs_f64() * 1000.0;
+        write!(
+            f,
+            "{} ticks, mean {:.2}ms, p99 under {:.0}ms, max {:.2}ms",
+            self.count(),
+            ms(self.mean()),
+            ms(self.percentile(99.0)),
+            ms(self.max())
+        )
+    }
+}
+
+/// A render frame that ran past its tick
+#[derive(Clone, Copy, Debug, PartialEq, Serialize, Deserialize)]
+pub struct FrameDrift {
+    /// Which frame, counted from the console starting
+    pub frame: u64,
+    /// Values and effects the frame rendered
+    pub values: usize,
+    /// How long the frame took
+    pub took: Duration,
+}
+
+impl FrameDrift {
+    /// How far past the render tick the frame ran
+    pub fn drift(&self) -> Duration {
+        self.took.saturating_sub(RENDER_TICK)
+    }
+}
+
+impl fmt::Display for FrameDrift {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        write!(
+            f,
+            "frame {} took {:.2}ms, {:.2}ms over, rendering {} values",
+            self.frame,
+            self.took.as_secs_f64() * 1000.0,
+            self.drift().as_secs_f64() * 1000.0,
+            self.values
+        )
+    }
+}
+
+/// Times each render frame against the tick and keeps the worst one per cue, so a cue that
+/// ran late can be traced to the frame that held it up
+#[derive(Clone, Debug, Default)]
+pub struct FrameTimer {
+    frames: u64,
+    /// The cue playing, by list and cue index, and its worst frame so far
+    cue: Option<(usize, usize)>,
+    worst: Option<FrameDrift>,
+    completed: Vec<(usize, usize, Option<FrameDrift>)>,
+}
+
+impl FrameTimer {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Count a frame that took `took` rendering `values`, with `cue` the cue playing
+    pub fn record(
+        &mut self,
+        cue: Option<(usize, usize)>,
+        took: Duration,
+        values: usize,
+    ) -> FrameDrift {
+        if cue != self.cue {
+            let worst = self.worst.take();
+            if let Some((list_index, cue_index)) = self.cue {
+                self.completed.push((list_index, cue_index, worst));
+            }
+            self.cue = cue;
+        }
+        self.frames += 1;
+        let frame = FrameDrift {
+            frame: self.frames,
+            values,
+            took,
+        };
+        if !frame.drift().is_zero() && self.worst.map_or(true, |worst| took > worst.took) {
+            self.worst = Some(frame);
+        }
+        frame
+    }
+
+    /// The frame that ran furthest past its tick while the current cue played
+    pub fn worst(&self) -> Option<FrameDrift> {
+        self.worst
+    }
+
+    /// Cues that have given way to another since last asked, by list and cue index, each
+    /// with its worst frame
+    pub fn take_completed(&mut self) -> Vec<(usize, usize, Option<FrameDrift>)> {
+        std::mem::take(&mut self.completed)
+    }
+}
+
+/// Set the calling thread's niceness, lower for the scheduler to favour it. Going below 0
+/// needs root or CAP_SYS_NICE.
+#[cfg(target_os = "linux")]
+pub fn set_thread_niceness(niceness: i32) -> Result<(), String> {
+    // On Linux, a thread ID given to setpriority sets that thread alone
+    let result = unsafe {
+        let thread = libc::syscall(libc::SYS_gettid) as libc::id_t;
+        libc::setpriority(libc::PRIO_PROCESS, thread, niceness)
+    };
+    if result != 0 {
+        return Err(format!(
+            "Couldn't set thread niceness to {niceness}: {}",
+            std::io::Error::last_os_error()
+        ));
+    }
+    Ok(())
+}
+
+/// Thread priority is only set on Linux. Elsewhere threads keep the process's.
+#[cfg(not(target_os = "linux"))]
+pub fn set_thread_niceness(_niceness: i32) -> Result<(), String> {
+    log::debug!("Thread niceness is only set on Linux");
+    Ok(())
+}
+
+/// Run `task` on an OS thread of its own, with a runtime of its own, so nothing else is
+/// scheduled on the thread between its ticks. The thread is set to `niceness` first if given,
+/// carrying on at normal priority if that isn't allowed.
+///
+/// The handle returned finishes when the task does, so callers can wait on it as they would a
+/// spawned task. It must be called within a Tokio runtime.
+pub fn spawn_pinned<F>(
+    name: &str,
+    niceness: Option<i32>,
+    task: F,
+) -> Result<JoinHandle<()>, std::io::Error>
+where
+    F: Future<Output = ()> + Send + 'static,
+{
+    let (done_tx, done_rx) = tokio::sync::oneshot::channel::<()>();
+    let thread_name = name.to_string();
+    std::thread::Builder::new()
+        .name(name.to_string())
+        .spawn(move || {
+            if let Some(niceness) = niceness {
+                match set_thread_niceness(niceness) {
+                    Ok(()) => log::info!("{thread_name} running at niceness {niceness}"),
+                    Err(e) => log::warn!("{e}, {thread_name} runs at normal priority"),
+                }
+            }
+            match tokio::runtime::Builder::new_current_thread()
+                .enable_all()
+                .build()
+            {
+                Ok(runtime) => runtime.block_on(task),
+                Err(e) => log::error!("Couldn't start a runtime for {thread_name}: {e}"),
+            }
+            let _ = done_tx.send(());
+        })?;
+    Ok(tokio::spawn(async move {
+        let _ = done_rx.await;
+    }))
+}
@@ -0,0 +1,639 @@
+use std::collections::HashMap;
+use std::fmt::{self, Write as _};
+use std::fs::File;
+use std::io::{BufRead, BufReader, BufWriter, Write};
+use std::path::Path;
+use std::str::FromStr;
+use std::time::{Duration, Instant};
+
+use halo_fixtures::Fixture;
+use serde::de::DeserializeOwned;
+use serde::{Deserialize, Serialize};
+
+use crate::build_info::BuildInfo;
+use crate::rhythm::rhythm::RhythmState;
+use crate::timecode::timecode::TimeCode;
+
+/// Written at the top of every recording. Readers take any version up to this one, so bump
+/// it when the frame layout changes and keep reading the older layouts.
+pub const RECORDING_VERSION: u32 = 1;
+
+const RECORDING_FORMAT: &str = "halo-dmxrec";
+
+/// How often recordings and captures are flushed to disk, so a crash loses little of them
+const FLUSH_INTERVAL: Duration = Duration::from_secs(1);
+
+/// Where in the music a frame was sent, counted from 1 like a metronome reads it
+#[derive(Clone, Copy, Debug, PartialEq, Eq, PartialOrd, Ord, Serialize, Deserialize)]
+pub struct MusicalPosition {
+    pub phrase: u32,
+    pub bar: u32,
+    pub beat: u32,
+}
+
+impl MusicalPosition {
+    /// The position `beats` into the show
+    pub fn from_beats(beats: f64, beats_per_bar: u32, bars_per_phrase: u32) -> Self {
+        let beats_per_bar = beats_per_bar.max(1) as u64;
+        let bars_per_phrase = bars_per_phrase.max(1) as u64;
+        let beat = beats.max(0.0) as u64;
+        let bar = beat / beats_per_bar;
+        Self {
+            phrase: (bar / bars_per_phrase) as u32 + 1,
+            bar: (bar % bars_per_phrase) as u32 + 1,
+            beat: (beat % beats_per_bar) as u32 + 1,
+        }
+    }
+}
+
+impl fmt::Display for MusicalPosition {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        write!(f, "{}.{}.{}", self.phrase, self.bar, self.beat)
+    }
+}
+
+impl FromStr for MusicalPosition {
+    type Err = String;
+
+    /// Parse `phrase.bar.beat`, e.g. `2.3.1`
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        let parts: Vec<&str> = s.split('.').collect();
+        let [phrase, bar, beat] = parts.as_slice() else {
+            return Err(format!("Invalid position '{s}', expected phrase.bar.beat"));
+        };
+        let part = |p: &str| match p.parse::<u32>() {
+            Ok(n) if n > 0 => Ok(n),
+            _ => Err(format!("Invalid position '{s}', counts start from 1")),
+        };
+        Ok(Self {
+            phrase: part(phrase)?,
+            bar: part(bar)?,
+            beat: part(beat)?,
+        })
+    }
+}
+
+/// A patched fixture, as it was when recording started
+#[derive(Clone, Debug, Serialize, Deserialize)]
+pub struct RecordedFixture {
+    pub id: usize,
+    pub name: String,
+    pub universe: u8,
+    /// Each channel's name and absolute address
+    pub channels: Vec<(String, u16)>,
+}
+
+/// The first line of a recording
+#[derive(Clone, Debug, Serialize, Deserialize)]
+pub struct RecordingHeader {
+    pub format: String,
+    pub version: u32,
+    pub show: String,
+    /// Hash of the show file, if the show came from one
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub show_hash: Option<String>,
+    /// The halo that made the recording, missing from recordings made before it was noted
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub build: Option<BuildInfo>,
+    pub fixtures: Vec<RecordedFixture>,
+}
+
+/// One frame of output, holding only the channels that changed since the frame before
+#[derive(Clone, Debug, Serialize, Deserialize)]
+pub struct RecordedFrame {
+    /// Milliseconds since recording started
+    pub millis: u64,
+    /// Where in the music since recording started. Jumps back when the beat grid is edited or
+    /// Link moves the beat.
+    pub position: MusicalPosition,
+    /// The show timecode, from audio, LTC or the playback clock, as `HH:MM:SS:FF`
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub timecode: Option<String>,
+    /// Universe, address and value of each changed channel
+    pub changes: Vec<(u8, u16, u8)>,
+}
+
+/// A file of one JSON line after another, as recordings and output captures are written:
+/// a header naming the format and its version, then a line per frame
+pub(crate) struct JsonLines {
+    writer: BufWriter<File>,
+    last_flush: Option<Instant>,
+    /// What's being written, for errors
+    what: &'static str,
+}
+
+impl JsonLines {
+    pub(crate) fn create(path: &Path, what: &'static str) -> Result<Self, String> {
+        let file =
+            File::create(path).map_err(|e| format!("Couldn't create {}: {e}", path.display()))?;
+        Ok(Self {
+            writer: BufWriter::new(file),
+            last_flush: None,
+            what,
+        })
+    }
+
+    pub(crate) fn write_line<T: Serialize>(&mut self, line: &T) -> Result<(), String> {
+        serde_json::to_writer(&mut self.writer, line)
+            .map_err(|e| format!("Couldn't write {}: {e}", self.what))?;
+        self.writer
+            .write_all(b"\n")
+            .map_err(|e| format!("Couldn't write {}: {e}", self.what))
+    }
+
+    /// Flush to disk if it's been a while since the last flush
+    pub(crate) fn flush_after(&mut self, now: Instant) -> Result<(), String> {
+        let last_flush = *self.last_flush.get_or_insert(now);
+        if now.duration_since(last_flush) >= FLUSH_INTERVAL {
+            self.last_flush = Some(now);
+            self.writer
+                .flush()
+                .map_err(|e| format!("Couldn't write {}: {e}", self.what))?;
+        }
+        Ok(())
+    }
+}
+
+impl Drop for JsonLines {
+    fn drop(&mut self) {
+        let _ = self.writer.flush();
+    }
+}
+
+/// The format and version every header starts with
+#[derive(Deserialize)]
+struct Stamp {
+    format: String,
+    version: u32,
+}
+
+/// Read back a file written with [`JsonLines`], refusing one of another format or a newer
+/// version than `version`. Blank lines are skipped.
+pub(crate) fn read_json_lines<H: DeserializeOwned, L: DeserializeOwned>(
+    path: &Path,
+    format: &str,
+    version: u32,
+    what: &str,
+) -> Result<(H, Vec<L>), String> {
+    let file = File::open(path).map_err(|e| format!("Couldn't read {}: {e}", path.display()))?;
+    let mut lines = BufReader::new(file).lines();
+    let parse_error = |line: usize, e: &dyn fmt::Display| {
+        format!("Couldn't parse {} line {line}: {e}", path.display())
+    };
+
+    let first = lines
+        .next()
+        .ok_or_else(|| format!("{} is empty", path.display()))?
+        .map_err(|e| parse_error(1, &e))?;
+    let stamp: Stamp = serde_json::from_str(&first).map_err(|e| parse_error(1, &e))?;
+    if stamp.format != format {
+        return Err(format!("{} isn't a halo {what}", path.display()));
+    }
+    if stamp.version > version {
+        return Err(format!(
+            "{} is a version {} {what}, this halo reads up to version {version}",
+            path.display(),
+            stamp.version
+        ));
+    }
+    let header: H = serde_json::from_str(&first).map_err(|e| parse_error(1, &e))?;
+
+    let mut frames = Vec::new();
+    for (index, line) in lines.enumerate() {
+        let line = line.map_err(|e| parse_error(index + 2, &e))?;
+        if line.trim().is_empty() {
+            continue;
+        }
+        frames.push(serde_json::from_str(&line).map_err(|e| parse_error(index + 2, &e))?);
+    }
+    Ok((header, frames))
+}
+
+/// Writes the console's output to a `.dmxrec` file: a header line with the patch, then one
+/// JSON line per frame that changed anything.
+///
+/// The header is written with the first frame that has fixtures patched, so a recording
+/// started before the show loads still knows its fixtures.
+pub struct DmxRecorder {
+    lines: JsonLines,
+    show_hash: Option<String>,
+    started: Option<Instant>,
+    /// Beats the console had counted when recording started
+    start_beats: f64,
+    universes: HashMap<u8, Vec<u8>>,
+}
+
+impl DmxRecorder {
+    pub fn create(path: &Path) -> Result<Self, String> {
+        Ok(Self {
+            lines: JsonLines::create(path, "recording")?,
+            show_hash: None,
+            started: None,
+            start_beats: 0.0,
+            universes: HashMap::new(),
+        })
+    }
+
+    /// Note the hash of the show file being recorded, for the header
+    pub fn set_show_hash(&mut self, hash: Option<&str>) {
+        if self.show_hash.as_deref() != hash {
+            self.show_hash = hash.map(str::to_string);
+        }
+    }
+
+    /// Record this frame's output, given every universe and not only those that changed, at
+    /// `beats` counted by the console
+    pub fn record<'a>(
+        &mut self,
+        now: Instant,
+        show: &str,
+        fixtures: &[Fixture],
+        beats: f64,
+        rhythm_state: &RhythmState,
+        timecode: Option<&TimeCode>,
+        universes: impl IntoIterator<Item = (u8, &'a [u8])>,
+    ) -> Result<(), String> {
+        let started = match self.started {
+            Some(started) => started,
+            None if fixtures.is_empty() => return Ok(()),
+            None => {
+                self.write_header(show, fixtures)?;
+                self.started = Some(now);
+                self.start_beats = beats;
+                now
+            }
+        };
+
+        let mut changes = Vec::new();
+        for (universe, data) in universes {
+            let last = self.universes.entry(universe).or_default();
+            last.resize(data.len(), 0);
+            for (index, (&value, previous)) in data.iter().zip(last.iter_mut()).enumerate() {
+                if value != *previous {
+                    changes.push((universe, index as u16 + 1, value));
+                    *previous = value;
+                }
+            }
+        }
+
+        if !changes.is_empty() {
+            changes.sort_unstable();
+            let frame = RecordedFrame {
+                millis: now.duration_since(started).as_millis() as u64,
+                position: MusicalPosition::from_beats(
+                    beats - self.start_beats,
+                    rhythm_state.beats_per_bar,
+                    rhythm_state.bars_per_phrase,
+                ),
+                timecode: timecode.map(format_timecode),
+                changes,
+            };
+            self.lines.write_line(&frame)?;
+        }
+        self.lines.flush_after(now)
+    }
+
+    fn write_header(&mut self, show: &str, fixtures: &[Fixture]) -> Result<(), String> {
+        let header = RecordingHeader {
+            format: RECORDING_FORMAT.to_string(),
+            version: RECORDING_VERSION,
+            show: show.to_string(),
+            show_hash: self.show_hash.clone(),
+            build: Some(BuildInfo::current()),
+            fixtures: fixtures
+                .iter()
+                .map(|fixture| RecordedFixture {
+                    id: fixture.id,
+                    name: fixture.name.clone(),
+                    universe: fixture.universe,
+                    channels: fixture.channel_map(),
+                })
+                .collect(),
+        };
+        self.lines.write_line(&header)
+    }
+}
+
+fn format_timecode(timecode: &TimeCode) -> String {
+    format!(
+        "{:02}:{:02}:{:02}:{:02}",
+        timecode.hours, timecode.minutes, timecode.seconds, timecode.frames
+    )
+}
+
+/// A recording read back for inspection
+#[derive(Clone, Debug)]
+pub struct Recording {
+    pub header: RecordingHeader,
+    pub frames: Vec<RecordedFrame>,
+}
+
+impl Recording {
+    pub fn read(path: &Path) -> Result<Self, String> {
+        let (header, frames) =
+            read_json_lines(path, RECORDING_FORMAT, RECORDING_VERSION, "recording")?;
+        Ok(Self { header, frames })
+    }
+
+    /// The fixture with this name, ignoring case, or this ID
+    pub fn fixture_named(&self, name: &str) -> Option<&RecordedFixture> {
+        let fixtures = &self.header.fixtures;
+        fixtures
+            .iter()
+            .find(|f| f.name.eq_ignore_ascii_case(name))
+            .or_else(|| {
+                let id: usize = name.parse().ok()?;
+                fixtures.iter().find(|f| f.id == id)
+            })
+    }
+
+    /// Output before the recording first reached `position` and at the end of that stretch,
+    /// with what changed on the way. Positions jump back on beat grid edits and Link updates,
+    /// so the same position can come round again later, which is only counted.
+    pub fn diff_at(&self, position: MusicalPosition) -> PositionDiff {
+        let stretches = self.stretches_at(position);
+        let (before, after) = stretches.first().copied().unwrap_or((0, 0));
+        let mut output = self.output_until(before);
+        let frames = &self.frames[before..after];
+
+        let mut changes: Vec<ChannelChange> = Vec::new();
+        for frame in frames {
+            for &(universe, address, value) in &frame.changes {
+                let slot = output.entry((universe, address)).or_insert(0);
+                match changes
+                    .iter_mut()
+                    .find(|c| c.universe == universe && c.address == address)
+                {
+                    Some(change) => change.after = value,
+                    None => changes.push(ChannelChange {
+                        universe,
+                        address,
+                        channel: self.channel_name(universe, address),
+                        before: *slot,
+                        after: value,
+                    }),
+                }
+                *slot = value;
+            }
+        }
+        changes.retain(|c| c.before != c.after);
+        changes.sort_by_key(|c| (c.universe, c.address));
+
+        PositionDiff {
+            position,
+            start: frames.first().map(|f| Duration::from_millis(f.millis)),
+            end: frames.last().map(|f| Duration::from_millis(f.millis)),
+            timecode: frames.first().and_then(|f| f.timecode.clone()),
+            changes,
+            repeats: stretches.len().saturating_sub(1),
+        }
+    }
+
+    /// The start and end of each run of frames at `position`, in the order they were recorded
+    fn stretches_at(&self, position: MusicalPosition) -> Vec<(usize, usize)> {
+        let mut stretches: Vec<(usize, usize)> = Vec::new();
+        for (index, frame) in self.frames.iter().enumerate() {
+            if frame.position != position {
+                continue;
+            }
+            match stretches.last_mut() {
+                Some((_, end)) if *end == index => *end = index + 1,
+                _ => stretches.push((index, index + 1)),
+            }
+        }
+        stretches
+    }
+
+    /// One fixture's channels over the frames that changed them, optionally only those at
+    /// `position`, every time the recording was there
+    pub fn fixture_history(
+        &self,
+        fixture: &RecordedFixture,
+        position: Option<MusicalPosition>,
+    ) -> FixtureHistory {
+        let mut values: Vec<u8> = vec![0; fixture.channels.len()];
+        let mut rows = Vec::new();
+        for frame in &self.frames {
+            let mut changed = false;
+            for &(universe, address, value) in &frame.changes {
+                if universe != fixture.universe {
+                    continue;
+                }
+                if let Some(index) = fixture.channels.iter().position(|(_, a)| *a == address) {
+                    values[index] = value;
+                    changed = true;
+                }
+            }
+            if changed && position.is_none_or(|position| frame.position == position) {
+                rows.push(HistoryRow {
+                    time: Duration::from_millis(frame.millis),
+                    position: frame.position,
+                    timecode: frame.timecode.clone(),
+                    values: values.clone(),
+                });
+            }
+        }
+
+        FixtureHistory {
+            name: fixture.name.clone(),
+            channels: fixture
+                .channels
+                .iter()
+                .map(|(name, _)| name.clone())
+                .collect(),
+            rows,
+        }
+    }
+
+    /// Every channel's value once the first `frames` frames have played
+    fn output_until(&self, frames: usize) -> HashMap<(u8, u16), u8> {
+        let mut output = HashMap::new();
+        for frame in &self.frames[..frames] {
+            for &(universe, address, value) in &frame.changes {
+                output.insert((universe, address), value);
+            }
+        }
+        output
+    }
+
+    fn channel_name(&self, universe: u8, address: u16) -> Option<String> {
+        self.header
+            .fixtures
+            .iter()
+            .filter(|f| f.universe == universe)
+            .find_map(|f| {
+                let (name, _) = f.channels.iter().find(|(_, a)| *a == address)?;
+                Some(format!("{} {name}", f.name))
+            })
+    }
+}
+
+impl fmt::Display for Recording {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        writeln!(
+            f,
+            "{} (recording version {})",
+            self.header.show, self.header.version
+        )?;
+        if let Some(build) = &self.header.build {
+            writeln!(f, "  Made by:  {build}")?;
+        }
+        if let Some(hash) = &self.header.show_hash {
+            writeln!(f, "  Show:     {hash}")?;
+        }
+        writeln!(f, "  Fixtures: {}", self.header.fixtures.len())?;
+        writeln!(f, "  Frames:   {}", self.frames.len())?;
+        if let (Some(first), Some(last)) = (self.frames.first(), self.frames.last()) {
+            writeln!(
+                f,
+                "  Length:   {} ({} to {})",
+                format_time(Duration::from_millis(last.millis)),
+                first.position,
+                last.position
+            )?;
+        }
+        Ok(())
+    }
+}
+
+/// One channel that changed over a musical position
+#[derive(Clone, Debug, PartialEq)]
+pub struct ChannelChange {
+    pub universe: u8,
+    pub address: u16,
+    /// The fixture and channel at the address, if one was patched there
+    pub channel: Option<String>,
+    pub before: u8,
+    pub after: u8,
+}
+
+/// What changed in the output over one beat
+#[derive(Clone, Debug)]
+pub struct PositionDiff {
+    pub position: MusicalPosition,
+    /// Time of the first and last frames within the beat
+    pub start: Option<Duration>,
+    pub end: Option<Duration>,
+    pub timecode: Option<String>,
+    pub changes: Vec<ChannelChange>,
+    /// Later stretches of the recording at the same position, after it jumped back
+    pub repeats: usize,
+}
+
+impl fmt::Display for PositionDiff {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        let (Some(start), Some(end)) = (self.start, self.end) else {
+            return writeln!(f, "Nothing was recorded at {}", self.position);
+        };
+        write!(
+            f,
+            "At {} ({} to {}",
+            self.position,
+            format_time(start),
+            format_time(end)
+        )?;
+        if let Some(timecode) = &self.timecode {
+            write!(f, ", timecode {timecode}")?;
+        }
+        writeln!(f, ")")?;
+        if self.repeats > 0 {
+            writeln!(
+                f,
+                "  The recording came back to {} {} more time{} after the beat moved",
+                self.position,
+                self.repeats,
+                if self.repeats == 1 { "" } else { "s" }
+            )?;
+        }
+        if self.changes.is_empty() {
+            return writeln!(f, "  No changes");
+        }
+
+        let name_width = self
+            .changes
+            .iter()
+            .map(|c| c.channel.as_deref().unwrap_or("").len())
+            .max()
+            .unwrap_or(0);
+        let mut universe = None;
+        for change in &self.changes {
+            if universe != Some(change.universe) {
+                universe = Some(change.universe);
+                writeln!(f, "  Universe {}", change.universe)?;
+            }
+            writeln!(
+                f,
+                "    {:>3}  {:<name_width$}  {:>3} -> {:>3}",
+                change.address,
+                change.channel.as_deref().unwrap_or(""),
+                change.before,
+                change.after
+            )?;
+        }
+        Ok(())
+    }
+}
+
+/// A fixture's channel values after a frame that changed them
+#[derive(Clone, Debug)]
+pub struct HistoryRow {
+    pub time: Duration,
+    pub position: MusicalPosition,
+    pub timecode: Option<String>,
+    pub values: Vec<u8>,
+}
+
+/// One fixture's channels over time
+#[derive(Clone, Debug)]
+pub struct FixtureHistory {
+    pub name: String,
+    pub channels: Vec<String>,
+    pub rows: Vec<HistoryRow>,
+}
+
+impl fmt::Display for FixtureHistory {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        writeln!(f, "{}", self.name)?;
+        if self.rows.is_empty() {
+            return writeln!(f, "  No changes");
+        }
+        let with_timecode = self.rows.iter().any(|r| r.timecode.is_some());
+
+        let mut line = format!("  {:>9}  {:<8}", "Time", "Position");
+        if with_timecode {
+            line.push_str("  Timecode   ");
+        }
+        for channel in &self.channels {
+            let _ = write!(line, "  {channel:>3}");
+        }
+        writeln!(f, "{}", line.trim_end())?;
+
+        for row in &self.rows {
+            let mut line = format!(
+                "  {:>9}  {:<8}",
+                format_time(row.time),
+                row.position.to_string()
+            );
+            if with_timecode {
+                let _ = write!(line, "  {:<11}", row.timecode.as_deref().unwrap_or("-"));
+            }
+            for (channel, value) in self.channels.iter().zip(&row.values) {
+                let width = channel.len().max(3);
+                let _ = write!(line, "  {value:>width$}");
+            }
+            writeln!(f, "{line}")?;
+        }
+        Ok(())
+    }
+}
+
+/// `m:ss.mmm`
+fn format_time(time: Duration) -> String {
+    let millis = time.as_millis();
+    format!(
+        "{}:{:02}.{:03}",
+        millis / 60_000,
+        millis / 1000 % 60,
+        millis % 1000
+    )
+}
@@ -0,0 +1,282 @@
+use std::collections::{HashMap, HashSet};
+use std::time::Duration;
+
+use halo_fixtures::{Fixture, FixtureType};
+
+/// How often the console renders a frame, about 44 times a second. A fade shorter than this
+/// has no frame to show a step in.
+pub const RENDER_TICK: Duration = Duration::from_millis(23);
+
+/// Frames are sent in lengths that are a multiple of this, which keeps them even for Art-Net
+const FRAME_ROUNDING: usize = 8;
+
+/// Where a fixture was last written and what it wrote
+#[derive(Debug, Clone, PartialEq)]
+struct Snapshot {
+    universe: u8,
+    start: usize,
+    values: Vec<u8>,
+}
+
+impl Snapshot {
+    fn of(fixture: &Fixture) -> Self {
+        Self {
+            universe: fixture.universe,
+            start: (fixture.start_address.saturating_sub(1) as usize).min(512),
+            values: fixture.get_dmx_values(),
+        }
+    }
+
+    /// Whether the fixture still writes exactly this, checked without copying its values
+    fn matches(&self, fixture: &Fixture) -> bool {
+        self.universe == fixture.universe
+            && self.start == (fixture.start_address.saturating_sub(1) as usize).min(512)
+            && self.values.len() == fixture.channels.len()
+            && self
+                .values
+                .iter()
+                .enumerate()
+                .all(|(offset, value)| *value == fixture.dmx_value(offset))
+    }
+
+    /// One past the last channel it writes
+    fn end(&self) -> usize {
+        (self.start + self.values.len()).min(512)
+    }
+
+    fn write(&self, buffer: &mut [u8]) {
+        // Channels past the end of the universe are dropped
+        let end = self.end();
+        buffer[self.start..end].copy_from_slice(&self.values[..end - self.start]);
+    }
+}
+
+/// The last frame sent for each universe, kept so only what changed is rendered.
+///
+/// A fixture's channels are copied into its universe in one go, in the profile's channel
+/// order, and universes are handed to the DMX module whole. A fixture never goes out with some
+/// of a frame's channels and not others, e.g. a PAR's new strobe value ahead of its dimmer.
+///
+/// Each frame, a fixture's output is compared with what it wrote last time. Fixtures that
+/// haven't changed are skipped and universes with nothing new aren't sent at all, which leaves
+/// an idle rig costing next to nothing. The DMX module resends the last data it was given
+/// every `dmx_keep_alive_secs`, so skipped universes stay live on the wire.
+///
+/// A universe is rebuilt from scratch when a fixture in it is repatched or removed, or when
+/// pixel output covers it, so nothing stale is left behind.
+///
+/// Universes go out only as far as their highest patched channel, rounded up, which matters on
+/// serial links where a frame takes time in proportion to its length. Patching past the end
+/// lengthens the frame. Unpatching never shortens it, so the channels a fixture leaves are
+/// zeroed on the receiver rather than held at their last values.
+#[derive(Debug, Clone, Default)]
+pub struct FrameCache {
+    universes: HashMap<u8, Vec<u8>>,
+    fixtures: HashMap<usize, Snapshot>,
+    pixel_universes: HashSet<u8>,
+    lengths: HashMap<u8, usize>,
+    full_frames: bool,
+    scratch: Scratch,
+}
+
+/// Working sets for a frame, kept between frames so rendering one allocates little more than
+/// the universes it hands over
+#[derive(Debug, Clone, Default)]
+struct Scratch {
+    rebuild: HashSet<u8>,
+    /// Fixtures whose output changed
+    dirty: Vec<usize>,
+    patched: HashSet<usize>,
+    changed: HashSet<u8>,
+    order: Vec<u8>,
+}
+
+impl Scratch {
+    /// Empty every set, keeping what each has allocated
+    fn clear(&mut self) {
+        self.rebuild.clear();
+        self.dirty.clear();
+        self.patched.clear();
+        self.changed.clear();
+        self.order.clear();
+    }
+}
+
+impl FrameCache {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Send all 512 channels of every universe, for receivers that won't take shorter frames
+    pub fn set_full_frames(&mut self, full_frames: bool) {
+        if self.full_frames != full_frames {
+            // Start over so every universe goes out again at its new length
+            *self = Self {
+                full_frames,
+                ..Self::default()
+            };
+        }
+    }
+
+    /// Lengthen the frame sent for `universe` to cover its first `channels`, returning whether
+    /// it grew
+    fn cover(&mut self, universe: u8, channels: usize) -> bool {
+        let length = if self.full_frames {
+            512
+        } else {
+            (channels.div_ceil(FRAME_ROUNDING) * FRAME_ROUNDING).clamp(FRAME_ROUNDING, 512)
+        };
+        let sent = self.lengths.entry(universe).or_insert(0);
+        let grew = length > *sent;
+        *sent = (*sent).max(length);
+        grew
+    }
+
+    /// Render fixtures over the pixel engine's output, returning the universes that changed
+    /// since the last frame
+    pub fn render(
+        &mut self,
+        fixtures: &[Fixture],
+        pixel_universes: HashMap<u8, Vec<u8>>,
+    ) -> Vec<(u8, Vec<u8>)> {
+        let fixtures = || {
+            fixtures
+                .iter()
+                .filter(|f| f.profile.fixture_type != FixtureType::PixelBar)
+        };
+        let mut scratch = std::mem::take(&mut self.scratch);
+        scratch.clear();
+
+        let mut patched = 0;
+        for fixture in fixtures() {
+            patched += 1;
+            let start = (fixture.start_address.saturating_sub(1) as usize).min(512);
+            match self.fixtures.get_mut(&fixture.id) {
+                Some(last) if last.matches(fixture) => continue,
+                // Same place, new values, so they're copied over what it wrote last time
+                Some(last)
+                    if last.universe == fixture.universe
+                        && last.start == start
+                        && last.values.len() == fixture.channels.len() =>
+                {
+                    last.values.clear();
+                    last.values
+                        .extend(fixture.channels.iter().map(|channel| channel.value));
+                }
+                // Repatched or changed mode, so clear what it covered before
+                Some(last) => {
+                    scratch.rebuild.extend([last.universe, fixture.universe]);
+                    *last = Snapshot::of(fixture);
+                }
+                None => {
+                    scratch.rebuild.insert(fixture.universe);
+                    self.fixtures.insert(fixture.id, Snapshot::of(fixture));
+                }
+            }
+            scratch.dirty.push(fixture.id);
+        }
+
+        // Unpatched fixtures leave their channels to be cleared
+        if self.fixtures.len() > patched {
+            scratch.patched.extend(fixtures().map(|f| f.id));
+            let (still_patched, rebuild) = (&scratch.patched, &mut scratch.rebuild);
+            self.fixtures.retain(|id, last| {
+                let keep = still_patched.contains(id);
+                if !keep {
+                    rebuild.insert(last.universe);
+                }
+                keep
+            });
+        }
+
+        // Universes pixels used to cover go back to fixtures only
+        scratch.rebuild.extend(
+            self.pixel_universes
+                .iter()
+                .filter(|u| !pixel_universes.contains_key(u)),
+        );
+        self.pixel_universes.clear();
+        self.pixel_universes.extend(pixel_universes.keys());
+
+        for (universe, buffer) in &pixel_universes {
+            if self.cover(*universe, buffer.len()) {
+                scratch.changed.insert(*universe);
+            }
+        }
+        for id in &scratch.dirty {
+            let snapshot = &self.fixtures[id];
+            let (universe, end) = (snapshot.universe, snapshot.end());
+            if !scratch.rebuild.contains(&universe) {
+                let buffer = self
+                    .universes
+                    .entry(universe)
+                    .or_insert_with(|| vec![0; 512]);
+                snapshot.write(buffer);
+                scratch.changed.insert(universe);
+            }
+            if self.cover(universe, end) {
+                scratch.changed.insert(universe);
+            }
+        }
+
+        let mut fresh: HashMap<u8, Vec<u8>> = scratch
+            .rebuild
+            .iter()
+            .map(|universe| (*universe, vec![0; 512]))
+            .collect();
+        fresh.extend(pixel_universes);
+        for (universe, buffer) in fresh.iter_mut() {
+            for fixture in fixtures() {
+                if let Some(snapshot) = self.fixtures.get(&fixture.id) {
+                    if snapshot.universe == *universe {
+                        snapshot.write(buffer);
+                    }
+                }
+            }
+        }
+        for (universe, buffer) in fresh {
+            if self.universes.get(&universe) != Some(&buffer) {
+                self.universes.insert(universe, buffer);
+                scratch.changed.insert(universe);
+            }
+        }
+
+        scratch.order.extend(&scratch.changed);
+        scratch.order.sort_unstable();
+        let frames = scratch
+            .order
+            .iter()
+            .map(|universe| {
+                let buffer = &self.universes[universe];
+                let length = self
+                    .lengths
+                    .get(universe)
+                    .copied()
+                    .unwrap_or(512)
+                    .min(buffer.len());
+                (*universe, buffer[..length].to_vec())
+            })
+            .collect();
+        self.scratch = scratch;
+        frames
+    }
+
+    /// How many channels of a universe go out each frame
+    pub fn frame_length(&self, universe: u8) -> Option<usize> {
+        self.lengths.get(&universe).copied()
+    }
+
+    /// The last frame rendered for a universe
+    pub fn universe(&self, universe: u8) -> Option<&[u8]> {
+        self.universes
+            .get(&universe)
+            .map(|buffer| buffer.as_slice())
+    }
+
+    /// Every universe as last rendered
+    pub fn universes(&self) -> impl Iterator<Item = (u8, &[u8])> {
+        self.universes
+            .iter()
+            .map(|(universe, buffer)| (*universe, buffer.as_slice()))
+    }
+}
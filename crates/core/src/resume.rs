@@ -0,0 +1,110 @@
+use std::path::{Path, PathBuf};
+use std::time::{Duration, Instant, SystemTime, UNIX_EPOCH};
+
+use serde::{Deserialize, Serialize};
+
+use crate::cue::fade::FadeKey;
+use crate::{BuildInfo, StaticValue};
+
+/// How often playback state is saved between cue changes
+const SAVE_INTERVAL: Duration = Duration::from_secs(5);
+
+/// How long restored fixtures take to fade up from black
+pub(crate) const RESUME_FADE: Duration = Duration::from_secs(2);
+
+/// Where playback was, saved as the show runs so a restart after a crash can pick up from it
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ResumeState {
+    /// Seconds since the Unix epoch
+    pub saved_at: u64,
+    /// The list playback was in
+    pub cue_list: String,
+    /// The cue the next Go runs, or none if the list had finished
+    pub next_cue: Option<usize>,
+    /// Tracked channel values from every cue run so far
+    pub values: Vec<StaticValue>,
+    pub grand_master: f32,
+    pub bpm: f64,
+    /// Hash of the show file playing, if the show came from one
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub show_hash: Option<String>,
+    /// The halo that saved the state, missing from state saved before it was noted
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub build: Option<BuildInfo>,
+}
+
+impl ResumeState {
+    /// Read a saved state, ignoring it with a log line if it's older than `max_age`
+    pub fn load(path: &Path, max_age: Duration) -> Result<Option<Self>, String> {
+        let data = std::fs::read_to_string(path)
+            .map_err(|e| format!("Couldn't read {}: {e}", path.display()))?;
+        let state: Self = serde_json::from_str(&data)
+            .map_err(|e| format!("Couldn't parse {}: {e}", path.display()))?;
+
+        let age = state.age(SystemTime::now());
+        if age > max_age {
+            log::info!(
+                "Ignoring resume state in {}, saved {}s ago",
+                path.display(),
+                age.as_secs()
+            );
+            return Ok(None);
+        }
+        Ok(Some(state))
+    }
+
+    /// Write the state, replacing the file in one step so a crash mid-write can't corrupt it
+    pub fn save(&self, path: &Path) -> Result<(), String> {
+        let data = serde_json::to_string(self).map_err(|e| e.to_string())?;
+        let tmp = path.with_extension("tmp");
+        std::fs::write(&tmp, data)
+            .and_then(|_| std::fs::rename(&tmp, path))
+            .map_err(|e| format!("Couldn't write {}: {e}", path.display()))
+    }
+
+    /// How long ago the state was saved
+    pub fn age(&self, now: SystemTime) -> Duration {
+        let now = now.duration_since(UNIX_EPOCH).unwrap_or_default();
+        now.saturating_sub(Duration::from_secs(self.saved_at))
+    }
+}
+
+/// Seconds since the Unix epoch, for stamping saved state
+pub(crate) fn unix_now() -> u64 {
+    SystemTime::now()
+        .duration_since(UNIX_EPOCH)
+        .unwrap_or_default()
+        .as_secs()
+}
+
+/// Decides when the console saves its resume state: whenever a cue starts and every few
+/// seconds in between
+pub(crate) struct ResumeWriter {
+    pub path: PathBuf,
+    last_save: Option<Instant>,
+    last_cue: Option<FadeKey>,
+}
+
+impl ResumeWriter {
+    pub fn new(path: PathBuf) -> Self {
+        Self {
+            path,
+            last_save: None,
+            last_cue: None,
+        }
+    }
+
+    /// Whether to save now, given the cue that's running
+    pub fn due(&mut self, now: Instant, cue: Option<FadeKey>) -> bool {
+        let cue_changed = cue != self.last_cue;
+        let interval_passed = self
+            .last_save
+            .map_or(true, |last| now.duration_since(last) >= SAVE_INTERVAL);
+        if !cue_changed && !interval_passed {
+            return false;
+        }
+        self.last_save = Some(now);
+        self.last_cue = cue;
+        true
+    }
+}
@@ -1,4 +1,4 @@
-use std::time::Instant;
+use std::time::{Duration, Instant};
 
 use serde::{Deserialize, Serialize};
 
@@ -14,9 +14,103 @@ pub struct RhythmState {
     pub tap_count: u32,
 }
 
+impl RhythmState {
+    /// Move every phase to where it is `beat_time` beats in
+    pub fn set_beat_time(&mut self, beat_time: f64) {
+        self.beat_phase = beat_time.fract();
+        self.bar_phase = (beat_time / self.beats_per_bar as f64).fract();
+        self.phrase_phase =
+            (beat_time / (self.beats_per_bar * self.bars_per_phrase) as f64).fract();
+    }
+}
+
 #[derive(Clone, Debug, Serialize, Deserialize)]
 pub enum Interval {
     Beat,
     Bar,
     Phrase,
 }
+
+/// Tempo and time signature, for turning beats into time
+#[derive(Clone, Copy, Debug, PartialEq)]
+pub struct Meter {
+    pub bpm: f64,
+    pub beats_per_bar: u32,
+    pub bars_per_phrase: u32,
+}
+
+impl Default for Meter {
+    fn default() -> Self {
+        Self {
+            bpm: 120.0,
+            beats_per_bar: 4,
+            bars_per_phrase: 4,
+        }
+    }
+}
+
+impl Meter {
+    /// Beats in one interval
+    pub fn beats_in(&self, interval: &Interval) -> f64 {
+        match interval {
+            Interval::Beat => 1.0,
+            Interval::Bar => self.beats_per_bar as f64,
+            Interval::Phrase => (self.beats_per_bar * self.bars_per_phrase) as f64,
+        }
+    }
+
+    /// How long `beats` beats take at this tempo
+    pub fn duration_of(&self, beats: f64) -> Duration {
+        Duration::from_secs_f64((beats * 60.0 / self.bpm.max(1.0)).max(0.0))
+    }
+}
+
+/// An edit to the beat grid, for lining the metronome up with a track that's playing
+#[derive(Clone, Copy, Debug, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum BeatGridEdit {
+    /// Halve the tempo, carrying on from the same point in the beat
+    Half,
+    /// Double the tempo, carrying on from the same point in the beat
+    Double,
+    /// Make now the start of beat 1 of a bar
+    AlignBeat,
+    /// Count the downbeat this many beats later, leaving the beat itself where it is
+    ShiftBeat(i32),
+    /// Make the bar playing now bar 1 of a phrase, leaving the beat where it is
+    AlignBar,
+}
+
+impl BeatGridEdit {
+    /// The tempo and beat time after the edit, made `beat_time` beats in at `tempo`.
+    ///
+    /// Alignments go to the nearest bar or phrase, so an edit made just early or just late
+    /// lands on the downbeat it was meant for. The beat time never goes below zero.
+    pub fn apply(
+        self,
+        tempo: f64,
+        beat_time: f64,
+        beats_per_bar: u32,
+        bars_per_phrase: u32,
+    ) -> (f64, f64) {
+        let bar = beats_per_bar.max(1) as f64;
+        let phrase = bar * bars_per_phrase.max(1) as f64;
+        let edited = match self {
+            BeatGridEdit::Half => return (tempo / 2.0, beat_time),
+            BeatGridEdit::Double => return (tempo * 2.0, beat_time),
+            BeatGridEdit::AlignBeat => (beat_time / bar).round() * bar,
+            BeatGridEdit::ShiftBeat(beats) => beat_time + beats as f64,
+            BeatGridEdit::AlignBar => {
+                let bar_start = (beat_time / bar).round() * bar;
+                let phrase_start = (bar_start / phrase).round() * phrase;
+                beat_time + phrase_start - bar_start
+            }
+        };
+        let edited = if edited < 0.0 {
+            edited + phrase * (-edited / phrase).ceil()
+        } else {
+            edited
+        };
+        (tempo, edited)
+    }
+}
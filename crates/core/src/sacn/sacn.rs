@@ -0,0 +1,232 @@
+use std::collections::HashMap;
+use std::net::{IpAddr, Ipv4Addr, SocketAddr, UdpSocket};
+
+use log::debug;
+
+/// The port every sACN receiver listens on
+pub const SACN_PORT: u16 = 5568;
+
+/// Priority sources send at unless told otherwise, mid way between 0 and 200
+pub const DEFAULT_PRIORITY: u8 = 100;
+
+/// Highest priority E1.31 allows
+pub const MAX_PRIORITY: u8 = 200;
+
+/// Bytes before the DMX slots in a data packet
+const HEADER_LENGTH: usize = 126;
+
+const ACN_PACKET_IDENTIFIER: [u8; 12] = *b"ASC-E1.17\0\0\0";
+const VECTOR_ROOT_E131_DATA: u32 = 0x0000_0004;
+const VECTOR_E131_DATA_PACKET: u32 = 0x0000_0002;
+const VECTOR_DMP_SET_PROPERTY: u8 = 0x02;
+
+/// Options bit telling receivers the source has stopped sending the universe
+const STREAM_TERMINATED: u8 = 0x40;
+
+/// How to send sACN, E1.31 streaming DMX, for nodes that take it instead of Art-Net
+#[derive(Clone, Debug)]
+pub struct SacnConfig {
+    /// Shown by receivers that list their sources
+    pub source_name: String,
+    /// Identifies this source to receivers, and should stay the same across restarts
+    pub cid: [u8; 16],
+    /// Priority for universes without their own, from 0 to 200
+    pub priority: u8,
+    pub universe_priorities: HashMap<u8, u8>,
+    /// Address of the network interface to send from
+    pub interface: IpAddr,
+    /// Receivers to send every universe to directly, instead of multicasting
+    pub unicast: Vec<SocketAddr>,
+}
+
+impl SacnConfig {
+    /// Multicast from `interface` at the default priority, with a CID worked out from the
+    /// source name so it's the same every run
+    pub fn new(interface: IpAddr, source_name: &str) -> Self {
+        Self {
+            source_name: source_name.to_string(),
+            cid: cid_for(source_name),
+            priority: DEFAULT_PRIORITY,
+            universe_priorities: HashMap::new(),
+            interface,
+            unicast: Vec::new(),
+        }
+    }
+
+    pub fn priority_for(&self, universe: u8) -> u8 {
+        self.universe_priorities
+            .get(&universe)
+            .copied()
+            .unwrap_or(self.priority)
+            .min(MAX_PRIORITY)
+    }
+
+    /// Where a universe's packets go
+    pub fn destinations(&self, universe: u8) -> Vec<SocketAddr> {
+        if self.unicast.is_empty() {
+            vec![SocketAddr::new(
+                IpAddr::V4(multicast_address(universe.into())),
+                SACN_PORT,
+            )]
+        } else {
+            self.unicast.clone()
+        }
+    }
+}
+
+/// The multicast group a universe is sent to, 239.255.x.x
+pub fn multicast_address(universe: u16) -> Ipv4Addr {
+    let [high, low] = universe.to_be_bytes();
+    Ipv4Addr::new(239, 255, high, low)
+}
+
+/// A stable CID for a source name, so receivers see the same source after a restart
+fn cid_for(source_name: &str) -> [u8; 16] {
+    // Two FNV-1a hashes with different offsets, for 128 bits
+    let hash = |offset: u64| {
+        source_name.bytes().fold(offset, |hash, byte| {
+            (hash ^ byte as u64).wrapping_mul(0x0000_0100_0000_01b3)
+        })
+    };
+    let mut cid = [0; 16];
+    cid[..8].copy_from_slice(&hash(0xcbf2_9ce4_8422_2325).to_be_bytes());
+    cid[8..].copy_from_slice(&hash(0x6c62_272e_07bb_0142).to_be_bytes());
+    // Mark it as a version 4 UUID, the way receivers expect a CID to look
+    cid[6] = (cid[6] & 0x0f) | 0x40;
+    cid[8] = (cid[8] & 0x3f) | 0x80;
+    cid
+}
+
+/// One E1.31 data packet: a universe's DMX slots with the source's details
+#[derive(Clone, Debug, PartialEq)]
+pub struct DataPacket<'a> {
+    pub cid: [u8; 16],
+    pub source_name: &'a str,
+    pub priority: u8,
+    pub sequence: u8,
+    pub universe: u16,
+    /// Whether this is one of the last packets before the source stops sending the universe
+    pub terminated: bool,
+    /// DMX slots, without the start code
+    pub data: &'a [u8],
+}
+
+impl DataPacket<'_> {
+    pub fn to_bytes(&self) -> Vec<u8> {
+        let data = &self.data[..self.data.len().min(512)];
+        let length = HEADER_LENGTH + data.len();
+        // Each layer's length counts from the start of its own flags and length
+        let flags_and_length = |from: usize| 0x7000 | (length - from) as u16;
+
+        let mut packet = Vec::with_capacity(length);
+        // Root layer
+        packet.extend_from_slice(&0x0010u16.to_be_bytes());
+        packet.extend_from_slice(&0x0000u16.to_be_bytes());
+        packet.extend_from_slice(&ACN_PACKET_IDENTIFIER);
+        packet.extend_from_slice(&flags_and_length(16).to_be_bytes());
+        packet.extend_from_slice(&VECTOR_ROOT_E131_DATA.to_be_bytes());
+        packet.extend_from_slice(&self.cid);
+
+        // Framing layer
+        packet.extend_from_slice(&flags_and_length(38).to_be_bytes());
+        packet.extend_from_slice(&VECTOR_E131_DATA_PACKET.to_be_bytes());
+        let mut source_name = [0u8; 64];
+        let name = self.source_name.as_bytes();
+        // Leave room for the terminating null
+        let name_length = name.len().min(63);
+        source_name[..name_length].copy_from_slice(&name[..name_length]);
+        packet.extend_from_slice(&source_name);
+        packet.push(self.priority.min(MAX_PRIORITY));
+        packet.extend_from_slice(&0u16.to_be_bytes()); // No synchronization universe
+        packet.push(self.sequence);
+        packet.push(if self.terminated {
+            STREAM_TERMINATED
+        } else {
+            0
+        });
+        packet.extend_from_slice(&self.universe.to_be_bytes());
+
+        // DMP layer
+        packet.extend_from_slice(&flags_and_length(115).to_be_bytes());
+        packet.push(VECTOR_DMP_SET_PROPERTY);
+        packet.push(0xa1); // Address and data type
+        packet.extend_from_slice(&0u16.to_be_bytes()); // First property address
+        packet.extend_from_slice(&1u16.to_be_bytes()); // Address increment
+        packet.extend_from_slice(&(data.len() as u16 + 1).to_be_bytes());
+        packet.push(0); // DMX start code
+        packet.extend_from_slice(data);
+        packet
+    }
+}
+
+/// Sends universes as sACN, keeping each universe's sequence number
+pub struct Sacn {
+    socket: UdpSocket,
+    config: SacnConfig,
+    sequences: HashMap<u8, u8>,
+}
+
+impl Sacn {
+    pub fn new(config: SacnConfig) -> Result<Self, anyhow::Error> {
+        // Use an ephemeral port, only using the interface's address to pick where to send from
+        let socket = UdpSocket::bind(SocketAddr::new(config.interface, 0))?;
+        if let IpAddr::V4(interface) = config.interface {
+            if !interface.is_unspecified() {
+                socket.set_multicast_if_v4(&interface)?;
+            }
+        }
+        socket.set_multicast_ttl_v4(8)?;
+        debug!(
+            "sACN set up OK on local port {} as '{}'",
+            socket.local_addr()?.port(),
+            config.source_name
+        );
+        Ok(Self {
+            socket,
+            config,
+            sequences: HashMap::new(),
+        })
+    }
+
+    pub fn send_data(&mut self, universe: u8, dmx: &[u8]) -> Result<(), anyhow::Error> {
+        self.send(universe, dmx, false)
+    }
+
+    /// Tell receivers the universes are no longer being sent, so they stop holding the last
+    /// frame from this source
+    pub fn terminate(&mut self, universes: &[u8]) -> Result<(), anyhow::Error> {
+        for universe in universes {
+            // E1.31 asks for three, in case one goes missing
+            for _ in 0..3 {
+                self.send(*universe, &[], true)?;
+            }
+        }
+        Ok(())
+    }
+
+    fn send(&mut self, universe: u8, dmx: &[u8], terminated: bool) -> Result<(), anyhow::Error> {
+        let sequence = self.sequences.entry(universe).or_insert(0);
+        let packet = DataPacket {
+            cid: self.config.cid,
+            source_name: &self.config.source_name,
+            priority: self.config.priority_for(universe),
+            sequence: *sequence,
+            universe: universe.into(),
+            terminated,
+            data: dmx,
+        }
+        .to_bytes();
+        *sequence = sequence.wrapping_add(1);
+
+        // Try every receiver before reporting one that failed
+        let mut failed = None;
+        for destination in self.config.destinations(universe) {
+            if let Err(e) = self.socket.send_to(&packet, destination) {
+                failed = Some(anyhow::anyhow!(
+                    "Couldn't send universe {universe} to {destination} over sACN: {e}"
+                ));
+            }
+        }
+        failed.map_or(Ok(()), Err)
+    }
+}
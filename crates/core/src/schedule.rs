@@ -0,0 +1,132 @@
+use std::time::{Duration, Instant};
+
+use serde::{Deserialize, Serialize};
+
+use crate::StaticValue;
+
+/// Housekeeping to run at a time relative to the start of the show, e.g.
+/// `{"name": "Smoke", "at": {"secs": 600, "nanos": 0}, "action": {"type": "hold", ...}}`
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ScheduledEvent {
+    pub name: String,
+    /// Time after the show clock starts
+    pub at: Duration,
+    pub action: ScheduledAction,
+    /// What to do when the event is added after its time has already passed
+    #[serde(default)]
+    pub late: LatePolicy,
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize)]
+#[serde(tag = "type", rename_all = "lowercase")]
+pub enum ScheduledAction {
+    /// Advance a cue list, starting it from its first cue if another list is running
+    Go {
+        #[serde(rename = "cuelist")]
+        cue_list: String,
+    },
+    /// Jump to a named cue
+    Goto {
+        #[serde(rename = "cuelist")]
+        cue_list: String,
+        cue: String,
+    },
+    /// Hold static values over playback until the show clock stops
+    Hold { values: Vec<StaticValue> },
+}
+
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum LatePolicy {
+    /// Run the event straight away
+    #[default]
+    Fire,
+    /// Drop the event for this run of the show
+    Skip,
+}
+
+#[derive(Debug, Clone)]
+struct Entry {
+    event: ScheduledEvent,
+    done: bool,
+}
+
+/// Events scheduled against a show clock that starts when the show does, rather than when
+/// the console boots.
+///
+/// Time comes from the caller, so the console's clock drives it and tests can use a manual
+/// clock. Nothing fires until the show clock starts.
+#[derive(Debug, Clone, Default)]
+pub struct ShowSchedule {
+    entries: Vec<Entry>,
+    started: Option<Instant>,
+}
+
+impl ShowSchedule {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Start the show clock at `now`. Restarting it runs every event again.
+    pub fn start(&mut self, now: Instant) {
+        self.started = Some(now);
+        for entry in &mut self.entries {
+            entry.done = false;
+        }
+    }
+
+    pub fn stop(&mut self) {
+        self.started = None;
+    }
+
+    pub fn is_running(&self) -> bool {
+        self.started.is_some()
+    }
+
+    /// Time since the show clock started
+    pub fn elapsed(&self, now: Instant) -> Option<Duration> {
+        self.started.map(|started| now.duration_since(started))
+    }
+
+    /// Add an event. One whose time has already passed fires on the next poll or is skipped,
+    /// depending on its late policy.
+    pub fn add(&mut self, event: ScheduledEvent, now: Instant) {
+        let done = event.late == LatePolicy::Skip
+            && self.elapsed(now).is_some_and(|elapsed| event.at < elapsed);
+        if done {
+            log::info!(
+                "Skipping scheduled event '{}', its time has passed",
+                event.name
+            );
+        }
+        self.entries.push(Entry { event, done });
+    }
+
+    /// Replace every event, e.g. when a show loads
+    pub fn set_events(&mut self, events: Vec<ScheduledEvent>, now: Instant) {
+        self.entries.clear();
+        for event in events {
+            self.add(event, now);
+        }
+    }
+
+    pub fn events(&self) -> Vec<ScheduledEvent> {
+        self.entries.iter().map(|e| e.event.clone()).collect()
+    }
+
+    /// Events that have come due since the last poll, earliest first
+    pub fn poll(&mut self, now: Instant) -> Vec<ScheduledEvent> {
+        let Some(elapsed) = self.elapsed(now) else {
+            return Vec::new();
+        };
+        let mut due: Vec<ScheduledEvent> = Vec::new();
+        for entry in self.entries.iter_mut() {
+            if !entry.done && entry.event.at <= elapsed {
+                entry.done = true;
+                due.push(entry.event.clone());
+            }
+        }
+        due.sort_by_key(|event| event.at);
+        due
+    }
+}
@@ -0,0 +1,143 @@
+use std::collections::HashMap;
+use std::fmt;
+
+use halo_fixtures::{Fixture, PanTilt};
+use serde::Serialize;
+
+use crate::PositionPresets;
+
+/// Find a fixture by name or alias, ignoring case, or by ID. Finding it by an alias also
+/// returns a warning naming the fixture it now goes by.
+pub fn find_fixture<'a>(
+    fixtures: &'a [Fixture],
+    name: &str,
+) -> Option<(&'a Fixture, Option<String>)> {
+    if let Some(fixture) = fixtures.iter().find(|f| f.name.eq_ignore_ascii_case(name)) {
+        return Some((fixture, None));
+    }
+    if let Some(fixture) = fixtures
+        .iter()
+        .find(|f| f.aliases.iter().any(|a| a.eq_ignore_ascii_case(name)))
+    {
+        return Some((fixture, Some(alias_warning(name, fixture))));
+    }
+    let id: usize = name.parse().ok()?;
+    fixtures.iter().find(|f| f.id == id).map(|f| (f, None))
+}
+
+fn alias_warning(alias: &str, fixture: &Fixture) -> String {
+    format!(
+        "'{alias}' is an alias of {}, refer to it by name instead",
+        fixture.name
+    )
+}
+
+/// One error for each alias that is another fixture's name or appears more than once, so a
+/// name can only ever mean one fixture
+pub fn alias_collisions(fixtures: &[Fixture]) -> Vec<String> {
+    let mut errors = Vec::new();
+    for (index, fixture) in fixtures.iter().enumerate() {
+        for alias in &fixture.aliases {
+            if let Some(named) = fixtures.iter().find(|f| f.name.eq_ignore_ascii_case(alias)) {
+                errors.push(format!(
+                    "Alias '{alias}' of {} is the name of {}",
+                    fixture.name, named.name
+                ));
+            }
+            let repeated = fixtures[index..]
+                .iter()
+                .flat_map(|f| &f.aliases)
+                .filter(|a| a.eq_ignore_ascii_case(alias));
+            let error = format!("Alias '{alias}' is given more than once");
+            if repeated.count() > 1 && !errors.contains(&error) {
+                errors.push(error);
+            }
+        }
+    }
+    errors
+}
+
+/// Look up a fixture's entry in a venue's position preset, falling back to its aliases. An
+/// entry found by alias also returns a warning, so the venue config can be migrated in time.
+pub fn preset_entry(
+    preset: &HashMap<String, PanTilt>,
+    fixture: &Fixture,
+) -> Option<(PanTilt, Option<String>)> {
+    if let Some(entry) = preset.get(&fixture.name) {
+        return Some((*entry, None));
+    }
+    fixture.aliases.iter().find_map(|alias| {
+        preset
+            .get(alias)
+            .map(|entry| (*entry, Some(alias_warning(alias, fixture))))
+    })
+}
+
+/// Where one fixture alias is still referred to
+#[derive(Clone, Debug, Serialize)]
+pub struct AliasUsage {
+    pub alias: String,
+    /// The fixture's current name
+    pub fixture: String,
+    /// Position presets with an entry under the alias rather than the fixture's name
+    pub position_presets: Vec<String>,
+}
+
+/// Every alias in a show and what still uses it, for migrating off old names
+#[derive(Clone, Debug, Default, Serialize)]
+pub struct AliasReport {
+    pub aliases: Vec<AliasUsage>,
+    /// Collisions that stop the show loading
+    pub errors: Vec<String>,
+}
+
+impl fmt::Display for AliasReport {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        if self.aliases.is_empty() && self.errors.is_empty() {
+            return Ok(());
+        }
+        writeln!(f, "Aliases:")?;
+        for usage in &self.aliases {
+            let used = if usage.position_presets.is_empty() {
+                "unused, safe to remove".to_string()
+            } else {
+                format!(
+                    "used by position presets {}",
+                    usage.position_presets.join(", ")
+                )
+            };
+            writeln!(f, "  {:<30} {:<30} {used}", usage.alias, usage.fixture)?;
+        }
+        for error in &self.errors {
+            writeln!(f, "Error: {error}")?;
+        }
+        Ok(())
+    }
+}
+
+/// Report each fixture alias with the venue position presets that still use it, along with
+/// any collisions
+pub fn analyze_aliases(fixtures: &[Fixture], presets: &PositionPresets) -> AliasReport {
+    let mut report = AliasReport {
+        errors: alias_collisions(fixtures),
+        ..AliasReport::default()
+    };
+    for fixture in fixtures {
+        for alias in &fixture.aliases {
+            let mut position_presets: Vec<String> = presets
+                .iter()
+                .filter(|(_, entries)| {
+                    !entries.contains_key(&fixture.name) && entries.contains_key(alias)
+                })
+                .map(|(name, _)| name.clone())
+                .collect();
+            position_presets.sort();
+            report.aliases.push(AliasUsage {
+                alias: alias.clone(),
+                fixture: fixture.name.clone(),
+                position_presets,
+            });
+        }
+    }
+    report
+}
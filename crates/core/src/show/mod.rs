@@ -1,2 +1,6 @@
+pub mod alias;
 pub mod show;
 pub mod show_manager;
+pub mod usage;
+pub mod variables;
+pub mod workspace;
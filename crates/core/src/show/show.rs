@@ -1,17 +1,39 @@
+use std::collections::{BTreeMap, HashMap};
+use std::path::Path;
 use std::time::SystemTime;
 
-use halo_fixtures::Fixture;
-use serde::{Deserialize, Serialize};
+use halo_fixtures::{Fixture, FixtureLibrary};
+use serde::{Deserialize, Deserializer, Serialize, Serializer};
+use serde_json::Value;
 
-use crate::CueList;
+use crate::show::alias::find_fixture;
+use crate::show::variables::{substitute, variable_refs, Variables};
+use crate::{Cue, CueList, ExclusionGroup, Looks, ScheduledEvent};
 
+// The derived impls are wrapped below to fill in and write back the cues' `$name` references
 #[derive(Debug, Serialize, Deserialize, Clone)]
+#[serde(remote = "Self")]
 pub struct Show {
     pub name: String,
     pub created_at: SystemTime,
     pub modified_at: SystemTime,
     pub fixtures: Vec<Fixture>,
     pub cue_lists: Vec<CueList>,
+    /// Housekeeping run against the show clock
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub schedule: Vec<ScheduledEvent>,
+    /// Named colors for effect color overrides
+    #[serde(default, skip_serializing_if = "HashMap::is_empty")]
+    pub palettes: HashMap<String, (u8, u8, u8)>,
+    /// Named sets of values that cues can build on
+    #[serde(default, skip_serializing_if = "HashMap::is_empty")]
+    pub looks: Looks,
+    /// Fixtures only one cue list may drive at a time
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub exclusion_groups: Vec<ExclusionGroup>,
+    /// Values cues use by `$name`, e.g. `"accent": "#FF2288"` or `"chorus_rate": "1b"`
+    #[serde(default, skip_serializing_if = "BTreeMap::is_empty")]
+    pub vars: Variables,
     pub version: String, // Schema version for future compatibility
 }
 
@@ -24,7 +46,104 @@ impl Show {
             modified_at: now,
             fixtures: Vec::new(),
             cue_lists: Vec::new(),
+            schedule: Vec::new(),
+            palettes: HashMap::new(),
+            looks: Looks::new(),
+            exclusion_groups: Vec::new(),
+            vars: Variables::new(),
             version: env!("CARGO_PKG_VERSION").to_string(),
         }
     }
+
+    /// Read a show file, resolving each fixture's channels from the built-in library
+    pub fn read(path: &Path) -> Result<Self, anyhow::Error> {
+        Self::read_with(path, &FixtureLibrary::new())
+    }
+
+    /// Read a show file, resolving each fixture's channels from `library`, e.g. one with a
+    /// profiles directory loaded into it
+    pub fn read_with(path: &Path, library: &FixtureLibrary) -> Result<Self, anyhow::Error> {
+        let mut show: Show = serde_json::from_str(&std::fs::read_to_string(path)?)?;
+        for fixture in &mut show.fixtures {
+            let profile = library
+                .profiles
+                .get(&fixture.profile_id)
+                .ok_or_else(|| anyhow::anyhow!("Profile {} not found", fixture.profile_id))?;
+            fixture.profile = profile.clone();
+            fixture.channels = profile
+                .layout(fixture.mode)
+                .map_err(|e| anyhow::anyhow!(e))?;
+        }
+        Ok(show)
+    }
+
+    /// Find a fixture by name or alias, ignoring case, or by ID
+    pub fn fixture_named(&self, name: &str) -> Option<&Fixture> {
+        find_fixture(&self.fixtures, name).map(|(fixture, _)| fixture)
+    }
+}
+
+impl<'de> Deserialize<'de> for Show {
+    /// Cue fields written as `$name` are noted and given the variable's value, so the cues
+    /// parse as usual and can be bound again when the variable changes
+    fn deserialize<D: Deserializer<'de>>(deserializer: D) -> Result<Self, D::Error> {
+        use serde::de::Error;
+
+        let mut value = Value::deserialize(deserializer)?;
+        let vars: Variables = match value.get("vars") {
+            Some(vars) => serde_json::from_value(vars.clone()).map_err(D::Error::custom)?,
+            None => Variables::new(),
+        };
+
+        let mut bound = Vec::new();
+        let lists = value.get_mut("cue_lists").and_then(Value::as_array_mut);
+        for (list_index, list) in lists.into_iter().flatten().enumerate() {
+            let cues = list.get_mut("cues").and_then(Value::as_array_mut);
+            for (cue_index, cue) in cues.into_iter().flatten().enumerate() {
+                let refs = variable_refs(cue);
+                if refs.is_empty() {
+                    continue;
+                }
+                let name = cue.get("name").and_then(Value::as_str).unwrap_or_default();
+                let invalid = |e: &dyn std::fmt::Display| {
+                    D::Error::custom(format!("Cue '{name}' in list {list_index}: {e}"))
+                };
+                let mut filled = cue.clone();
+                substitute(&mut filled, &refs, &vars).map_err(|e| invalid(&e))?;
+                // Parsed alone first, so a variable of the wrong sort names its cue
+                Cue::deserialize(filled.clone()).map_err(|e| invalid(&e))?;
+                *cue = filled;
+                bound.push((list_index, cue_index, refs));
+            }
+        }
+
+        let mut show = Show::deserialize(value).map_err(D::Error::custom)?;
+        for (list_index, cue_index, refs) in bound {
+            show.cue_lists[list_index].cues[cue_index].variables = refs;
+        }
+        Ok(show)
+    }
+}
+
+impl Serialize for Show {
+    /// Cue fields bound to a variable are written as the `$name` they came from, not the
+    /// value they have now
+    fn serialize<S: Serializer>(&self, serializer: S) -> Result<S::Ok, S::Error> {
+        use serde::ser::Error;
+
+        let mut value =
+            Show::serialize(self, serde_json::value::Serializer).map_err(S::Error::custom)?;
+        for (list_index, list) in self.cue_lists.iter().enumerate() {
+            for (cue_index, cue) in list.cues.iter().enumerate() {
+                for var in &cue.variables {
+                    let pointer =
+                        format!("/cue_lists/{list_index}/cues/{cue_index}{}", var.pointer);
+                    if let Some(field) = value.pointer_mut(&pointer) {
+                        *field = Value::String(format!("${}", var.name));
+                    }
+                }
+            }
+        }
+        value.serialize(serializer)
+    }
 }
@@ -66,13 +66,21 @@ impl ShowManager {
     }
 
     pub fn load_show(&mut self, path: &Path) -> Result<Show> {
+        let show = Self::read_show(path)?;
+        self.set_current(show.clone(), path);
+        Ok(show)
+    }
+
+    /// Read a show file without making it the current show
+    pub fn read_show(path: &Path) -> Result<Show> {
         let file = File::open(path)?;
-        let show: Show = from_reader(file)?;
+        Ok(from_reader(file)?)
+    }
 
-        self.current_show = Some(show.clone());
+    /// Make `show` the current show, saved back to `path`
+    pub fn set_current(&mut self, show: Show, path: &Path) {
+        self.current_show = Some(show);
         self.current_path = Some(path.to_path_buf());
-
-        Ok(show)
     }
 
     pub fn list_shows(&self) -> Result<Vec<PathBuf>> {
@@ -0,0 +1,184 @@
+use std::fmt;
+
+use halo_fixtures::{ChannelType, Fixture};
+use serde::Serialize;
+
+use crate::CueList;
+
+/// Where a patched fixture is used, and which of its channels nothing touches
+#[derive(Clone, Debug, Serialize)]
+pub struct FixtureUsage {
+    pub fixture_id: usize,
+    pub name: String,
+    /// Cues that reference the fixture, e.g. "Cue 'Verse' in 'Main'"
+    pub referenced_by: Vec<String>,
+    /// Channel types in the fixture's layout that no cue sets
+    pub unused_channels: Vec<ChannelType>,
+    /// Cues that set a position on the fixture when it can't move
+    pub positioned_by: Vec<String>,
+}
+
+/// Static analysis of which fixtures and channels a show's cues reach
+#[derive(Clone, Debug, Default, Serialize)]
+pub struct UsageReport {
+    /// Every patched fixture, in patch order
+    pub fixtures: Vec<FixtureUsage>,
+    /// Names of fixtures no cue references
+    pub unreferenced: Vec<String>,
+}
+
+impl UsageReport {
+    /// One warning per unreferenced fixture, per referenced fixture with untouched channels,
+    /// and per cue that tries to move a fixture that can't
+    pub fn warnings(&self) -> Vec<String> {
+        let mut warnings: Vec<String> = self
+            .unreferenced
+            .iter()
+            .map(|name| format!("{name} is patched but no cue uses it"))
+            .collect();
+        for usage in &self.fixtures {
+            for location in &usage.positioned_by {
+                warnings.push(format!(
+                    "{location} sets a position on {}, which doesn't move",
+                    usage.name
+                ));
+            }
+            if usage.referenced_by.is_empty() || usage.unused_channels.is_empty() {
+                continue;
+            }
+            let channels: Vec<String> = usage
+                .unused_channels
+                .iter()
+                .map(ToString::to_string)
+                .collect();
+            warnings.push(format!(
+                "No cue sets {} on {}",
+                channels.join(", "),
+                usage.name
+            ));
+        }
+        warnings
+    }
+}
+
+impl fmt::Display for UsageReport {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        writeln!(f, "Fixtures:")?;
+        for usage in &self.fixtures {
+            writeln!(
+                f,
+                "  {:<30} {} cue(s)",
+                usage.name,
+                usage.referenced_by.len()
+            )?;
+        }
+        writeln!(f)?;
+        let warnings = self.warnings();
+        if warnings.is_empty() {
+            writeln!(f, "Every fixture and channel is used")?;
+        }
+        for warning in warnings {
+            writeln!(f, "Warning: {warning}")?;
+        }
+        Ok(())
+    }
+}
+
+/// Work out which fixtures and channels the cue lists reach, from static values, effects,
+/// pixel effects and position presets. Fixtures need their channels resolved from their
+/// profiles first.
+pub fn analyze_usage(fixtures: &[Fixture], cue_lists: &[CueList]) -> UsageReport {
+    let mut referenced_by: Vec<Vec<String>> = vec![Vec::new(); fixtures.len()];
+    let mut touched: Vec<Vec<ChannelType>> = vec![Vec::new(); fixtures.len()];
+    let mut positioned_by: Vec<Vec<String>> = vec![Vec::new(); fixtures.len()];
+    let index_of = |id: usize| fixtures.iter().position(|f| f.id == id);
+
+    for cue_list in cue_lists {
+        for cue in &cue_list.cues {
+            let location = format!("Cue '{}' in '{}'", cue.name, cue_list.name);
+            let mut reached = |id: usize, channel_types: &[ChannelType]| {
+                let Some(index) = index_of(id) else {
+                    return;
+                };
+                if referenced_by[index].last() != Some(&location) {
+                    referenced_by[index].push(location.clone());
+                }
+                let moves = channel_types
+                    .iter()
+                    .any(|c| matches!(c, ChannelType::Pan | ChannelType::Tilt));
+                if moves
+                    && !fixtures[index].capabilities().position
+                    && positioned_by[index].last() != Some(&location)
+                {
+                    positioned_by[index].push(location.clone());
+                }
+                for channel_type in channel_types {
+                    if !touched[index].contains(channel_type) {
+                        touched[index].push(channel_type.clone());
+                    }
+                }
+            };
+
+            for value in &cue.static_values {
+                reached(value.fixture_id, std::slice::from_ref(&value.channel_type));
+            }
+            for effect in &cue.effects {
+                for id in &effect.fixture_ids {
+                    reached(*id, &effect.channel_types);
+                }
+            }
+            for position in &cue.positions {
+                reached(position.fixture_id, &[ChannelType::Pan, ChannelType::Tilt]);
+            }
+            for effect in &cue.pixel_effects {
+                for id in &effect.fixture_ids {
+                    let Some(index) = index_of(*id) else {
+                        continue;
+                    };
+                    let pixels: Vec<ChannelType> = fixtures[index]
+                        .channels
+                        .iter()
+                        .map(|c| c.channel_type.clone())
+                        .filter(|c| {
+                            matches!(
+                                c,
+                                ChannelType::PixelRed(_)
+                                    | ChannelType::PixelGreen(_)
+                                    | ChannelType::PixelBlue(_)
+                            )
+                        })
+                        .collect();
+                    reached(*id, &pixels);
+                }
+            }
+        }
+    }
+
+    let mut report = UsageReport::default();
+    for (((fixture, referenced_by), touched), positioned_by) in fixtures
+        .iter()
+        .zip(referenced_by)
+        .zip(touched)
+        .zip(positioned_by)
+    {
+        if referenced_by.is_empty() {
+            report.unreferenced.push(fixture.name.clone());
+        }
+        let mut unused_channels = Vec::new();
+        for channel in &fixture.channels {
+            if !touched.contains(&channel.channel_type)
+                && !unused_channels.contains(&channel.channel_type)
+            {
+                unused_channels.push(channel.channel_type.clone());
+            }
+        }
+        report.fixtures.push(FixtureUsage {
+            fixture_id: fixture.id,
+            name: fixture.name.clone(),
+            referenced_by,
+            unused_channels,
+            positioned_by,
+        });
+    }
+    report
+}
@@ -0,0 +1,333 @@
+use std::collections::BTreeMap;
+use std::fmt;
+use std::str::FromStr;
+
+use serde::{Deserialize, Deserializer, Serialize, Serializer};
+use serde_json::Value;
+
+use crate::cue::fade::FadeKey;
+use crate::{Cue, CueList, MusicalDuration};
+
+/// Show variables by name, as the show file's `vars` has them
+pub type Variables = BTreeMap<String, Variable>;
+
+/// OSC address prefix for setting a variable, followed by its name
+pub const VARIABLE_OSC_PREFIX: &str = "/halo/var/";
+
+/// Cue fields that are the operator's own text, never a variable reference
+const TEXT_FIELDS: [&str; 3] = ["name", "notes", "warning"];
+
+/// A value a show names once and its cues use by `$name`, e.g. the night's accent color or
+/// the chorus chase rate
+#[derive(Clone, Copy, Debug, PartialEq)]
+pub enum Variable {
+    /// Written "#RRGGBB"
+    Color(u8, u8, u8),
+    /// Written as cue fades are, e.g. "500ms" or "1b"
+    Duration(MusicalDuration),
+    Number(f64),
+}
+
+impl Variable {
+    /// What sort of value this is, for messages
+    pub fn kind(&self) -> &'static str {
+        match self {
+            Variable::Color(..) => "color",
+            Variable::Duration(_) => "duration",
+            Variable::Number(_) => "number",
+        }
+    }
+
+    /// A value of the same sort as this one, from OSC arguments: one for a number, three from
+    /// 0 to 1 for a color, or one for a duration in this one's unit, seconds for a time
+    pub fn parse_osc(&self, args: &[f32]) -> Result<Variable, String> {
+        let arg = |i: usize| {
+            args.get(i)
+                .map(|arg| *arg as f64)
+                .ok_or_else(|| format!("a {} needs {} arguments", self.kind(), self.osc_args()))
+        };
+        Ok(match self {
+            Variable::Color(..) => {
+                let level = |i: usize| arg(i).map(|v| (v.clamp(0.0, 1.0) * 255.0).round() as u8);
+                Variable::Color(level(0)?, level(1)?, level(2)?)
+            }
+            Variable::Duration(duration) => {
+                let count = arg(0)?.max(0.0);
+                Variable::Duration(match duration {
+                    MusicalDuration::Absolute(_) => {
+                        MusicalDuration::Absolute(std::time::Duration::from_secs_f64(count))
+                    }
+                    MusicalDuration::Beats(_) => MusicalDuration::Beats(count),
+                    MusicalDuration::Bars(_) => MusicalDuration::Bars(count),
+                    MusicalDuration::Phrases(_) => MusicalDuration::Phrases(count),
+                })
+            }
+            Variable::Number(_) => Variable::Number(arg(0)?),
+        })
+    }
+
+    fn osc_args(&self) -> usize {
+        match self {
+            Variable::Color(..) => 3,
+            _ => 1,
+        }
+    }
+
+    /// The value as it goes into a cue field: colors as [r, g, b] like the rest of the show
+    /// file, durations as text and whole numbers as integers, so they fit u8 fields
+    fn to_json(self) -> Value {
+        match self {
+            Variable::Color(r, g, b) => Value::from(vec![r, g, b]),
+            Variable::Duration(duration) => Value::String(duration.to_string()),
+            Variable::Number(n) if n.fract() == 0.0 && n.abs() < i64::MAX as f64 => {
+                Value::from(n as i64)
+            }
+            Variable::Number(n) => Value::from(n),
+        }
+    }
+}
+
+impl FromStr for Variable {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        let text = s.trim();
+        if let Some(hex) = text.strip_prefix('#') {
+            let channel = |i: usize| {
+                hex.get(i..i + 2)
+                    .and_then(|digits| u8::from_str_radix(digits, 16).ok())
+            };
+            return match (hex.len(), channel(0), channel(2), channel(4)) {
+                (6, Some(r), Some(g), Some(b)) => Ok(Variable::Color(r, g, b)),
+                _ => Err(format!("'{s}' isn't a color, write it as #RRGGBB")),
+            };
+        }
+        if let Ok(number) = text.parse::<f64>() {
+            return Ok(Variable::Number(number));
+        }
+        text.parse::<MusicalDuration>()
+            .map(Variable::Duration)
+            .map_err(|e| format!("'{s}' isn't a color, a number or a length of time: {e}"))
+    }
+}
+
+impl fmt::Display for Variable {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        match self {
+            Variable::Color(r, g, b) => write!(f, "#{r:02X}{g:02X}{b:02X}"),
+            Variable::Duration(duration) => write!(f, "{duration}"),
+            Variable::Number(n) => write!(f, "{n}"),
+        }
+    }
+}
+
+impl Serialize for Variable {
+    fn serialize<S: Serializer>(&self, serializer: S) -> Result<S::Ok, S::Error> {
+        match self {
+            Variable::Number(_) => self.to_json().serialize(serializer),
+            _ => serializer.serialize_str(&self.to_string()),
+        }
+    }
+}
+
+impl<'de> Deserialize<'de> for Variable {
+    fn deserialize<D: Deserializer<'de>>(deserializer: D) -> Result<Self, D::Error> {
+        match Value::deserialize(deserializer)? {
+            Value::Number(n) => n
+                .as_f64()
+                .map(Variable::Number)
+                .ok_or_else(|| serde::de::Error::custom(format!("{n} is out of range"))),
+            Value::String(text) => text.parse().map_err(serde::de::Error::custom),
+            Value::Array(channels) => match <(u8, u8, u8)>::deserialize(Value::Array(channels)) {
+                Ok((r, g, b)) => Ok(Variable::Color(r, g, b)),
+                Err(e) => Err(serde::de::Error::custom(e)),
+            },
+            other => Err(serde::de::Error::custom(format!(
+                "{other} isn't a color, a number or a length of time"
+            ))),
+        }
+    }
+}
+
+/// A cue field that takes its value from a show variable
+#[derive(Clone, Debug, PartialEq)]
+pub struct VariableRef {
+    /// Where the field is in the cue, as a JSON pointer, e.g. "/static_values/1/value"
+    pub pointer: String,
+    pub name: String,
+}
+
+/// The `$name` references in a cue as the show file has it
+pub(crate) fn variable_refs(cue: &Value) -> Vec<VariableRef> {
+    fn walk(value: &Value, pointer: &mut String, refs: &mut Vec<VariableRef>) {
+        match value {
+            Value::String(text) => {
+                if let Some(name) = reference(text) {
+                    refs.push(VariableRef {
+                        pointer: pointer.clone(),
+                        name: name.to_string(),
+                    });
+                }
+            }
+            Value::Array(items) => {
+                for (index, item) in items.iter().enumerate() {
+                    let len = pointer.len();
+                    pointer.push_str(&format!("/{index}"));
+                    walk(item, pointer, refs);
+                    pointer.truncate(len);
+                }
+            }
+            Value::Object(fields) => {
+                for (key, field) in fields {
+                    if TEXT_FIELDS.contains(&key.as_str()) {
+                        continue;
+                    }
+                    let len = pointer.len();
+                    pointer.push('/');
+                    pointer.push_str(&key.replace('~', "~0").replace('/', "~1"));
+                    walk(field, pointer, refs);
+                    pointer.truncate(len);
+                }
+            }
+            _ => {}
+        }
+    }
+
+    let mut refs = Vec::new();
+    walk(cue, &mut String::new(), &mut refs);
+    refs
+}
+
+/// The variable name in "$name"
+fn reference(text: &str) -> Option<&str> {
+    let name = text.strip_prefix('$')?;
+    let mut chars = name.chars();
+    let valid = chars
+        .next()
+        .is_some_and(|c| c.is_ascii_alphabetic() || c == '_')
+        && chars.all(|c| c.is_ascii_alphanumeric() || c == '_');
+    valid.then_some(name)
+}
+
+/// Put the variables' values into a cue's referencing fields
+pub(crate) fn substitute(
+    cue: &mut Value,
+    refs: &[VariableRef],
+    values: &Variables,
+) -> Result<(), String> {
+    for var in refs {
+        let value = values
+            .get(&var.name)
+            .ok_or_else(|| format!("${} isn't one of the show's vars", var.name))?;
+        let field = cue.pointer_mut(&var.pointer).ok_or_else(|| {
+            format!(
+                "${} is used at {}, which isn't there",
+                var.name, var.pointer
+            )
+        })?;
+        *field = value.to_json();
+    }
+    Ok(())
+}
+
+/// `cue` with its variable fields set from `values`. A variable of the wrong sort for its
+/// field, e.g. a color as a dimmer level, is an error.
+pub fn bind_cue(cue: &Cue, values: &Variables) -> Result<Cue, String> {
+    if cue.variables.is_empty() {
+        return Ok(cue.clone());
+    }
+    let invalid = |e: &dyn fmt::Display| format!("Cue '{}': {e}", cue.name);
+    let mut json = serde_json::to_value(cue).map_err(|e| invalid(&e))?;
+    substitute(&mut json, &cue.variables, values).map_err(|e| invalid(&e))?;
+    let mut bound: Cue = serde_json::from_value(json).map_err(|e| {
+        let names: Vec<String> = cue
+            .variables
+            .iter()
+            .map(|v| format!("${}", v.name))
+            .collect();
+        invalid(&format!("can't take {}: {e}", names.join(", ")))
+    })?;
+    bound.variables = cue.variables.clone();
+    Ok(bound)
+}
+
+/// The show's variables while it runs.
+///
+/// Changing one rebinds every cue that uses it, so previews and the next Go see the new
+/// value. The cue already running keeps the values it started with: a change is picked up
+/// the next time a cue runs, never partway through its fade.
+#[derive(Clone, Debug, Default)]
+pub struct ShowVariables {
+    values: Variables,
+    /// The running cue as it was when it started, if it uses variables
+    started: Option<(FadeKey, Cue)>,
+}
+
+impl ShowVariables {
+    pub fn new(values: Variables) -> Self {
+        Self {
+            values,
+            started: None,
+        }
+    }
+
+    pub fn values(&self) -> &Variables {
+        &self.values
+    }
+
+    pub fn get(&self, name: &str) -> Option<&Variable> {
+        self.values.get(name)
+    }
+
+    /// Change a variable and rebind the cues in `cue_lists` that use it. The variable must
+    /// exist and keep its sort, and every cue must take the new value, or nothing changes.
+    pub fn set(
+        &mut self,
+        name: &str,
+        value: Variable,
+        cue_lists: &mut [CueList],
+    ) -> Result<(), String> {
+        let current = self
+            .values
+            .get(name)
+            .ok_or_else(|| format!("The show has no variable ${name}"))?;
+        if current.kind() != value.kind() {
+            return Err(format!(
+                "${name} is a {}, {value} is a {}",
+                current.kind(),
+                value.kind()
+            ));
+        }
+
+        let mut values = self.values.clone();
+        values.insert(name.to_string(), value);
+        let mut bound = Vec::new();
+        for (list_index, list) in cue_lists.iter().enumerate() {
+            for (cue_index, cue) in list.cues.iter().enumerate() {
+                if cue.variables.iter().any(|v| v.name == name) {
+                    bound.push((list_index, cue_index, bind_cue(cue, &values)?));
+                }
+            }
+        }
+        for (list_index, cue_index, cue) in bound {
+            cue_lists[list_index].cues[cue_index] = cue;
+        }
+        self.values = values;
+        Ok(())
+    }
+
+    /// The cue running under `key` as it was when it started. A cue that uses variables is
+    /// held from its first frame, so a change made while it runs waits for its next run.
+    pub(crate) fn as_started(&mut self, key: FadeKey, cue: &Cue) -> Cue {
+        if cue.variables.is_empty() {
+            return cue.clone();
+        }
+        match &self.started {
+            Some((started_key, started)) if *started_key == key => started.clone(),
+            _ => {
+                self.started = Some((key, cue.clone()));
+                cue.clone()
+            }
+        }
+    }
+}
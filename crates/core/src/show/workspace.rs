@@ -0,0 +1,74 @@
+use std::collections::BTreeMap;
+use std::path::{Path, PathBuf};
+
+use super::show::Show;
+
+/// A show held in the workspace, ready to switch to
+#[derive(Debug, Clone)]
+pub struct WorkspaceShow {
+    pub path: PathBuf,
+    pub show: Show,
+}
+
+/// Shows loaded side by side under names of their own, for switching between acts without
+/// a restart. Each show keeps its own fixtures, cue lists and effects; only the active one
+/// is ever handed to playback, so nothing one show starts can outlive a switch to another.
+#[derive(Debug, Clone, Default)]
+pub struct ShowWorkspace {
+    shows: BTreeMap<String, WorkspaceShow>,
+    active: Option<String>,
+}
+
+impl ShowWorkspace {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Hold `show` under `name`, replacing any show already loaded as it
+    pub fn insert(&mut self, name: &str, path: &Path, show: Show) {
+        self.shows.insert(
+            name.to_string(),
+            WorkspaceShow {
+                path: path.to_path_buf(),
+                show,
+            },
+        );
+    }
+
+    pub fn get(&self, name: &str) -> Option<&WorkspaceShow> {
+        self.shows.get(name)
+    }
+
+    /// Keep the running copy of a show, with any edits made while it was active, for when
+    /// it's switched back to
+    pub fn update(&mut self, name: &str, show: Show) {
+        if let Some(loaded) = self.shows.get_mut(name) {
+            loaded.show = show;
+        }
+    }
+
+    /// Drop a show from the workspace. The active show can't be unloaded.
+    pub fn remove(&mut self, name: &str) -> Result<(), String> {
+        if self.active.as_deref() == Some(name) {
+            return Err(format!("'{name}' is the active show, switch away first"));
+        }
+        self.shows
+            .remove(name)
+            .map(|_| ())
+            .ok_or_else(|| format!("No show loaded as '{name}'"))
+    }
+
+    /// Names of the loaded shows, in order
+    pub fn names(&self) -> Vec<String> {
+        self.shows.keys().cloned().collect()
+    }
+
+    /// The show playback is running, if it came from the workspace
+    pub fn active(&self) -> Option<&str> {
+        self.active.as_deref()
+    }
+
+    pub fn set_active(&mut self, name: Option<&str>) {
+        self.active = name.map(str::to_string);
+    }
+}
@@ -0,0 +1,761 @@
+use std::collections::{BTreeMap, HashMap, HashSet};
+use std::fmt;
+use std::path::{Path, PathBuf};
+use std::sync::Arc;
+use std::time::{Duration, Instant};
+
+use halo_fixtures::ChannelType;
+use serde::Serialize;
+use tokio::sync::mpsc;
+
+use crate::clock::{Clock, ManualClock};
+use crate::console::LightingConsole;
+use crate::contributions::{ContributionSource, Rule};
+use crate::messages::{ConsoleCommand, ConsoleEvent, Settings};
+use crate::modules::{AsyncModule, NullDmxModule};
+use crate::motion::{Axis, MotionModel};
+use crate::patch::patch_conflicts;
+use crate::realtime::{FrameDrift, FrameTimer};
+use crate::recording::MusicalPosition;
+use crate::render::RENDER_TICK;
+use crate::rhythm::rhythm::Meter;
+use crate::show::show::Show;
+use crate::show::show_manager::ShowManager;
+use crate::timecode::timecode::TimeCode;
+use crate::StaticValue;
+
+/// Simulated console tick, matching the real update loop
+const TICK: Duration = RENDER_TICK;
+
+/// How long an operator is assumed to sit on a cue before pressing Go when nothing else
+/// advances it
+const MANUAL_CUE_HOLD: Duration = Duration::from_secs(5);
+
+/// Longest a single cue list may run before the simulation gives up on it
+const MAX_LIST_DURATION: Duration = Duration::from_secs(24 * 60 * 60);
+
+#[derive(Clone, Copy, Debug, PartialEq, Eq, Serialize)]
+pub enum Severity {
+    Warning,
+    Error,
+}
+
+#[derive(Clone, Debug, Serialize)]
+pub struct Finding {
+    pub severity: Severity,
+    pub message: String,
+}
+
+/// What to look for beyond the basic checks
+#[derive(Clone, Debug, Default)]
+pub struct SimulationOptions {
+    /// Playback speed relative to real time, or as fast as possible when `None`
+    pub speed: Option<f64>,
+    /// Seed for cue variations, so each run of a show rolls them the same way
+    pub seed: u64,
+    /// Look for fixtures that flash dark between cues
+    pub gaps: Option<GapCheck>,
+    /// Directory of extra fixture profiles, e.g. ones giving a mover's top speeds
+    pub profiles: Option<PathBuf>,
+}
+
+/// How dark and how brief a dip between two cues has to be to count as a gap
+#[derive(Clone, Copy, Debug)]
+pub struct GapCheck {
+    /// Dimmer level below which a fixture counts as dark
+    pub threshold: u8,
+    /// Dips this many ticks long or longer are taken as meant
+    pub max_ticks: usize,
+}
+
+impl Default for GapCheck {
+    fn default() -> Self {
+        Self {
+            threshold: 10,
+            max_ticks: 4,
+        }
+    }
+}
+
+/// A fixture that went dark for a few ticks between two cues that both had it lit
+#[derive(Clone, Debug, Serialize)]
+pub struct Gap {
+    pub fixture_id: usize,
+    pub fixture: String,
+    pub list_index: usize,
+    pub cue_list: String,
+    /// Index of the cue that had the fixture lit before the gap
+    pub from_cue: usize,
+    /// Index of the cue that lit it again
+    pub to_cue: usize,
+    /// Where the gap started
+    pub position: MusicalPosition,
+    /// Seconds from the start of the show
+    pub start: f64,
+    pub ticks: usize,
+    /// Dimmer level before the gap
+    pub level: u8,
+}
+
+/// An effect that asked a moving head to go faster than its profile says it can
+#[derive(Clone, Debug, Serialize)]
+pub struct MotionLag {
+    pub fixture_id: usize,
+    pub fixture: String,
+    pub axis: Axis,
+    pub cue_list: String,
+    pub effect: String,
+    /// How fast the effect runs, e.g. "2 cycles/beat"
+    pub rate: String,
+    /// Fastest the effect drove the axis, in degrees per second
+    pub speed: f32,
+    /// Fastest the head can move the axis, in degrees per second
+    pub max_speed: f32,
+    /// Furthest the head fell behind, in degrees
+    pub max_lag: f32,
+}
+
+impl MotionLag {
+    /// How far over the head's top speed the effect went, as a percentage
+    pub fn excess(&self) -> f32 {
+        (self.speed / self.max_speed - 1.0) * 100.0
+    }
+}
+
+#[derive(Clone, Debug, Serialize)]
+pub struct CueTiming {
+    pub cue_list: String,
+    pub cue: String,
+    /// Seconds from the start of the show
+    pub start: f64,
+    /// Seconds the cue was active
+    pub duration: f64,
+    /// Seconds the cue was expected to run, `None` for effects left running until a Go
+    pub estimate: Option<f64>,
+    /// Which of the cue's variations applied, by index, for cues that have any
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub variations: Option<Vec<usize>>,
+    /// The frame that ran furthest past its tick by the wall clock, if any did
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub worst_frame: Option<FrameDrift>,
+}
+
+/// Result of a dry run of a whole show
+#[derive(Clone, Debug, Default, Serialize)]
+pub struct SimulationReport {
+    pub show_name: String,
+    /// Simulated show length in seconds
+    pub total_duration: f64,
+    pub cues: Vec<CueTiming>,
+    /// Fixtures that never output a non-zero value
+    pub unused_fixtures: Vec<String>,
+    pub findings: Vec<Finding>,
+    /// Seed the cue variations were rolled from
+    pub seed: u64,
+    /// Brief dips to dark between cues, when asked to look for them
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub gaps: Vec<Gap>,
+    /// Effects that outrun moving heads with a known top speed
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub motion: Vec<MotionLag>,
+}
+
+impl SimulationReport {
+    pub fn has_errors(&self) -> bool {
+        self.findings.iter().any(|f| f.severity == Severity::Error)
+    }
+
+    fn warn(&mut self, message: String) {
+        self.findings.push(Finding {
+            severity: Severity::Warning,
+            message,
+        });
+    }
+
+    fn error(&mut self, message: String) {
+        if !self.findings.iter().any(|f| f.message == message) {
+            self.findings.push(Finding {
+                severity: Severity::Error,
+                message,
+            });
+        }
+    }
+}
+
+impl fmt::Display for SimulationReport {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        writeln!(f, "Show: {}", self.show_name)?;
+        writeln!(f, "Total duration: {:.1}s", self.total_duration)?;
+        writeln!(f)?;
+        writeln!(f, "Cues:")?;
+        for cue in &self.cues {
+            writeln!(
+                f,
+                "  [{}] {:<30} start {:>8.1}s  duration {:>7.1}s  estimate {:>10}",
+                cue.cue_list,
+                cue.cue,
+                cue.start,
+                cue.duration,
+                cue.estimate
+                    .map_or_else(|| "open-ended".to_string(), |secs| format!("{secs:.1}s"))
+            )?;
+            if let Some(variations) = &cue.variations {
+                let applied: Vec<String> = variations.iter().map(|i| i.to_string()).collect();
+                writeln!(
+                    f,
+                    "      variations applied (seed {}): {}",
+                    self.seed,
+                    if applied.is_empty() {
+                        "none".to_string()
+                    } else {
+                        applied.join(", ")
+                    }
+                )?;
+            }
+            if let Some(frame) = &cue.worst_frame {
+                writeln!(f, "      slowest {frame}")?;
+            }
+        }
+        writeln!(f)?;
+        if self.unused_fixtures.is_empty() {
+            writeln!(f, "Unused fixtures: none")?;
+        } else {
+            writeln!(f, "Unused fixtures: {}", self.unused_fixtures.join(", "))?;
+        }
+        writeln!(f)?;
+        if !self.motion.is_empty() {
+            writeln!(f, "Motion:")?;
+            for lag in &self.motion {
+                writeln!(
+                    f,
+                    "  [{}] {:<20} {:<4} {:>7.0}°/s of {:>5.0}°/s  up to {:>5.1}° behind",
+                    lag.cue_list, lag.fixture, lag.axis, lag.speed, lag.max_speed, lag.max_lag
+                )?;
+            }
+            writeln!(f)?;
+        }
+        if self.findings.is_empty() {
+            writeln!(f, "No findings")?;
+        }
+        for finding in &self.findings {
+            writeln!(f, "{:?}: {}", finding.severity, finding.message)?;
+        }
+        Ok(())
+    }
+}
+
+/// Run a show file end to end against a manual clock and a null DMX module.
+///
+/// Each cue list is played from its first cue. Timecoded cues fire when their timecode is
+/// reached; other cues are held for a fixed time before the next Go. `speed` paces the run
+/// relative to real time, or runs as fast as possible when `None`.
+pub async fn simulate_show(
+    path: &Path,
+    speed: Option<f64>,
+) -> Result<SimulationReport, anyhow::Error> {
+    simulate_show_with(
+        path,
+        &SimulationOptions {
+            speed,
+            ..SimulationOptions::default()
+        },
+    )
+    .await
+}
+
+/// [`simulate_show`], with the extra checks in `options`
+pub async fn simulate_show_with(
+    path: &Path,
+    options: &SimulationOptions,
+) -> Result<SimulationReport, anyhow::Error> {
+    let speed = options.speed;
+    let modules: Vec<Box<dyn AsyncModule>> = vec![Box::new(NullDmxModule::new())];
+    // Load leniently so a cue that references a missing fixture doesn't stop the run. The
+    // static checks still report it, against the cue lists as written.
+    let settings = Settings {
+        lenient_fixture_references: true,
+        ..Settings::default()
+    };
+    let mut console = LightingConsole::new_with_modules(120.0, settings, modules)?;
+    let clock = ManualClock::new();
+    console.set_clock(Arc::new(clock.clone())).await;
+    console.initialize().await?;
+    console.set_seed(options.seed).await;
+
+    let mut report = SimulationReport {
+        seed: options.seed,
+        ..SimulationReport::default()
+    };
+    if let Some(dir) = &options.profiles {
+        for error in console.load_profiles(dir).errors {
+            report.warn(format!("Skipped fixture profile {error}"));
+        }
+    }
+
+    if let Err(e) = console.load_show(path).await {
+        report.show_name = path.display().to_string();
+        report.error(e.to_string());
+        console.shutdown().await?;
+        return Ok(report);
+    }
+
+    let mut show = console.get_show().await;
+    report.show_name = show.name.clone();
+    show.cue_lists = ShowManager::new()?.load_show(path)?.cue_lists;
+    check_show(&show, &mut report);
+
+    let (event_tx, mut event_rx) = mpsc::unbounded_channel();
+    let mut used_fixtures = HashSet::new();
+    let show_start = clock.elapsed();
+
+    for (list_index, cue_list) in show.cue_lists.iter().enumerate() {
+        if cue_list.cues.is_empty() {
+            continue;
+        }
+
+        console
+            .process_command(
+                ConsoleCommand::GoToCue {
+                    list_index,
+                    cue_index: 0,
+                },
+                &event_tx,
+            )
+            .await?;
+        // Start the show clock so timecoded cues follow
+        console.cue_manager.write().await.show_start_time = Some(clock.now());
+
+        let list_start = clock.elapsed();
+        let mut current = 0;
+        let mut cue_start = clock.elapsed();
+        let mut gaps = options.gaps.map(GapFinder::new);
+        let mut applied = Vec::new();
+        let mut motion = MotionModel::new();
+        let mut lags: BTreeMap<(usize, Axis, String), MotionLag> = BTreeMap::new();
+        // The effect that drove each axis last tick
+        let mut driven: HashMap<(usize, Axis), String> = HashMap::new();
+        // Frames timed by the wall clock, as the show clock stands still while one renders
+        let mut frames = FrameTimer::new();
+
+        loop {
+            clock.advance(TICK);
+            // Timecoded cues fire after the frame renders, so this is the cue behind the output
+            let rendered = console.cue_manager.read().await.get_current_cue_index();
+            let frame_started = Instant::now();
+            if let Err(e) = console.update().await {
+                report.error(format!("Update failed: {e}"));
+            }
+            frames.record(
+                Some((list_index, rendered)),
+                frame_started.elapsed(),
+                console.render_count().await,
+            );
+            if rendered == current {
+                applied = console.applied_variations().await;
+            }
+            while let Ok(event) = event_rx.try_recv() {
+                if let ConsoleEvent::Error { message } = event {
+                    report.error(message);
+                }
+            }
+            for fixture in console.fixtures.read().await.iter() {
+                if fixture.channels.iter().any(|c| c.value > 0) {
+                    used_fixtures.insert(fixture.id);
+                }
+            }
+            let demands = motion.update(console.fixtures.read().await.iter(), TICK);
+            let mut driving = HashMap::new();
+            for demand in &demands {
+                let Some(fixture) = show.fixtures.iter().find(|f| f.id == demand.fixture_id) else {
+                    continue;
+                };
+                // Snaps between cues ask for any speed at all, so only effects are followed,
+                // and only from their second frame so the jump onto an effect doesn't count
+                let Some(effect) = driving_effect(&console, &fixture.name, demand.axis).await
+                else {
+                    continue;
+                };
+                let key = (demand.fixture_id, demand.axis);
+                let steady = driven.get(&key) == Some(&effect);
+                driving.insert(key, effect.clone());
+                if !steady || !demand.exceeded() {
+                    continue;
+                }
+                let lag = lags
+                    .entry((demand.fixture_id, demand.axis, effect.clone()))
+                    .or_insert_with(|| MotionLag {
+                        fixture_id: demand.fixture_id,
+                        fixture: fixture.name.clone(),
+                        axis: demand.axis,
+                        cue_list: cue_list.name.clone(),
+                        rate: effect_rate(cue_list, &effect),
+                        effect,
+                        speed: 0.0,
+                        max_speed: demand.max_speed,
+                        max_lag: 0.0,
+                    });
+                lag.speed = lag.speed.max(demand.speed);
+                lag.max_lag = lag.max_lag.max(demand.lag);
+            }
+            driven = driving;
+            if let Some(speed) = speed {
+                tokio::time::sleep(TICK.div_f64(speed)).await;
+            }
+
+            let now = clock.elapsed();
+            let index = console.cue_manager.read().await.get_current_cue_index();
+            if let Some(finder) = gaps.as_mut() {
+                let position = console.musical_position().await;
+                let found = finder.tick(
+                    &console.fixtures.read().await,
+                    rendered,
+                    position,
+                    (now - show_start).as_secs_f64(),
+                );
+                for (fixture_id, fixture, gap) in found {
+                    report.warn(format!(
+                        "{fixture} goes dark for {} ticks at {} ({:.2}s) between '{}' and '{}' in '{}'",
+                        gap.ticks,
+                        gap.position,
+                        gap.start,
+                        cue_name(cue_list, gap.from_cue),
+                        cue_name(cue_list, rendered),
+                        cue_list.name
+                    ));
+                    report.gaps.push(Gap {
+                        fixture_id,
+                        fixture,
+                        list_index,
+                        cue_list: cue_list.name.clone(),
+                        from_cue: gap.from_cue,
+                        to_cue: rendered,
+                        position: gap.position,
+                        start: gap.start,
+                        ticks: gap.ticks,
+                        level: gap.level,
+                    });
+                }
+            }
+            if index != current {
+                report.cues.push(cue_timing(
+                    cue_list,
+                    current,
+                    cue_start - show_start,
+                    now - cue_start,
+                    &applied,
+                    &console.meter().await,
+                    frames.worst(),
+                ));
+                current = index;
+                cue_start = now;
+            }
+
+            if now - list_start > MAX_LIST_DURATION {
+                report.error(format!(
+                    "Cue list '{}' did not finish within {}h",
+                    cue_list.name,
+                    MAX_LIST_DURATION.as_secs() / 3600
+                ));
+                break;
+            }
+
+            // Timecoded cues advance on their own, everything else needs a Go
+            let next_is_timed = cue_list
+                .cues
+                .iter()
+                .skip(current + 1)
+                .filter_map(|cue| cue.timecode.as_deref())
+                .any(|timecode| TimeCode::default().from_string(timecode).is_ok());
+            // and follow cues run the next one themselves
+            let follows = cue_list
+                .cues
+                .get(current)
+                .is_some_and(|cue| cue_list.resolve(cue).follow.is_some());
+            let follows = follows && current + 1 < cue_list.cues.len();
+            if next_is_timed || follows || now - cue_start < MANUAL_CUE_HOLD {
+                continue;
+            }
+
+            if current + 1 < cue_list.cues.len() {
+                console
+                    .process_command(
+                        ConsoleCommand::GoToCue {
+                            list_index,
+                            cue_index: current + 1,
+                        },
+                        &event_tx,
+                    )
+                    .await?;
+            } else {
+                report.cues.push(cue_timing(
+                    cue_list,
+                    current,
+                    cue_start - show_start,
+                    now - cue_start,
+                    &applied,
+                    &console.meter().await,
+                    frames.worst(),
+                ));
+                break;
+            }
+        }
+
+        for lag in lags.into_values() {
+            report.warn(format!(
+                "{} effect at {} exceeds {} speed by {:.0}% on {} in '{}', up to {:.1}° behind",
+                lag.effect,
+                lag.rate,
+                lag.axis,
+                lag.excess(),
+                lag.fixture,
+                lag.cue_list,
+                lag.max_lag
+            ));
+            report.motion.push(lag);
+        }
+
+        console
+            .process_command(ConsoleCommand::Stop, &event_tx)
+            .await?;
+    }
+
+    report.total_duration = (clock.elapsed() - show_start).as_secs_f64();
+    report.unused_fixtures = show
+        .fixtures
+        .iter()
+        .filter(|f| !used_fixtures.contains(&f.id))
+        .map(|f| f.name.clone())
+        .collect();
+
+    console.shutdown().await?;
+    Ok(report)
+}
+
+fn cue_timing(
+    cue_list: &crate::CueList,
+    index: usize,
+    start: Duration,
+    duration: Duration,
+    applied: &[usize],
+    meter: &Meter,
+    worst_frame: Option<FrameDrift>,
+) -> CueTiming {
+    let varied = cue_list
+        .cues
+        .get(index)
+        .is_some_and(|cue| !cue.variations.is_empty());
+    CueTiming {
+        cue_list: cue_list.name.clone(),
+        cue: cue_name(cue_list, index),
+        start: start.as_secs_f64(),
+        duration: duration.as_secs_f64(),
+        estimate: cue_list
+            .cues
+            .get(index)
+            .and_then(|cue| {
+                cue_list
+                    .resolve(cue)
+                    .at_tempo(meter)
+                    .estimated_duration(meter)
+                    .fixed()
+            })
+            .map(|estimate| estimate.as_secs_f64()),
+        variations: varied.then(|| applied.to_vec()),
+        worst_frame,
+    }
+}
+
+/// The effect behind a fixture's output on `axis` in the last frame, if it's an effect's
+/// value that went out, smoothed or not
+async fn driving_effect(console: &LightingConsole, fixture: &str, axis: Axis) -> Option<String> {
+    let channel_type = axis.channel_type();
+    let values = console.contributions(fixture).await.ok()?;
+    let value = values
+        .iter()
+        .rev()
+        .find(|v| v.channel_type == channel_type && v.rule != Rule::Scaled)?;
+    match &value.source {
+        ContributionSource::Effect(name) => Some(name.clone()),
+        _ => None,
+    }
+}
+
+/// How fast an effect in the list runs, e.g. "2 cycles/beat"
+fn effect_rate(cue_list: &crate::CueList, effect: &str) -> String {
+    cue_list
+        .cues
+        .iter()
+        .flat_map(|cue| &cue.effects)
+        .find(|mapping| mapping.name == effect)
+        .map(|mapping| {
+            let params = &mapping.effect.params;
+            format!(
+                "{} cycles/{}",
+                params.interval_ratio,
+                format!("{:?}", params.interval).to_lowercase()
+            )
+        })
+        .unwrap_or_else(|| "an unknown rate".to_string())
+}
+
+fn cue_name(cue_list: &crate::CueList, index: usize) -> String {
+    cue_list
+        .cues
+        .get(index)
+        .map(|c| c.name.clone())
+        .unwrap_or_default()
+}
+
+/// A dip that's still dark
+struct Dip {
+    from_cue: usize,
+    level: u8,
+    position: MusicalPosition,
+    start: f64,
+    ticks: usize,
+}
+
+/// Follows each fixture's dimmer tick by tick through one cue list
+struct GapFinder {
+    check: GapCheck,
+    // The cue that last had each fixture lit, and at what level
+    lit: HashMap<usize, (usize, u8)>,
+    dips: HashMap<usize, Dip>,
+}
+
+impl GapFinder {
+    fn new(check: GapCheck) -> Self {
+        Self {
+            check,
+            lit: HashMap::new(),
+            dips: HashMap::new(),
+        }
+    }
+
+    /// Take this tick's dimmer levels, returning dips that just ended soon enough to be gaps
+    fn tick(
+        &mut self,
+        fixtures: &[halo_fixtures::Fixture],
+        cue: usize,
+        position: MusicalPosition,
+        now: f64,
+    ) -> Vec<(usize, String, Dip)> {
+        let mut found = Vec::new();
+        for fixture in fixtures {
+            let Some(level) = fixture.channel_value(&ChannelType::Dimmer) else {
+                continue;
+            };
+            if level >= self.check.threshold {
+                if let Some(dip) = self.dips.remove(&fixture.id) {
+                    if dip.from_cue != cue {
+                        found.push((fixture.id, fixture.name.clone(), dip));
+                    }
+                }
+                self.lit.insert(fixture.id, (cue, level));
+            } else if let Some(dip) = self.dips.get_mut(&fixture.id) {
+                dip.ticks += 1;
+                if dip.ticks >= self.check.max_ticks {
+                    // Dark long enough to be on purpose
+                    self.dips.remove(&fixture.id);
+                    self.lit.remove(&fixture.id);
+                }
+            } else if let Some((from_cue, level)) = self.lit.remove(&fixture.id) {
+                self.dips.insert(
+                    fixture.id,
+                    Dip {
+                        from_cue,
+                        level,
+                        position,
+                        start: now,
+                        ticks: 1,
+                    },
+                );
+            }
+        }
+        found
+    }
+}
+
+/// Close gaps by holding each fixture at its earlier level through the cues that took it
+/// dark, returning what was changed. Gaps with no cue in between can't be fixed this way and
+/// are left alone.
+pub fn fix_gaps(show: &mut Show, gaps: &[Gap]) -> Vec<String> {
+    let mut changes = Vec::new();
+    for gap in gaps {
+        let Some(cue_list) = show.cue_lists.get_mut(gap.list_index) else {
+            continue;
+        };
+        let end = gap.to_cue.min(cue_list.cues.len());
+        let start = (gap.from_cue + 1).min(end);
+        for cue in &mut cue_list.cues[start..end] {
+            let value = cue
+                .static_values
+                .iter_mut()
+                .find(|v| v.fixture_id == gap.fixture_id && v.channel_type == ChannelType::Dimmer);
+            match value {
+                Some(value) => value.value = gap.level,
+                None => cue.static_values.push(StaticValue {
+                    fixture_id: gap.fixture_id,
+                    channel_type: ChannelType::Dimmer,
+                    value: gap.level,
+                }),
+            }
+            changes.push(format!(
+                "Cue '{}' in '{}' holds {} at {}",
+                cue.name, cue_list.name, gap.fixture, gap.level
+            ));
+        }
+    }
+    changes
+}
+
+/// Static checks that don't need the show to run
+fn check_show(show: &Show, report: &mut SimulationReport) {
+    for conflict in patch_conflicts(&show.fixtures) {
+        report.warn(conflict);
+    }
+
+    for cue_list in &show.cue_lists {
+        for cue in &cue_list.cues {
+            let location = format!("Cue '{}' in '{}'", cue.name, cue_list.name);
+
+            if let Some(timecode) = &cue.timecode {
+                if TimeCode::default().from_string(timecode).is_err() {
+                    report.error(format!("{location} has invalid timecode '{timecode}'"));
+                }
+            }
+
+            for id in cue.fixture_ids() {
+                if !show.fixtures.iter().any(|f| f.id == id) {
+                    report.error(format!("{location} references missing fixture {id}"));
+                }
+            }
+
+            for look in &cue.looks {
+                if !show.looks.contains_key(look) {
+                    report.error(format!("{location} uses missing look '{look}'"));
+                }
+            }
+
+            for value in &cue.static_values {
+                let Some(fixture) = show.fixtures.iter().find(|f| f.id == value.fixture_id) else {
+                    continue;
+                };
+                let Some(limits) = &fixture.pan_tilt_limits else {
+                    continue;
+                };
+                let (min, max) = match value.channel_type {
+                    ChannelType::Pan => (limits.pan_min, limits.pan_max),
+                    ChannelType::Tilt => (limits.tilt_min, limits.tilt_max),
+                    _ => continue,
+                };
+                if value.value < min || value.value > max {
+                    report.warn(format!(
+                        "{location} sets {} {} to {}, outside its limits {}-{}",
+                        fixture.name, value.channel_type, value.value, min, max
+                    ));
+                }
+            }
+        }
+    }
+}
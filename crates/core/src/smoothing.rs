@@ -0,0 +1,95 @@
+use std::time::Instant;
+
+use halo_fixtures::{ChannelType, Fixture};
+use serde::{Deserialize, Serialize};
+
+use crate::parked::ParkedChannels;
+
+/// How quickly a channel type may move, e.g. `{"channel_type": "Pan", "secs": 0.25}`
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct ChannelSmoothing {
+    pub channel_type: ChannelType,
+    /// Seconds to travel the channel's full range. Zero turns smoothing off.
+    pub secs: f32,
+}
+
+/// Smoothing on pan and tilt only, so moving heads glide rather than snap when it's turned on
+pub fn default_channel_smoothing() -> Vec<ChannelSmoothing> {
+    [ChannelType::Pan, ChannelType::Tilt]
+        .into_iter()
+        .map(|channel_type| ChannelSmoothing {
+            channel_type,
+            secs: 0.25,
+        })
+        .collect()
+}
+
+/// Limits how fast smoothed channels can move on their way out, so step changes in their
+/// values become short ramps.
+///
+/// Each channel's output moves towards the rendered value by at most the full range over the
+/// channel type's smoothing time per second. Anything already changing slower than that, like a
+/// deliberate slow fade, passes through exactly. A channel's first frame is sent as is.
+#[derive(Clone, Default)]
+pub struct ChannelSmoother {
+    parked: ParkedChannels,
+    outputs: Vec<(usize, ChannelType, f32)>,
+    last_frame: Option<Instant>,
+}
+
+impl ChannelSmoother {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Put back the rendered values the last frame held back. Call before rendering playback.
+    pub fn restore(&mut self, fixtures: &mut [Fixture]) {
+        self.parked.restore(fixtures);
+    }
+
+    /// Forget where each channel was, so the next frame is sent as is
+    pub fn reset(&mut self) {
+        self.outputs.clear();
+        self.last_frame = None;
+    }
+
+    /// Move each smoothed channel towards its rendered value
+    pub fn apply(
+        &mut self,
+        fixtures: &mut [Fixture],
+        now: Instant,
+        smoothing: &[ChannelSmoothing],
+    ) {
+        let elapsed = self
+            .last_frame
+            .replace(now)
+            .map_or(0.0, |last| now.duration_since(last).as_secs_f32());
+
+        let mut outputs = Vec::with_capacity(self.outputs.len());
+        for rule in smoothing.iter().filter(|rule| rule.secs > 0.0) {
+            let max_step = 255.0 * elapsed / rule.secs;
+            for fixture in fixtures.iter_mut() {
+                let Some(target) = fixture.channel_value(&rule.channel_type) else {
+                    continue;
+                };
+                let target = target as f32;
+                let output = self
+                    .outputs
+                    .iter()
+                    .find(|(id, channel_type, _)| {
+                        *id == fixture.id && *channel_type == rule.channel_type
+                    })
+                    .map_or(target, |(_, _, last)| {
+                        last + (target - last).clamp(-max_step, max_step)
+                    });
+
+                let value = output.round() as u8;
+                if value != target as u8 {
+                    self.parked.park(fixture, &rule.channel_type, value);
+                }
+                outputs.push((fixture.id, rule.channel_type.clone(), output));
+            }
+        }
+        self.outputs = outputs;
+    }
+}
@@ -0,0 +1,69 @@
+use halo_fixtures::{ChannelType, Fixture};
+
+use crate::grand_master::is_intensity;
+use crate::parked::ParkedChannels;
+
+/// Keeps a set of fixtures lit and everything else dark without touching playback.
+///
+/// Intensity of every fixture outside the solo is parked at zero, so cues keep running
+/// underneath and ending the solo brings the full look straight back. Fixtures without a
+/// dimmer have their color and pixel channels parked instead, the way the grand master
+/// scales them, and pixel bars outside the solo go out black from the pixel engine.
+#[derive(Clone, Default)]
+pub struct SoloLayer {
+    fixture_ids: Option<Vec<usize>>,
+    parked: ParkedChannels,
+}
+
+impl SoloLayer {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Solo a set of fixtures, replacing any solo already active
+    pub fn solo(&mut self, fixture_ids: Vec<usize>) {
+        self.fixture_ids = Some(fixture_ids);
+    }
+
+    pub fn clear(&mut self) {
+        self.fixture_ids = None;
+    }
+
+    /// The soloed fixtures, or `None` when nothing is soloed
+    pub fn soloed(&self) -> Option<&[usize]> {
+        self.fixture_ids.as_deref()
+    }
+
+    /// Put back the intensities the last frame's solo replaced. Call before rendering playback.
+    pub fn restore(&mut self, fixtures: &mut [Fixture]) {
+        self.parked.restore(fixtures);
+    }
+
+    /// Whether a fixture's light gets through the solo
+    pub fn lit(&self, fixture_id: usize) -> bool {
+        self.fixture_ids
+            .as_ref()
+            .is_none_or(|soloed| soloed.contains(&fixture_id))
+    }
+
+    /// Darken every fixture outside the solo
+    pub fn apply(&mut self, fixtures: &mut [Fixture]) {
+        if self.fixture_ids.is_none() {
+            return;
+        }
+        for fixture in fixtures.iter_mut() {
+            if self.lit(fixture.id) {
+                continue;
+            }
+            let intensity: Vec<ChannelType> = fixture
+                .channels
+                .iter()
+                .filter(|channel| is_intensity(fixture, &channel.channel_type))
+                .map(|channel| channel.channel_type.clone())
+                .collect();
+            for channel_type in intensity {
+                self.parked.park(fixture, &channel_type, 0);
+            }
+        }
+    }
+}
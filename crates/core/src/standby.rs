@@ -0,0 +1,173 @@
+use std::io;
+use std::net::SocketAddr;
+use std::path::Path;
+use std::time::{Duration, Instant};
+
+use serde::{Deserialize, Serialize};
+use tokio::io::{AsyncBufRead, AsyncBufReadExt, AsyncWrite, AsyncWriteExt, BufReader};
+use tokio::net::{TcpListener, TcpStream};
+use tokio::sync::mpsc;
+
+use crate::messages::ConsoleCommand;
+use crate::StaticValue;
+
+/// How long a standby waits without hearing from the primary before taking over
+pub const HEARTBEAT_TIMEOUT: Duration = Duration::from_millis(500);
+
+/// How often a standby tries the primary again after losing it
+const RECONNECT_INTERVAL: Duration = Duration::from_secs(1);
+
+/// Two halo machines running the same show, one driving the rig and one ready to take over
+#[derive(Clone, Copy, Debug, PartialEq)]
+pub enum Redundancy {
+    /// Drive the rig and stream playback to a standby connecting on this address
+    Primary(SocketAddr),
+    /// Mirror the primary at this address with output muted, until told to take over or the
+    /// primary goes quiet
+    Standby(SocketAddr),
+}
+
+/// What the primary streams its standby every update. Each one doubles as a heartbeat.
+#[derive(Clone, Debug, PartialEq, Serialize, Deserialize)]
+pub struct MirrorState {
+    /// Hash of the show file the primary is running, see [`show_file_hash`]
+    pub show_hash: String,
+    /// The list playback is following
+    pub cue_list: usize,
+    /// The cue running, or none while stopped
+    pub cue: Option<usize>,
+    /// How long the cue has been running, so fades line up
+    pub cue_elapsed: Duration,
+    /// Tracked channel values from every cue run so far
+    pub values: Vec<StaticValue>,
+    pub grand_master: f32,
+    pub bpm: f64,
+    /// Beats since the rhythm engine started, for effects to stay in phase
+    pub beats: f64,
+}
+
+/// Where a standby console is with its primary
+#[derive(Clone, Debug, Default)]
+pub(crate) struct StandbyLink {
+    /// Latest state from the primary, not yet applied
+    pub pending: Option<MirrorState>,
+    /// When the primary was last heard from
+    pub last_heard: Option<Instant>,
+    /// Whether the tracked values have been taken from the primary yet
+    pub synced: bool,
+    /// Why the standby won't mirror the primary, e.g. it's running a different show
+    pub refused: Option<String>,
+}
+
+impl StandbyLink {
+    /// Whether the primary has gone quiet after being heard from. A standby that refused to
+    /// mirror never takes over on its own.
+    pub fn timed_out(&self, now: Instant) -> bool {
+        self.refused.is_none()
+            && self
+                .last_heard
+                .is_some_and(|heard| now.duration_since(heard) > HEARTBEAT_TIMEOUT)
+    }
+}
+
+/// A hash of a show file's contents, the same on every machine for the same file
+pub fn show_file_hash(path: &Path) -> io::Result<String> {
+    let data = std::fs::read(path)?;
+    // FNV-1a, stable across builds unlike the standard library's hasher
+    let hash = data.iter().fold(0xcbf2_9ce4_8422_2325_u64, |hash, byte| {
+        (hash ^ *byte as u64).wrapping_mul(0x0100_0000_01b3)
+    });
+    Ok(format!("{hash:016x}"))
+}
+
+/// Send one state down the link, as a line of JSON
+pub async fn write_mirror<W: AsyncWrite + Unpin>(
+    writer: &mut W,
+    state: &MirrorState,
+) -> io::Result<()> {
+    let mut line = serde_json::to_vec(state)?;
+    line.push(b'\n');
+    writer.write_all(&line).await?;
+    writer.flush().await
+}
+
+/// Read the next state from the link, or `None` once the primary has closed it
+pub async fn read_mirror<R: AsyncBufRead + Unpin>(
+    reader: &mut R,
+) -> io::Result<Option<MirrorState>> {
+    let mut line = String::new();
+    if reader.read_line(&mut line).await? == 0 {
+        return Ok(None);
+    }
+    serde_json::from_str(&line)
+        .map(Some)
+        .map_err(|e| io::Error::new(io::ErrorKind::InvalidData, e))
+}
+
+/// Stream the primary's states to whichever standby last connected on `addr`
+pub async fn serve_standby(
+    addr: SocketAddr,
+    mut states: mpsc::UnboundedReceiver<MirrorState>,
+) -> io::Result<()> {
+    let listener = TcpListener::bind(addr).await?;
+    log::info!("Waiting for a standby on {addr}");
+    let mut standby: Option<TcpStream> = None;
+    loop {
+        tokio::select! {
+            accepted = listener.accept() => match accepted {
+                Ok((stream, peer)) => {
+                    log::info!("Standby connected from {peer}");
+                    let _ = stream.set_nodelay(true);
+                    standby = Some(stream);
+                }
+                Err(e) => log::warn!("Couldn't accept a standby: {e}"),
+            },
+            state = states.recv() => {
+                let Some(state) = state else {
+                    return Ok(());
+                };
+                if let Some(stream) = standby.as_mut() {
+                    if let Err(e) = write_mirror(stream, &state).await {
+                        log::warn!("Lost the standby: {e}");
+                        standby = None;
+                    }
+                }
+            }
+        }
+    }
+}
+
+/// Follow the primary on `addr`, handing each state it sends to the console as a
+/// [`ConsoleCommand::MirrorPrimary`]. Reconnects until the console goes away.
+pub async fn follow_primary(addr: SocketAddr, commands: mpsc::UnboundedSender<ConsoleCommand>) {
+    while !commands.is_closed() {
+        match TcpStream::connect(addr).await {
+            Ok(stream) => {
+                log::info!("Following the primary on {addr}");
+                let mut reader = BufReader::new(stream);
+                loop {
+                    match read_mirror(&mut reader).await {
+                        Ok(Some(state)) => {
+                            if commands
+                                .send(ConsoleCommand::MirrorPrimary { state })
+                                .is_err()
+                            {
+                                return;
+                            }
+                        }
+                        Ok(None) => {
+                            log::warn!("The primary on {addr} closed the link");
+                            break;
+                        }
+                        Err(e) => {
+                            log::warn!("Lost the primary on {addr}: {e}");
+                            break;
+                        }
+                    }
+                }
+            }
+            Err(e) => log::debug!("Couldn't reach the primary on {addr}: {e}"),
+        }
+        tokio::time::sleep(RECONNECT_INTERVAL).await;
+    }
+}
@@ -0,0 +1,129 @@
+use std::collections::HashSet;
+
+use halo_fixtures::{ChannelType, Fixture};
+
+use crate::effect::effect::EffectParams;
+use crate::parked::ParkedChannels;
+use crate::{EffectMapping, EffectType, Interval, RhythmState};
+
+/// Strobe-safe output for venues that limit flash rates.
+///
+/// Strobe channels asking for more than the limit are parked at the fastest allowed value,
+/// or at their open value in no-strobe mode. Square wave effects have their rate clamped the
+/// same way. Each clamp is logged once, when it starts.
+#[derive(Clone, Default)]
+pub struct StrobeLimiter {
+    parked: ParkedChannels,
+    clamped_fixtures: HashSet<usize>,
+    clamped_effects: HashSet<String>,
+}
+
+impl StrobeLimiter {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Put back the strobe values the last frame clamped. Call before rendering playback.
+    pub fn restore(&mut self, fixtures: &mut [Fixture]) {
+        self.parked.restore(fixtures);
+    }
+
+    /// Clamp every fixture's strobe channel to `max_hz`, or hold it open when `no_strobe`
+    pub fn apply(&mut self, fixtures: &mut [Fixture], max_hz: Option<f32>, no_strobe: bool) {
+        for fixture in fixtures.iter_mut() {
+            let Some(value) = fixture.channel_value(&ChannelType::Strobe) else {
+                continue;
+            };
+            let range = fixture.profile.strobe_range();
+            let Some(hz) = range.hz(value) else {
+                self.clamped_fixtures.remove(&fixture.id);
+                continue;
+            };
+
+            let limited = if no_strobe {
+                range.open
+            } else {
+                match max_hz {
+                    Some(max_hz) if hz > max_hz => range.value_for_hz(max_hz),
+                    _ => {
+                        self.clamped_fixtures.remove(&fixture.id);
+                        continue;
+                    }
+                }
+            };
+
+            if self.clamped_fixtures.insert(fixture.id) {
+                log::info!(
+                    "Strobe on {} limited from {hz:.1}Hz (value {value} to {limited})",
+                    fixture.name
+                );
+            }
+            self.parked.park(fixture, &ChannelType::Strobe, limited);
+        }
+    }
+
+    /// The effect as it should run under the strobe limits, or `None` if it shouldn't run.
+    ///
+    /// Any effect on an intensity channel flashes the light at its rate whatever its shape,
+    /// so every one is slowed to `max_hz`, as are square waves on any channel. No-strobe
+    /// mode takes intensity channels off effects with hard edges, leaving only sines and
+    /// triangles to fade them.
+    pub fn limit_effect(
+        &mut self,
+        mapping: &EffectMapping,
+        bpm: f64,
+        rhythm: &RhythmState,
+        max_hz: Option<f32>,
+        no_strobe: bool,
+    ) -> Option<EffectMapping> {
+        let effect = &mapping.effect;
+        let square = effect.source.is_none() && effect.effect_type == EffectType::Square;
+        // A registered source's shape isn't known, so it's taken to be hard edged
+        let smooth = effect.source.is_none()
+            && matches!(effect.effect_type, EffectType::Sine | EffectType::Triangle);
+        let mut limited = mapping.clone();
+
+        if no_strobe && !smooth {
+            limited.channel_types.retain(|c| !flashes(c));
+            if limited.channel_types.is_empty() {
+                return None;
+            }
+        }
+
+        if !square && !limited.channel_types.iter().any(flashes) {
+            self.clamped_effects.remove(&mapping.name);
+            return Some(limited);
+        }
+
+        if let Some(max_hz) = max_hz {
+            let hz = effect_hz(&limited.effect.params, bpm, rhythm);
+            if hz > max_hz as f64 {
+                limited.effect.params.interval_ratio *= max_hz as f64 / hz;
+                if self.clamped_effects.insert(mapping.name.clone()) {
+                    log::info!(
+                        "Effect {} limited from {hz:.1}Hz to {max_hz:.1}Hz",
+                        mapping.name
+                    );
+                }
+                return Some(limited);
+            }
+        }
+        self.clamped_effects.remove(&mapping.name);
+        Some(limited)
+    }
+}
+
+/// Whether changes on the channel show as the light flashing
+fn flashes(channel_type: &ChannelType) -> bool {
+    matches!(channel_type, ChannelType::Dimmer | ChannelType::Strobe)
+}
+
+/// Cycles per second of an effect at the given tempo
+pub fn effect_hz(params: &EffectParams, bpm: f64, rhythm: &RhythmState) -> f64 {
+    let beats = match params.interval {
+        Interval::Beat => 1.0,
+        Interval::Bar => rhythm.beats_per_bar as f64,
+        Interval::Phrase => (rhythm.beats_per_bar * rhythm.bars_per_phrase) as f64,
+    };
+    bpm / 60.0 / beats * params.interval_ratio
+}
@@ -0,0 +1,193 @@
+use chrono::{Datelike, Duration as ChronoDuration, NaiveDateTime, NaiveTime, Weekday};
+use serde::{Deserialize, Serialize};
+
+/// A time of day rule for unattended operation, e.g.
+/// `{"at": "18:00", "days": ["weekdays"], "action": {"type": "start", "cuelist": "Ambient"}}`
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct TimetableRule {
+    /// Local time of day, `HH:MM` or `HH:MM:SS`
+    pub at: String,
+    /// Day names (`mon` to `sun`), `weekdays` or `weekends`. Every day when empty.
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub days: Vec<String>,
+    pub action: TimetableAction,
+}
+
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(tag = "type", rename_all = "lowercase")]
+pub enum TimetableAction {
+    /// Run a cue list from its first cue with the grand master up. With `step_secs`, step
+    /// through the list at that interval, going back to the top after the last cue.
+    Start {
+        #[serde(rename = "cuelist")]
+        cue_list: String,
+        #[serde(default, skip_serializing_if = "Option::is_none")]
+        step_secs: Option<f64>,
+    },
+    /// Stop playback
+    Stop,
+    /// Fade the grand master to a level from 0.0 to 1.0
+    #[serde(rename = "grandmaster")]
+    GrandMaster {
+        level: f32,
+        #[serde(default)]
+        time: f64,
+    },
+    /// Fade the grand master to black
+    Blackout {
+        #[serde(default)]
+        time: f64,
+    },
+}
+
+impl TimetableAction {
+    /// Whether the action sets playback rather than the grand master
+    fn is_playback(&self) -> bool {
+        matches!(self, TimetableAction::Start { .. } | TimetableAction::Stop)
+    }
+
+    /// The same action without its fade, for catching up
+    fn immediate(&self) -> Self {
+        match self {
+            TimetableAction::GrandMaster { level, .. } => TimetableAction::GrandMaster {
+                level: *level,
+                time: 0.0,
+            },
+            TimetableAction::Blackout { .. } => TimetableAction::Blackout { time: 0.0 },
+            action => action.clone(),
+        }
+    }
+}
+
+#[derive(Debug, Clone)]
+struct Entry {
+    at: NaiveTime,
+    days: Vec<Weekday>,
+    action: TimetableAction,
+}
+
+impl Entry {
+    fn parse(rule: &TimetableRule) -> Result<Self, String> {
+        let at = NaiveTime::parse_from_str(&rule.at, "%H:%M")
+            .or_else(|_| NaiveTime::parse_from_str(&rule.at, "%H:%M:%S"))
+            .map_err(|_| format!("Timetable rule has a bad time '{}'", rule.at))?;
+
+        let mut days = Vec::new();
+        for day in &rule.days {
+            match day.to_ascii_lowercase().as_str() {
+                "weekdays" => days.extend([
+                    Weekday::Mon,
+                    Weekday::Tue,
+                    Weekday::Wed,
+                    Weekday::Thu,
+                    Weekday::Fri,
+                ]),
+                "weekends" => days.extend([Weekday::Sat, Weekday::Sun]),
+                name => {
+                    days.push(name.parse().map_err(|_| {
+                        format!("Timetable rule at {} has a bad day '{day}'", rule.at)
+                    })?)
+                }
+            }
+        }
+
+        Ok(Self {
+            at,
+            days,
+            action: rule.action.clone(),
+        })
+    }
+
+    fn runs_on(&self, day: Weekday) -> bool {
+        self.days.is_empty() || self.days.contains(&day)
+    }
+}
+
+/// Daily rules evaluated against the local wall clock, for running a venue with no operator.
+///
+/// The first poll catches up with the state the rules call for at that moment, so a console
+/// restarted mid-evening picks up where the timetable left it. After that, each poll returns
+/// the rules that came due since the last one. Rules due at the same time run in the order
+/// they were declared.
+#[derive(Debug, Clone, Default)]
+pub struct Timetable {
+    entries: Vec<Entry>,
+    last_poll: Option<NaiveDateTime>,
+}
+
+impl Timetable {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Replace the rules, returning a description of each that can't be used. Those are
+    /// skipped. Rules that came due before now aren't run again.
+    pub fn set_rules(&mut self, rules: &[TimetableRule]) -> Vec<String> {
+        let mut errors = Vec::new();
+        self.entries.clear();
+        for rule in rules {
+            match Entry::parse(rule) {
+                Ok(entry) => self.entries.push(entry),
+                Err(e) => errors.push(e),
+            }
+        }
+        errors
+    }
+
+    /// Actions to run at `now`
+    pub fn poll(&mut self, now: NaiveDateTime) -> Vec<TimetableAction> {
+        let Some(last) = self.last_poll.replace(now) else {
+            return self.catch_up(now);
+        };
+        if now <= last {
+            // The clock went back, e.g. for daylight saving, so nothing new is due
+            return Vec::new();
+        }
+        self.due_between(last, now)
+            .into_iter()
+            .map(|(_, action)| action)
+            .collect()
+    }
+
+    /// What the rules from the past week leave in place at `now`: the latest playback and
+    /// grand master actions, in the order they came due and without their fades
+    pub fn catch_up(&self, now: NaiveDateTime) -> Vec<TimetableAction> {
+        let due = self.due_between(now - ChronoDuration::days(7), now);
+        let latest = |playback: bool| {
+            due.iter()
+                .rposition(|(_, action)| action.is_playback() == playback)
+        };
+
+        let mut indexes: Vec<usize> = [latest(true), latest(false)]
+            .into_iter()
+            .flatten()
+            .collect();
+        indexes.sort_unstable();
+        indexes
+            .into_iter()
+            .map(|index| due[index].1.immediate())
+            .collect()
+    }
+
+    /// Rule occurrences after `from` up to and including `to`, in time then declaration order
+    fn due_between(
+        &self,
+        from: NaiveDateTime,
+        to: NaiveDateTime,
+    ) -> Vec<(NaiveDateTime, TimetableAction)> {
+        let mut due = Vec::new();
+        let mut date = from.date();
+        while date <= to.date() {
+            for entry in &self.entries {
+                let at = date.and_time(entry.at);
+                if entry.runs_on(date.weekday()) && at > from && at <= to {
+                    due.push((at, entry.action.clone()));
+                }
+            }
+            date = date.succ_opt().expect("date in range");
+        }
+        // Stable, so rules due together keep their declaration order
+        due.sort_by_key(|(at, _)| *at);
+        due
+    }
+}
@@ -1,6 +1,9 @@
 use std::collections::HashMap;
 
-use crate::{Cue, EffectMapping, PixelEffectMapping, StaticValue};
+use halo_fixtures::{ChannelType, Fixture};
+
+use crate::cue::fade::Attribute;
+use crate::{Cue, EffectMapping, PixelEffectMapping, Release, StaticValue};
 
 /// Manages accumulated tracking state for a tracking console
 /// Values and effects persist across cues until explicitly changed or cleared by blocking cues
@@ -8,8 +11,11 @@ use crate::{Cue, EffectMapping, PixelEffectMapping, StaticValue};
 pub struct TrackingState {
     /// Accumulated fixture channel values
     accumulated_values: Vec<StaticValue>,
-    /// Active effects that continue to run
-    active_effects: HashMap<String, EffectMapping>,
+    /// Name of the cue that last set each accumulated value, by index
+    value_cues: Vec<String>,
+    /// Active effects that continue to run, in the order they were declared, so where two
+    /// write the same channel the later one wins every frame
+    active_effects: Vec<EffectMapping>,
     /// Active pixel effects that continue to run
     active_pixel_effects: HashMap<String, PixelEffectMapping>,
 }
@@ -19,7 +25,8 @@ impl TrackingState {
     pub fn new() -> Self {
         Self {
             accumulated_values: Vec::new(),
-            active_effects: HashMap::new(),
+            value_cues: Vec::new(),
+            active_effects: Vec::new(),
             active_pixel_effects: HashMap::new(),
         }
     }
@@ -29,22 +36,21 @@ impl TrackingState {
         // Merge static values into accumulated state
         for value in &cue.static_values {
             // Find and update existing value or add new one
-            if let Some(existing) = self
-                .accumulated_values
-                .iter_mut()
-                .find(|v| v.fixture_id == value.fixture_id && v.channel_type == value.channel_type)
-            {
-                existing.value = value.value;
+            if let Some(index) = self.accumulated_values.iter().position(|v| {
+                v.fixture_id == value.fixture_id && v.channel_type == value.channel_type
+            }) {
+                self.accumulated_values[index].value = value.value;
+                self.value_cues[index] = cue.name.clone();
             } else {
                 self.accumulated_values.push(value.clone());
+                self.value_cues.push(cue.name.clone());
             }
         }
 
         // Process effects based on release behavior
         for effect_mapping in &cue.effects {
             // Add or update the effect in tracking state
-            self.active_effects
-                .insert(effect_mapping.name.clone(), effect_mapping.clone());
+            self.add_effect(effect_mapping.clone());
         }
 
         // Process pixel effects based on release behavior
@@ -71,9 +77,17 @@ impl TrackingState {
         self.accumulated_values.clone()
     }
 
-    /// Get all active effects
+    /// Name of the cue that last set a tracked value
+    pub fn cue_for(&self, fixture_id: usize, channel_type: &ChannelType) -> Option<&str> {
+        self.accumulated_values
+            .iter()
+            .position(|v| v.fixture_id == fixture_id && v.channel_type == *channel_type)
+            .map(|index| self.value_cues[index].as_str())
+    }
+
+    /// Get all active effects, in the order they render
     pub fn get_effects(&self) -> Vec<EffectMapping> {
-        self.active_effects.values().cloned().collect()
+        self.active_effects.clone()
     }
 
     /// Get all active pixel effects
@@ -84,6 +98,7 @@ impl TrackingState {
     /// Clear all tracking state
     pub fn clear(&mut self) {
         self.accumulated_values.clear();
+        self.value_cues.clear();
         self.active_effects.clear();
         self.active_pixel_effects.clear();
     }
@@ -95,15 +110,45 @@ impl TrackingState {
             && self.active_pixel_effects.is_empty()
     }
 
+    /// Get the number of tracked values and active effects, what each frame renders
+    pub fn render_count(&self) -> usize {
+        self.accumulated_values.len() + self.active_effect_count()
+    }
+
     /// Get the number of active effects
     pub fn active_effect_count(&self) -> usize {
         self.active_effects.len() + self.active_pixel_effects.len()
     }
 
-    /// Add or update an effect in the tracking state
+    /// Take released fixtures out of running effects on the attributes they release. Effects
+    /// left with no fixtures stop.
+    pub fn release_effects(&mut self, release: &Release, fixtures: &[Fixture]) {
+        let released: Vec<usize> = release.fixtures(fixtures).map(|f| f.id).collect();
+        for effect in self.active_effects.iter_mut() {
+            if effect
+                .channel_types
+                .iter()
+                .any(|channel_type| release.releases(Attribute::of(channel_type)))
+            {
+                effect.fixture_ids.retain(|id| !released.contains(id));
+            }
+        }
+        self.active_effects.retain(|e| !e.fixture_ids.is_empty());
+        if release.releases(Attribute::Color) {
+            for effect in self.active_pixel_effects.values_mut() {
+                effect.fixture_ids.retain(|id| !released.contains(id));
+            }
+            self.active_pixel_effects
+                .retain(|_, e| !e.fixture_ids.is_empty());
+        }
+    }
+
+    /// Add or update an effect in the tracking state. It renders after every effect already
+    /// running, an update included, so the effect declared last wins the channels they share.
     pub fn add_effect(&mut self, effect_mapping: EffectMapping) {
         self.active_effects
-            .insert(effect_mapping.name.clone(), effect_mapping);
+            .retain(|e| e.name != effect_mapping.name);
+        self.active_effects.push(effect_mapping);
     }
 }
 
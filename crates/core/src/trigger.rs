@@ -0,0 +1,448 @@
+use serde::{Deserialize, Serialize};
+
+use crate::{BeatGridEdit, ConsoleCommand, CueList, MidiMessage};
+
+/// Maps an external event to a cue list action, e.g.
+/// `{"type": "midi", "note": 60, "action": "go", "cuelist": "Main"}`
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct Trigger {
+    #[serde(flatten)]
+    pub source: TriggerSource,
+    pub action: TriggerAction,
+    /// Cue list the action applies to. Grand master actions don't need one.
+    #[serde(rename = "cuelist", default, skip_serializing_if = "String::is_empty")]
+    pub cue_list: String,
+    /// Cue name, for `goto`
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub cue: Option<String>,
+    /// Fade time in seconds, for `fadetoblack` and `fadeup`
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub time: Option<f64>,
+    /// Scale the cue's intensity by the event, for `goto` and `flash`
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub scale: Option<TriggerScale>,
+    /// Beats to move the downbeat by, for `beatshift`. One when not given.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub beats: Option<i32>,
+    /// Fixture ID, for `manual`
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub fixture: Option<usize>,
+    /// Channel name, e.g. "dimmer", for `manual`
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub channel: Option<String>,
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum TriggerScale {
+    /// Note velocity, 0 to 127, so a soft hit gives a dimmer look than a hard one. OSC
+    /// triggers take the velocity from their first argument.
+    Velocity,
+}
+
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(tag = "type", rename_all = "lowercase")]
+pub enum TriggerSource {
+    Midi {
+        #[serde(default, skip_serializing_if = "Option::is_none")]
+        note: Option<u8>,
+        #[serde(default, skip_serializing_if = "Option::is_none")]
+        cc: Option<u8>,
+    },
+    Osc {
+        address: String,
+    },
+}
+
+impl TriggerSource {
+    /// The manual source a `manual` trigger writes as, e.g. "/fader/1" or "midi cc 7", so it
+    /// can be released by that name
+    fn manual_source(&self) -> String {
+        match self {
+            TriggerSource::Osc { address } => address.clone(),
+            TriggerSource::Midi {
+                note: Some(note), ..
+            } => format!("midi note {note}"),
+            TriggerSource::Midi { cc, .. } => format!("midi cc {}", cc.unwrap_or_default()),
+        }
+    }
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum TriggerAction {
+    /// Advance the cue list to its next cue
+    Go,
+    /// Jump to a named cue
+    Goto,
+    /// Hold a named cue's look as a flash until the note is released, or for OSC, until the
+    /// address is sent 0
+    Flash,
+    /// Set the playback rate from the event's value. Playback can't change rate yet, so
+    /// these are refused when triggers are resolved.
+    Rate,
+    /// Move the crossfader into the list's next cue to the event's value
+    Crossfade,
+    /// Fade the grand master to black
+    FadeToBlack,
+    /// Fade the grand master back up
+    FadeUp,
+    /// Halve the tempo
+    BeatHalf,
+    /// Double the tempo
+    BeatDouble,
+    /// Make now beat 1 of a bar
+    BeatAlign,
+    /// Move the downbeat by the trigger's `beats`
+    BeatShift,
+    /// Make the bar playing now bar 1 of a phrase
+    BarAlign,
+    /// Capture the DMX output to a timestamped file, stopping when the trigger sends 0, e.g.
+    /// an OSC toggle turned off
+    Capture,
+    /// Write the event's value to the trigger's `fixture` and `channel` over playback, as a
+    /// manual source named after the address or controller, e.g. an OSC fader
+    Manual,
+}
+
+impl TriggerAction {
+    /// Whether the action works on a cue list rather than the whole output or the tempo
+    fn needs_cue_list(self) -> bool {
+        !matches!(
+            self,
+            TriggerAction::FadeToBlack
+                | TriggerAction::FadeUp
+                | TriggerAction::Capture
+                | TriggerAction::Manual
+        ) && !self.edits_beat_grid()
+    }
+
+    /// Whether the action does something when its button is let go, rather than once per
+    /// press: flashes and captures stop, and the crossfader and manual values follow their
+    /// fader down to 0
+    fn acts_on_release(self) -> bool {
+        matches!(
+            self,
+            TriggerAction::Flash
+                | TriggerAction::Capture
+                | TriggerAction::Crossfade
+                | TriggerAction::Manual
+        )
+    }
+
+    /// Whether the action moves the beat grid
+    fn edits_beat_grid(self) -> bool {
+        matches!(
+            self,
+            TriggerAction::BeatHalf
+                | TriggerAction::BeatDouble
+                | TriggerAction::BeatAlign
+                | TriggerAction::BeatShift
+                | TriggerAction::BarAlign
+        )
+    }
+}
+
+fn beat_grid(edit: BeatGridEdit) -> ConsoleCommand {
+    ConsoleCommand::EditBeatGrid { edit }
+}
+
+/// Grand master fade time for triggers that don't give one
+const DEFAULT_FADE_SECS: f64 = 3.0;
+
+/// An event from one of the input subsystems
+#[derive(Debug, Clone)]
+pub enum TriggerEvent {
+    Midi(MidiMessage),
+    Osc { address: String, args: Vec<f32> },
+}
+
+/// A trigger with its cue list and cue resolved against the loaded show
+#[derive(Debug, Clone)]
+struct Binding {
+    source: TriggerSource,
+    action: TriggerAction,
+    list_index: Option<usize>,
+    cue_index: Option<usize>,
+    /// Cue name, for flashes
+    cue_name: Option<String>,
+    fade_secs: f64,
+    scale: Option<TriggerScale>,
+    /// Beats to shift by, for `beatshift`
+    beats: i32,
+    /// Fixture and channel name, for `manual`
+    fixture_id: Option<usize>,
+    channel: Option<String>,
+}
+
+/// Routes MIDI and OSC events to console commands using the configured triggers.
+///
+/// Triggers are resolved against the show when it loads, so a trigger that names a missing
+/// cue list or cue is reported once up front. Events that match no trigger are counted and
+/// otherwise ignored.
+#[derive(Debug, Clone, Default)]
+pub struct TriggerDispatcher {
+    triggers: Vec<Trigger>,
+    bindings: Vec<Binding>,
+    unmatched: u64,
+}
+
+impl TriggerDispatcher {
+    pub fn new(triggers: Vec<Trigger>) -> Self {
+        Self {
+            triggers,
+            ..Self::default()
+        }
+    }
+
+    /// Replace the configured triggers. Call `resolve` again before dispatching.
+    pub fn set_triggers(&mut self, triggers: Vec<Trigger>) {
+        self.triggers = triggers;
+        self.bindings.clear();
+    }
+
+    /// Resolve triggers against the show's cue lists. Triggers that can't be resolved are
+    /// skipped and described in the returned errors.
+    pub fn resolve(&mut self, cue_lists: &[CueList]) -> Vec<String> {
+        let mut errors = Vec::new();
+        self.bindings.clear();
+
+        for trigger in &self.triggers {
+            match Self::resolve_trigger(trigger, cue_lists) {
+                Ok(binding) => self.bindings.push(binding),
+                Err(e) => errors.push(e),
+            }
+        }
+        errors
+    }
+
+    fn resolve_trigger(trigger: &Trigger, cue_lists: &[CueList]) -> Result<Binding, String> {
+        if let TriggerSource::Midi { note, cc } = &trigger.source {
+            if note.is_some() == cc.is_some() {
+                return Err(format!(
+                    "MIDI trigger for '{}' needs exactly one of note or cc",
+                    trigger.cue_list
+                ));
+            }
+        }
+
+        if trigger.scale.is_some()
+            && !matches!(trigger.action, TriggerAction::Goto | TriggerAction::Flash)
+        {
+            return Err(format!(
+                "{:?} trigger for '{}' can't be scaled, only goto and flash can",
+                trigger.action, trigger.cue_list
+            ));
+        }
+
+        if trigger.action == TriggerAction::Rate {
+            return Err(format!(
+                "Rate trigger for '{}' can't be used, playback rate can't be changed yet",
+                trigger.cue_list
+            ));
+        }
+
+        if trigger.action == TriggerAction::Manual
+            && (trigger.fixture.is_none() || trigger.channel.is_none())
+        {
+            return Err(format!(
+                "Manual trigger on {} needs a fixture and a channel",
+                trigger.source.manual_source()
+            ));
+        }
+
+        if !trigger.action.needs_cue_list() {
+            return Ok(Binding {
+                source: trigger.source.clone(),
+                action: trigger.action,
+                list_index: None,
+                cue_index: None,
+                cue_name: None,
+                fade_secs: trigger.time.unwrap_or(DEFAULT_FADE_SECS),
+                scale: None,
+                beats: trigger.beats.unwrap_or(1),
+                fixture_id: trigger.fixture,
+                channel: trigger.channel.clone(),
+            });
+        }
+
+        let list_index = cue_lists
+            .iter()
+            .position(|list| list.name.eq_ignore_ascii_case(&trigger.cue_list))
+            .ok_or_else(|| format!("Trigger references missing cue list '{}'", trigger.cue_list))?;
+
+        let cue_index = match (trigger.action, &trigger.cue) {
+            (TriggerAction::Goto | TriggerAction::Flash, Some(cue)) => Some(
+                cue_lists[list_index]
+                    .cues
+                    .iter()
+                    .position(|c| c.name.eq_ignore_ascii_case(cue))
+                    .ok_or_else(|| {
+                        format!(
+                            "Trigger references missing cue '{cue}' in '{}'",
+                            trigger.cue_list
+                        )
+                    })?,
+            ),
+            (TriggerAction::Goto | TriggerAction::Flash, None) => {
+                return Err(format!(
+                    "{:?} trigger for '{}' doesn't name a cue",
+                    trigger.action, trigger.cue_list
+                ))
+            }
+            _ => None,
+        };
+
+        Ok(Binding {
+            source: trigger.source.clone(),
+            action: trigger.action,
+            list_index: Some(list_index),
+            cue_name: cue_index.map(|i| cue_lists[list_index].cues[i].name.clone()),
+            cue_index,
+            fade_secs: 0.0,
+            scale: trigger.scale,
+            beats: 0,
+            fixture_id: None,
+            channel: None,
+        })
+    }
+
+    /// Console commands for an event, empty when no trigger matches. Note offs only release
+    /// flashes and clock messages never trigger anything, so neither is counted as unmatched,
+    /// and nor is a button let go.
+    pub fn dispatch(&mut self, event: &TriggerEvent) -> Vec<ConsoleCommand> {
+        match event {
+            TriggerEvent::Midi(MidiMessage::NoteOff(note)) => return self.release_flashes(*note),
+            TriggerEvent::Midi(MidiMessage::Clock) => return Vec::new(),
+            _ => {}
+        }
+
+        let mut matched = false;
+        let commands: Vec<ConsoleCommand> = self
+            .bindings
+            .iter()
+            .filter_map(|binding| {
+                let value = Self::matches(&binding.source, event)?;
+                matched = true;
+                let intensity = match binding.scale {
+                    Some(TriggerScale::Velocity) => Self::velocity(event),
+                    None => 1.0,
+                };
+                // A button sends 0 as it's let go, which mustn't fire it a second time
+                if value == 0.0 && !binding.action.acts_on_release() {
+                    return None;
+                }
+                Some(match binding.action {
+                    TriggerAction::Go => ConsoleCommand::NextCue {
+                        list_index: binding.list_index?,
+                    },
+                    TriggerAction::Goto if binding.scale.is_some() => {
+                        ConsoleCommand::GoToCueScaled {
+                            list_index: binding.list_index?,
+                            cue_index: binding.cue_index?,
+                            intensity,
+                        }
+                    }
+                    TriggerAction::Goto => ConsoleCommand::GoToCue {
+                        list_index: binding.list_index?,
+                        cue_index: binding.cue_index?,
+                    },
+                    TriggerAction::Flash if value == 0.0 => ConsoleCommand::FlashOff {
+                        cue_name: binding.cue_name.clone()?,
+                    },
+                    TriggerAction::Flash => ConsoleCommand::FlashOnScaled {
+                        cue_name: binding.cue_name.clone()?,
+                        intensity,
+                    },
+                    // Refused when resolved
+                    TriggerAction::Rate => return None,
+                    TriggerAction::Crossfade => ConsoleCommand::SetCrossfade {
+                        position: value as f32,
+                        list_index: binding.list_index,
+                    },
+                    TriggerAction::FadeToBlack => ConsoleCommand::FadeToBlack {
+                        duration_secs: binding.fade_secs,
+                    },
+                    TriggerAction::FadeUp => ConsoleCommand::FadeUp {
+                        duration_secs: binding.fade_secs,
+                    },
+                    TriggerAction::BeatHalf => beat_grid(BeatGridEdit::Half),
+                    TriggerAction::BeatDouble => beat_grid(BeatGridEdit::Double),
+                    TriggerAction::BeatAlign => beat_grid(BeatGridEdit::AlignBeat),
+                    TriggerAction::BeatShift => beat_grid(BeatGridEdit::ShiftBeat(binding.beats)),
+                    TriggerAction::BarAlign => beat_grid(BeatGridEdit::AlignBar),
+                    TriggerAction::Capture if value == 0.0 => ConsoleCommand::StopOutputCapture,
+                    TriggerAction::Capture => ConsoleCommand::StartOutputCapture { path: None },
+                    TriggerAction::Manual => ConsoleCommand::SetManualValue {
+                        source: binding.source.manual_source(),
+                        fixture_id: binding.fixture_id?,
+                        channel: binding.channel.clone()?,
+                        value: (value * 255.0).round() as u8,
+                    },
+                })
+            })
+            .collect();
+
+        if !matched {
+            self.unmatched += 1;
+        }
+        commands
+    }
+
+    /// Releases for the flashes held by a note
+    fn release_flashes(&self, note: u8) -> Vec<ConsoleCommand> {
+        self.bindings
+            .iter()
+            .filter(|binding| {
+                binding.action == TriggerAction::Flash
+                    && binding.source
+                        == TriggerSource::Midi {
+                            note: Some(note),
+                            cc: None,
+                        }
+            })
+            .filter_map(|binding| {
+                Some(ConsoleCommand::FlashOff {
+                    cue_name: binding.cue_name.clone()?,
+                })
+            })
+            .collect()
+    }
+
+    /// A note's velocity from 0.0 to 1.0. OSC sends it as the first argument, 0 to 127.
+    fn velocity(event: &TriggerEvent) -> f32 {
+        let velocity = match event {
+            TriggerEvent::Midi(MidiMessage::NoteOn(_, velocity)) => *velocity as f32,
+            TriggerEvent::Osc { args, .. } => args.first().copied().unwrap_or(127.0),
+            _ => 127.0,
+        };
+        (velocity / 127.0).clamp(0.0, 1.0)
+    }
+
+    /// Number of events that matched no trigger
+    pub fn unmatched_events(&self) -> u64 {
+        self.unmatched
+    }
+
+    /// The event's value from 0.0 to 1.0 if it matches the source
+    fn matches(source: &TriggerSource, event: &TriggerEvent) -> Option<f64> {
+        match (source, event) {
+            (
+                TriggerSource::Midi {
+                    note: Some(note), ..
+                },
+                TriggerEvent::Midi(MidiMessage::NoteOn(n, velocity)),
+            ) if note == n => Some(*velocity as f64 / 127.0),
+            (
+                TriggerSource::Midi { cc: Some(cc), .. },
+                TriggerEvent::Midi(MidiMessage::ControlChange(c, value)),
+            ) if cc == c => Some(*value as f64 / 127.0),
+            (TriggerSource::Osc { address }, TriggerEvent::Osc { address: a, args })
+                if address == a =>
+            {
+                Some(args.first().copied().unwrap_or(1.0).clamp(0.0, 1.0) as f64)
+            }
+            _ => None,
+        }
+    }
+}
@@ -0,0 +1,108 @@
+mod harness;
+
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+use std::time::Duration;
+
+use halo_core::{alias_collisions, analyze_aliases, ConsoleCommand, PositionPresets, Settings};
+use halo_fixtures::PanTilt;
+use harness::Harness;
+use serde_json::{json, Value};
+
+/// `file` from the test data with each `(fixture index, alias)` added
+fn write_show(dir: &Path, file: &str, aliases: &[(usize, &str)]) -> PathBuf {
+    let testdata = Path::new(env!("CARGO_MANIFEST_DIR"))
+        .join("tests/testdata")
+        .join(file);
+    let mut show: Value =
+        serde_json::from_str(&std::fs::read_to_string(testdata).unwrap()).unwrap();
+    for &(fixture, alias) in aliases {
+        let fixture = &mut show["fixtures"][fixture];
+        if fixture.get("aliases").is_none() {
+            fixture["aliases"] = json!([]);
+        }
+        fixture["aliases"]
+            .as_array_mut()
+            .unwrap()
+            .push(json!(alias));
+    }
+    let path = dir.join(file);
+    std::fs::write(&path, serde_json::to_string(&show).unwrap()).unwrap();
+    path
+}
+
+/// A venue whose DJ booth preset still has the spot under its old name
+fn venue() -> PositionPresets {
+    HashMap::from([(
+        "dj_booth".to_string(),
+        HashMap::from([("Old Spot".to_string(), PanTilt { pan: 40, tilt: 200 })]),
+    )])
+}
+
+#[tokio::test]
+async fn presets_find_a_renamed_fixture_by_its_alias() {
+    let dir = tempfile::tempdir().unwrap();
+    let mut harness = Harness::new().await;
+    harness
+        .command(ConsoleCommand::UpdateSettings {
+            settings: Settings {
+                position_presets: venue(),
+                ..Settings::default()
+            },
+        })
+        .await
+        .unwrap();
+    let path = write_show(dir.path(), "spot.json", &[(0, "Old Spot")]);
+    harness
+        .command(ConsoleCommand::LoadShow { path })
+        .await
+        .unwrap();
+
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect dmx 1 1 40").await.unwrap();
+    harness.run_step("expect dmx 1 2 200").await.unwrap();
+
+    // Looking the fixture up by its old name works too
+    let description = harness.console.describe_fixture("old spot").await.unwrap();
+    assert_eq!(description.name, "Spot");
+
+    let fixtures = harness.console.fixtures.read().await;
+    let report = analyze_aliases(&fixtures, &venue());
+    assert_eq!(report.aliases[0].position_presets, ["dj_booth"]);
+    assert!(report.errors.is_empty());
+}
+
+#[tokio::test]
+async fn an_alias_cannot_be_another_fixtures_name() {
+    let dir = tempfile::tempdir().unwrap();
+    let mut harness = Harness::new().await;
+    let path = write_show(dir.path(), "two_pars.json", &[(1, "left par")]);
+
+    let error = harness.console.load_show(&path).await.unwrap_err();
+    assert!(
+        error
+            .to_string()
+            .contains("Alias 'left par' of Right PAR is the name of Left PAR"),
+        "{error}"
+    );
+}
+
+#[tokio::test]
+async fn an_alias_can_only_be_given_once() {
+    let dir = tempfile::tempdir().unwrap();
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    let path = write_show(dir.path(), "two_pars.json", &[(0, "Par"), (1, "PAR")]);
+    assert!(harness.console.load_show(&path).await.is_err());
+
+    let mut fixtures = harness.console.fixtures.read().await.clone();
+    fixtures[0].aliases = vec!["House left".to_string()];
+    fixtures[1].aliases = vec!["House right".to_string()];
+    assert!(alias_collisions(&fixtures).is_empty());
+    fixtures[1].aliases.push("house left".to_string());
+    assert_eq!(
+        alias_collisions(&fixtures),
+        ["Alias 'House left' is given more than once"]
+    );
+}
@@ -0,0 +1,152 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{BeatGridEdit, ConsoleCommand, Settings, Trigger};
+use harness::Harness;
+
+/// A console `beats` in at 120 BPM
+async fn at_beat(beats: f64) -> Harness {
+    let mut harness = Harness::new().await;
+    harness
+        .advance(Duration::from_secs_f64(beats / 2.0))
+        .await
+        .unwrap();
+    harness
+}
+
+async fn position(harness: &Harness) -> String {
+    harness.console.musical_position().await.to_string()
+}
+
+async fn edit(harness: &mut Harness, edit: BeatGridEdit) {
+    harness
+        .command(ConsoleCommand::EditBeatGrid { edit })
+        .await
+        .unwrap();
+}
+
+async fn advance_ms(harness: &mut Harness, ms: u64) {
+    harness.advance(Duration::from_millis(ms)).await.unwrap();
+}
+
+#[tokio::test]
+async fn halving_keeps_the_beat_and_slows_it() {
+    let mut harness = at_beat(10.5).await;
+    assert_eq!(position(&harness).await, "1.3.3");
+
+    edit(&mut harness, BeatGridEdit::Half).await;
+    assert_eq!(position(&harness).await, "1.3.3");
+    harness.run_step("expect bpm 60").await.unwrap();
+
+    // Three quarters of a beat at 60, where 120 would have reached the next bar
+    advance_ms(&mut harness, 750).await;
+    assert_eq!(position(&harness).await, "1.3.4");
+}
+
+#[tokio::test]
+async fn doubling_keeps_the_beat_and_speeds_it() {
+    let mut harness = at_beat(10.5).await;
+
+    edit(&mut harness, BeatGridEdit::Double).await;
+    assert_eq!(position(&harness).await, "1.3.3");
+    harness.run_step("expect bpm 240").await.unwrap();
+
+    // 0.8 of a beat at 240, where 120 would still be on beat 3
+    advance_ms(&mut harness, 200).await;
+    assert_eq!(position(&harness).await, "1.3.4");
+}
+
+#[tokio::test]
+async fn beat_align_makes_now_the_downbeat() {
+    let mut harness = at_beat(10.5).await;
+    assert_eq!(position(&harness).await, "1.3.3");
+
+    edit(&mut harness, BeatGridEdit::AlignBeat).await;
+    assert_eq!(position(&harness).await, "1.4.1");
+
+    // The next beat comes a whole beat from now, not half of one
+    advance_ms(&mut harness, 400).await;
+    assert_eq!(position(&harness).await, "1.4.1");
+    advance_ms(&mut harness, 200).await;
+    assert_eq!(position(&harness).await, "1.4.2");
+}
+
+#[tokio::test]
+async fn beat_shift_renumbers_without_moving_the_beat() {
+    let mut harness = at_beat(10.5).await;
+
+    edit(&mut harness, BeatGridEdit::ShiftBeat(1)).await;
+    assert_eq!(position(&harness).await, "1.3.4");
+
+    // The beat still turns over a quarter of a second after the shift
+    advance_ms(&mut harness, 200).await;
+    assert_eq!(position(&harness).await, "1.3.4");
+    advance_ms(&mut harness, 100).await;
+    assert_eq!(position(&harness).await, "1.4.1");
+}
+
+#[tokio::test]
+async fn shifting_back_past_the_start_wraps_to_the_phrase_end() {
+    let mut harness = at_beat(0.5).await;
+    assert_eq!(position(&harness).await, "1.1.1");
+
+    edit(&mut harness, BeatGridEdit::ShiftBeat(-1)).await;
+    assert_eq!(position(&harness).await, "1.4.4");
+}
+
+#[tokio::test]
+async fn bar_align_makes_this_bar_the_first_of_a_phrase() {
+    let mut harness = at_beat(9.5).await;
+    assert_eq!(position(&harness).await, "1.3.2");
+
+    edit(&mut harness, BeatGridEdit::AlignBar).await;
+    assert_eq!(position(&harness).await, "2.1.2");
+
+    // Just early for the next bar counts as that bar
+    let mut harness = at_beat(11.75).await;
+    assert_eq!(position(&harness).await, "1.3.4");
+    edit(&mut harness, BeatGridEdit::AlignBar).await;
+    assert_eq!(position(&harness).await, "1.4.4");
+    advance_ms(&mut harness, 250).await;
+    assert_eq!(position(&harness).await, "2.1.1");
+}
+
+#[tokio::test]
+async fn osc_buttons_edit_the_grid_once_per_press() {
+    let triggers: Vec<Trigger> = serde_json::from_str(
+        r#"[
+            {"type": "osc", "address": "/beat/shift", "action": "beatshift"},
+            {"type": "osc", "address": "/beat/back", "action": "beatshift", "beats": -2},
+            {"type": "osc", "address": "/beat/align", "action": "beatalign"}
+        ]"#,
+    )
+    .unwrap();
+    let mut harness = Harness::new().await;
+    harness
+        .command(ConsoleCommand::UpdateSettings {
+            settings: Settings {
+                triggers,
+                ..Settings::default()
+            },
+        })
+        .await
+        .unwrap();
+    harness.run_step("load two_pars.json").await.unwrap();
+    advance_ms(&mut harness, 5250).await;
+    assert_eq!(position(&harness).await, "1.3.3");
+
+    let osc = |address: &str, value: f32| ConsoleCommand::ProcessOscMessage {
+        address: address.to_string(),
+        args: vec![value],
+    };
+    harness.command(osc("/beat/shift", 1.0)).await.unwrap();
+    harness.command(osc("/beat/shift", 0.0)).await.unwrap();
+    assert_eq!(position(&harness).await, "1.3.4");
+
+    harness.command(osc("/beat/back", 1.0)).await.unwrap();
+    assert_eq!(position(&harness).await, "1.3.2");
+
+    harness.command(osc("/beat/align", 1.0)).await.unwrap();
+    assert_eq!(position(&harness).await, "1.3.1");
+}
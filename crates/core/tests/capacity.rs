@@ -0,0 +1,89 @@
+use std::path::Path;
+use std::time::Duration;
+
+use halo_core::{CapacityEstimate, Settings, Show, UnitCosts, Workload};
+
+fn two_pars() -> Show {
+    Show::read(&Path::new(env!("CARGO_MANIFEST_DIR")).join("tests/testdata/two_pars.json")).unwrap()
+}
+
+fn costs(micros_per_channel: u64) -> UnitCosts {
+    UnitCosts {
+        channel: Duration::from_micros(micros_per_channel),
+        effect_channel: Duration::from_micros(micros_per_channel * 2),
+    }
+}
+
+#[test]
+fn counts_the_show_workload() {
+    let show = two_pars();
+    let workload = Workload::of(&show.fixtures, &show.cue_lists);
+
+    assert_eq!(workload.fixtures, 2);
+    assert_eq!(workload.universes, 1);
+    assert_eq!(workload.channels, 16);
+    assert_eq!((workload.effects, workload.effect_channels), (0, 0));
+}
+
+#[test]
+fn warns_as_frames_near_the_budget() {
+    // 40 fps leaves 25ms a frame
+    let settings = Settings {
+        target_fps: 40,
+        ..Settings::default()
+    };
+    let workload = Workload {
+        fixtures: 10,
+        universes: 1,
+        channels: 100,
+        effects: 1,
+        effect_channels: 50,
+    };
+
+    // 100 × 100µs + 50 × 200µs = 20ms, within budget but tight
+    let estimate = CapacityEstimate::new(workload.clone(), costs(100), &settings);
+    assert_eq!(estimate.frame_time, Duration::from_millis(20));
+    assert_eq!(estimate.warnings.len(), 1, "{estimate}");
+    assert!(estimate.warnings[0].contains("close to the 25.0ms budget"));
+
+    let estimate = CapacityEstimate::new(workload.clone(), costs(150), &settings);
+    assert_eq!(estimate.warnings.len(), 1, "{estimate}");
+    assert!(estimate.warnings[0].contains("over the 25.0ms budget"));
+
+    let estimate = CapacityEstimate::new(workload, costs(50), &settings);
+    assert!(estimate.warnings.is_empty(), "{estimate}");
+    assert!((estimate.load() - 0.4).abs() < 1e-9);
+}
+
+#[test]
+fn warns_past_the_configured_limits() {
+    let settings = Settings {
+        max_universes: Some(8),
+        max_fixtures: Some(200),
+        ..Settings::default()
+    };
+    let mut workload = Workload {
+        fixtures: 200,
+        universes: 8,
+        ..Workload::default()
+    };
+    let estimate = CapacityEstimate::new(workload.clone(), UnitCosts::default(), &settings);
+    assert!(estimate.warnings.is_empty(), "{estimate}");
+
+    workload.fixtures = 201;
+    workload.universes = 9;
+    let estimate = CapacityEstimate::new(workload, UnitCosts::default(), &settings);
+    assert_eq!(
+        estimate.warnings,
+        [
+            "Show uses 9 universes, more than the limit of 8",
+            "Show patches 201 fixtures, more than the limit of 200",
+        ]
+    );
+}
+
+#[test]
+fn measures_this_machine() {
+    let costs = UnitCosts::measure();
+    assert!(costs.channel > Duration::ZERO);
+}
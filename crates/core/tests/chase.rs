@@ -0,0 +1,139 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{
+    Chase, ChaseDirection, ChaseRate, ChaseStep, ConsoleCommand, Cue, CueList, StaticValue,
+};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+
+/// A step putting the left PAR's dimmer at `value`
+fn step(value: u8) -> ChaseStep {
+    ChaseStep {
+        static_values: vec![StaticValue {
+            fixture_id: 0,
+            channel_type: ChannelType::Dimmer,
+            value,
+        }],
+        channels: vec![],
+    }
+}
+
+fn four_steps(direction: ChaseDirection) -> Chase {
+    Chase {
+        steps: vec![step(0), step(200), step(100), step(50)],
+        rate: ChaseRate::Beats(1.0),
+        direction,
+        crossfade: 0.5,
+    }
+}
+
+/// The two PARs running `chase` from the start of the fake clock, at 120 BPM
+async fn running(chase: Chase) -> Harness {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    let cue_list = CueList {
+        name: "Chases".to_string(),
+        cues: vec![Cue {
+            name: "Chase".to_string(),
+            chase: Some(chase),
+            ..Cue::default()
+        }],
+        audio_file: None,
+        default_fade: None,
+        default_values: vec![],
+        move_in_black: None,
+    };
+    harness
+        .command(ConsoleCommand::SetCueLists {
+            cue_lists: vec![cue_list],
+        })
+        .await
+        .unwrap();
+    harness.run_step("goto 0 0").await.unwrap();
+    harness
+}
+
+#[tokio::test]
+async fn a_four_step_chase_steps_each_beat_and_crossfades_between_steps() {
+    let mut harness = running(four_steps(ChaseDirection::Forward)).await;
+
+    // A beat is half a second, and the last half of each step fades into the next
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect dmx 1 1 0").await.unwrap();
+    assert_eq!(harness.console.chase_step().await, Some((0, 4)));
+
+    harness.advance(Duration::from_millis(350)).await.unwrap();
+    harness.run_step("expect dmx 1 1 160").await.unwrap();
+
+    harness.advance(Duration::from_millis(150)).await.unwrap();
+    harness.run_step("expect dmx 1 1 200").await.unwrap();
+    assert_eq!(harness.console.chase_step().await, Some((1, 4)));
+
+    harness.advance(Duration::from_millis(350)).await.unwrap();
+    harness.run_step("expect dmx 1 1 120").await.unwrap();
+
+    harness.advance(Duration::from_millis(150)).await.unwrap();
+    harness.run_step("expect dmx 1 1 100").await.unwrap();
+    assert_eq!(harness.console.chase_step().await, Some((2, 4)));
+
+    harness.advance(Duration::from_millis(500)).await.unwrap();
+    harness.run_step("expect dmx 1 1 50").await.unwrap();
+    assert_eq!(harness.console.chase_step().await, Some((3, 4)));
+
+    // Then round to the first step again, fading down from the last
+    harness.advance(Duration::from_millis(350)).await.unwrap();
+    harness.run_step("expect dmx 1 1 10").await.unwrap();
+    harness.advance(Duration::from_millis(150)).await.unwrap();
+    harness.run_step("expect dmx 1 1 0").await.unwrap();
+    assert_eq!(harness.console.chase_step().await, Some((0, 4)));
+}
+
+#[tokio::test]
+async fn tempo_changes_apply_to_a_running_chase() {
+    let mut harness = running(four_steps(ChaseDirection::Forward)).await;
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness
+        .command(ConsoleCommand::SetBpm { bpm: 60.0 })
+        .await
+        .unwrap();
+
+    // Steps take a second from here, so the second step runs from 0.9s to 1.9s
+    harness.advance(Duration::from_millis(900)).await.unwrap();
+    assert_eq!(harness.console.chase_step().await, Some((1, 4)));
+    harness.advance(Duration::from_millis(800)).await.unwrap();
+    assert_eq!(harness.console.chase_step().await, Some((1, 4)));
+    harness.advance(Duration::from_millis(200)).await.unwrap();
+    assert_eq!(harness.console.chase_step().await, Some((2, 4)));
+}
+
+#[tokio::test]
+async fn chases_stop_with_their_cue() {
+    let mut harness = running(four_steps(ChaseDirection::Forward)).await;
+    harness.advance(Duration::from_millis(600)).await.unwrap();
+    harness.run_step("expect dmx 1 1 200").await.unwrap();
+
+    // The dimmer goes back to what the cues left it at
+    harness.run_step("stop").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    assert_eq!(harness.console.chase_step().await, None);
+    harness.run_step("expect dmx 1 1 0").await.unwrap();
+}
+
+#[test]
+fn directions_set_the_step_order() {
+    assert_eq!(four_steps(ChaseDirection::Forward).order(), [0, 1, 2, 3]);
+    assert_eq!(four_steps(ChaseDirection::Backward).order(), [3, 2, 1, 0]);
+    assert_eq!(
+        four_steps(ChaseDirection::Bounce).order(),
+        [0, 1, 2, 3, 2, 1]
+    );
+
+    let chase = four_steps(ChaseDirection::Bounce);
+    assert_eq!(chase.position(3.2), Some((3, 2, 0.0)));
+    assert_eq!(chase.position(5.2), Some((1, 0, 0.0)));
+    let (step, next, blend) = chase.position(4.75).unwrap();
+    assert_eq!((step, next), (2, 1));
+    assert!((blend - 0.5).abs() < 1e-9);
+}
@@ -0,0 +1,247 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{ConsoleCommand, StaticValue};
+use halo_fixtures::{ChannelType, ColorCalibration, Fixture, FixtureLibrary, WheelColor};
+use harness::Harness;
+
+const SAMPLES: [(u8, u8, u8); 5] = [
+    (0, 0, 0),
+    (255, 136, 0),
+    (12, 200, 99),
+    (255, 255, 255),
+    (1, 2, 3),
+];
+
+fn fixture(profile_id: &str) -> Fixture {
+    let profile = FixtureLibrary::new().profiles[profile_id].clone();
+    let channels = profile.channel_layout.clone();
+    Fixture::new(0, "Test", profile, channels, 1, 1)
+}
+
+#[test]
+fn identity_calibrations_change_nothing() {
+    let identity = ColorCalibration::Matrix([[1.0, 0.0, 0.0], [0.0, 1.0, 0.0], [0.0, 0.0, 1.0]]);
+    for rgb in SAMPLES {
+        assert_eq!(identity.apply(rgb), rgb);
+        assert_eq!(ColorCalibration::IDENTITY.apply(rgb), rgb);
+    }
+
+    let mut par = fixture("shehds-rgbw-par");
+    let uncalibrated: Vec<_> = SAMPLES.iter().map(|rgb| par.color_values(*rgb)).collect();
+    par.profile.color_calibration = Some(identity);
+    let calibrated: Vec<_> = SAMPLES.iter().map(|rgb| par.color_values(*rgb)).collect();
+    assert_eq!(uncalibrated, calibrated);
+}
+
+#[test]
+fn matrix_and_gain_calibrations_are_applied() {
+    // Swap red and blue, and mix half of red into green
+    let matrix = ColorCalibration::Matrix([[0.0, 0.0, 1.0], [0.5, 1.0, 0.0], [1.0, 0.0, 0.0]]);
+    assert_eq!(matrix.apply((200, 20, 10)), (10, 120, 200));
+    // Sums past full clamp rather than wrap
+    assert_eq!(matrix.apply((255, 255, 0)), (0, 255, 255));
+
+    let gain = ColorCalibration::Gain([1.0, 0.6, 0.8]);
+    assert_eq!(gain.apply((255, 136, 100)), (255, 82, 80));
+
+    let calibration: ColorCalibration =
+        serde_json::from_str(r#"{"gain": [1.0, 0.6, 0.8]}"#).unwrap();
+    assert_eq!(calibration, gain);
+}
+
+#[test]
+fn one_color_renders_on_each_fixture_type() {
+    // RGBW takes the shared part of the color from the white emitter
+    let par = fixture("shehds-rgbw-par");
+    assert_eq!(
+        par.color_values((255, 200, 100)),
+        [
+            (ChannelType::Red, 155),
+            (ChannelType::Green, 100),
+            (ChannelType::Blue, 0),
+            (ChannelType::White, 100),
+        ]
+    );
+
+    // RGBWA also takes what it can from amber
+    let wash = fixture("shehds-led-wash-7x18w-rgbwa-uv");
+    assert_eq!(
+        wash.color_values((255, 136, 0)),
+        [
+            (ChannelType::Red, 0),
+            (ChannelType::Green, 9),
+            (ChannelType::Blue, 0),
+            (ChannelType::White, 0),
+            (ChannelType::Amber, 255),
+        ]
+    );
+
+    // The spot snaps to the closest wheel slot, after calibration
+    let mut spot = fixture("shehds-led-spot-60w");
+    assert_eq!(spot.color_values((255, 136, 0)), [(ChannelType::Color, 50)]);
+    spot.profile.color_wheel = vec![
+        WheelColor {
+            name: "White".to_string(),
+            value: 0,
+            rgb: (255, 255, 255),
+        },
+        WheelColor {
+            name: "Red".to_string(),
+            value: 20,
+            rgb: (255, 0, 0),
+        },
+        WheelColor {
+            name: "Amber".to_string(),
+            value: 40,
+            rgb: (255, 160, 0),
+        },
+    ];
+    assert_eq!(spot.color_values((255, 136, 0)), [(ChannelType::Color, 40)]);
+    spot.profile.color_calibration = Some(ColorCalibration::Gain([1.0, 0.0, 1.0]));
+    assert_eq!(spot.color_values((255, 136, 0)), [(ChannelType::Color, 20)]);
+}
+
+#[tokio::test]
+async fn programmer_color_is_calibrated_per_fixture() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    harness.console.fixtures.write().await[1]
+        .profile
+        .color_calibration = Some(ColorCalibration::Gain([0.5, 1.0, 1.0]));
+
+    harness
+        .command(ConsoleCommand::SetProgrammerPreviewMode { preview_mode: true })
+        .await
+        .unwrap();
+    harness
+        .command(ConsoleCommand::SetProgrammerColor {
+            fixture_ids: vec![0, 1],
+            red: 255,
+            green: 100,
+            blue: 0,
+        })
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+
+    harness.run_step("expect channel 0 red 255").await.unwrap();
+    harness
+        .run_step("expect channel 0 green 100")
+        .await
+        .unwrap();
+    harness.run_step("expect channel 1 red 128").await.unwrap();
+    harness
+        .run_step("expect channel 1 green 100")
+        .await
+        .unwrap();
+}
+
+#[tokio::test]
+async fn cue_colors_are_calibrated_per_fixture() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    // Half the red, with the other half mixed into green
+    harness.console.fixtures.write().await[0]
+        .profile
+        .color_calibration = Some(ColorCalibration::Matrix([
+        [0.5, 0.0, 0.0],
+        [0.5, 1.0, 0.0],
+        [0.0, 0.0, 1.0],
+    ]));
+
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+
+    harness.run_step("expect channel 0 red 128").await.unwrap();
+    harness
+        .run_step("expect channel 0 green 128")
+        .await
+        .unwrap();
+    harness.run_step("expect channel 0 blue 0").await.unwrap();
+    harness
+        .run_step("expect channel 0 dimmer 255")
+        .await
+        .unwrap();
+}
+
+#[tokio::test]
+async fn cue_colors_turn_the_spot_wheel() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load spot.json").await.unwrap();
+
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    for (channel_type, value) in [
+        (ChannelType::Red, 0xFF),
+        (ChannelType::Green, 0x88),
+        (ChannelType::Blue, 0x00),
+    ] {
+        cue_lists[0].cues[1].static_values.push(StaticValue {
+            fixture_id: 0,
+            channel_type,
+            value,
+        });
+    }
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+
+    harness.run_step("expect channel 0 color 50").await.unwrap();
+    let fixtures = harness.console.fixtures.read().await;
+    assert_eq!(fixtures[0].wheel_color().unwrap().name, "Amber");
+}
+
+#[test]
+fn the_spot_picks_the_nearest_wheel_slot() {
+    let mut spot = fixture("shehds-led-spot-60w");
+    let cases = [
+        ((255, 136, 0), "Amber"),
+        ((230, 20, 10), "Red"),
+        ((250, 240, 30), "Yellow"),
+        ((20, 40, 200), "Blue"),
+        ((240, 240, 240), "White"),
+    ];
+    for (rgb, name) in cases {
+        for (channel_type, value) in spot.color_values(rgb) {
+            spot.set_channel_value(&channel_type, value);
+        }
+        assert_eq!(
+            spot.wheel_color().map(|slot| slot.name.as_str()),
+            Some(name)
+        );
+    }
+
+    // Anywhere between one slot's value and the next is still that slot
+    spot.set_channel_value(&ChannelType::Color, 55);
+    assert_eq!(spot.wheel_color().unwrap().name, "Amber");
+    spot.set_channel_value(&ChannelType::Dimmer, 255);
+    assert_eq!(spot.display_color(), (255, 160, 0));
+}
+
+#[tokio::test]
+async fn programmer_color_turns_the_spot_wheel() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load spot.json").await.unwrap();
+    harness
+        .command(ConsoleCommand::SetProgrammerPreviewMode { preview_mode: true })
+        .await
+        .unwrap();
+    harness
+        .command(ConsoleCommand::SetProgrammerColor {
+            fixture_ids: vec![0],
+            red: 0xFF,
+            green: 0x88,
+            blue: 0x00,
+        })
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+
+    harness.run_step("expect channel 0 color 50").await.unwrap();
+    let fixtures = harness.console.fixtures.read().await;
+    assert_eq!(fixtures[0].wheel_color().unwrap().name, "Amber");
+}
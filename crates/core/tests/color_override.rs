@@ -0,0 +1,146 @@
+mod harness;
+
+use std::collections::HashMap;
+use std::time::Duration;
+
+use halo_core::{
+    ColorOverride, ConsoleCommand, Effect, EffectDistribution, EffectMapping, EffectRelease,
+    OverrideColor,
+};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+
+const AMBER: (u8, u8, u8) = (255, 136, 0);
+
+/// A color chase across both PARs, running on intensity and color together
+fn rainbow() -> EffectMapping {
+    EffectMapping {
+        name: "Rainbow".to_string(),
+        effect: Effect::default(),
+        fixture_ids: vec![0, 1],
+        channel_types: vec![
+            ChannelType::Dimmer,
+            ChannelType::Red,
+            ChannelType::Green,
+            ChannelType::Blue,
+        ],
+        distribution: EffectDistribution::Wave(0.33),
+        release: EffectRelease::Hold,
+    }
+}
+
+/// two_pars.json with the rainbow starting in Left Red
+async fn load_with_rainbow() -> Harness {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues[1].effects.push(rainbow());
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+    harness
+}
+
+/// Dimmer and RGB of the left PAR over the next 20 frames
+async fn sample(harness: &mut Harness) -> Vec<(u8, (u8, u8, u8))> {
+    let mut samples = Vec::new();
+    for _ in 0..20 {
+        harness.advance(Duration::from_millis(25)).await.unwrap();
+        let fixtures = harness.console.fixtures.read().await;
+        let value = |channel_type: ChannelType| fixtures[0].channel_value(&channel_type).unwrap();
+        samples.push((
+            value(ChannelType::Dimmer),
+            (
+                value(ChannelType::Red),
+                value(ChannelType::Green),
+                value(ChannelType::Blue),
+            ),
+        ));
+    }
+    samples
+}
+
+fn distinct<T: PartialEq + Clone>(values: impl Iterator<Item = T>) -> usize {
+    let mut seen: Vec<T> = Vec::new();
+    for value in values {
+        if !seen.contains(&value) {
+            seen.push(value);
+        }
+    }
+    seen.len()
+}
+
+#[tokio::test]
+async fn an_override_holds_the_color_while_intensity_keeps_moving() {
+    let mut harness = load_with_rainbow().await;
+    harness.run_step("goto 0 1").await.unwrap();
+    let running = sample(&mut harness).await;
+    assert!(distinct(running.iter().map(|(_, rgb)| *rgb)) > 1);
+
+    let (red, green, blue) = AMBER;
+    harness
+        .command(ConsoleCommand::SetEffectColorOverride {
+            effect: "Rainbow".to_string(),
+            color: OverrideColor::Rgb(red, green, blue),
+        })
+        .await
+        .unwrap();
+    let overridden = sample(&mut harness).await;
+    assert!(overridden.iter().all(|(_, rgb)| *rgb == AMBER));
+    assert!(distinct(overridden.iter().map(|(dimmer, _)| *dimmer)) > 1);
+
+    harness
+        .command(ConsoleCommand::ClearEffectColorOverride {
+            effect: "Rainbow".to_string(),
+        })
+        .await
+        .unwrap();
+    let released = sample(&mut harness).await;
+    assert!(distinct(released.iter().map(|(_, rgb)| *rgb)) > 1);
+}
+
+#[tokio::test]
+async fn cues_set_and_clear_overrides_by_palette_name() {
+    let mut harness = load_with_rainbow().await;
+    harness
+        .console
+        .set_palettes(HashMap::from([("Amber".to_string(), AMBER)]))
+        .await;
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues[1].color_overrides =
+        serde_json::from_str(r#"[{ "effect": "Rainbow", "color": "Amber" }]"#).unwrap();
+    cue_lists[0].cues[2].color_overrides = vec![ColorOverride {
+        effect: "Rainbow".to_string(),
+        color: None,
+    }];
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+
+    harness.run_step("goto 0 1").await.unwrap();
+    let verse = sample(&mut harness).await;
+    assert!(verse.iter().all(|(_, rgb)| *rgb == AMBER));
+
+    harness.run_step("go").await.unwrap();
+    let chorus = sample(&mut harness).await;
+    assert!(distinct(chorus.iter().map(|(_, rgb)| *rgb)) > 1);
+}
+
+#[tokio::test]
+async fn unknown_palettes_are_reported() {
+    let mut harness = load_with_rainbow().await;
+    harness.run_step("goto 0 1").await.unwrap();
+    let error = harness
+        .command(ConsoleCommand::SetEffectColorOverride {
+            effect: "Rainbow".to_string(),
+            color: OverrideColor::Palette("Teal".to_string()),
+        })
+        .await
+        .unwrap_err();
+    assert!(error.contains("No palette named 'Teal'"));
+
+    let running = sample(&mut harness).await;
+    assert!(distinct(running.iter().map(|(_, rgb)| *rgb)) > 1);
+}
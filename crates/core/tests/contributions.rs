@@ -0,0 +1,99 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{
+    ConsoleCommand, ContributionSource, Effect, EffectDistribution, EffectMapping, EffectRelease,
+    Rule,
+};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+
+/// An effect holding the dimmer at a steady 100, so it's easy to pick out
+fn steady_dimmer() -> EffectMapping {
+    EffectMapping {
+        name: "Steady".to_string(),
+        effect: Effect {
+            min: 100,
+            max: 100,
+            ..Effect::default()
+        },
+        fixture_ids: vec![0],
+        channel_types: vec![ChannelType::Dimmer],
+        distribution: EffectDistribution::All,
+        release: EffectRelease::Hold,
+    }
+}
+
+#[tokio::test]
+async fn a_cue_an_effect_and_a_manual_override_stack_in_render_order() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues[1].effects.push(steady_dimmer());
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    harness
+        .command(ConsoleCommand::SetManualValue {
+            source: "/fader/1".to_string(),
+            fixture_id: 0,
+            channel: "Dimmer".to_string(),
+            value: 40,
+        })
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect dmx 1 1 40").await.unwrap();
+
+    let contributions = harness.console.contributions("Left PAR").await.unwrap();
+    let dimmer: Vec<_> = contributions
+        .iter()
+        .filter(|c| c.channel_type == ChannelType::Dimmer)
+        .map(|c| (c.source.clone(), c.value, c.won, c.rule))
+        .collect();
+    assert_eq!(
+        dimmer,
+        [
+            (
+                ContributionSource::Cue("Left Red".to_string()),
+                255,
+                false,
+                Rule::Ltp
+            ),
+            (
+                ContributionSource::Effect("Steady".to_string()),
+                100,
+                false,
+                Rule::Ltp
+            ),
+            (ContributionSource::Manual, 40, true, Rule::Ltp),
+        ]
+    );
+
+    // The cue's red has nothing over it
+    let red: Vec<_> = contributions
+        .iter()
+        .filter(|c| c.channel_type == ChannelType::Red)
+        .map(|c| (c.source.clone(), c.value, c.won))
+        .collect();
+    assert_eq!(
+        red,
+        [(ContributionSource::Cue("Left Red".to_string()), 255, true)]
+    );
+
+    // A full on beats them all
+    harness.run_step("fullon").await.unwrap();
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+    let contributions = harness.console.contributions("Left PAR").await.unwrap();
+    let winner = contributions
+        .iter()
+        .find(|c| c.channel_type == ChannelType::Dimmer && c.won)
+        .unwrap();
+    assert_eq!(winner.source, ContributionSource::FullOn);
+    assert_eq!(winner.rule, Rule::Priority);
+
+    assert!(harness.console.contributions("Nobody").await.is_err());
+}
@@ -0,0 +1,134 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{ConsoleCommand, Settings, Trigger};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+
+async fn crossfade(harness: &mut Harness, position: f32) {
+    harness
+        .command(ConsoleCommand::SetCrossfade {
+            position,
+            list_index: None,
+        })
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+}
+
+fn dimmer(harness: &Harness, fixture: usize) -> u8 {
+    let fixtures = harness.console.fixtures.try_read().unwrap();
+    fixtures[fixture]
+        .channel_value(&ChannelType::Dimmer)
+        .unwrap()
+}
+
+async fn current_cue(harness: &Harness) -> usize {
+    harness
+        .console
+        .cue_manager
+        .read()
+        .await
+        .get_current_cue_index()
+}
+
+#[tokio::test]
+async fn crossfader_sweeps_into_the_next_cue_and_commits_it() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    harness.run_step("goto 0 0").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+
+    // Sweep into Left Red, which brings fixture 0 to full
+    let mut levels = Vec::new();
+    for step in 1..10 {
+        crossfade(&mut harness, step as f32 / 10.0).await;
+        levels.push(dimmer(&harness, 0));
+        assert_eq!(current_cue(&harness).await, 0);
+    }
+    assert!(levels.windows(2).all(|w| w[0] < w[1]), "{levels:?}");
+    assert_eq!(levels[4], 128);
+
+    crossfade(&mut harness, 1.0).await;
+    assert_eq!(current_cue(&harness).await, 1);
+    harness
+        .run_step("expect channel 0 dimmer 255")
+        .await
+        .unwrap();
+    harness.run_step("expect channel 0 red 255").await.unwrap();
+
+    // Pulling the fader back down doesn't touch the new cue until it re-arms at zero
+    crossfade(&mut harness, 0.5).await;
+    assert_eq!(dimmer(&harness, 1), 0);
+    crossfade(&mut harness, 0.0).await;
+    crossfade(&mut harness, 0.5).await;
+    assert_eq!(current_cue(&harness).await, 1);
+    assert_eq!(dimmer(&harness, 1), 64);
+    harness
+        .run_step("expect channel 0 dimmer 255")
+        .await
+        .unwrap();
+}
+
+#[tokio::test]
+async fn pulling_the_fader_back_without_committing_restores_the_look() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    harness.run_step("goto 0 0").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+
+    // Nothing tracks on the left PAR under the preset, so only the fader puts values there
+    crossfade(&mut harness, 0.5).await;
+    assert_eq!(dimmer(&harness, 0), 128);
+    crossfade(&mut harness, 0.0).await;
+    assert_eq!(current_cue(&harness).await, 0);
+    harness.run_step("expect channel 0 dimmer 0").await.unwrap();
+    harness.run_step("expect channel 0 red 0").await.unwrap();
+}
+
+#[tokio::test]
+async fn crossfader_follows_a_midi_cc() {
+    let triggers: Vec<Trigger> = serde_json::from_str(
+        r#"[{"type": "midi", "cc": 7, "action": "crossfade", "cuelist": "Main"}]"#,
+    )
+    .unwrap();
+    let mut harness = Harness::new().await;
+    harness
+        .command(ConsoleCommand::UpdateSettings {
+            settings: Settings {
+                triggers,
+                ..Settings::default()
+            },
+        })
+        .await
+        .unwrap();
+    harness.run_step("load two_pars.json").await.unwrap();
+    harness.run_step("goto 0 0").await.unwrap();
+
+    harness.run_step("midi 0xB0 7 127").await.unwrap();
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+    assert_eq!(current_cue(&harness).await, 1);
+    harness
+        .run_step("expect channel 0 dimmer 255")
+        .await
+        .unwrap();
+}
+
+#[tokio::test]
+async fn a_crossfade_for_another_list_leaves_playback_alone() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    harness.run_step("goto 0 0").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+
+    harness
+        .command(ConsoleCommand::SetCrossfade {
+            position: 0.5,
+            list_index: Some(1),
+        })
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+    assert_eq!(dimmer(&harness, 0), 0);
+}
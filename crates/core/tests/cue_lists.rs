@@ -0,0 +1,138 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{ConsoleCommand, Cue, CueList};
+use harness::Harness;
+
+/// two_pars.json with a second song that drives the right PAR
+async fn two_songs() -> Harness {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists.push(CueList {
+        name: "Encore".to_string(),
+        cues: vec![
+            Cue::intensity_only("Verse", &[1], 60, Duration::ZERO),
+            Cue::intensity_only("Chorus", &[1], 200, Duration::ZERO),
+            Cue::intensity_only("Outro", &[1], 20, Duration::ZERO),
+        ],
+        audio_file: None,
+        default_fade: None,
+        default_values: vec![],
+        move_in_black: None,
+    });
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+    harness
+}
+
+async fn select(harness: &mut Harness, name: &str) {
+    harness
+        .command(ConsoleCommand::SelectCueList {
+            name: name.to_string(),
+        })
+        .await
+        .unwrap();
+}
+
+async fn go(harness: &mut Harness) {
+    harness
+        .command(ConsoleCommand::NextCue { list_index: 0 })
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+}
+
+async fn playing(harness: &Harness) -> (usize, usize) {
+    let cue_manager = harness.console.cue_manager.read().await;
+    (
+        cue_manager.get_current_cue_list_idx(),
+        cue_manager.get_current_cue_idx().unwrap(),
+    )
+}
+
+#[tokio::test]
+async fn go_follows_the_selected_list() {
+    let mut harness = two_songs().await;
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+
+    // Selecting the next song leaves the current one running
+    select(&mut harness, "Encore").await;
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+    assert_eq!(playing(&harness).await, (0, 1));
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+    let status = harness.console.cue_manager.read().await.status(1);
+    assert!(status[0].is_current && !status[0].is_selected);
+    assert!(!status[1].is_current && status[1].is_selected);
+
+    go(&mut harness).await;
+    assert_eq!(playing(&harness).await, (1, 0));
+    harness.run_step("expect dmx 1 10 60").await.unwrap();
+    go(&mut harness).await;
+    assert_eq!(playing(&harness).await, (1, 1));
+    harness.run_step("expect dmx 1 10 200").await.unwrap();
+
+    // Back to the first song picks up after the cue it was left on
+    select(&mut harness, "main").await;
+    let status = harness.console.cue_manager.read().await.status(1);
+    assert_eq!(status[0].pending, ["Right Half"]);
+    go(&mut harness).await;
+    assert_eq!(playing(&harness).await, (0, 2));
+    harness.run_step("expect dmx 1 10 128").await.unwrap();
+
+    // And the second song resumes where it was too
+    select(&mut harness, "Encore").await;
+    go(&mut harness).await;
+    assert_eq!(playing(&harness).await, (1, 2));
+    harness.run_step("expect dmx 1 10 20").await.unwrap();
+}
+
+#[tokio::test]
+async fn preloading_arms_a_list_from_the_top() {
+    let mut harness = two_songs().await;
+    harness.run_step("goto 0 1").await.unwrap();
+    select(&mut harness, "Encore").await;
+    go(&mut harness).await;
+    go(&mut harness).await;
+    select(&mut harness, "Main").await;
+    go(&mut harness).await;
+
+    harness
+        .command(ConsoleCommand::PreloadCueList {
+            name: "Encore".to_string(),
+        })
+        .await
+        .unwrap();
+    go(&mut harness).await;
+    assert_eq!(playing(&harness).await, (1, 0));
+
+    // The playing list can't be preloaded, and unknown names are rejected
+    let error = harness
+        .command(ConsoleCommand::PreloadCueList {
+            name: "Encore".to_string(),
+        })
+        .await
+        .unwrap_err();
+    assert_eq!(error, "Cue list 'Encore' is already playing");
+    let error = harness
+        .command(ConsoleCommand::SelectCueList {
+            name: "Finale".to_string(),
+        })
+        .await
+        .unwrap_err();
+    assert_eq!(error, "No cue list named 'Finale'");
+    assert_eq!(
+        harness
+            .console
+            .cue_manager
+            .read()
+            .await
+            .get_selected_cue_list_idx(),
+        1
+    );
+}
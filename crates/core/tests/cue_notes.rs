@@ -0,0 +1,76 @@
+mod harness;
+
+use halo_core::{ConsoleCommand, Cue};
+use harness::Harness;
+
+const PYRO: &str = "Pyro safety: confirm the stage is clear";
+
+#[test]
+fn notes_and_warnings_round_trip_through_the_show_file() {
+    let cue = Cue {
+        name: "Finale".to_string(),
+        notes: "Wait for the second chorus".to_string(),
+        warning: PYRO.to_string(),
+        ..Cue::default()
+    };
+
+    let json = serde_json::to_value(&cue).unwrap();
+    assert_eq!(json["notes"], "Wait for the second chorus");
+    assert_eq!(json["warning"], PYRO);
+    let loaded: Cue = serde_json::from_value(json).unwrap();
+    assert_eq!(loaded.notes, cue.notes);
+    assert_eq!(loaded.warning, cue.warning);
+
+    // Cues without them leave them out, and older show files load without them
+    let json = serde_json::to_value(Cue::default()).unwrap();
+    assert!(json.get("notes").is_none());
+    assert!(json.get("warning").is_none());
+    let loaded: Cue = serde_json::from_value(json).unwrap();
+    assert!(loaded.notes.is_empty() && loaded.warning.is_empty());
+}
+
+/// two_pars.json with a warning on Left Red
+async fn load_with_warning() -> Harness {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues[1].warning = PYRO.to_string();
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+    harness
+}
+
+#[tokio::test]
+async fn go_waits_for_the_warning_to_be_confirmed() {
+    let mut harness = load_with_warning().await;
+
+    harness.run_step("go").await.unwrap();
+    harness.run_step("expect state stopped").await.unwrap();
+    harness.run_step("expect cue 0").await.unwrap();
+    assert_eq!(harness.cue_warnings, [PYRO]);
+
+    // Pressing Go again still asks
+    harness.run_step("go").await.unwrap();
+    harness.run_step("expect cue 0").await.unwrap();
+    assert_eq!(harness.cue_warnings.len(), 2);
+
+    harness.run_step("confirm").await.unwrap();
+    harness.run_step("expect state playing").await.unwrap();
+    harness.run_step("expect cue 1").await.unwrap();
+
+    // Cues without a warning go straight away
+    harness.run_step("go").await.unwrap();
+    harness.run_step("expect cue 2").await.unwrap();
+    assert_eq!(harness.cue_warnings.len(), 2);
+}
+
+#[tokio::test]
+async fn jumping_to_a_cue_skips_the_confirmation() {
+    let mut harness = load_with_warning().await;
+
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.run_step("expect cue 1").await.unwrap();
+    assert!(harness.cue_warnings.is_empty());
+}
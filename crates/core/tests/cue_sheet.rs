@@ -0,0 +1,200 @@
+use std::time::Duration;
+
+use halo_core::{merge_cue_sheet, parse_cue_sheet, Cue, CueList, Follow, Meter, SheetTime};
+
+fn cue(id: usize, name: &str) -> Cue {
+    Cue {
+        id,
+        name: name.to_string(),
+        ..Cue::default()
+    }
+}
+
+/// A list part way through programming: a preset, an opening not yet timed, a verse that
+/// follows on and a chorus already anchored to the timeline
+fn base_list() -> CueList {
+    CueList {
+        name: "Main".to_string(),
+        cues: vec![
+            cue(0, "Preset"),
+            cue(1, "Opening"),
+            Cue {
+                follow: Some(Follow::After(Duration::from_secs(10))),
+                ..cue(2, "Verse")
+            },
+            Cue {
+                timecode: Some("00:01:00:00".to_string()),
+                ..cue(3, "Chorus")
+            },
+            cue(5, "Outro"),
+        ],
+        audio_file: None,
+        default_fade: None,
+        default_values: Vec::new(),
+        move_in_black: None,
+    }
+}
+
+const SHEET: &str = "\
+Cue,Label,Time,Position,Look
+1,Intro,0:05,,
+2,Verse,,9.1,
+
+,Chorus,1:00,,
+4,Drop,,41.1,\"Strobes, full white\"
+5,Outro,2:30.5,,
+";
+
+#[test]
+fn sheets_read_absolute_and_musical_times() {
+    let rows = parse_cue_sheet(SHEET).unwrap();
+    let times: Vec<_> = rows.iter().map(|r| r.time).collect();
+    assert_eq!(
+        times,
+        [
+            Some(SheetTime::Absolute(Duration::from_secs(5))),
+            Some(SheetTime::Musical { bar: 9, beat: 1 }),
+            Some(SheetTime::Absolute(Duration::from_secs(60))),
+            Some(SheetTime::Musical { bar: 41, beat: 1 }),
+            Some(SheetTime::Absolute(Duration::from_millis(150_500))),
+        ]
+    );
+    assert_eq!(rows[3].look, "Strobes, full white");
+    assert_eq!(rows[3].line, 6);
+
+    // Bar 9 is 32 beats in, 16 seconds at 120 bpm
+    let meter = Meter::default();
+    assert_eq!(
+        SheetTime::Musical { bar: 9, beat: 1 }.resolve(&meter),
+        Duration::from_secs(16)
+    );
+    assert_eq!(
+        SheetTime::absolute("1:02:03"),
+        Ok(SheetTime::Absolute(Duration::from_secs(3723)))
+    );
+}
+
+#[test]
+fn each_column_reads_its_own_kind_of_time() {
+    // The same cell is seconds in the time column and a bar and beat in the position column
+    let rows = parse_cue_sheet("Cue,Time,Position\n1,75.5,\n2,,75.5\n3,1.25,\n").unwrap();
+    let times: Vec<_> = rows.iter().map(|r| r.time).collect();
+    assert_eq!(
+        times,
+        [
+            Some(SheetTime::Absolute(Duration::from_millis(75_500))),
+            Some(SheetTime::Musical { bar: 75, beat: 5 }),
+            Some(SheetTime::Absolute(Duration::from_millis(1_250))),
+        ]
+    );
+
+    // A cell in the wrong column is refused rather than read as the other kind
+    assert_eq!(
+        parse_cue_sheet("Cue,Position\n1,1:20\n"),
+        Err("Line 2: '1:20' is a time, not a bar.beat position".to_string())
+    );
+    assert_eq!(
+        parse_cue_sheet("Cue,Time\n1,17.3.2\n"),
+        Err("Line 2: '17.3.2' isn't a time, write it as seconds, m:ss or h:mm:ss".to_string())
+    );
+    assert_eq!(
+        parse_cue_sheet("Cue,Time,Position\n1,0:05,bar 3\n"),
+        Err("Line 2: 'bar 3' isn't a bar.beat position".to_string())
+    );
+}
+
+#[test]
+fn merging_times_cues_and_stubs_the_rest() {
+    let mut list = base_list();
+    let rows = parse_cue_sheet(SHEET).unwrap();
+    let merge = merge_cue_sheet(&mut list, &rows, &Meter::default());
+
+    let names: Vec<_> = list.cues.iter().map(|c| c.name.as_str()).collect();
+    assert_eq!(
+        names,
+        ["Preset", "Intro", "Verse", "Chorus", "Drop", "Outro"]
+    );
+    let timecodes: Vec<_> = list.cues.iter().map(|c| c.timecode.as_deref()).collect();
+    assert_eq!(
+        timecodes,
+        [
+            None,
+            Some("00:00:05:00"),
+            None,
+            Some("00:01:00:00"),
+            Some("00:01:20:00"),
+            Some("00:02:30:15"),
+        ]
+    );
+
+    // The verse keeps following on, now until the chorus at a minute
+    assert_eq!(
+        list.cues[2].follow,
+        Some(Follow::After(Duration::from_secs(44)))
+    );
+
+    let drop = &list.cues[4];
+    assert_eq!(drop.id, 4);
+    assert_eq!(drop.notes, "TODO: Strobes, full white");
+    assert!(drop.static_values.is_empty());
+
+    assert_eq!(
+        merge.to_string(),
+        "\
+Updated 'Intro': renamed from 'Opening', at 00:00:05:00
+Updated 'Verse': follows on after 44.00s
+Updated 'Outro': at 00:02:30:15
+Added stub 'Drop' (cue 4) at 00:01:20:00
+3 updated, 1 added, 1 unchanged
+"
+    );
+
+    // A second merge of the same sheet has nothing left to do
+    let again = merge_cue_sheet(&mut list, &rows, &Meter::default());
+    assert!(again.updated.is_empty() && again.added.is_empty());
+    assert_eq!(again.unchanged, 5);
+}
+
+#[test]
+fn unnumbered_rows_become_stubs_with_the_next_id() {
+    let mut list = base_list();
+    let rows = parse_cue_sheet("Name,Bar\nWalk In,1\nPreset,2.3\nEncore,\n").unwrap();
+    let merge = merge_cue_sheet(&mut list, &rows, &Meter::default());
+
+    // Walk In comes before the preset it precedes on the sheet, Encore straight after it
+    let stubs: Vec<_> = list
+        .cues
+        .iter()
+        .filter(|c| c.notes.starts_with("TODO"))
+        .map(|c| (c.id, c.name.as_str(), c.timecode.as_deref()))
+        .collect();
+    assert_eq!(
+        stubs,
+        [(6, "Walk In", Some("00:00:00:00")), (7, "Encore", None)]
+    );
+    assert_eq!(list.cues[0].name, "Walk In");
+    assert_eq!(list.cues[2].name, "Encore");
+    // Bar 2 beat 3 is six beats in
+    assert_eq!(list.cues[1].timecode.as_deref(), Some("00:00:03:00"));
+    assert_eq!(merge.added.len(), 2);
+}
+
+#[test]
+fn bad_sheets_name_the_line() {
+    assert_eq!(
+        parse_cue_sheet("Cue,Time\n1,0:05\n2,soon\n"),
+        Err("Line 3: 'soon' isn't a time, write it as seconds, m:ss or h:mm:ss".to_string())
+    );
+    assert_eq!(
+        parse_cue_sheet("Cue,Look\n1,\"Red\n"),
+        Err("Line 2: a quoted field isn't closed".to_string())
+    );
+    assert_eq!(
+        parse_cue_sheet("Cue,Position\n1,0.0\n"),
+        Err("Line 2: '0.0' counts bars and beats from 1".to_string())
+    );
+    assert!(parse_cue_sheet("Time,Look\n0:05,Red\n")
+        .unwrap_err()
+        .contains("needs a cue or label column"));
+    assert!(parse_cue_sheet("\n\n").is_err());
+}
@@ -0,0 +1,48 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{ConsoleCommand, PlaybackState};
+use harness::Harness;
+
+#[tokio::test]
+async fn status_tracks_progress_through_the_running_cue() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues[1].fade_time = Duration::from_secs(2);
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+
+    let status = harness.console.cue_manager.read().await.status(2);
+    assert_eq!(status.len(), 1);
+    assert_eq!(status[0].playback_state, PlaybackState::Stopped);
+    assert_eq!(status[0].active_cue, None);
+    assert_eq!(status[0].pending, ["Preset", "Left Red"]);
+
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(500)).await.unwrap();
+
+    let status = harness.console.cue_manager.read().await.status(2);
+    let main = &status[0];
+    assert!(main.is_current);
+    assert_eq!(main.playback_state, PlaybackState::Playing);
+    assert_eq!(main.processed, 1);
+    assert_eq!(main.pending, ["Right Half", "Blackout"]);
+
+    let active = main.active_cue.as_ref().unwrap();
+    assert_eq!(active.name, "Left Red");
+    assert_eq!(active.duration, Duration::from_secs(2));
+    assert_eq!(active.elapsed, Duration::from_millis(500));
+    assert_eq!(active.progress, 0.25);
+
+    // Progress stops at the end of the cue
+    harness.advance(Duration::from_secs(3)).await.unwrap();
+    let status = harness.console.cue_manager.read().await.status(1);
+    let active = status[0].active_cue.as_ref().unwrap();
+    assert_eq!(active.progress, 1.0);
+    assert_eq!(status[0].pending, ["Right Half"]);
+}
@@ -0,0 +1,130 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{Attribute, ConsoleCommand, Cue, CueList, DefaultValue, StaticValue};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+
+fn value(fixture_id: usize, channel_type: ChannelType, value: u8) -> StaticValue {
+    StaticValue {
+        fixture_id,
+        channel_type,
+        value,
+    }
+}
+
+fn default(channel_type: ChannelType, value: u8) -> DefaultValue {
+    DefaultValue {
+        channel_type,
+        value,
+    }
+}
+
+/// A list that fades in a second and lights everything white unless told otherwise
+fn white_list(cues: Vec<Cue>) -> CueList {
+    CueList {
+        name: "White".to_string(),
+        cues,
+        audio_file: None,
+        default_fade: Some(Duration::from_secs(1)),
+        default_values: vec![
+            default(ChannelType::Red, 255),
+            default(ChannelType::Green, 255),
+            default(ChannelType::Blue, 255),
+        ],
+        move_in_black: None,
+    }
+}
+
+fn channel(cue: &Cue, fixture_id: usize, channel_type: ChannelType) -> Option<u8> {
+    cue.static_values
+        .iter()
+        .find(|v| v.fixture_id == fixture_id && v.channel_type == channel_type)
+        .map(|v| v.value)
+}
+
+#[test]
+fn fades_come_from_the_attribute_then_the_cue_then_the_list() {
+    let list = white_list(vec![]);
+
+    let plain = list.resolve(&Cue::default());
+    assert_eq!(plain.fade_for(Attribute::Color), Duration::from_secs(1));
+
+    let slow = list.resolve(&Cue {
+        fade_time: Duration::from_secs(3),
+        ..Cue::default()
+    });
+    assert_eq!(slow.fade_for(Attribute::Color), Duration::from_secs(3));
+
+    // An explicit zero on an attribute snaps it, even with fades above it
+    let snap = list.resolve(&Cue {
+        fade_time: Duration::from_secs(3),
+        intensity_fade: Some(Duration::ZERO),
+        ..Cue::default()
+    });
+    assert_eq!(snap.fade_for(Attribute::Intensity), Duration::ZERO);
+    assert_eq!(snap.fade_for(Attribute::Color), Duration::from_secs(3));
+}
+
+#[test]
+fn values_come_from_the_cue_then_its_defaults_then_the_list() {
+    let list = white_list(vec![]);
+    let cue = list.resolve(&Cue {
+        static_values: vec![
+            value(0, ChannelType::Dimmer, 255),
+            // Explicitly dark, which the list's white mustn't undo
+            value(0, ChannelType::Red, 0),
+            value(1, ChannelType::Dimmer, 128),
+        ],
+        default_values: vec![default(ChannelType::Blue, 64)],
+        ..Cue::default()
+    });
+
+    assert_eq!(channel(&cue, 0, ChannelType::Red), Some(0));
+    assert_eq!(channel(&cue, 0, ChannelType::Green), Some(255));
+    assert_eq!(channel(&cue, 0, ChannelType::Blue), Some(64));
+    assert_eq!(channel(&cue, 1, ChannelType::Red), Some(255));
+    assert_eq!(channel(&cue, 1, ChannelType::Blue), Some(64));
+    assert_eq!(cue.static_values.len(), 8);
+
+    // Fixtures the cue doesn't use are left alone
+    assert_eq!(channel(&cue, 2, ChannelType::Red), None);
+}
+
+#[tokio::test]
+async fn playback_and_status_use_the_resolved_cue() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    harness
+        .command(ConsoleCommand::SetCueLists {
+            cue_lists: vec![white_list(vec![Cue {
+                name: "Up".to_string(),
+                static_values: vec![value(0, ChannelType::Dimmer, 255)],
+                ..Cue::default()
+            }])],
+        })
+        .await
+        .unwrap();
+
+    harness.run_step("goto 0 0").await.unwrap();
+    let status = harness.console.cue_manager.read().await.status(0);
+    assert_eq!(
+        status[0].active_cue.as_ref().unwrap().duration,
+        Duration::from_secs(1)
+    );
+
+    // Part way through the list's fade, then white at full
+    harness.advance(Duration::from_millis(500)).await.unwrap();
+    harness
+        .run_step("expect channel 0 dimmer 128")
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(600)).await.unwrap();
+    for address in 1..=4 {
+        harness
+            .run_step(&format!("expect dmx 1 {address} 255"))
+            .await
+            .unwrap();
+    }
+}
@@ -0,0 +1,91 @@
+mod harness;
+
+use std::path::{Path, PathBuf};
+use std::time::Duration;
+
+use halo_core::{demo_show, simulate_show, ConsoleCommand, DEMO_CUE_TIME};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+
+fn write_demo(dir: &Path) -> PathBuf {
+    let path = dir.join("demo.json");
+    let show = demo_show().unwrap();
+    std::fs::write(&path, serde_json::to_string(&show).unwrap()).unwrap();
+    path
+}
+
+/// Each PAR's level on `channel_type`, in patch order
+async fn pars(harness: &Harness, channel_type: ChannelType) -> Vec<u8> {
+    let fixtures = harness.console.fixtures.read().await;
+    fixtures[..8]
+        .iter()
+        .map(|f| f.channel_value(&channel_type).unwrap())
+        .collect()
+}
+
+#[tokio::test]
+async fn the_demo_plays_itself_once_loaded() {
+    let dir = tempfile::tempdir().unwrap();
+    let mut harness = Harness::new().await;
+    let path = write_demo(dir.path());
+    harness
+        .command(ConsoleCommand::LoadShow { path })
+        .await
+        .unwrap();
+
+    // Lit well within ten seconds of starting
+    harness.advance(Duration::from_secs(3)).await.unwrap();
+    assert_eq!(pars(&harness, ChannelType::Dimmer).await, [255; 8]);
+    assert_eq!(pars(&harness, ChannelType::Green).await, [120; 8]);
+    harness
+        .run_step("expect channel 8 dimmer 255")
+        .await
+        .unwrap();
+    harness
+        .run_step("expect channel 9 dimmer 255")
+        .await
+        .unwrap();
+
+    // Into the chase, some PARs are on and some off
+    harness.advance(DEMO_CUE_TIME).await.unwrap();
+    harness.run_step("expect cue 1").await.unwrap();
+    assert_eq!(pars(&harness, ChannelType::Blue).await, [255; 8]);
+    let lit = pars(&harness, ChannelType::Dimmer)
+        .await
+        .iter()
+        .filter(|&&level| level > 127)
+        .count();
+    assert!((1..8).contains(&lit), "{lit} PARs lit");
+
+    // The spots change gobo on the beat
+    let mut gobos = Vec::new();
+    for _ in 0..8 {
+        harness.advance(Duration::from_millis(250)).await.unwrap();
+        let (gobo, shaking) = harness.console.fixtures.read().await[8].gobo().unwrap();
+        assert!((1..=4).contains(&gobo) && !shaking, "gobo {gobo}");
+        if !gobos.contains(&gobo) {
+            gobos.push(gobo);
+        }
+    }
+    assert!(gobos.len() > 1, "{gobos:?}");
+
+    // The rainbow puts a different color on each PAR
+    harness.advance(DEMO_CUE_TIME).await.unwrap();
+    harness.run_step("expect cue 2").await.unwrap();
+    let red = pars(&harness, ChannelType::Red).await;
+    assert!(red.iter().any(|&level| level != red[0]), "{red:?}");
+
+    // Everything goes home at the end
+    harness.advance(DEMO_CUE_TIME * 3).await.unwrap();
+    harness.run_step("expect cue 4").await.unwrap();
+    assert_eq!(pars(&harness, ChannelType::Dimmer).await, [0; 8]);
+}
+
+#[tokio::test]
+async fn the_demo_show_simulates_cleanly() {
+    let dir = tempfile::tempdir().unwrap();
+    let report = simulate_show(&write_demo(dir.path()), None).await.unwrap();
+
+    assert!(report.unused_fixtures.is_empty(), "{report}");
+    assert!(!report.has_errors(), "{report}");
+}
@@ -0,0 +1,118 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{ConsoleCommand, Settings};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+
+fn dimmer(harness: &Harness, fixture: usize) -> u8 {
+    let fixtures = harness.console.fixtures.try_read().unwrap();
+    fixtures[fixture]
+        .channel_value(&ChannelType::Dimmer)
+        .unwrap()
+}
+
+/// Load two_pars.json and start a two second fade into Left Red
+async fn mid_fade(settings: Settings) -> Harness {
+    let mut harness = Harness::new().await;
+    harness
+        .command(ConsoleCommand::UpdateSettings { settings })
+        .await
+        .unwrap();
+    harness.run_step("load two_pars.json").await.unwrap();
+
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues[1].fade_time = Duration::from_secs(2);
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+
+    harness.run_step("goto 0 0").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(500)).await.unwrap();
+    harness
+}
+
+#[tokio::test]
+async fn disabled_fixture_is_frozen_and_resumes_with_the_cue() {
+    let mut harness = mid_fade(Settings::default()).await;
+    assert!(dimmer(&harness, 0) > 0);
+
+    harness
+        .command(ConsoleCommand::DisableFixture { fixture_id: 0 })
+        .await
+        .unwrap();
+    for _ in 0..8 {
+        harness.advance(Duration::from_millis(250)).await.unwrap();
+        harness.run_step("expect dmx 1 1 0").await.unwrap();
+        harness.run_step("expect dmx 1 2 0").await.unwrap();
+    }
+
+    // The fade finished underneath, so the fixture comes straight back at the target
+    harness
+        .command(ConsoleCommand::EnableFixture { fixture_id: 0 })
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+    harness.run_step("expect dmx 1 2 255").await.unwrap();
+}
+
+#[tokio::test]
+async fn held_fixture_keeps_its_last_values() {
+    let mut harness = mid_fade(Settings {
+        hold_disabled_fixtures: true,
+        ..Settings::default()
+    })
+    .await;
+
+    harness
+        .command(ConsoleCommand::DisableFixture { fixture_id: 0 })
+        .await
+        .unwrap();
+    let held = dimmer(&harness, 0);
+    assert!(held > 0 && held < 255, "dimmer {held}");
+
+    let mut levels = Vec::new();
+    for _ in 0..8 {
+        harness.advance(Duration::from_millis(250)).await.unwrap();
+        levels.push(dimmer(&harness, 0));
+    }
+    assert!(levels.iter().all(|level| *level == held), "{levels:?}");
+
+    harness
+        .command(ConsoleCommand::EnableFixture { fixture_id: 0 })
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+    assert_eq!(dimmer(&harness, 0), 255);
+}
+
+#[tokio::test]
+async fn disabling_a_universe_freezes_every_fixture_in_it() {
+    let mut harness = mid_fade(Settings::default()).await;
+    harness.run_step("goto 0 2").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness
+        .run_step("expect channel 1 dimmer 128")
+        .await
+        .unwrap();
+
+    harness
+        .command(ConsoleCommand::DisableUniverse { universe: 1 })
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect dmx 1 10 0").await.unwrap();
+    harness.run_step("expect dmx 1 1 0").await.unwrap();
+
+    harness
+        .command(ConsoleCommand::EnableUniverse { universe: 1 })
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect dmx 1 10 128").await.unwrap();
+}
@@ -0,0 +1,78 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{ConsoleCommand, ContributionSource};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+
+#[tokio::test]
+async fn an_imported_universe_renders_the_same_look_and_the_next_cue_fades_from_it() {
+    let mut house = Harness::new().await;
+    house.run_step("load two_pars.json").await.unwrap();
+    house.run_step("goto 0 1").await.unwrap();
+    house.run_step("goto 0 2").await.unwrap();
+    house.advance(Duration::from_millis(100)).await.unwrap();
+    let on_the_wire = house.console.rendered_universe(1).await.unwrap();
+
+    // Something the house console drives that isn't in our patch
+    let mut captured = on_the_wire.clone();
+    captured.resize(512, 0);
+    captured[299] = 77;
+
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    let import = harness
+        .console
+        .import_dmx_snapshot(1, &captured)
+        .await
+        .unwrap();
+    assert_eq!(import.skipped, [300]);
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    assert_eq!(
+        harness.console.rendered_universe(1).await.unwrap(),
+        on_the_wire
+    );
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+    harness.run_step("expect dmx 1 10 128").await.unwrap();
+
+    let contributions = harness.console.contributions("Left PAR").await.unwrap();
+    let dimmer = contributions
+        .iter()
+        .find(|c| c.channel_type == ChannelType::Dimmer && c.won)
+        .unwrap();
+    assert_eq!(
+        dimmer.source,
+        ContributionSource::Cue("Imported universe 1".to_string())
+    );
+
+    // The blackout fades down from the inherited look rather than snapping from black
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues[3].fade_time = Duration::from_secs(1);
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+    harness.run_step("goto 0 3").await.unwrap();
+    harness.advance(Duration::from_millis(500)).await.unwrap();
+    let dimmer = harness.console.rendered_universe(1).await.unwrap()[0];
+    assert!(dimmer > 0 && dimmer < 255, "{dimmer}");
+    harness.advance(Duration::from_millis(600)).await.unwrap();
+    harness.run_step("expect dmx 1 1 0").await.unwrap();
+}
+
+#[tokio::test]
+async fn a_universe_with_nothing_patched_is_refused() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    assert!(harness
+        .console
+        .import_dmx_snapshot(2, &[255; 16])
+        .await
+        .is_err());
+    assert!(harness
+        .console
+        .import_dmx_snapshot(1, &[0; 513])
+        .await
+        .is_err());
+}
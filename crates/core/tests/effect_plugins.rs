@@ -0,0 +1,186 @@
+#[path = "../examples/plugin/flicker.rs"]
+mod flicker;
+mod harness;
+
+use std::sync::atomic::{AtomicUsize, Ordering};
+use std::sync::Arc;
+use std::time::Duration;
+
+use flicker::Flicker;
+use halo_core::{
+    sine_effect, ConsoleCommand, Effect, EffectContext, EffectDistribution, EffectMapping,
+    EffectRegistry, EffectRelease, EffectSource, EffectType, RhythmState,
+};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+
+fn rhythm(beat_phase: f64) -> RhythmState {
+    RhythmState {
+        beat_phase,
+        bar_phase: 0.0,
+        phrase_phase: 0.0,
+        beats_per_bar: 4,
+        bars_per_phrase: 4,
+        last_tap_time: None,
+        tap_count: 0,
+    }
+}
+
+fn dimmer_effect(source: &str) -> EffectMapping {
+    EffectMapping {
+        name: "Plugin".to_string(),
+        effect: Effect {
+            source: Some(source.to_string()),
+            ..Effect::default()
+        },
+        fixture_ids: vec![0, 1],
+        channel_types: vec![ChannelType::Dimmer],
+        distribution: EffectDistribution::All,
+        release: EffectRelease::Hold,
+    }
+}
+
+/// two_pars.json with `mapping` running from Left Red, and `registry` in the console
+async fn load_with_effect(registry: EffectRegistry, mapping: EffectMapping) -> Harness {
+    let mut harness = Harness::new().await;
+    harness.console.set_effect_registry(registry).await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues[1].effects.push(mapping);
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+    harness
+}
+
+fn dimmers(harness: &Harness) -> (u8, u8) {
+    let fixtures = harness.console.fixtures.try_read().unwrap();
+    let dimmer = |id: usize| {
+        fixtures[id]
+            .channels
+            .iter()
+            .find(|c| c.channel_type == ChannelType::Dimmer)
+            .unwrap()
+            .value
+    };
+    (dimmer(0), dimmer(1))
+}
+
+#[tokio::test]
+async fn a_registered_effect_drives_the_fixtures_it_is_mapped_to() {
+    let mut registry = EffectRegistry::new();
+    registry.register("Flicker", Flicker::factory);
+    let mut harness = load_with_effect(registry, dimmer_effect("flicker")).await;
+    harness.run_step("goto 0 1").await.unwrap();
+
+    let mut seen = Vec::new();
+    for _ in 0..20 {
+        harness.advance(Duration::from_millis(25)).await.unwrap();
+        let (left, right) = dimmers(&harness);
+        assert!((153..=255).contains(&left), "{left} is outside the flicker");
+        assert!(
+            (153..=255).contains(&right),
+            "{right} is outside the flicker"
+        );
+        seen.push((left, right));
+    }
+    seen.dedup();
+    assert!(seen.len() > 1, "the flicker never moved");
+}
+
+#[derive(Default)]
+struct Calls {
+    starts: AtomicUsize,
+    frames: AtomicUsize,
+    stops: AtomicUsize,
+}
+
+struct Counting(Arc<Calls>);
+
+impl EffectSource for Counting {
+    fn start(&mut self) {
+        self.0.starts.fetch_add(1, Ordering::SeqCst);
+    }
+
+    fn values(&mut self, _context: &EffectContext, fixture_ids: &[usize]) -> Vec<f64> {
+        self.0.frames.fetch_add(1, Ordering::SeqCst);
+        vec![0.5; fixture_ids.len()]
+    }
+
+    fn stop(&mut self) {
+        self.0.stops.fetch_add(1, Ordering::SeqCst);
+    }
+}
+
+#[tokio::test]
+async fn sources_start_once_and_stop_when_their_effect_does() {
+    let calls = Arc::new(Calls::default());
+    let mut registry = EffectRegistry::new();
+    let factory_calls = calls.clone();
+    registry.register("Counting", move |_| {
+        Box::new(Counting(factory_calls.clone()))
+    });
+    let mut harness = load_with_effect(registry, dimmer_effect("Counting")).await;
+
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(500)).await.unwrap();
+    harness
+        .run_step("expect channel 0 dimmer 127")
+        .await
+        .unwrap();
+    assert_eq!(calls.starts.load(Ordering::SeqCst), 1);
+    assert!(calls.frames.load(Ordering::SeqCst) >= 10);
+    assert_eq!(calls.stops.load(Ordering::SeqCst), 0);
+
+    harness.command(ConsoleCommand::Stop).await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    let frames = calls.frames.load(Ordering::SeqCst);
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    assert_eq!(calls.stops.load(Ordering::SeqCst), 1);
+    assert_eq!(calls.frames.load(Ordering::SeqCst), frames);
+}
+
+#[tokio::test]
+async fn effects_naming_an_unregistered_source_leave_fixtures_alone() {
+    let mut harness = load_with_effect(EffectRegistry::new(), dimmer_effect("Flicker")).await;
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(200)).await.unwrap();
+    harness
+        .run_step("expect channel 0 dimmer 255")
+        .await
+        .unwrap();
+}
+
+#[test]
+fn built_in_waveforms_are_sources_too() {
+    let registry = EffectRegistry::new();
+    assert_eq!(
+        registry.names(),
+        ["pulse", "random", "sawtooth", "sine", "square", "triangle"]
+    );
+
+    let mapping = EffectMapping {
+        name: "Wave".to_string(),
+        effect: Effect {
+            effect_type: EffectType::Sine,
+            ..Effect::default()
+        },
+        fixture_ids: vec![0, 1, 2, 3],
+        channel_types: vec![ChannelType::Dimmer],
+        distribution: EffectDistribution::Wave(0.25),
+        release: EffectRelease::Hold,
+    };
+    let mut source = registry.create(&mapping.effect).unwrap();
+    let rhythm = rhythm(0.1);
+    let context = EffectContext {
+        effect: &mapping.effect,
+        distribution: &mapping.distribution,
+        rhythm: &rhythm,
+    };
+    let values = source.values(&context, &mapping.fixture_ids);
+    for (index, value) in values.iter().enumerate() {
+        let phase = (0.1 + index as f64 * 0.25) % 1.0;
+        assert!((value - sine_effect(phase)).abs() < 1e-9);
+    }
+}
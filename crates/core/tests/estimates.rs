@@ -0,0 +1,128 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{
+    ConsoleCommand, CueDuration, Effect, EffectDistribution, EffectMapping, EffectParams,
+    EffectRelease, Follow, Interval, Meter,
+};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+
+/// A dimmer chase that goes round once a bar
+fn bar_chase() -> EffectMapping {
+    EffectMapping {
+        name: "Chase".to_string(),
+        effect: Effect {
+            params: EffectParams {
+                interval: Interval::Bar,
+                ..EffectParams::default()
+            },
+            ..Effect::default()
+        },
+        fixture_ids: vec![0, 1],
+        channel_types: vec![ChannelType::Dimmer],
+        distribution: EffectDistribution::All,
+        release: EffectRelease::Hold,
+    }
+}
+
+#[tokio::test]
+async fn fades_effect_cycles_and_follow_chains_are_estimated() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    let meter = Meter::default();
+
+    // A plain fade runs for its fade time
+    cue_lists[0].cues[0].fade_time = Duration::from_secs(3);
+    assert_eq!(
+        cue_lists[0].cues[0].estimated_duration(&meter),
+        CueDuration::Fixed(Duration::from_secs(3))
+    );
+
+    // Four cycles of a bar chase at 120 BPM in 4/4 take 8s
+    cue_lists[0].cues[1].effects.push(bar_chase());
+    assert_eq!(
+        cue_lists[0].cues[1].estimated_duration(&meter),
+        CueDuration::OpenEnded
+    );
+    cue_lists[0].cues[1].follow = Some(Follow::Cycles(4));
+    assert_eq!(
+        cue_lists[0].cues[1].estimated_duration(&meter),
+        CueDuration::Fixed(Duration::from_secs(8))
+    );
+    let half_time = Meter { bpm: 60.0, ..meter };
+    assert_eq!(
+        cue_lists[0].cues[1].estimated_duration(&half_time),
+        CueDuration::Fixed(Duration::from_secs(16))
+    );
+
+    // The chain runs on through the follow cues to the first that waits for a Go
+    cue_lists[0].cues[0].follow = Some(Follow::After(Duration::from_secs(2)));
+    cue_lists[0].cues[2].fade_time = Duration::from_secs(1);
+    assert_eq!(
+        cue_lists[0].chain_duration(0, &meter),
+        CueDuration::Fixed(Duration::from_secs(11))
+    );
+    assert_eq!(
+        cue_lists[0].chain_duration(2, &meter),
+        CueDuration::Fixed(Duration::from_secs(1))
+    );
+
+    // An effect left running until a Go leaves the chain open-ended
+    cue_lists[0].cues[1].follow = None;
+    assert_eq!(
+        cue_lists[0].chain_duration(0, &meter),
+        CueDuration::OpenEnded
+    );
+    assert_eq!(CueDuration::OpenEnded.to_string(), "open-ended");
+}
+
+#[tokio::test]
+async fn follow_cues_run_the_next_cue_on_their_own() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues[1].follow = Some(Follow::After(Duration::from_secs(1)));
+    cue_lists[0].cues[2].effects.push(bar_chase());
+    cue_lists[0].cues[2].follow = Some(Follow::Cycles(1));
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(500)).await.unwrap();
+    {
+        let status = harness.console.cue_manager.read().await.status(0);
+        let active = status[0].active_cue.as_ref().unwrap();
+        assert_eq!(active.name, "Left Red");
+        assert_eq!(active.estimate, CueDuration::Fixed(Duration::from_secs(1)));
+        assert_eq!(active.chain, CueDuration::Fixed(Duration::from_secs(3)));
+        assert_eq!(active.progress, 0.5);
+    }
+
+    harness.advance(Duration::from_millis(600)).await.unwrap();
+    assert_eq!(
+        harness
+            .console
+            .cue_manager
+            .read()
+            .await
+            .get_current_cue_index(),
+        2
+    );
+
+    // One bar of the chase at 120 BPM, then on to the blackout
+    harness.advance(Duration::from_secs(2)).await.unwrap();
+    assert_eq!(
+        harness
+            .console
+            .cue_manager
+            .read()
+            .await
+            .get_current_cue_index(),
+        3
+    );
+}
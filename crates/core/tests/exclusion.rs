@@ -0,0 +1,190 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{
+    Chase, ChaseDirection, ChaseRate, ChaseStep, ConsoleCommand, ContributionSource, Cue, CueList,
+    ExclusionConflict, ExclusionGroup, Rule, StaticValue,
+};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+
+fn dimmer(fixture_id: usize, value: u8) -> StaticValue {
+    StaticValue {
+        fixture_id,
+        channel_type: ChannelType::Dimmer,
+        value,
+    }
+}
+
+/// two_pars.json with the right PAR standing in for a haze machine, a second list that runs
+/// it and a third that chases both PARs
+async fn haze_show() -> Harness {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists.push(CueList {
+        name: "Haze".to_string(),
+        cues: vec![Cue::intensity_only("Haze On", &[1], 60, Duration::ZERO)],
+        audio_file: None,
+        default_fade: None,
+        default_values: vec![],
+        move_in_black: None,
+    });
+    cue_lists.push(CueList {
+        name: "Pulse".to_string(),
+        cues: vec![Cue {
+            name: "Pulse".to_string(),
+            chase: Some(Chase {
+                steps: vec![
+                    ChaseStep {
+                        static_values: vec![dimmer(0, 200), dimmer(1, 255)],
+                        channels: vec![],
+                    },
+                    ChaseStep {
+                        static_values: vec![dimmer(0, 100), dimmer(1, 30)],
+                        channels: vec![],
+                    },
+                ],
+                rate: ChaseRate::Beats(1.0),
+                direction: ChaseDirection::Forward,
+                crossfade: 0.0,
+            }),
+            ..Cue::default()
+        }],
+        audio_file: None,
+        default_fade: None,
+        default_values: vec![],
+        move_in_black: None,
+    });
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+    harness
+        .console
+        .set_exclusion_groups(vec![ExclusionGroup {
+            name: "haze".to_string(),
+            fixture_ids: vec![1],
+            release_after: Duration::from_secs(2),
+        }])
+        .await;
+    harness
+}
+
+#[tokio::test]
+async fn only_the_list_holding_a_group_reaches_its_fixtures() {
+    let mut harness = haze_show().await;
+
+    // Right Half claims the haze
+    harness.run_step("goto 0 2").await.unwrap();
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+    harness.run_step("expect dmx 1 10 128").await.unwrap();
+    assert_eq!(
+        harness.console.exclusion_owners().await,
+        [("haze".to_string(), Some(0))]
+    );
+
+    // The haze list is kept off it, and says so once
+    harness.run_step("goto 1 0").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect dmx 1 10 128").await.unwrap();
+    assert_eq!(
+        harness.console.take_exclusion_conflicts().await,
+        [ExclusionConflict {
+            group: "haze".to_string(),
+            list_index: 1,
+            cue_index: 0,
+            owner: 0,
+        }]
+    );
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    assert!(harness.console.take_exclusion_conflicts().await.is_empty());
+
+    let contributions = harness.console.contributions("Right PAR").await.unwrap();
+    let dimmer: Vec<_> = contributions
+        .iter()
+        .filter(|c| c.channel_type == ChannelType::Dimmer)
+        .map(|c| (c.source.clone(), c.value, c.won, c.rule))
+        .collect();
+    assert_eq!(
+        dimmer,
+        [
+            (
+                ContributionSource::Excluded("haze".to_string()),
+                60,
+                false,
+                Rule::Excluded
+            ),
+            (
+                ContributionSource::Cue("Right Half".to_string()),
+                128,
+                true,
+                Rule::Ltp
+            ),
+        ]
+    );
+}
+
+#[tokio::test]
+async fn a_claim_lets_go_once_its_list_has_been_idle() {
+    let mut harness = haze_show().await;
+    harness.run_step("goto 0 2").await.unwrap();
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+    harness.run_step("goto 1 0").await.unwrap();
+    harness.advance(Duration::from_secs(1)).await.unwrap();
+    harness.run_step("expect dmx 1 10 128").await.unwrap();
+
+    // The main list hasn't played for two seconds, so the haze list takes over
+    harness.advance(Duration::from_millis(1100)).await.unwrap();
+    harness.run_step("expect dmx 1 10 60").await.unwrap();
+    assert_eq!(
+        harness.console.exclusion_owners().await,
+        [("haze".to_string(), Some(1))]
+    );
+}
+
+#[tokio::test]
+async fn a_chase_in_another_list_steps_around_the_group() {
+    let mut harness = haze_show().await;
+    harness.run_step("goto 0 2").await.unwrap();
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+
+    // The chase runs on the left PAR, beat by beat, and never reaches the haze
+    harness.run_step("goto 2 0").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness
+        .run_step("expect channel 0 dimmer 200")
+        .await
+        .unwrap();
+    harness.run_step("expect dmx 1 10 128").await.unwrap();
+    harness.advance(Duration::from_millis(500)).await.unwrap();
+    harness
+        .run_step("expect channel 0 dimmer 100")
+        .await
+        .unwrap();
+    harness.run_step("expect dmx 1 10 128").await.unwrap();
+
+    assert_eq!(
+        harness.console.take_exclusion_conflicts().await,
+        [ExclusionConflict {
+            group: "haze".to_string(),
+            list_index: 2,
+            cue_index: 0,
+            owner: 0,
+        }]
+    );
+}
+
+#[test]
+fn show_files_can_leave_out_the_release_time() {
+    let group: ExclusionGroup =
+        serde_json::from_str(r#"{ "name": "pyro", "fixture_ids": [4, 5] }"#).unwrap();
+    assert_eq!(group.release_after, Duration::from_secs(5));
+
+    let group: ExclusionGroup =
+        serde_json::from_str(r#"{ "name": "pyro", "fixture_ids": [4], "release_after": "500ms" }"#)
+            .unwrap();
+    assert_eq!(group.release_after, Duration::from_millis(500));
+}
@@ -0,0 +1,432 @@
+mod harness;
+
+use std::path::Path;
+use std::time::Duration;
+
+use halo_core::{
+    Attribute, Chase, ChaseDirection, ChaseRate, ChaseStep, ConsoleCommand, Cue, CueList,
+    StaticValue,
+};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+
+#[test]
+fn attribute_fades_fall_back_to_the_cue_fade() {
+    let cue = Cue {
+        fade_time: Duration::from_secs(2),
+        color_fade: Some(Duration::from_secs(8)),
+        intensity_fade: Some(Duration::from_millis(500)),
+        ..Cue::default()
+    };
+
+    assert_eq!(
+        cue.fade_for(Attribute::Intensity),
+        Duration::from_millis(500)
+    );
+    assert_eq!(cue.fade_for(Attribute::Color), Duration::from_secs(8));
+    assert_eq!(cue.fade_for(Attribute::Position), Duration::from_secs(2));
+    assert_eq!(cue.fade_for(Attribute::Other), Duration::from_secs(2));
+    assert_eq!(cue.longest_fade(), Duration::from_secs(8));
+}
+
+#[tokio::test]
+async fn intensity_snaps_in_while_color_is_still_fading() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+
+    // Left Red brings fixture 0 to full red: intensity in half a second, color over eight
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues[1].intensity_fade = Some(Duration::from_millis(500));
+    cue_lists[0].cues[1].color_fade = Some(Duration::from_secs(8));
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+
+    harness.run_step("goto 0 0").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect channel 0 dimmer 0").await.unwrap();
+    harness.run_step("expect channel 0 red 0").await.unwrap();
+
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(250)).await.unwrap();
+    harness
+        .run_step("expect channel 0 dimmer 128")
+        .await
+        .unwrap();
+
+    harness.advance(Duration::from_millis(750)).await.unwrap();
+    harness
+        .run_step("expect channel 0 dimmer 255")
+        .await
+        .unwrap();
+    harness.run_step("expect channel 0 red 32").await.unwrap();
+
+    // Cue progress follows the longest fade
+    harness.advance(Duration::from_secs(3)).await.unwrap();
+    harness.run_step("expect channel 0 red 128").await.unwrap();
+    let progress = harness
+        .console
+        .cue_manager
+        .read()
+        .await
+        .get_current_cue_progress();
+    assert!((progress - 0.5).abs() < 0.01, "progress {progress}");
+
+    harness.advance(Duration::from_secs(4)).await.unwrap();
+    harness.run_step("expect channel 0 red 255").await.unwrap();
+    harness.run_step("expect dmx 1 2 255").await.unwrap();
+}
+
+#[tokio::test]
+async fn intensity_cue_keeps_the_color_from_the_cue_before() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    // Left Red at half intensity, masked so its red can't stomp the color
+    let mut half = cue_lists[0].cues[1].clone();
+    half.name = "Half".to_string();
+    half.mask(&[Attribute::Intensity]);
+    half.static_values[0].value = 128;
+
+    cue_lists[0].cues = vec![
+        cue_lists[0].cues[0].clone(),
+        Cue::color_only("Blue", &[0, 1], (0, 0, 255), Duration::ZERO),
+        Cue::intensity_only("Up", &[0, 1], 255, Duration::ZERO),
+        half,
+    ];
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+
+    harness.run_step("goto 0 0").await.unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect channel 0 blue 255").await.unwrap();
+
+    harness.run_step("goto 0 2").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    for fixture in [0, 1] {
+        harness
+            .run_step(&format!("expect channel {fixture} dimmer 255"))
+            .await
+            .unwrap();
+        harness
+            .run_step(&format!("expect channel {fixture} blue 255"))
+            .await
+            .unwrap();
+    }
+
+    harness.run_step("goto 0 3").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness
+        .run_step("expect channel 0 dimmer 128")
+        .await
+        .unwrap();
+    harness.run_step("expect channel 0 red 0").await.unwrap();
+    harness.run_step("expect channel 0 blue 255").await.unwrap();
+}
+
+#[tokio::test]
+async fn ripple_brings_each_par_up_after_the_one_before() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load six_pars.json").await.unwrap();
+
+    let ripple = Cue::ripple(
+        "Ripple",
+        &[0, 1, 2, 3, 4, 5],
+        &[(ChannelType::Dimmer, 255)],
+        Duration::ZERO,
+        Duration::from_millis(100),
+    );
+    // Delays run past the cue's zero fade time, so they set how long it takes
+    assert_eq!(ripple.completion_time(), Duration::from_millis(500));
+
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues = vec![ripple];
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+    harness.run_step("goto 0 0").await.unwrap();
+
+    let lit = |harness: &Harness| {
+        let fixtures = harness.console.fixtures.try_read().unwrap();
+        fixtures
+            .iter()
+            .filter(|f| f.channel_value(&ChannelType::Dimmer) == Some(255))
+            .count()
+    };
+
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+    assert_eq!(lit(&harness), 1);
+    for expected in 2..=6 {
+        harness.advance(Duration::from_millis(100)).await.unwrap();
+        assert_eq!(lit(&harness), expected);
+    }
+    let progress = harness
+        .console
+        .cue_manager
+        .read()
+        .await
+        .get_current_cue_progress();
+    assert_eq!(progress, 1.0);
+}
+
+#[test]
+fn delays_add_to_the_fade() {
+    let mut cue = Cue::ripple(
+        "Ripple",
+        &[0, 1, 2],
+        &[(ChannelType::Dimmer, 255)],
+        Duration::from_secs(1),
+        Duration::from_millis(250),
+    );
+    assert_eq!(cue.completion_time(), Duration::from_millis(1500));
+
+    cue.color_fade = Some(Duration::from_secs(3));
+    assert_eq!(cue.completion_time(), Duration::from_millis(3500));
+}
+
+#[tokio::test]
+async fn shutting_down_mid_fade_is_prompt_and_writes_no_more_dmx() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues[1].fade_time = Duration::from_secs(17);
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_secs(2)).await.unwrap();
+
+    // Nothing waits on the fade, so shutdown is as quick as stopping the modules
+    tokio::time::timeout(Duration::from_millis(100), harness.console.shutdown())
+        .await
+        .expect("shutdown waited on the fade")
+        .unwrap();
+
+    let frames = harness.recording.lock().unwrap().frame_count;
+    harness.advance(Duration::from_secs(1)).await.unwrap();
+    assert_eq!(harness.recording.lock().unwrap().frame_count, frames);
+}
+
+/// The left PAR's dimmer as it goes out
+async fn left_dimmer(harness: &Harness) -> u8 {
+    harness.console.fixtures.read().await[0]
+        .channel_value(&ChannelType::Dimmer)
+        .unwrap()
+}
+
+/// Two PARs running `cues` as their only list
+async fn two_pars_running(cues: Vec<Cue>) -> Harness {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues = cues;
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+    harness
+}
+
+#[tokio::test]
+async fn a_fade_interrupted_part_way_carries_on_from_where_it_got_to() {
+    let mut harness = two_pars_running(vec![
+        Cue::intensity_only("Up", &[0], 255, Duration::from_secs(2)),
+        Cue::intensity_only("Down", &[0], 0, Duration::from_secs(2)),
+    ])
+    .await;
+    harness.run_step("goto 0 0").await.unwrap();
+    harness.advance(Duration::from_millis(800)).await.unwrap();
+    let interrupted = left_dimmer(&harness).await;
+    assert!((95..=110).contains(&interrupted), "{interrupted}");
+
+    // Down from about 100, never back up to where the first fade was going
+    harness.run_step("goto 0 1").await.unwrap();
+    let mut last = interrupted;
+    for _ in 0..25 {
+        harness.advance(Duration::from_millis(100)).await.unwrap();
+        let level = left_dimmer(&harness).await;
+        assert!(level <= last, "went from {last} to {level}");
+        last = level;
+    }
+    assert_eq!(last, 0);
+}
+
+#[tokio::test]
+async fn a_fade_after_a_chase_starts_from_what_the_chase_put_out() {
+    let dimmer = |value| StaticValue {
+        fixture_id: 0,
+        channel_type: ChannelType::Dimmer,
+        value,
+    };
+    // A chase holding the dimmer at full over a tracked level of nothing
+    let chased = Cue {
+        name: "Chased".to_string(),
+        static_values: vec![dimmer(0)],
+        chase: Some(Chase {
+            steps: vec![ChaseStep {
+                static_values: vec![dimmer(255)],
+                channels: vec![],
+            }],
+            rate: ChaseRate::Seconds(10.0),
+            direction: ChaseDirection::Forward,
+            crossfade: 0.0,
+        }),
+        ..Cue::default()
+    };
+    let mut harness = two_pars_running(vec![
+        chased,
+        Cue::intensity_only("Half", &[0], 128, Duration::from_secs(2)),
+    ])
+    .await;
+    harness.run_step("goto 0 0").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    assert_eq!(left_dimmer(&harness).await, 255);
+
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    assert!(left_dimmer(&harness).await > 240);
+    harness.advance(Duration::from_millis(900)).await.unwrap();
+    let halfway = left_dimmer(&harness).await;
+    assert!((185..=198).contains(&halfway), "{halfway}");
+    harness.advance(Duration::from_secs(1)).await.unwrap();
+    assert_eq!(left_dimmer(&harness).await, 128);
+}
+
+#[tokio::test]
+async fn white_amber_and_uv_fade_like_the_other_colors() {
+    // The right PAR swapped for an RGBWA+UV wash at the same address
+    let dir = tempfile::tempdir().unwrap();
+    let testdata = Path::new(env!("CARGO_MANIFEST_DIR")).join("tests/testdata/two_pars.json");
+    let mut show: serde_json::Value =
+        serde_json::from_str(&std::fs::read_to_string(testdata).unwrap()).unwrap();
+    show["fixtures"][1]["profile_id"] = "shehds-led-wash-7x18w-rgbwa-uv".into();
+    let path = dir.path().join("wash.json");
+    std::fs::write(&path, show.to_string()).unwrap();
+
+    let mut harness = Harness::new().await;
+    harness
+        .command(ConsoleCommand::LoadShow { path })
+        .await
+        .unwrap();
+    let values = [
+        (ChannelType::White, 200),
+        (ChannelType::Amber, 100),
+        (ChannelType::UV, 255),
+    ];
+    let cue = Cue {
+        name: "Warm UV".to_string(),
+        color_fade: Some(Duration::from_secs(2)),
+        static_values: [0, 1]
+            .iter()
+            .flat_map(|&fixture_id| {
+                values.iter().map(move |(channel_type, value)| StaticValue {
+                    fixture_id,
+                    channel_type: channel_type.clone(),
+                    value: *value,
+                })
+            })
+            .collect(),
+        ..Cue::default()
+    };
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues = vec![cue];
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+
+    harness.run_step("goto 0 0").await.unwrap();
+    harness.advance(Duration::from_secs(1)).await.unwrap();
+    for step in [
+        "expect channel 1 white 100",
+        "expect channel 1 amber 50",
+        "expect channel 1 uv 128",
+    ] {
+        harness.run_step(step).await.unwrap();
+    }
+
+    harness.advance(Duration::from_secs(1)).await.unwrap();
+    // The wash's white, amber and UV are its 7th to 9th channels, from address 10
+    for step in [
+        "expect dmx 1 16 200",
+        "expect dmx 1 17 100",
+        "expect dmx 1 18 255",
+        // The RGBW PAR takes the white and has nowhere to put the rest
+        "expect channel 0 white 200",
+        "expect dmx 1 7 0",
+    ] {
+        harness.run_step(step).await.unwrap();
+    }
+}
+
+#[test]
+fn fades_shorter_than_a_frame_are_flagged() {
+    let cue = Cue {
+        name: "Verse".to_string(),
+        fade_time: Duration::from_millis(10),
+        color_fade: Some(Duration::from_secs(10)),
+        position_fade: Some(Duration::ZERO),
+        ..Cue::default()
+    };
+    // A zero fade is a deliberate snap
+    assert_eq!(cue.short_fades(), ["10ms fade"]);
+
+    let list = CueList {
+        name: "Main".to_string(),
+        cues: vec![
+            cue,
+            Cue {
+                name: "Chorus".to_string(),
+                ..Cue::default()
+            },
+        ],
+        audio_file: None,
+        default_fade: Some(Duration::from_millis(35)),
+        default_values: vec![],
+        move_in_black: None,
+    };
+    assert_eq!(
+        list.short_fade_warnings(),
+        ["Cue 'Verse' in 'Main' snaps: 10ms fade shorter than one 23ms frame"]
+    );
+}
+
+#[tokio::test]
+async fn a_fade_shorter_than_a_frame_snaps_on_the_first_one() {
+    let mut harness = two_pars_running(vec![Cue::intensity_only(
+        "Blink",
+        &[0],
+        255,
+        Duration::from_millis(20),
+    )])
+    .await;
+    harness.run_step("goto 0 0").await.unwrap();
+    // A quarter of the way through the fade, if it had been one
+    harness.advance(Duration::from_millis(5)).await.unwrap();
+    assert_eq!(left_dimmer(&harness).await, 255);
+}
+
+#[tokio::test]
+async fn a_fade_just_over_a_frame_shows_a_step() {
+    let mut harness = two_pars_running(vec![Cue::intensity_only(
+        "Quick",
+        &[0],
+        255,
+        Duration::from_millis(35),
+    )])
+    .await;
+    harness.run_step("goto 0 0").await.unwrap();
+    harness.advance(Duration::from_millis(25)).await.unwrap();
+    let level = left_dimmer(&harness).await;
+    assert!(level > 0 && level < 255, "{level}");
+    harness.advance(Duration::from_millis(25)).await.unwrap();
+    assert_eq!(left_dimmer(&harness).await, 255);
+}
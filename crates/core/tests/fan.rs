@@ -0,0 +1,75 @@
+mod harness;
+
+use halo_core::{fan_values, ConsoleCommand, FanMode};
+use halo_fixtures::StagePosition;
+use harness::Harness;
+
+#[test]
+fn linear_fan_across_six_fixtures() {
+    assert_eq!(
+        fan_values(6, 10, 100, FanMode::Linear),
+        [10, 28, 46, 64, 82, 100]
+    );
+    // Fanning downwards works the same way
+    assert_eq!(
+        fan_values(6, 100, 10, FanMode::Linear),
+        [100, 82, 64, 46, 28, 10]
+    );
+}
+
+#[test]
+fn symmetric_fan_across_six_fixtures() {
+    assert_eq!(
+        fan_values(6, 10, 100, FanMode::Symmetric),
+        [100, 64, 28, 28, 64, 100]
+    );
+    assert_eq!(
+        fan_values(5, 10, 100, FanMode::Symmetric),
+        [100, 55, 10, 55, 100]
+    );
+}
+
+#[test]
+fn fan_with_one_or_no_fixtures() {
+    assert_eq!(fan_values(1, 10, 100, FanMode::Symmetric), [10]);
+    assert!(fan_values(0, 10, 100, FanMode::Linear).is_empty());
+}
+
+#[tokio::test]
+async fn fan_follows_stage_position() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+
+    // Selection order is used until every fixture has been placed
+    let fan = || ConsoleCommand::FanProgrammerValues {
+        fixture_ids: vec![0, 1],
+        channel: "dimmer".to_string(),
+        from: 0,
+        to: 200,
+        mode: FanMode::Linear,
+    };
+    harness.command(fan()).await.unwrap();
+    assert_eq!(dimmers(&harness).await, [(0, 0), (1, 200)]);
+
+    {
+        let mut fixtures = harness.console.fixtures.write().await;
+        fixtures[0].position = Some(StagePosition { x: 0.9, y: 0.5 });
+        fixtures[1].position = Some(StagePosition { x: 0.1, y: 0.5 });
+    }
+    harness.command(fan()).await.unwrap();
+    assert_eq!(dimmers(&harness).await, [(0, 200), (1, 0)]);
+}
+
+async fn dimmers(harness: &Harness) -> Vec<(usize, u8)> {
+    let mut values: Vec<(usize, u8)> = harness
+        .console
+        .programmer
+        .read()
+        .await
+        .get_values()
+        .iter()
+        .map(|v| (v.fixture_id, v.value))
+        .collect();
+    values.sort_unstable();
+    values
+}
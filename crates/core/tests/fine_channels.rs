@@ -0,0 +1,105 @@
+use std::time::{Duration, Instant};
+
+use halo_core::{Cue, CueFade, StaticValue};
+use halo_fixtures::{
+    join_16, split_16, Channel, ChannelType, Fixture, FixtureLibrary, PanTiltLimits,
+};
+
+/// The spot, with fine channels under pan and tilt if `fine`
+fn spot(fine: bool) -> Fixture {
+    let profile = FixtureLibrary::new().profiles["shehds-led-spot-60w"].clone();
+    let mut channels = profile.channel_layout.clone();
+    if fine {
+        let channel = |name: &str, channel_type| Channel {
+            name: name.to_string(),
+            channel_type,
+            value: 0,
+        };
+        channels.insert(1, channel("Pan Fine", ChannelType::PanFine));
+        channels.insert(3, channel("Tilt Fine", ChannelType::TiltFine));
+    }
+    Fixture::new(0, "Spot", profile, channels, 1, 1)
+}
+
+#[test]
+fn sixteen_bit_values_split_into_high_and_low_bytes() {
+    for (value, bytes) in [
+        (0, (0, 0)),
+        (255, (0, 255)),
+        (256, (1, 0)),
+        (0x7FFF, (127, 255)),
+        (0x8000, (128, 0)),
+        (0xFF00, (255, 0)),
+        (u16::MAX, (255, 255)),
+    ] {
+        assert_eq!(split_16(value), bytes, "{value:#06x}");
+        assert_eq!(join_16(bytes.0, bytes.1), value);
+    }
+}
+
+#[test]
+fn fixtures_with_fine_channels_take_both_bytes() {
+    let mut fine = spot(true);
+    fine.set_channel_value_16(&ChannelType::Pan, 0x1234);
+    fine.set_channel_value_16(&ChannelType::Tilt, u16::MAX);
+    assert_eq!(fine.get_dmx_values()[..4], [0x12, 0x34, 255, 255]);
+    assert_eq!(fine.channel_value_16(&ChannelType::Pan), Some(0x1234));
+
+    // Without fine channels the high byte is all that goes out, and nothing else moves
+    let mut coarse = spot(false);
+    let before = coarse.get_dmx_values();
+    coarse.set_channel_value_16(&ChannelType::Pan, 0x1234);
+    assert_eq!(coarse.get_dmx_values()[0], 0x12);
+    assert_eq!(coarse.get_dmx_values()[1..], before[1..]);
+    assert_eq!(coarse.channel_value_16(&ChannelType::Pan), Some(0x1200));
+}
+
+#[test]
+fn limits_hold_the_coarse_byte() {
+    let mut fixture = spot(true);
+    fixture.set_pan_tilt_limits(PanTiltLimits {
+        pan_min: 10,
+        pan_max: 200,
+        tilt_min: 0,
+        tilt_max: 255,
+    });
+    fixture.set_channel_value_16(&ChannelType::Pan, join_16(250, 7));
+    assert_eq!(fixture.channel_value(&ChannelType::Pan), Some(200));
+    fixture.set_channel_value_16(&ChannelType::Pan, join_16(10, 7));
+    assert_eq!(
+        fixture.channel_value_16(&ChannelType::Pan),
+        Some(join_16(10, 7))
+    );
+}
+
+#[test]
+fn slow_moves_fade_through_the_fine_steps() {
+    let started = Instant::now();
+    let mut fade = CueFade::new();
+    fade.track(
+        (0, 1, started),
+        &Cue {
+            fade_time: Duration::from_secs(10),
+            ..Cue::default()
+        },
+    );
+    let target = StaticValue {
+        fixture_id: 0,
+        channel_type: ChannelType::Pan,
+        value: 1,
+    };
+    let fixture = spot(true);
+
+    // Halfway from 0 to one coarse step, which 8 bits can't show
+    let halfway = started + Duration::from_secs(5);
+    assert_eq!(fade.value_16(&fixture, &target, 0, halfway), 128);
+    assert_eq!(
+        fade.value_16(&fixture, &target, 0, started + Duration::from_secs(10)),
+        256
+    );
+    // The fine byte the cue sets is part of the target
+    assert_eq!(
+        fade.value_16(&fixture, &target, 64, started + Duration::from_secs(10)),
+        320
+    );
+}
@@ -0,0 +1,107 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{ConsoleCommand, FixtureStats, StaticValue};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+
+/// Step the clock a second at a time, as often as the counters sample
+async fn run_for(harness: &mut Harness, duration: Duration) {
+    for _ in 0..duration.as_secs() {
+        harness.clock.advance(Duration::from_secs(1));
+        harness.console.update().await.unwrap();
+    }
+}
+
+fn assert_minutes(actual: Duration, minutes: u64) {
+    let expected = Duration::from_secs(minutes * 60);
+    let off = actual.abs_diff(expected);
+    assert!(
+        off <= Duration::from_secs(60),
+        "{actual:?}, expected {minutes} minutes"
+    );
+}
+
+#[tokio::test]
+async fn an_hour_at_half_duty_accrues_half_an_hour_on() {
+    let dir = tempfile::tempdir().unwrap();
+    let path = dir.path().join("stats.json");
+    let mut harness = Harness::new().await;
+    harness.console.set_stats_file(Some(path.clone())).await;
+    harness.run_step("load two_pars.json").await.unwrap();
+
+    // Left Red lights the left PAR for a minute, then Blackout darkens it for a minute
+    for _ in 0..30 {
+        harness.run_step("goto 0 1").await.unwrap();
+        run_for(&mut harness, Duration::from_secs(60)).await;
+        harness.run_step("goto 0 3").await.unwrap();
+        run_for(&mut harness, Duration::from_secs(60)).await;
+    }
+
+    let stats = harness.console.fixture_stats().await;
+    let left = stats.get("Left PAR").unwrap();
+    assert_minutes(left.on_time(), 30);
+    assert_eq!(left.pan_travel, 0);
+    assert_minutes(stats.get("Right PAR").unwrap().on_time(), 0);
+
+    // All but the last minute has been saved
+    let saved = FixtureStats::load(&path).unwrap();
+    assert_minutes(saved.get("Left PAR").unwrap().on_time(), 30);
+}
+
+#[tokio::test]
+async fn travel_counts_pan_and_tilt_moves() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load spot.json").await.unwrap();
+    let value = |channel_type, value| StaticValue {
+        fixture_id: 0,
+        channel_type,
+        value,
+    };
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues[0]
+        .static_values
+        .extend([value(ChannelType::Pan, 0), value(ChannelType::Tilt, 0)]);
+    cue_lists[0].cues[1].positions.clear();
+    cue_lists[0].cues[1].static_values =
+        vec![value(ChannelType::Pan, 100), value(ChannelType::Tilt, 40)];
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+
+    harness.run_step("goto 0 0").await.unwrap();
+    run_for(&mut harness, Duration::from_secs(5)).await;
+    for _ in 0..10 {
+        harness.run_step("goto 0 1").await.unwrap();
+        run_for(&mut harness, Duration::from_secs(5)).await;
+        harness.run_step("goto 0 0").await.unwrap();
+        run_for(&mut harness, Duration::from_secs(5)).await;
+    }
+
+    let stats = harness.console.fixture_stats().await;
+    let spot = stats.get("Spot").unwrap();
+    assert_eq!(spot.pan_travel, 2000);
+    assert_eq!(spot.tilt_travel, 800);
+}
+
+#[tokio::test]
+async fn resetting_a_fixture_survives_the_console_saving() {
+    let dir = tempfile::tempdir().unwrap();
+    let path = dir.path().join("stats.json");
+    let mut harness = Harness::new().await;
+    harness.console.set_stats_file(Some(path.clone())).await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    run_for(&mut harness, Duration::from_secs(120)).await;
+
+    // Serviced while the console runs, as `halo stats fixtures --reset` would
+    let mut stats = FixtureStats::load(&path).unwrap();
+    assert!(stats.reset("Left PAR"));
+    stats.save(&path).unwrap();
+    run_for(&mut harness, Duration::from_secs(60)).await;
+
+    let saved = FixtureStats::load(&path).unwrap();
+    assert_minutes(saved.get("Left PAR").unwrap().on_time(), 1);
+}
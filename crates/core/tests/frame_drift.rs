@@ -0,0 +1,101 @@
+mod harness;
+
+use std::sync::atomic::{AtomicBool, Ordering};
+use std::sync::Arc;
+use std::time::Duration;
+
+use halo_core::{
+    ConsoleCommand, Effect, EffectContext, EffectDistribution, EffectMapping, EffectRegistry,
+    EffectRelease, EffectSource, FrameTimer, ManualClock, RENDER_TICK,
+};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+
+fn ms(ms: u64) -> Duration {
+    Duration::from_millis(ms)
+}
+
+#[test]
+fn each_cue_keeps_its_worst_frame() {
+    let mut frames = FrameTimer::new();
+    frames.record(Some((0, 1)), ms(5), 2);
+    frames.record(Some((0, 1)), ms(40), 6);
+    frames.record(Some((0, 1)), ms(30), 4);
+    assert_eq!(frames.worst().map(|f| (f.frame, f.values)), Some((2, 6)));
+    assert_eq!(frames.worst().unwrap().drift(), ms(40) - RENDER_TICK);
+    assert!(frames.take_completed().is_empty());
+
+    // A cue whose frames all fit the tick has no worst frame
+    frames.record(Some((0, 2)), ms(10), 1);
+    frames.record(None, ms(1), 0);
+    let completed = frames.take_completed();
+    assert_eq!(completed.len(), 2);
+    assert_eq!((completed[0].0, completed[0].1), (0, 1));
+    assert_eq!(completed[0].2.map(|f| f.took), Some(ms(40)));
+    assert_eq!(completed[1], (0, 2, None));
+}
+
+/// Holds up the frame it first renders in by moving the clock on
+struct Stall {
+    clock: ManualClock,
+    stalled: Arc<AtomicBool>,
+}
+
+impl EffectSource for Stall {
+    fn values(&mut self, _context: &EffectContext, fixture_ids: &[usize]) -> Vec<f64> {
+        if !self.stalled.swap(true, Ordering::SeqCst) {
+            self.clock.advance(ms(100));
+        }
+        vec![1.0; fixture_ids.len()]
+    }
+}
+
+#[tokio::test]
+async fn a_slow_frame_is_reported_when_its_cue_completes() {
+    let mut harness = Harness::new().await;
+    let clock = harness.clock.clone();
+    let stalled = Arc::new(AtomicBool::new(false));
+    let mut registry = EffectRegistry::new();
+    registry.register("Stall", move |_: &Effect| -> Box<dyn EffectSource> {
+        Box::new(Stall {
+            clock: clock.clone(),
+            stalled: Arc::clone(&stalled),
+        })
+    });
+    harness.console.set_effect_registry(registry).await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues[1].effects.push(EffectMapping {
+        name: "Stall".to_string(),
+        effect: Effect {
+            source: Some("stall".to_string()),
+            ..Effect::default()
+        },
+        fixture_ids: vec![0],
+        channel_types: vec![ChannelType::Dimmer],
+        distribution: EffectDistribution::All,
+        release: EffectRelease::Hold,
+    });
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(ms(100)).await.unwrap();
+    let worst = harness.console.worst_frame().expect("a frame ran long");
+    assert_eq!(worst.took, ms(100));
+    assert_eq!(worst.drift(), ms(100) - RENDER_TICK);
+    // Left Red's two values and the stalling effect
+    assert_eq!(worst.values, 3);
+
+    harness.run_step("go").await.unwrap();
+    harness.advance(ms(25)).await.unwrap();
+    let completed = harness.console.take_completed_cues();
+    let left_red = completed
+        .iter()
+        .find(|(list, cue, _)| (*list, *cue) == (0, 1))
+        .expect("Left Red completed");
+    assert_eq!(left_red.2, Some(worst));
+    assert_eq!(harness.console.worst_frame(), None);
+}
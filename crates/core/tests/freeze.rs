@@ -0,0 +1,113 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{
+    ConsoleCommand, Cue, CueList, Effect, EffectDistribution, EffectMapping, EffectParams,
+    EffectRelease, Interval, StaticValue,
+};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+
+/// A look that puts the right PAR at full and freezes the effects underneath
+fn blinder(name: &str) -> Cue {
+    Cue {
+        name: name.to_string(),
+        static_values: vec![StaticValue {
+            fixture_id: 1,
+            channel_type: ChannelType::Dimmer,
+            value: 255,
+        }],
+        freeze_others: true,
+        ..Cue::default()
+    }
+}
+
+/// The left PAR's dimmer running a sine over each bar, with two blinders to flash over it
+async fn running_sine() -> Harness {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    let wave = Cue {
+        name: "Wave".to_string(),
+        effects: vec![EffectMapping {
+            name: "Sine".to_string(),
+            effect: Effect {
+                // A bar is four beats, so the freezes below are shorter than one cycle
+                params: EffectParams {
+                    interval: Interval::Bar,
+                    ..EffectParams::default()
+                },
+                ..Effect::default()
+            },
+            fixture_ids: vec![0],
+            channel_types: vec![ChannelType::Dimmer],
+            distribution: EffectDistribution::All,
+            release: EffectRelease::Hold,
+        }],
+        ..Cue::default()
+    };
+    let cue_list = CueList {
+        name: "Main".to_string(),
+        cues: vec![wave, blinder("Blinder"), blinder("Side Blinder")],
+        audio_file: None,
+        default_fade: None,
+        default_values: vec![],
+        move_in_black: None,
+    };
+    harness
+        .command(ConsoleCommand::SetCueLists {
+            cue_lists: vec![cue_list],
+        })
+        .await
+        .unwrap();
+    harness.run_step("goto 0 0").await.unwrap();
+    harness.advance(Duration::from_millis(300)).await.unwrap();
+    harness
+}
+
+async fn sine(harness: &Harness) -> u8 {
+    harness.console.fixtures.read().await[0]
+        .channel_value(&ChannelType::Dimmer)
+        .unwrap()
+}
+
+#[tokio::test]
+async fn a_freezing_flash_holds_effects_and_resumes_from_the_same_phase() {
+    let mut harness = running_sine().await;
+
+    harness.run_step("flash Blinder").await.unwrap();
+    harness.advance(Duration::from_millis(25)).await.unwrap();
+    let frozen = sine(&harness).await;
+    harness.run_step("expect dmx 1 10 255").await.unwrap();
+
+    // The effect keeps rendering, but stays where it was
+    harness.advance(Duration::from_millis(500)).await.unwrap();
+    assert_eq!(sine(&harness).await, frozen);
+
+    // Released, it picks up from the phase it froze at rather than jumping ahead
+    harness.run_step("release Blinder").await.unwrap();
+    harness.advance(Duration::from_millis(25)).await.unwrap();
+    assert_eq!(sine(&harness).await, frozen);
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    assert_ne!(sine(&harness).await, frozen);
+}
+
+#[tokio::test]
+async fn nested_freezes_hold_until_the_last_is_released() {
+    let mut harness = running_sine().await;
+
+    harness.run_step("flash Blinder").await.unwrap();
+    harness.run_step("flash Side Blinder").await.unwrap();
+    harness.advance(Duration::from_millis(25)).await.unwrap();
+    let frozen = sine(&harness).await;
+
+    harness.run_step("release Blinder").await.unwrap();
+    harness.advance(Duration::from_millis(200)).await.unwrap();
+    assert_eq!(sine(&harness).await, frozen);
+
+    harness.run_step("release Side Blinder").await.unwrap();
+    harness.advance(Duration::from_millis(25)).await.unwrap();
+    assert_eq!(sine(&harness).await, frozen);
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    assert_ne!(sine(&harness).await, frozen);
+}
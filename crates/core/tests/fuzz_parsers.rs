@@ -0,0 +1,405 @@
+//! Mutation fuzzing for everything that parses untrusted input: show files, config files,
+//! output captures, timecode strings, CSV cue sheets and raw MIDI bytes.
+//!
+//! Each target takes a seed corpus (the checked-in shows, `tests/testdata` and the hand-mangled
+//! files in `tests/testdata/fuzz`), mutates it with a deterministic PRNG and asserts that errors
+//! are returned rather than panics. Set `HALO_FUZZ_ITERATIONS` to run longer locally. When the
+//! console target panics the offending show is written to the temp directory; add it to
+//! `tests/testdata/fuzz` once fixed so it stays covered.
+
+mod harness;
+
+use std::path::{Path, PathBuf};
+use std::time::Duration;
+
+use halo_core::{
+    merge_cue_sheet, parse_cue_sheet, Capture, ConfigManager, ConsoleCommand, CueList, Meter,
+    MidiMessage, Show, TimeCode,
+};
+use harness::Harness;
+use serde_json::Value;
+
+const DEFAULT_ITERATIONS: usize = 200;
+
+const TOKENS: &[&str] = &[
+    "0",
+    "-1",
+    "1",
+    "255",
+    "256",
+    "511",
+    "512",
+    "513",
+    "65535",
+    "4294967296",
+    "1e308",
+    "-0.0",
+    "null",
+    "true",
+    "\"\"",
+    "[]",
+    "{}",
+    "\"Step\"",
+    "{\"Step\":0}",
+    "{\"Wave\":1e300}",
+];
+
+const NUMBERS: &[f64] = &[
+    0.0, 1.0, -1.0, 2.0, 3.0, 255.0, 256.0, 509.0, 510.0, 511.0, 512.0, 513.0, 65535.0, 1e9,
+];
+
+/// Small xorshift PRNG so failures reproduce without external crates
+struct Rng(u64);
+
+impl Rng {
+    fn next(&mut self) -> u64 {
+        self.0 ^= self.0 << 13;
+        self.0 ^= self.0 >> 7;
+        self.0 ^= self.0 << 17;
+        self.0
+    }
+
+    fn below(&mut self, n: usize) -> usize {
+        if n == 0 {
+            0
+        } else {
+            (self.next() % n as u64) as usize
+        }
+    }
+}
+
+fn iterations() -> usize {
+    std::env::var("HALO_FUZZ_ITERATIONS")
+        .ok()
+        .and_then(|v| v.parse().ok())
+        .unwrap_or(DEFAULT_ITERATIONS)
+}
+
+fn manifest_dir() -> PathBuf {
+    PathBuf::from(env!("CARGO_MANIFEST_DIR"))
+}
+
+fn json_files(dir: &Path) -> Vec<PathBuf> {
+    let mut files: Vec<PathBuf> = std::fs::read_dir(dir)
+        .map(|entries| {
+            entries
+                .filter_map(|entry| entry.ok().map(|e| e.path()))
+                .filter(|path| path.extension().is_some_and(|ext| ext == "json"))
+                .collect()
+        })
+        .unwrap_or_default();
+    files.sort();
+    files
+}
+
+/// Seed corpus for show files
+fn show_seeds() -> Vec<(PathBuf, Vec<u8>)> {
+    let root = manifest_dir();
+    let mut paths = json_files(&root.join("../../shows"));
+    paths.extend(json_files(&root.join("tests/testdata")));
+    paths.extend(json_files(&root.join("tests/testdata/fuzz")));
+    assert!(!paths.is_empty(), "no show seeds found");
+
+    paths
+        .into_iter()
+        .map(|path| {
+            let bytes = std::fs::read(&path).expect("readable seed");
+            (path, bytes)
+        })
+        .collect()
+}
+
+/// Apply one random byte-level mutation
+fn mutate_bytes(rng: &mut Rng, input: &[u8]) -> Vec<u8> {
+    let mut data = input.to_vec();
+    if data.is_empty() {
+        return TOKENS[rng.below(TOKENS.len())].as_bytes().to_vec();
+    }
+
+    match rng.below(5) {
+        0 => {
+            let i = rng.below(data.len());
+            data[i] ^= 1 << rng.below(8);
+        }
+        1 => {
+            let start = rng.below(data.len());
+            let end = (start + rng.below(32)).min(data.len());
+            data.drain(start..end);
+        }
+        2 => {
+            let start = rng.below(data.len());
+            let end = (start + rng.below(64)).min(data.len());
+            let chunk = data[start..end].to_vec();
+            let at = rng.below(data.len());
+            data.splice(at..at, chunk);
+        }
+        3 => {
+            let at = rng.below(data.len());
+            let token = TOKENS[rng.below(TOKENS.len())].as_bytes();
+            data.splice(at..at, token.iter().copied());
+        }
+        _ => data.truncate(rng.below(data.len())),
+    }
+    data
+}
+
+/// Replace a random number in a JSON document with an edge-case value
+fn mutate_numbers(rng: &mut Rng, value: &mut Value) {
+    fn number_paths(value: &Value, path: String, out: &mut Vec<String>) {
+        match value {
+            Value::Number(_) => out.push(path),
+            Value::Array(items) => {
+                for (i, v) in items.iter().enumerate() {
+                    number_paths(v, format!("{path}/{i}"), out);
+                }
+            }
+            Value::Object(map) => {
+                for (k, v) in map {
+                    number_paths(v, format!("{path}/{k}"), out);
+                }
+            }
+            _ => {}
+        }
+    }
+
+    let mut paths = Vec::new();
+    number_paths(value, String::new(), &mut paths);
+    if paths.is_empty() {
+        return;
+    }
+    let path = &paths[rng.below(paths.len())];
+    if let Some(target) = value.pointer_mut(path) {
+        let n = NUMBERS[rng.below(NUMBERS.len())];
+        *target = if n.fract() == 0.0 && n >= 0.0 {
+            Value::from(n as u64)
+        } else if n.fract() == 0.0 {
+            Value::from(n as i64)
+        } else {
+            Value::from(n)
+        };
+    }
+}
+
+#[test]
+fn show_files_round_trip() {
+    for (path, bytes) in show_seeds() {
+        let Ok(show) = serde_json::from_slice::<Show>(&bytes) else {
+            continue;
+        };
+        let first = serde_json::to_value(&show).expect("serializable show");
+        let reparsed: Show = serde_json::from_value(first.clone())
+            .unwrap_or_else(|e| panic!("{}: reparse failed: {e}", path.display()));
+        let second = serde_json::to_value(&reparsed).expect("serializable show");
+        assert_eq!(
+            first,
+            second,
+            "{}: round trip changed the show",
+            path.display()
+        );
+    }
+}
+
+#[test]
+fn checked_in_shows_parse() {
+    for path in json_files(&manifest_dir().join("../../shows")) {
+        let bytes = std::fs::read(&path).unwrap();
+        if let Err(e) = serde_json::from_slice::<Show>(&bytes) {
+            panic!("{}: {e}", path.display());
+        }
+    }
+}
+
+#[test]
+fn show_parser_does_not_panic() {
+    let mut rng = Rng(0x9E37_79B9_7F4A_7C15);
+    for (_, seed) in show_seeds() {
+        let mut input = seed.clone();
+        for _ in 0..iterations() {
+            input = mutate_bytes(&mut rng, &input);
+            let _ = serde_json::from_slice::<Show>(&input);
+            if rng.below(8) == 0 {
+                input = seed.clone();
+            }
+        }
+    }
+}
+
+#[tokio::test]
+async fn console_survives_mutated_shows() {
+    let mut rng = Rng(0xD1B5_4A32_D192_ED03);
+    let dir = tempfile::tempdir().unwrap();
+    let mut candidates = Vec::new();
+
+    for (_, seed) in show_seeds() {
+        if let Ok(value) = serde_json::from_slice::<Value>(&seed) {
+            // Whole seeds cover the hand-mangled regression files
+            candidates.push(value.clone());
+            for _ in 0..iterations() / 20 {
+                let mut mutated = value.clone();
+                for _ in 0..=rng.below(3) {
+                    mutate_numbers(&mut rng, &mut mutated);
+                }
+                candidates.push(mutated);
+            }
+        }
+    }
+
+    for (i, candidate) in candidates.into_iter().enumerate() {
+        if serde_json::from_value::<Show>(candidate.clone()).is_err() {
+            continue;
+        }
+        let path = dir.path().join(format!("candidate-{i}.json"));
+        std::fs::write(&path, serde_json::to_vec_pretty(&candidate).unwrap()).unwrap();
+
+        let show_path = path.clone();
+        let result = tokio::spawn(async move { exercise_show(show_path).await }).await;
+        if result.is_err() {
+            let saved = std::env::temp_dir().join(format!("halo-fuzz-crash-{i}.json"));
+            std::fs::copy(&path, &saved).unwrap();
+            panic!(
+                "console panicked on show, input saved to {}",
+                saved.display()
+            );
+        }
+    }
+}
+
+/// Load a show and step through its first few cues, ignoring returned errors
+async fn exercise_show(path: PathBuf) {
+    let mut harness = Harness::new().await;
+    if harness
+        .command(ConsoleCommand::LoadShow { path })
+        .await
+        .is_err()
+    {
+        return;
+    }
+
+    let _ = harness.advance(Duration::from_millis(50)).await;
+    let cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    for (list_index, cue_list) in cue_lists.iter().enumerate().take(2) {
+        for cue_index in 0..cue_list.cues.len().min(4) {
+            let _ = harness
+                .command(ConsoleCommand::GoToCue {
+                    list_index,
+                    cue_index,
+                })
+                .await;
+            let _ = harness.advance(Duration::from_millis(100)).await;
+        }
+    }
+}
+
+#[test]
+fn config_loader_does_not_panic() {
+    let mut rng = Rng(0xA076_1D64_78BD_642F);
+    let dir = tempfile::tempdir().unwrap();
+    let path = dir.path().join("config.json");
+    let root = manifest_dir().join("../..");
+
+    for seed in ["config.json", "config.template.json"] {
+        let Ok(seed) = std::fs::read(root.join(seed)) else {
+            continue;
+        };
+        let mut input = seed.clone();
+        for _ in 0..iterations() {
+            input = mutate_bytes(&mut rng, &input);
+            std::fs::write(&path, &input).unwrap();
+            let mut manager = ConfigManager::new(Some(path.clone()));
+            if let Ok(settings) = manager.load() {
+                let _ = ConfigManager::validate_settings(&settings);
+            }
+            if rng.below(8) == 0 {
+                input = seed.clone();
+            }
+        }
+    }
+}
+
+#[test]
+fn capture_reader_does_not_panic() {
+    let mut rng = Rng(0x94D0_49BB_1331_11EB);
+    let dir = tempfile::tempdir().unwrap();
+    let path = dir.path().join("output.dmxcap");
+    let header = r#"{"format":"halo-dmxcap","version":1,"started_at":"2026-01-01T00:00:00Z","rate_hz":44.0,"universes":[1]}"#;
+    let frame =
+        |data: &str| format!("{header}\n{{\"micros\":0,\"universe\":1,\"data\":\"{data}\"}}\n");
+    // The second with a multi-byte character where a hex digit should be
+    let seeds = [(frame("00ff10"), true), (frame("0é0"), false)];
+    for (seed, valid) in seeds {
+        let mut input = seed.as_bytes().to_vec();
+        std::fs::write(&path, &input).unwrap();
+        assert_eq!(Capture::read(&path).is_ok(), valid, "{seed}");
+        for _ in 0..iterations() {
+            input = mutate_bytes(&mut rng, &input);
+            std::fs::write(&path, &input).unwrap();
+            let _ = Capture::read(&path);
+            if rng.below(8) == 0 {
+                input = seed.as_bytes().to_vec();
+            }
+        }
+    }
+}
+
+#[test]
+fn timecode_parser_does_not_panic() {
+    let mut rng = Rng(0xE703_7ED1_A0B4_28DB);
+    let seeds = [
+        "00:00:00:00",
+        "01:02:03:04",
+        "23:59:59:29",
+        "99:99:99:99",
+        "::::",
+        "",
+    ];
+    for seed in seeds {
+        let mut input = seed.as_bytes().to_vec();
+        for _ in 0..iterations() {
+            input = mutate_bytes(&mut rng, &input);
+            let text = String::from_utf8_lossy(&input);
+            let mut timecode = TimeCode::default();
+            if timecode.from_string(&text).is_ok() {
+                let _ = timecode.to_seconds();
+                let _ = timecode.to_string();
+            }
+        }
+    }
+}
+
+#[test]
+fn cue_sheet_parser_does_not_panic() {
+    let mut rng = Rng(0x2545_F491_4F6C_DD1D);
+    let seeds = [
+        "Cue,Label,Time,Look\n1,Intro,0:05,\"Warm, low\"\n2,Verse,1:20.5,\n",
+        "#,Name,Bar\n1,Drop,17.3\n,Outro,99999999.1\n",
+        "cue\n\"\n",
+    ];
+    for seed in seeds {
+        let mut input = seed.as_bytes().to_vec();
+        for _ in 0..iterations() {
+            input = mutate_bytes(&mut rng, &input);
+            let text = String::from_utf8_lossy(&input);
+            if let Ok(rows) = parse_cue_sheet(&text) {
+                let mut list = CueList {
+                    name: "Fuzz".to_string(),
+                    cues: Vec::new(),
+                    audio_file: None,
+                    default_fade: None,
+                    default_values: Vec::new(),
+                    move_in_black: None,
+                };
+                let _ = merge_cue_sheet(&mut list, &rows, &Meter::default()).to_string();
+            }
+        }
+    }
+}
+
+#[test]
+fn midi_parser_does_not_panic() {
+    let mut rng = Rng(0x8EBC_6AF0_9C88_C6E3);
+    for _ in 0..iterations() * 10 {
+        let len = rng.below(6);
+        let bytes: Vec<u8> = (0..len).map(|_| rng.next() as u8).collect();
+        let _ = MidiMessage::from_bytes(&bytes);
+    }
+}
@@ -0,0 +1,85 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::ConsoleCommand;
+use halo_fixtures::{ChannelType, Fixture, FixtureLibrary};
+use harness::Harness;
+
+fn fixture(profile_id: &str) -> Fixture {
+    let profile = FixtureLibrary::new().profiles[profile_id].clone();
+    let channels = profile.channel_layout.clone();
+    Fixture::new(0, "Test", profile, channels, 1, 1)
+}
+
+#[test]
+fn the_spot_picks_gobos_off_its_wheel() {
+    let mut spot = fixture("shehds-led-spot-60w");
+    assert_eq!(spot.gobo_values(0, None), [(ChannelType::Gobo, 0)]);
+    assert_eq!(spot.gobo_values(3, None), [(ChannelType::Gobo, 24)]);
+    assert!(spot.gobo_values(20, None).is_empty());
+
+    // Shaking runs slow to fast across the gobo's band
+    assert_eq!(spot.gobo_values(3, Some(0.0)), [(ChannelType::Gobo, 80)]);
+    assert_eq!(spot.gobo_values(3, Some(1.0)), [(ChannelType::Gobo, 87)]);
+    assert_eq!(spot.gobo_values(3, Some(5.0)), [(ChannelType::Gobo, 87)]);
+    // Open has nothing to shake
+    assert_eq!(spot.gobo_values(0, Some(0.5)), [(ChannelType::Gobo, 0)]);
+
+    for (gobo, shake) in [(0, None), (3, None), (3, Some(0.5)), (7, Some(1.0))] {
+        for (channel_type, value) in spot.gobo_values(gobo, shake) {
+            spot.set_channel_value(&channel_type, value);
+        }
+        assert_eq!(spot.gobo(), Some((gobo, shake.is_some() && gobo > 0)));
+    }
+}
+
+#[test]
+fn gobos_go_straight_to_the_channel_without_a_wheel() {
+    let mut spot = fixture("shehds-led-spot-60w");
+    spot.profile.gobo_wheel.clear();
+    assert_eq!(spot.gobo_values(42, Some(1.0)), [(ChannelType::Gobo, 42)]);
+    assert!(spot.gobo_values(300, None).is_empty());
+    assert_eq!(spot.gobo(), None);
+
+    // Fixtures without a gobo channel are left alone
+    let par = fixture("shehds-rgbw-par");
+    assert!(par.gobo_values(1, None).is_empty());
+    assert_eq!(par.gobo(), None);
+}
+
+#[tokio::test]
+async fn the_programmer_sets_gobos_by_number() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load spot.json").await.unwrap();
+    harness
+        .command(ConsoleCommand::SetProgrammerPreviewMode { preview_mode: true })
+        .await
+        .unwrap();
+
+    harness
+        .command(ConsoleCommand::SetProgrammerGobo {
+            fixture_ids: vec![0],
+            gobo: 2,
+            shake: None,
+        })
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+    harness.run_step("expect channel 0 gobo 16").await.unwrap();
+
+    harness
+        .command(ConsoleCommand::SetProgrammerGobo {
+            fixture_ids: vec![0],
+            gobo: 2,
+            shake: Some(1.0),
+        })
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+    harness.run_step("expect channel 0 gobo 79").await.unwrap();
+    assert_eq!(
+        harness.console.fixtures.read().await[0].gobo(),
+        Some((2, true))
+    );
+}
@@ -0,0 +1,279 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{is_intensity, ConsoleCommand, OverrideFadePolicy, Settings, Trigger};
+use halo_fixtures::{ChannelType, Fixture, FixtureLibrary};
+use harness::Harness;
+
+/// Left Red running in two_pars.json, with the first PAR at full
+async fn left_red() -> Harness {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+    harness
+}
+
+fn assert_level(actual: f32, expected: f32) {
+    assert!(
+        (actual - expected).abs() < 0.02,
+        "level {actual}, expected {expected}"
+    );
+}
+
+#[tokio::test]
+async fn fade_to_black_ramps_and_fade_up_reveals_current_playback() {
+    let mut harness = left_red().await;
+
+    harness
+        .command(ConsoleCommand::FadeToBlack { duration_secs: 2.0 })
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(500)).await.unwrap();
+    assert_level(harness.console.grand_master_level().await, 0.75);
+    harness.advance(Duration::from_millis(500)).await.unwrap();
+    assert_level(harness.console.grand_master_level().await, 0.5);
+    harness.run_step("expect dmx 1 1 128").await.unwrap();
+
+    harness.advance(Duration::from_secs(1)).await.unwrap();
+    assert_level(harness.console.grand_master_level().await, 0.0);
+    harness.run_step("expect dmx 1 1 0").await.unwrap();
+
+    // Playback moves on in the dark
+    harness.run_step("goto 0 2").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect dmx 1 10 0").await.unwrap();
+
+    harness
+        .command(ConsoleCommand::FadeUp { duration_secs: 1.0 })
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(1000)).await.unwrap();
+    assert_level(harness.console.grand_master_level().await, 1.0);
+    harness.run_step("expect dmx 1 10 128").await.unwrap();
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+}
+
+#[tokio::test]
+async fn opposite_command_reverses_from_mid_ramp() {
+    let mut harness = left_red().await;
+
+    harness
+        .command(ConsoleCommand::FadeToBlack { duration_secs: 2.0 })
+        .await
+        .unwrap();
+    harness.advance(Duration::from_secs(1)).await.unwrap();
+    harness
+        .command(ConsoleCommand::FadeUp { duration_secs: 1.0 })
+        .await
+        .unwrap();
+
+    // Ramps back up from half rather than jumping
+    assert_level(harness.console.grand_master_level().await, 0.5);
+    harness.advance(Duration::from_millis(500)).await.unwrap();
+    assert_level(harness.console.grand_master_level().await, 0.75);
+    harness.advance(Duration::from_millis(500)).await.unwrap();
+    assert_level(harness.console.grand_master_level().await, 1.0);
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+}
+
+#[tokio::test]
+async fn setting_the_grand_master_scales_at_once() {
+    let mut harness = left_red().await;
+
+    harness
+        .command(ConsoleCommand::SetGrandMaster { level: 0.5 })
+        .await
+        .unwrap();
+    assert_level(harness.console.grand_master_level().await, 0.5);
+    harness.advance(Duration::from_millis(25)).await.unwrap();
+    harness.run_step("expect dmx 1 1 128").await.unwrap();
+
+    harness
+        .command(ConsoleCommand::SetGrandMaster { level: 2.0 })
+        .await
+        .unwrap();
+    assert_level(harness.console.grand_master_level().await, 1.0);
+    harness.advance(Duration::from_millis(25)).await.unwrap();
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+}
+
+#[tokio::test]
+async fn blackout_is_instant_and_release_brings_back_current_playback() {
+    let mut harness = left_red().await;
+
+    harness
+        .command(ConsoleCommand::SetBlackout { active: true })
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(25)).await.unwrap();
+    assert!(harness.console.is_blacked_out().await);
+    harness.run_step("expect dmx 1 1 0").await.unwrap();
+
+    // The grand master level is left alone, and playback moves on in the dark
+    assert_level(harness.console.grand_master_level().await, 1.0);
+    harness.run_step("goto 0 2").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect dmx 1 10 0").await.unwrap();
+
+    harness
+        .command(ConsoleCommand::SetBlackout { active: false })
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(25)).await.unwrap();
+    harness.run_step("expect dmx 1 10 128").await.unwrap();
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+}
+
+fn fixture(profile_id: &str) -> Fixture {
+    let profile = FixtureLibrary::new().profiles[profile_id].clone();
+    let channels = profile.channel_layout.clone();
+    Fixture::new(0, "Test", profile, channels, 1, 1)
+}
+
+#[test]
+fn intensity_is_the_dimmer_or_the_colors_without_one() {
+    let spot = fixture("shehds-led-spot-60w");
+    assert!(is_intensity(&spot, &ChannelType::Dimmer));
+    assert!(!is_intensity(&spot, &ChannelType::Pan));
+    assert!(!is_intensity(&spot, &ChannelType::Color));
+
+    let par = fixture("hyulights-led-rgbw-par");
+    assert!(is_intensity(&par, &ChannelType::Dimmer));
+    assert!(!is_intensity(&par, &ChannelType::Red));
+
+    let bar = fixture("generic-rgb-pixel-bar-30");
+    assert!(is_intensity(&bar, &ChannelType::PixelRed(0)));
+    assert!(is_intensity(&bar, &ChannelType::PixelBlue(29)));
+}
+
+#[tokio::test]
+async fn osc_triggers_fade_the_grand_master() {
+    let triggers: Vec<Trigger> = serde_json::from_str(
+        r#"[
+            {"type": "osc", "address": "/master/black", "action": "fadetoblack", "time": 1},
+            {"type": "osc", "address": "/master/up", "action": "fadeup"}
+        ]"#,
+    )
+    .unwrap();
+    let mut harness = Harness::new().await;
+    harness
+        .command(ConsoleCommand::UpdateSettings {
+            settings: Settings {
+                triggers,
+                ..Settings::default()
+            },
+        })
+        .await
+        .unwrap();
+    harness.run_step("load two_pars.json").await.unwrap();
+
+    let osc = |address: &str| ConsoleCommand::ProcessOscMessage {
+        address: address.to_string(),
+        args: vec![],
+    };
+    harness.command(osc("/master/black")).await.unwrap();
+    harness.advance(Duration::from_secs(1)).await.unwrap();
+    assert_level(harness.console.grand_master_level().await, 0.0);
+
+    // Fade up takes the default three seconds
+    harness.command(osc("/master/up")).await.unwrap();
+    harness.advance(Duration::from_millis(1500)).await.unwrap();
+    assert_level(harness.console.grand_master_level().await, 0.5);
+}
+
+/// Left Red fading the first PAR up over four seconds, just started
+async fn fading_left_red(policy: OverrideFadePolicy) -> Harness {
+    let mut harness = Harness::new().await;
+    harness
+        .command(ConsoleCommand::UpdateSettings {
+            settings: Settings {
+                override_fade_policy: policy,
+                ..Settings::default()
+            },
+        })
+        .await
+        .unwrap();
+    harness.run_step("load two_pars.json").await.unwrap();
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues[1].fade_time = Duration::from_secs(4);
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+
+    harness.run_step("goto 0 0").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    harness
+}
+
+async fn dimmer(harness: &Harness) -> u8 {
+    harness.console.fixtures.read().await[0]
+        .channel_value(&ChannelType::Dimmer)
+        .unwrap()
+}
+
+fn assert_near(actual: u8, expected: u8) {
+    assert!(
+        actual.abs_diff(expected) <= 3,
+        "dimmer {actual}, expected {expected}"
+    );
+}
+
+/// Black out for two seconds a quarter of the way through the fade, returning the dimmer
+/// just after the blackout is released
+async fn blackout_mid_fade(policy: OverrideFadePolicy) -> u8 {
+    let mut harness = fading_left_red(policy).await;
+    harness.advance(Duration::from_secs(1)).await.unwrap();
+    assert_near(dimmer(&harness).await, 64);
+
+    harness
+        .command(ConsoleCommand::FadeToBlack { duration_secs: 0.0 })
+        .await
+        .unwrap();
+    harness.advance(Duration::from_secs(2)).await.unwrap();
+    harness.run_step("expect dmx 1 1 0").await.unwrap();
+
+    harness
+        .command(ConsoleCommand::FadeUp { duration_secs: 0.0 })
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(25)).await.unwrap();
+    dimmer(&harness).await
+}
+
+#[tokio::test]
+async fn a_blacked_out_fade_continues_by_default() {
+    assert_near(blackout_mid_fade(OverrideFadePolicy::Continue).await, 191);
+}
+
+#[tokio::test]
+async fn a_frozen_fade_resumes_where_the_blackout_caught_it() {
+    assert_near(blackout_mid_fade(OverrideFadePolicy::Freeze).await, 64);
+}
+
+#[tokio::test]
+async fn a_completed_fade_is_at_its_target_after_the_blackout() {
+    assert_eq!(blackout_mid_fade(OverrideFadePolicy::Complete).await, 255);
+}
+
+#[tokio::test]
+async fn full_on_freezes_a_fade_too() {
+    let mut harness = fading_left_red(OverrideFadePolicy::Freeze).await;
+    harness.advance(Duration::from_secs(2)).await.unwrap();
+    harness.command(ConsoleCommand::FullOn).await.unwrap();
+    harness.advance(Duration::from_secs(5)).await.unwrap();
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+
+    harness
+        .command(ConsoleCommand::ReleaseFullOn)
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(25)).await.unwrap();
+    assert_near(dimmer(&harness).await, 128);
+    harness.advance(Duration::from_secs(2)).await.unwrap();
+    assert_eq!(dimmer(&harness).await, 255);
+}
@@ -0,0 +1,355 @@
+//! Scripted end-to-end harness for the lighting console.
+//!
+//! The harness wires a real `LightingConsole` to a manual clock and a recording DMX module, then
+//! drives it from a plain text script. Bug reproductions can be captured as `.script` files in
+//! `tests/testdata` and are picked up automatically by the integration tests.
+//!
+//! One step per line, `#` starts a comment:
+//!
+//! ```text
+//! load <show.json>                        load a show, relative to the script
+//! advance <duration>                      tick the console while advancing the clock (5s, 250ms, 2bar)
+//! go | stop | hold | resume               playback commands
+//! goto <cue list> <cue>                   jump straight to a cue
+//! tap                                     tap tempo
+//! flash <cue name>                        hold a cue as a flash
+//! release <cue name>                      release a held flash
+//! command <fixture id> <name>            run a fixture control command, e.g. reset
+//! solo <fixture id>... | unsolo           keep only these fixtures lit, or end the solo
+//! fullon | release fullon                 emergency full on, and release it
+//! midi <status> <data1> <data2>           raw MIDI input, decimal or 0x-prefixed hex
+//! expect cue <index>
+//! expect state <stopped|playing|holding>
+//! expect bpm <value>
+//! expect channel <fixture id> <channel> <value>
+//! expect dmx <universe> <address> <value>
+//! ```
+
+#![allow(dead_code)]
+
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+use std::sync::{Arc, Mutex};
+use std::time::Duration;
+
+use async_trait::async_trait;
+use halo_core::{
+    AsyncModule, ConsoleCommand, ConsoleEvent, LightingConsole, ManualClock, ModuleEvent, ModuleId,
+    ModuleMessage, MusicalDuration, PlaybackState, Settings,
+};
+use tokio::sync::mpsc;
+
+/// Console tick used while advancing time, close to the real 23ms update interval
+const TICK: Duration = Duration::from_millis(25);
+
+/// DMX frames captured by the recording module
+#[derive(Default)]
+pub struct DmxRecording {
+    /// Most recent frame for each universe
+    pub universes: HashMap<u8, Vec<u8>>,
+    /// Total number of frames received
+    pub frame_count: usize,
+    /// Every frame received, in order
+    pub frames: Vec<(u8, Vec<u8>)>,
+}
+
+/// Stands in for the DMX module and records every frame the console sends
+pub struct RecordingDmxModule {
+    recording: Arc<Mutex<DmxRecording>>,
+}
+
+#[async_trait]
+impl AsyncModule for RecordingDmxModule {
+    fn id(&self) -> ModuleId {
+        ModuleId::Dmx
+    }
+
+    async fn initialize(&mut self) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        Ok(())
+    }
+
+    async fn run(
+        &mut self,
+        mut rx: mpsc::Receiver<ModuleEvent>,
+        _tx: mpsc::Sender<ModuleMessage>,
+    ) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        while let Some(event) = rx.recv().await {
+            match event {
+                ModuleEvent::DmxOutput(universe, data) => {
+                    let mut recording = self.recording.lock().unwrap();
+                    recording.frames.push((universe, data.clone()));
+                    recording.universes.insert(universe, data);
+                    recording.frame_count += 1;
+                }
+                ModuleEvent::Shutdown => break,
+                _ => {}
+            }
+        }
+        Ok(())
+    }
+
+    async fn shutdown(&mut self) -> Result<(), Box<dyn std::error::Error + Send + Sync>> {
+        Ok(())
+    }
+
+    fn status(&self) -> HashMap<String, String> {
+        HashMap::new()
+    }
+}
+
+pub struct Harness {
+    pub console: LightingConsole,
+    pub clock: ManualClock,
+    pub recording: Arc<Mutex<DmxRecording>>,
+    event_tx: mpsc::UnboundedSender<ConsoleEvent>,
+    event_rx: mpsc::UnboundedReceiver<ConsoleEvent>,
+    bpm: Option<f64>,
+    /// Cue warnings Go has stopped to ask about, oldest first
+    pub cue_warnings: Vec<String>,
+    base_dir: PathBuf,
+}
+
+impl Harness {
+    pub async fn new() -> Self {
+        let recording = Arc::new(Mutex::new(DmxRecording::default()));
+        let modules: Vec<Box<dyn AsyncModule>> = vec![Box::new(RecordingDmxModule {
+            recording: Arc::clone(&recording),
+        })];
+
+        let clock = ManualClock::new();
+        let mut console = LightingConsole::new_with_modules(120.0, Settings::default(), modules)
+            .expect("failed to create console");
+        console.set_clock(Arc::new(clock.clone())).await;
+        console
+            .initialize()
+            .await
+            .expect("failed to initialize console");
+
+        let (event_tx, event_rx) = mpsc::unbounded_channel();
+
+        Self {
+            console,
+            clock,
+            recording,
+            event_tx,
+            event_rx,
+            bpm: None,
+            cue_warnings: Vec::new(),
+            base_dir: PathBuf::from(env!("CARGO_MANIFEST_DIR")).join("tests/testdata"),
+        }
+    }
+
+    /// Send a command to the console as the UI would
+    pub async fn command(&mut self, command: ConsoleCommand) -> Result<(), String> {
+        self.console
+            .process_command(command, &self.event_tx)
+            .await
+            .map_err(|e| e.to_string())?;
+        self.drain_events()
+    }
+
+    /// Advance the clock by `duration`, updating the console every tick
+    pub async fn advance(&mut self, duration: Duration) -> Result<(), String> {
+        let mut remaining = duration;
+        while !remaining.is_zero() {
+            let step = remaining.min(TICK);
+            self.clock.advance(step);
+            self.console.update().await.map_err(|e| e.to_string())?;
+            remaining -= step;
+        }
+        self.settle().await;
+        Ok(())
+    }
+
+    /// Let the recording module catch up with everything the console has sent
+    async fn settle(&self) {
+        for _ in 0..16 {
+            tokio::task::yield_now().await;
+        }
+    }
+
+    fn drain_events(&mut self) -> Result<(), String> {
+        while let Ok(event) = self.event_rx.try_recv() {
+            match event {
+                ConsoleEvent::BpmChanged { bpm } => self.bpm = Some(bpm),
+                ConsoleEvent::CueWarning { warning, .. } => self.cue_warnings.push(warning),
+                ConsoleEvent::Error { message } => return Err(message),
+                _ => {}
+            }
+        }
+        Ok(())
+    }
+
+    /// Run every step of a script file, stopping at the first failure
+    pub async fn run_script(&mut self, path: &Path) -> Result<(), String> {
+        let script = std::fs::read_to_string(path)
+            .map_err(|e| format!("failed to read {}: {e}", path.display()))?;
+        if let Some(dir) = path.parent() {
+            self.base_dir = dir.to_path_buf();
+        }
+
+        for (number, line) in script.lines().enumerate() {
+            let line = line.split('#').next().unwrap_or("").trim();
+            if line.is_empty() {
+                continue;
+            }
+            self.run_step(line)
+                .await
+                .map_err(|e| format!("{}:{}: `{line}`: {e}", path.display(), number + 1))?;
+        }
+        Ok(())
+    }
+
+    /// Run a single script step
+    pub async fn run_step(&mut self, line: &str) -> Result<(), String> {
+        let words: Vec<&str> = line.split_whitespace().collect();
+        match words.as_slice() {
+            ["load", file] => {
+                let path = self.base_dir.join(file);
+                self.command(ConsoleCommand::LoadShow { path }).await
+            }
+            ["advance", duration] => {
+                // Musical lengths, e.g. "2bar", are worked out at the console's tempo
+                let duration: MusicalDuration = duration.parse()?;
+                let meter = self.console.meter().await;
+                self.advance(duration.resolve(&meter)).await
+            }
+            ["go"] => self.command(ConsoleCommand::Play).await,
+            ["confirm"] => self.command(ConsoleCommand::ConfirmGo).await,
+            ["stop"] => self.command(ConsoleCommand::Stop).await,
+            ["hold"] => self.command(ConsoleCommand::Pause).await,
+            ["resume"] => self.command(ConsoleCommand::Resume).await,
+            ["goto", list, cue] => {
+                let list_index = parse(list)?;
+                let cue_index = parse(cue)?;
+                self.command(ConsoleCommand::GoToCue {
+                    list_index,
+                    cue_index,
+                })
+                .await
+            }
+            ["fullon"] => self.command(ConsoleCommand::FullOn).await,
+            ["release", "fullon"] => self.command(ConsoleCommand::ReleaseFullOn).await,
+            ["flash", name @ ..] => {
+                let cue_name = name.join(" ");
+                self.command(ConsoleCommand::FlashOn { cue_name }).await
+            }
+            ["release", name @ ..] => {
+                let cue_name = name.join(" ");
+                self.command(ConsoleCommand::FlashOff { cue_name }).await
+            }
+            ["command", fixture_id, name @ ..] => {
+                let fixture_id = parse(fixture_id)?;
+                let command = name.join(" ");
+                self.command(ConsoleCommand::SendFixtureCommand {
+                    fixture_id,
+                    command,
+                })
+                .await
+            }
+            ["solo", ids @ ..] => {
+                let fixture_ids = ids.iter().map(|id| parse(id)).collect::<Result<_, _>>()?;
+                self.command(ConsoleCommand::Solo { fixture_ids }).await
+            }
+            ["unsolo"] => self.command(ConsoleCommand::Unsolo).await,
+            ["tap"] => self.command(ConsoleCommand::TapTempo).await,
+            ["midi", bytes @ ..] => {
+                let message = bytes
+                    .iter()
+                    .map(|b| parse_byte(b))
+                    .collect::<Result<Vec<u8>, String>>()?;
+                self.command(ConsoleCommand::ProcessMidiMessage { message })
+                    .await
+            }
+            ["expect", rest @ ..] => self.expect(rest).await,
+            _ => Err("unknown step".to_string()),
+        }
+    }
+
+    async fn expect(&mut self, words: &[&str]) -> Result<(), String> {
+        match words {
+            ["cue", index] => {
+                let expected: usize = parse(index)?;
+                let actual = self
+                    .console
+                    .cue_manager
+                    .read()
+                    .await
+                    .get_current_cue_index();
+                check("cue", expected, actual)
+            }
+            ["state", state] => {
+                let expected = match *state {
+                    "stopped" => PlaybackState::Stopped,
+                    "playing" => PlaybackState::Playing,
+                    "holding" => PlaybackState::Holding,
+                    other => return Err(format!("unknown playback state `{other}`")),
+                };
+                let actual = self.console.cue_manager.read().await.get_playback_state();
+                check("state", expected, actual)
+            }
+            ["bpm", bpm] => {
+                let expected: f64 = parse(bpm)?;
+                let actual = self.bpm.ok_or("no BPM change seen")?;
+                if (expected - actual).abs() < 0.5 {
+                    Ok(())
+                } else {
+                    Err(format!("bpm: expected {expected}, got {actual}"))
+                }
+            }
+            ["channel", fixture_id, channel, value] => {
+                let fixture_id: usize = parse(fixture_id)?;
+                let expected: u8 = parse(value)?;
+                let fixtures = self.console.fixtures.read().await;
+                let fixture = fixtures
+                    .iter()
+                    .find(|f| f.id == fixture_id)
+                    .ok_or_else(|| format!("no fixture with id {fixture_id}"))?;
+                let actual = fixture
+                    .channels
+                    .iter()
+                    .find(|c| c.channel_type.to_string().eq_ignore_ascii_case(channel))
+                    .map(|c| c.value)
+                    .ok_or_else(|| format!("fixture {fixture_id} has no {channel} channel"))?;
+                check(channel, expected, actual)
+            }
+            ["dmx", universe, address, value] => {
+                let universe: u8 = parse(universe)?;
+                let address: usize = parse(address)?;
+                let expected: u8 = parse(value)?;
+                self.settle().await;
+                let recording = self.recording.lock().unwrap();
+                let frame = recording
+                    .universes
+                    .get(&universe)
+                    .ok_or_else(|| format!("no frames recorded for universe {universe}"))?;
+                let actual = *frame
+                    .get(address.wrapping_sub(1))
+                    .ok_or_else(|| format!("address {address} out of range"))?;
+                check("dmx", expected, actual)
+            }
+            _ => Err("unknown expectation".to_string()),
+        }
+    }
+}
+
+fn check<T: PartialEq + std::fmt::Debug>(what: &str, expected: T, actual: T) -> Result<(), String> {
+    if expected == actual {
+        Ok(())
+    } else {
+        Err(format!("{what}: expected {expected:?}, got {actual:?}"))
+    }
+}
+
+fn parse<T: std::str::FromStr>(value: &str) -> Result<T, String> {
+    value
+        .parse()
+        .map_err(|_| format!("invalid value `{value}`"))
+}
+
+fn parse_byte(value: &str) -> Result<u8, String> {
+    match value.strip_prefix("0x") {
+        Some(hex) => u8::from_str_radix(hex, 16).map_err(|_| format!("invalid byte `{value}`")),
+        None => parse(value),
+    }
+}
@@ -0,0 +1,167 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{
+    ConsoleCommand, Effect, EffectDistribution, EffectMapping, EffectRelease, EffectType,
+    MidiAction, MidiOverride, StaticValue,
+};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+
+#[tokio::test]
+async fn testdata_scripts() {
+    let dir = std::path::Path::new(env!("CARGO_MANIFEST_DIR")).join("tests/testdata");
+    let mut scripts: Vec<_> = std::fs::read_dir(&dir)
+        .expect("testdata directory")
+        .filter_map(|entry| entry.ok().map(|e| e.path()))
+        .filter(|path| path.extension().is_some_and(|ext| ext == "script"))
+        .collect();
+    scripts.sort();
+    assert!(!scripts.is_empty(), "no scripts in {}", dir.display());
+
+    for script in scripts {
+        let mut harness = Harness::new().await;
+        if let Err(e) = harness.run_script(&script).await {
+            panic!("{e}");
+        }
+    }
+}
+
+#[tokio::test]
+async fn tap_tempo_ignores_stale_taps() {
+    let mut harness = Harness::new().await;
+
+    harness.command(ConsoleCommand::TapTempo).await.unwrap();
+    harness.advance(Duration::from_secs(3)).await.unwrap();
+    harness.command(ConsoleCommand::TapTempo).await.unwrap();
+    harness.advance(Duration::from_millis(400)).await.unwrap();
+    harness.command(ConsoleCommand::TapTempo).await.unwrap();
+
+    harness.run_step("expect bpm 150").await.unwrap();
+}
+
+#[tokio::test]
+async fn midi_flash_releases_to_cue_fired_mid_flash() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    harness
+        .command(ConsoleCommand::AddMidiOverride {
+            note: 60,
+            override_config: MidiOverride {
+                action: MidiAction::StaticValues(vec![StaticValue {
+                    fixture_id: 0,
+                    channel_type: ChannelType::Red,
+                    value: 50,
+                }]),
+            },
+        })
+        .await
+        .unwrap();
+
+    harness.run_step("goto 0 0").await.unwrap();
+    harness.run_step("midi 0x90 60 127").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect channel 0 red 50").await.unwrap();
+
+    // The background cue changes while the note is held, but the flash stays on top
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect channel 0 red 50").await.unwrap();
+    harness
+        .run_step("expect channel 0 dimmer 255")
+        .await
+        .unwrap();
+
+    // Note off hands the channel back to the new cue, not the value from before the flash
+    harness.run_step("midi 0x80 60 0").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect channel 0 red 255").await.unwrap();
+    harness.run_step("expect dmx 1 2 255").await.unwrap();
+}
+
+#[tokio::test]
+async fn flashing_an_unknown_cue_is_an_error() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+
+    assert!(harness.run_step("flash Nope").await.is_err());
+}
+
+#[tokio::test]
+async fn fixture_commands_are_checked_before_running() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load spot.json").await.unwrap();
+
+    assert!(harness.run_step("command 0 lamp on").await.is_err());
+    assert!(harness.run_step("command 9 reset").await.is_err());
+
+    // A second reset can't start until the first has finished
+    harness.run_step("command 0 reset").await.unwrap();
+    assert!(harness.run_step("command 0 reset").await.is_err());
+    harness.advance(Duration::from_secs(6)).await.unwrap();
+    harness.run_step("command 0 reset").await.unwrap();
+}
+
+#[tokio::test]
+async fn full_on_beats_a_strobe_effect() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues[1].effects.push(EffectMapping {
+        name: "Strobe".to_string(),
+        effect: Effect {
+            effect_type: EffectType::Square,
+            ..Effect::default()
+        },
+        fixture_ids: vec![0],
+        channel_types: vec![ChannelType::Strobe],
+        distribution: EffectDistribution::All,
+        release: EffectRelease::Hold,
+    });
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+
+    let strobe = |harness: &Harness| {
+        let fixtures = harness.console.fixtures.try_read().unwrap();
+        fixtures[0].channel_value(&ChannelType::Strobe).unwrap()
+    };
+    let mut seen = Vec::new();
+    for _ in 0..40 {
+        harness.advance(Duration::from_millis(25)).await.unwrap();
+        seen.push(strobe(&harness));
+    }
+    assert!(seen.contains(&255), "strobe effect isn't running: {seen:?}");
+
+    // MIDI note 100 toggles full on
+    harness
+        .command(ConsoleCommand::AddMidiOverride {
+            note: 100,
+            override_config: MidiOverride {
+                action: MidiAction::FullOn,
+            },
+        })
+        .await
+        .unwrap();
+    harness.run_step("midi 0x90 100 127").await.unwrap();
+    for _ in 0..40 {
+        harness.advance(Duration::from_millis(25)).await.unwrap();
+        assert_eq!(strobe(&harness), 0);
+        harness
+            .run_step("expect channel 0 white 255")
+            .await
+            .unwrap();
+    }
+
+    harness.run_step("midi 0x90 100 127").await.unwrap();
+    let mut seen = Vec::new();
+    for _ in 0..40 {
+        harness.advance(Duration::from_millis(25)).await.unwrap();
+        seen.push(strobe(&harness));
+    }
+    assert!(seen.contains(&255), "strobe effect didn't resume: {seen:?}");
+}
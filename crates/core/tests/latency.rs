@@ -0,0 +1,103 @@
+mod harness;
+
+use std::collections::HashMap;
+use std::time::Duration;
+
+use halo_core::{
+    ConsoleCommand, Effect, EffectDistribution, EffectMapping, EffectParams, EffectRelease,
+    EffectType, Interval, Settings,
+};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+
+/// A square wave on the left PAR's dimmer, coming on at the start of every beat
+fn beat_strobe() -> EffectMapping {
+    EffectMapping {
+        name: "Beat strobe".to_string(),
+        effect: Effect {
+            effect_type: EffectType::Square,
+            params: EffectParams {
+                interval: Interval::Beat,
+                interval_ratio: 1.0,
+                phase: 0.0,
+            },
+            ..Effect::default()
+        },
+        fixture_ids: vec![0],
+        channel_types: vec![ChannelType::Dimmer],
+        distribution: EffectDistribution::All,
+        release: EffectRelease::Hold,
+    }
+}
+
+/// two_pars.json playing the beat strobe from the start of the clock, at 120 BPM
+async fn play_strobe(settings: Settings) -> Harness {
+    let mut harness = Harness::new().await;
+    harness
+        .command(ConsoleCommand::UpdateSettings { settings })
+        .await
+        .unwrap();
+    harness.run_step("load two_pars.json").await.unwrap();
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues[1].effects.push(beat_strobe());
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    harness
+}
+
+/// Milliseconds into the clock when the strobe's DMX output first switches on after the first
+/// beat
+async fn first_rising_edge(harness: &mut Harness) -> u128 {
+    let mut was_on = true;
+    for _ in 0..40 {
+        harness.advance(Duration::from_millis(25)).await.unwrap();
+        let on = harness.recording.lock().unwrap().universes[&1][0] > 0;
+        if on && !was_on {
+            return harness.clock.elapsed().as_millis();
+        }
+        was_on = on;
+    }
+    panic!("the strobe never switched on");
+}
+
+#[tokio::test]
+async fn beat_synced_effects_go_out_early_by_the_output_latency() {
+    // At 120 BPM the second beat falls 500ms in
+    let mut harness = play_strobe(Settings::default()).await;
+    let edge = first_rising_edge(&mut harness).await;
+    assert!((500..=525).contains(&edge), "edge at {edge}ms");
+
+    let mut harness = play_strobe(Settings {
+        output_latency_ms: 100.0,
+        ..Settings::default()
+    })
+    .await;
+    let edge = first_rising_edge(&mut harness).await;
+    assert!((400..=425).contains(&edge), "edge at {edge}ms");
+}
+
+#[tokio::test]
+async fn universe_latency_overrides_the_global_offset() {
+    let mut harness = play_strobe(Settings {
+        output_latency_ms: 100.0,
+        universe_latency_ms: HashMap::from([(1, 0.0)]),
+        ..Settings::default()
+    })
+    .await;
+    let edge = first_rising_edge(&mut harness).await;
+    assert!((500..=525).contains(&edge), "edge at {edge}ms");
+}
+
+#[test]
+fn output_latency_falls_back_to_the_global_offset() {
+    let settings = Settings {
+        output_latency_ms: 60.0,
+        universe_latency_ms: HashMap::from([(2, 20.0)]),
+        ..Settings::default()
+    };
+    assert_eq!(settings.output_latency(1), Duration::from_millis(60));
+    assert_eq!(settings.output_latency(2), Duration::from_millis(20));
+}
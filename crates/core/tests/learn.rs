@@ -0,0 +1,148 @@
+mod harness;
+
+use std::path::Path;
+use std::time::{Duration, Instant};
+
+use halo_core::{apply_learned, ConsoleCommand, Follow, LearnedTiming, Meter, Show, TimingLearner};
+use harness::Harness;
+
+fn secs(secs: u64) -> Duration {
+    Duration::from_secs(secs)
+}
+
+#[test]
+fn each_cue_runs_until_the_next_go() {
+    let started = Instant::now();
+    let meter = Meter {
+        bpm: 120.0,
+        ..Meter::default()
+    };
+    let mut learner = TimingLearner::new(0);
+    learner.cue_started(1, started, meter);
+    learner.cue_started(2, started + secs(2), meter);
+    learner.cue_started(3, started + secs(5), meter);
+
+    assert_eq!(
+        learner.learned(),
+        [
+            LearnedTiming {
+                cue_index: 1,
+                after: secs(2),
+                beats: 4.0,
+            },
+            LearnedTiming {
+                cue_index: 2,
+                after: secs(3),
+                beats: 6.0,
+            },
+        ]
+    );
+}
+
+#[test]
+fn jumps_teach_nothing_and_reruns_keep_the_latest() {
+    let started = Instant::now();
+    let meter = Meter::default();
+    let mut learner = TimingLearner::new(0);
+    learner.cue_started(1, started, meter);
+    learner.cue_started(3, started + secs(1), meter);
+    assert!(learner.learned().is_empty());
+
+    learner.cue_started(1, started + secs(2), meter);
+    learner.cue_started(2, started + secs(6), meter);
+    learner.cue_started(1, started + secs(7), meter);
+    learner.cue_started(2, started + secs(10), meter);
+    let after: Vec<_> = learner.learned().iter().map(|t| t.after).collect();
+    assert_eq!(after, [secs(3)]);
+}
+
+/// Go into Left Red, then Right Half two seconds later and Blackout three after that
+async fn run_two_pars(harness: &mut Harness) {
+    harness
+        .command(ConsoleCommand::StartLearning { list_index: 0 })
+        .await
+        .unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(secs(2)).await.unwrap();
+    harness.run_step("go").await.unwrap();
+    harness.advance(secs(3)).await.unwrap();
+    harness.run_step("go").await.unwrap();
+    harness.run_step("expect cue 3").await.unwrap();
+}
+
+#[tokio::test]
+async fn go_presses_become_follow_times() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    run_two_pars(&mut harness).await;
+
+    let (list_index, timings) = harness
+        .console
+        .cue_manager
+        .write()
+        .await
+        .stop_learning()
+        .unwrap();
+    assert_eq!(list_index, 0);
+    let after: Vec<_> = timings.iter().map(|t| (t.cue_index, t.after)).collect();
+    assert_eq!(after, [(1, secs(2)), (2, secs(3))]);
+
+    let mut list = harness.console.cue_manager.read().await.get_cue_lists()[0].clone();
+    let changes = apply_learned(&mut list, &timings);
+    assert_eq!(
+        changes,
+        [
+            "Left Red: follows on after 2.00s",
+            "Right Half: follows on after 3.00s"
+        ]
+    );
+    assert_eq!(list.cues[1].follow, Some(Follow::After(secs(2))));
+    assert_eq!(list.cues[2].follow, Some(Follow::After(secs(3))));
+    assert_eq!(list.cues[3].follow, None);
+}
+
+#[tokio::test]
+async fn writing_saves_the_follow_times_to_the_show() {
+    let dir = tempfile::tempdir().unwrap();
+    let path = dir.path().join("two_pars.json");
+    let testdata = Path::new(env!("CARGO_MANIFEST_DIR")).join("tests/testdata/two_pars.json");
+    std::fs::copy(testdata, &path).unwrap();
+
+    let mut harness = Harness::new().await;
+    harness
+        .command(ConsoleCommand::LoadShow { path: path.clone() })
+        .await
+        .unwrap();
+    run_two_pars(&mut harness).await;
+    harness
+        .command(ConsoleCommand::StopLearning { write: true })
+        .await
+        .unwrap();
+
+    let show = Show::read(&path).unwrap();
+    let follows: Vec<_> = show.cue_lists[0].cues.iter().map(|c| c.follow).collect();
+    assert_eq!(
+        follows,
+        [
+            None,
+            Some(Follow::After(secs(2))),
+            Some(Follow::After(secs(3))),
+            None
+        ]
+    );
+}
+
+#[tokio::test]
+async fn stopping_without_learning_is_an_error() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    let error = harness
+        .command(ConsoleCommand::StopLearning { write: false })
+        .await
+        .unwrap_err();
+    assert!(error.contains("Not learning"), "{error}");
+    assert!(harness
+        .command(ConsoleCommand::StartLearning { list_index: 9 })
+        .await
+        .is_err());
+}
@@ -0,0 +1,133 @@
+use std::sync::{Arc, Mutex};
+use std::time::Duration;
+
+use async_trait::async_trait;
+use halo_core::{Lifecycle, Stage};
+
+const TIMEOUT: Duration = Duration::from_millis(50);
+
+#[derive(Clone, Copy, PartialEq)]
+enum Behaviour {
+    Works,
+    FailsToStart,
+    HangsOnStop,
+}
+
+/// A stage that notes each start and stop in a shared log
+struct Logged {
+    name: String,
+    behaviour: Behaviour,
+    log: Arc<Mutex<Vec<String>>>,
+}
+
+#[async_trait]
+impl Stage for Logged {
+    fn name(&self) -> &str {
+        &self.name
+    }
+
+    async fn start(&mut self) -> Result<(), anyhow::Error> {
+        if self.behaviour == Behaviour::FailsToStart {
+            anyhow::bail!("no config");
+        }
+        self.log
+            .lock()
+            .unwrap()
+            .push(format!("start {}", self.name));
+        Ok(())
+    }
+
+    async fn stop(&mut self) -> Result<(), anyhow::Error> {
+        if self.behaviour == Behaviour::HangsOnStop {
+            std::future::pending::<()>().await;
+        }
+        self.log.lock().unwrap().push(format!("stop {}", self.name));
+        Ok(())
+    }
+}
+
+fn lifecycle(stages: &[(&str, Behaviour)]) -> (Lifecycle, Arc<Mutex<Vec<String>>>) {
+    let log = Arc::new(Mutex::new(Vec::new()));
+    let mut lifecycle = Lifecycle::new();
+    for (name, behaviour) in stages {
+        lifecycle.add(
+            Logged {
+                name: name.to_string(),
+                behaviour: *behaviour,
+                log: log.clone(),
+            },
+            TIMEOUT,
+        );
+    }
+    (lifecycle, log)
+}
+
+#[tokio::test]
+async fn stages_start_in_order_and_stop_in_reverse() {
+    let (mut lifecycle, log) = lifecycle(&[
+        ("config", Behaviour::Works),
+        ("console", Behaviour::Works),
+        ("outputs", Behaviour::Works),
+    ]);
+    assert_eq!(lifecycle.stage_names(), ["config", "console", "outputs"]);
+    lifecycle.start().await.unwrap();
+    lifecycle.stop().await.unwrap();
+    assert_eq!(
+        *log.lock().unwrap(),
+        [
+            "start config",
+            "start console",
+            "start outputs",
+            "stop outputs",
+            "stop console",
+            "stop config",
+        ]
+    );
+
+    // Stopping again has nothing left to stop
+    lifecycle.stop().await.unwrap();
+    assert_eq!(log.lock().unwrap().len(), 6);
+}
+
+#[tokio::test]
+async fn a_stage_that_fails_to_start_stops_the_ones_before_it() {
+    let (mut lifecycle, log) = lifecycle(&[
+        ("config", Behaviour::Works),
+        ("console", Behaviour::Works),
+        ("outputs", Behaviour::FailsToStart),
+        ("inputs", Behaviour::Works),
+    ]);
+    let error = lifecycle.start().await.unwrap_err();
+    assert_eq!(error.to_string(), "outputs: no config");
+    assert_eq!(
+        *log.lock().unwrap(),
+        [
+            "start config",
+            "start console",
+            "stop console",
+            "stop config"
+        ]
+    );
+}
+
+#[tokio::test]
+async fn a_hanging_stage_is_abandoned_and_the_rest_still_stop() {
+    let (mut lifecycle, log) = lifecycle(&[
+        ("config", Behaviour::Works),
+        ("console", Behaviour::HangsOnStop),
+        ("outputs", Behaviour::Works),
+    ]);
+    lifecycle.start().await.unwrap();
+
+    let error = tokio::time::timeout(Duration::from_secs(1), lifecycle.stop())
+        .await
+        .expect("the hanging stage should have been abandoned")
+        .unwrap_err();
+    assert_eq!(error.failures.len(), 1);
+    assert_eq!(error.failures[0].stage, "console");
+    assert!(error.failures[0].error.contains("timed out"));
+    assert_eq!(
+        log.lock().unwrap()[3..],
+        ["stop outputs".to_string(), "stop config".to_string()]
+    );
+}
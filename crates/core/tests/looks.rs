@@ -0,0 +1,112 @@
+mod harness;
+
+use std::path::{Path, PathBuf};
+use std::time::Duration;
+
+use halo_core::{expand_looks, ConsoleCommand, Cue, Looks, StaticValue};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+use serde_json::{json, Value};
+
+fn value(fixture_id: usize, channel_type: ChannelType, value: u8) -> StaticValue {
+    StaticValue {
+        fixture_id,
+        channel_type,
+        value,
+    }
+}
+
+fn gold(dimmer: u8) -> Vec<StaticValue> {
+    vec![
+        value(0, ChannelType::Dimmer, dimmer),
+        value(0, ChannelType::Red, 255),
+        value(0, ChannelType::Green, 180),
+    ]
+}
+
+#[test]
+fn cue_values_override_their_looks() {
+    let looks = Looks::from([
+        ("Gold".to_string(), gold(204)),
+        (
+            "Warm white".to_string(),
+            vec![value(0, ChannelType::White, 120)],
+        ),
+    ]);
+    let cue = Cue {
+        name: "Verse".to_string(),
+        looks: vec!["Gold".to_string(), "Warm white".to_string()],
+        static_values: vec![value(0, ChannelType::Dimmer, 255)],
+        ..Cue::default()
+    };
+
+    let (values, warnings) = expand_looks(&cue, &looks);
+    assert!(warnings.is_empty());
+    assert_eq!(
+        values,
+        [
+            value(0, ChannelType::Dimmer, 255),
+            value(0, ChannelType::Red, 255),
+            value(0, ChannelType::Green, 180),
+            value(0, ChannelType::White, 120),
+        ]
+    );
+}
+
+#[test]
+fn missing_looks_are_skipped_with_a_warning() {
+    let cue = Cue {
+        name: "Chorus".to_string(),
+        looks: vec!["Gold".to_string()],
+        static_values: vec![value(1, ChannelType::Dimmer, 128)],
+        ..Cue::default()
+    };
+
+    let (values, warnings) = expand_looks(&cue, &Looks::new());
+    assert_eq!(values, cue.static_values);
+    assert_eq!(warnings, ["Cue 'Chorus' uses missing look 'Gold'"]);
+}
+
+/// two_pars.json with Right Half also taking the left PAR to gold
+fn write_show(dir: &Path, look_dimmer: u8) -> PathBuf {
+    let testdata = Path::new(env!("CARGO_MANIFEST_DIR")).join("tests/testdata/two_pars.json");
+    let mut show: Value =
+        serde_json::from_str(&std::fs::read_to_string(testdata).unwrap()).unwrap();
+    show["looks"] = json!({ "Gold": gold(look_dimmer) });
+    show["cue_lists"][0]["cues"][2]["looks"] = json!(["Gold"]);
+    let path = dir.join("looks.json");
+    std::fs::write(&path, serde_json::to_string(&show).unwrap()).unwrap();
+    path
+}
+
+async fn run_right_half(harness: &mut Harness, path: PathBuf) {
+    harness
+        .command(ConsoleCommand::LoadShow { path })
+        .await
+        .unwrap();
+    harness.run_step("goto 0 2").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+}
+
+#[tokio::test]
+async fn cues_play_their_looks_and_follow_edits_on_reload() {
+    let dir = tempfile::tempdir().unwrap();
+    let mut harness = Harness::new().await;
+
+    run_right_half(&mut harness, write_show(dir.path(), 204)).await;
+    harness.run_step("expect dmx 1 1 204").await.unwrap();
+    harness.run_step("expect dmx 1 2 255").await.unwrap();
+    harness.run_step("expect dmx 1 3 180").await.unwrap();
+    // The cue's own value for the right PAR is still there
+    harness.run_step("expect dmx 1 10 128").await.unwrap();
+
+    run_right_half(&mut harness, write_show(dir.path(), 100)).await;
+    harness.run_step("expect dmx 1 1 100").await.unwrap();
+
+    // Saving keeps the reference, not the look's values
+    let show = harness.console.get_show().await;
+    let cue = &show.cue_lists[0].cues[2];
+    assert_eq!(cue.looks, ["Gold"]);
+    assert!(cue.static_values.iter().all(|v| v.fixture_id == 1));
+    assert_eq!(show.looks["Gold"], gold(100));
+}
@@ -0,0 +1,109 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{ConsoleCommand, Settings};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+
+fn dimmer(harness: &Harness) -> u8 {
+    let fixtures = harness.console.fixtures.try_read().unwrap();
+    fixtures[0].channel_value(&ChannelType::Dimmer).unwrap()
+}
+
+/// Left Red running in two_pars.json, with a fader source holding the first PAR at 40
+async fn fader_over_left_red(settings: Settings) -> Harness {
+    let mut harness = Harness::new().await;
+    harness
+        .command(ConsoleCommand::UpdateSettings { settings })
+        .await
+        .unwrap();
+    harness.run_step("load two_pars.json").await.unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+
+    set_fader(&mut harness, 40).await;
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+    harness.run_step("expect dmx 1 1 40").await.unwrap();
+    harness
+}
+
+async fn set_fader(harness: &mut Harness, value: u8) {
+    harness
+        .command(ConsoleCommand::SetManualValue {
+            source: "/fader/1".to_string(),
+            fixture_id: 0,
+            channel: "Dimmer".to_string(),
+            value,
+        })
+        .await
+        .unwrap();
+}
+
+#[tokio::test]
+async fn quiet_source_ramps_back_to_the_cue() {
+    let mut harness = fader_over_left_red(Settings {
+        manual_release_secs: Some(2.0),
+        manual_release_fade_secs: 1.0,
+        ..Settings::default()
+    })
+    .await;
+
+    // Still held just short of the timeout
+    harness.advance(Duration::from_millis(1900)).await.unwrap();
+    harness.run_step("expect dmx 1 1 40").await.unwrap();
+
+    // Then a steady ramp up to the cue's level
+    let mut levels = Vec::new();
+    for _ in 0..10 {
+        harness.advance(Duration::from_millis(100)).await.unwrap();
+        levels.push(dimmer(&harness));
+    }
+    assert!(levels.windows(2).all(|w| w[0] < w[1]), "{levels:?}");
+    assert!(levels[4] > 100 && levels[4] < 200, "{levels:?}");
+
+    harness.advance(Duration::from_millis(200)).await.unwrap();
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+}
+
+#[tokio::test]
+async fn active_source_is_not_released() {
+    let mut harness = fader_over_left_red(Settings {
+        manual_release_secs: Some(2.0),
+        ..Settings::default()
+    })
+    .await;
+
+    for value in [50, 60, 70] {
+        harness.advance(Duration::from_millis(1500)).await.unwrap();
+        set_fader(&mut harness, value).await;
+    }
+    harness.advance(Duration::from_millis(1500)).await.unwrap();
+    harness.run_step("expect dmx 1 1 70").await.unwrap();
+}
+
+#[tokio::test]
+async fn explicit_release_fades_straight_away() {
+    // No timeout, so only the release lets go
+    let mut harness = fader_over_left_red(Settings {
+        manual_release_fade_secs: 0.5,
+        ..Settings::default()
+    })
+    .await;
+    harness.advance(Duration::from_secs(30)).await.unwrap();
+    harness.run_step("expect dmx 1 1 40").await.unwrap();
+
+    harness
+        .command(ConsoleCommand::ReleaseManualSource {
+            source: "/fader/1".to_string(),
+        })
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(250)).await.unwrap();
+    let level = dimmer(&harness);
+    assert!(level > 40 && level < 255, "dimmer {level}");
+
+    harness.advance(Duration::from_millis(300)).await.unwrap();
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+}
@@ -0,0 +1,328 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{
+    ConsoleCommand, ContributionSource, ContributionTrace, Cue, CueList, Effect, EffectContext,
+    EffectDistribution, EffectMapping, EffectPlayer, EffectRegistry, EffectRelease, EffectSource,
+    MergePolicy, PlaybackMerge, RhythmState, Settings, StaticValue, TrackingState,
+};
+use halo_fixtures::{ChannelType, Fixture, FixtureLibrary};
+use harness::Harness;
+
+fn cue(name: &str) -> ContributionSource {
+    ContributionSource::Cue(name.to_string())
+}
+
+fn effect(name: &str) -> ContributionSource {
+    ContributionSource::Effect(name.to_string())
+}
+
+#[test]
+fn intensity_takes_the_highest_source_and_the_rest_the_latest() {
+    let mut merge = PlaybackMerge::new(MergePolicy::Htp);
+    merge.write(cue("Verse"), 0, &ChannelType::Dimmer, 180);
+    merge.write(effect("Pulse"), 0, &ChannelType::Dimmer, 60);
+    merge.write(cue("Verse"), 0, &ChannelType::Red, 255);
+    merge.write(effect("Rainbow"), 0, &ChannelType::Red, 20);
+    merge.write(effect("Pulse"), 1, &ChannelType::Dimmer, 60);
+
+    assert_eq!(
+        merge.merged(),
+        [
+            (0, ChannelType::Dimmer, 180),
+            (0, ChannelType::Red, 20),
+            (1, ChannelType::Dimmer, 60),
+        ]
+    );
+
+    let mut merge = PlaybackMerge::new(MergePolicy::Ltp);
+    merge.write(cue("Verse"), 0, &ChannelType::Dimmer, 180);
+    merge.write(effect("Pulse"), 0, &ChannelType::Dimmer, 60);
+    assert_eq!(merge.merged(), [(0, ChannelType::Dimmer, 60)]);
+}
+
+#[test]
+fn a_source_that_stops_writing_leaves_nothing_behind() {
+    let frame = |sources: &[(ContributionSource, u8)]| {
+        let mut merge = PlaybackMerge::new(MergePolicy::Htp);
+        for (source, value) in sources {
+            merge.write(source.clone(), 0, &ChannelType::Dimmer, *value);
+        }
+        merge.merged()
+    };
+
+    assert_eq!(
+        frame(&[(cue("Verse"), 100), (effect("Pulse"), 220)]),
+        [(0, ChannelType::Dimmer, 220)]
+    );
+    // The effect released, the cue's level is all that's left
+    assert_eq!(
+        frame(&[(cue("Verse"), 100)]),
+        [(0, ChannelType::Dimmer, 100)]
+    );
+}
+
+#[test]
+fn the_trace_shows_which_source_won_the_merge() {
+    let mut merge = PlaybackMerge::new(MergePolicy::Htp);
+    merge.write(cue("Verse"), 0, &ChannelType::Dimmer, 180);
+    merge.write(effect("Pulse"), 0, &ChannelType::Dimmer, 60);
+
+    let mut trace = ContributionTrace::new();
+    let mut fixtures = Vec::new();
+    trace.start(&fixtures);
+    merge.render(&mut fixtures, &mut trace);
+
+    let won: Vec<_> = trace
+        .contributions(0)
+        .iter()
+        .map(|c| (c.source.clone(), c.won))
+        .collect();
+    assert_eq!(won, [(cue("Verse"), true), (effect("Pulse"), false)]);
+}
+
+#[test]
+fn a_fixture_without_a_dimmer_takes_intensity_on_its_colors() {
+    let profile = FixtureLibrary::new().profiles["shehds-rgbw-par"].clone();
+    let mut channels = profile.channel_layout.clone();
+    channels.retain(|c| c.channel_type != ChannelType::Dimmer);
+    let mut fixtures = vec![Fixture::new(0, "RGB", profile, channels, 1, 1)];
+
+    let mut merge = PlaybackMerge::new(MergePolicy::Htp);
+    merge.write(cue("Wash"), 0, &ChannelType::Red, 0);
+    merge.write(cue("Wash"), 0, &ChannelType::Blue, 255);
+    merge.write(effect("Pulse"), 0, &ChannelType::Dimmer, 128);
+    merge.render(&mut fixtures, &mut ContributionTrace::new());
+
+    assert_eq!(fixtures[0].channel_value(&ChannelType::Red), Some(0));
+    assert_eq!(fixtures[0].channel_value(&ChannelType::Blue), Some(128));
+}
+
+#[tokio::test]
+async fn an_intensity_effect_never_changes_the_cues_color() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues.push(Cue::color_only(
+        "Right Red",
+        &[1],
+        (255, 0, 0),
+        Duration::ZERO,
+    ));
+    cue_lists[0].cues.push(Cue::color_base(
+        "Right Blue",
+        &[1],
+        (0, 0, 255),
+        Effect::default(),
+    ));
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+
+    harness.run_step("goto 0 4").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect dmx 1 11 255").await.unwrap();
+    harness.run_step("goto 0 5").await.unwrap();
+
+    let mut levels = Vec::new();
+    for _ in 0..40 {
+        harness.advance(Duration::from_millis(25)).await.unwrap();
+        let fixtures = harness.console.fixtures.read().await;
+        let right = &fixtures[1];
+        assert_eq!(right.channel_value(&ChannelType::Red), Some(0));
+        assert_eq!(right.channel_value(&ChannelType::Blue), Some(255));
+        levels.push(right.channel_value(&ChannelType::Dimmer).unwrap());
+    }
+    assert!(levels.iter().any(|&level| level != levels[0]), "{levels:?}");
+}
+
+/// Left Red with a sine running over the left PAR's dimmer, between 0 and half
+async fn wave_under_left_red(intensity_merge: MergePolicy) -> Harness {
+    let mut harness = Harness::new().await;
+    harness
+        .command(ConsoleCommand::UpdateSettings {
+            settings: Settings {
+                intensity_merge,
+                ..Settings::default()
+            },
+        })
+        .await
+        .unwrap();
+    harness.run_step("load two_pars.json").await.unwrap();
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues[1].effects.push(EffectMapping {
+        name: "Wave".to_string(),
+        effect: Effect {
+            max: 128,
+            ..Effect::default()
+        },
+        fixture_ids: vec![0],
+        channel_types: vec![ChannelType::Dimmer],
+        distribution: EffectDistribution::All,
+        release: EffectRelease::Hold,
+    });
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    harness
+}
+
+/// The left PAR's dimmer over the next 20 frames
+async fn dimmer_samples(harness: &mut Harness) -> Vec<u8> {
+    let mut samples = Vec::new();
+    for _ in 0..20 {
+        harness.advance(Duration::from_millis(25)).await.unwrap();
+        samples.push(
+            harness.console.fixtures.read().await[0]
+                .channel_value(&ChannelType::Dimmer)
+                .unwrap(),
+        );
+    }
+    samples
+}
+
+#[tokio::test]
+async fn an_effect_below_the_cue_level_leaves_it_steady() {
+    let mut harness = wave_under_left_red(MergePolicy::Htp).await;
+    assert!(dimmer_samples(&mut harness)
+        .await
+        .iter()
+        .all(|&level| level == 255));
+}
+
+#[tokio::test]
+async fn ltp_intensity_lets_the_effect_write_over_the_cue() {
+    let mut harness = wave_under_left_red(MergePolicy::Ltp).await;
+    let samples = dimmer_samples(&mut harness).await;
+    assert!(samples.iter().all(|&level| level <= 128), "{samples:?}");
+}
+
+/// Holds every fixture at the top of the effect's range
+struct Full;
+
+impl EffectSource for Full {
+    fn values(&mut self, _context: &EffectContext, fixture_ids: &[usize]) -> Vec<f64> {
+        vec![1.0; fixture_ids.len()]
+    }
+}
+
+/// An effect holding fixture 0's red at `red`
+fn held_red(name: &str, red: u8) -> EffectMapping {
+    EffectMapping {
+        name: name.to_string(),
+        effect: Effect {
+            source: Some("full".to_string()),
+            max: red,
+            ..Effect::default()
+        },
+        fixture_ids: vec![0],
+        channel_types: vec![ChannelType::Red],
+        distribution: EffectDistribution::All,
+        release: EffectRelease::Hold,
+    }
+}
+
+fn with_effects(name: &str, effects: Vec<EffectMapping>) -> Cue {
+    Cue {
+        name: name.to_string(),
+        effects,
+        ..Cue::default()
+    }
+}
+
+/// The red one frame puts on fixture 0 once `cues` have run, from a fresh console
+fn red_after(cues: &[Cue]) -> u8 {
+    let mut tracking = TrackingState::new();
+    for cue in cues {
+        tracking.apply_cue(cue);
+    }
+    let mut registry = EffectRegistry::new();
+    registry.register("Full", |_: &Effect| -> Box<dyn EffectSource> {
+        Box::new(Full)
+    });
+    let mut player = EffectPlayer::new(registry);
+    let profile = FixtureLibrary::new().profiles["shehds-rgbw-par"].clone();
+    let channels = profile.channel_layout.clone();
+    let mut fixtures = vec![Fixture::new(0, "PAR", profile, channels, 1, 1)];
+    let rhythm = RhythmState {
+        beat_phase: 0.0,
+        bar_phase: 0.0,
+        phrase_phase: 0.0,
+        beats_per_bar: 4,
+        bars_per_phrase: 4,
+        last_tap_time: None,
+        tap_count: 0,
+    };
+    player.render(&tracking.get_effects(), |_| rhythm.clone(), &mut fixtures);
+
+    let mut merge = PlaybackMerge::new(MergePolicy::Htp);
+    for (name, fixture_id, channel_type, value) in player.rendered() {
+        merge.write(effect(name), *fixture_id, channel_type, *value);
+    }
+    merge.render(&mut fixtures, &mut ContributionTrace::new());
+    fixtures[0].channel_value(&ChannelType::Red).unwrap()
+}
+
+#[test]
+fn the_effect_declared_last_wins_every_frame() {
+    // Every fresh console picks the same winner, however its effects are stored
+    let verse = with_effects("Verse", vec![held_red("Warm", 40), held_red("Hot", 200)]);
+    for _ in 0..200 {
+        assert_eq!(red_after(&[verse.clone()]), 200);
+    }
+
+    // A later cue declaring an effect again puts it last
+    let chorus = with_effects("Chorus", vec![held_red("Warm", 40)]);
+    for _ in 0..200 {
+        assert_eq!(red_after(&[verse.clone(), chorus.clone()]), 40);
+    }
+}
+
+#[test]
+fn cues_writing_a_channel_twice_are_flagged() {
+    let value = |fixture_id, channel_type, value| StaticValue {
+        fixture_id,
+        channel_type,
+        value,
+    };
+    let mut verse = with_effects(
+        "Verse",
+        vec![
+            held_red("Warm", 40),
+            held_red("Hot", 200),
+            // Intensity merges by policy, so overlapping there is fine
+            EffectMapping {
+                channel_types: vec![ChannelType::Dimmer],
+                ..held_red("Pulse", 255)
+            },
+            EffectMapping {
+                channel_types: vec![ChannelType::Dimmer],
+                ..held_red("Swell", 255)
+            },
+        ],
+    );
+    verse.static_values = vec![
+        value(0, ChannelType::Blue, 10),
+        value(1, ChannelType::Blue, 10),
+        value(0, ChannelType::Blue, 90),
+    ];
+    let list = CueList {
+        name: "Main".to_string(),
+        cues: vec![verse, with_effects("Chorus", vec![held_red("Warm", 40)])],
+        audio_file: None,
+        default_fade: None,
+        default_values: vec![],
+        move_in_black: None,
+    };
+    assert_eq!(
+        list.duplicate_warnings(),
+        [
+            "Cue 'Verse' in 'Main' writes channels twice: fixture 0 Blue set twice, the last \
+          value wins; effects 'Warm' and 'Hot' share 1 channel(s), 'Hot' wins"
+        ]
+    );
+}
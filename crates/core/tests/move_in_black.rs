@@ -0,0 +1,111 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{ConsoleCommand, Cue, CueList, StaticValue};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+
+const LEAD: Duration = Duration::from_millis(500);
+
+fn value(channel_type: ChannelType, value: u8) -> StaticValue {
+    StaticValue {
+        fixture_id: 0,
+        channel_type,
+        value,
+    }
+}
+
+fn cue(name: &str, fade_time: Duration, static_values: Vec<StaticValue>) -> Cue {
+    Cue {
+        name: name.to_string(),
+        fade_time,
+        static_values,
+        ..Cue::default()
+    }
+}
+
+/// The spot lit pointing down, faded out over a second, then revealed at a new tilt
+async fn reveal(move_in_black: Option<Duration>) -> Harness {
+    let mut harness = Harness::new().await;
+    harness.run_step("load spot.json").await.unwrap();
+    let cue_list = CueList {
+        name: "Reveal".to_string(),
+        cues: vec![
+            cue(
+                "Open",
+                Duration::ZERO,
+                vec![value(ChannelType::Dimmer, 255), value(ChannelType::Tilt, 0)],
+            ),
+            cue(
+                "Dark",
+                Duration::from_secs(1),
+                vec![value(ChannelType::Dimmer, 0)],
+            ),
+            cue(
+                "Reveal",
+                Duration::ZERO,
+                vec![
+                    value(ChannelType::Dimmer, 255),
+                    value(ChannelType::Tilt, 200),
+                    value(ChannelType::Gobo, 30),
+                ],
+            ),
+        ],
+        audio_file: None,
+        default_fade: None,
+        default_values: vec![],
+        move_in_black,
+    };
+    harness
+        .command(ConsoleCommand::SetCueLists {
+            cue_lists: vec![cue_list],
+        })
+        .await
+        .unwrap();
+    harness.run_step("goto 0 0").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect dmx 1 6 255").await.unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    harness
+}
+
+#[tokio::test]
+async fn a_dark_spot_moves_to_the_next_cues_tilt_before_it_lights() {
+    let mut harness = reveal(Some(LEAD)).await;
+
+    // Still fading out, so it stays put
+    harness.advance(Duration::from_millis(500)).await.unwrap();
+    harness.run_step("expect dmx 1 2 0").await.unwrap();
+
+    // Out, but not for long enough yet
+    harness.advance(Duration::from_millis(700)).await.unwrap();
+    harness.run_step("expect dmx 1 6 0").await.unwrap();
+    harness.run_step("expect dmx 1 2 0").await.unwrap();
+
+    // Moved and set up while still dark
+    harness.advance(Duration::from_secs(1)).await.unwrap();
+    harness.run_step("expect dmx 1 6 0").await.unwrap();
+    harness.run_step("expect dmx 1 2 200").await.unwrap();
+    harness.run_step("expect dmx 1 4 30").await.unwrap();
+
+    // The reveal only brings up the intensity
+    harness.run_step("go").await.unwrap();
+    harness.advance(Duration::from_millis(25)).await.unwrap();
+    harness.run_step("expect cue 2").await.unwrap();
+    harness.run_step("expect dmx 1 6 255").await.unwrap();
+    harness.run_step("expect dmx 1 2 200").await.unwrap();
+}
+
+#[tokio::test]
+async fn a_list_without_move_in_black_moves_with_the_reveal() {
+    let mut harness = reveal(None).await;
+    harness.advance(Duration::from_millis(2200)).await.unwrap();
+    harness.run_step("expect dmx 1 6 0").await.unwrap();
+    harness.run_step("expect dmx 1 2 0").await.unwrap();
+
+    harness.run_step("go").await.unwrap();
+    harness.advance(Duration::from_millis(25)).await.unwrap();
+    harness.run_step("expect dmx 1 6 255").await.unwrap();
+    harness.run_step("expect dmx 1 2 0").await.unwrap_err();
+}
@@ -0,0 +1,174 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{ConsoleCommand, Cue, CueList, Meter, MusicalDuration, StaticValue};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+
+fn meter(bpm: f64) -> Meter {
+    Meter {
+        bpm,
+        ..Meter::default()
+    }
+}
+
+#[test]
+fn lengths_parse_with_absolute_and_musical_units() {
+    let parse = |s: &str| s.parse::<MusicalDuration>().unwrap();
+    assert_eq!(
+        parse("500ms"),
+        MusicalDuration::Absolute(Duration::from_millis(500))
+    );
+    assert_eq!(
+        parse("2s"),
+        MusicalDuration::Absolute(Duration::from_secs(2))
+    );
+    assert_eq!(
+        parse("1.5min"),
+        MusicalDuration::Absolute(Duration::from_secs(90))
+    );
+    assert_eq!(parse("4b"), MusicalDuration::Beats(4.0));
+    assert_eq!(parse("0.5beats"), MusicalDuration::Beats(0.5));
+    assert_eq!(parse("2bar"), MusicalDuration::Bars(2.0));
+    assert_eq!(parse(" 1 Phrase "), MusicalDuration::Phrases(1.0));
+
+    // And write back the way they were written
+    for text in ["500ms", "2s", "4b", "2bar", "1phrase"] {
+        assert_eq!(parse(text).to_string(), text);
+    }
+}
+
+#[test]
+fn unclear_lengths_are_refused_with_a_reason() {
+    let error = |s: &str| s.parse::<MusicalDuration>().unwrap_err();
+    assert!(error("4").contains("no unit"), "{}", error("4"));
+    assert!(error("2m").contains("ambiguous"), "{}", error("2m"));
+    assert!(error("3 fortnights").contains("Unknown unit"));
+    assert!(error("b").contains("number"));
+    assert!(error("-1b").contains("number"));
+    assert!(error("1.2.3s").contains("isn't a number"));
+}
+
+#[test]
+fn musical_lengths_follow_the_tempo_and_absolute_ones_dont() {
+    let fade = MusicalDuration::Beats(4.0);
+    assert_eq!(fade.resolve(&meter(120.0)), Duration::from_secs(2));
+    assert_eq!(fade.resolve(&meter(60.0)), Duration::from_secs(4));
+
+    // Four beats to the bar and four bars to the phrase
+    assert_eq!(
+        MusicalDuration::Bars(2.0).resolve(&meter(120.0)),
+        Duration::from_secs(4)
+    );
+    assert_eq!(
+        MusicalDuration::Phrases(1.0).resolve(&meter(60.0)),
+        Duration::from_secs(16)
+    );
+
+    let fixed = MusicalDuration::Absolute(Duration::from_millis(500));
+    assert_eq!(fixed.resolve(&meter(120.0)), Duration::from_millis(500));
+    assert_eq!(fixed.resolve(&meter(60.0)), Duration::from_millis(500));
+}
+
+#[test]
+fn show_files_can_write_durations_with_units() {
+    let cue: Cue = serde_json::from_str(
+        r#"{
+            "id": 0,
+            "name": "Build",
+            "fade_time": "500ms",
+            "intensity_fade": "2s",
+            "tempo_fade": "2bar",
+            "delays": [{ "fixture_id": 1, "delay": "250ms" }],
+            "static_values": [],
+            "effects": [],
+            "pixel_effects": [],
+            "timecode": null,
+            "is_blocking": false
+        }"#,
+    )
+    .unwrap();
+    assert_eq!(cue.fade_time, Duration::from_millis(500));
+    assert_eq!(cue.intensity_fade, Some(Duration::from_secs(2)));
+    assert_eq!(cue.delays[0].delay, Duration::from_millis(250));
+    assert_eq!(cue.tempo_fade, Some(MusicalDuration::Bars(2.0)));
+    assert_eq!(
+        cue.clone().at_tempo(&meter(120.0)).fade_time,
+        Duration::from_secs(4)
+    );
+
+    // Round trips keep the tempo fade as written
+    let json = serde_json::to_value(&cue).unwrap();
+    assert_eq!(json["tempo_fade"], "2bar");
+
+    // Fields fixed at load time say where a musical length can go instead
+    let error = serde_json::from_str::<Cue>(
+        r#"{
+            "id": 0,
+            "name": "Build",
+            "fade_time": "4b",
+            "static_values": [],
+            "effects": [],
+            "pixel_effects": [],
+            "timecode": null,
+            "is_blocking": false
+        }"#,
+    )
+    .unwrap_err()
+    .to_string();
+    assert!(error.contains("tempo_fade"), "{error}");
+}
+
+/// A cue fading the left PAR up over four beats, started at `bpm`
+async fn four_beat_fade(bpm: f64) -> Harness {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    harness
+        .command(ConsoleCommand::SetBpm { bpm })
+        .await
+        .unwrap();
+    let cue_list = CueList {
+        name: "Main".to_string(),
+        cues: vec![Cue {
+            name: "Swell".to_string(),
+            static_values: vec![StaticValue {
+                fixture_id: 0,
+                channel_type: ChannelType::Dimmer,
+                value: 255,
+            }],
+            ..Cue::default()
+        }
+        .with_tempo_fade(MusicalDuration::Beats(4.0))],
+        audio_file: None,
+        default_fade: None,
+        default_values: vec![],
+        move_in_black: None,
+    };
+    harness
+        .command(ConsoleCommand::SetCueLists {
+            cue_lists: vec![cue_list],
+        })
+        .await
+        .unwrap();
+    // Let the cue engine pick up the new tempo before the Go
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("goto 0 0").await.unwrap();
+    harness
+}
+
+#[tokio::test]
+async fn a_tempo_fade_takes_its_length_from_the_tempo_at_the_go() {
+    // Four beats at 120bpm is two seconds
+    let mut fast = four_beat_fade(120.0).await;
+    fast.run_step("advance 2100ms").await.unwrap();
+    fast.run_step("expect dmx 1 1 255").await.unwrap();
+
+    // and at 60bpm four
+    let mut slow = four_beat_fade(60.0).await;
+    slow.run_step("advance 2100ms").await.unwrap();
+    assert!(slow.run_step("expect dmx 1 1 255").await.is_err());
+    slow.run_step("advance 1b").await.unwrap();
+    slow.run_step("advance 1b").await.unwrap();
+    slow.run_step("expect dmx 1 1 255").await.unwrap();
+}
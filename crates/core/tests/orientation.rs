@@ -0,0 +1,221 @@
+mod harness;
+
+use std::collections::HashMap;
+use std::time::Duration;
+
+use halo_core::{ConsoleCommand, Cue, CueList, FrameCache, StaticValue};
+use halo_fixtures::{Channel, ChannelType, Fixture, FixtureLibrary, Orientation};
+use harness::Harness;
+
+/// The spot at address 1 with pan at 10 and tilt at 200, with fine channels if `fine`
+fn spot(orientation: Orientation, fine: bool) -> Fixture {
+    let profile = FixtureLibrary::new().profiles["shehds-led-spot-60w"].clone();
+    let mut channels = profile.channel_layout.clone();
+    if fine {
+        let channel = |name: &str, channel_type| Channel {
+            name: name.to_string(),
+            channel_type,
+            value: 0,
+        };
+        channels.insert(1, channel("Pan Fine", ChannelType::PanFine));
+        channels.insert(3, channel("Tilt Fine", ChannelType::TiltFine));
+    }
+    let mut fixture = Fixture::new(0, "Spot", profile, channels, 1, 1);
+    fixture.orientation = orientation;
+    fixture.set_channel_value(&ChannelType::Pan, 10);
+    fixture.set_channel_value(&ChannelType::Tilt, 200);
+    fixture.set_channel_value(&ChannelType::Dimmer, 255);
+    fixture
+}
+
+/// What goes out on the pan and tilt channels
+fn sent(fixture: &Fixture) -> (u8, u8) {
+    let values = fixture.get_dmx_values();
+    let at = |channel_type| {
+        let address = fixture.channel_address(&channel_type).unwrap();
+        values[address as usize - 1]
+    };
+    (at(ChannelType::Pan), at(ChannelType::Tilt))
+}
+
+#[test]
+fn each_flag_changes_only_what_it_names() {
+    let upright = spot(Orientation::default(), false);
+    assert_eq!(sent(&upright), (10, 200));
+
+    let cases = [
+        (
+            Orientation {
+                invert_pan: true,
+                ..Orientation::default()
+            },
+            (245, 200),
+        ),
+        (
+            Orientation {
+                invert_tilt: true,
+                ..Orientation::default()
+            },
+            (10, 55),
+        ),
+        (
+            Orientation {
+                swap_pan_tilt: true,
+                ..Orientation::default()
+            },
+            (200, 10),
+        ),
+    ];
+    for (orientation, expected) in cases {
+        let fixture = spot(orientation, false);
+        assert_eq!(sent(&fixture), expected, "{orientation:?}");
+        // Cues still see the values they set, and nothing else goes out differently
+        assert_eq!(fixture.channel_value(&ChannelType::Pan), Some(10));
+        let pan = fixture.channel_address(&ChannelType::Pan).unwrap() as usize - 1;
+        let tilt = fixture.channel_address(&ChannelType::Tilt).unwrap() as usize - 1;
+        let others = |f: &Fixture| {
+            let mut values = f.get_dmx_values();
+            values[pan] = 0;
+            values[tilt] = 0;
+            values
+        };
+        assert_eq!(others(&fixture), others(&upright));
+    }
+}
+
+#[test]
+fn combined_flags_invert_before_swapping() {
+    let both = Orientation {
+        invert_pan: true,
+        invert_tilt: true,
+        swap_pan_tilt: false,
+    };
+    assert_eq!(sent(&spot(both, false)), (245, 55));
+
+    // Pan inverted goes out on the tilt channel
+    let sideways = Orientation {
+        invert_pan: true,
+        invert_tilt: false,
+        swap_pan_tilt: true,
+    };
+    assert_eq!(sent(&spot(sideways, false)), (200, 245));
+
+    let all = Orientation {
+        invert_pan: true,
+        invert_tilt: true,
+        swap_pan_tilt: true,
+    };
+    assert_eq!(sent(&spot(all, false)), (55, 245));
+}
+
+#[test]
+fn fine_channels_follow_their_axis() {
+    let all = Orientation {
+        invert_pan: true,
+        invert_tilt: true,
+        swap_pan_tilt: true,
+    };
+    let mut fixture = spot(all, true);
+    fixture.set_channel_value_16(&ChannelType::Pan, 0x1234);
+    fixture.set_channel_value_16(&ChannelType::Tilt, 0xABCD);
+    // Tilt inverted on the pan channels, pan inverted on the tilt channels
+    assert_eq!(fixture.get_dmx_values()[..4], [0x54, 0x32, 0xED, 0xCB]);
+}
+
+#[test]
+fn sent_values_read_back_as_cues_set_them() {
+    let all = Orientation {
+        invert_pan: true,
+        invert_tilt: false,
+        swap_pan_tilt: true,
+    };
+    let fixture = spot(all, false);
+    let data = fixture.get_dmx_values();
+    assert_eq!(fixture.channel_value_in(&data, &ChannelType::Pan), Some(10));
+    assert_eq!(
+        fixture.channel_value_in(&data, &ChannelType::Tilt),
+        Some(200)
+    );
+    let read = fixture.values_from_dmx(&data);
+    let value = |channel_type| {
+        read.iter()
+            .find(|(c, _)| *c == channel_type)
+            .map(|(_, v)| *v)
+    };
+    assert_eq!(value(ChannelType::Pan), Some(10));
+    assert_eq!(value(ChannelType::Tilt), Some(200));
+}
+
+#[test]
+fn turning_a_fixture_round_sends_it_again() {
+    let mut cache = FrameCache::new();
+    let mut fixtures = vec![spot(Orientation::default(), false)];
+    assert_eq!(cache.render(&fixtures, HashMap::new()).len(), 1);
+    assert!(cache.render(&fixtures, HashMap::new()).is_empty());
+
+    fixtures[0].orientation.invert_pan = true;
+    let changed = cache.render(&fixtures, HashMap::new());
+    assert_eq!(changed.len(), 1);
+    assert_eq!(changed[0].1[0], 245);
+}
+
+fn pan_cue(name: &str, pan: u8, fade_time: Duration) -> Cue {
+    Cue {
+        name: name.to_string(),
+        fade_time,
+        static_values: vec![StaticValue {
+            fixture_id: 0,
+            channel_type: ChannelType::Pan,
+            value: pan,
+        }],
+        ..Cue::default()
+    }
+}
+
+#[tokio::test]
+async fn an_interrupted_fade_on_an_inverted_spot_carries_on_from_where_it_is() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load spot.json").await.unwrap();
+    harness.console.fixtures.write().await[0]
+        .orientation
+        .invert_pan = true;
+    let cue_list = CueList {
+        name: "Sweep".to_string(),
+        cues: vec![
+            pan_cue("Left", 0, Duration::ZERO),
+            pan_cue("Right", 200, Duration::from_secs(2)),
+            pan_cue("Back", 0, Duration::from_secs(2)),
+        ],
+        audio_file: None,
+        default_fade: None,
+        default_values: vec![],
+        move_in_black: None,
+    };
+    harness
+        .command(ConsoleCommand::SetCueLists {
+            cue_lists: vec![cue_list],
+        })
+        .await
+        .unwrap();
+    harness.run_step("goto 0 0").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+
+    // Halfway to the right, the fade back is started from there
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_secs(1)).await.unwrap();
+    let halfway = harness.console.fixtures.read().await[0]
+        .channel_value(&ChannelType::Pan)
+        .unwrap();
+    assert!((90..=110).contains(&halfway), "pan {halfway}");
+
+    harness.run_step("goto 0 2").await.unwrap();
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+    let fixtures = harness.console.fixtures.read().await;
+    let pan = fixtures[0].channel_value(&ChannelType::Pan).unwrap();
+    assert!(
+        pan <= halfway && halfway - pan <= 5,
+        "pan jumped from {halfway} to {pan}"
+    );
+    assert_eq!(sent(&fixtures[0]).0, 255 - pan);
+}
@@ -0,0 +1,615 @@
+use std::sync::{Arc, Mutex};
+use std::time::Duration;
+
+use halo_core::{
+    AsyncModule, BuildInfo, Capture, CapturedFrame, DmxModule, ModuleEvent, ModuleMessage,
+    OutputCapture, OutputDriver, OutputKind, OutputStats, Settings,
+};
+use tokio::sync::mpsc;
+
+/// What a driver was asked to do, in order
+#[derive(Clone, Debug, PartialEq)]
+enum Call {
+    Open,
+    Send(u8, Vec<u8>),
+    Close,
+}
+
+/// A driver that writes down every call instead of sending anything
+struct Recorder {
+    calls: Arc<Mutex<Vec<Call>>>,
+    /// Universes to refuse, to check a failing send doesn't stop the others
+    failing: Vec<u8>,
+}
+
+impl OutputDriver for Recorder {
+    fn name(&self) -> &str {
+        "recorder"
+    }
+
+    fn open(&mut self) -> Result<(), anyhow::Error> {
+        self.calls.lock().unwrap().push(Call::Open);
+        Ok(())
+    }
+
+    fn send_universe(&mut self, universe: u8, data: &[u8]) -> Result<(), anyhow::Error> {
+        if self.failing.contains(&universe) {
+            anyhow::bail!("universe {universe} is unplugged");
+        }
+        self.calls
+            .lock()
+            .unwrap()
+            .push(Call::Send(universe, data.to_vec()));
+        Ok(())
+    }
+
+    fn close(&mut self) -> Result<(), anyhow::Error> {
+        self.calls.lock().unwrap().push(Call::Close);
+        Ok(())
+    }
+}
+
+/// Run a DMX module over a recorder, send it `frames`, then shut it down
+async fn record(frames: Vec<(u8, Vec<u8>)>, failing: Vec<u8>) -> Vec<Call> {
+    let calls = Arc::new(Mutex::new(Vec::new()));
+    let mut module = DmxModule::with_driver(Box::new(Recorder {
+        calls: calls.clone(),
+        failing,
+    }));
+    module.initialize().await.unwrap();
+
+    let (event_tx, event_rx) = mpsc::channel(16);
+    let (message_tx, mut message_rx) = mpsc::channel::<ModuleMessage>(16);
+    tokio::spawn(async move { while message_rx.recv().await.is_some() {} });
+    let handle = tokio::spawn(async move { module.run(event_rx, message_tx).await.unwrap() });
+
+    for (universe, data) in frames {
+        event_tx
+            .send(ModuleEvent::DmxOutput(universe, data))
+            .await
+            .unwrap();
+    }
+    // A few frames at 44Hz
+    tokio::time::sleep(Duration::from_millis(100)).await;
+    event_tx.send(ModuleEvent::Shutdown).await.unwrap();
+    handle.await.unwrap();
+
+    let calls = calls.lock().unwrap().clone();
+    calls
+}
+
+#[tokio::test]
+async fn the_module_sends_the_latest_frame_of_each_universe_through_its_driver() {
+    let calls = record(
+        vec![(1, vec![1; 512]), (1, vec![2; 512]), (2, vec![3; 512])],
+        vec![],
+    )
+    .await;
+
+    assert_eq!(calls.first(), Some(&Call::Open));
+    assert_eq!(calls.last(), Some(&Call::Close));
+    let sent: Vec<&Call> = calls
+        .iter()
+        .filter(|c| matches!(c, Call::Send(..)))
+        .collect();
+    assert!(!sent.is_empty());
+    // Only the newest frame for universe 1 goes out once both have arrived
+    assert!(sent.contains(&&Call::Send(1, vec![2; 512])));
+    assert!(sent.contains(&&Call::Send(2, vec![3; 512])));
+    assert_eq!(
+        calls.iter().filter(|c| **c == Call::Close).count(),
+        1,
+        "closed once, after the last frame"
+    );
+}
+
+#[tokio::test]
+async fn a_universe_that_fails_to_send_doesnt_hold_up_the_others() {
+    let calls = record(vec![(1, vec![1; 512]), (2, vec![2; 512])], vec![1]).await;
+
+    assert!(calls.contains(&Call::Send(2, vec![2; 512])));
+    assert!(!calls.iter().any(|c| matches!(c, Call::Send(1, _))));
+    assert_eq!(calls.last(), Some(&Call::Close));
+}
+
+#[test]
+fn the_output_is_picked_in_the_config_file() {
+    assert_eq!(Settings::default().output, OutputKind::ArtNet);
+
+    let mut config = serde_json::to_value(Settings::default()).unwrap();
+    config["output"] = "sacn".into();
+    let settings: Settings = serde_json::from_value(config.clone()).unwrap();
+    assert_eq!(settings.output, OutputKind::Sacn);
+
+    // Config files from before the option carry on with Art-Net
+    config.as_object_mut().unwrap().remove("output");
+    let settings: Settings = serde_json::from_value(config).unwrap();
+    assert_eq!(settings.output, OutputKind::ArtNet);
+
+    assert_eq!("null".parse::<OutputKind>(), Ok(OutputKind::Null));
+    assert!("ola".parse::<OutputKind>().is_err());
+}
+
+#[tokio::test(flavor = "multi_thread", worker_threads = 4)]
+async fn frames_from_many_senders_go_out_whole() {
+    let calls = Arc::new(Mutex::new(Vec::new()));
+    let mut module = DmxModule::with_driver(Box::new(Recorder {
+        calls: calls.clone(),
+        failing: vec![],
+    }));
+    module.initialize().await.unwrap();
+
+    let (event_tx, event_rx) = mpsc::channel(16);
+    let (message_tx, mut message_rx) = mpsc::channel::<ModuleMessage>(16);
+    tokio::spawn(async move { while message_rx.recv().await.is_some() {} });
+    let handle = tokio::spawn(async move { module.run(event_rx, message_tx).await.unwrap() });
+
+    // Each sender fills the universe with its own value, so a frame mixing two is torn
+    let senders: Vec<_> = (1..=8u8)
+        .map(|value| {
+            let event_tx = event_tx.clone();
+            tokio::spawn(async move {
+                for _ in 0..50 {
+                    event_tx
+                        .send(ModuleEvent::DmxOutput(1, vec![value; 512]))
+                        .await
+                        .unwrap();
+                    tokio::task::yield_now().await;
+                }
+            })
+        })
+        .collect();
+    for sender in senders {
+        sender.await.unwrap();
+    }
+    tokio::time::sleep(Duration::from_millis(50)).await;
+    event_tx.send(ModuleEvent::Shutdown).await.unwrap();
+    handle.await.unwrap();
+
+    let calls = calls.lock().unwrap();
+    let frames: Vec<&Vec<u8>> = calls
+        .iter()
+        .filter_map(|c| match c {
+            Call::Send(1, data) => Some(data),
+            _ => None,
+        })
+        .collect();
+    assert!(!frames.is_empty());
+    for frame in frames {
+        assert_eq!(frame.len(), 512);
+        assert!(frame.iter().all(|v| *v == frame[0]), "torn frame");
+    }
+}
+
+/// A DMX module over a recorder that resends unchanged universes every `keep_alive`
+fn keep_alive_module(
+    keep_alive: Option<Duration>,
+) -> (
+    Arc<Mutex<Vec<Call>>>,
+    Arc<OutputStats>,
+    mpsc::Sender<ModuleEvent>,
+    tokio::task::JoinHandle<()>,
+) {
+    let calls = Arc::new(Mutex::new(Vec::new()));
+    let mut module = DmxModule::with_driver(Box::new(Recorder {
+        calls: calls.clone(),
+        failing: vec![],
+    }));
+    module.set_keep_alive(keep_alive);
+    let stats = module.stats();
+
+    let (event_tx, event_rx) = mpsc::channel(16);
+    let (message_tx, mut message_rx) = mpsc::channel::<ModuleMessage>(16);
+    tokio::spawn(async move { while message_rx.recv().await.is_some() {} });
+    let handle = tokio::spawn(async move {
+        module.initialize().await.unwrap();
+        module.run(event_rx, message_tx).await.unwrap()
+    });
+    (calls, stats, event_tx, handle)
+}
+
+fn sends(calls: &Mutex<Vec<Call>>) -> usize {
+    calls
+        .lock()
+        .unwrap()
+        .iter()
+        .filter(|c| matches!(c, Call::Send(..)))
+        .count()
+}
+
+#[tokio::test]
+async fn universes_that_havent_changed_arent_sent_again() {
+    let (calls, stats, events, handle) = keep_alive_module(None);
+    events
+        .send(ModuleEvent::DmxOutput(1, vec![1; 512]))
+        .await
+        .unwrap();
+    tokio::time::sleep(Duration::from_millis(150)).await;
+    assert_eq!(sends(&calls), 1);
+    assert_eq!(stats.sent(), 1);
+    assert!(stats.skipped() > 0);
+
+    // The same data again is no change
+    events
+        .send(ModuleEvent::DmxOutput(1, vec![1; 512]))
+        .await
+        .unwrap();
+    tokio::time::sleep(Duration::from_millis(100)).await;
+    assert_eq!(sends(&calls), 1);
+
+    events
+        .send(ModuleEvent::DmxOutput(1, vec![2; 512]))
+        .await
+        .unwrap();
+    tokio::time::sleep(Duration::from_millis(100)).await;
+    assert_eq!(sends(&calls), 2);
+    assert!(calls.lock().unwrap().contains(&Call::Send(1, vec![2; 512])));
+
+    events.send(ModuleEvent::Shutdown).await.unwrap();
+    handle.await.unwrap();
+}
+
+#[tokio::test]
+async fn unchanged_universes_are_resent_to_keep_nodes_alive() {
+    let (calls, stats, events, handle) = keep_alive_module(Some(Duration::from_millis(50)));
+    events
+        .send(ModuleEvent::DmxOutput(1, vec![1; 512]))
+        .await
+        .unwrap();
+    tokio::time::sleep(Duration::from_millis(300)).await;
+    events.send(ModuleEvent::Shutdown).await.unwrap();
+    handle.await.unwrap();
+
+    // Around six resends over a dozen ticks, rather than one a tick
+    let sent = sends(&calls);
+    assert!(sent >= 3, "{sent} sends");
+    assert!(stats.skipped() > 0);
+    assert_eq!(stats.sent() as usize, sent);
+}
+
+/// A driver whose first few opens and sends fail as if the network were down
+struct Flaky {
+    calls: Arc<Mutex<Vec<Call>>>,
+    opens_to_fail: usize,
+    sends_to_fail: usize,
+}
+
+impl OutputDriver for Flaky {
+    fn name(&self) -> &str {
+        "flaky"
+    }
+
+    fn open(&mut self) -> Result<(), anyhow::Error> {
+        if self.opens_to_fail > 0 {
+            self.opens_to_fail -= 1;
+            anyhow::bail!("nothing listening");
+        }
+        self.calls.lock().unwrap().push(Call::Open);
+        Ok(())
+    }
+
+    fn send_universe(&mut self, universe: u8, data: &[u8]) -> Result<(), anyhow::Error> {
+        if self.sends_to_fail > 0 {
+            self.sends_to_fail -= 1;
+            return Err(std::io::Error::from(std::io::ErrorKind::ConnectionRefused).into());
+        }
+        self.calls
+            .lock()
+            .unwrap()
+            .push(Call::Send(universe, data.to_vec()));
+        Ok(())
+    }
+
+    fn close(&mut self) -> Result<(), anyhow::Error> {
+        self.calls.lock().unwrap().push(Call::Close);
+        Ok(())
+    }
+}
+
+/// Run a flaky driver for a while with one frame, then another, and return its calls
+async fn record_flaky(opens_to_fail: usize, sends_to_fail: usize) -> Vec<Call> {
+    let calls = Arc::new(Mutex::new(Vec::new()));
+    let mut module = DmxModule::with_driver(Box::new(Flaky {
+        calls: calls.clone(),
+        opens_to_fail,
+        sends_to_fail,
+    }));
+    module.set_reconnect_backoff(Duration::from_millis(10), Duration::from_millis(40));
+    module.initialize().await.unwrap();
+
+    let (event_tx, event_rx) = mpsc::channel(16);
+    let (message_tx, mut message_rx) = mpsc::channel::<ModuleMessage>(16);
+    tokio::spawn(async move { while message_rx.recv().await.is_some() {} });
+    let handle = tokio::spawn(async move { module.run(event_rx, message_tx).await.unwrap() });
+
+    event_tx
+        .send(ModuleEvent::DmxOutput(1, vec![1; 512]))
+        .await
+        .unwrap();
+    tokio::time::sleep(Duration::from_millis(300)).await;
+    event_tx
+        .send(ModuleEvent::DmxOutput(1, vec![2; 512]))
+        .await
+        .unwrap();
+    tokio::time::sleep(Duration::from_millis(100)).await;
+    event_tx.send(ModuleEvent::Shutdown).await.unwrap();
+    handle.await.unwrap();
+
+    let calls = calls.lock().unwrap().clone();
+    calls
+}
+
+#[tokio::test]
+async fn an_output_that_drops_is_reopened_and_carries_on() {
+    let calls = record_flaky(0, 3).await;
+
+    // Opened at startup, then closed and reopened after each of the three failed sends
+    let opens = calls.iter().filter(|c| **c == Call::Open).count();
+    let closes = calls.iter().filter(|c| **c == Call::Close).count();
+    assert_eq!(opens, 4, "{calls:?}");
+    assert_eq!(closes, 4, "{calls:?}");
+
+    // The frame that failed goes out once the output is back, and later ones follow
+    let sent: Vec<&Call> = calls
+        .iter()
+        .filter(|c| matches!(c, Call::Send(..)))
+        .collect();
+    assert_eq!(sent.first(), Some(&&Call::Send(1, vec![1; 512])));
+    assert!(sent.contains(&&Call::Send(1, vec![2; 512])));
+    assert_eq!(calls.last(), Some(&Call::Close));
+}
+
+#[tokio::test]
+async fn an_output_that_isnt_there_at_startup_is_opened_once_it_is() {
+    let calls = record_flaky(3, 0).await;
+
+    assert_eq!(calls.first(), Some(&Call::Open));
+    assert!(calls.contains(&Call::Send(1, vec![1; 512])));
+    assert!(calls.contains(&Call::Send(1, vec![2; 512])));
+    // Only the open that worked is closed
+    assert_eq!(calls.iter().filter(|c| **c == Call::Close).count(), 1);
+}
+
+/// A driver that notes when each universe went out
+struct Timed {
+    sends: Arc<Mutex<Vec<(u8, tokio::time::Instant)>>>,
+}
+
+impl OutputDriver for Timed {
+    fn name(&self) -> &str {
+        "timed"
+    }
+
+    fn open(&mut self) -> Result<(), anyhow::Error> {
+        Ok(())
+    }
+
+    fn send_universe(&mut self, universe: u8, _data: &[u8]) -> Result<(), anyhow::Error> {
+        self.sends
+            .lock()
+            .unwrap()
+            .push((universe, tokio::time::Instant::now()));
+        Ok(())
+    }
+
+    fn close(&mut self) -> Result<(), anyhow::Error> {
+        Ok(())
+    }
+}
+
+/// The gaps between sends of `universe`
+fn gaps(sends: &[(u8, tokio::time::Instant)], universe: u8) -> Vec<Duration> {
+    let times: Vec<_> = sends
+        .iter()
+        .filter(|(u, _)| *u == universe)
+        .map(|(_, at)| *at)
+        .collect();
+    times.windows(2).map(|w| w[1] - w[0]).collect()
+}
+
+#[tokio::test(start_paused = true)]
+async fn each_universe_goes_out_at_its_own_rate() {
+    let sends = Arc::new(Mutex::new(Vec::new()));
+    let mut module = DmxModule::with_driver(Box::new(Timed {
+        sends: sends.clone(),
+    }));
+    module.set_target_fps(40.0);
+    // A wireless link
+    module.set_universe_fps(2, 20.0);
+    // Resend every tick, changed or not
+    module.set_keep_alive(Some(Duration::ZERO));
+    module.initialize().await.unwrap();
+
+    let (event_tx, event_rx) = mpsc::channel(16);
+    let (message_tx, mut message_rx) = mpsc::channel::<ModuleMessage>(16);
+    tokio::spawn(async move { while message_rx.recv().await.is_some() {} });
+    let handle = tokio::spawn(async move { module.run(event_rx, message_tx).await.unwrap() });
+    for universe in [1, 2] {
+        event_tx
+            .send(ModuleEvent::DmxOutput(universe, vec![universe; 512]))
+            .await
+            .unwrap();
+    }
+    tokio::time::sleep(Duration::from_secs(1)).await;
+    event_tx.send(ModuleEvent::Shutdown).await.unwrap();
+    handle.await.unwrap();
+
+    let sends = sends.lock().unwrap().clone();
+    // Give or take the timer's millisecond
+    let about = |gap: &Duration, ms: u64| {
+        gap.abs_diff(Duration::from_millis(ms)) <= Duration::from_millis(1)
+    };
+    let wired = gaps(&sends, 1);
+    let wireless = gaps(&sends, 2);
+    assert!(wired.iter().all(|gap| about(gap, 25)), "{wired:?}");
+    assert!(wireless.iter().all(|gap| about(gap, 50)), "{wireless:?}");
+    assert!((39..=41).contains(&wired.len()), "{} gaps", wired.len());
+    assert!(
+        (19..=21).contains(&wireless.len()),
+        "{} gaps",
+        wireless.len()
+    );
+}
+
+#[tokio::test]
+async fn a_capture_holds_exactly_what_went_out() {
+    let dir = tempfile::tempdir().unwrap();
+    let path = dir.path().join("rehearsal.dmxcap");
+    let calls = Arc::new(Mutex::new(Vec::new()));
+    let mut module = DmxModule::with_driver(Box::new(Recorder {
+        calls: calls.clone(),
+        failing: vec![],
+    }));
+    let capture = OutputCapture::new();
+    module.set_capture(capture.clone());
+    module.set_universe_fps(2, 20.0);
+    capture.set_show_hash(Some("5f3c9a"));
+    capture.start(&path).unwrap();
+    module.initialize().await.unwrap();
+
+    let (event_tx, event_rx) = mpsc::channel(16);
+    let (message_tx, mut message_rx) = mpsc::channel::<ModuleMessage>(16);
+    tokio::spawn(async move { while message_rx.recv().await.is_some() {} });
+    let handle = tokio::spawn(async move { module.run(event_rx, message_tx).await.unwrap() });
+    let ramp: Vec<u8> = (0..=255).chain((0..=255).rev()).collect();
+    for (universe, data) in [(1, ramp.clone()), (2, vec![7; 512]), (1, vec![255; 24])] {
+        event_tx
+            .send(ModuleEvent::DmxOutput(universe, data))
+            .await
+            .unwrap();
+        tokio::time::sleep(Duration::from_millis(60)).await;
+    }
+    assert_eq!(capture.stop(), Some(path.clone()));
+    // Nothing more is captured once stopped
+    event_tx
+        .send(ModuleEvent::DmxOutput(1, vec![1; 512]))
+        .await
+        .unwrap();
+    tokio::time::sleep(Duration::from_millis(50)).await;
+    event_tx.send(ModuleEvent::Shutdown).await.unwrap();
+    handle.await.unwrap();
+
+    let capture = Capture::read(&path).unwrap();
+    assert_eq!(capture.header.rate_hz, 44.0);
+    assert_eq!(capture.header.universe_rates.get(&2), Some(&20.0));
+    assert_eq!(capture.header.universes, [1]);
+    // Traceable to the build that made it and the show it played
+    assert_eq!(capture.header.build, Some(BuildInfo::current()));
+    assert_eq!(capture.header.show_hash.as_deref(), Some("5f3c9a"));
+
+    let sent: Vec<(u8, Vec<u8>)> = calls
+        .lock()
+        .unwrap()
+        .iter()
+        .filter_map(|c| match c {
+            Call::Send(universe, data) => Some((*universe, data.clone())),
+            _ => None,
+        })
+        .collect();
+    let captured: Vec<(u8, Vec<u8>)> = capture
+        .frames
+        .iter()
+        .map(|f| (f.universe, f.data.clone()))
+        .collect();
+    assert_eq!(captured, sent[..captured.len()]);
+    assert_eq!(sent.last(), Some(&(1, vec![1; 512])));
+    assert!(!captured.contains(&(1, vec![1; 512])));
+    assert!(captured.contains(&(1, vec![255; 24])));
+    assert!(capture
+        .frames
+        .windows(2)
+        .all(|w| w[0].micros <= w[1].micros));
+}
+
+/// A capture of universe 1 changing every 100ms and universe 2 once, at 50ms
+fn rehearsal() -> Capture {
+    let frame = |millis: u64, universe: u8, level: u8| CapturedFrame {
+        micros: millis * 1000,
+        universe,
+        data: vec![level; 8],
+    };
+    Capture {
+        header: OutputCapture::header(44.0, Default::default(), vec![1, 2]),
+        frames: vec![
+            frame(0, 1, 0),
+            frame(50, 2, 9),
+            frame(100, 1, 10),
+            frame(200, 1, 20),
+            frame(300, 1, 30),
+        ],
+    }
+}
+
+/// Check each send went out `millis` after `start`, give or take the timer's millisecond
+fn assert_sent_at(
+    sends: &[(u8, tokio::time::Instant)],
+    start: tokio::time::Instant,
+    millis: &[u64],
+) {
+    let at: Vec<Duration> = sends.iter().map(|(_, t)| *t - start).collect();
+    assert_eq!(at.len(), millis.len(), "{at:?}");
+    for (at, ms) in at.iter().zip(millis) {
+        assert!(
+            at.abs_diff(Duration::from_millis(*ms)) <= Duration::from_millis(1),
+            "{at:?}"
+        );
+    }
+}
+
+#[tokio::test(start_paused = true)]
+async fn a_capture_plays_back_with_its_timing_at_any_speed() {
+    let calls = Arc::new(Mutex::new(Vec::new()));
+    let mut recorder = Recorder {
+        calls: calls.clone(),
+        failing: vec![],
+    };
+    let capture = rehearsal();
+    assert_eq!(
+        capture
+            .play(&mut recorder, 1.0, Duration::ZERO)
+            .await
+            .unwrap(),
+        5
+    );
+    let calls = calls.lock().unwrap().clone();
+    assert_eq!(calls.first(), Some(&Call::Open));
+    assert_eq!(calls.last(), Some(&Call::Close));
+    let sent: Vec<Call> = capture
+        .frames
+        .iter()
+        .map(|f| Call::Send(f.universe, f.data.clone()))
+        .collect();
+    assert_eq!(calls[1..calls.len() - 1], sent);
+
+    let sends = Arc::new(Mutex::new(Vec::new()));
+    let mut timed = Timed {
+        sends: sends.clone(),
+    };
+    let start = tokio::time::Instant::now();
+    capture.play(&mut timed, 2.0, Duration::ZERO).await.unwrap();
+    let sends = sends.lock().unwrap().clone();
+    assert_sent_at(&sends, start, &[0, 25, 50, 100, 150]);
+}
+
+#[tokio::test(start_paused = true)]
+async fn playing_from_part_way_sets_each_universe_as_it_stood_first() {
+    let capture = rehearsal();
+    let levels: Vec<(u8, u8)> = capture
+        .frames_from(Duration::from_millis(150))
+        .iter()
+        .map(|f| (f.universe, f.data[0]))
+        .collect();
+    assert_eq!(levels, [(1, 10), (2, 9), (1, 20), (1, 30)]);
+
+    let sends = Arc::new(Mutex::new(Vec::new()));
+    let mut timed = Timed {
+        sends: sends.clone(),
+    };
+    let start = tokio::time::Instant::now();
+    capture
+        .play(&mut timed, 1.0, Duration::from_millis(150))
+        .await
+        .unwrap();
+    let sends = sends.lock().unwrap().clone();
+    assert_sent_at(&sends, start, &[0, 0, 50, 150]);
+}
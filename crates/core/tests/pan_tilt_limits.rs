@@ -0,0 +1,133 @@
+mod harness;
+
+use std::sync::{Arc, Mutex};
+use std::time::Duration;
+
+use halo_core::{
+    auto_patch, ConsoleCommand, Effect, EffectContext, EffectDistribution, EffectMapping,
+    EffectRegistry, EffectRelease, EffectSource, PatchSpec,
+};
+use halo_fixtures::{ChannelType, FixtureLibrary, PanTiltLimits};
+use harness::Harness;
+
+/// Sends every fixture to the level the test sets, standing in for an oscillator
+struct Level(Arc<Mutex<f64>>);
+
+impl EffectSource for Level {
+    fn values(&mut self, _context: &EffectContext, fixture_ids: &[usize]) -> Vec<f64> {
+        vec![*self.0.lock().unwrap(); fixture_ids.len()]
+    }
+}
+
+/// The spot show with a pan and tilt effect under the test's control running from Open
+async fn spot_moving(level: Arc<Mutex<f64>>) -> Harness {
+    let mut harness = Harness::new().await;
+    let mut registry = EffectRegistry::new();
+    registry.register("Level", move |_: &Effect| -> Box<dyn EffectSource> {
+        Box::new(Level(Arc::clone(&level)))
+    });
+    harness.console.set_effect_registry(registry).await;
+    harness.run_step("load spot.json").await.unwrap();
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues[0].effects.push(EffectMapping {
+        name: "Sweep".to_string(),
+        effect: Effect {
+            source: Some("level".to_string()),
+            ..Effect::default()
+        },
+        fixture_ids: vec![0],
+        channel_types: vec![ChannelType::Pan, ChannelType::Tilt],
+        distribution: EffectDistribution::All,
+        release: EffectRelease::Hold,
+    });
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+    harness.run_step("goto 0 0").await.unwrap();
+    harness
+}
+
+/// Pan and tilt with the effect at each of `levels`
+async fn sweep(harness: &mut Harness, level: &Mutex<f64>, levels: &[f64]) -> Vec<(u8, u8)> {
+    let mut seen = Vec::new();
+    for value in levels {
+        *level.lock().unwrap() = *value;
+        harness.advance(Duration::from_millis(50)).await.unwrap();
+        let fixtures = harness.console.fixtures.read().await;
+        let spot = &fixtures[0];
+        seen.push((
+            spot.channel_value(&ChannelType::Pan).unwrap(),
+            spot.channel_value(&ChannelType::Tilt).unwrap(),
+        ));
+    }
+    seen
+}
+
+#[tokio::test]
+async fn effects_sweep_the_whole_range_without_limits() {
+    let level = Arc::new(Mutex::new(0.0));
+    let mut harness = spot_moving(Arc::clone(&level)).await;
+    let seen = sweep(&mut harness, &level, &[0.0, 0.5, 1.0]).await;
+    assert_eq!(seen, [(0, 0), (127, 127), (255, 255)]);
+}
+
+#[tokio::test]
+async fn effects_are_scaled_into_the_limits_rather_than_held_against_them() {
+    let level = Arc::new(Mutex::new(0.0));
+    let mut harness = spot_moving(Arc::clone(&level)).await;
+    harness
+        .command(ConsoleCommand::SetPanTiltLimits {
+            fixture_id: 0,
+            pan_min: 50,
+            pan_max: 150,
+            tilt_min: 100,
+            tilt_max: 200,
+        })
+        .await
+        .unwrap();
+
+    let seen = sweep(&mut harness, &level, &[0.0, 0.25, 0.5, 0.75, 1.0]).await;
+    assert_eq!(
+        seen,
+        [(50, 100), (75, 125), (100, 150), (125, 175), (150, 200)]
+    );
+
+    // Values set directly are clamped
+    let mut fixtures = harness.console.fixtures.write().await;
+    fixtures[0].set_channel_value(&ChannelType::Tilt, 10);
+    assert_eq!(fixtures[0].channel_value(&ChannelType::Tilt), Some(100));
+}
+
+#[test]
+fn the_patch_carries_limits_onto_the_fixture() {
+    let spec = |pan_tilt_limits| PatchSpec {
+        name: "Wash".to_string(),
+        profile_id: "shehds-led-spot-60w".to_string(),
+        address: None,
+        mode: None,
+        pan_tilt_limits,
+    };
+    let limits = PanTiltLimits {
+        pan_min: 0,
+        pan_max: 255,
+        tilt_min: 0,
+        tilt_max: 120,
+    };
+    let plan = auto_patch(
+        &[spec(Some(limits)), spec(None)],
+        &[1],
+        &FixtureLibrary::new(),
+    )
+    .unwrap();
+
+    let mut limited = plan.fixtures[0].clone();
+    limited.set_channel_value(&ChannelType::Tilt, 255);
+    assert_eq!(limited.channel_value(&ChannelType::Tilt), Some(120));
+    assert_eq!(limited.limited_range(&ChannelType::Tilt, 0, 255), (0, 120));
+
+    let mut free = plan.fixtures[1].clone();
+    free.set_channel_value(&ChannelType::Tilt, 255);
+    assert_eq!(free.channel_value(&ChannelType::Tilt), Some(255));
+    assert_eq!(free.limited_range(&ChannelType::Tilt, 0, 255), (0, 255));
+}
@@ -0,0 +1,172 @@
+use halo_core::{
+    auto_patch, demo_patch, patch_conflicts, patch_sheet, FixtureDescription, PatchAddress,
+    PatchReport, PatchSpec, ReportFormat,
+};
+use halo_fixtures::{ChannelType, Fixture, FixtureLibrary};
+
+const PROFILES: [&str; 6] = [
+    "shehds-rgbw-par",
+    "shehds-led-spot-60w",
+    "shehds-led-wash-7x18w-rgbwa-uv",
+    "shehds-mini-led-pinspot-10w",
+    "shehds-led-bar-beam-8x12w",
+    "generic-rgb-pixel-bar-30",
+];
+
+/// 30 fixtures cycling through the profiles, with the first spot pinned to 2.101
+fn rig() -> Vec<PatchSpec> {
+    (0..30)
+        .map(|i| PatchSpec {
+            name: format!("Fixture {i}"),
+            profile_id: PROFILES[i % PROFILES.len()].to_string(),
+            address: (i == 1).then_some(PatchAddress {
+                universe: 2,
+                start_address: 101,
+            }),
+            mode: None,
+            pan_tilt_limits: None,
+        })
+        .collect()
+}
+
+#[test]
+fn auto_patch_fills_two_universes_without_overlaps() {
+    let library = FixtureLibrary::new();
+    let plan = auto_patch(&rig(), &[1, 2], &library).unwrap();
+
+    assert_eq!(plan.fixtures.len(), 30);
+    assert!(patch_conflicts(&plan.fixtures).is_empty());
+    assert!(plan
+        .fixtures
+        .iter()
+        .all(|f| f.universe == 1 || f.universe == 2));
+    assert!(plan.fixtures.iter().any(|f| f.universe == 2 && f.id != 1));
+
+    let spot = &plan.fixtures[1];
+    assert_eq!((spot.universe, spot.start_address), (2, 101));
+
+    // Every fixture fits inside its universe and the free counts match what was used
+    for universe in [1, 2] {
+        let used: usize = plan
+            .fixtures
+            .iter()
+            .filter(|f| f.universe == universe)
+            .inspect(|f| assert!(f.start_address as usize + f.channels.len() - 1 <= 512))
+            .map(|f| f.channels.len())
+            .sum();
+        assert_eq!(plan.free_channels[&universe] as usize, 512 - used);
+    }
+
+    // The same rig always gets the same patch
+    let again = auto_patch(&rig(), &[1, 2], &library).unwrap();
+    assert_eq!(patch_sheet(&plan.fixtures), patch_sheet(&again.fixtures));
+}
+
+#[test]
+fn pinned_fixtures_must_not_overlap() {
+    let mut specs = rig();
+    specs[6].address = Some(PatchAddress {
+        universe: 2,
+        start_address: 105,
+    });
+
+    let err = auto_patch(&specs, &[1, 2], &FixtureLibrary::new()).unwrap_err();
+    assert!(
+        err.contains("Fixture 1") && err.contains("Fixture 6"),
+        "{err}"
+    );
+}
+
+#[test]
+fn auto_patch_reports_a_full_rig() {
+    let err = auto_patch(&rig(), &[1], &FixtureLibrary::new()).unwrap_err();
+    assert!(err.starts_with("No room for"), "{err}");
+}
+
+#[test]
+fn patch_sheet_lists_address_ranges() {
+    let specs = vec![PatchSpec {
+        name: "Par".to_string(),
+        profile_id: "shehds-rgbw-par".to_string(),
+        address: None,
+        mode: None,
+        pan_tilt_limits: None,
+    }];
+    let plan = auto_patch(&specs, &[1], &FixtureLibrary::new()).unwrap();
+    let footprint = plan.fixtures[0].channels.len();
+
+    let sheet = patch_sheet(&plan.fixtures);
+    let line = sheet.lines().nth(1).unwrap();
+    assert!(line.starts_with("Par"), "{sheet}");
+    assert!(line.contains(&format!("1-{footprint}")), "{sheet}");
+}
+
+#[test]
+fn channel_map_follows_the_profile_from_the_start_address() {
+    let library = FixtureLibrary::new();
+    let profile = library.profiles["shehds-led-wash-7x18w-rgbwa-uv"].clone();
+    let wash = Fixture::new(
+        3,
+        "Right Wash",
+        profile.clone(),
+        profile.channel_layout.clone(),
+        1,
+        55,
+    );
+
+    let map = wash.channel_map();
+    assert_eq!(map.len(), profile.channel_layout.len());
+    for (offset, (channel, (name, address))) in profile.channel_layout.iter().zip(&map).enumerate()
+    {
+        assert_eq!(name, &channel.name);
+        assert_eq!(*address, 55 + offset as u16);
+    }
+    assert_eq!(wash.channel_address(&ChannelType::Blue), Some(60));
+    assert_eq!(wash.channel_address(&ChannelType::Gobo), None);
+
+    let description = FixtureDescription::new(&wash, false);
+    assert_eq!(
+        (description.start_address, description.end_address),
+        (55, 64)
+    );
+    assert!(description.to_string().contains(" 60  Blue "));
+}
+
+#[test]
+fn the_demo_rig_patch_report_matches_the_golden_file() {
+    let plan = auto_patch(&demo_patch(), &[1], &FixtureLibrary::new()).unwrap();
+    let report = PatchReport::new("Halo Demo", &plan.fixtures, &plan.auto_patched);
+
+    assert_eq!(
+        report.render(ReportFormat::Markdown),
+        include_str!("testdata/demo_patch_sheet.md")
+    );
+}
+
+#[test]
+fn patch_reports_mark_pinned_fixtures_and_escape_names() {
+    let mut specs = rig()[..2].to_vec();
+    specs[0].name = "Wash, \"DSL\" <front>".to_string();
+    let plan = auto_patch(&specs, &[1, 2], &FixtureLibrary::new()).unwrap();
+    let report = PatchReport::new("Venue", &plan.fixtures, &plan.auto_patched);
+
+    let csv = report.render(ReportFormat::Csv);
+    let mut lines = csv.lines();
+    assert_eq!(
+        lines.next(),
+        Some("Type,Fixture,ID,Universe,Address,Profile,Mode,Position,Home (pan/tilt),Patch")
+    );
+    assert!(csv.contains("MovingHead,Fixture 1,1,2,101-109,"), "{csv}");
+    assert!(csv.contains(",fixed\n"), "{csv}");
+    assert!(
+        csv.contains("PAR,\"Wash, \"\"DSL\"\" <front>\",0,1,1-8,"),
+        "{csv}"
+    );
+
+    let html = report.render(ReportFormat::Html);
+    assert!(
+        html.contains("<td>Wash, &quot;DSL&quot; &lt;front&gt;</td>"),
+        "{html}"
+    );
+    assert!(html.contains("<h2>MovingHead</h2>"), "{html}");
+}
@@ -0,0 +1,63 @@
+mod harness;
+
+use std::collections::HashMap;
+use std::time::Duration;
+
+use halo_core::{ConsoleCommand, PositionPresets, Settings};
+use halo_fixtures::PanTilt;
+use harness::Harness;
+
+fn venue(pan: u8, tilt: u8) -> PositionPresets {
+    HashMap::from([(
+        "dj_booth".to_string(),
+        HashMap::from([("Spot".to_string(), PanTilt { pan, tilt })]),
+    )])
+}
+
+/// Run the DJ Booth cue from the spot show with the given venue presets
+async fn run_at_venue(position_presets: PositionPresets) -> Harness {
+    let mut harness = Harness::new().await;
+    harness
+        .command(ConsoleCommand::UpdateSettings {
+            settings: Settings {
+                position_presets,
+                ..Settings::default()
+            },
+        })
+        .await
+        .unwrap();
+    harness.run_step("load spot.json").await.unwrap();
+    harness.run_step("goto 0 0").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness
+}
+
+async fn cue_data(harness: &Harness) -> String {
+    let cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    serde_json::to_string(&cue_lists).unwrap()
+}
+
+#[tokio::test]
+async fn same_cue_follows_each_venues_presets() {
+    let mut club = run_at_venue(venue(40, 200)).await;
+    club.run_step("expect dmx 1 1 40").await.unwrap();
+    club.run_step("expect dmx 1 2 200").await.unwrap();
+
+    let mut festival = run_at_venue(venue(220, 30)).await;
+    festival.run_step("expect dmx 1 1 220").await.unwrap();
+    festival.run_step("expect dmx 1 2 30").await.unwrap();
+
+    assert_eq!(cue_data(&club).await, cue_data(&festival).await);
+}
+
+#[tokio::test]
+async fn missing_preset_entries_fall_back_to_home() {
+    let mut harness = run_at_venue(PositionPresets::new()).await;
+
+    harness.run_step("expect dmx 1 1 128").await.unwrap();
+    harness.run_step("expect dmx 1 2 128").await.unwrap();
+    // The rest of the cue still plays
+    harness.run_step("expect dmx 1 6 255").await.unwrap();
+}
@@ -0,0 +1,410 @@
+use std::collections::BTreeMap;
+use std::path::PathBuf;
+
+use halo_core::{auto_patch, PatchSpec, Show};
+use halo_fixtures::{
+    channel_layout, Channel, ChannelType, ColorCalibration, FixtureLibrary, FixtureType,
+    ProfileDefinition, StrobeRange,
+};
+
+fn channel_names(channels: &[Channel]) -> Vec<&str> {
+    channels.iter().map(|c| c.name.as_str()).collect()
+}
+
+/// A wash with a base layout, a variant that extends it and a variant of that variant
+fn wash_definitions() -> Vec<ProfileDefinition> {
+    vec![
+        ProfileDefinition {
+            id: "wash".to_string(),
+            fixture_type: Some(FixtureType::Wash),
+            manufacturer: Some("Shehds".to_string()),
+            model: Some("LED Wash".to_string()),
+            channels: channel_layout![
+                ("Dimmer", ChannelType::Dimmer),
+                ("Red", ChannelType::Red),
+                ("Green", ChannelType::Green),
+                ("Blue", ChannelType::Blue),
+                ("Function", ChannelType::Other("Function".to_string())),
+            ],
+            modes: BTreeMap::from([(3, channel_layout![("Red", ChannelType::Red)])]),
+            ..ProfileDefinition::default()
+        },
+        ProfileDefinition {
+            id: "wash-uv".to_string(),
+            extends: Some("wash".to_string()),
+            model: Some("LED Wash UV".to_string()),
+            channels: channel_layout![("Function", ChannelType::Function), ("UV", ChannelType::UV),],
+            strobe: Some(StrobeRange {
+                max_hz: 12.0,
+                ..StrobeRange::default()
+            }),
+            ..ProfileDefinition::default()
+        },
+        ProfileDefinition {
+            id: "wash-uv-amber".to_string(),
+            extends: Some("wash-uv".to_string()),
+            channels: channel_layout![("Amber", ChannelType::Amber)],
+            modes: BTreeMap::from([(3, channel_layout![("Amber", ChannelType::Amber)])]),
+            ..ProfileDefinition::default()
+        },
+    ]
+}
+
+#[test]
+fn child_profiles_override_their_parents() {
+    let library = FixtureLibrary::from_definitions(wash_definitions()).unwrap();
+
+    let uv = &library.profiles["wash-uv"];
+    assert_eq!(uv.manufacturer, "Shehds");
+    assert_eq!(uv.model, "LED Wash UV");
+    assert_eq!(uv.fixture_type, FixtureType::Wash);
+    assert_eq!(
+        channel_names(&uv.channel_layout),
+        ["Dimmer", "Red", "Green", "Blue", "Function", "UV"]
+    );
+    // Overridden channels keep their place in the parent's layout
+    assert_eq!(uv.channel_layout[4].channel_type, ChannelType::Function);
+    assert_eq!(uv.strobe_range().max_hz, 12.0);
+
+    // The closest definition wins
+    let amber = &library.profiles["wash-uv-amber"];
+    assert_eq!(amber.model, "LED Wash UV");
+    assert_eq!(
+        channel_names(&amber.channel_layout),
+        ["Dimmer", "Red", "Green", "Blue", "Function", "UV", "Amber"]
+    );
+    assert_eq!(channel_names(&amber.layout(Some(3)).unwrap()), ["Amber"]);
+    assert_eq!(
+        channel_names(&library.profiles["wash"].layout(Some(3)).unwrap()),
+        ["Red"]
+    );
+
+    // The base profile is untouched by its children
+    assert_eq!(library.profiles["wash"].channel_layout.len(), 5);
+}
+
+#[test]
+fn inheritance_cycles_are_rejected() {
+    let mut definitions = wash_definitions();
+    definitions[0].extends = Some("wash-uv-amber".to_string());
+
+    let error = FixtureLibrary::from_definitions(definitions).unwrap_err();
+    assert_eq!(
+        error,
+        "Profile inheritance cycle: wash -> wash-uv-amber -> wash-uv -> wash"
+    );
+
+    let error = FixtureLibrary::from_definitions(vec![ProfileDefinition {
+        id: "orphan".to_string(),
+        extends: Some("missing".to_string()),
+        ..ProfileDefinition::default()
+    }])
+    .unwrap_err();
+    assert_eq!(error, "Profile orphan extends unknown profile missing");
+}
+
+#[test]
+fn patch_mode_selects_the_channel_layout() {
+    let library = FixtureLibrary::new();
+    let spec = |mode| PatchSpec {
+        name: "Strobe".to_string(),
+        profile_id: "hyulights-led-rgbw-4in1-48-partition-strobe".to_string(),
+        address: None,
+        mode,
+        pan_tilt_limits: None,
+    };
+
+    let plan = auto_patch(&[spec(None), spec(Some(6)), spec(Some(12))], &[1], &library).unwrap();
+    let footprints: Vec<usize> = plan.fixtures.iter().map(|f| f.channels.len()).collect();
+    assert_eq!(footprints, [6, 6, 12]);
+    assert_eq!(plan.fixtures[2].mode, Some(12));
+
+    let error = auto_patch(&[spec(Some(8))], &[1], &library).unwrap_err();
+    assert!(error.contains("has no mode 8"), "{error}");
+}
+
+#[test]
+fn profile_files_add_to_and_override_the_built_in_library() {
+    let dir = tempfile::tempdir().unwrap();
+    std::fs::write(
+        dir.path().join("acme-spot.json"),
+        r#"{
+  "id": "acme-spot-100",
+  "fixture_type": "MovingHead",
+  "manufacturer": "Acme",
+  "model": "Spot 100",
+  "channel_count": 6,
+  "channels": { "Pan": 1, "Tilt": 2, "Gobo": 4, "dimmer": 6 },
+  "color_calibration": { "gain": [1.0, 0.8, 0.9] },
+  "color_wheel": [
+    { "name": "White", "value": 0, "rgb": [255, 255, 255] },
+    { "name": "Red", "value": 10, "rgb": [255, 0, 0] }
+  ]
+}"#,
+    )
+    .unwrap();
+    std::fs::write(
+        dir.path().join("par.json"),
+        r#"{
+  "id": "shehds-rgbw-par",
+  "fixture_type": "PAR",
+  "channel_count": 2,
+  "channels": { "Dimmer": 1, "Haze": 2 }
+}"#,
+    )
+    .unwrap();
+    std::fs::write(
+        dir.path().join("broken.json"),
+        "{\n  \"id\": \"broken\",\n  \"channel_count\": \"six\"\n}",
+    )
+    .unwrap();
+    std::fs::write(
+        dir.path().join("overlap.json"),
+        r#"{
+  "id": "overlap",
+  "channel_count": 2,
+  "channels": {
+    "Red": 1,
+    "Green": 3
+  }
+}"#,
+    )
+    .unwrap();
+    std::fs::write(dir.path().join("notes.txt"), "not a profile").unwrap();
+
+    let mut library = FixtureLibrary::new();
+    let load = library.load_profiles(dir.path());
+    assert_eq!(load.loaded, ["acme-spot-100", "shehds-rgbw-par"]);
+
+    let spot = &library.profiles["acme-spot-100"];
+    assert_eq!(spot.fixture_type, FixtureType::MovingHead);
+    assert_eq!(spot.to_string(), "Acme Spot 100");
+    assert_eq!(
+        channel_names(&spot.channel_layout),
+        ["Pan", "Tilt", "Channel 3", "Gobo", "Channel 5", "dimmer"]
+    );
+    assert_eq!(spot.channel_layout[5].channel_type, ChannelType::Dimmer);
+    assert_eq!(
+        spot.color_calibration,
+        Some(ColorCalibration::Gain([1.0, 0.8, 0.9]))
+    );
+    let wheel: Vec<_> = spot.color_wheel.iter().map(|c| c.name.as_str()).collect();
+    assert_eq!(wheel, ["White", "Red"]);
+    assert_eq!(
+        spot.channel_layout[2].channel_type,
+        ChannelType::Other("Channel 3".to_string())
+    );
+
+    // A file replaces the built-in profile with the same ID
+    let par = &library.profiles["shehds-rgbw-par"];
+    assert_eq!(par.fixture_type, FixtureType::PAR);
+    assert_eq!(
+        par.channel_layout[1].channel_type,
+        ChannelType::Other("Haze".to_string())
+    );
+
+    // Malformed files are reported by name and line, and the rest still load
+    let errors: Vec<(PathBuf, Option<usize>)> = load
+        .errors
+        .iter()
+        .map(|e| {
+            (
+                e.path.strip_prefix(dir.path()).unwrap().to_path_buf(),
+                e.line,
+            )
+        })
+        .collect();
+    assert_eq!(
+        errors,
+        [
+            (PathBuf::from("broken.json"), Some(3)),
+            (PathBuf::from("overlap.json"), Some(6)),
+        ]
+    );
+    assert!(load.errors[1]
+        .to_string()
+        .ends_with("overlap.json:6: Green is on channel 3, outside 1 to 2"));
+    assert!(!library.profiles.contains_key("broken"));
+}
+
+#[test]
+fn profile_files_can_extend_other_profiles() {
+    let dir = tempfile::tempdir().unwrap();
+    // The variant sorts before the file it extends, so has to wait for it
+    std::fs::write(
+        dir.path().join("a-spot-uv.json"),
+        r#"{
+  "id": "acme-spot-uv",
+  "extends": "acme-spot",
+  "model": "Spot UV",
+  "channel_count": 5,
+  "channels": { "UV": 4, "Dimmer": 5 }
+}"#,
+    )
+    .unwrap();
+    std::fs::write(
+        dir.path().join("b-spot.json"),
+        r#"{
+  "id": "acme-spot",
+  "fixture_type": "MovingHead",
+  "manufacturer": "Acme",
+  "model": "Spot",
+  "channel_count": 4,
+  "channels": { "Pan": 1, "Tilt": 2, "Dimmer": 3, "Gobo": 4 },
+  "modes": { "2": { "Dimmer": 1, "Gobo": 2 } }
+}"#,
+    )
+    .unwrap();
+    std::fs::write(
+        dir.path().join("c-par.json"),
+        r#"{
+  "id": "shehds-rgbw-par-uv",
+  "extends": "shehds-rgbw-par",
+  "channels": { "UV": 8 }
+}"#,
+    )
+    .unwrap();
+    std::fs::write(
+        dir.path().join("d-orphan.json"),
+        "{\n  \"id\": \"orphan\",\n  \"extends\": \"missing\"\n}",
+    )
+    .unwrap();
+
+    let mut library = FixtureLibrary::new();
+    let load = library.load_profiles(dir.path());
+    assert_eq!(
+        load.loaded,
+        ["acme-spot", "acme-spot-uv", "shehds-rgbw-par-uv"]
+    );
+    assert!(load.warnings.is_empty(), "{:?}", load.warnings);
+
+    // The dimmer moves out of the parent's way, leaving its old channel to be named
+    let uv = &library.profiles["acme-spot-uv"];
+    assert_eq!(uv.to_string(), "Acme Spot UV");
+    assert_eq!(uv.fixture_type, FixtureType::MovingHead);
+    assert_eq!(
+        channel_names(&uv.channel_layout),
+        ["Pan", "Tilt", "Channel 3", "UV", "Dimmer"]
+    );
+    assert_eq!(
+        channel_names(&uv.layout(Some(2)).unwrap()),
+        ["Dimmer", "Gobo"]
+    );
+
+    // Built-in profiles can be extended too
+    let par = &library.profiles["shehds-rgbw-par-uv"];
+    assert_eq!(par.manufacturer, "Shehds");
+    assert_eq!(par.channel_layout.len(), 8);
+    assert_eq!(par.channel_layout[7].channel_type, ChannelType::UV);
+
+    assert_eq!(load.errors.len(), 1);
+    assert!(load.errors[0]
+        .to_string()
+        .ends_with("d-orphan.json:3: orphan extends unknown profile missing"));
+}
+
+#[test]
+fn built_in_pixel_bars_extend_each_other() {
+    let library = FixtureLibrary::new();
+    let bar = &library.profiles["generic-rgb-pixel-bar-144"];
+    assert_eq!(bar.to_string(), "Generic RGB Pixel Bar 144 Pixels");
+    assert_eq!(bar.fixture_type, FixtureType::PixelBar);
+    assert_eq!(bar.channel_layout.len(), 144 * 3);
+    assert_eq!(
+        bar.channel_layout[90].channel_type,
+        ChannelType::PixelRed(30)
+    );
+    assert_eq!(bar.channel_layout[431].name, "Pixel 144 Blue");
+
+    let clen = &library.profiles["clen-led-pixel-bar-64"];
+    assert_eq!(clen.manufacturer, "Clen");
+    assert_eq!(clen.channel_layout.len(), 64 * 3);
+}
+
+#[test]
+fn shows_read_with_a_library_find_its_profiles() {
+    let dir = tempfile::tempdir().unwrap();
+    let profiles = dir.path().join("profiles");
+    std::fs::create_dir(&profiles).unwrap();
+    std::fs::write(
+        profiles.join("acme-spot.json"),
+        r#"{
+  "id": "acme-spot-100",
+  "channel_count": 3,
+  "channels": { "Pan": 1, "Tilt": 2, "Dimmer": 3 }
+}"#,
+    )
+    .unwrap();
+    let show = dir.path().join("show.json");
+    let mut contents = Show::new("Acme".to_string());
+    contents.fixtures = serde_json::from_str(
+        r#"[{"id": 0, "name": "Spot", "profile_id": "acme-spot-100", "universe": 1, "start_address": 1}]"#,
+    )
+    .unwrap();
+    std::fs::write(&show, serde_json::to_string(&contents).unwrap()).unwrap();
+
+    let error = Show::read(&show).unwrap_err();
+    assert_eq!(error.to_string(), "Profile acme-spot-100 not found");
+
+    let mut library = FixtureLibrary::new();
+    assert!(library.load_profiles(&profiles).errors.is_empty());
+    let show = Show::read_with(&show, &library).unwrap();
+    assert_eq!(
+        channel_names(&show.fixtures[0].channels),
+        ["Pan", "Tilt", "Dimmer"]
+    );
+}
+
+#[test]
+fn profile_channel_maps_are_checked_when_they_load() {
+    let dir = tempfile::tempdir().unwrap();
+    let write = |name: &str, channels: &str| {
+        std::fs::write(
+            dir.path().join(name),
+            format!(
+                "{{\n  \"id\": \"{}\",\n  \"channel_count\": 4,\n  \"channels\": {{\n{channels}\n  }}\n}}",
+                name.trim_end_matches(".json")
+            ),
+        )
+        .unwrap();
+    };
+    write(
+        "collision.json",
+        "    \"Dimmer\": 1,\n    \"Red\": 2,\n    \"Strobe\": 2",
+    );
+    write("zero.json", "    \"Dimmer\": 0");
+    write("past-footprint.json", "    \"Dimmer\": 1,\n    \"Red\": 5");
+    write("gaps.json", "    \"Dimmer\": 1,\n    \"Blue\": 4");
+    write(
+        "whole.json",
+        "    \"Dimmer\": 1,\n    \"Red\": 2,\n    \"Green\": 3,\n    \"Blue\": 4",
+    );
+
+    let mut library = FixtureLibrary::new();
+    let load = library.load_profiles(dir.path());
+    assert_eq!(load.loaded, ["gaps", "whole"]);
+
+    let errors: Vec<String> = load.errors.iter().map(|e| e.message.clone()).collect();
+    assert_eq!(
+        errors,
+        [
+            // Both attributes are named
+            "Strobe and Red are both on channel 2",
+            "Red is on channel 5, outside 1 to 4",
+            "Dimmer is on channel 0, outside 1 to 4",
+        ]
+    );
+    assert_eq!(load.errors[0].line, Some(7));
+
+    // Gaps still load, but are flagged as a likely wrong mode
+    assert_eq!(load.warnings.len(), 1);
+    assert!(load.warnings[0].path.ends_with("gaps.json"));
+    assert_eq!(
+        load.warnings[0].message,
+        "Channels 2, 3 of 4 have nothing mapped, check channel_count is for the right mode"
+    );
+    assert_eq!(
+        channel_names(&library.profiles["gaps"].channel_layout),
+        ["Dimmer", "Channel 2", "Channel 3", "Blue"]
+    );
+}
@@ -0,0 +1,75 @@
+use std::sync::{Arc, Mutex};
+use std::time::Duration;
+
+use halo_core::{
+    spawn_pinned, AsyncModule, DmxModule, ModuleEvent, ModuleMessage, NullDriver, TickHistogram,
+};
+use tokio::sync::mpsc;
+
+#[test]
+fn tick_times_are_counted_into_buckets() {
+    let ticks = TickHistogram::new();
+    assert_eq!(ticks.count(), 0);
+    assert_eq!(ticks.mean(), Duration::ZERO);
+
+    for _ in 0..98 {
+        ticks.record(Duration::from_micros(1500));
+    }
+    ticks.record(Duration::from_millis(22));
+    ticks.record(Duration::from_millis(75));
+
+    assert_eq!(ticks.count(), 100);
+    assert_eq!(ticks.max(), Duration::from_millis(75));
+    // Half of ticks came in under 2ms, and all but one under 25ms
+    assert_eq!(ticks.percentile(50.0), Duration::from_millis(2));
+    assert_eq!(ticks.percentile(99.0), Duration::from_millis(25));
+    // The slowest past the last bucket gives the longest tick
+    assert_eq!(ticks.percentile(100.0), Duration::from_millis(75));
+    assert_eq!(ticks.over(Duration::from_millis(20)), 2);
+    assert_eq!(ticks.over(Duration::from_millis(50)), 1);
+    assert_eq!(ticks.buckets().iter().map(|(_, n)| n).sum::<u64>(), 100);
+    assert!(ticks.to_string().starts_with("100 ticks"), "{ticks}");
+}
+
+#[tokio::test]
+async fn a_pinned_task_runs_on_a_thread_of_its_own() {
+    let ran_on = Arc::new(Mutex::new(None));
+    let seen = ran_on.clone();
+    let handle = spawn_pinned("halo-test", None, async move {
+        // Timers work on the thread's own runtime
+        tokio::time::sleep(Duration::from_millis(10)).await;
+        *seen.lock().unwrap() = std::thread::current().name().map(str::to_string);
+    })
+    .unwrap();
+    handle.await.unwrap();
+    assert_eq!(ran_on.lock().unwrap().as_deref(), Some("halo-test"));
+}
+
+#[tokio::test]
+async fn a_niceness_the_thread_cant_have_doesnt_stop_it() {
+    // Lower than an unprivileged process may go, so it carries on at normal priority
+    let handle = spawn_pinned("halo-test", Some(-100), async {}).unwrap();
+    handle.await.unwrap();
+}
+
+#[tokio::test]
+async fn the_dmx_module_times_its_ticks() {
+    let mut module = DmxModule::with_driver(Box::new(NullDriver));
+    let stats = module.stats();
+    module.initialize().await.unwrap();
+
+    let (event_tx, event_rx) = mpsc::channel(16);
+    let (message_tx, mut message_rx) = mpsc::channel::<ModuleMessage>(16);
+    tokio::spawn(async move { while message_rx.recv().await.is_some() {} });
+    let handle = tokio::spawn(async move { module.run(event_rx, message_tx).await.unwrap() });
+    event_tx
+        .send(ModuleEvent::DmxOutput(1, vec![255; 512]))
+        .await
+        .unwrap();
+    tokio::time::sleep(Duration::from_millis(200)).await;
+    event_tx.send(ModuleEvent::Shutdown).await.unwrap();
+    handle.await.unwrap();
+
+    assert!(stats.ticks().count() >= 5, "{}", stats.ticks());
+    assert!(stats.ticks().max() < Duration::from_millis(50));
+}
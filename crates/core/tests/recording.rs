@@ -0,0 +1,197 @@
+mod harness;
+
+use std::path::Path;
+use std::time::Duration;
+
+use halo_core::{show_file_hash, BuildInfo, ChannelChange, MusicalPosition, Recording};
+use harness::Harness;
+
+/// two_pars.json at 120 BPM, recorded with Left Red going on the third beat and Right Half on
+/// the second beat of the second bar
+async fn record_show(path: &std::path::Path) -> Recording {
+    let mut harness = Harness::new().await;
+    harness.console.record_dmx(Some(path)).await.unwrap();
+    harness.run_step("load two_pars.json").await.unwrap();
+    harness.advance(Duration::from_millis(1100)).await.unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(1500)).await.unwrap();
+    harness.run_step("go").await.unwrap();
+    harness.advance(Duration::from_secs(1)).await.unwrap();
+    harness.console.record_dmx(None).await.unwrap();
+    Recording::read(path).unwrap()
+}
+
+fn position(s: &str) -> MusicalPosition {
+    s.parse().unwrap()
+}
+
+#[tokio::test]
+async fn the_diff_at_a_beat_shows_what_changed_over_it() {
+    let dir = tempfile::tempdir().unwrap();
+    let recording = record_show(&dir.path().join("show.dmxrec")).await;
+    assert_eq!(recording.header.show, "Two PARs");
+
+    let left_red = recording.diff_at(position("1.1.3"));
+    assert_eq!(
+        left_red.changes,
+        [
+            ChannelChange {
+                universe: 1,
+                address: 1,
+                channel: Some("Left PAR Dimmer".to_string()),
+                before: 0,
+                after: 255,
+            },
+            ChannelChange {
+                universe: 1,
+                address: 2,
+                channel: Some("Left PAR Red".to_string()),
+                before: 0,
+                after: 255,
+            },
+        ]
+    );
+    assert_eq!(left_red.start, Some(Duration::from_millis(1100)));
+
+    let right_half = recording.diff_at(position("1.2.2"));
+    let changed: Vec<(u16, u8, u8)> = right_half
+        .changes
+        .iter()
+        .map(|c| (c.address, c.before, c.after))
+        .collect();
+    assert_eq!(changed, [(10, 0, 128)]);
+
+    let quiet = recording.diff_at(position("1.2.1"));
+    assert!(quiet.changes.is_empty());
+    assert_eq!(quiet.to_string(), "Nothing was recorded at 1.2.1\n");
+}
+
+#[tokio::test]
+async fn a_fixture_can_be_followed_over_time() {
+    let dir = tempfile::tempdir().unwrap();
+    let recording = record_show(&dir.path().join("show.dmxrec")).await;
+
+    let right = recording.fixture_named("right par").unwrap();
+    let history = recording.fixture_history(right, None);
+    assert_eq!(history.channels[0], "Dimmer");
+    assert_eq!(history.rows.len(), 1);
+    assert_eq!(history.rows[0].position, position("1.2.2"));
+    assert_eq!(history.rows[0].values[0], 128);
+
+    // Limited to a beat where the fixture didn't change
+    let left = recording.fixture_named("0").unwrap();
+    assert!(recording
+        .fixture_history(left, Some(position("1.2.2")))
+        .rows
+        .is_empty());
+}
+
+#[test]
+fn positions_are_counted_from_one() {
+    assert_eq!(MusicalPosition::from_beats(0.4, 4, 4), position("1.1.1"));
+    assert_eq!(MusicalPosition::from_beats(5.2, 4, 4), position("1.2.2"));
+    assert_eq!(MusicalPosition::from_beats(16.0, 4, 4), position("2.1.1"));
+    assert!("2.0.1".parse::<MusicalPosition>().is_err());
+    assert!("2.1".parse::<MusicalPosition>().is_err());
+}
+
+#[test]
+fn version_one_recordings_stay_readable() {
+    let dir = tempfile::tempdir().unwrap();
+    let path = dir.path().join("v1.dmxrec");
+    std::fs::write(
+        &path,
+        concat!(
+            r#"{"format":"halo-dmxrec","version":1,"show":"Old","fixtures":[{"id":0,"name":"Wash","universe":1,"channels":[["Dimmer",1]]}]}"#,
+            "\n",
+            r#"{"millis":0,"position":{"phrase":1,"bar":1,"beat":1},"changes":[[1,1,200]]}"#,
+            "\n",
+        ),
+    )
+    .unwrap();
+    let recording = Recording::read(&path).unwrap();
+    assert_eq!(recording.diff_at(position("1.1.1")).changes[0].after, 200);
+
+    let newer = std::fs::read_to_string(&path)
+        .unwrap()
+        .replace(r#""version":1"#, r#""version":99"#);
+    std::fs::write(&path, newer).unwrap();
+    assert!(Recording::read(&path).unwrap_err().contains("version 99"));
+}
+
+#[test]
+fn positions_that_jump_back_are_found_each_time() {
+    let dir = tempfile::tempdir().unwrap();
+    let path = dir.path().join("jumps.dmxrec");
+    let frame = |millis: u64, beat: u32, value: u8| {
+        format!(
+            r#"{{"millis":{millis},"position":{{"phrase":1,"bar":1,"beat":{beat}}},"changes":[[1,1,{value}]]}}"#
+        )
+    };
+    // Link moves the beat back half way through
+    let lines = [
+        r#"{"format":"halo-dmxrec","version":1,"show":"Jumps","fixtures":[{"id":0,"name":"Wash","universe":1,"channels":[["Dimmer",1]]}]}"#.to_string(),
+        frame(0, 1, 100),
+        frame(500, 2, 150),
+        frame(1000, 1, 50),
+        frame(1500, 2, 200),
+    ];
+    std::fs::write(&path, lines.join("\n")).unwrap();
+    let recording = Recording::read(&path).unwrap();
+
+    let diff = recording.diff_at(position("1.1.2"));
+    assert_eq!(diff.start, Some(Duration::from_millis(500)));
+    assert_eq!(diff.changes[0].before, 100);
+    assert_eq!(diff.changes[0].after, 150);
+    assert_eq!(diff.repeats, 1);
+    assert!(diff.to_string().contains("came back to 1.1.2 1 more time"));
+
+    let wash = recording.fixture_named("wash").unwrap();
+    let history = recording.fixture_history(wash, Some(position("1.1.2")));
+    let rows: Vec<(Duration, u8)> = history
+        .rows
+        .iter()
+        .map(|row| (row.time, row.values[0]))
+        .collect();
+    assert_eq!(
+        rows,
+        [
+            (Duration::from_millis(500), 150),
+            (Duration::from_millis(1500), 200)
+        ]
+    );
+}
+
+#[tokio::test]
+async fn positions_count_from_when_recording_started() {
+    let dir = tempfile::tempdir().unwrap();
+    let path = dir.path().join("late.dmxrec");
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    // Four beats go by at 120 BPM before recording starts
+    harness.advance(Duration::from_secs(2)).await.unwrap();
+    harness.console.record_dmx(Some(&path)).await.unwrap();
+    harness.advance(Duration::from_millis(1100)).await.unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.console.record_dmx(None).await.unwrap();
+
+    let recording = Recording::read(&path).unwrap();
+    let left_red = recording.diff_at(position("1.1.3"));
+    assert!(left_red
+        .changes
+        .iter()
+        .any(|c| c.address == 1 && c.after == 255));
+}
+
+#[tokio::test]
+async fn the_header_names_the_build_and_show_file() {
+    let dir = tempfile::tempdir().unwrap();
+    let recording = record_show(&dir.path().join("show.dmxrec")).await;
+    let show = Path::new(env!("CARGO_MANIFEST_DIR")).join("tests/testdata/two_pars.json");
+    assert_eq!(recording.header.build, Some(BuildInfo::current()));
+    assert_eq!(recording.header.show_hash, show_file_hash(&show).ok());
+    assert!(recording
+        .to_string()
+        .contains(&BuildInfo::current().to_string()));
+}
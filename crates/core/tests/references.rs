@@ -0,0 +1,97 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{ConsoleCommand, Cue, CueList, Settings};
+use harness::Harness;
+
+/// Left Red with a typo'd fixture id alongside the real one
+fn typo_cue_lists() -> Vec<CueList> {
+    vec![CueList {
+        name: "Main".to_string(),
+        cues: vec![
+            Cue::intensity_only("Dark", &[0, 1], 0, Duration::ZERO),
+            Cue::intensity_only("Up", &[0, 9, 12], 255, Duration::ZERO),
+        ],
+        audio_file: None,
+        default_fade: None,
+        default_values: vec![],
+        move_in_black: None,
+    }]
+}
+
+async fn two_pars(settings: Settings) -> Harness {
+    let mut harness = Harness::new().await;
+    harness
+        .command(ConsoleCommand::UpdateSettings { settings })
+        .await
+        .unwrap();
+    harness.run_step("load two_pars.json").await.unwrap();
+    harness
+}
+
+#[tokio::test]
+async fn strict_mode_rejects_missing_fixtures() {
+    let mut harness = two_pars(Settings::default()).await;
+
+    let error = harness
+        .command(ConsoleCommand::SetCueLists {
+            cue_lists: typo_cue_lists(),
+        })
+        .await
+        .unwrap_err();
+    assert!(error.starts_with("2 cue reference(s)"), "{error}");
+    assert!(error.contains("Cue 'Up' in 'Main' references missing fixture 9"));
+    assert!(error.contains("Cue 'Up' in 'Main' references missing fixture 12"));
+
+    // The show's own cues are still loaded
+    let cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    assert_eq!(cue_lists[0].cues[1].name, "Left Red");
+}
+
+#[tokio::test]
+async fn lenient_mode_strips_missing_fixtures_once() {
+    let mut harness = two_pars(Settings {
+        lenient_fixture_references: true,
+        ..Settings::default()
+    })
+    .await;
+
+    harness
+        .command(ConsoleCommand::SetCueLists {
+            cue_lists: typo_cue_lists(),
+        })
+        .await
+        .unwrap();
+    let cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    assert_eq!(cue_lists[0].cues[1].fixture_ids(), [0]);
+
+    // The rest of the cue runs as written
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+}
+
+#[tokio::test]
+async fn show_files_with_missing_fixtures_fail_to_load() {
+    let dir = tempfile::tempdir().unwrap();
+    let source =
+        std::path::Path::new(env!("CARGO_MANIFEST_DIR")).join("tests/testdata/two_pars.json");
+    let mut show: serde_json::Value =
+        serde_json::from_str(&std::fs::read_to_string(source).unwrap()).unwrap();
+    show["cue_lists"][0]["cues"][1]["static_values"][0]["fixture_id"] = serde_json::json!(4);
+    let path = dir.path().join("typo.json");
+    std::fs::write(&path, show.to_string()).unwrap();
+
+    let mut harness = Harness::new().await;
+    let error = harness
+        .console
+        .load_show(&path)
+        .await
+        .unwrap_err()
+        .to_string();
+    assert!(
+        error.contains("Cue 'Left Red' in 'Main' references missing fixture 4"),
+        "{error}"
+    );
+}
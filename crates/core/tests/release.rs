@@ -0,0 +1,126 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{
+    Attribute, ConsoleCommand, Cue, Effect, EffectDistribution, EffectMapping, EffectRelease,
+    Release, StaticValue,
+};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+
+fn value(channel_type: ChannelType, value: u8) -> StaticValue {
+    StaticValue {
+        fixture_id: 0,
+        channel_type,
+        value,
+    }
+}
+
+/// spot.json with DJ Booth tilting the spot down and strobing it, followed by `release`
+async fn load_with_release(release: Cue) -> Harness {
+    let mut harness = Harness::new().await;
+    harness.run_step("load spot.json").await.unwrap();
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    let booth = &mut cue_lists[0].cues[1];
+    booth.positions.clear();
+    booth.static_values = vec![
+        value(ChannelType::Dimmer, 255),
+        value(ChannelType::Tilt, 40),
+        value(ChannelType::Strobe, 200),
+        value(ChannelType::Color, 255),
+    ];
+    cue_lists[0].cues.push(release);
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect channel 0 tilt 40").await.unwrap();
+    harness
+        .run_step("expect channel 0 strobe 200")
+        .await
+        .unwrap();
+    harness
+}
+
+async fn channel(harness: &Harness, channel_type: ChannelType) -> u8 {
+    let fixtures = harness.console.fixtures.read().await;
+    fixtures[0].channel_value(&channel_type).unwrap()
+}
+
+#[tokio::test]
+async fn release_all_takes_every_attribute_home() {
+    let mut harness = load_with_release(Cue::release_all("Release", &[], Duration::ZERO)).await;
+    harness.run_step("goto 0 2").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+
+    let home = harness.console.fixtures.read().await[0].home_position();
+    assert_eq!(channel(&harness, ChannelType::Tilt).await, home.tilt);
+    assert_eq!(channel(&harness, ChannelType::Pan).await, home.pan);
+    assert_eq!(channel(&harness, ChannelType::Strobe).await, 0);
+    assert_eq!(channel(&harness, ChannelType::Dimmer).await, 0);
+    assert_eq!(channel(&harness, ChannelType::Color).await, 0);
+}
+
+#[tokio::test]
+async fn a_release_can_leave_some_attributes_alone() {
+    let release = Cue {
+        name: "Park".to_string(),
+        release: Some(Release {
+            fixture_ids: vec![0],
+            attributes: vec![Attribute::Position, Attribute::Other],
+        }),
+        // The cue's own values go over the release
+        static_values: vec![value(ChannelType::Pan, 10)],
+        ..Cue::default()
+    };
+    let mut harness = load_with_release(release).await;
+    harness.run_step("goto 0 2").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+
+    let home = harness.console.fixtures.read().await[0].home_position();
+    assert_eq!(channel(&harness, ChannelType::Tilt).await, home.tilt);
+    assert_eq!(channel(&harness, ChannelType::Pan).await, 10);
+    assert_eq!(channel(&harness, ChannelType::Strobe).await, 0);
+    assert_eq!(channel(&harness, ChannelType::Dimmer).await, 255);
+    assert_eq!(channel(&harness, ChannelType::Color).await, 255);
+}
+
+#[tokio::test]
+async fn releasing_a_fixture_takes_it_out_of_running_effects() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load spot.json").await.unwrap();
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues[1].effects.push(EffectMapping {
+        name: "Tilt sweep".to_string(),
+        effect: Effect::default(),
+        fixture_ids: vec![0],
+        channel_types: vec![ChannelType::Tilt],
+        distribution: EffectDistribution::All,
+        release: EffectRelease::Hold,
+    });
+    cue_lists[0]
+        .cues
+        .push(Cue::release_all("Release", &[0], Duration::ZERO));
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    let sweeping = channel(&harness, ChannelType::Tilt).await;
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    assert_ne!(channel(&harness, ChannelType::Tilt).await, sweeping);
+
+    // The sweep would keep moving the tilt if it still had the spot
+    harness.run_step("goto 0 2").await.unwrap();
+    let home = harness.console.fixtures.read().await[0].home_position();
+    for _ in 0..10 {
+        harness.advance(Duration::from_millis(50)).await.unwrap();
+        assert_eq!(channel(&harness, ChannelType::Tilt).await, home.tilt);
+    }
+}
@@ -0,0 +1,297 @@
+mod harness;
+
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+use std::time::Duration;
+
+use halo_core::{ConsoleCommand, FrameCache, Settings};
+use halo_fixtures::{ChannelType, Fixture, FixtureLibrary};
+use harness::Harness;
+use serde_json::Value;
+
+fn par(id: usize, universe: u8, start_address: u16) -> Fixture {
+    let library = FixtureLibrary::new();
+    let profile = library.profiles["shehds-rgbw-par"].clone();
+    Fixture::new(
+        id,
+        &format!("Par {id}"),
+        profile.clone(),
+        profile.channel_layout.clone(),
+        universe,
+        start_address,
+    )
+}
+
+#[tokio::test]
+async fn an_idle_rig_stops_sending_until_a_fixture_changes() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_secs(1)).await.unwrap();
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+
+    let idle = harness.recording.lock().unwrap().frame_count;
+    harness.advance(Duration::from_secs(5)).await.unwrap();
+    assert_eq!(harness.recording.lock().unwrap().frame_count, idle);
+
+    // After minutes of nothing, the next cue still goes out
+    harness.run_step("goto 0 2").await.unwrap();
+    harness.advance(Duration::from_secs(1)).await.unwrap();
+    assert!(harness.recording.lock().unwrap().frame_count > idle);
+    harness.run_step("expect dmx 1 10 128").await.unwrap();
+}
+
+#[test]
+fn repatched_and_removed_fixtures_leave_nothing_behind() {
+    let mut cache = FrameCache::new();
+    let mut fixtures = vec![par(0, 1, 1), par(1, 1, 10)];
+    fixtures[0].set_channel_value(&ChannelType::Dimmer, 255);
+    fixtures[1].set_channel_value(&ChannelType::Dimmer, 128);
+
+    let changed = cache.render(&fixtures, HashMap::new());
+    assert_eq!(changed.len(), 1);
+    assert_eq!((changed[0].1[0], changed[0].1[9]), (255, 128));
+    assert!(cache.render(&fixtures, HashMap::new()).is_empty());
+
+    // Moving a fixture clears its old channels
+    fixtures[0].start_address = 20;
+    let changed = cache.render(&fixtures, HashMap::new());
+    assert_eq!((changed[0].1[0], changed[0].1[19]), (0, 255));
+
+    // Unpatching clears too, and a move to another universe sends both
+    fixtures.remove(1);
+    fixtures[0].universe = 2;
+    let changed = cache.render(&fixtures, HashMap::new());
+    assert_eq!(changed.len(), 2);
+    assert!(changed[0].1.iter().all(|&v| v == 0));
+    assert_eq!(changed[1].1[19], 255);
+}
+
+#[tokio::test]
+async fn a_fixtures_channels_go_out_in_the_same_frame() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues[1]
+        .static_values
+        .push(halo_core::StaticValue {
+            fixture_id: 0,
+            channel_type: ChannelType::Strobe,
+            value: 200,
+        });
+    cue_lists[0].cues[3].static_values =
+        [ChannelType::Dimmer, ChannelType::Red, ChannelType::Strobe]
+            .into_iter()
+            .map(|channel_type| halo_core::StaticValue {
+                fixture_id: 0,
+                channel_type,
+                value: 0,
+            })
+            .collect();
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+
+    harness.run_step("goto 0 3").await.unwrap();
+    harness.advance(Duration::from_secs(1)).await.unwrap();
+    let before = harness.recording.lock().unwrap().frames.len();
+    for _ in 0..3 {
+        harness.run_step("goto 0 1").await.unwrap();
+        harness.advance(Duration::from_millis(250)).await.unwrap();
+        harness.run_step("goto 0 3").await.unwrap();
+        harness.advance(Duration::from_millis(250)).await.unwrap();
+    }
+
+    // Dimmer, red and strobe are channels 1, 2 and 6 of the left PAR
+    let recording = harness.recording.lock().unwrap();
+    let frames = &recording.frames[before..];
+    assert!(frames.len() >= 6, "{} frames", frames.len());
+    for (universe, data) in frames {
+        assert_eq!(*universe, 1);
+        let lit = (data[0], data[1], data[5]);
+        assert!(
+            lit == (255, 255, 200) || lit == (0, 0, 0),
+            "left PAR sent part of a cue: {lit:?}"
+        );
+    }
+}
+
+/// Patch a PAR into universe 1 of a running console
+async fn hot_patch(harness: &mut Harness, name: &str, address: u16) {
+    harness
+        .command(ConsoleCommand::PatchFixture {
+            name: name.to_string(),
+            profile_name: "shehds-rgbw-par".to_string(),
+            universe: 1,
+            address,
+        })
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+}
+
+fn frame_length(harness: &Harness) -> usize {
+    harness.recording.lock().unwrap().universes[&1].len()
+}
+
+#[tokio::test]
+async fn universes_go_out_as_far_as_their_highest_patched_channel() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    // The right PAR's eight channels end at 17, rounded up
+    assert_eq!(frame_length(&harness), 24);
+
+    // Its last channel is 72
+    hot_patch(&mut harness, "Far PAR", 65).await;
+    assert_eq!(frame_length(&harness), 72);
+
+    hot_patch(&mut harness, "Farther PAR", 300).await;
+    assert_eq!(frame_length(&harness), 312);
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+}
+
+#[tokio::test]
+async fn receivers_can_be_sent_full_frames() {
+    let mut harness = Harness::new().await;
+    harness
+        .command(ConsoleCommand::UpdateSettings {
+            settings: Settings {
+                full_universe_frames: true,
+                ..Settings::default()
+            },
+        })
+        .await
+        .unwrap();
+    harness.run_step("load two_pars.json").await.unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    assert_eq!(frame_length(&harness), 512);
+}
+
+#[test]
+fn unpatching_never_shortens_a_frame() {
+    let mut cache = FrameCache::new();
+    let mut fixtures = vec![par(0, 1, 1), par(1, 1, 300)];
+    fixtures[1].set_channel_value(&ChannelType::Dimmer, 255);
+    let changed = cache.render(&fixtures, HashMap::new());
+    assert_eq!(changed[0].1.len(), 312);
+
+    // The far PAR's channels are zeroed, not left out
+    fixtures.remove(1);
+    let changed = cache.render(&fixtures, HashMap::new());
+    assert_eq!(changed[0].1.len(), 312);
+    assert_eq!(changed[0].1[299], 0);
+    assert_eq!(cache.frame_length(1), Some(312));
+}
+
+#[test]
+fn a_fixture_patched_at_the_end_of_a_universe_sends_every_channel() {
+    let mut cache = FrameCache::new();
+    // Eight channels from 505 fill the universe exactly
+    let mut wash = par(0, 1, 505);
+    for (i, channel) in wash.channels.iter_mut().enumerate() {
+        channel.value = 10 + i as u8;
+    }
+    let changed = cache.render(&[wash], HashMap::new());
+    let data = &changed[0].1;
+    assert_eq!(data.len(), 512);
+    assert_eq!(&data[504..], &[10, 11, 12, 13, 14, 15, 16, 17]);
+}
+
+#[tokio::test]
+async fn patching_a_fixture_past_the_end_of_a_universe_is_refused() {
+    let mut harness = Harness::new().await;
+    hot_patch(&mut harness, "Wash", 505).await;
+
+    let error = harness
+        .command(ConsoleCommand::PatchFixture {
+            name: "Spill".to_string(),
+            profile_name: "shehds-rgbw-par".to_string(),
+            universe: 1,
+            address: 510,
+        })
+        .await
+        .unwrap_err();
+    assert!(error.contains("1.510"), "{error}");
+    assert!(error.contains("1.517"), "{error}");
+    assert_eq!(harness.console.fixtures.read().await.len(), 1);
+}
+
+#[test]
+fn setting_a_channel_a_fixture_lacks_writes_nothing() {
+    let mut cache = FrameCache::new();
+    // Back to back, so a stray write just before the second PAR would land on the first
+    let mut fixtures = vec![par(0, 1, 1), par(1, 1, 9)];
+    assert!(!fixtures[1].capabilities().position);
+    cache.render(&fixtures, HashMap::new());
+
+    fixtures[1].set_channel_value(&ChannelType::Pan, 200);
+    fixtures[1].set_channel_value(&ChannelType::Tilt, 100);
+    assert!(fixtures[1].get_dmx_values().iter().all(|v| *v == 0));
+    assert!(cache.render(&fixtures, HashMap::new()).is_empty());
+    assert!(cache.universe(1).unwrap().iter().all(|v| *v == 0));
+}
+
+#[test]
+fn capabilities_come_from_the_channel_layout() {
+    let library = FixtureLibrary::new();
+    let par = library.profiles["shehds-rgbw-par"].capabilities();
+    assert!(par.dimmer && par.color && par.strobe);
+    assert!(!par.position && !par.gobo && !par.pixels);
+
+    let spot = library.profiles["shehds-led-spot-60w"].capabilities();
+    assert!(spot.position && spot.gobo);
+}
+
+/// The two PARs show with the right PAR moved to `address`
+fn two_pars_with_right_at(dir: &Path, address: u16) -> PathBuf {
+    let testdata = Path::new(env!("CARGO_MANIFEST_DIR")).join("tests/testdata/two_pars.json");
+    let mut show: Value =
+        serde_json::from_str(&std::fs::read_to_string(testdata).unwrap()).unwrap();
+    show["fixtures"][1]["start_address"] = address.into();
+    let path = dir.join("two_pars.json");
+    std::fs::write(&path, serde_json::to_string(&show).unwrap()).unwrap();
+    path
+}
+
+#[tokio::test]
+async fn shows_with_fixtures_past_the_end_of_a_universe_are_refused() {
+    let path = Path::new(env!("CARGO_MANIFEST_DIR"))
+        .join("tests/testdata/fuzz/address_past_universe_end.json");
+    let mut harness = Harness::new().await;
+    let error = harness
+        .command(ConsoleCommand::LoadShow { path })
+        .await
+        .unwrap_err();
+    assert!(error.contains("invalid addresses"), "{error}");
+    assert!(error.contains("1.517"), "{error}");
+    assert!(harness.console.fixtures.read().await.is_empty());
+}
+
+#[tokio::test]
+async fn shows_with_overlapping_fixtures_are_refused() {
+    let dir = tempfile::tempdir().unwrap();
+    let mut harness = Harness::new().await;
+    let error = harness
+        .command(ConsoleCommand::LoadShow {
+            path: two_pars_with_right_at(dir.path(), 5),
+        })
+        .await
+        .unwrap_err();
+    assert!(
+        error.contains("Left PAR (1.1-8) overlaps Right PAR (1.5-12)"),
+        "{error}"
+    );
+
+    // Right after the left PAR it loads
+    harness
+        .command(ConsoleCommand::LoadShow {
+            path: two_pars_with_right_at(dir.path(), 9),
+        })
+        .await
+        .unwrap();
+    assert_eq!(harness.console.fixtures.read().await.len(), 2);
+}
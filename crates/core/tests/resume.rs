@@ -0,0 +1,105 @@
+mod harness;
+
+use std::path::Path;
+use std::time::{Duration, SystemTime, UNIX_EPOCH};
+
+use halo_core::{show_file_hash, BuildInfo, ConsoleCommand, ResumeState};
+use harness::Harness;
+
+/// two_pars.json run to the Right Half cue, at 128 BPM
+async fn mid_show() -> Harness {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    harness
+        .command(ConsoleCommand::SetBpm { bpm: 128.0 })
+        .await
+        .unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_secs(1)).await.unwrap();
+    harness.run_step("go").await.unwrap();
+    harness.advance(Duration::from_secs(1)).await.unwrap();
+    harness
+}
+
+#[tokio::test]
+async fn a_restarted_console_carries_on_from_the_saved_state() {
+    let crashed = mid_show().await;
+    let saved = serde_json::to_string(&crashed.console.resume_state().await).unwrap();
+    drop(crashed);
+
+    let mut harness = Harness::new().await;
+    let state: ResumeState = serde_json::from_str(&saved).unwrap();
+    assert_eq!(state.next_cue, Some(3));
+    harness.console.resume_from(state).await;
+
+    // Nothing happens until the show is loaded, then the rig fades back up
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("load two_pars.json").await.unwrap();
+    harness.advance(Duration::from_secs(1)).await.unwrap();
+    assert!(harness.console.grand_master_level().await < 1.0);
+    harness.advance(Duration::from_millis(1100)).await.unwrap();
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+    harness.run_step("expect dmx 1 2 255").await.unwrap();
+    harness.run_step("expect dmx 1 10 128").await.unwrap();
+
+    // The next Go runs the cue that was next before the crash
+    assert_eq!(
+        harness.console.cue_manager.read().await.next_go(),
+        Some((0, 3))
+    );
+    harness.run_step("go").await.unwrap();
+    harness.advance(Duration::from_secs(1)).await.unwrap();
+    let cue_manager = harness.console.cue_manager.read().await;
+    assert_eq!(cue_manager.get_current_cue().unwrap().name, "Blackout");
+    drop(cue_manager);
+    assert_eq!(harness.console.resume_state().await.bpm, 128.0);
+}
+
+#[tokio::test]
+async fn state_is_saved_when_each_cue_starts() {
+    let dir = tempfile::tempdir().unwrap();
+    let path = dir.path().join("halo-state.json");
+
+    let mut harness = Harness::new().await;
+    harness.console.set_resume_file(Some(path.clone())).await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+    let state = ResumeState::load(&path, Duration::from_secs(60))
+        .unwrap()
+        .unwrap();
+    assert_eq!((state.cue_list.as_str(), state.next_cue), ("Main", Some(2)));
+    // Saved state is traceable to the build and show file that wrote it
+    let show = Path::new(env!("CARGO_MANIFEST_DIR")).join("tests/testdata/two_pars.json");
+    assert_eq!(state.build, Some(BuildInfo::current()));
+    assert_eq!(state.show_hash, show_file_hash(&show).ok());
+
+    harness.run_step("go").await.unwrap();
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+    let state = ResumeState::load(&path, Duration::from_secs(60))
+        .unwrap()
+        .unwrap();
+    assert_eq!(state.next_cue, Some(3));
+}
+
+#[test]
+fn stale_state_is_ignored() {
+    let dir = tempfile::tempdir().unwrap();
+    let path = dir.path().join("halo-state.json");
+    let now = SystemTime::now().duration_since(UNIX_EPOCH).unwrap();
+    let state = ResumeState {
+        saved_at: now.as_secs() - 3600,
+        cue_list: "Main".to_string(),
+        next_cue: Some(1),
+        values: vec![],
+        grand_master: 1.0,
+        bpm: 120.0,
+        show_hash: None,
+        build: None,
+    };
+    state.save(&path).unwrap();
+
+    let max_age = Duration::from_secs(30 * 60);
+    assert!(ResumeState::load(&path, max_age).unwrap().is_none());
+    assert!(ResumeState::load(&path, max_age * 4).unwrap().is_some());
+}
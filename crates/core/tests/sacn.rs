@@ -0,0 +1,164 @@
+use std::net::{IpAddr, Ipv4Addr, SocketAddr};
+use std::time::Duration;
+
+use halo_core::{
+    multicast_address, AsyncModule, DmxModule, ModuleEvent, ModuleMessage, SacnConfig, SacnDriver,
+};
+use tokio::net::UdpSocket;
+use tokio::sync::mpsc;
+
+const LOCALHOST: IpAddr = IpAddr::V4(Ipv4Addr::LOCALHOST);
+
+/// The parts of an E1.31 data packet the tests look at
+#[derive(Debug)]
+struct Received {
+    source_name: String,
+    priority: u8,
+    sequence: u8,
+    terminated: bool,
+    universe: u16,
+    data: Vec<u8>,
+}
+
+fn parse(packet: &[u8]) -> Received {
+    assert!(packet.len() >= 126, "short packet: {} bytes", packet.len());
+    let word = |at: usize| u16::from_be_bytes([packet[at], packet[at + 1]]);
+
+    // Root layer
+    assert_eq!(word(0), 0x0010);
+    assert_eq!(&packet[4..16], b"ASC-E1.17\0\0\0");
+    assert_eq!(word(16), 0x7000 | (packet.len() - 16) as u16);
+    assert_eq!(&packet[18..22], &[0, 0, 0, 4]);
+    // Framing layer
+    assert_eq!(word(38), 0x7000 | (packet.len() - 38) as u16);
+    assert_eq!(&packet[40..44], &[0, 0, 0, 2]);
+    // DMP layer
+    assert_eq!(word(115), 0x7000 | (packet.len() - 115) as u16);
+    assert_eq!(&packet[117..119], &[0x02, 0xa1]);
+    assert_eq!(word(119), 0);
+    assert_eq!(word(121), 1);
+    assert_eq!(word(123) as usize, packet.len() - 125);
+    assert_eq!(packet[125], 0, "DMX start code");
+
+    let name = &packet[44..108];
+    let name_length = name.iter().position(|b| *b == 0).unwrap_or(64);
+    Received {
+        source_name: String::from_utf8_lossy(&name[..name_length]).to_string(),
+        priority: packet[108],
+        sequence: packet[111],
+        terminated: packet[112] & 0x40 != 0,
+        universe: word(113),
+        data: packet[126..].to_vec(),
+    }
+}
+
+async fn receive(socket: &UdpSocket) -> Received {
+    let mut buffer = [0u8; 1024];
+    let (length, _) = tokio::time::timeout(Duration::from_secs(2), socket.recv_from(&mut buffer))
+        .await
+        .expect("no sACN packet arrived")
+        .unwrap();
+    parse(&buffer[..length])
+}
+
+/// A DMX module sending sACN to a socket on localhost, and the socket
+async fn unicast_to_localhost(
+    config: impl FnOnce(&mut SacnConfig),
+) -> (
+    UdpSocket,
+    mpsc::Sender<ModuleEvent>,
+    tokio::task::JoinHandle<()>,
+) {
+    let receiver = UdpSocket::bind(SocketAddr::new(LOCALHOST, 0))
+        .await
+        .unwrap();
+    let mut sacn = SacnConfig::new(LOCALHOST, "Test Console");
+    sacn.unicast = vec![receiver.local_addr().unwrap()];
+    config(&mut sacn);
+
+    let mut module = DmxModule::with_driver(Box::new(SacnDriver::new(sacn)));
+    module.initialize().await.unwrap();
+    let (event_tx, event_rx) = mpsc::channel(16);
+    let (message_tx, mut message_rx) = mpsc::channel::<ModuleMessage>(16);
+    let handle = tokio::spawn(async move {
+        module.run(event_rx, message_tx).await.unwrap();
+    });
+    tokio::spawn(async move { while message_rx.recv().await.is_some() {} });
+    (receiver, event_tx, handle)
+}
+
+#[tokio::test]
+async fn universes_go_out_as_e131_data_with_rising_sequence_numbers() {
+    let (receiver, events, handle) = unicast_to_localhost(|_| {}).await;
+    let mut dmx = vec![0u8; 512];
+    dmx[0] = 255;
+    dmx[9] = 128;
+    events
+        .send(ModuleEvent::DmxOutput(1, dmx.clone()))
+        .await
+        .unwrap();
+
+    let first = receive(&receiver).await;
+    assert_eq!(first.source_name, "Test Console");
+    assert_eq!(first.universe, 1);
+    assert_eq!(first.priority, 100);
+    assert!(!first.terminated);
+    assert_eq!(first.data, dmx);
+
+    let second = receive(&receiver).await;
+    assert_eq!(second.sequence, first.sequence.wrapping_add(1));
+
+    // Stopping tells receivers the stream is over
+    events.send(ModuleEvent::Shutdown).await.unwrap();
+    handle.await.unwrap();
+    let mut last = receive(&receiver).await;
+    while !last.terminated {
+        last = receive(&receiver).await;
+    }
+    assert_eq!(last.universe, 1);
+}
+
+#[tokio::test]
+async fn universes_can_have_their_own_priority() {
+    let (receiver, events, handle) = unicast_to_localhost(|sacn| {
+        sacn.priority = 80;
+        sacn.universe_priorities.insert(2, 150);
+    })
+    .await;
+    events
+        .send(ModuleEvent::DmxOutput(2, vec![10; 512]))
+        .await
+        .unwrap();
+    events
+        .send(ModuleEvent::DmxOutput(3, vec![20; 512]))
+        .await
+        .unwrap();
+
+    let mut priorities = Vec::new();
+    while priorities.len() < 2 {
+        let packet = receive(&receiver).await;
+        if !priorities.iter().any(|(u, _)| *u == packet.universe) {
+            priorities.push((packet.universe, packet.priority));
+        }
+    }
+    priorities.sort();
+    assert_eq!(priorities, [(2, 150), (3, 80)]);
+
+    events.send(ModuleEvent::Shutdown).await.unwrap();
+    handle.await.unwrap();
+}
+
+#[test]
+fn universes_multicast_to_their_own_group() {
+    assert_eq!(multicast_address(1), Ipv4Addr::new(239, 255, 0, 1));
+    assert_eq!(multicast_address(300), Ipv4Addr::new(239, 255, 1, 44));
+
+    let sacn = SacnConfig::new(LOCALHOST, "Test Console");
+    assert_eq!(
+        sacn.destinations(7),
+        [SocketAddr::new(Ipv4Addr::new(239, 255, 0, 7).into(), 5568)]
+    );
+    // The same name gives the same CID from run to run
+    assert_eq!(sacn.cid, SacnConfig::new(LOCALHOST, "Test Console").cid);
+    assert_ne!(sacn.cid, SacnConfig::new(LOCALHOST, "Other Console").cid);
+}
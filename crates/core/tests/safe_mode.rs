@@ -0,0 +1,87 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{recover_panic, ConsoleCommand, SAFE_MODE_REQUESTED};
+use harness::Harness;
+
+/// Patch a PAR at address 1 and put its dimmer up from the programmer
+async fn drive_by_hand(harness: &mut Harness) {
+    harness
+        .command(ConsoleCommand::PatchFixture {
+            name: "House PAR".to_string(),
+            profile_name: "shehds-rgbw-par".to_string(),
+            universe: 1,
+            address: 1,
+        })
+        .await
+        .unwrap();
+    let fixture_id = harness.console.fixtures.read().await[0].id;
+    harness
+        .command(ConsoleCommand::SetProgrammerPreviewMode { preview_mode: true })
+        .await
+        .unwrap();
+    harness
+        .command(ConsoleCommand::SetProgrammerValue {
+            fixture_id,
+            channel: "dimmer".to_string(),
+            value: 200,
+        })
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+}
+
+#[tokio::test]
+async fn a_corrupt_show_at_startup_falls_back_to_manual_control() {
+    let dir = tempfile::tempdir().unwrap();
+    let path = dir.path().join("corrupt.json");
+    std::fs::write(&path, "{\"name\": \"Half a show\", \"fixtures\": [").unwrap();
+    let mut harness = Harness::new().await;
+
+    harness
+        .command(ConsoleCommand::StartShow { path })
+        .await
+        .unwrap();
+    let reason = harness.console.safe_mode().unwrap();
+    assert!(reason.contains("corrupt.json"), "{reason}");
+
+    drive_by_hand(&mut harness).await;
+    harness.run_step("expect dmx 1 1 200").await.unwrap();
+}
+
+#[tokio::test]
+async fn safe_mode_refuses_shows_and_drops_playback() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+
+    harness
+        .console
+        .enter_safe_mode(SAFE_MODE_REQUESTED.to_string())
+        .await;
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    assert!(harness
+        .console
+        .cue_manager
+        .read()
+        .await
+        .get_cue_lists()
+        .is_empty());
+    assert!(harness.run_step("load two_pars.json").await.is_err());
+    assert_eq!(harness.console.safe_mode(), Some(SAFE_MODE_REQUESTED));
+}
+
+#[tokio::test]
+async fn a_panic_is_recovered_with_its_message() {
+    let result = recover_panic(async {
+        tokio::task::yield_now().await;
+        panic!("profile table is corrupt");
+    })
+    .await;
+    assert_eq!(result.unwrap_err(), "profile table is corrupt");
+
+    assert_eq!(recover_panic(async { 7 }).await, Ok(7));
+}
@@ -0,0 +1,110 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{ConsoleCommand, LatePolicy, ScheduledAction, ScheduledEvent, StaticValue};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+
+fn event(name: &str, at_secs: u64, action: ScheduledAction) -> ScheduledEvent {
+    ScheduledEvent {
+        name: name.to_string(),
+        at: Duration::from_secs(at_secs),
+        action,
+        late: LatePolicy::Fire,
+    }
+}
+
+fn smoke() -> ScheduledAction {
+    // The second PAR's white channel stands in for a smoke machine
+    ScheduledAction::Hold {
+        values: vec![StaticValue {
+            fixture_id: 1,
+            channel_type: ChannelType::White,
+            value: 200,
+        }],
+    }
+}
+
+async fn schedule(harness: &mut Harness, event: ScheduledEvent) {
+    harness
+        .command(ConsoleCommand::ScheduleEvent { event })
+        .await
+        .unwrap();
+}
+
+#[tokio::test]
+async fn events_fire_at_show_relative_times() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    schedule(
+        &mut harness,
+        event(
+            "Doors",
+            5,
+            ScheduledAction::Goto {
+                cue_list: "Main".to_string(),
+                cue: "Left Red".to_string(),
+            },
+        ),
+    )
+    .await;
+    schedule(&mut harness, event("Smoke", 10, smoke())).await;
+
+    // The schedule waits for the show clock, however long the console has been up
+    harness.advance(Duration::from_secs(20)).await.unwrap();
+    harness.run_step("expect dmx 1 1 0").await.unwrap();
+
+    harness
+        .command(ConsoleCommand::StartShowClock)
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(4900)).await.unwrap();
+    harness.run_step("expect dmx 1 1 0").await.unwrap();
+    harness.advance(Duration::from_millis(200)).await.unwrap();
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+    harness.run_step("expect dmx 1 14 0").await.unwrap();
+
+    harness.advance(Duration::from_secs(5)).await.unwrap();
+    harness.run_step("expect dmx 1 14 200").await.unwrap();
+
+    // Stopping the show clock lets go of held values
+    harness
+        .command(ConsoleCommand::StopShowClock)
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+    harness.run_step("expect dmx 1 14 0").await.unwrap();
+}
+
+#[tokio::test]
+async fn late_events_fire_or_skip_by_policy() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    harness
+        .command(ConsoleCommand::StartShowClock)
+        .await
+        .unwrap();
+    harness.advance(Duration::from_secs(60)).await.unwrap();
+
+    schedule(
+        &mut harness,
+        ScheduledEvent {
+            late: LatePolicy::Skip,
+            ..event(
+                "Missed Doors",
+                30,
+                ScheduledAction::Goto {
+                    cue_list: "Main".to_string(),
+                    cue: "Left Red".to_string(),
+                },
+            )
+        },
+    )
+    .await;
+    schedule(&mut harness, event("Late Smoke", 30, smoke())).await;
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+
+    harness.run_step("expect dmx 1 14 200").await.unwrap();
+    harness.run_step("expect dmx 1 1 0").await.unwrap();
+}
@@ -0,0 +1,242 @@
+use std::path::{Path, PathBuf};
+
+use halo_core::{
+    fix_gaps, simulate_show, simulate_show_with, Axis, GapCheck, Severity, Show, SimulationOptions,
+};
+use serde_json::{json, Value};
+
+fn example_show() -> PathBuf {
+    Path::new(env!("CARGO_MANIFEST_DIR")).join("tests/testdata/two_pars.json")
+}
+
+fn write_variant(dir: &Path, edit: impl FnOnce(&mut Value)) -> PathBuf {
+    let mut show: Value =
+        serde_json::from_str(&std::fs::read_to_string(example_show()).unwrap()).unwrap();
+    edit(&mut show);
+    let path = dir.join("variant.json");
+    std::fs::write(&path, serde_json::to_string(&show).unwrap()).unwrap();
+    path
+}
+
+#[tokio::test]
+async fn simulates_example_show() {
+    let report = simulate_show(&example_show(), None).await.unwrap();
+
+    assert_eq!(report.show_name, "Two PARs");
+    let cues: Vec<&str> = report.cues.iter().map(|c| c.cue.as_str()).collect();
+    assert_eq!(cues, ["Preset", "Left Red", "Right Half", "Blackout"]);
+
+    // Manual cues are held for five seconds each
+    assert!((report.total_duration - 20.0).abs() < 0.5, "{report}");
+    for cue in &report.cues {
+        assert!((cue.duration - 5.0).abs() < 0.1, "{report}");
+    }
+    assert!(report.unused_fixtures.is_empty(), "{report}");
+    assert!(!report.has_errors(), "{report}");
+}
+
+#[tokio::test]
+async fn timecoded_cues_follow_the_show_clock() {
+    let dir = tempfile::tempdir().unwrap();
+    let path = write_variant(dir.path(), |show| {
+        let cues = &mut show["cue_lists"][0]["cues"];
+        cues[1]["timecode"] = json!("00:00:02:00");
+        cues[2]["timecode"] = json!("00:00:10:00");
+        cues[3]["timecode"] = json!("00:00:12:15");
+    });
+
+    let report = simulate_show(&path, None).await.unwrap();
+
+    let starts: Vec<f64> = report.cues.iter().map(|c| c.start).collect();
+    assert_eq!(starts.len(), 4, "{report}");
+    assert!((starts[1] - 2.0).abs() < 0.1, "{report}");
+    assert!((starts[2] - 10.0).abs() < 0.1, "{report}");
+    assert!((starts[3] - 12.5).abs() < 0.1, "{report}");
+}
+
+#[tokio::test]
+async fn reports_unused_fixtures_and_missing_references() {
+    let dir = tempfile::tempdir().unwrap();
+    let path = write_variant(dir.path(), |show| {
+        show["fixtures"].as_array_mut().unwrap().push(json!({
+            "id": 2,
+            "name": "Spare PAR",
+            "profile_id": "shehds-rgbw-par",
+            "universe": 1,
+            "start_address": 20
+        }));
+        show["cue_lists"][0]["cues"][1]["static_values"]
+            .as_array_mut()
+            .unwrap()
+            .push(json!({ "fixture_id": 7, "channel_type": "Dimmer", "value": 255 }));
+    });
+
+    let report = simulate_show(&path, None).await.unwrap();
+
+    assert_eq!(report.unused_fixtures, ["Spare PAR"]);
+    assert!(report.has_errors());
+    assert!(report
+        .findings
+        .iter()
+        .any(|f| f.severity == Severity::Error && f.message.contains("missing fixture 7")));
+}
+
+#[tokio::test]
+async fn load_failures_are_errors() {
+    let dir = tempfile::tempdir().unwrap();
+    let path = write_variant(dir.path(), |show| {
+        show["fixtures"][0]["profile_id"] = json!("no-such-profile");
+    });
+
+    let report = simulate_show(&path, None).await.unwrap();
+
+    assert!(report.has_errors());
+    assert!(report.cues.is_empty());
+}
+
+fn gap_options() -> SimulationOptions {
+    SimulationOptions {
+        gaps: Some(GapCheck::default()),
+        ..SimulationOptions::default()
+    }
+}
+
+#[tokio::test]
+async fn finds_and_fixes_brief_gaps_between_cues() {
+    let dir = tempfile::tempdir().unwrap();
+    let path = write_variant(dir.path(), |show| {
+        let cues = &mut show["cue_lists"][0]["cues"];
+        cues[1]["timecode"] = json!("00:00:02:00");
+        // Takes the left PAR out a frame before the next cue brings it back
+        cues[2]["timecode"] = json!("00:00:04:00");
+        cues[2]["static_values"] =
+            json!([{ "fixture_id": 0, "channel_type": "Dimmer", "value": 0 }]);
+        cues[3]["timecode"] = json!("00:00:04:01");
+        cues[3]["blocking"] = json!(false);
+        cues[3]["static_values"] =
+            json!([{ "fixture_id": 0, "channel_type": "Dimmer", "value": 255 }]);
+    });
+
+    let report = simulate_show_with(&path, &gap_options()).await.unwrap();
+
+    assert_eq!(report.gaps.len(), 1, "{report}");
+    let gap = &report.gaps[0];
+    assert_eq!(gap.fixture, "Left PAR");
+    assert_eq!((gap.from_cue, gap.to_cue), (1, 3));
+    assert_eq!(gap.ticks, 2, "{report}");
+    assert_eq!(gap.level, 255);
+    // Two seconds in at 120 BPM is the start of the second bar
+    assert_eq!(gap.position.to_string(), "1.3.1");
+    assert!((gap.start - 4.0).abs() < 0.1, "{report}");
+    assert!(report
+        .findings
+        .iter()
+        .any(|f| f.message.contains("'Left Red' and 'Blackout'")));
+
+    let mut show = Show::read(&path).unwrap();
+    let changes = fix_gaps(&mut show, &report.gaps);
+    assert_eq!(changes.len(), 1, "{changes:?}");
+    let fixed = dir.path().join("fixed.json");
+    std::fs::write(&fixed, serde_json::to_string(&show).unwrap()).unwrap();
+
+    let report = simulate_show_with(&fixed, &gap_options()).await.unwrap();
+    assert!(report.gaps.is_empty(), "{report}");
+}
+
+#[tokio::test]
+async fn long_blackouts_are_not_gaps() {
+    let dir = tempfile::tempdir().unwrap();
+    let path = write_variant(dir.path(), |show| {
+        let cues = &mut show["cue_lists"][0]["cues"];
+        cues[1]["timecode"] = json!("00:00:02:00");
+        cues[2]["timecode"] = json!("00:00:04:00");
+        cues[2]["static_values"] =
+            json!([{ "fixture_id": 0, "channel_type": "Dimmer", "value": 0 }]);
+        cues[3]["timecode"] = json!("00:00:06:00");
+        cues[3]["blocking"] = json!(false);
+        cues[3]["static_values"] =
+            json!([{ "fixture_id": 0, "channel_type": "Dimmer", "value": 255 }]);
+    });
+
+    let report = simulate_show_with(&path, &gap_options()).await.unwrap();
+    assert!(report.gaps.is_empty(), "{report}");
+}
+
+/// A sine on one axis of the spot, twice a beat
+fn circle_axis(name: &str, channel_type: &str, phase: f64) -> Value {
+    json!({
+        "name": name,
+        "effect": {
+            "effect_type": "Sine",
+            "min": 0,
+            "max": 255,
+            "amplitude": 1.0,
+            "frequency": 1.0,
+            "offset": 0.0,
+            "params": { "interval": "Beat", "interval_ratio": 2.0, "phase": phase }
+        },
+        "fixture_ids": [2],
+        "channel_types": [channel_type],
+        "distribution": "All"
+    })
+}
+
+#[tokio::test]
+async fn reports_effects_that_outrun_a_slow_head() {
+    let dir = tempfile::tempdir().unwrap();
+    let profiles = dir.path().join("profiles");
+    std::fs::create_dir(&profiles).unwrap();
+    std::fs::write(
+        profiles.join("slow-spot.json"),
+        serde_json::to_string(&json!({
+            "id": "slow-spot",
+            "channel_count": 3,
+            "channels": { "Pan": 1, "Tilt": 2, "Dimmer": 3 },
+            "motion": {
+                "pan_range": 540.0,
+                "tilt_range": 270.0,
+                "pan_speed": 10000.0,
+                "tilt_speed": 2400.0
+            }
+        }))
+        .unwrap(),
+    )
+    .unwrap();
+    let path = write_variant(dir.path(), |show| {
+        show["fixtures"].as_array_mut().unwrap().push(json!({
+            "id": 2,
+            "name": "Spot",
+            "profile_id": "slow-spot",
+            "universe": 1,
+            "start_address": 30
+        }));
+        show["cue_lists"][0]["cues"][1]["effects"] = json!([
+            circle_axis("Circle Pan", "Pan", 0.0),
+            circle_axis("Circle Tilt", "Tilt", 0.25),
+        ]);
+    });
+
+    let options = SimulationOptions {
+        profiles: Some(profiles),
+        ..SimulationOptions::default()
+    };
+    let report = simulate_show_with(&path, &options).await.unwrap();
+
+    // A full-range sine at 4Hz peaks near 3400°/s on a 270° tilt, while the pan's range
+    // is wide but its head fast enough
+    assert_eq!(report.motion.len(), 1, "{report}");
+    let lag = &report.motion[0];
+    assert_eq!(lag.fixture, "Spot");
+    assert_eq!(lag.axis, Axis::Tilt);
+    assert_eq!(lag.effect, "Circle Tilt");
+    assert_eq!(lag.rate, "2 cycles/beat");
+    assert!((30.0..50.0).contains(&lag.excess()), "{report}");
+    assert!(lag.max_lag > 10.0, "{report}");
+    assert!(report.findings.iter().any(|f| f
+        .message
+        .starts_with("Circle Tilt effect at 2 cycles/beat exceeds tilt speed by")));
+
+    // Without the profiles the spot isn't patched, so there's nothing to check
+    let report = simulate_show(&path, None).await.unwrap();
+    assert!(report.motion.is_empty(), "{report}");
+}
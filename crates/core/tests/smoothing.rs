@@ -0,0 +1,107 @@
+mod harness;
+
+use std::collections::HashMap;
+use std::time::{Duration, Instant};
+
+use halo_core::{default_channel_smoothing, ChannelSmoother, ConsoleCommand, Settings};
+use halo_fixtures::{ChannelType, Fixture, FixtureLibrary, PanTilt};
+use harness::Harness;
+
+/// 40fps, like the harness tick
+const FRAME: Duration = Duration::from_millis(25);
+
+fn spot() -> Fixture {
+    let library = FixtureLibrary::new();
+    let profile = library.profiles["shehds-led-spot-60w"].clone();
+    Fixture::new(
+        0,
+        "Spot",
+        profile.clone(),
+        profile.channel_layout.clone(),
+        1,
+        1,
+    )
+}
+
+/// Pan output for each frame, rendering `input(frame)` underneath
+fn pan_output(frames: u32, input: impl Fn(u32) -> u8) -> Vec<u8> {
+    let mut fixtures = vec![spot()];
+    let mut smoother = ChannelSmoother::new();
+    let start = Instant::now();
+    (0..frames)
+        .map(|frame| {
+            smoother.restore(&mut fixtures);
+            fixtures[0].set_channel_value(&ChannelType::Pan, input(frame));
+            smoother.apply(
+                &mut fixtures,
+                start + FRAME * frame,
+                &default_channel_smoothing(),
+            );
+            fixtures[0].channel_value(&ChannelType::Pan).unwrap()
+        })
+        .collect()
+}
+
+#[test]
+fn a_full_step_ramps_over_the_smoothing_time() {
+    let output = pan_output(12, |frame| if frame == 0 { 0 } else { 255 });
+    assert_eq!(
+        output,
+        [0, 26, 51, 77, 102, 128, 153, 179, 204, 230, 255, 255]
+    );
+}
+
+#[test]
+fn small_steps_ramp_for_less_time() {
+    let output = pan_output(5, |frame| if frame == 0 { 100 } else { 150 });
+    assert_eq!(output, [100, 126, 150, 150, 150]);
+}
+
+#[test]
+fn slow_fades_pass_through_untouched() {
+    // Two seconds end to end, well under the quarter second full travel
+    let fade = |frame: u32| (frame * 255 / 80) as u8;
+    assert_eq!(pan_output(81, fade), (0..81).map(fade).collect::<Vec<_>>());
+}
+
+#[test]
+fn channels_without_smoothing_snap() {
+    let mut fixtures = vec![spot()];
+    let mut smoother = ChannelSmoother::new();
+    let start = Instant::now();
+    smoother.apply(&mut fixtures, start, &default_channel_smoothing());
+
+    fixtures[0].set_channel_value(&ChannelType::Dimmer, 255);
+    smoother.apply(&mut fixtures, start + FRAME, &default_channel_smoothing());
+    assert_eq!(fixtures[0].channel_value(&ChannelType::Dimmer), Some(255));
+}
+
+#[tokio::test]
+async fn console_ramps_position_changes_when_smoothing_is_on() {
+    let mut harness = Harness::new().await;
+    harness
+        .command(ConsoleCommand::UpdateSettings {
+            settings: Settings {
+                smooth_channels: true,
+                position_presets: HashMap::from([(
+                    "dj_booth".to_string(),
+                    HashMap::from([("Spot".to_string(), PanTilt { pan: 40, tilt: 200 })]),
+                )]),
+                ..Settings::default()
+            },
+        })
+        .await
+        .unwrap();
+    harness.run_step("load spot.json").await.unwrap();
+    harness.run_step("goto 0 0").await.unwrap();
+    harness.advance(Duration::from_secs(1)).await.unwrap();
+    harness.run_step("expect dmx 1 2 0").await.unwrap();
+
+    // The DJ booth preset snaps in the cue, but tilt takes 200/255 of a quarter second
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(FRAME * 4).await.unwrap();
+    harness.run_step("expect dmx 1 1 40").await.unwrap();
+    harness.run_step("expect dmx 1 2 102").await.unwrap();
+    harness.advance(FRAME * 4).await.unwrap();
+    harness.run_step("expect dmx 1 2 200").await.unwrap();
+}
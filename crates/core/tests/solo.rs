@@ -0,0 +1,90 @@
+use halo_core::{
+    EffectDistribution, PixelEffect, PixelEffectScope, PixelEffectType, PixelEngine, RhythmState,
+    SoloLayer,
+};
+use halo_fixtures::{ChannelType, Fixture, FixtureLibrary};
+
+fn fixture(id: usize, profile_id: &str, address: u16) -> Fixture {
+    let profile = FixtureLibrary::new().profiles[profile_id].clone();
+    let channels = profile.channel_layout.clone();
+    Fixture::new(id, "Test", profile, channels, 1, address)
+}
+
+fn rhythm() -> RhythmState {
+    RhythmState {
+        beat_phase: 0.25,
+        bar_phase: 0.0,
+        phrase_phase: 0.0,
+        beats_per_bar: 4,
+        bars_per_phrase: 4,
+        last_tap_time: None,
+        tap_count: 0,
+    }
+}
+
+#[test]
+fn solo_darkens_fixtures_without_a_dimmer() {
+    let mut fixtures = vec![
+        fixture(0, "shehds-rgbw-par", 1),
+        fixture(1, "dl-geyser-1000-led-smoke-machine-1000w-3x9w-rgb", 9),
+        fixture(2, "generic-rgb-pixel-bar-30", 16),
+    ];
+    for fixture in fixtures.iter_mut() {
+        for channel in fixture.channels.iter_mut() {
+            channel.value = 200;
+        }
+    }
+
+    let mut solo = SoloLayer::new();
+    solo.solo(vec![0]);
+    solo.apply(&mut fixtures);
+
+    // The smoke machine's LEDs and the bar's pixels go dark, the smoke itself keeps going
+    let smoke = &fixtures[1];
+    assert_eq!(smoke.channel_value(&ChannelType::Red), Some(0));
+    assert_eq!(smoke.channel_value(&ChannelType::Blue), Some(0));
+    assert_eq!(
+        smoke.channel_value(&ChannelType::Other("Smoke".to_string())),
+        Some(200)
+    );
+    let bar = &fixtures[2];
+    assert_eq!(bar.channel_value(&ChannelType::PixelGreen(12)), Some(0));
+    assert_eq!(fixtures[0].channel_value(&ChannelType::Red), Some(200));
+
+    solo.restore(&mut fixtures);
+    assert_eq!(fixtures[1].channel_value(&ChannelType::Red), Some(200));
+    assert_eq!(
+        fixtures[2].channel_value(&ChannelType::PixelGreen(12)),
+        Some(200)
+    );
+}
+
+#[test]
+fn pixel_bars_outside_the_solo_render_black() {
+    let fixtures = vec![
+        fixture(0, "generic-rgb-pixel-bar-30", 1),
+        fixture(1, "generic-rgb-pixel-bar-30", 91),
+    ];
+    let mut engine = PixelEngine::new();
+    engine.add_effect(
+        "Cycle".to_string(),
+        vec![0, 1],
+        PixelEffect {
+            effect_type: PixelEffectType::ColorCycle,
+            scope: PixelEffectScope::Bar,
+            ..PixelEffect::default()
+        },
+        EffectDistribution::All,
+    );
+
+    let mut solo = SoloLayer::new();
+    solo.solo(vec![0]);
+    let universes = engine.render(&fixtures, |_| rhythm(), |id| solo.lit(id));
+    let data = &universes[&1];
+    assert!(data[..90].iter().any(|v| *v > 0));
+    assert!(data[90..180].iter().all(|v| *v == 0));
+
+    solo.clear();
+    let universes = engine.render(&fixtures, |_| rhythm(), |id| solo.lit(id));
+    assert!(universes[&1][90..180].iter().any(|v| *v > 0));
+}
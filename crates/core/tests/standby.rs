@@ -0,0 +1,126 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{read_mirror, write_mirror, HEARTBEAT_TIMEOUT};
+use harness::Harness;
+use tokio::io::{BufReader, DuplexStream};
+
+const TICK: Duration = Duration::from_millis(25);
+
+/// A primary and a standby connected in-process, with the link between them
+struct Pair {
+    primary: Harness,
+    standby: Harness,
+    to_standby: DuplexStream,
+    from_primary: BufReader<DuplexStream>,
+}
+
+impl Pair {
+    async fn new(primary_show: &str, standby_show: &str) -> Self {
+        let mut primary = Harness::new().await;
+        primary
+            .run_step(&format!("load {primary_show}"))
+            .await
+            .unwrap();
+        let mut standby = Harness::new().await;
+        standby
+            .run_step(&format!("load {standby_show}"))
+            .await
+            .unwrap();
+        standby.console.enter_standby();
+        let (to_standby, from_primary) = tokio::io::duplex(1 << 20);
+        Self {
+            primary,
+            standby,
+            to_standby,
+            from_primary: BufReader::new(from_primary),
+        }
+    }
+
+    /// Run both consoles for `duration`, streaming the primary's state after every tick
+    async fn advance(&mut self, duration: Duration) -> Vec<Result<(), String>> {
+        let mut results = Vec::new();
+        let mut elapsed = Duration::ZERO;
+        while elapsed < duration {
+            self.primary.advance(TICK).await.unwrap();
+            let state = self.primary.console.mirror_state().await.unwrap();
+            write_mirror(&mut self.to_standby, &state).await.unwrap();
+            let state = read_mirror(&mut self.from_primary).await.unwrap().unwrap();
+            results.push(self.standby.console.follow_primary(state));
+            self.standby.advance(TICK).await.unwrap();
+            elapsed += TICK;
+        }
+        results
+    }
+
+    async fn assert_rendered_alike(&self) {
+        assert_eq!(
+            self.primary.console.rendered_universe(1).await,
+            self.standby.console.rendered_universe(1).await
+        );
+    }
+
+    fn standby_output(&self) -> Option<Vec<u8>> {
+        self.standby
+            .recording
+            .lock()
+            .unwrap()
+            .universes
+            .get(&1)
+            .cloned()
+    }
+}
+
+#[tokio::test]
+async fn the_standby_mirrors_the_primary_muted_until_it_takes_over() {
+    let mut pair = Pair::new("two_pars.json", "two_pars.json").await;
+    pair.primary.run_step("goto 0 1").await.unwrap();
+    pair.advance(Duration::from_millis(200)).await;
+    pair.primary.run_step("expect dmx 1 1 255").await.unwrap();
+    pair.assert_rendered_alike().await;
+    assert_eq!(pair.standby_output(), None);
+
+    pair.primary.run_step("goto 0 2").await.unwrap();
+    pair.advance(Duration::from_millis(200)).await;
+    pair.assert_rendered_alike().await;
+    assert_eq!(pair.standby_output(), None);
+
+    pair.standby.console.take_over().await.unwrap();
+    pair.standby.advance(TICK).await.unwrap();
+    assert!(!pair.standby.console.is_standby());
+    assert_eq!(
+        pair.standby_output(),
+        pair.primary.console.rendered_universe(1).await
+    );
+}
+
+#[tokio::test]
+async fn the_standby_takes_over_when_the_primary_goes_quiet() {
+    let mut pair = Pair::new("two_pars.json", "two_pars.json").await;
+    pair.primary.run_step("goto 0 1").await.unwrap();
+    pair.advance(Duration::from_millis(200)).await;
+
+    // The primary stops sending, and the standby carries the look on
+    pair.standby.advance(HEARTBEAT_TIMEOUT / 2).await.unwrap();
+    assert!(pair.standby.console.is_standby());
+    pair.standby.advance(HEARTBEAT_TIMEOUT).await.unwrap();
+    assert!(!pair.standby.console.is_standby());
+    pair.standby.run_step("expect dmx 1 1 255").await.unwrap();
+    pair.standby.run_step("expect cue 1").await.unwrap();
+}
+
+#[tokio::test]
+async fn a_standby_with_a_different_show_refuses_to_mirror() {
+    let mut pair = Pair::new("two_pars.json", "spot.json").await;
+    pair.primary.run_step("goto 0 1").await.unwrap();
+    let results = pair.advance(Duration::from_millis(100)).await;
+    assert!(results.iter().all(Result::is_err));
+    let reason = pair.standby.console.standby_refused().unwrap();
+    assert!(reason.contains("Refusing standby"), "{reason}");
+
+    // A refused standby stays muted rather than taking over a show it doesn't match
+    pair.standby.advance(HEARTBEAT_TIMEOUT * 2).await.unwrap();
+    assert!(pair.standby.console.is_standby());
+    assert_eq!(pair.standby_output(), None);
+}
@@ -0,0 +1,205 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{
+    effect_hz, ConsoleCommand, Effect, EffectDistribution, EffectMapping, EffectParams,
+    EffectRelease, EffectType, Interval, RhythmState, Settings, StaticValue, StrobeLimiter,
+};
+use halo_fixtures::{ChannelType, StrobeRange};
+use harness::Harness;
+
+fn rhythm() -> RhythmState {
+    RhythmState {
+        beat_phase: 0.0,
+        bar_phase: 0.0,
+        phrase_phase: 0.0,
+        beats_per_bar: 4,
+        bars_per_phrase: 4,
+        last_tap_time: None,
+        tap_count: 0,
+    }
+}
+
+fn square_on_dimmer(interval_ratio: f64) -> EffectMapping {
+    EffectMapping {
+        name: "Chase".to_string(),
+        effect: Effect {
+            effect_type: EffectType::Square,
+            params: EffectParams {
+                interval: Interval::Beat,
+                interval_ratio,
+                phase: 0.0,
+            },
+            ..Effect::default()
+        },
+        fixture_ids: vec![0, 1],
+        channel_types: vec![ChannelType::Dimmer],
+        distribution: EffectDistribution::All,
+        release: EffectRelease::Hold,
+    }
+}
+
+/// Load two_pars.json with Left Red asking for full speed strobe
+async fn load_with_strobe(settings: Settings) -> Harness {
+    let mut harness = Harness::new().await;
+    harness
+        .command(ConsoleCommand::UpdateSettings { settings })
+        .await
+        .unwrap();
+    harness.run_step("load two_pars.json").await.unwrap();
+
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues[1].static_values.push(StaticValue {
+        fixture_id: 0,
+        channel_type: ChannelType::Strobe,
+        value: 255,
+    });
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness
+}
+
+#[test]
+fn strobe_range_maps_values_to_rates() {
+    let range = StrobeRange::default();
+
+    assert_eq!(range.hz(0), None);
+    assert_eq!(range.hz(range.slowest), Some(range.min_hz));
+    assert_eq!(range.hz(range.fastest), Some(range.max_hz));
+    assert_eq!(range.value_for_hz(range.max_hz), range.fastest);
+    assert_eq!(range.value_for_hz(0.5), range.open);
+
+    let value = range.value_for_hz(5.0);
+    assert!(range.hz(value).unwrap() <= 5.0);
+}
+
+#[test]
+fn square_effects_are_slowed_to_the_limit() {
+    let mut limiter = StrobeLimiter::new();
+    let rhythm = rhythm();
+
+    // Eight flashes a beat at 120 BPM is 16Hz
+    let mapping = square_on_dimmer(8.0);
+    assert_eq!(effect_hz(&mapping.effect.params, 120.0, &rhythm), 16.0);
+
+    let limited = limiter
+        .limit_effect(&mapping, 120.0, &rhythm, Some(4.0), false)
+        .unwrap();
+    assert_eq!(effect_hz(&limited.effect.params, 120.0, &rhythm), 4.0);
+
+    // Slow enough effects pass through untouched
+    let slow = limiter
+        .limit_effect(&square_on_dimmer(1.0), 120.0, &rhythm, Some(4.0), false)
+        .unwrap();
+    assert_eq!(slow.effect.params.interval_ratio, 1.0);
+
+    // No-strobe mode drops square effects on intensity altogether
+    assert!(limiter
+        .limit_effect(&mapping, 120.0, &rhythm, None, true)
+        .is_none());
+}
+
+#[test]
+fn every_effect_on_intensity_is_slowed_to_the_limit() {
+    let mut limiter = StrobeLimiter::new();
+    let rhythm = rhythm();
+
+    let mut sawtooth = square_on_dimmer(8.0);
+    sawtooth.effect.effect_type = EffectType::Sawtooth;
+    let mut sine = square_on_dimmer(8.0);
+    sine.effect.effect_type = EffectType::Sine;
+    let mut registered = square_on_dimmer(8.0);
+    registered.effect.source = Some("sparkle".to_string());
+    let mut on_strobe = square_on_dimmer(8.0);
+    on_strobe.effect.effect_type = EffectType::Random;
+    on_strobe.channel_types = vec![ChannelType::Strobe];
+
+    for mapping in [&sawtooth, &sine, &registered, &on_strobe] {
+        let limited = limiter
+            .limit_effect(mapping, 120.0, &rhythm, Some(4.0), false)
+            .unwrap();
+        assert_eq!(effect_hz(&limited.effect.params, 120.0, &rhythm), 4.0);
+    }
+
+    // Fast color fades aren't flashes, so only intensity is held back
+    let mut color = sine.clone();
+    color.channel_types = vec![ChannelType::Red];
+    let color = limiter
+        .limit_effect(&color, 120.0, &rhythm, Some(4.0), false)
+        .unwrap();
+    assert_eq!(color.effect.params.interval_ratio, 8.0);
+
+    // No-strobe mode stops hard edges on intensity, whatever the waveform, and leaves the rest
+    for mapping in [&sawtooth, &registered, &on_strobe] {
+        assert!(limiter
+            .limit_effect(mapping, 120.0, &rhythm, None, true)
+            .is_none());
+    }
+    let mut dimmer_and_red = sawtooth.clone();
+    dimmer_and_red.channel_types.push(ChannelType::Red);
+    let red = limiter
+        .limit_effect(&dimmer_and_red, 120.0, &rhythm, None, true)
+        .unwrap();
+    assert_eq!(red.channel_types, vec![ChannelType::Red]);
+    assert!(limiter
+        .limit_effect(&sine, 120.0, &rhythm, None, true)
+        .is_some());
+}
+
+#[tokio::test]
+async fn a_cues_strobe_goes_out_on_the_strobe_channel() {
+    let mut harness = load_with_strobe(Settings::default()).await;
+    harness
+        .run_step("expect channel 0 strobe 255")
+        .await
+        .unwrap();
+    // The sixth channel of the left PAR's shehds-rgbw-par profile
+    harness.run_step("expect dmx 1 6 255").await.unwrap();
+}
+
+#[tokio::test]
+async fn strobe_channels_are_clamped_to_the_venue_limit() {
+    let mut harness = load_with_strobe(Settings {
+        max_strobe_hz: Some(5.0),
+        ..Settings::default()
+    })
+    .await;
+
+    let expected = StrobeRange::default().value_for_hz(5.0);
+    harness
+        .run_step(&format!("expect channel 0 strobe {expected}"))
+        .await
+        .unwrap();
+    harness
+        .run_step("expect channel 0 dimmer 255")
+        .await
+        .unwrap();
+}
+
+#[tokio::test]
+async fn no_strobe_mode_holds_strobe_channels_open() {
+    let mut harness = load_with_strobe(Settings {
+        no_strobe: true,
+        ..Settings::default()
+    })
+    .await;
+    harness.run_step("expect channel 0 strobe 0").await.unwrap();
+
+    // Leaving no-strobe mode puts the cue's strobe back
+    harness
+        .command(ConsoleCommand::UpdateSettings {
+            settings: Settings::default(),
+        })
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+    harness
+        .run_step("expect channel 0 strobe 255")
+        .await
+        .unwrap();
+}
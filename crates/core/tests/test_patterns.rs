@@ -0,0 +1,189 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{describe_step, ConsoleCommand, CueList, TestPattern};
+use halo_fixtures::{ChannelType, Fixture, FixtureLibrary};
+use harness::Harness;
+
+fn fixture(profile_id: &str) -> Fixture {
+    let library = FixtureLibrary::new();
+    let profile = library.profiles[profile_id].clone();
+    Fixture::new(
+        3,
+        "Under Test",
+        profile.clone(),
+        profile.channel_layout.clone(),
+        1,
+        1,
+    )
+}
+
+#[test]
+fn the_channel_walk_brings_up_every_channel_in_profile_order() {
+    for profile_id in ["shehds-rgbw-par", "shehds-led-spot-60w"] {
+        let fixture = fixture(profile_id);
+        let cue = TestPattern::ChannelWalk.cue(&fixture).unwrap();
+
+        // Everything starts at zero under the walk, the dimmer included
+        assert!(cue.static_values.iter().all(|v| v.value == 0));
+
+        let dimmer = fixture
+            .channels
+            .iter()
+            .position(|c| c.channel_type == ChannelType::Dimmer)
+            .unwrap();
+        let walked: Vec<usize> = cue
+            .chase
+            .unwrap()
+            .steps
+            .iter()
+            .map(|step| {
+                let value = &step.static_values[0];
+                assert_eq!(value.fixture_id, 3);
+                assert_eq!(value.value, 255);
+                let index = step.channel_index(&fixture, value).unwrap();
+                // The dimmer comes up with every other channel, so it lights them
+                let lit = step.static_values[1..]
+                    .iter()
+                    .map(|v| (step.channel_index(&fixture, v), v.value))
+                    .collect::<Vec<_>>();
+                match index == dimmer {
+                    true => assert!(lit.is_empty(), "{profile_id}"),
+                    false => assert_eq!(lit, [(Some(dimmer), 255)], "{profile_id}"),
+                }
+                index
+            })
+            .collect();
+        let layout: Vec<usize> = (0..fixture.channels.len()).collect();
+        assert_eq!(walked, layout, "{profile_id}");
+    }
+}
+
+#[test]
+fn patterns_need_the_channels_they_drive() {
+    let par = fixture("shehds-rgbw-par");
+    let error = TestPattern::PanTiltBox.cue(&par).unwrap_err();
+    assert!(error.contains("doesn't pan and tilt"), "{error}");
+
+    let spot = fixture("shehds-led-spot-60w");
+    let corners: Vec<String> = TestPattern::PanTiltBox
+        .cue(&spot)
+        .unwrap()
+        .chase
+        .unwrap()
+        .steps
+        .iter()
+        .map(|step| describe_step(&spot, step))
+        .collect();
+    assert_eq!(corners[0], "Pan (channel 1) at 64, Tilt (channel 2) at 64");
+    assert_eq!(corners.len(), 4);
+
+    let sweep = TestPattern::RgbSweep.cue(&par).unwrap();
+    let colors: Vec<ChannelType> = sweep
+        .chase
+        .unwrap()
+        .steps
+        .iter()
+        .map(|step| step.static_values[0].channel_type.clone())
+        .collect();
+    assert_eq!(
+        colors,
+        [
+            ChannelType::Red,
+            ChannelType::Green,
+            ChannelType::Blue,
+            ChannelType::White
+        ]
+    );
+    assert!(sweep
+        .static_values
+        .iter()
+        .any(|v| v.channel_type == ChannelType::Dimmer && v.value == 255));
+    assert!(TestPattern::RgbSweep.cue(&spot).is_err());
+}
+
+#[test]
+fn patterns_are_picked_by_name() {
+    for pattern in TestPattern::ALL {
+        assert_eq!(pattern.to_string().parse::<TestPattern>(), Ok(pattern));
+    }
+    let error = "strobe-test".parse::<TestPattern>().unwrap_err();
+    assert!(error.contains("channel-walk"), "{error}");
+}
+
+#[tokio::test]
+async fn the_channel_walk_steps_through_the_output_a_second_at_a_time() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    let left = harness.console.fixtures.read().await[0].clone();
+    let cue = TestPattern::ChannelWalk.cue(&left).unwrap();
+    harness
+        .command(ConsoleCommand::SetCueLists {
+            cue_lists: vec![CueList {
+                name: "Test".to_string(),
+                cues: vec![cue],
+                audio_file: None,
+                default_fade: None,
+                default_values: vec![],
+                move_in_black: None,
+            }],
+        })
+        .await
+        .unwrap();
+    harness.run_step("goto 0 0").await.unwrap();
+
+    // Dimmer first, then red, lit by the dimmer coming up with it
+    harness.advance(Duration::from_millis(500)).await.unwrap();
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+    harness.run_step("expect dmx 1 2 0").await.unwrap();
+    harness.advance(Duration::from_secs(1)).await.unwrap();
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+    harness.run_step("expect dmx 1 2 255").await.unwrap();
+    harness.run_step("expect dmx 1 3 0").await.unwrap();
+}
+
+#[tokio::test]
+async fn the_channel_walk_steps_through_channels_sharing_a_type() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    // The left PAR's third channel is a second red, as on a fixture with two cells
+    let left = {
+        let mut fixtures = harness.console.fixtures.write().await;
+        fixtures[0].channels[2].name = "Red 2".to_string();
+        fixtures[0].channels[2].channel_type = ChannelType::Red;
+        fixtures[0].clone()
+    };
+    let cue = TestPattern::ChannelWalk.cue(&left).unwrap();
+    let steps = cue.chase.clone().unwrap().steps;
+    assert_eq!(steps.len(), left.channels.len());
+    assert_eq!(
+        describe_step(&left, &steps[2]),
+        "Red 2 (channel 3), Dimmer (channel 1)"
+    );
+    harness
+        .command(ConsoleCommand::SetCueLists {
+            cue_lists: vec![CueList {
+                name: "Test".to_string(),
+                cues: vec![cue],
+                audio_file: None,
+                default_fade: None,
+                default_values: vec![],
+                move_in_black: None,
+            }],
+        })
+        .await
+        .unwrap();
+    harness.run_step("goto 0 0").await.unwrap();
+
+    // The dimmer's own step, then the first red, then the second on its own
+    harness.advance(Duration::from_millis(500)).await.unwrap();
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+    harness.advance(Duration::from_secs(1)).await.unwrap();
+    harness.run_step("expect dmx 1 2 255").await.unwrap();
+    harness.run_step("expect dmx 1 3 0").await.unwrap();
+    harness.advance(Duration::from_secs(1)).await.unwrap();
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+    harness.run_step("expect dmx 1 2 0").await.unwrap();
+    harness.run_step("expect dmx 1 3 255").await.unwrap();
+}
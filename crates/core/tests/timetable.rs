@@ -0,0 +1,180 @@
+mod harness;
+
+use std::time::Duration;
+
+use chrono::{NaiveDate, NaiveDateTime};
+use halo_core::{
+    ConsoleCommand, Cue, CueList, Settings, Timetable, TimetableAction, TimetableRule,
+};
+use harness::Harness;
+
+fn rules(json: &str) -> Vec<TimetableRule> {
+    serde_json::from_str(json).expect("timetable config")
+}
+
+/// Weekday evenings of ambient lighting, dark overnight and a dimmer Saturday lunchtime
+fn bar_rules() -> Vec<TimetableRule> {
+    rules(
+        r#"[
+            {"at": "18:00", "days": ["weekdays"], "action": {"type": "start", "cuelist": "Ambient"}},
+            {"at": "18:00", "days": ["weekdays"], "action": {"type": "grandmaster", "level": 0.8}},
+            {"at": "02:00", "action": {"type": "blackout", "time": 60}},
+            {"at": "12:00", "days": ["sat"], "action": {"type": "grandmaster", "level": 0.5}}
+        ]"#,
+    )
+}
+
+/// 2024-01-01 was a Monday
+fn monday(hour: u32, minute: u32) -> NaiveDateTime {
+    NaiveDate::from_ymd_opt(2024, 1, 1)
+        .unwrap()
+        .and_hms_opt(hour, minute, 0)
+        .unwrap()
+}
+
+fn label(at: NaiveDateTime, action: &TimetableAction) -> String {
+    let action = match action {
+        TimetableAction::Start { cue_list, .. } => format!("start {cue_list}"),
+        TimetableAction::Stop => "stop".to_string(),
+        TimetableAction::GrandMaster { level, .. } => format!("master {level}"),
+        TimetableAction::Blackout { time } => format!("blackout {time}"),
+    };
+    format!("{} {action}", at.format("%a %H:%M"))
+}
+
+#[test]
+fn a_week_runs_rules_in_time_then_declaration_order() {
+    let mut timetable = Timetable::new();
+    assert!(timetable.set_rules(&bar_rules()).is_empty());
+
+    // Starting Monday lunchtime catches up with last Friday's start and last night's blackout
+    let mut now = monday(12, 0);
+    let mut fired: Vec<String> = timetable
+        .poll(now)
+        .iter()
+        .map(|action| label(now, action))
+        .collect();
+
+    let end = now + chrono::Duration::days(7);
+    while now < end {
+        now += chrono::Duration::minutes(1);
+        fired.extend(timetable.poll(now).iter().map(|action| label(now, action)));
+    }
+
+    let mut expected = vec![
+        "Mon 12:00 start Ambient".to_string(),
+        "Mon 12:00 blackout 0".to_string(),
+    ];
+    for (evening, morning) in [
+        ("Mon", "Tue"),
+        ("Tue", "Wed"),
+        ("Wed", "Thu"),
+        ("Thu", "Fri"),
+        ("Fri", "Sat"),
+    ] {
+        expected.push(format!("{evening} 18:00 start Ambient"));
+        expected.push(format!("{evening} 18:00 master 0.8"));
+        expected.push(format!("{morning} 02:00 blackout 60"));
+    }
+    expected.extend([
+        "Sat 12:00 master 0.5".to_string(),
+        "Sun 02:00 blackout 60".to_string(),
+        "Mon 02:00 blackout 60".to_string(),
+    ]);
+    assert_eq!(fired, expected);
+}
+
+#[test]
+fn bad_rules_are_reported_and_skipped() {
+    let mut timetable = Timetable::new();
+    let errors = timetable.set_rules(&rules(
+        r#"[
+            {"at": "25:00", "action": {"type": "stop"}},
+            {"at": "18:00", "days": ["funday"], "action": {"type": "stop"}},
+            {"at": "18:00", "days": ["Fri"], "action": {"type": "stop"}}
+        ]"#,
+    ));
+    assert_eq!(errors.len(), 2, "{errors:?}");
+    assert!(errors[0].contains("'25:00'"));
+    assert!(errors[1].contains("'funday'"));
+}
+
+/// two_pars.json plus an ambient list on the right PAR, with the wall clock at `start`
+async fn bar(start: NaiveDateTime, timetable: Vec<TimetableRule>) -> Harness {
+    let mut harness = Harness::new().await;
+    harness.clock.set_local_time(start);
+    harness
+        .command(ConsoleCommand::UpdateSettings {
+            settings: Settings {
+                timetable,
+                ..Settings::default()
+            },
+        })
+        .await
+        .unwrap();
+    harness.run_step("load two_pars.json").await.unwrap();
+
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists.push(CueList {
+        name: "Ambient".to_string(),
+        cues: vec![
+            Cue::intensity_only("Warm", &[1], 200, Duration::ZERO),
+            Cue::intensity_only("Low", &[1], 100, Duration::ZERO),
+        ],
+        audio_file: None,
+        default_fade: None,
+        default_values: vec![],
+        move_in_black: None,
+    });
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+    harness
+}
+
+#[tokio::test]
+async fn restart_mid_evening_resumes_the_evening_state() {
+    let mut harness = bar(monday(20, 0), bar_rules()).await;
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+
+    // The ambient list is up at the 18:00 level, without waiting for tomorrow
+    assert_eq!(harness.console.grand_master_level().await, 0.8);
+    harness.run_step("expect dmx 1 10 160").await.unwrap();
+
+    // Then the overnight blackout fades out as usual
+    harness
+        .clock
+        .set_local_time(monday(23, 59) + chrono::Duration::hours(2));
+    harness.advance(Duration::from_secs(90)).await.unwrap();
+    assert!(harness.console.grand_master_level().await < 0.8);
+    harness.advance(Duration::from_secs(31)).await.unwrap();
+    harness.run_step("expect dmx 1 10 0").await.unwrap();
+}
+
+#[tokio::test]
+async fn restart_overnight_stays_dark() {
+    let mut harness = bar(monday(3, 0), bar_rules()).await;
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    assert_eq!(harness.console.grand_master_level().await, 0.0);
+    harness.run_step("expect dmx 1 10 0").await.unwrap();
+}
+
+#[tokio::test]
+async fn started_lists_can_step_round_on_their_own() {
+    let mut harness = bar(
+        monday(20, 0),
+        rules(
+            r#"[{"at": "18:00", "action": {"type": "start", "cuelist": "Ambient", "step_secs": 10}}]"#,
+        ),
+    )
+    .await;
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect dmx 1 10 200").await.unwrap();
+
+    // One step on, then round to the top after the last cue
+    harness.advance(Duration::from_secs(10)).await.unwrap();
+    harness.run_step("expect dmx 1 10 100").await.unwrap();
+    harness.advance(Duration::from_secs(10)).await.unwrap();
+    harness.run_step("expect dmx 1 10 200").await.unwrap();
+}
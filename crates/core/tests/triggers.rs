@@ -0,0 +1,330 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{ConsoleCommand, MidiMessage, Settings, Trigger, TriggerDispatcher, TriggerEvent};
+use harness::Harness;
+
+fn triggers(json: &str) -> Vec<Trigger> {
+    serde_json::from_str(json).expect("trigger config")
+}
+
+async fn load_with_triggers(triggers: Vec<Trigger>) -> Result<Harness, String> {
+    let mut harness = Harness::new().await;
+    harness
+        .command(ConsoleCommand::UpdateSettings {
+            settings: Settings {
+                triggers,
+                ..Settings::default()
+            },
+        })
+        .await?;
+    harness.run_step("load two_pars.json").await?;
+    Ok(harness)
+}
+
+#[tokio::test]
+async fn events_dispatch_to_cue_list_commands() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    let cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+
+    let mut dispatcher = TriggerDispatcher::new(triggers(
+        r#"[
+            {"type": "osc", "address": "/drop", "action": "goto", "cuelist": "main", "cue": "Blackout"},
+            {"type": "midi", "note": 60, "action": "go", "cuelist": "main"},
+            {"type": "midi", "cc": 1, "action": "crossfade", "cuelist": "main"}
+        ]"#,
+    ));
+    assert!(dispatcher.resolve(&cue_lists).is_empty());
+
+    let commands = dispatcher.dispatch(&TriggerEvent::Osc {
+        address: "/drop".to_string(),
+        args: vec![],
+    });
+    assert!(matches!(
+        commands[..],
+        [ConsoleCommand::GoToCue {
+            list_index: 0,
+            cue_index: 3
+        }]
+    ));
+
+    let commands = dispatcher.dispatch(&TriggerEvent::Midi(MidiMessage::NoteOn(60, 100)));
+    assert!(matches!(
+        commands[..],
+        [ConsoleCommand::NextCue { list_index: 0 }]
+    ));
+
+    let commands = dispatcher.dispatch(&TriggerEvent::Midi(MidiMessage::ControlChange(1, 127)));
+    assert!(
+        matches!(
+            commands[..],
+            [ConsoleCommand::SetCrossfade {
+                position,
+                list_index: Some(0)
+            }] if position == 1.0
+        ),
+        "{commands:?}"
+    );
+
+    // Releasing the note isn't an event of its own, but unmapped notes and addresses are
+    assert_eq!(dispatcher.unmatched_events(), 0);
+    assert!(dispatcher
+        .dispatch(&TriggerEvent::Midi(MidiMessage::NoteOff(60)))
+        .is_empty());
+    assert!(dispatcher
+        .dispatch(&TriggerEvent::Midi(MidiMessage::NoteOn(61, 100)))
+        .is_empty());
+    assert!(dispatcher
+        .dispatch(&TriggerEvent::Osc {
+            address: "/build".to_string(),
+            args: vec![1.0],
+        })
+        .is_empty());
+    assert_eq!(dispatcher.unmatched_events(), 2);
+}
+
+#[tokio::test]
+async fn letting_go_of_a_button_fires_nothing() {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    let cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+
+    let mut dispatcher = TriggerDispatcher::new(triggers(
+        r#"[
+            {"type": "osc", "address": "/go", "action": "go", "cuelist": "main"},
+            {"type": "midi", "cc": 20, "action": "goto", "cuelist": "main", "cue": "Blackout"},
+            {"type": "midi", "cc": 21, "action": "fadetoblack"},
+            {"type": "midi", "cc": 22, "action": "fadeup"},
+            {"type": "midi", "cc": 7, "action": "crossfade", "cuelist": "main"}
+        ]"#,
+    ));
+    assert!(dispatcher.resolve(&cue_lists).is_empty());
+
+    let go = |value: f32| TriggerEvent::Osc {
+        address: "/go".to_string(),
+        args: vec![value],
+    };
+    assert!(matches!(
+        dispatcher.dispatch(&go(1.0))[..],
+        [ConsoleCommand::NextCue { list_index: 0 }]
+    ));
+    assert!(dispatcher.dispatch(&go(0.0)).is_empty());
+    for cc in [20, 21, 22] {
+        assert_eq!(
+            dispatcher
+                .dispatch(&TriggerEvent::Midi(MidiMessage::ControlChange(cc, 127)))
+                .len(),
+            1
+        );
+        assert!(dispatcher
+            .dispatch(&TriggerEvent::Midi(MidiMessage::ControlChange(cc, 0)))
+            .is_empty());
+    }
+
+    // A fader is followed all the way down
+    assert!(matches!(
+        dispatcher.dispatch(&TriggerEvent::Midi(MidiMessage::ControlChange(7, 0)))[..],
+        [ConsoleCommand::SetCrossfade { position, .. }] if position == 0.0
+    ));
+    // Releases matched a trigger, so none of them count as unmatched
+    assert_eq!(dispatcher.unmatched_events(), 0);
+}
+
+#[test]
+fn rate_triggers_are_refused() {
+    let mut dispatcher = TriggerDispatcher::new(triggers(
+        r#"[{"type": "midi", "cc": 1, "action": "rate", "cuelist": "main"}]"#,
+    ));
+    let errors = dispatcher.resolve(&[]);
+    assert_eq!(errors.len(), 1);
+    assert!(errors[0].contains("playback rate"), "{errors:?}");
+}
+
+#[test]
+fn a_trigger_starts_and_stops_an_output_capture() {
+    let mut dispatcher = TriggerDispatcher::new(triggers(
+        r#"[{"type": "osc", "address": "/capture", "action": "capture"}]"#,
+    ));
+    // No cue list needed
+    assert!(dispatcher.resolve(&[]).is_empty());
+
+    let capture = |value: f32| TriggerEvent::Osc {
+        address: "/capture".to_string(),
+        args: vec![value],
+    };
+    assert!(matches!(
+        dispatcher.dispatch(&capture(1.0))[..],
+        [ConsoleCommand::StartOutputCapture { path: None }]
+    ));
+    assert!(matches!(
+        dispatcher.dispatch(&capture(0.0))[..],
+        [ConsoleCommand::StopOutputCapture]
+    ));
+}
+
+#[tokio::test]
+async fn triggers_are_checked_against_the_show_at_load() {
+    let result = load_with_triggers(triggers(
+        r#"[
+            {"type": "midi", "note": 60, "action": "go", "cuelist": "main"},
+            {"type": "midi", "note": 61, "action": "go", "cuelist": "encore"},
+            {"type": "osc", "address": "/drop", "action": "goto", "cuelist": "main", "cue": "Drop"},
+            {"type": "osc", "address": "/up", "action": "goto", "cuelist": "main"},
+            {"type": "midi", "action": "rate", "cuelist": "main"}
+        ]"#,
+    ))
+    .await;
+
+    let Err(message) = result else {
+        panic!("bad triggers weren't reported");
+    };
+    assert!(message.starts_with("4 trigger(s)"), "{message}");
+    for problem in ["'encore'", "'Drop'", "doesn't name a cue", "note or cc"] {
+        assert!(
+            message.contains(problem),
+            "{problem} missing from {message}"
+        );
+    }
+}
+
+#[tokio::test]
+async fn midi_and_osc_triggers_drive_playback() {
+    let mut harness = load_with_triggers(triggers(
+        r#"[
+            {"type": "midi", "note": 60, "action": "goto", "cuelist": "Main", "cue": "Left Red"},
+            {"type": "osc", "address": "/blackout", "action": "goto", "cuelist": "Main", "cue": "Blackout"}
+        ]"#,
+    ))
+    .await
+    .unwrap();
+
+    harness.run_step("midi 0x90 60 127").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect channel 0 red 255").await.unwrap();
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+
+    harness
+        .command(ConsoleCommand::ProcessOscMessage {
+            address: "/blackout".to_string(),
+            args: vec![],
+        })
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect channel 0 dimmer 0").await.unwrap();
+
+    // Notes without a trigger are counted and otherwise ignored
+    harness.run_step("midi 0x90 72 127").await.unwrap();
+    assert_eq!(harness.console.unmatched_trigger_events().await, 1);
+}
+
+#[tokio::test]
+async fn an_osc_fader_sets_a_channel_as_a_manual_source() {
+    let mut harness = load_with_triggers(triggers(
+        r#"[
+            {"type": "osc", "address": "/fader/1", "action": "manual", "fixture": 0, "channel": "dimmer"}
+        ]"#,
+    ))
+    .await
+    .unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+
+    let fader = |value: f32| ConsoleCommand::ProcessOscMessage {
+        address: "/fader/1".to_string(),
+        args: vec![value],
+    };
+    harness.command(fader(0.5)).await.unwrap();
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+    harness.run_step("expect dmx 1 1 128").await.unwrap();
+
+    // Pulled right down it holds the dimmer at 0 rather than letting go
+    harness.command(fader(0.0)).await.unwrap();
+    harness.advance(Duration::from_millis(50)).await.unwrap();
+    harness.run_step("expect dmx 1 1 0").await.unwrap();
+
+    // Released by its address, like any manual source
+    harness
+        .command(ConsoleCommand::ReleaseManualSource {
+            source: "/fader/1".to_string(),
+        })
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(1100)).await.unwrap();
+    harness.run_step("expect dmx 1 1 255").await.unwrap();
+
+    let mut dispatcher = TriggerDispatcher::new(triggers(
+        r#"[{"type": "midi", "cc": 7, "action": "manual", "fixture": 0}]"#,
+    ));
+    assert_eq!(
+        dispatcher.resolve(&[]),
+        ["Manual trigger on midi cc 7 needs a fixture and a channel"]
+    );
+}
+
+#[tokio::test]
+async fn velocity_scales_triggered_intensity() {
+    let mut harness = load_with_triggers(triggers(
+        r#"[
+            {"type": "midi", "note": 36, "action": "flash", "cuelist": "Main", "cue": "Left Red", "scale": "velocity"},
+            {"type": "osc", "address": "/pad", "action": "flash", "cuelist": "Main", "cue": "Left Red", "scale": "velocity"},
+            {"type": "midi", "note": 40, "action": "goto", "cuelist": "Main", "cue": "Right Half", "scale": "velocity"}
+        ]"#,
+    ))
+    .await
+    .unwrap();
+
+    // A soft hit flashes at a quarter of a hard one, and only the intensity scales
+    for (velocity, dimmer) in [(32, 64), (127, 255)] {
+        harness
+            .run_step(&format!("midi 0x90 36 {velocity}"))
+            .await
+            .unwrap();
+        harness.advance(Duration::from_millis(50)).await.unwrap();
+        harness
+            .run_step(&format!("expect dmx 1 1 {dimmer}"))
+            .await
+            .unwrap();
+        harness.run_step("expect dmx 1 2 255").await.unwrap();
+
+        harness.run_step("midi 0x80 36 0").await.unwrap();
+        harness.advance(Duration::from_millis(50)).await.unwrap();
+        harness.run_step("expect dmx 1 1 0").await.unwrap();
+    }
+
+    // OSC pads send velocity as their first argument, and 0 to let go
+    for (args, dimmer) in [(vec![32.0], 64), (vec![0.0], 0)] {
+        harness
+            .command(ConsoleCommand::ProcessOscMessage {
+                address: "/pad".to_string(),
+                args,
+            })
+            .await
+            .unwrap();
+        harness.advance(Duration::from_millis(50)).await.unwrap();
+        harness
+            .run_step(&format!("expect dmx 1 1 {dimmer}"))
+            .await
+            .unwrap();
+    }
+
+    for (velocity, dimmer) in [(32, 32), (127, 128)] {
+        harness
+            .run_step(&format!("midi 0x90 40 {velocity}"))
+            .await
+            .unwrap();
+        harness.advance(Duration::from_millis(50)).await.unwrap();
+        harness
+            .run_step(&format!("expect dmx 1 10 {dimmer}"))
+            .await
+            .unwrap();
+    }
+
+    // The stored cues keep their levels
+    let cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    assert_eq!(cue_lists[0].cues[1].static_values[0].value, 255);
+    assert_eq!(cue_lists[0].cues[2].static_values[0].value, 128);
+}
@@ -0,0 +1,118 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{
+    analyze_usage, ConsoleCommand, Cue, CueList, Effect, EffectDistribution, EffectMapping,
+    EffectRelease, StaticValue,
+};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+
+/// six_pars.json with cues that only reach the first three PARs
+async fn half_the_patch() -> Harness {
+    let mut harness = Harness::new().await;
+    harness.run_step("load six_pars.json").await.unwrap();
+
+    let mut chase = Cue::intensity_only("Chase", &[], 0, Duration::ZERO);
+    chase.effects.push(EffectMapping {
+        name: "Pulse".to_string(),
+        effect: Effect::default(),
+        fixture_ids: vec![1, 2],
+        channel_types: vec![ChannelType::Dimmer],
+        distribution: EffectDistribution::All,
+        release: EffectRelease::Hold,
+    });
+    let cue_lists = vec![CueList {
+        name: "Main".to_string(),
+        cues: vec![
+            Cue::color_only("Front", &[0, 1], (255, 0, 0), Duration::ZERO),
+            Cue::intensity_only("Front Up", &[0], 255, Duration::ZERO),
+            chase,
+        ],
+        audio_file: None,
+        default_fade: None,
+        default_values: vec![],
+        move_in_black: None,
+    }];
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+    harness
+}
+
+#[tokio::test]
+async fn fixtures_no_cue_reaches_are_reported() {
+    let harness = half_the_patch().await;
+    let fixtures = harness.console.fixtures.read().await.clone();
+    let cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    let report = analyze_usage(&fixtures, &cue_lists);
+
+    assert_eq!(report.unreferenced, ["PAR 4", "PAR 5", "PAR 6"]);
+    assert_eq!(
+        report.fixtures[0].referenced_by,
+        ["Cue 'Front' in 'Main'", "Cue 'Front Up' in 'Main'"]
+    );
+    assert_eq!(
+        report.fixtures[1].referenced_by,
+        ["Cue 'Front' in 'Main'", "Cue 'Chase' in 'Main'"]
+    );
+    assert_eq!(report.fixtures[2].referenced_by, ["Cue 'Chase' in 'Main'"]);
+
+    let warnings = report.warnings();
+    assert!(warnings.contains(&"PAR 5 is patched but no cue uses it".to_string()));
+}
+
+#[tokio::test]
+async fn channels_no_cue_sets_are_reported_per_fixture() {
+    let harness = half_the_patch().await;
+    let fixtures = harness.console.fixtures.read().await.clone();
+    let cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    let report = analyze_usage(&fixtures, &cue_lists);
+
+    // Front sets red, green and blue on PAR 1 and Front Up its dimmer
+    let unused = &report.fixtures[0].unused_channels;
+    assert!(!unused.contains(&ChannelType::Red));
+    assert!(!unused.contains(&ChannelType::Dimmer));
+    assert!(unused.contains(&ChannelType::White));
+    assert!(unused.contains(&ChannelType::Strobe));
+
+    // PAR 3 only gets the dimmer effect
+    let unused = &report.fixtures[2].unused_channels;
+    assert!(!unused.contains(&ChannelType::Dimmer));
+    assert!(unused.contains(&ChannelType::Red));
+
+    // Unreferenced fixtures aren't repeated channel by channel
+    assert!(!report
+        .warnings()
+        .iter()
+        .any(|w| w.starts_with("No cue sets") && w.ends_with("PAR 4")));
+}
+
+#[tokio::test]
+async fn cues_that_move_fixtures_that_cant_are_reported() {
+    let mut harness = half_the_patch().await;
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues[1].static_values.push(StaticValue {
+        fixture_id: 0,
+        channel_type: ChannelType::Tilt,
+        value: 200,
+    });
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+
+    let fixtures = harness.console.fixtures.read().await.clone();
+    let cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    let report = analyze_usage(&fixtures, &cue_lists);
+    assert_eq!(
+        report.fixtures[0].positioned_by,
+        ["Cue 'Front Up' in 'Main'"]
+    );
+    assert!(report.warnings().contains(
+        &"Cue 'Front Up' in 'Main' sets a position on PAR 1, which doesn't move".to_string()
+    ));
+    assert!(report.fixtures[1].positioned_by.is_empty());
+}
@@ -0,0 +1,185 @@
+mod harness;
+
+use std::path::{Path, PathBuf};
+use std::time::Duration;
+
+use halo_core::{ConsoleCommand, MusicalDuration, Variable};
+use harness::Harness;
+use serde_json::{json, Value};
+
+#[test]
+fn variables_read_as_colors_durations_and_numbers() {
+    assert_eq!("#FF2288".parse(), Ok(Variable::Color(255, 34, 136)));
+    assert_eq!(
+        "1b".parse(),
+        Ok(Variable::Duration(MusicalDuration::Beats(1.0)))
+    );
+    assert_eq!("128".parse(), Ok(Variable::Number(128.0)));
+    assert!("#FF22".parse::<Variable>().is_err());
+
+    assert_eq!(Variable::Color(255, 34, 136).to_string(), "#FF2288");
+    let vars: Value = serde_json::to_value([Variable::Color(0, 255, 0)]).unwrap();
+    assert_eq!(vars, json!(["#00FF00"]));
+}
+
+#[test]
+fn osc_arguments_keep_the_variables_sort() {
+    let accent = Variable::Color(255, 34, 136);
+    assert_eq!(
+        accent.parse_osc(&[0.0, 1.0, 0.5]),
+        Ok(Variable::Color(0, 255, 128))
+    );
+    assert!(accent.parse_osc(&[1.0]).is_err());
+
+    let rate = Variable::Duration(MusicalDuration::Beats(1.0));
+    assert_eq!(
+        rate.parse_osc(&[2.0]),
+        Ok(Variable::Duration(MusicalDuration::Beats(2.0)))
+    );
+}
+
+/// two_pars.json with Left Red's dimmer at `$level` and Right Half coloring the right PAR
+/// `$accent`
+fn write_show(dir: &Path, edit: impl FnOnce(&mut Value)) -> PathBuf {
+    let testdata = Path::new(env!("CARGO_MANIFEST_DIR")).join("tests/testdata/two_pars.json");
+    let mut show: Value =
+        serde_json::from_str(&std::fs::read_to_string(testdata).unwrap()).unwrap();
+    show["vars"] = json!({ "level": 200, "accent": "#FF2288" });
+    show["cue_lists"][0]["cues"][1]["static_values"][0]["value"] = json!("$level");
+    show["cue_lists"][0]["cues"][2]["variations"] =
+        json!([{ "fixture_ids": [1], "color_choices": [{ "color": "$accent" }] }]);
+    edit(&mut show);
+    let path = dir.join("variables.json");
+    std::fs::write(&path, serde_json::to_string(&show).unwrap()).unwrap();
+    path
+}
+
+async fn load(harness: &mut Harness, path: PathBuf) -> Result<(), String> {
+    harness.command(ConsoleCommand::LoadShow { path }).await
+}
+
+async fn set(harness: &mut Harness, name: &str, value: Variable) -> Result<(), String> {
+    harness
+        .command(ConsoleCommand::SetVariable {
+            name: name.to_string(),
+            value,
+        })
+        .await
+}
+
+#[tokio::test]
+async fn a_changed_variable_applies_the_next_time_the_cue_runs() {
+    let dir = tempfile::tempdir().unwrap();
+    let mut harness = Harness::new().await;
+    load(&mut harness, write_show(dir.path(), |_| {}))
+        .await
+        .unwrap();
+
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect dmx 1 1 200").await.unwrap();
+
+    // The running cue keeps the level it started with
+    set(&mut harness, "level", Variable::Number(100.0))
+        .await
+        .unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect dmx 1 1 200").await.unwrap();
+
+    harness.run_step("goto 0 0").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect dmx 1 1 100").await.unwrap();
+}
+
+#[tokio::test]
+async fn osc_sets_a_color_variable() {
+    let dir = tempfile::tempdir().unwrap();
+    let mut harness = Harness::new().await;
+    load(&mut harness, write_show(dir.path(), |_| {}))
+        .await
+        .unwrap();
+
+    harness.run_step("goto 0 2").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect dmx 1 11 255").await.unwrap();
+    harness.run_step("expect dmx 1 12 34").await.unwrap();
+    harness.run_step("expect dmx 1 13 136").await.unwrap();
+
+    harness
+        .command(ConsoleCommand::ProcessOscMessage {
+            address: "/halo/var/accent".to_string(),
+            args: vec![0.0, 1.0, 0.0],
+        })
+        .await
+        .unwrap();
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("goto 0 2").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect dmx 1 11 0").await.unwrap();
+    harness.run_step("expect dmx 1 12 255").await.unwrap();
+    harness.run_step("expect dmx 1 13 0").await.unwrap();
+}
+
+#[tokio::test]
+async fn variables_keep_their_sort() {
+    let dir = tempfile::tempdir().unwrap();
+    let mut harness = Harness::new().await;
+    load(&mut harness, write_show(dir.path(), |_| {}))
+        .await
+        .unwrap();
+
+    let error = set(&mut harness, "level", Variable::Color(0, 0, 0))
+        .await
+        .unwrap_err();
+    assert!(error.contains("$level is a number"), "{error}");
+    let error = set(&mut harness, "speed", Variable::Number(2.0))
+        .await
+        .unwrap_err();
+    assert!(error.contains("no variable $speed"), "{error}");
+}
+
+#[tokio::test]
+async fn shows_with_unknown_or_mismatched_variables_dont_load() {
+    let dir = tempfile::tempdir().unwrap();
+    let mut harness = Harness::new().await;
+
+    let unknown = write_show(dir.path(), |show| {
+        show["cue_lists"][0]["cues"][1]["static_values"][0]["value"] = json!("$levle");
+    });
+    let error = load(&mut harness, unknown).await.unwrap_err();
+    assert!(error.contains("Left Red"), "{error}");
+    assert!(error.contains("$levle"), "{error}");
+
+    // A color can't be a dimmer level
+    let mismatched = write_show(dir.path(), |show| {
+        show["cue_lists"][0]["cues"][1]["static_values"][0]["value"] = json!("$accent");
+    });
+    let error = load(&mut harness, mismatched).await.unwrap_err();
+    assert!(error.contains("Left Red"), "{error}");
+}
+
+#[tokio::test]
+async fn saving_keeps_the_references() {
+    let dir = tempfile::tempdir().unwrap();
+    let mut harness = Harness::new().await;
+    load(&mut harness, write_show(dir.path(), |_| {}))
+        .await
+        .unwrap();
+    set(&mut harness, "level", Variable::Number(100.0))
+        .await
+        .unwrap();
+
+    let show = harness.console.get_show().await;
+    assert_eq!(show.cue_lists[0].cues[1].static_values[0].value, 100);
+    let saved = serde_json::to_value(&show).unwrap();
+    assert_eq!(saved["vars"], json!({ "accent": "#FF2288", "level": 100 }));
+    let cues = &saved["cue_lists"][0]["cues"];
+    assert_eq!(cues[1]["static_values"][0]["value"], "$level");
+    assert_eq!(
+        cues[2]["variations"][0]["color_choices"][0]["color"],
+        "$accent"
+    );
+}
@@ -0,0 +1,129 @@
+mod harness;
+
+use std::time::Duration;
+
+use halo_core::{ConsoleCommand, StaticValue, Variation, WeightedColor};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+
+const RED: (u8, u8, u8) = (255, 0, 0);
+const BLUE: (u8, u8, u8) = (0, 0, 255);
+
+/// two_pars.json with Right Half sometimes adding white to the left PAR and always coloring
+/// the right PAR red or blue
+async fn load_with_variations(seed: u64) -> Harness {
+    let mut harness = Harness::new().await;
+    harness.run_step("load two_pars.json").await.unwrap();
+    let mut cue_lists = harness.console.cue_manager.read().await.get_cue_lists();
+    cue_lists[0].cues[2].variations = vec![
+        Variation {
+            probability: 0.3,
+            static_values: vec![StaticValue {
+                fixture_id: 0,
+                channel_type: ChannelType::White,
+                value: 255,
+            }],
+            fixture_ids: vec![],
+            color_choices: vec![],
+        },
+        Variation {
+            probability: 1.0,
+            static_values: vec![],
+            fixture_ids: vec![1],
+            color_choices: vec![
+                WeightedColor {
+                    color: RED,
+                    weight: 3.0,
+                },
+                WeightedColor {
+                    color: BLUE,
+                    weight: 1.0,
+                },
+            ],
+        },
+        Variation {
+            probability: 0.0,
+            static_values: vec![StaticValue {
+                fixture_id: 1,
+                channel_type: ChannelType::Strobe,
+                value: 255,
+            }],
+            fixture_ids: vec![],
+            color_choices: vec![],
+        },
+    ];
+    harness
+        .command(ConsoleCommand::SetCueLists { cue_lists })
+        .await
+        .unwrap();
+    harness.console.set_seed(seed).await;
+    harness
+}
+
+/// Run Right Half from a blackout `passes` times, noting each time whether the left PAR went
+/// white, the right PAR's color, and whether it strobed
+async fn passes(harness: &mut Harness, passes: usize) -> Vec<(bool, (u8, u8, u8), bool)> {
+    let mut seen = Vec::new();
+    for _ in 0..passes {
+        harness.run_step("goto 0 0").await.unwrap();
+        harness.advance(Duration::from_millis(50)).await.unwrap();
+        harness.run_step("goto 0 2").await.unwrap();
+        harness.advance(Duration::from_millis(50)).await.unwrap();
+
+        let fixtures = harness.console.fixtures.read().await;
+        let value = |fixture: usize, channel_type: ChannelType| {
+            fixtures[fixture].channel_value(&channel_type).unwrap()
+        };
+        seen.push((
+            value(0, ChannelType::White) == 255,
+            (
+                value(1, ChannelType::Red),
+                value(1, ChannelType::Green),
+                value(1, ChannelType::Blue),
+            ),
+            value(1, ChannelType::Strobe) == 255,
+        ));
+    }
+    seen
+}
+
+#[tokio::test]
+async fn variations_roll_each_time_the_cue_runs() {
+    let mut harness = load_with_variations(7).await;
+    let seen = passes(&mut harness, 20).await;
+
+    let white = seen.iter().filter(|(white, _, _)| *white).count();
+    assert!((1..20).contains(&white), "white on {white} of 20 passes");
+
+    let red = seen.iter().filter(|(_, color, _)| *color == RED).count();
+    let blue = seen.iter().filter(|(_, color, _)| *color == BLUE).count();
+    assert_eq!(red + blue, 20, "{seen:?}");
+    assert!(red > blue && blue > 0, "{red} red, {blue} blue");
+
+    assert!(seen.iter().all(|(_, _, strobe)| !strobe));
+}
+
+#[tokio::test]
+async fn the_same_seed_rolls_the_same_show() {
+    let mut first = load_with_variations(42).await;
+    let mut second = load_with_variations(42).await;
+    let mut other = load_with_variations(43).await;
+
+    let expected = passes(&mut first, 10).await;
+    assert_eq!(passes(&mut second, 10).await, expected);
+    assert_ne!(passes(&mut other, 10).await, expected);
+}
+
+#[tokio::test]
+async fn variations_hold_for_as_long_as_the_cue_runs() {
+    let mut harness = load_with_variations(7).await;
+    let first = passes(&mut harness, 1).await;
+    let applied = harness.console.applied_variations().await;
+    assert_eq!(applied.contains(&0), first[0].0);
+    assert!(applied.contains(&1));
+
+    for _ in 0..10 {
+        harness.advance(Duration::from_millis(100)).await.unwrap();
+        assert_eq!(harness.console.applied_variations().await, applied);
+    }
+}
@@ -0,0 +1,123 @@
+mod harness;
+
+use std::path::PathBuf;
+use std::time::Duration;
+
+use halo_core::{ConsoleCommand, Effect, EffectDistribution, EffectMapping, EffectRelease, Show};
+use halo_fixtures::ChannelType;
+use harness::Harness;
+
+fn testdata(name: &str) -> PathBuf {
+    PathBuf::from(env!("CARGO_MANIFEST_DIR"))
+        .join("tests/testdata")
+        .join(name)
+}
+
+/// two_pars.json with a chase across both PARs starting in Left Red
+fn write_rainbow_show(path: &std::path::Path) {
+    let mut show = Show::read(&testdata("two_pars.json")).unwrap();
+    show.name = "Opener".to_string();
+    show.cue_lists[0].cues[1].effects.push(EffectMapping {
+        name: "Rainbow".to_string(),
+        effect: Effect::default(),
+        fixture_ids: vec![0, 1],
+        channel_types: vec![ChannelType::Red, ChannelType::Green, ChannelType::Blue],
+        distribution: EffectDistribution::Wave(0.33),
+        release: EffectRelease::Hold,
+    });
+    std::fs::write(path, serde_json::to_string(&show).unwrap()).unwrap();
+}
+
+/// The opener running its chase, with the headliner loaded alongside it
+async fn opener_running(dir: &std::path::Path) -> Harness {
+    let opener = dir.join("opener.json");
+    write_rainbow_show(&opener);
+    let mut harness = Harness::new().await;
+    for (name, path) in [("Opener", opener), ("Headliner", testdata("two_pars.json"))] {
+        harness
+            .command(ConsoleCommand::LoadShowAs {
+                name: name.to_string(),
+                path,
+            })
+            .await
+            .unwrap();
+    }
+    activate(&mut harness, "Opener", 0.0).await;
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    assert_eq!(harness.console.active_effects().await, vec!["Rainbow"]);
+    harness
+}
+
+async fn activate(harness: &mut Harness, name: &str, transition_secs: f64) {
+    harness
+        .command(ConsoleCommand::ActivateShow {
+            name: name.to_string(),
+            transition_secs,
+        })
+        .await
+        .unwrap();
+}
+
+#[tokio::test]
+async fn switching_shows_cleans_up_the_outgoing_effects() {
+    let dir = tempfile::tempdir().unwrap();
+    let mut harness = opener_running(dir.path()).await;
+
+    activate(&mut harness, "Headliner", 2.0).await;
+    harness.advance(Duration::from_secs(1)).await.unwrap();
+    assert!(harness.console.active_effects().await.is_empty());
+    assert_eq!(harness.console.workspace().active(), Some("Opener"));
+
+    harness.advance(Duration::from_millis(1100)).await.unwrap();
+    assert_eq!(harness.console.workspace().active(), Some("Headliner"));
+    assert!(harness.console.active_effects().await.is_empty());
+    let cue_manager = harness.console.cue_manager.read().await;
+    assert_eq!(cue_manager.get_cue_lists()[0].cues[1].effects.len(), 0);
+    assert_eq!(cue_manager.next_go(), Some((0, 0)));
+}
+
+#[tokio::test]
+async fn manual_values_carry_across_a_switch() {
+    let dir = tempfile::tempdir().unwrap();
+    let mut harness = opener_running(dir.path()).await;
+    harness
+        .command(ConsoleCommand::SetManualValue {
+            source: "/fader/1".to_string(),
+            fixture_id: 0,
+            channel: "Dimmer".to_string(),
+            value: 40,
+        })
+        .await
+        .unwrap();
+
+    activate(&mut harness, "Headliner", 1.0).await;
+    harness.advance(Duration::from_millis(1100)).await.unwrap();
+    assert_eq!(harness.console.workspace().active(), Some("Headliner"));
+    harness.run_step("expect dmx 1 1 40").await.unwrap();
+
+    // The fader still holds the PAR over the incoming show's cues
+    harness.run_step("goto 0 1").await.unwrap();
+    harness.advance(Duration::from_millis(100)).await.unwrap();
+    harness.run_step("expect dmx 1 1 40").await.unwrap();
+}
+
+#[tokio::test]
+async fn the_running_show_cant_be_unloaded() {
+    let dir = tempfile::tempdir().unwrap();
+    let mut harness = opener_running(dir.path()).await;
+
+    let unload = |name: &str| ConsoleCommand::UnloadShow {
+        name: name.to_string(),
+    };
+    assert!(harness.command(unload("Opener")).await.is_err());
+    harness.command(unload("Headliner")).await.unwrap();
+    assert_eq!(harness.console.workspace().names(), vec!["Opener"]);
+    assert!(harness
+        .command(ConsoleCommand::ActivateShow {
+            name: "Headliner".to_string(),
+            transition_secs: 0.0,
+        })
+        .await
+        .is_err());
+}
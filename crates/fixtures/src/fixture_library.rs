@@ -1,4 +1,6 @@
-use std::collections::HashMap;
+use std::collections::{BTreeMap, HashMap};
+use std::ops::Range;
+use std::time::Duration;
 
 use serde::{Deserialize, Serialize};
 
@@ -11,6 +13,95 @@ pub struct FixtureProfile {
     pub manufacturer: String,
     pub model: String,
     pub channel_layout: Vec<Channel>,
+    /// Control sequences such as lamp on or reset that the fixture supports
+    pub commands: Vec<ControlCommand>,
+    /// How the strobe channel maps to flash rate, if it differs from the usual layout
+    pub strobe: Option<StrobeRange>,
+    /// Other channel layouts the fixture can be patched in, keyed by mode number
+    pub modes: BTreeMap<u8, Vec<Channel>>,
+    /// Correction applied to authored colors so they match across fixture types
+    pub color_calibration: Option<ColorCalibration>,
+    /// Colors on the fixture's wheel, for fixtures that can't mix RGB
+    pub color_wheel: Vec<WheelColor>,
+    /// Gobos on the fixture's gobo wheel, open first
+    pub gobo_wheel: Vec<GoboSlot>,
+    /// How far and how fast the head moves, for movers whose limits are known
+    pub motion: Option<Motion>,
+}
+
+impl FixtureProfile {
+    /// The profile's strobe range, or the common open-then-slow-to-fast layout
+    pub fn strobe_range(&self) -> StrobeRange {
+        self.strobe.unwrap_or_default()
+    }
+
+    /// The channel layout for a patch mode. No mode, or a mode matching the default layout's
+    /// channel count, gives the default layout.
+    pub fn layout(&self, mode: Option<u8>) -> Result<Vec<Channel>, String> {
+        let Some(mode) = mode else {
+            return Ok(self.channel_layout.clone());
+        };
+        if let Some(layout) = self.modes.get(&mode) {
+            return Ok(layout.clone());
+        }
+        if mode as usize == self.channel_layout.len() {
+            return Ok(self.channel_layout.clone());
+        }
+        Err(format!("Profile {} has no mode {mode}", self.id))
+    }
+
+    /// What the default channel layout can do
+    pub fn capabilities(&self) -> Capabilities {
+        Capabilities::of(&self.channel_layout)
+    }
+}
+
+/// What a channel layout can do, worked out from the channel types it has
+#[derive(Clone, Copy, Debug, Default, PartialEq, Eq)]
+pub struct Capabilities {
+    pub dimmer: bool,
+    /// Mixes color from emitters or has a color wheel
+    pub color: bool,
+    /// Has a pan or tilt channel
+    pub position: bool,
+    pub strobe: bool,
+    pub gobo: bool,
+    /// Has beam, focus or zoom channels
+    pub beam: bool,
+    pub pixels: bool,
+}
+
+impl Capabilities {
+    pub fn of(channels: &[Channel]) -> Self {
+        let mut capabilities = Self::default();
+        for channel in channels {
+            match channel.channel_type {
+                ChannelType::Dimmer => capabilities.dimmer = true,
+                ChannelType::Color
+                | ChannelType::Red
+                | ChannelType::Green
+                | ChannelType::Blue
+                | ChannelType::White
+                | ChannelType::Amber
+                | ChannelType::UV => capabilities.color = true,
+                ChannelType::Pan | ChannelType::Tilt => capabilities.position = true,
+                ChannelType::PanFine | ChannelType::TiltFine => {}
+                ChannelType::Strobe => capabilities.strobe = true,
+                ChannelType::Gobo => capabilities.gobo = true,
+                ChannelType::Beam | ChannelType::Focus | ChannelType::Zoom => {
+                    capabilities.beam = true
+                }
+                ChannelType::PixelRed(_)
+                | ChannelType::PixelGreen(_)
+                | ChannelType::PixelBlue(_) => capabilities.pixels = true,
+                ChannelType::TiltSpeed
+                | ChannelType::Function
+                | ChannelType::FunctionSpeed
+                | ChannelType::Other(_) => {}
+            }
+        }
+        capabilities
+    }
 }
 
 impl std::fmt::Display for FixtureProfile {
@@ -26,6 +117,13 @@ pub struct FixtureLibrary {
 
 impl FixtureLibrary {
     pub fn new() -> Self {
+        Self::from_definitions(Self::built_in_definitions())
+            .expect("built-in profile definitions resolve")
+    }
+
+    /// The profiles built into halo, with variants written as what they add to the profile
+    /// they extend
+    fn built_in_definitions() -> Vec<ProfileDefinition> {
         let mut profiles = HashMap::new();
 
         // Define all fixture profiles. Note in the future we'll load these from disk.
@@ -78,6 +176,13 @@ impl FixtureLibrary {
                         value: 0,
                     },
                 ],
+                commands: Vec::new(),
+                strobe: None,
+                modes: BTreeMap::new(),
+                color_calibration: None,
+                color_wheel: Vec::new(),
+                gobo_wheel: Vec::new(),
+                motion: None,
             },
         );
 
@@ -135,6 +240,39 @@ impl FixtureLibrary {
                         value: 0,
                     },
                 ],
+                commands: vec![ControlCommand {
+                    name: "Reset".to_string(),
+                    steps: vec![ControlStep {
+                        channel_type: ChannelType::Other("Reset".to_string()),
+                        value: 255,
+                        hold: Duration::from_secs(5),
+                    }],
+                }],
+                strobe: None,
+                modes: BTreeMap::new(),
+                color_calibration: None,
+                color_wheel: vec![
+                    WheelColor::new("White", 0, (255, 255, 255)),
+                    WheelColor::new("Red", 10, (255, 0, 0)),
+                    WheelColor::new("Green", 20, (0, 255, 0)),
+                    WheelColor::new("Blue", 30, (0, 0, 255)),
+                    WheelColor::new("Yellow", 40, (255, 255, 0)),
+                    WheelColor::new("Amber", 50, (255, 160, 0)),
+                    WheelColor::new("Cyan", 60, (0, 255, 255)),
+                    WheelColor::new("Magenta", 70, (255, 0, 255)),
+                ],
+                // Each gobo shakes, slow to fast, in its own band above the still gobos
+                gobo_wheel: vec![
+                    GoboSlot::new("Open", 0, None),
+                    GoboSlot::new("Gobo 1", 8, Some((64, 71))),
+                    GoboSlot::new("Gobo 2", 16, Some((72, 79))),
+                    GoboSlot::new("Gobo 3", 24, Some((80, 87))),
+                    GoboSlot::new("Gobo 4", 32, Some((88, 95))),
+                    GoboSlot::new("Gobo 5", 40, Some((96, 103))),
+                    GoboSlot::new("Gobo 6", 48, Some((104, 111))),
+                    GoboSlot::new("Gobo 7", 56, Some((112, 119))),
+                ],
+                motion: None,
             },
         );
 
@@ -198,6 +336,13 @@ impl FixtureLibrary {
                         value: 0,
                     },
                 ],
+                commands: Vec::new(),
+                strobe: None,
+                modes: BTreeMap::new(),
+                color_calibration: None,
+                color_wheel: Vec::new(),
+                gobo_wheel: Vec::new(),
+                motion: None,
             },
         );
 
@@ -225,6 +370,13 @@ impl FixtureLibrary {
                     // From slow to fast
                     ("Speed", ChannelType::Other("FunctionSpeed".to_string())),
                 ],
+                commands: Vec::new(),
+                strobe: None,
+                modes: BTreeMap::new(),
+                color_calibration: None,
+                color_wheel: Vec::new(),
+                gobo_wheel: Vec::new(),
+                motion: None,
             },
         );
 
@@ -278,6 +430,13 @@ impl FixtureLibrary {
                         value: 0,
                     },
                 ],
+                commands: Vec::new(),
+                strobe: None,
+                modes: BTreeMap::new(),
+                color_calibration: None,
+                color_wheel: Vec::new(),
+                gobo_wheel: Vec::new(),
+                motion: None,
             },
         );
 
@@ -313,6 +472,13 @@ impl FixtureLibrary {
                     ("Blue", ChannelType::Blue),
                     ("White", ChannelType::White),
                 ],
+                commands: Vec::new(),
+                strobe: None,
+                modes: BTreeMap::new(),
+                color_calibration: None,
+                color_wheel: Vec::new(),
+                gobo_wheel: Vec::new(),
+                motion: None,
             },
         );
 
@@ -330,38 +496,7 @@ impl FixtureLibrary {
         // 12	Intensity	White Dimmer	100%
 
         // https://personalities.avolites.com/?mainPage=Main.asp&LightName=LED+RGBW+4in1+48+Partition+Strobe+Light&Manufacturer=Unknown
-        // 12-channel variant
-        // profiles.insert(
-        //     "hyulights-led-rgbw-4in1-48-partition-strobe".to_string(),
-        //     FixtureProfile {
-        //         id: "hyulights-led-rgbw-4in1-48-partition-strobe".to_string(),
-        //         fixture_type: FixtureType::LEDBar,
-        //         manufacturer: "Hyulights".to_string(),
-        //         model: "200W LED RGBW 4in1 48 Partition Strobe Light".to_string(),
-        //         channel_layout: channel_layout![
-        //             ("Dimmer", ChannelType::Dimmer),
-        //             ("RGB Strobe", ChannelType::Other("RGBStrobe".to_string())),
-        //             ("Effect FX", ChannelType::Other("Function".to_string())),
-        //             (
-        //                 "Effect FX Speed",
-        //                 ChannelType::Other("FunctionSpeed".to_string())
-        //             ),
-        //             ("Color", ChannelType::Color),
-        //             ("Strobe", ChannelType::Strobe),
-        //             ("White FX", ChannelType::Other("WhiteFunction".to_string())),
-        //             (
-        //                 "White FX Speed",
-        //                 ChannelType::Other("WhiteFunctionSpeed".to_string())
-        //             ),
-        //             ("Red", ChannelType::Red),
-        //             ("Green", ChannelType::Green),
-        //             ("Blue", ChannelType::Blue),
-        //             ("White", ChannelType::White),
-        //         ],
-        //     },
-        // );
-
-        // 6-channel variant
+        // Patched in the 6-channel mode unless the show asks for mode 12
         profiles.insert(
             "hyulights-led-rgbw-4in1-48-partition-strobe".to_string(),
             FixtureProfile {
@@ -377,6 +512,35 @@ impl FixtureLibrary {
                     ("Blue", ChannelType::Blue),
                     ("White", ChannelType::White),
                 ],
+                commands: Vec::new(),
+                strobe: None,
+                modes: BTreeMap::from([(
+                    12,
+                    channel_layout![
+                        ("Dimmer", ChannelType::Dimmer),
+                        ("RGB Strobe", ChannelType::Other("RGBStrobe".to_string())),
+                        ("Effect FX", ChannelType::Other("Function".to_string())),
+                        (
+                            "Effect FX Speed",
+                            ChannelType::Other("FunctionSpeed".to_string())
+                        ),
+                        ("Color", ChannelType::Color),
+                        ("Strobe", ChannelType::Strobe),
+                        ("White FX", ChannelType::Other("WhiteFunction".to_string())),
+                        (
+                            "White FX Speed",
+                            ChannelType::Other("WhiteFunctionSpeed".to_string())
+                        ),
+                        ("Red", ChannelType::Red),
+                        ("Green", ChannelType::Green),
+                        ("Blue", ChannelType::Blue),
+                        ("White", ChannelType::White),
+                    ],
+                )]),
+                color_calibration: None,
+                color_wheel: Vec::new(),
+                gobo_wheel: Vec::new(),
+                motion: None,
             },
         );
 
@@ -397,61 +561,106 @@ impl FixtureLibrary {
                     ("Function", ChannelType::Function),
                     ("Function Speed", ChannelType::FunctionSpeed),
                 ],
+                commands: Vec::new(),
+                strobe: None,
+                modes: BTreeMap::new(),
+                color_calibration: None,
+                color_wheel: Vec::new(),
+                gobo_wheel: Vec::new(),
+                motion: None,
             },
         );
 
-        // Pixel Bar Fixtures
-        profiles.insert(
-            "generic-rgb-pixel-bar-30".to_string(),
-            FixtureProfile {
+        let mut definitions: Vec<ProfileDefinition> = profiles
+            .into_values()
+            .map(ProfileDefinition::from)
+            .collect();
+
+        // Pixel bars, each longer bar adding its pixels after those of the bar it extends
+        definitions.extend([
+            ProfileDefinition {
                 id: "generic-rgb-pixel-bar-30".to_string(),
-                fixture_type: FixtureType::PixelBar,
-                manufacturer: "Generic".to_string(),
-                model: "RGB Pixel Bar 30 Pixels".to_string(),
-                channel_layout: Self::create_pixel_bar_channels(30),
+                fixture_type: Some(FixtureType::PixelBar),
+                manufacturer: Some("Generic".to_string()),
+                model: Some("RGB Pixel Bar 30 Pixels".to_string()),
+                channels: Self::create_pixel_bar_channels(0..30),
+                ..ProfileDefinition::default()
             },
-        );
-
-        profiles.insert(
-            "generic-rgb-pixel-bar-60".to_string(),
-            FixtureProfile {
+            ProfileDefinition {
                 id: "generic-rgb-pixel-bar-60".to_string(),
-                fixture_type: FixtureType::PixelBar,
-                manufacturer: "Generic".to_string(),
-                model: "RGB Pixel Bar 60 Pixels".to_string(),
-                channel_layout: Self::create_pixel_bar_channels(60),
+                extends: Some("generic-rgb-pixel-bar-30".to_string()),
+                model: Some("RGB Pixel Bar 60 Pixels".to_string()),
+                channels: Self::create_pixel_bar_channels(30..60),
+                ..ProfileDefinition::default()
             },
-        );
-
-        profiles.insert(
-            "generic-rgb-pixel-bar-144".to_string(),
-            FixtureProfile {
+            ProfileDefinition {
                 id: "generic-rgb-pixel-bar-144".to_string(),
-                fixture_type: FixtureType::PixelBar,
-                manufacturer: "Generic".to_string(),
-                model: "RGB Pixel Bar 144 Pixels".to_string(),
-                channel_layout: Self::create_pixel_bar_channels(144),
+                extends: Some("generic-rgb-pixel-bar-60".to_string()),
+                model: Some("RGB Pixel Bar 144 Pixels".to_string()),
+                channels: Self::create_pixel_bar_channels(60..144),
+                ..ProfileDefinition::default()
             },
-        );
-
-        profiles.insert(
-            "clen-led-pixel-bar-64".to_string(),
-            FixtureProfile {
+            ProfileDefinition {
                 id: "clen-led-pixel-bar-64".to_string(),
-                fixture_type: FixtureType::PixelBar,
-                manufacturer: "Clen".to_string(),
-                model: "LED Pixel Bar 64 Pixels RGB".to_string(),
-                channel_layout: Self::create_pixel_bar_channels(64),
+                extends: Some("generic-rgb-pixel-bar-30".to_string()),
+                manufacturer: Some("Clen".to_string()),
+                model: Some("LED Pixel Bar 64 Pixels RGB".to_string()),
+                channels: Self::create_pixel_bar_channels(30..64),
+                ..ProfileDefinition::default()
             },
-        );
+        ]);
+        definitions
+    }
+
+    /// Build a library from profile definitions, flattening `extends` chains into complete
+    /// profiles. Fails if a definition extends a missing profile or its chain loops.
+    pub fn from_definitions(definitions: Vec<ProfileDefinition>) -> Result<Self, String> {
+        let by_id: HashMap<&str, &ProfileDefinition> =
+            definitions.iter().map(|d| (d.id.as_str(), d)).collect();
 
-        FixtureLibrary { profiles }
+        let mut profiles = HashMap::new();
+        for definition in &definitions {
+            let profile = Self::resolve(definition, &by_id)?;
+            profiles.insert(profile.id.clone(), profile);
+        }
+        Ok(FixtureLibrary { profiles })
     }
 
-    /// Create channel layout for a pixel bar with given number of pixels
-    fn create_pixel_bar_channels(pixel_count: usize) -> Vec<Channel> {
-        let mut channels = Vec::with_capacity(pixel_count * 3);
-        for i in 0..pixel_count {
+    fn resolve(
+        definition: &ProfileDefinition,
+        by_id: &HashMap<&str, &ProfileDefinition>,
+    ) -> Result<FixtureProfile, String> {
+        // Walk up to the root, then apply each definition over its parent
+        let mut chain = vec![definition];
+        while let Some(parent_id) = &chain[chain.len() - 1].extends {
+            if let Some(start) = chain.iter().position(|d| d.id == *parent_id) {
+                let mut cycle: Vec<&str> = chain[start..].iter().map(|d| d.id.as_str()).collect();
+                cycle.push(parent_id);
+                return Err(format!("Profile inheritance cycle: {}", cycle.join(" -> ")));
+            }
+            let parent = by_id.get(parent_id.as_str()).ok_or_else(|| {
+                format!(
+                    "Profile {} extends unknown profile {parent_id}",
+                    chain[chain.len() - 1].id
+                )
+            })?;
+            chain.push(parent);
+        }
+
+        let mut profile = FixtureProfile {
+            id: definition.id.clone(),
+            ..FixtureProfile::default()
+        };
+        for definition in chain.iter().rev() {
+            definition.apply(&mut profile);
+        }
+        Ok(profile)
+    }
+
+    /// Create channel layout for the given pixels of a pixel bar, counting from 0
+    fn create_pixel_bar_channels(pixels: Range<usize>) -> Vec<Channel> {
+        let mut channels = Vec::with_capacity(pixels.len() * 3);
+        for i in pixels {
             channels.push(Channel {
                 name: format!("Pixel {} Red", i + 1),
                 channel_type: ChannelType::PixelRed(i),
@@ -472,6 +681,247 @@ impl FixtureLibrary {
     }
 }
 
+/// A profile as written, before inheritance is flattened.
+///
+/// A definition that `extends` another only lists what differs: each channel replaces the
+/// parent's channel of the same name or is added after the parent's channels, each mode
+/// replaces the parent's mode with the same number, and fields left empty come from the parent.
+#[derive(Clone, Debug, Default)]
+pub struct ProfileDefinition {
+    pub id: String,
+    pub extends: Option<String>,
+    pub fixture_type: Option<FixtureType>,
+    pub manufacturer: Option<String>,
+    pub model: Option<String>,
+    pub channels: Vec<Channel>,
+    pub modes: BTreeMap<u8, Vec<Channel>>,
+    pub commands: Vec<ControlCommand>,
+    pub strobe: Option<StrobeRange>,
+    pub color_calibration: Option<ColorCalibration>,
+    pub color_wheel: Vec<WheelColor>,
+    pub gobo_wheel: Vec<GoboSlot>,
+    pub motion: Option<Motion>,
+}
+
+impl ProfileDefinition {
+    /// Layer this definition over the profile resolved from its parent
+    pub(crate) fn apply(&self, profile: &mut FixtureProfile) {
+        if let Some(fixture_type) = &self.fixture_type {
+            profile.fixture_type = fixture_type.clone();
+        }
+        if let Some(manufacturer) = &self.manufacturer {
+            profile.manufacturer = manufacturer.clone();
+        }
+        if let Some(model) = &self.model {
+            profile.model = model.clone();
+        }
+        for channel in &self.channels {
+            match profile
+                .channel_layout
+                .iter_mut()
+                .find(|c| c.name == channel.name)
+            {
+                Some(existing) => *existing = channel.clone(),
+                None => profile.channel_layout.push(channel.clone()),
+            }
+        }
+        profile.modes.extend(self.modes.clone());
+        if !self.commands.is_empty() {
+            profile.commands = self.commands.clone();
+        }
+        if self.strobe.is_some() {
+            profile.strobe = self.strobe;
+        }
+        if self.color_calibration.is_some() {
+            profile.color_calibration = self.color_calibration;
+        }
+        if !self.color_wheel.is_empty() {
+            profile.color_wheel = self.color_wheel.clone();
+        }
+        if !self.gobo_wheel.is_empty() {
+            profile.gobo_wheel = self.gobo_wheel.clone();
+        }
+        if self.motion.is_some() {
+            profile.motion = self.motion;
+        }
+    }
+}
+
+impl From<FixtureProfile> for ProfileDefinition {
+    fn from(profile: FixtureProfile) -> Self {
+        Self {
+            id: profile.id,
+            extends: None,
+            fixture_type: Some(profile.fixture_type),
+            manufacturer: Some(profile.manufacturer),
+            model: Some(profile.model),
+            channels: profile.channel_layout,
+            modes: profile.modes,
+            commands: profile.commands,
+            strobe: profile.strobe,
+            color_calibration: profile.color_calibration,
+            color_wheel: profile.color_wheel,
+            gobo_wheel: profile.gobo_wheel,
+            motion: profile.motion,
+        }
+    }
+}
+
+/// A named control sequence, e.g. lamp on, lamp off or reset
+#[derive(Clone, Debug, PartialEq)]
+pub struct ControlCommand {
+    pub name: String,
+    pub steps: Vec<ControlStep>,
+}
+
+/// Where a strobe channel is open and how its flash rate rises across the rest of its range
+#[derive(Clone, Copy, Debug, PartialEq)]
+pub struct StrobeRange {
+    /// Value that leaves the shutter open
+    pub open: u8,
+    /// Values from `slowest` to `fastest` strobe from `min_hz` up to `max_hz`
+    pub slowest: u8,
+    pub fastest: u8,
+    pub min_hz: f32,
+    pub max_hz: f32,
+}
+
+impl Default for StrobeRange {
+    fn default() -> Self {
+        Self {
+            open: 0,
+            slowest: 10,
+            fastest: 255,
+            min_hz: 1.0,
+            max_hz: 20.0,
+        }
+    }
+}
+
+impl StrobeRange {
+    /// Flash rate for a channel value, or `None` when the value doesn't strobe
+    pub fn hz(&self, value: u8) -> Option<f32> {
+        if value < self.slowest || value > self.fastest {
+            return None;
+        }
+        let span = (self.fastest - self.slowest).max(1) as f32;
+        let fraction = (value - self.slowest) as f32 / span;
+        Some(self.min_hz + (self.max_hz - self.min_hz) * fraction)
+    }
+
+    /// Fastest channel value that strobes at or below `hz`, or the open value when even the
+    /// slowest strobe is too fast
+    pub fn value_for_hz(&self, hz: f32) -> u8 {
+        if hz < self.min_hz {
+            return self.open;
+        }
+        let fraction = ((hz - self.min_hz) / (self.max_hz - self.min_hz)).clamp(0.0, 1.0);
+        let span = (self.fastest - self.slowest) as f32;
+        self.slowest + (span * fraction).floor() as u8
+    }
+}
+
+/// Correction from an authored color to the values a fixture needs to show it, so one color
+/// looks the same across fixture types
+#[derive(Clone, Copy, Debug, PartialEq, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum ColorCalibration {
+    /// Each row mixes the authored red, green and blue into one output channel
+    Matrix([[f32; 3]; 3]),
+    /// Scale red, green and blue on their own
+    Gain([f32; 3]),
+}
+
+impl ColorCalibration {
+    pub const IDENTITY: Self = ColorCalibration::Gain([1.0, 1.0, 1.0]);
+
+    /// The calibrated color, clamped to the channel range
+    pub fn apply(&self, rgb: (u8, u8, u8)) -> (u8, u8, u8) {
+        let input = [rgb.0 as f32, rgb.1 as f32, rgb.2 as f32];
+        let output = match self {
+            ColorCalibration::Matrix(matrix) => matrix.map(|row| {
+                row.iter()
+                    .zip(input)
+                    .map(|(weight, value)| weight * value)
+                    .sum::<f32>()
+            }),
+            ColorCalibration::Gain(gain) => [0, 1, 2].map(|i| gain[i] * input[i]),
+        };
+        let channel = |value: f32| value.round().clamp(0.0, 255.0) as u8;
+        (channel(output[0]), channel(output[1]), channel(output[2]))
+    }
+}
+
+/// A moving head's range of travel and top speed on each axis, in degrees and degrees per
+/// second
+#[derive(Clone, Copy, Debug, PartialEq, Serialize, Deserialize)]
+pub struct Motion {
+    pub pan_range: f32,
+    pub tilt_range: f32,
+    pub pan_speed: f32,
+    pub tilt_speed: f32,
+}
+
+impl Motion {
+    /// A head with the usual 540 degree pan and 270 degree tilt
+    pub fn new(pan_speed: f32, tilt_speed: f32) -> Self {
+        Self {
+            pan_range: 540.0,
+            tilt_range: 270.0,
+            pan_speed,
+            tilt_speed,
+        }
+    }
+}
+
+/// A slot on a color wheel and roughly what it looks like.
+///
+/// The wheel sits on a slot from its value up to the next slot's.
+#[derive(Clone, Debug, PartialEq, Serialize, Deserialize)]
+pub struct WheelColor {
+    pub name: String,
+    pub value: u8,
+    pub rgb: (u8, u8, u8),
+}
+
+impl WheelColor {
+    fn new(name: &str, value: u8, rgb: (u8, u8, u8)) -> Self {
+        Self {
+            name: name.to_string(),
+            value,
+            rgb,
+        }
+    }
+}
+
+/// A gobo on a gobo wheel: the value that puts it in the beam and, for gobos the fixture can
+/// shake, the range that shakes it from slow to fast
+#[derive(Clone, Debug, PartialEq, Serialize, Deserialize)]
+pub struct GoboSlot {
+    pub name: String,
+    pub value: u8,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub shake: Option<(u8, u8)>,
+}
+
+impl GoboSlot {
+    fn new(name: &str, value: u8, shake: Option<(u8, u8)>) -> Self {
+        Self {
+            name: name.to_string(),
+            value,
+            shake,
+        }
+    }
+}
+
+/// Hold a channel at a value for a while
+#[derive(Clone, Debug, PartialEq)]
+pub struct ControlStep {
+    pub channel_type: ChannelType,
+    pub value: u8,
+    pub hold: Duration,
+}
+
 #[derive(Clone, Debug)]
 pub struct Channel {
     pub name: String,
@@ -493,6 +943,10 @@ pub enum ChannelType {
     Strobe,
     Pan,
     Tilt,
+    /// Low byte of a 16-bit pan, under the Pan channel's high byte
+    PanFine,
+    /// Low byte of a 16-bit tilt, under the Tilt channel's high byte
+    TiltFine,
     TiltSpeed,
     Beam,
     Focus,
@@ -520,6 +974,8 @@ impl std::fmt::Display for ChannelType {
             ChannelType::Strobe => write!(f, "Strobe"),
             ChannelType::Pan => write!(f, "Pan"),
             ChannelType::Tilt => write!(f, "Tilt"),
+            ChannelType::PanFine => write!(f, "PanFine"),
+            ChannelType::TiltFine => write!(f, "TiltFine"),
             ChannelType::TiltSpeed => write!(f, "TiltSpeed"),
             ChannelType::Beam => write!(f, "Beam"),
             ChannelType::Focus => write!(f, "Focus"),
@@ -533,3 +989,61 @@ impl std::fmt::Display for ChannelType {
         }
     }
 }
+
+impl ChannelType {
+    /// The channel type an attribute name stands for, ignoring case, e.g. "tilt" or "UV".
+    /// Names that aren't a known type are kept as [`ChannelType::Other`].
+    pub fn from_name(name: &str) -> Self {
+        match name.to_lowercase().as_str() {
+            "dimmer" => ChannelType::Dimmer,
+            "color" => ChannelType::Color,
+            "gobo" => ChannelType::Gobo,
+            "red" => ChannelType::Red,
+            "green" => ChannelType::Green,
+            "blue" => ChannelType::Blue,
+            "white" => ChannelType::White,
+            "amber" => ChannelType::Amber,
+            "uv" => ChannelType::UV,
+            "strobe" => ChannelType::Strobe,
+            "pan" => ChannelType::Pan,
+            "tilt" => ChannelType::Tilt,
+            "panfine" | "pan fine" | "pan_fine" => ChannelType::PanFine,
+            "tiltfine" | "tilt fine" | "tilt_fine" => ChannelType::TiltFine,
+            "tiltspeed" => ChannelType::TiltSpeed,
+            "beam" => ChannelType::Beam,
+            "focus" => ChannelType::Focus,
+            "zoom" => ChannelType::Zoom,
+            "function" => ChannelType::Function,
+            "functionspeed" => ChannelType::FunctionSpeed,
+            _ => ChannelType::Other(name.to_string()),
+        }
+    }
+
+    /// The low byte channel that makes this one 16-bit, for pan and tilt
+    pub fn fine_channel(&self) -> Option<ChannelType> {
+        match self {
+            ChannelType::Pan => Some(ChannelType::PanFine),
+            ChannelType::Tilt => Some(ChannelType::TiltFine),
+            _ => None,
+        }
+    }
+
+    /// The high byte channel a fine channel sits under
+    pub fn coarse_channel(&self) -> Option<ChannelType> {
+        match self {
+            ChannelType::PanFine => Some(ChannelType::Pan),
+            ChannelType::TiltFine => Some(ChannelType::Tilt),
+            _ => None,
+        }
+    }
+}
+
+/// A 16-bit value split into its high (coarse) and low (fine) bytes
+pub fn split_16(value: u16) -> (u8, u8) {
+    ((value >> 8) as u8, (value & 0xFF) as u8)
+}
+
+/// A 16-bit value from its high (coarse) and low (fine) bytes
+pub fn join_16(coarse: u8, fine: u8) -> u16 {
+    (coarse as u16) << 8 | fine as u16
+}
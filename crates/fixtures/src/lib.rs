@@ -1,8 +1,15 @@
-pub use fixture_library::{Channel, ChannelType, FixtureLibrary, FixtureProfile};
+pub use fixture_library::{
+    join_16, split_16, Capabilities, Channel, ChannelType, ColorCalibration, ControlCommand,
+    ControlStep, FixtureLibrary, FixtureProfile, GoboSlot, Motion, ProfileDefinition, StrobeRange,
+    WheelColor,
+};
+pub use profile_file::{ProfileFile, ProfileFileError, ProfileLoad};
 use serde::{Deserialize, Serialize};
 
 mod fixture_library;
+mod profile_file;
 
+/// Soft limits on where a moving fixture may point, e.g. to keep it off the audience
 #[derive(Clone, Debug, Serialize, Deserialize)]
 pub struct PanTiltLimits {
     pub pan_min: u8,
@@ -11,6 +18,80 @@ pub struct PanTiltLimits {
     pub tilt_max: u8,
 }
 
+impl PanTiltLimits {
+    /// The lowest and highest value allowed on a pan or tilt channel, `None` for other
+    /// channels
+    pub fn range(&self, channel_type: &ChannelType) -> Option<(u8, u8)> {
+        match channel_type {
+            ChannelType::Pan => Some((self.pan_min, self.pan_max)),
+            ChannelType::Tilt => Some((self.tilt_min, self.tilt_max)),
+            _ => None,
+        }
+    }
+}
+
+/// How a moving fixture is hung, corrected for as its values go out so cues treat every
+/// fixture as if it were hung the same way. Inversion flips an axis end for end, and a swap
+/// sends pan on the tilt channels and tilt on the pan channels, inverted first if asked.
+#[derive(Clone, Copy, Debug, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Orientation {
+    #[serde(default)]
+    pub invert_pan: bool,
+    #[serde(default)]
+    pub invert_tilt: bool,
+    #[serde(default)]
+    pub swap_pan_tilt: bool,
+}
+
+impl Orientation {
+    /// Whether values go out as cues set them
+    pub fn is_upright(&self) -> bool {
+        *self == Self::default()
+    }
+
+    /// The channel whose value goes out on a channel of type `channel_type`. Swapping trades
+    /// pan and tilt both ways, so this also gives where a value sent on it came from.
+    fn source(&self, channel_type: &ChannelType) -> ChannelType {
+        match (self.swap_pan_tilt, channel_type) {
+            (true, ChannelType::Pan) => ChannelType::Tilt,
+            (true, ChannelType::Tilt) => ChannelType::Pan,
+            (true, ChannelType::PanFine) => ChannelType::TiltFine,
+            (true, ChannelType::TiltFine) => ChannelType::PanFine,
+            _ => channel_type.clone(),
+        }
+    }
+
+    /// `value` for a channel of type `channel_type` flipped if its axis is inverted, which
+    /// undoes itself for reading a sent value back
+    fn invert(&self, channel_type: &ChannelType, value: u8) -> u8 {
+        let inverted = match channel_type {
+            ChannelType::Pan | ChannelType::PanFine => self.invert_pan,
+            ChannelType::Tilt | ChannelType::TiltFine => self.invert_tilt,
+            _ => false,
+        };
+        if inverted {
+            255 - value
+        } else {
+            value
+        }
+    }
+}
+
+/// A pan and tilt pair, e.g. one fixture's entry in a position preset
+#[derive(Clone, Copy, Debug, PartialEq, Eq, Serialize, Deserialize)]
+pub struct PanTilt {
+    pub pan: u8,
+    pub tilt: u8,
+}
+
+/// Where a fixture sits on the stage plot, normalized so (0, 0) is upstage left and (1, 1) is
+/// downstage right
+#[derive(Clone, Copy, Debug, PartialEq, Serialize, Deserialize)]
+pub struct StagePosition {
+    pub x: f32,
+    pub y: f32,
+}
+
 #[derive(Clone, Debug, Serialize, Deserialize)]
 pub struct Fixture {
     pub id: usize,
@@ -24,9 +105,20 @@ pub struct Fixture {
     pub start_address: u16,
     #[serde(default)]
     pub pan_tilt_limits: Option<PanTiltLimits>,
+    /// How the fixture is hung, applied to pan and tilt on the way out
+    #[serde(default, skip_serializing_if = "Orientation::is_upright")]
+    pub orientation: Orientation,
+    #[serde(default)]
+    pub position: Option<StagePosition>,
+    /// Which of the profile's channel layouts the fixture is patched in
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub mode: Option<u8>,
+    /// Former names the fixture still answers to, so a rename doesn't break what refers to it
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub aliases: Vec<String>,
 }
 
-#[derive(Clone, Debug, PartialEq, Eq, Hash, Default)]
+#[derive(Clone, Debug, PartialEq, Eq, Hash, Default, Serialize, Deserialize)]
 pub enum FixtureType {
     #[default]
     MovingHead,
@@ -57,36 +149,130 @@ impl Fixture {
             universe,
             start_address,
             pan_tilt_limits: None,
+            orientation: Orientation::default(),
+            position: None,
+            mode: None,
+            aliases: Vec::new(),
         }
     }
 
+    /// Set the first channel of the given type. Fixtures without one are left as they are,
+    /// rather than having the value land on some other channel.
     pub fn set_channel_value(&mut self, channel_type: &ChannelType, value: u8) {
-        if let Some(channel) = self
+        if let Some(index) = self
             .channels
-            .iter_mut()
-            .find(|c| c.channel_type == *channel_type)
+            .iter()
+            .position(|c| c.channel_type == *channel_type)
         {
+            self.set_channel_value_at(index, value);
+        }
+    }
+
+    /// Set the channel at `index` in the fixture's channel list, e.g. the second of two Red
+    /// channels, within the pan/tilt limits of its type. Indices past the end are ignored.
+    pub fn set_channel_value_at(&mut self, index: usize, value: u8) {
+        if let Some(channel) = self.channels.get(index) {
+            let channel_type = &channel.channel_type;
             // Apply pan/tilt limits if they exist
-            let clamped_value = if let Some(limits) = &self.pan_tilt_limits {
-                match channel_type {
-                    ChannelType::Pan => value.clamp(limits.pan_min, limits.pan_max),
-                    ChannelType::Tilt => value.clamp(limits.tilt_min, limits.tilt_max),
-                    _ => value,
-                }
-            } else {
-                value
+            let range = self
+                .pan_tilt_limits
+                .as_ref()
+                .and_then(|limits| limits.range(channel_type));
+            let clamped_value = match range {
+                Some((min, max)) => value.clamp(min, max),
+                None => value,
             };
+            if clamped_value != value {
+                log::debug!(
+                    "{} {channel_type} held at {clamped_value} rather than {value} by its limits",
+                    self.name
+                );
+            }
 
-            channel.value = clamped_value;
+            self.channels[index].value = clamped_value;
         }
     }
 
+    /// Set a channel to a 16-bit value, split across it and its fine channel when the profile
+    /// has both. Fixtures with only the coarse channel get the high byte, so an 8-bit value
+    /// shifted up lands exactly where it always did.
+    pub fn set_channel_value_16(&mut self, channel_type: &ChannelType, value: u16) {
+        let (coarse, fine) = split_16(value);
+        self.set_channel_value(channel_type, coarse);
+        if let Some(fine_channel) = channel_type.fine_channel() {
+            self.set_channel_value(&fine_channel, fine);
+        }
+    }
+
+    /// A channel's value with its fine channel as the low byte, zero if the profile has none
+    pub fn channel_value_16(&self, channel_type: &ChannelType) -> Option<u16> {
+        let coarse = self.channel_value(channel_type)?;
+        let fine = channel_type
+            .fine_channel()
+            .and_then(|fine| self.channel_value(&fine))
+            .unwrap_or(0);
+        Some(join_16(coarse, fine))
+    }
+
+    /// Whether the fixture has a fine channel under `channel_type`
+    pub fn has_fine_channel(&self, channel_type: &ChannelType) -> bool {
+        channel_type
+            .fine_channel()
+            .is_some_and(|fine| self.channel_value(&fine).is_some())
+    }
+
+    /// What each channel sends, in layout order, with the fixture's orientation applied
     pub fn get_dmx_values(&self) -> Vec<u8> {
-        let mut values = Vec::new();
-        for channel in &self.channels {
-            values.push(channel.value);
+        (0..self.channels.len())
+            .map(|i| self.dmx_value(i))
+            .collect()
+    }
+
+    /// What the channel at `offset` in the layout sends, with the fixture's orientation applied
+    pub fn dmx_value(&self, offset: usize) -> u8 {
+        let channel = &self.channels[offset];
+        if self.orientation.is_upright() {
+            return channel.value;
+        }
+        let source = self.orientation.source(&channel.channel_type);
+        // A swap onto an axis the fixture has no channel for leaves the channel as it is
+        let value = self.channel_value(&source).unwrap_or(channel.value);
+        self.orientation.invert(&source, value)
+    }
+
+    /// Each channel's name and absolute address in the fixture's universe, in layout order
+    pub fn channel_map(&self) -> Vec<(String, u16)> {
+        self.channels
+            .iter()
+            .enumerate()
+            .map(|(offset, c)| (c.name.clone(), self.start_address + offset as u16))
+            .collect()
+    }
+
+    /// What the fixture can do in the mode it's patched in
+    pub fn capabilities(&self) -> Capabilities {
+        Capabilities::of(&self.channels)
+    }
+
+    /// Absolute address of the first channel of the given type
+    pub fn channel_address(&self, channel_type: &ChannelType) -> Option<u16> {
+        self.channels
+            .iter()
+            .position(|c| c.channel_type == *channel_type)
+            .map(|offset| self.start_address + offset as u16)
+    }
+
+    /// The part of `min` to `max` the fixture's limits allow on `channel_type`, for an effect
+    /// to sweep within rather than running into the limits and sticking there
+    pub fn limited_range(&self, channel_type: &ChannelType, min: u8, max: u8) -> (u8, u8) {
+        match self
+            .pan_tilt_limits
+            .as_ref()
+            .and_then(|limits| limits.range(channel_type))
+        {
+            Some((low, high)) => (min.clamp(low, high), max.clamp(low, high)),
+            None => (min, max),
         }
-        values
     }
 
     pub fn set_pan_tilt_limits(&mut self, limits: PanTiltLimits) {
@@ -100,6 +286,228 @@ impl Fixture {
     pub fn get_pan_tilt_limits(&self) -> Option<&PanTiltLimits> {
         self.pan_tilt_limits.as_ref()
     }
+
+    /// Current value of the first channel of the given type
+    pub fn channel_value(&self, channel_type: &ChannelType) -> Option<u8> {
+        self.channels
+            .iter()
+            .find(|c| c.channel_type == *channel_type)
+            .map(|c| c.value)
+    }
+
+    /// Each channel's type and value read out of a universe's DMX through the fixture's
+    /// channel map, the reverse of writing `get_dmx_values` at its start address, orientation
+    /// included. Channels past the end of `data` are left out.
+    pub fn values_from_dmx(&self, data: &[u8]) -> Vec<(ChannelType, u8)> {
+        let start = self.start_address.saturating_sub(1) as usize;
+        self.channels
+            .iter()
+            .enumerate()
+            .map_while(|(offset, c)| {
+                let source = self.orientation.source(&c.channel_type);
+                let value = self.orientation.invert(&source, *data.get(start + offset)?);
+                Some((source, value))
+            })
+            .collect()
+    }
+
+    /// The value of the first channel of the given type in a universe's DMX, as a cue would
+    /// have set it
+    pub fn channel_value_in(&self, data: &[u8], channel_type: &ChannelType) -> Option<u8> {
+        let address = self.channel_address(&self.orientation.source(channel_type))?;
+        let value = data.get(address.saturating_sub(1) as usize).copied()?;
+        Some(self.orientation.invert(channel_type, value))
+    }
+
+    /// The color wheel slot the fixture is sitting on, if it has a wheel
+    pub fn wheel_color(&self) -> Option<&WheelColor> {
+        let value = self.channel_value(&ChannelType::Color)?;
+        self.profile
+            .color_wheel
+            .iter()
+            .filter(|slot| slot.value <= value)
+            .max_by_key(|slot| slot.value)
+    }
+
+    /// Approximate color the fixture is emitting, scaled by its dimmer.
+    ///
+    /// Fixtures with neither RGB channels nor a color wheel are treated as white.
+    pub fn display_color(&self) -> (u8, u8, u8) {
+        let has_color = self.channels.iter().any(|c| {
+            matches!(
+                c.channel_type,
+                ChannelType::Red | ChannelType::Green | ChannelType::Blue | ChannelType::White
+            )
+        });
+
+        let (r, g, b) = if has_color {
+            let red = self.channel_value(&ChannelType::Red).unwrap_or(0) as u16;
+            let green = self.channel_value(&ChannelType::Green).unwrap_or(0) as u16;
+            let blue = self.channel_value(&ChannelType::Blue).unwrap_or(0) as u16;
+            let white = self.channel_value(&ChannelType::White).unwrap_or(0) as u16;
+            let amber = self.channel_value(&ChannelType::Amber).unwrap_or(0) as u16;
+            (red + white + amber, green + white + amber / 2, blue + white)
+        } else if let Some(slot) = self.wheel_color() {
+            let (r, g, b) = slot.rgb;
+            (r as u16, g as u16, b as u16)
+        } else {
+            (255, 255, 255)
+        };
+
+        let intensity = self
+            .channel_value(&ChannelType::Dimmer)
+            .map(|d| d as f32 / 255.0)
+            .unwrap_or(1.0);
+        let scale = |c: u16| (c.min(255) as f32 * intensity) as u8;
+
+        (scale(r), scale(g), scale(b))
+    }
+
+    /// Channel values that show an authored color on this fixture.
+    ///
+    /// The color goes through the profile's calibration first. RGB fixtures with white or amber
+    /// emitters take the part of the color those can make off the RGB channels, and fixtures
+    /// with only a color wheel snap to the closest slot. Empty if the fixture can't show color.
+    pub fn color_values(&self, rgb: (u8, u8, u8)) -> Vec<(ChannelType, u8)> {
+        let (mut red, mut green, mut blue) = match &self.profile.color_calibration {
+            Some(calibration) => calibration.apply(rgb),
+            None => rgb,
+        };
+        let has = |channel_type: ChannelType| self.channel_value(&channel_type).is_some();
+
+        if has(ChannelType::Red) && has(ChannelType::Green) && has(ChannelType::Blue) {
+            let mut extracted = Vec::new();
+            if has(ChannelType::White) {
+                let white = red.min(green).min(blue);
+                red -= white;
+                green -= white;
+                blue -= white;
+                extracted.push((ChannelType::White, white));
+            }
+            if has(ChannelType::Amber) {
+                // Amber emitters are close to full red with half green
+                let amber = red.min(green.saturating_mul(2));
+                red -= amber;
+                green -= amber / 2;
+                extracted.push((ChannelType::Amber, amber));
+            }
+            let mut values = vec![
+                (ChannelType::Red, red),
+                (ChannelType::Green, green),
+                (ChannelType::Blue, blue),
+            ];
+            values.extend(extracted);
+            return values;
+        }
+
+        if has(ChannelType::Color) {
+            let distance = |slot: &&WheelColor| {
+                let (r, g, b) = slot.rgb;
+                [(r, red), (g, green), (b, blue)]
+                    .iter()
+                    .map(|(a, b)| (*a as i32 - *b as i32).pow(2))
+                    .sum::<i32>()
+            };
+            if let Some(slot) = self.profile.color_wheel.iter().min_by_key(distance) {
+                return vec![(ChannelType::Color, slot.value)];
+            }
+        }
+        Vec::new()
+    }
+
+    /// Channel values for red, green and blue as a cue tracked them, or `None` if they go out
+    /// as written.
+    ///
+    /// Fixtures with a color calibration take the calibrated color, so a cue's colors match
+    /// across fixture types the way the programmer's do. Fixtures with only a color wheel
+    /// turn it to the nearest slot.
+    pub fn cue_color_values(&self, rgb: (u8, u8, u8)) -> Option<Vec<(ChannelType, u8)>> {
+        let has = |channel_type: ChannelType| self.channel_value(&channel_type).is_some();
+        let has_rgb = has(ChannelType::Red) || has(ChannelType::Green) || has(ChannelType::Blue);
+        if !has_rgb && has(ChannelType::Color) && !self.profile.color_wheel.is_empty() {
+            return Some(self.color_values(rgb));
+        }
+        let (red, green, blue) = self.profile.color_calibration?.apply(rgb);
+        Some(vec![
+            (ChannelType::Red, red),
+            (ChannelType::Green, green),
+            (ChannelType::Blue, blue),
+        ])
+    }
+
+    /// Channel values that put gobo `gobo` in the beam, counting from 0 for open, shaking at
+    /// `shake` from 0 for slow to 1 for fast if given.
+    ///
+    /// Gobos are looked up on the profile's gobo wheel, and one the fixture can't shake is held
+    /// still. Profiles without a wheel take `gobo` as the channel's value. Empty if the fixture
+    /// has no gobo channel or its wheel has no such gobo.
+    pub fn gobo_values(&self, gobo: usize, shake: Option<f64>) -> Vec<(ChannelType, u8)> {
+        if self.channel_value(&ChannelType::Gobo).is_none() {
+            return Vec::new();
+        }
+        if self.profile.gobo_wheel.is_empty() {
+            return u8::try_from(gobo)
+                .map(|value| vec![(ChannelType::Gobo, value)])
+                .unwrap_or_default();
+        }
+        let Some(slot) = self.profile.gobo_wheel.get(gobo) else {
+            return Vec::new();
+        };
+        let value = match (shake, slot.shake) {
+            (Some(speed), Some((slow, fast))) => {
+                let speed = speed.clamp(0.0, 1.0);
+                (slow as f64 + (fast as f64 - slow as f64) * speed).round() as u8
+            }
+            _ => slot.value,
+        };
+        vec![(ChannelType::Gobo, value)]
+    }
+
+    /// Where on its gobo wheel the fixture is, counting from 0 for open, and whether the gobo
+    /// is shaking
+    pub fn gobo(&self) -> Option<(usize, bool)> {
+        let value = self.channel_value(&ChannelType::Gobo)?;
+        let wheel = &self.profile.gobo_wheel;
+        let shaking = wheel.iter().position(|slot| {
+            slot.shake
+                .is_some_and(|(slow, fast)| (slow.min(fast)..=slow.max(fast)).contains(&value))
+        });
+        if let Some(index) = shaking {
+            return Some((index, true));
+        }
+        wheel
+            .iter()
+            .enumerate()
+            .filter(|(_, slot)| slot.value <= value)
+            .max_by_key(|(_, slot)| slot.value)
+            .map(|(index, _)| (index, false))
+    }
+
+    /// Pan and tilt as fractions of their range, for fixtures that have both
+    pub fn pan_tilt(&self) -> Option<(f32, f32)> {
+        let fraction = |channel_type: &ChannelType| {
+            if self.has_fine_channel(channel_type) {
+                Some(self.channel_value_16(channel_type)? as f32 / u16::MAX as f32)
+            } else {
+                Some(self.channel_value(channel_type)? as f32 / 255.0)
+            }
+        };
+        Some((fraction(&ChannelType::Pan)?, fraction(&ChannelType::Tilt)?))
+    }
+
+    /// Pan and tilt at the middle of the fixture's range, respecting its limits
+    pub fn home_position(&self) -> PanTilt {
+        match &self.pan_tilt_limits {
+            Some(limits) => PanTilt {
+                pan: ((limits.pan_min as u16 + limits.pan_max as u16) / 2) as u8,
+                tilt: ((limits.tilt_min as u16 + limits.tilt_max as u16) / 2) as u8,
+            },
+            None => PanTilt {
+                pan: 128,
+                tilt: 128,
+            },
+        }
+    }
 }
 
 #[macro_export]
@@ -0,0 +1,334 @@
+use std::collections::BTreeMap;
+use std::fmt;
+use std::path::{Path, PathBuf};
+
+use serde::Deserialize;
+
+use crate::{
+    Channel, ChannelType, ColorCalibration, FixtureLibrary, FixtureProfile, FixtureType, GoboSlot,
+    Motion, ProfileDefinition, WheelColor,
+};
+
+/// A fixture profile as written in a profiles directory, one per JSON file:
+///
+/// ```json
+/// {
+///   "id": "acme-spot-100",
+///   "fixture_type": "MovingHead",
+///   "manufacturer": "Acme",
+///   "model": "Spot 100",
+///   "channel_count": 8,
+///   "channels": { "Pan": 1, "Tilt": 2, "Color": 3, "Gobo": 4, "Dimmer": 6 }
+/// }
+/// ```
+///
+/// Channels map an attribute to its channel number, counting from 1. Attributes that aren't
+/// a known channel type are kept by name, and channels the map leaves out are named after
+/// their number, with a warning, as a gap often means `channel_count` is for another mode.
+///
+/// `modes` maps other channel counts the fixture can be patched in to their channel maps.
+/// A profile that `extends` another, built in or from another file, only lists what differs:
+/// its channels go over the parent's layout by number, moving any the parent has elsewhere,
+/// its modes replace the parent's with the same count, and fields left out come from the
+/// parent.
+#[derive(Clone, Debug, Deserialize)]
+#[serde(deny_unknown_fields)]
+pub struct ProfileFile {
+    pub id: String,
+    /// ID of the profile this one is a variant of
+    #[serde(default)]
+    pub extends: Option<String>,
+    #[serde(default)]
+    pub fixture_type: Option<FixtureType>,
+    #[serde(default)]
+    pub manufacturer: String,
+    #[serde(default)]
+    pub model: String,
+    /// Channels in the default layout, which a variant can leave as its parent's
+    #[serde(default)]
+    pub channel_count: usize,
+    #[serde(default)]
+    pub channels: BTreeMap<String, usize>,
+    #[serde(default)]
+    pub modes: BTreeMap<u8, BTreeMap<String, usize>>,
+    /// Range and top speed of the head, for checking movement effects against
+    #[serde(default)]
+    pub motion: Option<Motion>,
+    /// Gobos on the wheel, open first, with the range that shakes each one if it can
+    #[serde(default)]
+    pub gobo_wheel: Vec<GoboSlot>,
+    /// Correction from authored colors to what this fixture needs to show them
+    #[serde(default)]
+    pub color_calibration: Option<ColorCalibration>,
+    /// Colors on the wheel, in value order, with the RGB each one shows
+    #[serde(default)]
+    pub color_wheel: Vec<WheelColor>,
+}
+
+impl ProfileFile {
+    /// The profile the file describes, or which attribute is wrong with it
+    pub fn to_profile(&self) -> Result<FixtureProfile, (Option<&str>, String)> {
+        self.to_variant(None)
+    }
+
+    /// The profile the file describes over `parent`, the profile it extends, or which
+    /// attribute is wrong with it
+    pub fn to_variant(
+        &self,
+        parent: Option<&FixtureProfile>,
+    ) -> Result<FixtureProfile, (Option<&str>, String)> {
+        let inherited = parent.map_or(&[][..], |p| p.channel_layout.as_slice());
+        let channel_count = match self.channel_count {
+            0 => inherited.len(),
+            count => count,
+        };
+        if channel_count == 0 {
+            return Err((None, "channel_count must be at least 1".to_string()));
+        }
+        let channel_layout = map_channels(&self.channels, channel_count, inherited)?;
+        let mut modes = BTreeMap::new();
+        for (mode, channels) in &self.modes {
+            if *mode == 0 {
+                return Err((
+                    None,
+                    "modes are numbered by channel count from 1".to_string(),
+                ));
+            }
+            modes.insert(*mode, map_channels(channels, *mode as usize, &[])?);
+        }
+
+        let definition = ProfileDefinition {
+            id: self.id.clone(),
+            extends: self.extends.clone(),
+            fixture_type: self.fixture_type.clone(),
+            manufacturer: Some(self.manufacturer.clone()).filter(|m| !m.is_empty()),
+            model: Some(self.model.clone()).filter(|m| !m.is_empty()),
+            modes,
+            color_calibration: self.color_calibration,
+            color_wheel: self.color_wheel.clone(),
+            gobo_wheel: self.gobo_wheel.clone(),
+            motion: self.motion,
+            ..ProfileDefinition::default()
+        };
+        let mut profile = parent.cloned().unwrap_or_default();
+        profile.id = self.id.clone();
+        definition.apply(&mut profile);
+        profile.channel_layout = channel_layout;
+        Ok(profile)
+    }
+
+    /// Channel numbers up to `channel_count` that no attribute is on. A variant's come from
+    /// its parent, so none are.
+    pub fn unused_channels(&self) -> Vec<usize> {
+        if self.extends.is_some() {
+            return Vec::new();
+        }
+        (1..=self.channel_count)
+            .filter(|number| !self.channels.values().any(|n| n == number))
+            .collect()
+    }
+
+    /// What to warn about in a profile that loads, such as gaps in the channel map
+    pub fn warnings(&self) -> Vec<String> {
+        let unused = self.unused_channels();
+        if unused.is_empty() {
+            return Vec::new();
+        }
+        let numbers: Vec<String> = unused.iter().map(|n| n.to_string()).collect();
+        vec![format!(
+            "Channel{} {} of {} {} nothing mapped, check channel_count is for the right mode",
+            if unused.len() == 1 { "" } else { "s" },
+            numbers.join(", "),
+            self.channel_count,
+            if unused.len() == 1 { "has" } else { "have" },
+        )]
+    }
+}
+
+/// A profile file that couldn't be loaded, or a warning about one that could
+#[derive(Clone, Debug, PartialEq)]
+pub struct ProfileFileError {
+    pub path: PathBuf,
+    /// The line the problem is on, counting from 1, when it can be pinned down
+    pub line: Option<usize>,
+    pub message: String,
+}
+
+impl fmt::Display for ProfileFileError {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        match self.line {
+            Some(line) => write!(f, "{}:{line}: {}", self.path.display(), self.message),
+            None => write!(f, "{}: {}", self.path.display(), self.message),
+        }
+    }
+}
+
+/// What [`FixtureLibrary::load_profiles`] found in a profiles directory
+#[derive(Clone, Debug, Default, PartialEq)]
+pub struct ProfileLoad {
+    /// IDs of the profiles loaded, in file name order but for variants, which load after the
+    /// profile they extend
+    pub loaded: Vec<String>,
+    /// Files that were skipped, and why
+    pub errors: Vec<ProfileFileError>,
+    /// Problems with files that loaded anyway, such as channels the map leaves out
+    pub warnings: Vec<ProfileFileError>,
+}
+
+impl FixtureLibrary {
+    /// Add the profiles from every `.json` file in `dir`, replacing built-in profiles with
+    /// the same ID. A malformed file is skipped and reported without stopping the others.
+    pub fn load_profiles(&mut self, dir: &Path) -> ProfileLoad {
+        let mut load = ProfileLoad::default();
+        let mut paths: Vec<PathBuf> = match std::fs::read_dir(dir) {
+            Ok(entries) => entries
+                .filter_map(|entry| entry.ok().map(|e| e.path()))
+                .filter(|path| path.extension().is_some_and(|ext| ext == "json"))
+                .collect(),
+            Err(e) => {
+                load.errors.push(ProfileFileError {
+                    path: dir.to_path_buf(),
+                    line: None,
+                    message: e.to_string(),
+                });
+                return load;
+            }
+        };
+        paths.sort();
+
+        let mut pending = Vec::new();
+        for path in paths {
+            match read_file(&path) {
+                Ok((text, file)) => pending.push((path, text, file)),
+                Err(error) => load.errors.push(error),
+            }
+        }
+
+        // A variant waits for the profile it extends, which may come from a later file
+        let waiting = |file: &ProfileFile, pending: &[(PathBuf, String, ProfileFile)]| {
+            file.extends.as_ref().is_some_and(|parent| {
+                pending
+                    .iter()
+                    .any(|(_, _, other)| other.id == *parent && other.id != file.id)
+            })
+        };
+        while !pending.is_empty() {
+            let next = pending
+                .iter()
+                .position(|(_, _, file)| !waiting(file, &pending))
+                .unwrap_or(0);
+            let (path, text, file) = pending.remove(next);
+            let parent = match &file.extends {
+                Some(parent_id) => match self.profiles.get(parent_id) {
+                    Some(parent) if !waiting(&file, &pending) => Some(parent),
+                    _ => {
+                        let message = if waiting(&file, &pending) {
+                            format!("Profile inheritance cycle through {parent_id}")
+                        } else {
+                            format!("{} extends unknown profile {parent_id}", file.id)
+                        };
+                        load.errors
+                            .push(attribute_error(&path, &text, "extends", message));
+                        continue;
+                    }
+                },
+                None => None,
+            };
+            match file.to_variant(parent) {
+                Ok(profile) => {
+                    load.warnings
+                        .extend(file.warnings().into_iter().map(|message| ProfileFileError {
+                            path: path.clone(),
+                            line: None,
+                            message,
+                        }));
+                    load.loaded.push(profile.id.clone());
+                    self.profiles.insert(profile.id.clone(), profile);
+                }
+                Err((attribute, message)) => {
+                    load.errors.push(match attribute {
+                        Some(attribute) => attribute_error(&path, &text, attribute, message),
+                        None => ProfileFileError {
+                            path: path.clone(),
+                            line: None,
+                            message,
+                        },
+                    });
+                }
+            }
+        }
+        load
+    }
+}
+
+/// A layout of `channel_count` channels with each attribute in `channels` on its number, over
+/// the `inherited` layout
+fn map_channels<'a>(
+    channels: &'a BTreeMap<String, usize>,
+    channel_count: usize,
+    inherited: &[Channel],
+) -> Result<Vec<Channel>, (Option<&'a str>, String)> {
+    // An attribute mapped here moves from wherever the parent had it
+    let mut layout: Vec<Option<Channel>> = inherited
+        .iter()
+        .map(|c| Some(c.clone()).filter(|c| !channels.contains_key(&c.name)))
+        .collect();
+    layout.resize(channel_count, None);
+    let mut mapped: Vec<Option<&str>> = vec![None; channel_count];
+    for (attribute, number) in channels {
+        if *number == 0 || *number > channel_count {
+            return Err((
+                Some(attribute.as_str()),
+                format!("{attribute} is on channel {number}, outside 1 to {channel_count}"),
+            ));
+        }
+        if let Some(taken) = mapped[number - 1] {
+            return Err((
+                Some(attribute.as_str()),
+                format!("{attribute} and {taken} are both on channel {number}"),
+            ));
+        }
+        mapped[number - 1] = Some(attribute.as_str());
+        layout[number - 1] = Some(Channel {
+            name: attribute.clone(),
+            channel_type: ChannelType::from_name(attribute),
+            value: 0,
+        });
+    }
+
+    Ok(layout
+        .into_iter()
+        .enumerate()
+        .map(|(i, channel)| {
+            channel.unwrap_or_else(|| {
+                let name = format!("Channel {}", i + 1);
+                Channel {
+                    channel_type: ChannelType::Other(name.clone()),
+                    name,
+                    value: 0,
+                }
+            })
+        })
+        .collect())
+}
+
+fn read_file(path: &Path) -> Result<(String, ProfileFile), ProfileFileError> {
+    let error = |line, message| ProfileFileError {
+        path: path.to_path_buf(),
+        line,
+        message,
+    };
+    let text = std::fs::read_to_string(path).map_err(|e| error(None, e.to_string()))?;
+    let file = serde_json::from_str(&text).map_err(|e| error(Some(e.line()), e.to_string()))?;
+    Ok((text, file))
+}
+
+/// An error pointing at the line an attribute is written on, when it can be found
+fn attribute_error(path: &Path, text: &str, attribute: &str, message: String) -> ProfileFileError {
+    let key = format!("\"{attribute}\"");
+    ProfileFileError {
+        path: path.to_path_buf(),
+        line: text.lines().position(|l| l.contains(&key)).map(|i| i + 1),
+        message,
+    }
+}
@@ -1,23 +1,43 @@
 use std::collections::HashMap;
 use std::net::{IpAddr, SocketAddr};
+use std::path::{Path, PathBuf};
+use std::sync::Arc;
 use std::time::Duration;
 
 use anyhow::Result;
-use clap::Parser;
+use clap::{Parser, Subcommand};
 use halo_core::{
-    ArtNetDestination, ArtNetMode, ConfigManager, ConsoleCommand, ConsoleEvent, LightingConsole,
-    NetworkConfig, Settings,
+    describe_step, ArtNetDestination, ArtNetMode, BuildInfo, CapacityEstimate, Capture,
+    ConfigManager, ConsoleCommand, ConsoleEvent, CueList, EffectRegistry, Engine, EngineOptions,
+    FixtureDescription, FixtureStats, GapCheck, LearnedTiming, Meter, MusicalDuration,
+    MusicalPosition, NetworkConfig, OutputDriver, OutputKind, PatchReport, PatchSpec, Recording,
+    Redundancy, ReportFormat, ResumeState, SacnConfig, Settings, Show, SimulationOptions,
+    TestPattern, UnitCosts, Workload, DEMO_SHOW_NAME, SACN_PORT,
 };
+use halo_fixtures::FixtureLibrary;
 use tokio::sync::mpsc;
 
+/// Playback state saved alongside the config file, for `--resume`
+const RESUME_FILE: &str = "halo-state.json";
+
+/// Fixture on time and pan/tilt travel, saved alongside the config file
+const STATS_FILE: &str = "halo-fixture-stats.json";
+
+/// Fixture profile files, looked for alongside the config file
+const PROFILES_DIR: &str = "profiles";
+
 /// Lighting Console for live performances with precise automation and control.
 #[derive(Parser, Debug)]
 #[command(name = "halo")]
 #[command(about = "Halo lighting console")]
+#[command(subcommand_negates_reqs = true)]
 struct Args {
-    /// Art-Net Source IP address
-    #[arg(long, value_parser = parse_ip)]
-    source_ip: IpAddr,
+    #[command(subcommand)]
+    command: Option<Command>,
+
+    /// Art-Net Source IP address (required unless running a subcommand)
+    #[arg(long, value_parser = parse_ip, required = true)]
+    source_ip: Option<IpAddr>,
 
     /// Art-Net Destination IP address (optional - if not provided, broadcast mode will be used)
     /// This is for backward compatibility - use --lighting-dest-ip and --pixel-dest-ip for
@@ -49,6 +69,51 @@ struct Args {
     #[arg(long, default_value = "false")]
     broadcast: bool,
 
+    /// How to send DMX: artnet, sacn or null, overriding "output" in the config file
+    #[arg(long)]
+    output: Option<OutputKind>,
+
+    /// Send DMX as sACN (E1.31) from the source IP instead of Art-Net, multicast to
+    /// 239.255.x.x unless unicast receivers are given. Same as --output sacn.
+    #[arg(long, conflicts_with = "output")]
+    sacn: bool,
+
+    /// Source name sACN receivers show for this console
+    #[arg(long, default_value = "halo")]
+    sacn_source_name: String,
+
+    /// sACN priority for every universe, from 0 to 200
+    #[arg(long, default_value_t = 100, value_parser = clap::value_parser!(u8).range(0..=200))]
+    sacn_priority: u8,
+
+    /// sACN priority for one universe, as UNIVERSE=PRIORITY, e.g. 2=150. Can be repeated.
+    #[arg(long, value_parser = parse_universe_priority)]
+    sacn_universe_priority: Vec<(u8, u8)>,
+
+    /// Send sACN straight to this receiver instead of multicasting. Can be repeated.
+    #[arg(long, value_parser = parse_ip)]
+    sacn_unicast: Vec<IpAddr>,
+
+    /// DMX frames a second for each universe, from 1 to 44, overriding "dmx_rate_hz" in the
+    /// config file
+    #[arg(long, value_parser = parse_dmx_rate)]
+    dmx_rate: Option<f32>,
+
+    /// DMX rate for one universe, as UNIVERSE=HZ, e.g. 3=20 for a wireless link. Can be
+    /// repeated.
+    #[arg(long, value_parser = parse_universe_rate)]
+    universe_rate: Vec<(u8, f32)>,
+
+    /// Render and send DMX on OS threads of their own, for machines such as a Pi where
+    /// scheduling jitter makes strobes stutter. Prints how long ticks took on exit.
+    #[arg(long)]
+    low_latency: bool,
+
+    /// Niceness for the --low-latency threads, -20 to 19, on Linux. Below 0 needs root or
+    /// CAP_SYS_NICE.
+    #[arg(long, allow_negative_numbers = true, requires = "low_latency")]
+    nice: Option<i32>,
+
     /// Whether to enable MIDI support
     #[arg(short, long)]
     enable_midi: bool,
@@ -56,20 +121,828 @@ struct Args {
     /// Path to the show JSON file
     #[arg(long)]
     show_file: Option<String>,
+
+    /// Directory of fixture profile JSON files to add to the built-in library, overriding
+    /// built-ins with the same ID (default: the profiles directory beside the config file)
+    #[arg(long)]
+    profiles: Option<PathBuf>,
+
+    /// Hold every strobe channel open and stop hard-edged effects on intensity
+    #[arg(long)]
+    no_strobe: bool,
+
+    /// Carry on from where playback was when halo last stopped, e.g. after a crash
+    #[arg(long)]
+    resume: bool,
+
+    /// Start without the show, with only the patch, programmer and manual control, for when
+    /// the show won't load
+    #[arg(long, conflicts_with = "resume")]
+    safe: bool,
+
+    /// Record the output frame by frame to this file, for `halo inspect`
+    #[arg(long)]
+    record: Option<PathBuf>,
+
+    /// Capture exactly what goes out to every universe to this file, to replay or check later.
+    /// An OSC or MIDI trigger with the capture action starts and stops it too.
+    #[arg(long)]
+    capture: Option<PathBuf>,
+
+    /// Roll cue variations from this seed, so the show runs the same way every time
+    #[arg(long)]
+    seed: Option<u64>,
+
+    /// Stream playback to a standby halo connecting on this address, e.g. 0.0.0.0:7700
+    #[arg(long)]
+    serve_standby: Option<SocketAddr>,
+
+    /// Mirror the primary halo at this address with output muted, taking over on command or
+    /// when the primary goes quiet
+    #[arg(long, conflicts_with = "serve_standby")]
+    standby_of: Option<SocketAddr>,
+}
+
+#[derive(Subcommand, Debug)]
+enum Command {
+    /// Dry run a show end to end and report cue timing, unused fixtures and errors
+    Simulate {
+        /// Path to the show JSON file
+        #[arg(long)]
+        show: PathBuf,
+
+        /// Playback speed relative to real time, from 0.01 to 100x, e.g. 20x (default: as fast
+        /// as possible)
+        #[arg(long, value_parser = parse_speed)]
+        speed: Option<f64>,
+
+        /// Also write the report as JSON to this path
+        #[arg(long)]
+        json: Option<PathBuf>,
+
+        /// Seed for cue variations
+        #[arg(long, default_value_t = 0)]
+        seed: u64,
+
+        /// Look for fixtures that flash dark between two cues that both have them lit
+        #[arg(long)]
+        gaps: bool,
+
+        /// Dimmer level below which a fixture counts as dark
+        #[arg(long, default_value_t = 10)]
+        gap_threshold: u8,
+
+        /// Dips shorter than this many ticks count as gaps
+        #[arg(long, default_value_t = 4)]
+        gap_ticks: usize,
+
+        /// Hold fixtures through any gaps found and write the fixed show here (implies --gaps)
+        #[arg(long)]
+        fix_gaps: Option<PathBuf>,
+    },
+    /// Assign addresses to a list of fixtures and print the patch sheet
+    Patch {
+        /// JSON list of fixtures, each with a name, profile_id and optional pinned address
+        #[arg(long)]
+        fixtures: PathBuf,
+
+        /// Universes to fill, in order, e.g. 1,2
+        #[arg(long, value_delimiter = ',', default_value = "1")]
+        universes: Vec<u8>,
+    },
+    /// Print a patch report to take to the venue: fixtures by type with their addresses,
+    /// profiles, positions and home positions. Reports on the demo rig without --show or
+    /// --fixtures.
+    Patchsheet {
+        /// Path to the show JSON file
+        #[arg(long, conflicts_with = "fixtures")]
+        show: Option<PathBuf>,
+
+        /// JSON list of fixtures to auto-patch first, as for `halo patch`
+        #[arg(long)]
+        fixtures: Option<PathBuf>,
+
+        /// Universes to fill when auto-patching, in order, e.g. 1,2
+        #[arg(long, value_delimiter = ',', default_value = "1")]
+        universes: Vec<u8>,
+
+        /// md, csv or html
+        #[arg(long, default_value = "md")]
+        format: ReportFormat,
+
+        /// Write the report here instead of printing it
+        #[arg(long)]
+        output: Option<PathBuf>,
+    },
+    /// Estimate whether this machine can render a show at the configured frame rate
+    Capacity {
+        /// Path to the show JSON file
+        #[arg(long)]
+        show: PathBuf,
+    },
+    /// Report patched fixtures and channels that no cue uses, fixture aliases still in use,
+    /// and problems with the profiles directory's channel maps
+    Validate {
+        /// Path to the show JSON file
+        #[arg(long)]
+        show: PathBuf,
+    },
+    /// Merge cue numbers, labels and times from a CSV cue sheet into a show's cue list, adding
+    /// stub cues for rows the list doesn't have yet. Prints what would change unless --output
+    /// is given.
+    ImportCuesheet {
+        /// Path to the CSV cue sheet, with a header row naming its columns
+        cuesheet: PathBuf,
+
+        /// Path to the show JSON file to merge into
+        #[arg(long)]
+        show: PathBuf,
+
+        /// Name of the cue list to merge into (default: the first)
+        #[arg(long)]
+        list: Option<String>,
+
+        /// Tempo for times given as bar.beat
+        #[arg(long, default_value_t = 120.0)]
+        bpm: f64,
+
+        /// Write the merged show here
+        #[arg(long)]
+        output: Option<PathBuf>,
+    },
+    /// Print a fixture's profile, mode, address range and the absolute address of each channel
+    Describe {
+        /// Path to the show JSON file
+        #[arg(long)]
+        show: PathBuf,
+
+        /// Fixture name or ID
+        fixture: String,
+    },
+    /// Step a fixture through a grid of colors while tuning its color calibration, using the
+    /// usual Art-Net options and show file
+    Calibrate {
+        /// ID of the fixture to step through the grid
+        #[arg(long)]
+        fixture: usize,
+
+        /// Levels per channel, e.g. 3 steps each of red, green and blue through 0, 127 and 255
+        #[arg(long, default_value = "3", value_parser = clap::value_parser!(u8).range(2..))]
+        levels: u8,
+
+        /// Seconds to hold each color
+        #[arg(long, default_value = "5")]
+        hold: u64,
+    },
+    /// Run a test pattern on one fixture of the --show-file through the usual output, then
+    /// exit. The channel walk names each channel as it brings it up, for checking a profile
+    /// against the fixture.
+    TestFixture {
+        /// Fixture name or ID
+        fixture: String,
+
+        /// rgb-sweep, intensity-ramp, channel-walk or pan-tilt-box
+        #[arg(long, default_value = "channel-walk")]
+        pattern: TestPattern,
+    },
+    /// Run the --show-file as usual while learning the time between Go presses on a cue
+    /// list. Prints what was learned on exit, and with --write saves it to the show file as
+    /// the cues' follow times.
+    Learn {
+        /// Index of the cue list to learn
+        #[arg(long, default_value_t = 0)]
+        list: usize,
+
+        /// Save the learned times to the show file as follow times
+        #[arg(long)]
+        write: bool,
+    },
+    /// Print which build of halo this is, for bug reports
+    Version,
+    /// Play a built-in show on a virtual rig of eight PARs and two moving spots, with output
+    /// going nowhere, to try halo without any hardware
+    Demo,
+    /// Show usage counters kept across runs, for planning maintenance
+    Stats {
+        #[command(subcommand)]
+        stats: StatsCommand,
+    },
+    /// Send a capture made with --capture back out through the configured output, with its
+    /// original timing, instead of running the show
+    Play {
+        /// Path to the .dmxcap file
+        capture: PathBuf,
+
+        /// Playback speed from 0.01 to 100x, e.g. 2 or 0.5x
+        #[arg(long, default_value = "1", value_parser = parse_speed)]
+        speed: f64,
+
+        /// Start this far into the capture, e.g. 90s or 2.5min
+        #[arg(long, value_parser = parse_offset)]
+        from: Option<Duration>,
+    },
+    /// Look through a recording made with --record
+    Inspect {
+        /// Path to the .dmxrec file
+        recording: PathBuf,
+
+        /// Print what changed over this beat, as phrase.bar.beat, e.g. 2.3.1
+        #[arg(long)]
+        at: Option<MusicalPosition>,
+
+        /// Print a fixture's channels over time instead, by name or ID
+        #[arg(long)]
+        fixture: Option<String>,
+    },
+}
+
+#[derive(Subcommand, Debug)]
+enum StatsCommand {
+    /// Print each fixture's on time and pan/tilt travel
+    Fixtures {
+        /// Zero a fixture's counters after servicing it, by name
+        #[arg(long)]
+        reset: Option<String>,
+    },
 }
 
 fn parse_ip(s: &str) -> Result<IpAddr, String> {
     s.parse().map_err(|e| format!("Invalid IP address: {}", e))
 }
 
+fn parse_universe_priority(s: &str) -> Result<(u8, u8), String> {
+    let (universe, priority) = s
+        .split_once('=')
+        .ok_or_else(|| format!("Expected UNIVERSE=PRIORITY, got '{s}'"))?;
+    let universe: u8 = universe
+        .trim()
+        .parse()
+        .map_err(|e| format!("Invalid universe: {}", e))?;
+    let priority: u8 = priority
+        .trim()
+        .parse()
+        .map_err(|e| format!("Invalid priority: {}", e))?;
+    if priority > 200 {
+        return Err("Priority must be from 0 to 200".to_string());
+    }
+    Ok((universe, priority))
+}
+
+fn parse_dmx_rate(s: &str) -> Result<f32, String> {
+    let hz: f32 = s
+        .trim()
+        .trim_end_matches("Hz")
+        .parse()
+        .map_err(|e| format!("Invalid rate: {}", e))?;
+    if !(1.0..=44.0).contains(&hz) {
+        return Err("DMX rate must be from 1 to 44Hz".to_string());
+    }
+    Ok(hz)
+}
+
+fn parse_universe_rate(s: &str) -> Result<(u8, f32), String> {
+    let (universe, hz) = s
+        .split_once('=')
+        .ok_or_else(|| format!("Expected UNIVERSE=HZ, got '{s}'"))?;
+    let universe: u8 = universe
+        .trim()
+        .parse()
+        .map_err(|e| format!("Invalid universe: {}", e))?;
+    Ok((universe, parse_dmx_rate(hz)?))
+}
+
+fn parse_speed(s: &str) -> Result<f64, String> {
+    let speed: f64 = s
+        .trim_end_matches(['x', 'X'])
+        .parse()
+        .map_err(|e| format!("Invalid speed: {}", e))?;
+    if (0.01..=100.0).contains(&speed) {
+        Ok(speed)
+    } else {
+        Err("Speed must be from 0.01 to 100x".to_string())
+    }
+}
+
+fn parse_offset(s: &str) -> Result<Duration, String> {
+    match s.parse::<MusicalDuration>()? {
+        MusicalDuration::Absolute(offset) => Ok(offset),
+        musical => Err(format!(
+            "'{musical}' follows the tempo, give a time such as 90s or 2.5min"
+        )),
+    }
+}
+
+/// Run the `simulate` subcommand, exiting non-zero if the report contains errors
+async fn simulate(
+    show: PathBuf,
+    options: SimulationOptions,
+    json: Option<PathBuf>,
+    fix_gaps: Option<PathBuf>,
+) -> Result<()> {
+    let report = halo_core::simulate_show_with(&show, &options).await?;
+    print!("{report}");
+
+    if let Some(path) = json {
+        std::fs::write(&path, serde_json::to_string_pretty(&report)?)?;
+        println!("Report written to {}", path.display());
+    }
+
+    if let Some(path) = fix_gaps {
+        let library = fixture_library(options.profiles.clone());
+        let mut show = Show::read_with(&show, &library)?;
+        for change in halo_core::fix_gaps(&mut show, &report.gaps) {
+            println!("{change}");
+        }
+        std::fs::write(&path, serde_json::to_string_pretty(&show)?)?;
+        println!("Fixed show written to {}", path.display());
+    }
+
+    if report.has_errors() {
+        std::process::exit(1);
+    }
+    Ok(())
+}
+
+/// The profiles directory to load: the one given, or the one beside the config file if it's
+/// there
+fn profiles_dir(profiles: Option<PathBuf>, config_manager: &ConfigManager) -> Option<PathBuf> {
+    profiles.or_else(|| {
+        let dir = config_manager.config_path().with_file_name(PROFILES_DIR);
+        dir.is_dir().then_some(dir)
+    })
+}
+
+/// The fixture library with any profiles directory loaded into it
+fn fixture_library(profiles: Option<PathBuf>) -> FixtureLibrary {
+    let mut library = FixtureLibrary::new();
+    if let Some(dir) = profiles_dir(profiles, &ConfigManager::new(None)) {
+        let load = library.load_profiles(&dir);
+        for error in load.errors {
+            eprintln!("Warning: Skipped fixture profile {error}");
+        }
+        for warning in load.warnings {
+            eprintln!("Warning: Fixture profile {warning}");
+        }
+    }
+    library
+}
+
+/// Run the `patchsheet` subcommand
+fn patchsheet(
+    show: Option<PathBuf>,
+    fixtures: Option<PathBuf>,
+    universes: Vec<u8>,
+    profiles: Option<PathBuf>,
+    format: ReportFormat,
+    output: Option<PathBuf>,
+) -> Result<()> {
+    let library = fixture_library(profiles);
+    let report = if let Some(path) = show {
+        let show = Show::read_with(&path, &library)?;
+        PatchReport::new(&show.name, &show.fixtures, &[])
+    } else {
+        let (title, specs) = match fixtures {
+            Some(path) => {
+                let specs: Vec<PatchSpec> = serde_json::from_str(&std::fs::read_to_string(&path)?)?;
+                let title = path
+                    .file_stem()
+                    .map_or(String::new(), |s| s.to_string_lossy().to_string());
+                (title, specs)
+            }
+            None => (DEMO_SHOW_NAME.to_string(), halo_core::demo_patch()),
+        };
+        let plan =
+            halo_core::auto_patch(&specs, &universes, &library).map_err(|e| anyhow::anyhow!(e))?;
+        PatchReport::new(&title, &plan.fixtures, &plan.auto_patched)
+    };
+
+    let text = report.render(format);
+    match output {
+        Some(path) => {
+            std::fs::write(&path, text)?;
+            println!("Patch sheet written to {}", path.display());
+        }
+        None => print!("{text}"),
+    }
+    Ok(())
+}
+
+/// Run the `patch` subcommand
+fn patch(fixtures: PathBuf, universes: Vec<u8>, profiles: Option<PathBuf>) -> Result<()> {
+    let specs: Vec<PatchSpec> = serde_json::from_str(&std::fs::read_to_string(&fixtures)?)?;
+    let plan = halo_core::auto_patch(&specs, &universes, &fixture_library(profiles))
+        .map_err(|e| anyhow::anyhow!(e))?;
+
+    print!("{}", halo_core::patch_sheet(&plan.fixtures));
+    println!();
+    for (universe, free) in &plan.free_channels {
+        println!("Universe {universe}: {free} channels free");
+    }
+    Ok(())
+}
+
+/// Weigh a show against what this machine can render, with the limits from the config file
+fn estimate_capacity(
+    show: &Path,
+    library: &FixtureLibrary,
+    settings: &Settings,
+) -> Result<CapacityEstimate> {
+    let show = Show::read_with(show, library)?;
+    let workload = Workload::of(&show.fixtures, &show.cue_lists);
+    Ok(CapacityEstimate::new(
+        workload,
+        UnitCosts::measure(),
+        settings,
+    ))
+}
+
+/// Run the `capacity` subcommand
+fn capacity(show: PathBuf, profiles: Option<PathBuf>) -> Result<()> {
+    let settings = ConfigManager::new(None).load().unwrap_or_default();
+    let library = fixture_library(profiles);
+    print!("{}", estimate_capacity(&show, &library, &settings)?);
+    Ok(())
+}
+
+/// Run the `validate` subcommand, including which fixture aliases the venue's position presets
+/// still use
+fn validate(show: PathBuf, profiles: Option<PathBuf>) -> Result<()> {
+    let mut config_manager = ConfigManager::new(None);
+    let settings = config_manager.load().unwrap_or_default();
+
+    // The same checks as loading the profiles at startup, but broken ones fail validation
+    let mut library = FixtureLibrary::new();
+    let mut profile_errors = 0;
+    if let Some(dir) = profiles_dir(profiles, &config_manager) {
+        let load = library.load_profiles(&dir);
+        println!(
+            "Profiles: {} loaded from {}",
+            load.loaded.len(),
+            dir.display()
+        );
+        for error in &load.errors {
+            println!("  Error: {error}");
+        }
+        for warning in &load.warnings {
+            println!("  Warning: {warning}");
+        }
+        profile_errors = load.errors.len();
+    }
+
+    let show = Show::read_with(&show, &library)?;
+    println!("Show: {}", show.name);
+    print!(
+        "{}",
+        halo_core::analyze_usage(&show.fixtures, &show.cue_lists)
+    );
+    for warning in show.cue_lists.iter().flat_map(CueList::short_fade_warnings) {
+        println!("Warning: {warning}");
+    }
+    for warning in show.cue_lists.iter().flat_map(CueList::duplicate_warnings) {
+        println!("Warning: {warning}");
+    }
+    let aliases = halo_core::analyze_aliases(&show.fixtures, &settings.position_presets);
+    print!("{aliases}");
+    if !aliases.errors.is_empty() {
+        anyhow::bail!("{} fixture alias(es) are ambiguous", aliases.errors.len());
+    }
+    if profile_errors > 0 {
+        anyhow::bail!("{profile_errors} fixture profile(s) couldn't be loaded");
+    }
+    Ok(())
+}
+
+/// Run the `import-cuesheet` subcommand
+fn import_cuesheet(
+    cuesheet: PathBuf,
+    show_path: PathBuf,
+    list: Option<String>,
+    bpm: f64,
+    output: Option<PathBuf>,
+    profiles: Option<PathBuf>,
+) -> Result<()> {
+    let rows = halo_core::parse_cue_sheet(&std::fs::read_to_string(&cuesheet)?)
+        .map_err(|e| anyhow::anyhow!("{}: {e}", cuesheet.display()))?;
+    let mut show = Show::read_with(&show_path, &fixture_library(profiles))?;
+    let show_name = show.name.clone();
+    let cue_list = match &list {
+        Some(name) => show.cue_lists.iter_mut().find(|l| l.name == *name),
+        None => show.cue_lists.first_mut(),
+    }
+    .ok_or_else(|| match &list {
+        Some(name) => anyhow::anyhow!("No cue list named '{name}' in {show_name}"),
+        None => anyhow::anyhow!("{show_name} has no cue lists"),
+    })?;
+    let meter = Meter {
+        bpm,
+        ..Meter::default()
+    };
+    print!("{}", halo_core::merge_cue_sheet(cue_list, &rows, &meter));
+
+    match output {
+        Some(path) => {
+            std::fs::write(&path, serde_json::to_string_pretty(&show)?)?;
+            println!("Merged show written to {}", path.display());
+        }
+        None => println!("Nothing written, pass --output to save the merged show"),
+    }
+    Ok(())
+}
+
+/// Run the `describe` subcommand against a show file, with every channel at its patched value
+fn describe(show: PathBuf, name: &str, profiles: Option<PathBuf>) -> Result<()> {
+    let show = Show::read_with(&show, &fixture_library(profiles))?;
+    let (fixture, warning) = halo_core::find_fixture(&show.fixtures, name)
+        .ok_or_else(|| anyhow::anyhow!("No fixture named '{name}' in {}", show.name))?;
+    if let Some(warning) = warning {
+        eprintln!("Warning: {warning}");
+    }
+    print!("{}", FixtureDescription::new(fixture, false));
+    Ok(())
+}
+
+/// Run the `play` subcommand, sending a capture out until it ends or Ctrl-C
+async fn play(
+    path: &Path,
+    mut driver: Box<dyn OutputDriver>,
+    speed: f64,
+    from: Duration,
+) -> Result<()> {
+    let capture = Capture::read(path).map_err(|e| anyhow::anyhow!(e))?;
+    print!("{capture}");
+    if from > capture.length() {
+        anyhow::bail!(
+            "The capture is only {:.1}s long",
+            capture.length().as_secs_f64()
+        );
+    }
+    println!(
+        "Playing through {} at {speed}x from {:.1}s",
+        driver.name(),
+        from.as_secs_f64()
+    );
+    tokio::select! {
+        sent = capture.play(driver.as_mut(), speed, from) => {
+            println!("Sent {} universe frames", sent?);
+        }
+        _ = tokio::signal::ctrl_c() => println!("Stopped"),
+    }
+    Ok(())
+}
+
+/// Where fixture stats are kept: next to the config file
+fn stats_file(config_manager: &ConfigManager) -> PathBuf {
+    config_manager.config_path().with_file_name(STATS_FILE)
+}
+
+/// Run the `stats fixtures` subcommand, resetting a fixture's counters if asked to
+fn fixture_stats(reset: Option<String>) -> Result<()> {
+    let path = stats_file(&ConfigManager::new(None));
+    let mut stats = FixtureStats::load(&path).map_err(|e| anyhow::anyhow!(e))?;
+    if let Some(fixture) = reset {
+        if !stats.reset(&fixture) {
+            anyhow::bail!("No usage recorded for '{fixture}'");
+        }
+        stats.save(&path).map_err(|e| anyhow::anyhow!(e))?;
+        println!("Reset usage counters for '{fixture}'");
+        return Ok(());
+    }
+    print!("{stats}");
+    Ok(())
+}
+
+/// Run the `inspect` subcommand, printing a summary of the recording if not asked for a
+/// position or fixture
+fn inspect(recording: PathBuf, at: Option<MusicalPosition>, fixture: Option<String>) -> Result<()> {
+    let recording = Recording::read(&recording).map_err(|e| anyhow::anyhow!(e))?;
+    match (fixture, at) {
+        (Some(name), at) => {
+            let fixture = recording
+                .fixture_named(&name)
+                .ok_or_else(|| anyhow::anyhow!("No fixture named '{name}' in the recording"))?;
+            print!("{}", recording.fixture_history(fixture, at));
+        }
+        (None, Some(at)) => print!("{}", recording.diff_at(at)),
+        (None, None) => print!("{recording}"),
+    }
+    Ok(())
+}
+
+/// Write the demo show where the UI can load it from, returning its path
+fn write_demo_show() -> Result<PathBuf> {
+    let show = halo_core::demo_show().map_err(|e| anyhow::anyhow!(e))?;
+    let path = std::env::temp_dir().join("halo-demo.json");
+    std::fs::write(&path, serde_json::to_string_pretty(&show)?)?;
+    println!("Demo show written to {}", path.display());
+    Ok(path)
+}
+
+/// Run the `calibrate` subcommand: hold a fixture at full in each color of an RGB grid in
+/// turn, so it can be compared against a reference fixture while its matrix is tuned
+async fn calibrate(
+    command_tx: &mpsc::UnboundedSender<ConsoleCommand>,
+    show: Option<PathBuf>,
+    fixture_id: usize,
+    levels: u8,
+    hold: Duration,
+) -> Result<()> {
+    let send = |command: ConsoleCommand| {
+        command_tx
+            .send(command)
+            .map_err(|e| anyhow::anyhow!("Failed to send command: {}", e))
+    };
+
+    if let Some(path) = show {
+        send(ConsoleCommand::LoadShow { path })?;
+    }
+    send(ConsoleCommand::SetProgrammerPreviewMode { preview_mode: true })?;
+    send(ConsoleCommand::SetProgrammerValue {
+        fixture_id,
+        channel: "Dimmer".to_string(),
+        value: 255,
+    })?;
+
+    let steps: Vec<u8> = (0..levels as u32)
+        .map(|i| (i * 255 / (levels as u32 - 1)) as u8)
+        .collect();
+    for &red in &steps {
+        for &green in &steps {
+            for &blue in &steps {
+                println!("Fixture {fixture_id}: #{red:02X}{green:02X}{blue:02X}");
+                send(ConsoleCommand::SetProgrammerColor {
+                    fixture_ids: vec![fixture_id],
+                    red,
+                    green,
+                    blue,
+                })?;
+                tokio::time::sleep(hold).await;
+            }
+        }
+    }
+
+    send(ConsoleCommand::ClearProgrammer)
+}
+
+/// Run the `test-fixture` subcommand: play one cycle of a test pattern on a fixture from the
+/// show, saying what each step shows as it starts
+async fn test_fixture(
+    command_tx: &mpsc::UnboundedSender<ConsoleCommand>,
+    show: Option<PathBuf>,
+    name: &str,
+    pattern: TestPattern,
+    library: &FixtureLibrary,
+) -> Result<()> {
+    let send = |command: ConsoleCommand| {
+        command_tx
+            .send(command)
+            .map_err(|e| anyhow::anyhow!("Failed to send command: {}", e))
+    };
+
+    let path = show.ok_or_else(|| anyhow::anyhow!("test-fixture needs --show-file"))?;
+    let show = Show::read_with(&path, library)?;
+    let (fixture, warning) = halo_core::find_fixture(&show.fixtures, name)
+        .ok_or_else(|| anyhow::anyhow!("No fixture named '{name}' in {}", show.name))?;
+    if let Some(warning) = warning {
+        eprintln!("Warning: {warning}");
+    }
+    let cue = pattern.cue(fixture).map_err(|e| anyhow::anyhow!(e))?;
+    let chase = cue.chase.clone().unwrap_or_default();
+
+    send(ConsoleCommand::LoadShow { path })?;
+    send(ConsoleCommand::SetCueLists {
+        cue_lists: vec![CueList {
+            name: "Test".to_string(),
+            cues: vec![cue],
+            audio_file: None,
+            default_fade: None,
+            default_values: vec![],
+            move_in_black: None,
+        }],
+    })?;
+    send(ConsoleCommand::GoToCue {
+        list_index: 0,
+        cue_index: 0,
+    })?;
+
+    println!("{} on {}", pattern, fixture.name);
+    for (i, step) in chase.steps.iter().enumerate() {
+        let shows = describe_step(fixture, step);
+        match chase.steps.get(i + 1).filter(|_| chase.crossfade > 0.0) {
+            Some(next) => println!("  {shows}, fading to {}", describe_step(fixture, next)),
+            None => println!("  {shows}"),
+        }
+        tokio::time::sleep(pattern.step_time()).await;
+    }
+
+    send(ConsoleCommand::Stop)
+}
+
 #[tokio::main]
 async fn main() -> anyhow::Result<()> {
-    let args = Args::parse();
+    let mut args = Args::parse();
+
+    let mut demo = false;
+    let mut fixture_test = None;
+    let mut playback = None;
+    let mut learning = None;
+    let calibration = match args.command {
+        Some(Command::Simulate {
+            show,
+            speed,
+            json,
+            seed,
+            gaps,
+            gap_threshold,
+            gap_ticks,
+            fix_gaps,
+        }) => {
+            let options = SimulationOptions {
+                speed,
+                seed,
+                gaps: (gaps || fix_gaps.is_some()).then_some(GapCheck {
+                    threshold: gap_threshold,
+                    max_ticks: gap_ticks,
+                }),
+                profiles: profiles_dir(args.profiles, &ConfigManager::new(None)),
+            };
+            return simulate(show, options, json, fix_gaps).await;
+        }
+        Some(Command::Patch {
+            fixtures,
+            universes,
+        }) => return patch(fixtures, universes, args.profiles),
+        Some(Command::Patchsheet {
+            show,
+            fixtures,
+            universes,
+            format,
+            output,
+        }) => {
+            return patchsheet(show, fixtures, universes, args.profiles, format, output);
+        }
+        Some(Command::Capacity { show }) => return capacity(show, args.profiles),
+        Some(Command::Validate { show }) => return validate(show, args.profiles),
+        Some(Command::Describe { show, fixture }) => {
+            return describe(show, &fixture, args.profiles)
+        }
+        Some(Command::ImportCuesheet {
+            cuesheet,
+            show,
+            list,
+            bpm,
+            output,
+        }) => return import_cuesheet(cuesheet, show, list, bpm, output, args.profiles),
+        Some(Command::Stats {
+            stats: StatsCommand::Fixtures { reset },
+        }) => return fixture_stats(reset),
+        Some(Command::Inspect {
+            recording,
+            at,
+            fixture,
+        }) => return inspect(recording, at, fixture),
+        Some(Command::Version) => {
+            println!("{}", BuildInfo::current());
+            return Ok(());
+        }
+        Some(Command::Calibrate {
+            fixture,
+            levels,
+            hold,
+        }) => Some((fixture, levels, Duration::from_secs(hold))),
+        Some(Command::TestFixture { fixture, pattern }) => {
+            fixture_test = Some((fixture, pattern));
+            None
+        }
+        Some(Command::Learn { list, write }) => {
+            learning = Some((list, write));
+            None
+        }
+        Some(Command::Play {
+            capture,
+            speed,
+            from,
+        }) => {
+            playback = Some((capture, speed, from.unwrap_or_default()));
+            None
+        }
+        Some(Command::Demo) => {
+            demo = true;
+            args.show_file = Some(write_demo_show()?.display().to_string());
+            None
+        }
+        None => None,
+    };
+    // The demo's output is discarded, so any address will do
+    let source_ip = match args.source_ip {
+        Some(source_ip) => source_ip,
+        None if demo => IpAddr::from([127, 0, 0, 1]),
+        None => anyhow::bail!("--source-ip is required"),
+    };
 
     // Load configuration before initializing anything else
     println!("Loading configuration...");
     let mut config_manager = ConfigManager::new(None);
-    let settings = match config_manager.load() {
+    let mut settings = match config_manager.load() {
         Ok(settings) => {
             println!(
                 "Configuration loaded successfully from: {:?}",
@@ -85,6 +958,79 @@ async fn main() -> anyhow::Result<()> {
             Settings::default()
         }
     };
+    if let Some(output) = args.output {
+        settings.output = output;
+    } else if args.sacn {
+        settings.output = OutputKind::Sacn;
+    }
+    if args.no_strobe {
+        println!("Strobe-safe mode: strobes held open");
+        settings.no_strobe = true;
+    }
+    if let Some(hz) = args.dmx_rate {
+        settings.dmx_rate_hz = hz;
+    }
+    settings
+        .universe_rate_hz
+        .extend(args.universe_rate.iter().copied());
+    if args.low_latency {
+        settings.low_latency = true;
+    }
+    if args.nice.is_some() {
+        settings.thread_niceness = args.nice;
+    }
+    if let Err(errors) = ConfigManager::validate_settings(&settings) {
+        for error in errors {
+            println!("Warning: {error}");
+        }
+    }
+
+    if args.safe {
+        println!("Safe mode: starting without a show, with manual control only");
+        args.show_file = None;
+    }
+
+    if let Some(show_file) = &args.show_file {
+        let mut library = FixtureLibrary::new();
+        if let Some(dir) = profiles_dir(args.profiles.clone(), &config_manager) {
+            // Problems with the profiles are reported when the console loads them
+            let _ = library.load_profiles(&dir);
+        }
+        match estimate_capacity(Path::new(show_file), &library, &settings) {
+            Ok(estimate) => {
+                for warning in &estimate.warnings {
+                    println!("Warning: {warning}");
+                }
+            }
+            Err(e) => println!("Warning: Couldn't estimate capacity: {e}"),
+        }
+    }
+
+    // Playback state is always saved, so a restart after a crash can resume from it
+    let resume_file = config_manager.config_path().with_file_name(RESUME_FILE);
+    let resume = if args.resume {
+        let max_age = Duration::from_secs(settings.resume_max_age_secs);
+        match ResumeState::load(&resume_file, max_age) {
+            Ok(Some(state)) => {
+                println!(
+                    "Resuming '{}' from {}",
+                    state.cue_list,
+                    resume_file.display()
+                );
+                if let Some(build) = state.build.as_ref().filter(|b| **b != BuildInfo::current()) {
+                    println!("Note: the state was saved by {build}");
+                }
+                Some(state)
+            }
+            Ok(None) => None,
+            Err(e) => {
+                println!("Warning: {e}. Starting from the top.");
+                None
+            }
+        }
+    } else {
+        None
+    };
 
     // Apply CLI overrides to settings if provided
     let network_config = if args.lighting_dest_ip.is_some() || args.pixel_dest_ip.is_some() {
@@ -100,7 +1046,7 @@ async fn main() -> anyhow::Result<()> {
                     ArtNetMode::Broadcast
                 } else {
                     ArtNetMode::Unicast(
-                        SocketAddr::new(args.source_ip, args.artnet_port),
+                        SocketAddr::new(source_ip, args.artnet_port),
                         SocketAddr::new(lighting_ip, args.artnet_port),
                     )
                 },
@@ -111,11 +1057,7 @@ async fn main() -> anyhow::Result<()> {
 
             println!(
                 "Lighting destination: {}:{} -> {}:{} (Universe {})",
-                args.source_ip,
-                args.artnet_port,
-                lighting_ip,
-                args.artnet_port,
-                args.lighting_universe
+                source_ip, args.artnet_port, lighting_ip, args.artnet_port, args.lighting_universe
             );
         }
 
@@ -127,7 +1069,7 @@ async fn main() -> anyhow::Result<()> {
                     ArtNetMode::Broadcast
                 } else {
                     ArtNetMode::Unicast(
-                        SocketAddr::new(args.source_ip, args.artnet_port),
+                        SocketAddr::new(source_ip, args.artnet_port),
                         SocketAddr::new(pixel_ip, args.artnet_port),
                     )
                 },
@@ -143,62 +1085,90 @@ async fn main() -> anyhow::Result<()> {
 
             println!(
                 "Pixel destination: {}:{} -> {}:{} (Universes {} and up)",
-                args.source_ip,
-                args.artnet_port,
-                pixel_ip,
-                args.artnet_port,
-                args.pixel_start_universe
+                source_ip, args.artnet_port, pixel_ip, args.artnet_port, args.pixel_start_universe
             );
         }
 
         if destinations.is_empty() {
             // Fallback to single destination if no multi-destination args provided
-            NetworkConfig::new(
-                args.source_ip,
-                args.dest_ip,
-                args.artnet_port,
-                args.broadcast,
-            )
+            NetworkConfig::new(source_ip, args.dest_ip, args.artnet_port, args.broadcast)
         } else {
             NetworkConfig::new_multi_destination(destinations, universe_routing, args.artnet_port)
         }
     } else {
         // Legacy single destination setup
-        NetworkConfig::new(
-            args.source_ip,
-            args.dest_ip,
-            args.artnet_port,
-            args.broadcast,
-        )
+        NetworkConfig::new(source_ip, args.dest_ip, args.artnet_port, args.broadcast)
     };
 
-    println!("Configuring Halo with Art-Net settings:");
-    //    println!("Source IP: {}", network_config.source_ip);
-    println!("Mode: {}", network_config.get_mode_string());
-    println!("Destination: {}", network_config.get_destination());
-    println!("Port: {}", network_config.port);
-
-    // Create channels for communication
-    let (command_tx, command_rx) = mpsc::unbounded_channel::<ConsoleCommand>();
-    let (event_tx, mut event_rx) = mpsc::unbounded_channel::<ConsoleEvent>();
-
-    // Convert tokio receiver to std receiver for UI
-    let (ui_event_tx, ui_event_rx) = std::sync::mpsc::channel::<ConsoleEvent>();
+    let sacn = (settings.output == OutputKind::Sacn).then(|| {
+        let mut sacn = SacnConfig::new(source_ip, &args.sacn_source_name);
+        sacn.priority = args.sacn_priority;
+        sacn.universe_priorities = args.sacn_universe_priority.iter().copied().collect();
+        sacn.unicast = args
+            .sacn_unicast
+            .iter()
+            .map(|ip| SocketAddr::new(*ip, SACN_PORT))
+            .collect();
+        sacn
+    });
 
-    // Spawn a task to forward events from tokio to std channel
-    let event_forwarder = tokio::spawn(async move {
-        while let Some(event) = event_rx.recv().await {
-            if let Err(e) = ui_event_tx.send(event) {
-                log::error!("Failed to forward event to UI: {}", e);
-                break;
-            }
+    if let Some(sacn) = &sacn {
+        println!("Configuring Halo with sACN settings:");
+        println!("Source name: {}", sacn.source_name);
+        println!("Interface: {}", sacn.interface);
+        if sacn.unicast.is_empty() {
+            println!("Mode: multicast");
+        } else {
+            println!("Mode: unicast to {:?}", args.sacn_unicast);
         }
-        log::info!("Event forwarder task completed");
-    });
+        println!("Priority: {}", sacn.priority);
+    } else if settings.output == OutputKind::Null {
+        println!("DMX output: none (dry run)");
+    } else {
+        println!("Configuring Halo with Art-Net settings:");
+        //    println!("Source IP: {}", network_config.source_ip);
+        println!("Mode: {}", network_config.get_mode_string());
+        println!("Destination: {}", network_config.get_destination());
+        println!("Port: {}", network_config.port);
+    }
 
-    // Create the async console with loaded settings
-    let console =
-        LightingConsole::new_with_settings(80., network_config.clone(), settings.clone()).unwrap();
+    if let Some((path, speed, from)) = playback {
+        return play(
+            &path,
+            settings.output.driver(network_config, sacn),
+            speed,
+            from,
+        )
+        .await;
+    }
+
+    // Start the console with loaded settings
+    println!("Starting lighting console, {}...", BuildInfo::current());
+    println!("MIDI support: {}", args.enable_midi);
+    println!("Show file: {:?}", args.show_file);
+    let mut engine = Engine::start(EngineOptions {
+        bpm: 80.,
+        network_config: network_config.clone(),
+        settings: settings.clone(),
+        resume_file: Some(resume_file),
+        resume,
+        // The demo rig's fixtures don't wear
+        stats_file: (!demo).then(|| stats_file(&config_manager)),
+        profiles: profiles_dir(args.profiles.clone(), &config_manager),
+        record: args.record,
+        capture: args.capture,
+        effects: EffectRegistry::new(),
+        seed: args.seed,
+        null_output: demo,
+        sacn,
+        safe_mode: args.safe,
+        redundancy: args
+            .serve_standby
+            .map(Redundancy::Primary)
+            .or(args.standby_of.map(Redundancy::Standby)),
+    })
+    .await?;
+    log::info!("Initialization completed successfully");
 
     // // Blue Strobe Fast
     // console.add_midi_override(
@@ -245,75 +1215,94 @@ async fn main() -> anyhow::Result<()> {
 
     //// Cue Overrides
 
-    println!("Starting lighting console...");
-    println!("MIDI support: {}", args.enable_midi);
-    println!("Show file: {:?}", args.show_file);
+    let command_tx = engine.commands();
+    let mut event_rx = engine
+        .take_events()
+        .ok_or_else(|| anyhow::anyhow!("Console events already taken"))?;
 
-    // Create a command sender for the initialization task
-    let init_command_tx = command_tx.clone();
+    // Convert tokio receiver to std receiver for UI
+    let (ui_event_tx, ui_event_rx) = std::sync::mpsc::channel::<ConsoleEvent>();
 
-    // Spawn the console task with channel communication
-    let console_task = tokio::spawn(async move {
-        // Run the console with channels
-        if let Err(e) = console.run_with_channels(command_rx, event_tx).await {
-            println!("Console error: {}", e);
+    // Spawn a task to forward events from tokio to std channel
+    let learned = Arc::new(std::sync::Mutex::new(Vec::<LearnedTiming>::new()));
+    let forwarder_learned = Arc::clone(&learned);
+    let event_forwarder = tokio::spawn(async move {
+        while let Some(event) = event_rx.recv().await {
+            if let ConsoleEvent::TimingLearned { timings, .. } = &event {
+                *forwarder_learned.lock().unwrap() = timings.clone();
+            }
+            if let Err(e) = ui_event_tx.send(event) {
+                // What was learned only arrives after the UI has closed
+                if learning.is_some() {
+                    continue;
+                }
+                log::error!("Failed to forward event to UI: {}", e);
+                break;
+            }
         }
+        log::info!("Event forwarder task completed");
     });
 
-    // Store the show file path for later loading after UI starts
-    let show_file_path = args.show_file.clone();
-
-    // Spawn an initialization task to send all the setup commands
-    let init_task = tokio::spawn(async move {
-        println!("Starting initialization task...");
-
-        // Send initialization commands
-        println!("Sending Initialize command...");
-        init_command_tx
-            .send(ConsoleCommand::Initialize)
-            .map_err(|e| anyhow::anyhow!("Failed to send Initialize command: {}", e))?;
-
-        // Allow time for initialization
-        println!("Waiting for initialization...");
-        tokio::time::sleep(Duration::from_millis(100)).await;
-
-        println!("Initialization task completed successfully");
-        anyhow::Ok(())
-    });
+    if let Some((fixture_id, levels, hold)) = calibration {
+        let show_path = args.show_file.clone().map(PathBuf::from);
+        let result = calibrate(&command_tx, show_path, fixture_id, levels, hold).await;
+        engine.shutdown().await?;
+        let _ = event_forwarder.await;
+        return result;
+    }
 
-    // Wait for initialization to complete
-    log::info!("Waiting for initialization to complete...");
-    let init_result = init_task.await;
-    if let Err(e) = init_result {
-        log::error!("Initialization task join error: {}", e);
-        return Err(anyhow::anyhow!("Initialization task failed: {}", e));
+    if let Some((fixture, pattern)) = fixture_test {
+        let show_path = args.show_file.clone().map(PathBuf::from);
+        let library = fixture_library(args.profiles.clone());
+        let result = test_fixture(&command_tx, show_path, &fixture, pattern, &library).await;
+        engine.shutdown().await?;
+        let _ = event_forwarder.await;
+        return result;
     }
-    if let Err(e) = init_result.unwrap() {
-        log::error!("Initialization task error: {}", e);
-        return Err(e);
+
+    if let Some((list_index, _)) = learning {
+        if let Some(path) = args.show_file.clone().map(PathBuf::from) {
+            engine.send(ConsoleCommand::LoadShow { path })?;
+        }
+        engine.send(ConsoleCommand::StartLearning { list_index })?;
+        println!("Learning cue list {list_index}: Go through it as in the show, then close halo");
     }
-    log::info!("Initialization completed successfully");
 
     // Run the UI with the channels (this will block until UI closes)
     log::info!("Starting UI...");
-    let show_path = show_file_path.map(std::path::PathBuf::from);
-    let ui_result = halo_ui::run_ui(command_tx.clone(), ui_event_rx, show_path, config_manager);
+    let show_path = args.show_file.map(PathBuf::from);
+    let ui_result = halo_ui::run_ui(command_tx, ui_event_rx, show_path, config_manager);
     log::info!("UI completed");
 
-    // Send shutdown command
-    log::info!("Sending shutdown command...");
-    command_tx
-        .send(ConsoleCommand::Shutdown)
-        .map_err(|e| anyhow::anyhow!("Failed to send Shutdown command: {}", e))?;
+    if let Some((_, write)) = learning {
+        engine.send(ConsoleCommand::StopLearning { write })?;
+    }
 
-    // Wait for console task to finish
-    log::info!("Waiting for console task to finish...");
-    let _ = console_task.await;
+    // Stop the console and wait for its task to finish
+    log::info!("Shutting down console...");
+    let (render_ticks, output_stats) = (engine.render_ticks(), engine.output_stats());
+    engine.shutdown().await?;
+    if settings.low_latency {
+        println!("Render: {render_ticks}");
+        if let Some(stats) = output_stats {
+            println!("DMX send: {}", stats.ticks());
+        }
+    }
 
     // Wait for event forwarder task to finish
     log::info!("Waiting for event forwarder task to finish...");
     let _ = event_forwarder.await;
 
+    if let Some((list_index, _)) = learning {
+        let learned = learned.lock().unwrap();
+        if learned.is_empty() {
+            println!("Nothing learned: Go from one cue of list {list_index} into the next");
+        }
+        for timing in learned.iter() {
+            println!("{timing}");
+        }
+    }
+
     // Check UI result
     if let Err(e) = ui_result {
         log::error!("UI error: {}", e);
@@ -1,11 +1,14 @@
 use std::time::Duration;
 
 use eframe::egui;
-use halo_core::{ConsoleCommand, PlaybackState};
+use halo_core::{ConsoleCommand, Cue, PlaybackState};
 use tokio::sync::mpsc;
 
 use crate::state::ConsoleState;
 
+/// Cue warnings, in orange so they stand out from everything else in the list
+const WARNING_COLOR: egui::Color32 = egui::Color32::from_rgb(255, 140, 0);
+
 /// A panel that shows the list of cues.
 #[derive(Default)]
 pub struct CuePanel {
@@ -80,6 +83,22 @@ impl CuePanel {
                 });
             }
 
+            // Go is holding back until the operator has read this
+            if let Some((_, cue_index, warning)) = &state.pending_warning {
+                ui.add_space(10.0);
+                ui.group(|ui| {
+                    ui.label(
+                        egui::RichText::new(format!("⚠ Cue {cue_index}: {warning}"))
+                            .color(WARNING_COLOR)
+                            .size(18.0)
+                            .strong(),
+                    );
+                    if ui.button("Confirm and Go (Enter)").clicked() {
+                        let _ = _console_tx.send(ConsoleCommand::ConfirmGo);
+                    }
+                });
+            }
+
             // Column headers for cue list
             ui.add_space(10.0);
             ui.horizontal(|ui| {
@@ -188,9 +207,11 @@ impl CuePanel {
                             ui.add_sized(
                                 [80.0, 20.0],
                                 egui::Label::new(
-                                    egui::RichText::new(Self::format_duration(cue.fade_time))
-                                        .color(active_color)
-                                        .monospace(),
+                                    egui::RichText::new(Self::format_duration(
+                                        cue.completion_time(),
+                                    ))
+                                    .color(active_color)
+                                    .monospace(),
                                 ),
                             );
 
@@ -201,9 +222,17 @@ impl CuePanel {
                                 0.0
                             };
 
+                            // The running chase's step, counting from 1
+                            let step = state
+                                .chase_step
+                                .filter(|_| is_current_cue)
+                                .map(|(step, steps)| format!("Step {}/{steps}", step + 1))
+                                .unwrap_or_default();
+
                             ui.add_sized(
                                 [200.0, 20.0],
                                 egui::ProgressBar::new(progress)
+                                    .text(step)
                                     .desired_width(200.0)
                                     .desired_height(20.0)
                                     .corner_radius(0.0)
@@ -217,6 +246,14 @@ impl CuePanel {
                                     }),
                             );
                         });
+
+                        // Notes for the running cue and the one Go runs next
+                        let is_active = cue_index == state.current_cue_index
+                            && state.playback_state == PlaybackState::Playing;
+                        let is_pending = cue_index == state.current_cue_index + 1;
+                        if is_active || is_pending {
+                            Self::render_notes(ui, cue);
+                        }
                         ui.add_space(2.0); // Spacing between cue rows
                     }
                 });
@@ -234,6 +271,19 @@ impl CuePanel {
         }
     }
 
+    fn render_notes(ui: &mut egui::Ui, cue: &Cue) {
+        if !cue.warning.is_empty() {
+            ui.label(
+                egui::RichText::new(format!("⚠ {}", cue.warning))
+                    .color(WARNING_COLOR)
+                    .strong(),
+            );
+        }
+        if !cue.notes.is_empty() {
+            ui.label(egui::RichText::new(&cue.notes).italics().size(14.0));
+        }
+    }
+
     fn format_duration(duration: Duration) -> String {
         let total_secs = duration.as_secs();
         let minutes = total_secs / 60;
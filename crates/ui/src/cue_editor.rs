@@ -169,6 +169,9 @@ impl CueEditor {
                             name: std::mem::take(&mut self.new_cue_list_name),
                             cues: Vec::new(),
                             audio_file: None,
+                            default_fade: None,
+                            default_values: Vec::new(),
+                            move_in_black: None,
                         }],
                     });
                 }
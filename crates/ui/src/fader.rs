@@ -25,6 +25,21 @@ pub fn render(
                 }
             });
 
+            // Crossfader into the next cue
+            ui.horizontal(|ui| {
+                ui.label("Crossfade:");
+                let mut position = state.crossfade;
+                if ui
+                    .add(egui::Slider::new(&mut position, 0.0..=1.0).text("Next cue"))
+                    .changed()
+                {
+                    let _ = console_tx.send(ConsoleCommand::SetCrossfade {
+                        position,
+                        list_index: None,
+                    });
+                }
+            });
+
             ui.separator();
 
             // Individual faders
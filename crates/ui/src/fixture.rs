@@ -135,6 +135,36 @@ pub fn render_grid(
                             }
                         }
 
+                        // Right click to take a misbehaving fixture out of the output
+                        let fixture_disabled = state.disabled_fixtures.contains(&fixture.id);
+                        response.context_menu(|ui| {
+                            let (label, command) = if fixture_disabled {
+                                (
+                                    "Enable output",
+                                    ConsoleCommand::EnableFixture {
+                                        fixture_id: fixture.id,
+                                    },
+                                )
+                            } else {
+                                (
+                                    "Disable output",
+                                    ConsoleCommand::DisableFixture {
+                                        fixture_id: fixture.id,
+                                    },
+                                )
+                            };
+                            if ui.button(label).clicked() {
+                                let _ = console_tx.send(command);
+                                ui.close();
+                            }
+                            if ui.button("Inspect output").clicked() {
+                                let _ = console_tx.send(ConsoleCommand::QueryContributions {
+                                    name: fixture.name.clone(),
+                                });
+                                ui.close();
+                            }
+                        });
+
                         // Draw color strip at the top of the fixture box
                         let color_strip_height = 6.0;
                         let color_strip_rect = Rect::from_min_size(
@@ -187,6 +217,49 @@ pub fn render_grid(
                             },
                         );
 
+                        // Which slot the color wheel is on, for fixtures that have one
+                        if let Some(slot) = fixture.wheel_color() {
+                            let (r, g, b) = slot.rgb;
+                            ui.painter().text(
+                                rect.left_top() + Vec2::new(8.0, 12.0),
+                                egui::Align2::LEFT_TOP,
+                                &slot.name,
+                                egui::FontId::proportional(11.0),
+                                Color32::from_rgb(r, g, b),
+                            );
+                        }
+
+                        // And which gobo is in the beam
+                        let gobo = fixture.gobo().and_then(|(index, shaking)| {
+                            let slot = fixture.profile.gobo_wheel.get(index)?;
+                            Some(if shaking {
+                                format!("{} shake", slot.name)
+                            } else {
+                                slot.name.clone()
+                            })
+                        });
+                        if let Some(gobo) = gobo {
+                            ui.painter().text(
+                                rect.right_top() + Vec2::new(-8.0, 12.0),
+                                egui::Align2::RIGHT_TOP,
+                                gobo,
+                                egui::FontId::proportional(11.0),
+                                text_color,
+                            );
+                        }
+
+                        // Mark fixtures held out of the output, on their own or by universe
+                        if fixture_disabled || state.disabled_universes.contains(&fixture.universe)
+                        {
+                            ui.painter().text(
+                                rect.left_bottom() + Vec2::new(8.0, -8.0),
+                                egui::Align2::LEFT_BOTTOM,
+                                "DISABLED",
+                                egui::FontId::proportional(11.0),
+                                Color32::from_rgb(239, 68, 68),
+                            );
+                        }
+
                         // New row after each column
                         if (i + 1) % columns == 0 && i < state.fixtures.len() - 1 {
                             ui.end_row();
@@ -5,6 +5,9 @@ use tokio::sync::mpsc;
 use crate::settings::SettingsPanel;
 use crate::ActiveTab;
 
+/// How long the outgoing show takes to release when switching shows from the menu
+const SHOW_SWITCH_SECS: f64 = 2.0;
+
 pub fn render(
     ui: &mut eframe::egui::Ui,
     active_tab: &mut ActiveTab,
@@ -82,6 +85,41 @@ pub fn render(
             ui.ctx().send_viewport_cmd(egui::ViewportCommand::Close);
         }
     });
+    ui.menu_button("Workspace", |ui| {
+        for name in &state.workspace_shows {
+            let active = state.active_show.as_ref() == Some(name);
+            ui.horizontal(|ui| {
+                if ui.selectable_label(active, name).clicked() && !active {
+                    let _ = console_tx.send(ConsoleCommand::ActivateShow {
+                        name: name.clone(),
+                        transition_secs: SHOW_SWITCH_SECS,
+                    });
+                    ui.close();
+                }
+                if !active && ui.small_button("✕").on_hover_text("Unload").clicked() {
+                    let _ = console_tx.send(ConsoleCommand::UnloadShow { name: name.clone() });
+                }
+            });
+        }
+        if !state.workspace_shows.is_empty() {
+            ui.separator();
+        }
+        if ui.button("Load Show Into Workspace...").clicked() {
+            if let Some(path) = rfd::FileDialog::new()
+                .add_filter("Halo Show", &["json"])
+                .set_title("Load Show Into Workspace")
+                .pick_file()
+            {
+                let name = path
+                    .file_stem()
+                    .unwrap_or_default()
+                    .to_string_lossy()
+                    .to_string();
+                let _ = console_tx.send(ConsoleCommand::LoadShowAs { name, path });
+            }
+            ui.close();
+        }
+    });
     ui.menu_button("View", |ui| {
         if ui.button("Patch").clicked() {
             *active_tab = ActiveTab::PatchPanel;
@@ -1,7 +1,8 @@
+use std::collections::HashMap;
 use std::time::{Duration, Instant, SystemTime};
 
 use eframe::egui;
-use halo_core::{ConfigManager, ConsoleCommand, ConsoleEvent};
+use halo_core::{BeatGridEdit, ConfigManager, ConsoleCommand, ConsoleEvent};
 use tokio::sync::mpsc;
 
 use crate::state::ConsoleState;
@@ -61,8 +62,27 @@ pub struct HaloApp {
     cue_panel_state: cue::CuePanel,
     settings_panel: settings::SettingsPanel,
     timeline_state: timeline::TimelineState,
+
+    // Number keys currently holding a flash, and the cue each one flashed
+    held_flash_keys: HashMap<egui::Key, String>,
 }
 
+/// Seconds the B key takes to fade to black or back up
+const MASTER_FADE_SECS: f64 = 3.0;
+
+/// Number keys bound to the first nine cues of the current cue list as flashes
+const FLASH_KEYS: [egui::Key; 9] = [
+    egui::Key::Num1,
+    egui::Key::Num2,
+    egui::Key::Num3,
+    egui::Key::Num4,
+    egui::Key::Num5,
+    egui::Key::Num6,
+    egui::Key::Num7,
+    egui::Key::Num8,
+    egui::Key::Num9,
+];
+
 impl HaloApp {
     fn new(
         _cc: &eframe::CreationContext<'_>,
@@ -101,6 +121,115 @@ impl HaloApp {
             cue_panel_state: cue::CuePanel::default(),
             settings_panel: settings::SettingsPanel::new(),
             timeline_state: timeline::TimelineState::default(),
+            held_flash_keys: HashMap::new(),
+        }
+    }
+
+    /// Toggle a solo of the programmer's selected fixtures with the S key
+    fn handle_solo_key(&mut self, ctx: &egui::Context) {
+        if ctx.wants_keyboard_input() || !ctx.input(|i| i.key_pressed(egui::Key::S)) {
+            return;
+        }
+
+        if self.state.soloed_fixtures.is_some() {
+            let _ = self.console_tx.send(ConsoleCommand::Unsolo);
+        } else if !self.state.selected_fixtures.is_empty() {
+            let _ = self.console_tx.send(ConsoleCommand::Solo {
+                fixture_ids: self.state.selected_fixtures.clone(),
+            });
+        }
+    }
+
+    /// Fade the grand master to black, or back up if it's down, with the B key
+    fn handle_master_fade_key(&mut self, ctx: &egui::Context) {
+        if ctx.wants_keyboard_input() || !ctx.input(|i| i.key_pressed(egui::Key::B)) {
+            return;
+        }
+
+        let command = if self.state.grand_master < 1.0 {
+            ConsoleCommand::FadeUp {
+                duration_secs: MASTER_FADE_SECS,
+            }
+        } else {
+            ConsoleCommand::FadeToBlack {
+                duration_secs: MASTER_FADE_SECS,
+            }
+        };
+        let _ = self.console_tx.send(command);
+    }
+
+    /// Line the metronome up with the track playing: H halves the tempo, D doubles it, A
+    /// makes now beat 1 of a bar and Shift+A bar 1 of a phrase, and the comma and period keys
+    /// move the downbeat a beat earlier or later
+    fn handle_beat_grid_keys(&mut self, ctx: &egui::Context) {
+        if ctx.wants_keyboard_input() {
+            return;
+        }
+
+        let edit = ctx.input(|i| {
+            if i.key_pressed(egui::Key::H) {
+                Some(BeatGridEdit::Half)
+            } else if i.key_pressed(egui::Key::D) {
+                Some(BeatGridEdit::Double)
+            } else if i.key_pressed(egui::Key::A) && i.modifiers.shift {
+                Some(BeatGridEdit::AlignBar)
+            } else if i.key_pressed(egui::Key::A) {
+                Some(BeatGridEdit::AlignBeat)
+            } else if i.key_pressed(egui::Key::Period) {
+                Some(BeatGridEdit::ShiftBeat(1))
+            } else if i.key_pressed(egui::Key::Comma) {
+                Some(BeatGridEdit::ShiftBeat(-1))
+            } else {
+                None
+            }
+        });
+        if let Some(edit) = edit {
+            let _ = self.console_tx.send(ConsoleCommand::EditBeatGrid { edit });
+        }
+    }
+
+    /// Confirm the warning on the cue Go is waiting on with the Enter key
+    fn handle_confirm_key(&mut self, ctx: &egui::Context) {
+        if self.state.pending_warning.is_none()
+            || ctx.wants_keyboard_input()
+            || !ctx.input(|i| i.key_pressed(egui::Key::Enter))
+        {
+            return;
+        }
+
+        let _ = self.console_tx.send(ConsoleCommand::ConfirmGo);
+        self.state.pending_warning = None;
+    }
+
+    /// Flash a cue while its number key is held down
+    fn handle_flash_keys(&mut self, ctx: &egui::Context) {
+        if ctx.wants_keyboard_input() {
+            return;
+        }
+
+        for (index, key) in FLASH_KEYS.iter().enumerate() {
+            let (pressed, released) = ctx.input(|i| (i.key_pressed(*key), i.key_released(*key)));
+
+            if pressed && !self.held_flash_keys.contains_key(key) {
+                let cue_name = self
+                    .state
+                    .cue_lists
+                    .get(self.state.current_cue_list_index)
+                    .and_then(|list| list.cues.get(index))
+                    .map(|cue| cue.name.clone());
+                if let Some(cue_name) = cue_name {
+                    let _ = self.console_tx.send(ConsoleCommand::FlashOn {
+                        cue_name: cue_name.clone(),
+                    });
+                    self.held_flash_keys.insert(*key, cue_name);
+                }
+            }
+
+            if released {
+                if let Some(cue_name) = self.held_flash_keys.remove(key) {
+                    let _ = self.console_tx.send(ConsoleCommand::FlashOff { cue_name });
+                }
+            }
         }
     }
 
@@ -139,6 +268,49 @@ impl HaloApp {
         }
     }
 
+    /// What each layer put on the inspected fixture's channels, winners highlighted
+    fn render_contributions(&mut self, ctx: &egui::Context) {
+        let Some((name, values)) = self.state.contributions.clone() else {
+            return;
+        };
+        let mut open = true;
+        egui::Window::new(format!("Output of {name}"))
+            .open(&mut open)
+            .resizable(true)
+            .show(ctx, |ui| {
+                if values.is_empty() {
+                    ui.label("Nothing rendered on this fixture last frame");
+                }
+                egui::Grid::new("contributions_grid")
+                    .striped(true)
+                    .show(ui, |ui| {
+                        for value in &values {
+                            let text = |text: String| {
+                                if value.won {
+                                    egui::RichText::new(text).strong()
+                                } else {
+                                    egui::RichText::new(text).weak()
+                                }
+                            };
+                            ui.label(text(format!("{:?}", value.channel_type)));
+                            ui.label(text(value.source.to_string()));
+                            ui.label(text(value.value.to_string()));
+                            ui.label(text(format!("{:?}", value.rule).to_uppercase()));
+                            ui.label(if value.won { "✔ out" } else { "" });
+                            ui.end_row();
+                        }
+                    });
+                if ui.button("Refresh").clicked() {
+                    let _ = self
+                        .console_tx
+                        .send(ConsoleCommand::QueryContributions { name: name.clone() });
+                }
+            });
+        if !open {
+            self.state.contributions = None;
+        }
+    }
+
     fn render_ui(&mut self, ctx: &egui::Context) {
         // Header
         egui::TopBottomPanel::top("top_panel").show(ctx, |ui| {
@@ -153,6 +325,45 @@ impl HaloApp {
             });
         });
 
+        // Safe mode stays on screen for as long as the console has no show
+        if let Some(reason) = &self.state.safe_mode {
+            egui::TopBottomPanel::top("safe_mode_banner")
+                .frame(egui::Frame::default().fill(egui::Color32::from_rgb(140, 20, 20)))
+                .show(ctx, |ui| {
+                    ui.add_space(4.0);
+                    ui.label(
+                        egui::RichText::new(format!("⚠ SAFE MODE: manual control only. {reason}"))
+                            .strong()
+                            .color(egui::Color32::WHITE),
+                    );
+                    ui.add_space(4.0);
+                });
+        }
+
+        // A standby's output is muted until it takes over
+        if self.state.standby {
+            egui::TopBottomPanel::top("standby_banner")
+                .frame(egui::Frame::default().fill(egui::Color32::from_rgb(140, 100, 0)))
+                .show(ctx, |ui| {
+                    ui.add_space(4.0);
+                    ui.horizontal(|ui| {
+                        let message = match &self.state.standby_refused {
+                            Some(reason) => format!("⚠ STANDBY, not mirroring: {reason}"),
+                            None => "STANDBY: mirroring the primary, output muted".to_string(),
+                        };
+                        ui.label(
+                            egui::RichText::new(message)
+                                .strong()
+                                .color(egui::Color32::WHITE),
+                        );
+                        if ui.button("Take over").clicked() {
+                            let _ = self.console_tx.send(ConsoleCommand::TakeOver);
+                        }
+                    });
+                    ui.add_space(4.0);
+                });
+        }
+
         // Bottom UI
         egui::TopBottomPanel::bottom("footer_panel").show(ctx, |ui| {
             // Sync programmer state from console state before rendering
@@ -247,7 +458,7 @@ impl eframe::App for HaloApp {
                 println!("Loading show file on UI startup: {}", path.display());
                 let _ = self
                     .console_tx
-                    .send(ConsoleCommand::LoadShow { path: path.clone() });
+                    .send(ConsoleCommand::StartShow { path: path.clone() });
             }
             self.initial_show_loaded = true;
         }
@@ -255,6 +466,38 @@ impl eframe::App for HaloApp {
         // Process all updates first
         self.process_engine_updates();
 
+        // Momentary flashes on the number keys
+        self.handle_flash_keys(ctx);
+
+        // Solo the selected fixtures
+        self.handle_solo_key(ctx);
+
+        // Fade to black and back
+        self.handle_master_fade_key(ctx);
+
+        // Line the metronome up with the music
+        self.handle_beat_grid_keys(ctx);
+
+        // Confirm a cue warning so Go can run the cue
+        self.handle_confirm_key(ctx);
+
+        // Emergency full on works even while typing
+        if ctx.input(|i| i.key_pressed(egui::Key::F12)) {
+            let command = if self.state.full_on {
+                ConsoleCommand::ReleaseFullOn
+            } else {
+                ConsoleCommand::FullOn
+            };
+            let _ = self.console_tx.send(command);
+        }
+
+        // So does blackout
+        if ctx.input(|i| i.key_pressed(egui::Key::F11)) {
+            let _ = self.console_tx.send(ConsoleCommand::SetBlackout {
+                active: !self.state.blackout,
+            });
+        }
+
         // Periodically query Link state (every 2 seconds)
         if now.duration_since(self.last_link_query).as_secs() >= 2 {
             let _ = self.console_tx.send(ConsoleCommand::QueryLinkState);
@@ -266,6 +509,7 @@ impl eframe::App for HaloApp {
 
         // Render error dialog on top of everything
         self.render_error_dialog(ctx);
+        self.render_contributions(ctx);
 
         // Smart repaint based on playback state or active pixel effects
         let has_pixel_fixtures = self
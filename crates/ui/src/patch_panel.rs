@@ -189,6 +189,21 @@ impl PatchPanelState {
                                         }
                                     }
 
+                                    if !fixture.profile.commands.is_empty() {
+                                        ui.menu_button("Commands", |ui| {
+                                            for command in &fixture.profile.commands {
+                                                if ui.button(&command.name).clicked() {
+                                                    let _ = console_tx.send(
+                                                        ConsoleCommand::SendFixtureCommand {
+                                                            fixture_id: fixture.id,
+                                                            command: command.name.clone(),
+                                                        },
+                                                    );
+                                                }
+                                            }
+                                        });
+                                    }
+
                                     if ui.button("Remove").clicked() {
                                         self.fixture_to_remove = Some(fixture.id);
                                         self.fixture_to_remove_name = fixture.name.clone();
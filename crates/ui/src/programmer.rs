@@ -4,8 +4,8 @@ use std::f64::consts::PI;
 use eframe::egui::{self, Color32, Pos2, Rect, Sense, Stroke, Vec2};
 use egui_plot::{Line, Plot, PlotPoints};
 use halo_core::{
-    ConsoleCommand, EffectDistribution, EffectType, Interval, PixelEffect, PixelEffectParams,
-    PixelEffectScope, PixelEffectType,
+    ConsoleCommand, EffectDistribution, EffectType, FanMode, Interval, PixelEffect,
+    PixelEffectParams, PixelEffectScope, PixelEffectType,
 };
 use halo_fixtures::FixtureType;
 use tokio::sync::mpsc;
@@ -64,6 +64,10 @@ pub struct ProgrammerState {
     pixel_effect_type: usize,
     pixel_effect_scope: usize,
     pixel_effect_color: [f32; 3],
+    // Fan state
+    fan_from: f32,
+    fan_to: f32,
+    fan_mode: FanMode,
     // Modal dialog state
     show_record_dialog: bool,
     record_dialog_cue_name: String,
@@ -120,6 +124,10 @@ impl Default for ProgrammerState {
             pixel_effect_type: 0,                // Chase
             pixel_effect_scope: 1,               // Individual
             pixel_effect_color: [1.0, 1.0, 1.0], // White
+            // Fan defaults
+            fan_from: 10.0,
+            fan_to: 100.0,
+            fan_mode: FanMode::Linear,
             // Modal dialog defaults
             show_record_dialog: false,
             record_dialog_cue_name: String::new(),
@@ -1133,6 +1141,44 @@ impl ProgrammerState {
             );
 
             ui.add_space(spacing * 2.0);
+
+            self.show_fan_controls(ui, "dimmer", 100.0, console_tx);
+        });
+    }
+
+    /// Fan a channel across the selected fixtures
+    fn show_fan_controls(
+        &mut self,
+        ui: &mut egui::Ui,
+        channel: &str,
+        max: f32,
+        console_tx: &mpsc::UnboundedSender<ConsoleCommand>,
+    ) {
+        ui.vertical(|ui| {
+            ui.label("Fan");
+            ui.add_space(5.0);
+
+            ui.horizontal(|ui| {
+                ui.add(egui::DragValue::new(&mut self.fan_from).range(0.0..=max));
+                ui.label("→");
+                ui.add(egui::DragValue::new(&mut self.fan_to).range(0.0..=max));
+            });
+
+            ui.horizontal(|ui| {
+                ui.selectable_value(&mut self.fan_mode, FanMode::Linear, "Linear");
+                ui.selectable_value(&mut self.fan_mode, FanMode::Symmetric, "Symmetric");
+            });
+
+            let enabled = self.selected_fixtures.len() > 1;
+            if ui.add_enabled(enabled, egui::Button::new("Fan")).clicked() {
+                let _ = console_tx.send(ConsoleCommand::FanProgrammerValues {
+                    fixture_ids: self.selected_fixtures.clone(),
+                    channel: channel.to_string(),
+                    from: self.fan_from as u8,
+                    to: self.fan_to as u8,
+                    mode: self.fan_mode,
+                });
+            }
         });
     }
 
@@ -1230,6 +1276,10 @@ impl ProgrammerState {
 
             ui.add_space(spacing * 2.0);
 
+            self.show_fan_controls(ui, "pan", 255.0, console_tx);
+
+            ui.add_space(spacing * 2.0);
+
             // Position Grid
             ui.vertical(|ui| {
                 ui.label("Position Grid");
@@ -1382,9 +1432,15 @@ impl ProgrammerState {
                                 Color32::WHITE,
                             );
 
+                            // The first button is open, the rest the gobos on each
+                            // fixture's wheel
                             if response.clicked() {
                                 self.set_param("gobo_selection", i as f32);
-                                self.update_fixture_values(console_tx);
+                                let _ = console_tx.send(ConsoleCommand::SetProgrammerGobo {
+                                    fixture_ids: self.selected_fixtures.clone(),
+                                    gobo: i,
+                                    shake: None,
+                                });
                             }
 
                             if (i + 1) % 2 == 0 {
@@ -1,5 +1,10 @@
+use std::collections::HashMap;
+
 use eframe::egui;
-use halo_core::{ConsoleCommand, Settings};
+use halo_core::{
+    default_channel_smoothing, ChannelSmoothing, ConsoleCommand, MergePolicy, OutputKind,
+    OverrideFadePolicy, PositionPresets, Settings, TimetableRule, Trigger,
+};
 use tokio::sync::mpsc;
 
 use crate::state::ConsoleState;
@@ -34,6 +39,7 @@ pub struct SettingsPanel {
     pub midi_channel: String,
 
     // Output settings (DMX/Art-Net)
+    pub output: OutputKind,
     pub dmx_enabled: bool,
     pub dmx_broadcast: bool,
     pub dmx_source_ip: String,
@@ -41,6 +47,11 @@ pub struct SettingsPanel {
     pub dmx_port: String,
     pub wled_enabled: bool,
     pub wled_ip: String,
+    pub output_latency_ms: f32,
+    pub full_universe_frames: bool,
+    pub dmx_keep_alive_secs: f32,
+    pub dmx_rate_hz: f32,
+    pub low_latency: bool,
 
     // Pixel engine settings
     pub pixel_engine_enabled: bool,
@@ -48,6 +59,45 @@ pub struct SettingsPanel {
 
     // Fixture settings
     pub enable_pan_tilt_limits: bool,
+    pub limit_strobe: bool,
+    pub max_strobe_hz: f32,
+    pub no_strobe: bool,
+    pub smooth_channels: bool,
+    pub hold_disabled_fixtures: bool,
+    pub lenient_fixture_references: bool,
+    pub release_manual: bool,
+    pub manual_release_secs: f32,
+    pub manual_release_fade_secs: f32,
+    pub override_fade_policy: OverrideFadePolicy,
+    pub intensity_merge: MergePolicy,
+
+    // Venue settings, edited in the config file and passed through unchanged
+    position_presets: PositionPresets,
+
+    // Trigger map, also edited in the config file
+    triggers: Vec<Trigger>,
+
+    // Time of day rules, also edited in the config file
+    timetable: Vec<TimetableRule>,
+
+    // Channel smoothing times, also edited in the config file
+    channel_smoothing: Vec<ChannelSmoothing>,
+
+    // Oldest saved playback state to resume from, also edited in the config file
+    resume_max_age_secs: u64,
+
+    // Capacity limits for the machine, also edited in the config file
+    max_universes: Option<u8>,
+    max_fixtures: Option<usize>,
+
+    // Latency for particular universes, also edited in the config file
+    universe_latency_ms: HashMap<u8, f32>,
+    // Output rate for particular universes, also edited in the config file
+    universe_rate_hz: HashMap<u8, f32>,
+    // Niceness for the low latency threads, also edited in the config file
+    thread_niceness: Option<i32>,
+    // Slow frame warnings, also edited in the config file
+    slow_frame_ms: Option<f32>,
 
     // Internal state
     initialized: bool,
@@ -75,6 +125,7 @@ impl Default for SettingsPanel {
             midi_channel: "1".to_string(),
 
             // Output defaults
+            output: OutputKind::ArtNet,
             dmx_enabled: true,
             dmx_broadcast: false,
             dmx_source_ip: "192.168.1.100".to_string(),
@@ -82,6 +133,11 @@ impl Default for SettingsPanel {
             dmx_port: "6454".to_string(),
             wled_enabled: false,
             wled_ip: "192.168.1.50".to_string(),
+            output_latency_ms: 0.0,
+            full_universe_frames: false,
+            dmx_keep_alive_secs: 1.0,
+            dmx_rate_hz: 44.0,
+            low_latency: false,
 
             // Pixel engine defaults
             pixel_engine_enabled: false,
@@ -89,6 +145,28 @@ impl Default for SettingsPanel {
 
             // Fixture defaults
             enable_pan_tilt_limits: true,
+            limit_strobe: false,
+            max_strobe_hz: 3.0,
+            no_strobe: false,
+            smooth_channels: false,
+            hold_disabled_fixtures: false,
+            lenient_fixture_references: false,
+            release_manual: false,
+            manual_release_secs: 10.0,
+            manual_release_fade_secs: 1.0,
+            override_fade_policy: OverrideFadePolicy::default(),
+            intensity_merge: MergePolicy::default(),
+            position_presets: PositionPresets::new(),
+            triggers: Vec::new(),
+            timetable: Vec::new(),
+            channel_smoothing: default_channel_smoothing(),
+            resume_max_age_secs: Settings::default().resume_max_age_secs,
+            max_universes: None,
+            max_fixtures: None,
+            universe_latency_ms: HashMap::new(),
+            universe_rate_hz: HashMap::new(),
+            thread_niceness: None,
+            slow_frame_ms: None,
 
             // Internal state
             initialized: false,
@@ -130,6 +208,7 @@ impl SettingsPanel {
         self.midi_channel = settings.midi_channel.to_string();
 
         // Load output settings
+        self.output = settings.output;
         self.dmx_enabled = settings.dmx_enabled;
         self.dmx_broadcast = settings.dmx_broadcast;
         self.dmx_source_ip = settings.dmx_source_ip.clone();
@@ -137,6 +216,10 @@ impl SettingsPanel {
         self.dmx_port = settings.dmx_port.to_string();
         self.wled_enabled = settings.wled_enabled;
         self.wled_ip = settings.wled_ip.clone();
+        self.output_latency_ms = settings.output_latency_ms;
+        self.full_universe_frames = settings.full_universe_frames;
+        self.dmx_keep_alive_secs = settings.dmx_keep_alive_secs;
+        self.dmx_rate_hz = settings.dmx_rate_hz;
 
         // Load pixel engine settings
         self.pixel_engine_enabled = settings.pixel_engine_enabled;
@@ -144,6 +227,35 @@ impl SettingsPanel {
 
         // Load fixture settings
         self.enable_pan_tilt_limits = settings.enable_pan_tilt_limits;
+        self.limit_strobe = settings.max_strobe_hz.is_some();
+        if let Some(max_strobe_hz) = settings.max_strobe_hz {
+            self.max_strobe_hz = max_strobe_hz;
+        }
+        self.no_strobe = settings.no_strobe;
+        self.smooth_channels = settings.smooth_channels;
+        self.hold_disabled_fixtures = settings.hold_disabled_fixtures;
+        self.lenient_fixture_references = settings.lenient_fixture_references;
+        self.release_manual = settings.manual_release_secs.is_some();
+        if let Some(manual_release_secs) = settings.manual_release_secs {
+            self.manual_release_secs = manual_release_secs;
+        }
+        self.manual_release_fade_secs = settings.manual_release_fade_secs;
+        self.override_fade_policy = settings.override_fade_policy;
+        self.intensity_merge = settings.intensity_merge;
+
+        // Keep venue settings so applying doesn't drop them
+        self.position_presets = settings.position_presets.clone();
+        self.triggers = settings.triggers.clone();
+        self.timetable = settings.timetable.clone();
+        self.channel_smoothing = settings.channel_smoothing.clone();
+        self.resume_max_age_secs = settings.resume_max_age_secs;
+        self.max_universes = settings.max_universes;
+        self.max_fixtures = settings.max_fixtures;
+        self.universe_latency_ms = settings.universe_latency_ms.clone();
+        self.universe_rate_hz = settings.universe_rate_hz.clone();
+        self.low_latency = settings.low_latency;
+        self.thread_niceness = settings.thread_niceness;
+        self.slow_frame_ms = settings.slow_frame_ms;
     }
 
     pub fn render(
@@ -268,6 +380,13 @@ impl SettingsPanel {
                     });
                     ui.end_row();
                 }
+
+                ui.label("Missing Fixtures:");
+                ui.checkbox(
+                    &mut self.lenient_fixture_references,
+                    "Load cues anyway, dropping missing fixtures",
+                );
+                ui.end_row();
             });
 
         ui.add_space(20.0);
@@ -443,6 +562,23 @@ impl SettingsPanel {
                 ui.checkbox(&mut self.dmx_enabled, "Enable DMX output");
                 ui.end_row();
 
+                ui.label("Protocol:");
+                let label = |output: OutputKind| match output {
+                    OutputKind::ArtNet => "Art-Net",
+                    OutputKind::Sacn => "sACN (E1.31)",
+                    OutputKind::Null => "None (dry run)",
+                };
+                egui::ComboBox::from_id_salt("output_kind")
+                    .selected_text(label(self.output))
+                    .show_ui(ui, |ui| {
+                        for output in [OutputKind::ArtNet, OutputKind::Sacn, OutputKind::Null] {
+                            ui.selectable_value(&mut self.output, output, label(output));
+                        }
+                    })
+                    .response
+                    .on_hover_text("Takes effect when halo restarts");
+                ui.end_row();
+
                 if self.dmx_enabled {
                     ui.label("Mode:");
                     ui.horizontal(|ui| {
@@ -468,7 +604,220 @@ impl SettingsPanel {
                     ui.label("Port:");
                     ui.add(egui::TextEdit::singleline(&mut self.dmx_port).desired_width(100.0));
                     ui.end_row();
+
+                    ui.label("Link Latency:");
+                    ui.add(
+                        egui::DragValue::new(&mut self.output_latency_ms)
+                            .speed(1.0)
+                            .range(0.0..=500.0)
+                            .suffix(" ms"),
+                    );
+                    ui.end_row();
+
+                    ui.label("Frame Length:");
+                    ui.checkbox(
+                        &mut self.full_universe_frames,
+                        "Always send all 512 channels",
+                    );
+                    ui.end_row();
+
+                    ui.label("Keep Alive:");
+                    ui.add(
+                        egui::DragValue::new(&mut self.dmx_keep_alive_secs)
+                            .speed(0.1)
+                            .range(0.0..=10.0)
+                            .suffix(" s"),
+                    )
+                    .on_hover_text(
+                        "Resend unchanged universes this often, 0 to send only changes. \
+                         Takes effect when halo restarts",
+                    );
+                    ui.end_row();
+
+                    ui.label("Frame Rate:");
+                    ui.add(
+                        egui::DragValue::new(&mut self.dmx_rate_hz)
+                            .speed(1.0)
+                            .range(1.0..=44.0)
+                            .suffix(" Hz"),
+                    )
+                    .on_hover_text(
+                        "How often each universe goes out. Slow links such as wireless DMX can \
+                         be given their own rate per universe in the config file. Takes effect \
+                         when halo restarts",
+                    );
+                    ui.end_row();
+
+                    ui.label("Low Latency:");
+                    ui.checkbox(
+                        &mut self.low_latency,
+                        "Render and send on threads of their own",
+                    )
+                    .on_hover_text(
+                        "For machines such as a Pi where strobes stutter. Takes effect when \
+                             halo restarts",
+                    );
+                    ui.end_row();
+                }
+            });
+
+        ui.add_space(20.0);
+
+        // Strobe Safety Section
+        ui.label("Strobe Safety");
+        ui.separator();
+        ui.add_space(5.0);
+
+        egui::Grid::new("strobe_settings_grid")
+            .num_columns(2)
+            .spacing([40.0, 8.0])
+            .striped(true)
+            .show(ui, |ui| {
+                ui.label("Rate Limit:");
+                ui.checkbox(&mut self.limit_strobe, "Limit strobe rate");
+                ui.end_row();
+
+                if self.limit_strobe {
+                    ui.label("Max Rate:");
+                    ui.add(
+                        egui::DragValue::new(&mut self.max_strobe_hz)
+                            .speed(0.1)
+                            .range(0.5..=25.0)
+                            .suffix(" Hz"),
+                    );
+                    ui.end_row();
                 }
+
+                ui.label("No Strobe:");
+                ui.checkbox(&mut self.no_strobe, "Hold strobes open");
+                ui.end_row();
+            });
+
+        ui.add_space(20.0);
+
+        // Movement Section
+        ui.label("Movement");
+        ui.separator();
+        ui.add_space(5.0);
+
+        egui::Grid::new("movement_settings_grid")
+            .num_columns(2)
+            .spacing([40.0, 8.0])
+            .striped(true)
+            .show(ui, |ui| {
+                ui.label("Smoothing:");
+                ui.checkbox(
+                    &mut self.smooth_channels,
+                    "Ramp sudden pan and tilt changes",
+                );
+                ui.end_row();
+            });
+
+        ui.add_space(20.0);
+
+        // Disabled Fixtures Section
+        ui.label("Disabled Fixtures");
+        ui.separator();
+        ui.add_space(5.0);
+
+        egui::Grid::new("disabled_fixture_settings_grid")
+            .num_columns(2)
+            .spacing([40.0, 8.0])
+            .striped(true)
+            .show(ui, |ui| {
+                ui.label("Output:");
+                ui.checkbox(
+                    &mut self.hold_disabled_fixtures,
+                    "Hold last values instead of zero",
+                );
+                ui.end_row();
+            });
+
+        ui.add_space(20.0);
+
+        // Manual Sources Section
+        ui.label("Manual Sources");
+        ui.separator();
+        ui.add_space(5.0);
+
+        egui::Grid::new("manual_source_settings_grid")
+            .num_columns(2)
+            .spacing([40.0, 8.0])
+            .striped(true)
+            .show(ui, |ui| {
+                ui.label("Auto Release:");
+                ui.checkbox(&mut self.release_manual, "Release inactive sources");
+                ui.end_row();
+
+                if self.release_manual {
+                    ui.label("Release After:");
+                    ui.add(
+                        egui::DragValue::new(&mut self.manual_release_secs)
+                            .speed(0.5)
+                            .range(1.0..=600.0)
+                            .suffix(" s"),
+                    );
+                    ui.end_row();
+                }
+
+                ui.label("Release Fade:");
+                ui.add(
+                    egui::DragValue::new(&mut self.manual_release_fade_secs)
+                        .speed(0.1)
+                        .range(0.0..=30.0)
+                        .suffix(" s"),
+                );
+                ui.end_row();
+            });
+
+        ui.add_space(20.0);
+
+        // Blackout & Full On Section
+        ui.label("Blackout & Full On");
+        ui.separator();
+        ui.add_space(5.0);
+
+        egui::Grid::new("override_settings_grid")
+            .num_columns(2)
+            .spacing([40.0, 8.0])
+            .striped(true)
+            .show(ui, |ui| {
+                ui.label("Hidden Fades:");
+                let label = |policy: OverrideFadePolicy| match policy {
+                    OverrideFadePolicy::Continue => "Keep fading",
+                    OverrideFadePolicy::Freeze => "Pause until released",
+                    OverrideFadePolicy::Complete => "Finish on release",
+                };
+                egui::ComboBox::from_id_salt("override_fade_policy")
+                    .selected_text(label(self.override_fade_policy))
+                    .show_ui(ui, |ui| {
+                        for policy in [
+                            OverrideFadePolicy::Continue,
+                            OverrideFadePolicy::Freeze,
+                            OverrideFadePolicy::Complete,
+                        ] {
+                            ui.selectable_value(
+                                &mut self.override_fade_policy,
+                                policy,
+                                label(policy),
+                            );
+                        }
+                    });
+                ui.end_row();
+
+                ui.label("Intensity Merge:");
+                let label = |policy: MergePolicy| match policy {
+                    MergePolicy::Htp => "Highest wins",
+                    MergePolicy::Ltp => "Effects over cues",
+                };
+                egui::ComboBox::from_id_salt("intensity_merge")
+                    .selected_text(label(self.intensity_merge))
+                    .show_ui(ui, |ui| {
+                        for policy in [MergePolicy::Ltp, MergePolicy::Htp] {
+                            ui.selectable_value(&mut self.intensity_merge, policy, label(policy));
+                        }
+                    });
+                ui.end_row();
             });
 
         ui.add_space(20.0);
@@ -605,6 +954,7 @@ impl SettingsPanel {
             midi_device: self.midi_device.clone(),
             midi_channel: self.midi_channel.parse().unwrap_or(1),
 
+            output: self.output,
             dmx_enabled: self.dmx_enabled,
             dmx_broadcast: self.dmx_broadcast,
             dmx_source_ip: self.dmx_source_ip.clone(),
@@ -612,12 +962,38 @@ impl SettingsPanel {
             dmx_port: self.dmx_port.parse().unwrap_or(6454),
             wled_enabled: self.wled_enabled,
             wled_ip: self.wled_ip.clone(),
+            output_latency_ms: self.output_latency_ms,
+            full_universe_frames: self.full_universe_frames,
+            dmx_keep_alive_secs: self.dmx_keep_alive_secs,
+            dmx_rate_hz: self.dmx_rate_hz,
+            universe_latency_ms: self.universe_latency_ms.clone(),
+            universe_rate_hz: self.universe_rate_hz.clone(),
+            low_latency: self.low_latency,
+            thread_niceness: self.thread_niceness,
+            slow_frame_ms: self.slow_frame_ms,
 
             pixel_engine_enabled: self.pixel_engine_enabled,
             pixel_engine_fps: self.pixel_engine_fps.parse().unwrap_or(44.0),
             pixel_universe_mapping: std::collections::HashMap::new(),
 
             enable_pan_tilt_limits: self.enable_pan_tilt_limits,
+            max_strobe_hz: self.limit_strobe.then_some(self.max_strobe_hz),
+            no_strobe: self.no_strobe,
+            smooth_channels: self.smooth_channels,
+            channel_smoothing: self.channel_smoothing.clone(),
+            hold_disabled_fixtures: self.hold_disabled_fixtures,
+            lenient_fixture_references: self.lenient_fixture_references,
+            manual_release_secs: self.release_manual.then_some(self.manual_release_secs),
+            manual_release_fade_secs: self.manual_release_fade_secs,
+            override_fade_policy: self.override_fade_policy,
+            intensity_merge: self.intensity_merge,
+            resume_max_age_secs: self.resume_max_age_secs,
+            max_universes: self.max_universes,
+            max_fixtures: self.max_fixtures,
+
+            position_presets: self.position_presets.clone(),
+            triggers: self.triggers.clone(),
+            timetable: self.timetable.clone(),
         };
 
         // Send update command
@@ -1,9 +1,10 @@
 use std::collections::HashMap;
-use std::time::SystemTime;
+use std::time::{Duration, Instant, SystemTime};
 
 use halo_core::audio::waveform::WaveformData;
 use halo_core::{
-    AudioDeviceInfo, ConsoleCommand, CueList, PlaybackState, RhythmState, Settings, Show, TimeCode,
+    AudioDeviceInfo, ConsoleCommand, CueList, MotionModel, PlaybackState, RhythmState, Settings,
+    Show, TimeCode,
 };
 use halo_fixtures::{Fixture, FixtureLibrary};
 use tokio::sync::mpsc;
@@ -15,6 +16,8 @@ pub struct ConsoleState {
     pub current_cue_list_index: usize,
     pub current_cue_index: usize,
     pub current_cue_progress: f32,
+    /// Step the running cue's chase is on and how many steps it has
+    pub chase_step: Option<(usize, usize)>,
     pub playback_state: PlaybackState,
     pub bpm: f64,
     pub current_time: SystemTime,
@@ -35,10 +38,37 @@ pub struct ConsoleState {
     pub fixture_library: FixtureLibrary,
     pub active_effects_count: usize,
     pub last_error: Option<String>,
+    /// Why the console is running without a show, if it is
+    pub safe_mode: Option<String>,
+    /// Whether the console is a muted standby, waiting to take over from the primary
+    pub standby: bool,
+    /// Why the standby won't mirror the primary, if it refused to
+    pub standby_refused: Option<String>,
+    /// What each layer of a frame put on the fixture being inspected, by fixture name
+    pub contributions: Option<(String, Vec<halo_core::SourceValue>)>,
+    /// Shows loaded side by side for switching between
+    pub workspace_shows: Vec<String>,
+    /// The workspace show that's running, if the running show came from the workspace
+    pub active_show: Option<String>,
     pub audio_waveform: Option<WaveformData>,
     pub audio_duration: Option<f64>,
     pub audio_bpm: Option<f64>,
     pub pixel_data: HashMap<usize, Vec<(u8, u8, u8)>>,
+    pub active_flashes: Vec<String>,
+    pub soloed_fixtures: Option<Vec<usize>>,
+    pub disabled_fixtures: Vec<usize>,
+    pub disabled_universes: Vec<u8>,
+    pub full_on: bool,
+    pub blackout: bool,
+    /// Level the grand master is at or fading to
+    pub grand_master: f32,
+    pub crossfade: f32,
+    /// Cue Go is holding back until its warning is confirmed, with the warning
+    pub pending_warning: Option<(usize, usize, String)>,
+    /// Where movers with a known top speed physically are, for the visualizer
+    pub motion: MotionModel,
+    /// When fixture values last arrived, for moving the motion model on
+    pub last_values_update: Option<Instant>,
 }
 
 impl Default for ConsoleState {
@@ -49,6 +79,7 @@ impl Default for ConsoleState {
             current_cue_list_index: 0,
             current_cue_index: 0,
             current_cue_progress: 0.0,
+            chase_step: None,
             playback_state: PlaybackState::Stopped,
             bpm: 120.0,
             current_time: SystemTime::now(),
@@ -77,10 +108,27 @@ impl Default for ConsoleState {
             fixture_library: FixtureLibrary::new(),
             active_effects_count: 0,
             last_error: None,
+            safe_mode: None,
+            standby: false,
+            standby_refused: None,
+            contributions: None,
+            workspace_shows: Vec::new(),
+            active_show: None,
             audio_waveform: None,
             audio_duration: None,
             audio_bpm: None,
             pixel_data: HashMap::new(),
+            active_flashes: Vec::new(),
+            soloed_fixtures: None,
+            disabled_fixtures: Vec::new(),
+            disabled_universes: Vec::new(),
+            full_on: false,
+            blackout: false,
+            grand_master: 1.0,
+            crossfade: 0.0,
+            pending_warning: None,
+            motion: MotionModel::new(),
+            last_values_update: None,
         }
     }
 }
@@ -107,6 +155,22 @@ impl ConsoleState {
                 self.current_cue_index = cue_index;
                 self.current_cue_progress = progress;
             }
+            halo_core::ConsoleEvent::ChaseStepChanged { step } => {
+                self.chase_step = step;
+            }
+            halo_core::ConsoleEvent::CueStarted { .. } => {
+                self.pending_warning = None;
+            }
+            halo_core::ConsoleEvent::CueWarning {
+                list_index,
+                cue_index,
+                warning,
+            } => {
+                self.pending_warning = Some((list_index, cue_index, warning));
+            }
+            halo_core::ConsoleEvent::CrossfadeChanged { position } => {
+                self.crossfade = position;
+            }
             halo_core::ConsoleEvent::PlaybackStateChanged { state } => {
                 self.playback_state = state;
             }
@@ -228,6 +292,20 @@ impl ConsoleState {
             halo_core::ConsoleEvent::Error { message } => {
                 self.last_error = Some(message);
             }
+            halo_core::ConsoleEvent::SafeModeEntered { reason } => {
+                self.safe_mode = Some(reason);
+            }
+            halo_core::ConsoleEvent::StandbyChanged { standby, refused } => {
+                self.standby = standby;
+                self.standby_refused = refused;
+            }
+            halo_core::ConsoleEvent::FixtureContributions { name, values } => {
+                self.contributions = Some((name, values));
+            }
+            halo_core::ConsoleEvent::WorkspaceUpdated { shows, active } => {
+                self.workspace_shows = shows;
+                self.active_show = active;
+            }
             halo_core::ConsoleEvent::WaveformAnalyzed {
                 waveform_data,
                 duration,
@@ -237,12 +315,49 @@ impl ConsoleState {
                 self.audio_duration = Some(duration);
                 self.audio_bpm = bpm;
             }
+            halo_core::ConsoleEvent::FlashesChanged { active } => {
+                self.active_flashes = active;
+            }
+            halo_core::ConsoleEvent::FullOnChanged { active } => {
+                self.full_on = active;
+            }
+            halo_core::ConsoleEvent::GrandMasterChanged { level } => {
+                self.grand_master = level;
+            }
+            halo_core::ConsoleEvent::BlackoutChanged { active } => {
+                self.blackout = active;
+            }
+            halo_core::ConsoleEvent::SoloChanged { fixture_ids } => {
+                self.soloed_fixtures = fixture_ids;
+            }
+            halo_core::ConsoleEvent::DisabledOutputsChanged {
+                fixture_ids,
+                universes,
+            } => {
+                self.disabled_fixtures = fixture_ids;
+                self.disabled_universes = universes;
+            }
             halo_core::ConsoleEvent::PixelDataUpdated { pixel_data } => {
                 self.pixel_data.clear();
                 for (fixture_id, pixels) in pixel_data {
                     self.pixel_data.insert(fixture_id, pixels);
                 }
             }
+            halo_core::ConsoleEvent::FixtureValuesUpdated { values } => {
+                for (fixture_id, channel_values) in values {
+                    if let Some(fixture) = self.fixtures.get_mut(&fixture_id.to_string()) {
+                        for (channel, value) in fixture.channels.iter_mut().zip(channel_values) {
+                            channel.value = value;
+                        }
+                    }
+                }
+                let now = Instant::now();
+                let elapsed = self
+                    .last_values_update
+                    .map_or(Duration::ZERO, |last| now.duration_since(last));
+                self.last_values_update = Some(now);
+                self.motion.update(self.fixtures.values(), elapsed);
+            }
             _ => {
                 // Handle other events as needed
             }
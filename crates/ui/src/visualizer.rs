@@ -1,6 +1,6 @@
 use eframe::egui::{self, Color32, Pos2, Rect, Vec2};
 use halo_core::ConsoleCommand;
-use halo_fixtures::FixtureType;
+use halo_fixtures::{FixtureType, StagePosition};
 use tokio::sync::mpsc;
 
 use crate::state::ConsoleState;
@@ -20,6 +20,9 @@ pub fn render(
             ui.set_min_size(Vec2::new(250.0, 300.0));
             ui.set_max_size(Vec2::new(250.0, 300.0));
 
+            render_stage(ui, state);
+            ui.add_space(8.0);
+
             // Get pixel bar fixtures
             let mut pixel_fixtures: Vec<_> = state
                 .fixtures
@@ -52,6 +55,108 @@ pub fn render(
         });
 }
 
+/// Stage plot with one circle per fixture at its configured position, filled with its live color.
+/// Movers get a beam line from pan/tilt, with a second one where the head has actually got to
+/// when it's behind, and the border pulses on each downbeat.
+fn render_stage(ui: &mut egui::Ui, state: &ConsoleState) {
+    let mut fixtures: Vec<_> = state
+        .fixtures
+        .values()
+        .filter(|f| f.profile.fixture_type != FixtureType::PixelBar)
+        .collect();
+    fixtures.sort_by_key(|f| f.id);
+
+    let (response, painter) = ui.allocate_painter(Vec2::new(230.0, 120.0), egui::Sense::hover());
+    let rect = response.rect;
+
+    // Pulse the border on the first beat of each bar
+    let rhythm = &state.rhythm_state;
+    let downbeat = rhythm.bar_phase < 1.0 / rhythm.beats_per_bar.max(1) as f64;
+    let pulse = if downbeat {
+        (1.0 - rhythm.beat_phase) as f32
+    } else {
+        0.0
+    };
+    painter.rect_stroke(
+        rect,
+        2.0,
+        egui::Stroke::new(
+            1.0 + pulse * 2.0,
+            Color32::from_gray(40 + (pulse * 180.0) as u8),
+        ),
+        egui::StrokeKind::Inside,
+    );
+
+    let radius = 7.0;
+    let area = rect.shrink(radius + 4.0);
+    let unplaced = fixtures.iter().filter(|f| f.position.is_none()).count();
+    let mut next_unplaced = 0;
+
+    for fixture in fixtures {
+        // Fixtures without a configured position are lined up along the front of the stage
+        let position = fixture.position.unwrap_or_else(|| {
+            next_unplaced += 1;
+            StagePosition {
+                x: next_unplaced as f32 / (unplaced + 1) as f32,
+                y: 1.0,
+            }
+        });
+        let center = Pos2::new(
+            area.min.x + position.x.clamp(0.0, 1.0) * area.width(),
+            area.min.y + position.y.clamp(0.0, 1.0) * area.height(),
+        );
+
+        let (r, g, b) = fixture.display_color();
+        let color = Color32::from_rgb(r, g, b);
+
+        // Beam direction for movers, assuming a 540 degree pan with the midpoint facing downstage
+        if let Some((pan, tilt)) = fixture.pan_tilt() {
+            let beam_color = if r as u16 + g as u16 + b as u16 > 0 {
+                color
+            } else {
+                Color32::from_gray(70)
+            };
+            // Where the head really is, for movers that can't keep up with their output
+            if let Some((actual_pan, actual_tilt)) = lagging_position(state, fixture) {
+                let end = beam_end(center, radius, actual_pan, actual_tilt);
+                painter.line_segment(
+                    [center, end],
+                    egui::Stroke::new(2.0, Color32::from_rgba_unmultiplied(255, 80, 40, 140)),
+                );
+            }
+            let end = beam_end(center, radius, pan, tilt);
+            painter.line_segment([center, end], egui::Stroke::new(2.0, beam_color));
+        }
+
+        painter.circle_filled(center, radius, color);
+        painter.circle_stroke(
+            center,
+            radius,
+            egui::Stroke::new(1.0, Color32::from_gray(90)),
+        );
+    }
+}
+
+fn beam_end(center: Pos2, radius: f32, pan: f32, tilt: f32) -> Pos2 {
+    let angle = (pan - 0.5) * std::f32::consts::TAU * 1.5;
+    let length = radius + 24.0 * ((tilt - 0.5).abs() * 2.0);
+    center + Vec2::new(angle.sin(), angle.cos()) * length
+}
+
+/// Pan and tilt the head has actually reached, from 0 to 1, when it's a few degrees behind
+/// its output
+fn lagging_position(state: &ConsoleState, fixture: &halo_fixtures::Fixture) -> Option<(f32, f32)> {
+    let motion = fixture.profile.motion?;
+    let head = state.motion.position(fixture.id)?;
+    if head.pan.lag().max(head.tilt.lag()) < 2.0 {
+        return None;
+    }
+    Some((
+        head.pan.actual / motion.pan_range,
+        head.tilt.actual / motion.tilt_range,
+    ))
+}
+
 fn render_fixture_pixels(
     ui: &mut egui::Ui,
     fixture: &halo_fixtures::Fixture,